@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+)
+
+// promptResolver returns an ambiguity resolver asking on out which way each
+// ambiguous require resolves, reading the answer from in, and recording it
+// in the config file at path for later builds
+func promptResolver(in io.Reader, out io.Writer, path string) bundler.AmbiguityResolver {
+	reader := bufio.NewReader(in)
+	return func(a bundler.Ambiguity) (string, error) {
+		choices := a.Choices()
+		fmt.Fprintf(out, "%s\n", warningStyle.Render(fmt.Sprintf("❓ %s, required by %s, is ambiguous:", a.Module, a.File)))
+		for i, choice := range choices {
+			label := "embed " + choice
+			if choice == bundler.DecisionExternal {
+				label = "leave as a runtime require"
+			}
+			fmt.Fprintf(out, "  %d) %s\n", i+1, label)
+		}
+
+		for {
+			fmt.Fprintf(out, "Choose 1-%d: ", len(choices))
+			line, err := reader.ReadString('\n')
+			answer := strings.TrimSpace(line)
+			if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(choices) {
+				choice := choices[n-1]
+				if err := config.WriteRequire(path, a.Module, choice); err != nil {
+					return "", err
+				}
+				fmt.Fprintf(out, "📝 Recorded %s -> %s in %s\n", a.Module, choice, path)
+				return choice, nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("no choice made: %w", err)
+			}
+		}
+	}
+}
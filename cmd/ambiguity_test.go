@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.FileName)
+	var out bytes.Buffer
+	resolve := promptResolver(strings.NewReader("3\nx\n2\n"), &out, path)
+
+	a := bundler.Ambiguity{Module: "Players.Local", File: "main.lua", Candidates: []string{"Players/Local.lua"}, External: true}
+	choice, err := resolve(a)
+	require.NoError(t, err)
+	assert.Equal(t, bundler.DecisionExternal, choice, "out-of-range and unparseable answers should be asked again")
+	assert.Contains(t, out.String(), "1) embed Players/Local.lua")
+	assert.Contains(t, out.String(), "2) leave as a runtime require")
+	assert.Equal(t, 3, strings.Count(out.String(), "Choose 1-2: "))
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Players.Local": bundler.DecisionExternal}, cfg.Requires)
+
+	_, err = resolve(bundler.Ambiguity{Module: "lib.json", File: "main.lua", Candidates: []string{"lib/json.lua", "lib/json/init.lua"}})
+	require.Error(t, err, "running out of input should fail the build")
+}
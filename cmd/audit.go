@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var auditRemotesCmd = &cobra.Command{
+	Use:   "audit-remotes",
+	Short: "Check remote dependencies for dead links, redirects and content changes",
+	Long: `Check every HTTP dependency without building.
+
+Dependencies are discovered from the entry file's dependency graph, past dead
+links and changed content, or taken from the lockfile with --from-lockfile. Each URL is HEAD-checked and reported
+as dead, redirected, served over plain HTTP, or changed versus the lockfile hash.
+Exits with status 1 when any issue is found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		configPath, _ := cmd.Flags().GetString("config")
+		fromLockfile, _ := cmd.Flags().GetBool("from-lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+
+		var urls []string
+		if fromLockfile {
+			if lock == nil {
+				fmt.Println(errorStyle.Render("❌ No lockfile found (use --lockfile)"))
+				os.Exit(1)
+			}
+			urls = lock.URLs()
+		} else {
+			fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+			urls, err = b.DiscoverRemoteURLs()
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+				os.Exit(1)
+			}
+		}
+
+		if len(urls) == 0 {
+			fmt.Println(successStyle.Render("✅ No remote dependencies"))
+			return
+		}
+
+		fmt.Println(infoStyle.Render(fmt.Sprintf("🔍 Auditing %d remote dependencies...", len(urls))))
+		fmt.Println()
+
+		issues := 0
+		for _, url := range urls {
+			audit := b.AuditRemote(url)
+			printRemoteAudit(audit)
+			if audit.HasIssues() {
+				issues++
+			}
+		}
+
+		fmt.Println()
+		if issues > 0 {
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %d of %d remote dependencies need attention", issues, len(urls))))
+			os.Exit(1)
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("✅ All %d remote dependencies are healthy", len(urls))))
+	},
+}
+
+// printRemoteAudit prints a single audit result
+func printRemoteAudit(a bundler.RemoteAudit) {
+	switch {
+	case a.Dead && a.Err != nil:
+		fmt.Printf("%s %s (%v)\n", errorStyle.Render("✗ dead"), a.URL, a.Err)
+	case a.Dead:
+		fmt.Printf("%s %s (status %d)\n", errorStyle.Render("✗ dead"), a.URL, a.StatusCode)
+	case a.HasIssues():
+		fmt.Printf("%s %s\n", warningStyle.Render("! issue"), a.URL)
+	default:
+		fmt.Printf("%s %s\n", successStyle.Render("✓ ok"), a.URL)
+	}
+
+	if a.Redirected {
+		fmt.Printf("    ↪ redirects to %s\n", a.FinalURL)
+	}
+	if a.Insecure {
+		fmt.Printf("    🔓 served over plain HTTP\n")
+	}
+	if a.Changed {
+		fmt.Printf("    ✎ content changed since it was locked\n")
+	}
+	if !a.Dead && a.Err != nil {
+		fmt.Printf("    ⚠️  %v\n", a.Err)
+	}
+}
+
+func init() {
+	auditRemotesCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file")
+	auditRemotesCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	auditRemotesCmd.Flags().String("lockfile", "", "Lockfile to compare content hashes against (default: lua-bundler.lock next to the entry file, if present)")
+	auditRemotesCmd.Flags().Bool("from-lockfile", false, "Audit URLs listed in the lockfile instead of resolving the dependency graph")
+	auditRemotesCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	addHTTPFlags(auditRemotesCmd)
+
+	rootCmd.AddCommand(auditRemotesCmd)
+}
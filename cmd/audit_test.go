@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRemotesCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"audit-remotes"})
+	require.NoError(t, err, "audit-remotes should be registered")
+	assert.Equal(t, auditRemotesCmd, cmd)
+
+	for _, name := range []string{"entry", "lockfile", "from-lockfile", "proxy", "http-timeout"} {
+		assert.NotNil(t, auditRemotesCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/constt/lua-bundler/internal/semver"
+	"github.com/spf13/cobra"
+)
+
+var bumpCmd = &cobra.Command{
+	Use:   "bump <major|minor|patch|version>",
+	Short: "Increase the project version in lua-bundler.json",
+	Long: `Bump the "version" in lua-bundler.json by a major, minor or patch step, or
+set it to an explicit semantic version. The version is written to the bundle
+header and defined as VERSION (with the build's content hash as BUILD_ID), so
+scripts can report it without editing it by hand.
+
+A project without a version starts from 0.0.0.`,
+	Example: `  lua-bundler bump patch
+  lua-bundler bump 2.0.0-rc.1 -e src/main.lua`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		configPath, _ := cmd.Flags().GetString("config")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		path := cfg.Path()
+		if path == "" {
+			path = configPath
+		}
+		if path == "" {
+			path = filepath.Join(projectDir(entryFile), config.FileName)
+		}
+
+		next, err := nextVersion(cfg.Version, args[0])
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if err := config.WriteVersion(path, next); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		previous := cfg.Version
+		if previous == "" {
+			previous = "(none)"
+		}
+		fmt.Printf("%s %s → %s\n", successStyle.Render("🔖 Version:"), previous, infoStyle.Render(next))
+		fmt.Printf("  %s\n", path)
+	},
+}
+
+// nextVersion applies a bump argument (major, minor, patch or an explicit
+// version) to the current version
+func nextVersion(current, arg string) (string, error) {
+	switch arg {
+	case "major", "minor", "patch":
+	default:
+		v, err := semver.Parse(arg)
+		if err != nil {
+			return "", err
+		}
+		return v.String(), nil
+	}
+
+	if current == "" {
+		current = "0.0.0"
+	}
+	v, err := semver.Parse(current)
+	if err != nil {
+		return "", fmt.Errorf("current version: %w", err)
+	}
+	next, err := v.Bump(arg)
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
+
+func init() {
+	bumpCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file; lua-bundler.json is looked up next to it")
+	bumpCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+
+	rootCmd.AddCommand(bumpCmd)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBumpCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"bump"})
+	require.NoError(t, err, "bump should be registered")
+	assert.Equal(t, bumpCmd, cmd)
+}
+
+func TestNextVersion(t *testing.T) {
+	next, err := nextVersion("1.4.0", "minor")
+	require.NoError(t, err)
+	assert.Equal(t, "1.5.0", next)
+
+	next, err = nextVersion("", "patch")
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.1", next, "projects without a version start from 0.0.0")
+
+	next, err = nextVersion("1.4.0", "v2.0.0-rc.1")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0-rc.1", next)
+
+	_, err = nextVersion("latest", "patch")
+	assert.Error(t, err)
+	_, err = nextVersion("1.4.0", "huge")
+	assert.Error(t, err)
+}
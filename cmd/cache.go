@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect the download cache",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Report how well the download cache served past builds",
+	Long: `Report the cache statistics accumulated by every build since they were last
+reset: lookups and hit rate, hits, misses, revalidations (cached copies that
+failed their hash or the lockfile and were downloaded again), bytes served
+from the cache and from the network, and the time hits saved, estimated
+from the average download. Entries expire 24 hours after they are stored.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		reset, _ := cmd.Flags().GetBool("reset")
+
+		c, err := cache.NewCache(true)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if reset {
+			if err := c.ResetStats(); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+			fmt.Println(successStyle.Render("✅ Cache stats reset"))
+			return
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		entries, size, err := c.Usage()
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(stats, "", "  ")
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Printf("%s %s\n", infoStyle.Render("💾 Download cache:"), c.GetCacheDir())
+		fmt.Print(formatCacheStats(stats, entries, size))
+	},
+}
+
+// formatCacheStats renders the accumulated stats and the cache's contents
+func formatCacheStats(stats cache.Stats, entries int, size int64) string {
+	var out strings.Builder
+	if stats.Builds == 0 {
+		out.WriteString("  No builds recorded yet\n")
+	} else {
+		fmt.Fprintf(&out, "  Builds:         %d since %s\n", stats.Builds, stats.Since.Local().Format("2006-01-02"))
+		fmt.Fprintf(&out, "  Lookups:        %d (%.0f%% hit rate)\n", stats.Lookups(), stats.HitRate()*100)
+		fmt.Fprintf(&out, "  Hits:           %d\n", stats.Hits)
+		fmt.Fprintf(&out, "  Misses:         %d\n", stats.Misses)
+		fmt.Fprintf(&out, "  Revalidations:  %d\n", stats.Revalidations)
+		fmt.Fprintf(&out, "  From cache:     %s\n", formatBytes(stats.CacheBytes))
+		fmt.Fprintf(&out, "  From network:   %s in %d download(s), %s\n", formatBytes(stats.NetworkBytes), stats.Downloads, formatDuration(stats.NetworkTime))
+		fmt.Fprintf(&out, "  Time saved:     ~%s\n", formatDuration(stats.TimeSaved(stats.AverageDownload())))
+	}
+	fmt.Fprintf(&out, "  Entries:        %d (%s on disk)\n", entries, formatBytes(size))
+	return out.String()
+}
+
+// cacheBuildSummary sums up how the cache served a build, estimating the
+// time saved from the average download of every build so far
+func cacheBuildSummary(build, total cache.Stats) string {
+	return fmt.Sprintf("%d hit(s), %d miss(es), %d revalidation(s), %.0f%% hit rate · %s from cache, %s downloaded · ~%s saved",
+		build.Hits, build.Misses, build.Revalidations, build.HitRate()*100,
+		formatBytes(build.CacheBytes), formatBytes(build.NetworkBytes),
+		formatDuration(build.TimeSaved(total.AverageDownload())))
+}
+
+// formatBytes renders a size in B, KB or MB
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// formatDuration renders a duration to a tenth of a second, or in
+// milliseconds below one second
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+func init() {
+	cacheStatsCmd.Flags().Bool("json", false, "Print the stats as JSON")
+	cacheStatsCmd.Flags().Bool("reset", false, "Forget the recorded stats")
+
+	cacheCmd.AddCommand(cacheStatsCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
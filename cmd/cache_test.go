@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KB", formatBytes(1536))
+	assert.Equal(t, "2.0 MB", formatBytes(2<<20))
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "250ms", formatDuration(250*time.Millisecond))
+	assert.Equal(t, "1.5s", formatDuration(1500*time.Millisecond))
+}
+
+func TestCacheBuildSummary(t *testing.T) {
+	build := cache.Stats{Hits: 3, Misses: 1, CacheBytes: 2048, NetworkBytes: 512, Downloads: 1, NetworkTime: 100 * time.Millisecond}
+	total := cache.Stats{Downloads: 4, NetworkTime: 800 * time.Millisecond}
+
+	assert.Equal(t, "3 hit(s), 1 miss(es), 0 revalidation(s), 75% hit rate · 2.0 KB from cache, 512 B downloaded · ~600ms saved",
+		cacheBuildSummary(build, total))
+}
+
+func TestFormatCacheStats(t *testing.T) {
+	assert.Equal(t, "  No builds recorded yet\n  Entries:        0 (0 B on disk)\n", formatCacheStats(cache.Stats{}, 0, 0))
+
+	stats := cache.Stats{
+		Builds: 2, Hits: 1, Misses: 1, Downloads: 1,
+		CacheBytes: 10, NetworkBytes: 20, NetworkTime: 2 * time.Second,
+		Since: time.Date(2026, 1, 2, 12, 0, 0, 0, time.Local),
+	}
+	out := formatCacheStats(stats, 1, 30)
+	assert.Contains(t, out, "Builds:         2 since 2026-01-02")
+	assert.Contains(t, out, "Lookups:        2 (50% hit rate)")
+	assert.Contains(t, out, "From network:   20 B in 1 download(s), 2.0s")
+	assert.Contains(t, out, "Time saved:     ~2.0s")
+	assert.Contains(t, out, "Entries:        1 (30 B on disk)")
+}
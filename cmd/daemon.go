@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/constt/lua-bundler/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Serve the bundler over a local JSON-RPC API for editors and tools",
+	Long: `Run a long-lived bundler process answering JSON-RPC 2.0 requests on
+http://127.0.0.1:<port>/rpc, so editor extensions and other tools avoid process
+startup and a full re-resolution on every request.
+
+Methods take an "entry" parameter and configure the bundler from the
+lua-bundler.json and lua-bundler.lock next to it:
+
+  resolve      dependency graph, modules, externals and diagnostics
+  build        bundle (params: entry, release), cached until a file changes
+  diagnostics  warnings and resolution errors with file and line
+  watch        subscribe to changes; events stream from /events?subscription=<id>
+  unwatch      end a subscription (params: subscription)
+
+The daemon only listens on the loopback interface.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		port, _ := cmd.Flags().GetInt("port")
+		target, _ := cmd.Flags().GetString("target")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+		pollInterval, _ := cmd.Flags().GetDuration("poll-interval")
+		workers, _ := cmd.Flags().GetInt("workers")
+		httpOptions := httpOptionsFromFlags(cmd)
+
+		if pollInterval <= 0 {
+			fmt.Println(errorStyle.Render("❌ --poll-interval must be positive"))
+			os.Exit(1)
+		}
+
+		server := daemon.NewServer(func(entry string) (*bundler.Bundler, error) {
+			return projectBundler(entry, target, !noCache, httpOptions)
+		})
+		server.SetWorkers(workers)
+
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		go server.Run(pollInterval, nil)
+
+		fmt.Println(successStyle.Render(fmt.Sprintf("🛰️  Daemon listening on http://%s/rpc", listener.Addr())))
+		fmt.Println(infoStyle.Render("Press Ctrl+C to stop"))
+		if err := http.Serve(listener, server.Handler()); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+	},
+}
+
+// projectBundler creates a bundler for entry configured from the project's
+// config and lockfile, the way a build without extra flags would be
+func projectBundler(entry, target string, useCache bool, httpOptions bundler.HTTPOptions) (*bundler.Bundler, error) {
+	cfg, err := loadConfig("", entry)
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		target = cfg.Target
+	}
+	if target == "" {
+		target = bundler.TargetRoblox
+	}
+	lock, err := loadLockfile("", entry)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := cache.NewCache(useCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	b, err := bundler.New(entry, bundler.WithCache(c), bundler.WithTarget(target))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundler: %w", err)
+	}
+	b.SetVariants(cfg.Variants)
+	b.SetRequireDecisions(cfg.Requires)
+	b.SetMirrors(cfg.Mirrors)
+	if lock != nil {
+		b.SetLockfile(lock)
+	}
+	if err := b.SetHTTPOptions(httpOptions); err != nil {
+		return nil, err
+	}
+	if err := applyDefines(b, cfg.Defines, nil); err != nil {
+		return nil, err
+	}
+	if err := b.SuppressWarnings(cfg.SuppressWarnings); err != nil {
+		return nil, err
+	}
+	if err := b.SetKeepPatterns(cfg.KeepPatterns); err != nil {
+		return nil, err
+	}
+	if err := b.SetLogShim(cfg.LogShim); err != nil {
+		return nil, err
+	}
+	if err := b.SetDebugCalls(cfg.DebugCalls); err != nil {
+		return nil, err
+	}
+	if err := b.SetLoader(cfg.Loader); err != nil {
+		return nil, err
+	}
+	if err := b.SetResolution(cfg.Resolution); err != nil {
+		return nil, err
+	}
+	b.SetNoMemoize(cfg.NoMemoize)
+	b.SetHooks(cfg.Prelude, cfg.Epilogue)
+	b.SetRequestShim(cfg.RequestShim)
+	b.SetStrict(cfg.Strict)
+	b.SetSafeWrap(cfg.SafeWrap)
+	b.SetErrorHandler(cfg.ErrorHandler)
+	b.SetStateKey(cfg.StateKey)
+	if err := b.SetSizeLimit(cfg.SizeLimit); err != nil {
+		return nil, err
+	}
+	if err := applyEncoding(b, cfg, "", ""); err != nil {
+		return nil, err
+	}
+	obfuscation, err := cfg.Obfuscation.Pipeline()
+	if err != nil {
+		return nil, err
+	}
+	if err := applyObfuscation(b, cfg, obfuscation, nil); err != nil {
+		return nil, err
+	}
+	if err := applyStubs(b, cfg, nil, nil); err != nil {
+		return nil, err
+	}
+	if err := applyLocales(b, cfg, nil, ""); err != nil {
+		return nil, err
+	}
+	applyLualib(b, cfg, nil)
+	applyAddonLibs(b, cfg, nil)
+	if err := b.SetVersion(cfg.Version); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func init() {
+	daemonCmd.Flags().IntP("port", "p", 7420, "Port to listen on (loopback only)")
+	daemonCmd.Flags().StringP("target", "t", "", "Runtime target used for every project (default: each project's config target, then roblox)")
+	daemonCmd.Flags().Duration("poll-interval", 500*time.Millisecond, "How often watched files are checked for changes")
+	daemonCmd.Flags().Int("workers", 0, "Resolutions and builds run at once for parallel requests (0 = one per CPU)")
+	daemonCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache for remote scripts")
+	addHTTPFlags(daemonCmd)
+
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"daemon"})
+	require.NoError(t, err, "daemon should be registered")
+	assert.Equal(t, daemonCmd, cmd)
+
+	for _, name := range []string{"port", "target", "poll-interval", "no-cache", "proxy"} {
+		assert.NotNil(t, daemonCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestProjectBundlerAppliesConfig(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("print(DEBUG)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "checks.lua"), []byte("assert(game)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lua-bundler.json"), []byte(`{"loader": "lazy", "defines": {"DEBUG": "false"}, "version": "1.2.0", "prelude": ["checks"]}`), 0644))
+
+	b, err := projectBundler(entry, "", false, bundler.HTTPOptions{})
+	require.NoError(t, err)
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, "local DEBUG = false")
+	assert.Contains(t, out, "1.2.0")
+	assert.Contains(t, out, "-- Prelude\nlocal _ = loadModule(\"checks\")\n")
+
+	require.NoError(t, os.WriteFile(entry, []byte("print(\"hi\")\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lua-bundler.json"), []byte(`{"obfuscation": {"passes": [{"pass": "strings", "method": "escape"}]}}`), 0644))
+	b, err = projectBundler(entry, "", false, bundler.HTTPOptions{})
+	require.NoError(t, err)
+	out, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, `print("\104\105")`)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lua-bundler.json"), []byte(`{"loader": "eager"}`), 0644))
+	_, err = projectBundler(entry, "", false, bundler.HTTPOptions{})
+	assert.Error(t, err, "invalid config values are reported")
+
+	_, err = projectBundler(entry, "playstation", false, bundler.HTTPOptions{})
+	assert.Error(t, err)
+}
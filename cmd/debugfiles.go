@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	httpserver "github.com/constt/lua-bundler/internal/http"
+)
+
+// Names of the files --debug-files writes
+const (
+	debugMapFile      = "map.json"
+	debugManifestFile = "manifest.json"
+)
+
+// debugFiles returns the source map and manifest of the bundle written to
+// outputFile, for the server to hand to developers
+func debugFiles(b *bundler.Bundler, outputFile, version string, release bool, bundle []byte) (*httpserver.DebugFiles, error) {
+	sourceMap, manifest, err := encodeDebugFiles(b, filepath.Base(outputFile), version, release)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(bundle)
+	return httpserver.NewDebugFiles(sourceMap, manifest, hex.EncodeToString(sum[:])), nil
+}
+
+// encodeDebugFiles returns the source map, nil when the build has none,
+// and the manifest of the bundle named name
+func encodeDebugFiles(b *bundler.Bundler, name, version string, release bool) (sourceMap, manifest []byte, err error) {
+	// The bundler drops the line ranges when minifying or stripping moves lines
+	if b.SourceMap() != nil {
+		if sourceMap, err = b.SourceMapJSON(name); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode source map: %w", err)
+		}
+		sourceMap = append(sourceMap, '\n')
+	}
+	manifest, err = json.MarshalIndent(b.Manifest(strings.TrimSuffix(name, filepath.Ext(name)), version, release), "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return sourceMap, append(manifest, '\n'), nil
+}
+
+// writeDebugFiles writes the source map and manifest of the bundle named
+// name to dir, for the --serve-maps server that started this build
+func writeDebugFiles(b *bundler.Bundler, dir, name, version string, release bool) error {
+	sourceMap, manifest, err := encodeDebugFiles(b, name, version, release)
+	if err != nil {
+		return err
+	}
+	if sourceMap != nil {
+		if err := os.WriteFile(filepath.Join(dir, debugMapFile), sourceMap, 0644); err != nil {
+			return fmt.Errorf("failed to write source map: %w", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, debugManifestFile), manifest, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// debugRefresh updates the files a --serve-maps server hands out as the
+// bundle is rebuilt. The rebuilds run in child processes, which write
+// their debug files for the server to read back.
+type debugRefresh struct {
+	files *httpserver.DebugFiles
+	name  string // of the served bundle, which watched builds write under another
+}
+
+// rebuild runs build, a child build of the bundle to output taking extra
+// arguments, and updates the files with the ones it wrote
+func (d *debugRefresh) rebuild(output string, build func(extra ...string) (string, error)) (string, error) {
+	dir, err := os.MkdirTemp("", "lua-bundler-debug-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	out, err := build("--debug-files="+dir, "--debug-name="+d.name)
+	if err != nil {
+		return out, err
+	}
+	sourceMap, err := os.ReadFile(filepath.Join(dir, debugMapFile))
+	if os.IsNotExist(err) {
+		sourceMap, err = nil, nil
+	}
+	if err != nil {
+		return out, fmt.Errorf("failed to read the rebuilt source map: %w", err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, debugManifestFile))
+	if err != nil {
+		return out, fmt.Errorf("failed to read the rebuilt manifest: %w", err)
+	}
+	bundle, err := os.ReadFile(output)
+	if err != nil {
+		return out, err
+	}
+	sum := sha256.Sum256(bundle)
+	d.files.Update(sourceMap, manifest, hex.EncodeToString(sum[:]))
+	return out, nil
+}
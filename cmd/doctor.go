@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/constt/lua-bundler/internal/doctor"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment and project setup, suggesting fixes for problems",
+	Long: `Run pre-flight checks before building:
+
+  cache     the HTTP cache directory is writable
+  config    lua-bundler.json parses and its targets, variant files, mirrors,
+            shortener, version and defines are valid
+  lockfile  pinned hashes are well formed and cached copies still match them
+  network   hosts of pinned dependencies, mirrors and the shortener respond
+  tools     optional tools (git, luau-compile, luau) are on the PATH
+
+Exits with status 1 when a check fails; warnings do not change the status.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		offline, _ := cmd.Flags().GetBool("offline")
+
+		var results []doctor.Result
+
+		c, err := cache.NewCache(true)
+		if err != nil {
+			results = append(results, doctor.Result{
+				Check:   "cache",
+				Status:  doctor.Fail,
+				Message: err.Error(),
+				Fix:     "make sure your home directory is writable, or build with --no-cache",
+			})
+		} else {
+			results = append(results, doctor.CacheDir(c.GetCacheDir()))
+		}
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			results = append(results, doctor.Result{
+				Check:   "config",
+				Status:  doctor.Fail,
+				Message: err.Error(),
+				Fix:     "fix the JSON syntax of the config file",
+			})
+		} else {
+			results = append(results, doctor.Config(cfg, projectDir(entryFile))...)
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			results = append(results, doctor.Result{
+				Check:   "lockfile",
+				Status:  doctor.Fail,
+				Message: err.Error(),
+				Fix:     "fix the JSON syntax of the lockfile, or delete it and rebuild with --lockfile to pin again",
+			})
+		} else {
+			results = append(results, doctor.Lockfile(lock, func(url string) (string, bool) {
+				if c == nil {
+					return "", false
+				}
+				content, ok, err := c.Get(url)
+				return content, ok && err == nil
+			})...)
+		}
+
+		if !offline {
+			var urls []string
+			if lock != nil {
+				urls = append(urls, lock.URLs()...)
+			}
+			if cfg != nil {
+				for source, mirrors := range cfg.Mirrors {
+					urls = append(urls, source)
+					urls = append(urls, mirrors...)
+				}
+				if cfg.Shortener != "" {
+					urls = append(urls, cfg.Shortener)
+				}
+			}
+
+			b, err := bundler.NewBundler(entryFile, false, false)
+			if err == nil {
+				err = b.SetHTTPOptions(httpOptionsFromFlags(cmd))
+			}
+			if err != nil {
+				results = append(results, doctor.Result{Check: "network", Status: doctor.Fail, Message: err.Error(), Fix: "fix the HTTP flags"})
+			} else {
+				results = append(results, doctor.Hosts(urls, b.Ping)...)
+			}
+		}
+
+		results = append(results, doctor.Tools(exec.LookPath)...)
+
+		if failed := printDoctorResults(results); failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// printDoctorResults prints each result with its fix, returning the number of failures
+func printDoctorResults(results []doctor.Result) int {
+	failed, warned := 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case doctor.Pass:
+			fmt.Printf("%s %-9s %s\n", successStyle.Render("✓"), r.Check, r.Message)
+		case doctor.Warn:
+			warned++
+			fmt.Printf("%s %-9s %s\n", warningStyle.Render("!"), r.Check, r.Message)
+		case doctor.Fail:
+			failed++
+			fmt.Printf("%s %-9s %s\n", errorStyle.Render("✗"), r.Check, r.Message)
+		}
+		if r.Status != doctor.Pass && r.Fix != "" {
+			fmt.Printf("            %s %s\n", infoStyle.Render("→"), r.Fix)
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case failed > 0:
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %d problems, %d warnings", failed, warned)))
+	case warned > 0:
+		fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  No problems, %d warnings", warned)))
+	default:
+		fmt.Println(successStyle.Render("✅ Everything looks good"))
+	}
+	return failed
+}
+
+func init() {
+	doctorCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file; project files are looked up next to it")
+	doctorCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	doctorCmd.Flags().String("lockfile", "", "Lockfile to check (default: lua-bundler.lock next to the entry file, if present)")
+	doctorCmd.Flags().Bool("offline", false, "Skip the network checks")
+	addHTTPFlags(doctorCmd)
+
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/doctor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctorCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"doctor"})
+	require.NoError(t, err, "doctor should be registered")
+	assert.Equal(t, doctorCmd, cmd)
+
+	for _, name := range []string{"entry", "config", "lockfile", "offline", "proxy"} {
+		assert.NotNil(t, doctorCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestPrintDoctorResults(t *testing.T) {
+	failed := printDoctorResults([]doctor.Result{
+		{Check: "cache", Status: doctor.Pass, Message: "ok"},
+		{Check: "tools", Status: doctor.Warn, Message: "luau not found", Fix: "install it"},
+		{Check: "config", Status: doctor.Fail, Message: "bad target", Fix: "fix it"},
+	})
+	assert.Equal(t, 1, failed, "warnings should not count as failures")
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var executorsCmd = &cobra.Command{
+	Use:   "executors",
+	Short: "Report the executor-specific APIs a bundle uses and where it runs",
+	Long: `List the executor-specific functions the entry file and its modules use,
+such as hookfunction, getgenv or syn.request, with a compatibility matrix
+for Synapse X, Script-Ware, Fluxus and KRNL.
+
+APIs the code falls back between, as in syn and syn.request or request, or
+in an if statement checking for them, count as alternatives: an executor
+providing any of them is compatible. APIs used only inside an if checking
+for them first, without an else branch, are optional.
+With --require, exits with status 1 unless every listed executor is
+compatible.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+		requiredExecutors, _ := cmd.Flags().GetStringSlice("require")
+
+		for _, executor := range requiredExecutors {
+			if _, ok := bundler.ExecutorNames[executor]; !ok {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Unknown executor %q (expected one of: %s)", executor, strings.Join(bundler.Executors, ", "))))
+				os.Exit(1)
+			}
+		}
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if err := applyStubs(b, cfg, nil, nil); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+
+		report := b.ExecutorCompatibility()
+		fmt.Println()
+		if len(report.APIs) == 0 {
+			fmt.Println(successStyle.Render("✅ No executor-specific APIs used"))
+			return
+		}
+		fmt.Print(formatExecutorMatrix(report))
+		fmt.Println()
+
+		supported := report.Supported()
+		if len(supported) > 0 {
+			fmt.Println(successStyle.Render("✅ Compatible with: " + executorList(supported)))
+		}
+		failed := false
+		for _, executor := range bundler.Executors {
+			missing := report.Missing(executor)
+			if len(missing) == 0 {
+				continue
+			}
+			apis := make([]string, len(missing))
+			for i, req := range missing {
+				apis[i] = strings.Join(req.APIs, " or ")
+			}
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %s lacks: %s", bundler.ExecutorNames[executor], strings.Join(apis, "; "))))
+			for _, required := range requiredExecutors {
+				if required == executor {
+					failed = true
+				}
+			}
+		}
+		if failed {
+			fmt.Println(errorStyle.Render("❌ A required executor is not compatible"))
+			os.Exit(1)
+		}
+	},
+}
+
+// formatExecutorMatrix renders the APIs used against the executors
+// providing them
+func formatExecutorMatrix(report bundler.ExecutorReport) string {
+	names := make([]string, len(report.APIs))
+	width := len("API")
+	for i, api := range report.APIs {
+		names[i] = api.API
+		if api.Optional {
+			names[i] += " (optional)"
+		}
+		width = max(width, len(names[i]))
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%-*s", width, "API")
+	for _, executor := range bundler.Executors {
+		fmt.Fprintf(&out, "  %s", bundler.ExecutorNames[executor])
+	}
+	out.WriteString("\n")
+	for i, api := range report.APIs {
+		row := fmt.Sprintf("%-*s", width, names[i])
+		for _, executor := range bundler.Executors {
+			mark := "-"
+			for _, e := range api.Executors {
+				if e == executor {
+					mark = "✓"
+				}
+			}
+			row += fmt.Sprintf("  %-*s", len(bundler.ExecutorNames[executor]), mark)
+		}
+		out.WriteString(strings.TrimRight(row, " ") + "\n")
+	}
+	return out.String()
+}
+
+// executorList joins the display names of executors
+func executorList(executors []string) string {
+	names := make([]string, len(executors))
+	for i, executor := range executors {
+		names[i] = bundler.ExecutorNames[executor]
+	}
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	executorsCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	executorsCmd.Flags().StringSlice("require", nil, "Exit with status 1 unless these executors are compatible ("+strings.Join(bundler.Executors, ", ")+")")
+	executorsCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: config target, then roblox)")
+	executorsCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	executorsCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	executorsCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	addHTTPFlags(executorsCmd)
+
+	rootCmd.AddCommand(executorsCmd)
+}
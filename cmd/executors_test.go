@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutorsCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"executors"})
+	require.NoError(t, err, "executors should be registered")
+	assert.Equal(t, executorsCmd, cmd)
+
+	for _, name := range []string{"entry", "require", "config", "lockfile", "proxy"} {
+		assert.NotNil(t, executorsCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestFormatExecutorMatrix(t *testing.T) {
+	report := bundler.ExecutorReport{APIs: []bundler.ExecutorAPIUse{
+		{API: "hookfunction", Executors: bundler.Executors},
+		{API: "syn.protect_gui", Executors: []string{bundler.ExecutorSynapse}, Optional: true},
+	}}
+	assert.Equal(t, ""+
+		"API                         Synapse X  Script-Ware  Fluxus  KRNL\n"+
+		"hookfunction                ✓          ✓            ✓       ✓\n"+
+		"syn.protect_gui (optional)  ✓          -            -       -\n",
+		formatExecutorMatrix(report))
+
+	assert.Equal(t, "Script-Ware, KRNL", executorList([]string{bundler.ExecutorScriptWare, bundler.ExecutorKRNL}))
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// cleanups are run before the build exits, on every path out of it
+var (
+	cleanupMu sync.Mutex
+	cleanups  []func()
+)
+
+// atExit registers f to run before the build exits, such as removing a
+// --git checkout
+func atExit(f func()) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	cleanups = append(cleanups, f)
+}
+
+// runCleanups runs the registered clean-ups, last registered first, once
+func runCleanups() {
+	cleanupMu.Lock()
+	pending := cleanups
+	cleanups = nil
+	cleanupMu.Unlock()
+	for i := len(pending) - 1; i >= 0; i-- {
+		pending[i]()
+	}
+}
+
+// exit runs the clean-ups and exits with code; the build exits through it
+// rather than os.Exit, which skips deferred calls
+func exit(code int) {
+	runCleanups()
+	os.Exit(code)
+}
+
+// exitOnSignal runs the clean-ups when the build is interrupted, which is
+// the only way out of serving and watching
+func exitOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		exit(130)
+	}()
+}
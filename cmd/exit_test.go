@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCleanups(t *testing.T) {
+	var ran []int
+	atExit(func() { ran = append(ran, 1) })
+	atExit(func() { ran = append(ran, 2) })
+	runCleanups()
+	runCleanups()
+	assert.Equal(t, []int{2, 1}, ran, "clean-ups run last registered first, once")
+}
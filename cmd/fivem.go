@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+)
+
+// writeResource writes a bundled manifest entry as a resource folder at dir.
+// b has bundled the client side as client; the server side is bundled with
+// the same settings. The folder holds a bundle per side that has scripts, a
+// manifest loading them in place of the merged scripts, and the other files
+// of the resource, such as its NUI page and streamed assets. It returns the
+// path of the first bundle written.
+func writeResource(b *bundler.Bundler, client string, release, licenses bool, entryFile, dir string) (string, error) {
+	server, err := b.ForSide(bundler.SideServer)
+	if err != nil {
+		return "", err
+	}
+	serverBundle, err := server.Bundle(release)
+	if err != nil {
+		return "", fmt.Errorf("server side: %w", err)
+	}
+	if licenses {
+		serverBundle += "\n" + bundler.LicenseComment(server.Licenses())
+	}
+
+	merged := map[string]bool{}
+	for _, file := range server.GetLocalFiles() {
+		if abs, err := filepath.Abs(file); err == nil {
+			merged[abs] = true
+		}
+	}
+	root := filepath.Dir(entryFile)
+	files, err := projectFiles(b, entryFile, dir, func(rel string) bool {
+		abs, err := filepath.Abs(filepath.Join(root, filepath.FromSlash(rel)))
+		return err == nil && merged[abs]
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to collect resource files: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, f.Data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	names := map[string]string{}
+	var first string
+	for _, side := range []struct {
+		name   string
+		b      *bundler.Bundler
+		bundle string
+	}{{bundler.SideClient, b, client}, {bundler.SideServer, server, serverBundle}} {
+		if len(side.b.ResourceScripts()) == 0 {
+			continue
+		}
+		names[side.name] = side.name + ".lua"
+		path := filepath.Join(dir, names[side.name])
+		if err := os.WriteFile(path, []byte(side.bundle), 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if first == "" {
+			first = path
+		}
+	}
+	if first == "" {
+		return "", fmt.Errorf("%s lists no scripts to bundle", filepath.Base(entryFile))
+	}
+
+	manifest := filepath.Join(dir, filepath.Base(entryFile))
+	if err := os.WriteFile(manifest, []byte(b.ResourceManifest(names)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", manifest, err)
+	}
+	return first, nil
+}
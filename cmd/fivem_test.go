@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteResource(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"fxmanifest.lua":   "fx_version 'cerulean'\nshared_script 'config.lua'\nclient_scripts { 'client/*.lua' }\nserver_script 'server.lua'\nui_page 'html/index.html'\n",
+		"config.lua":       "Config = {}\n",
+		"client/main.lua":  "print('client')\n",
+		"server.lua":       "print('server')\n",
+		"html/index.html":  "<html></html>\n",
+		"lua-bundler.json": "{}",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	entry := filepath.Join(dir, "fxmanifest.lua")
+	out := filepath.Join(dir, "dist", "my-resource")
+
+	b, err := bundler.NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetTarget(bundler.TargetFiveM))
+	client, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	first, err := writeResource(b, client, false, false, entry, out)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(out, "client.lua"), first)
+
+	var files []string
+	require.NoError(t, filepath.WalkDir(out, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(out, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	}))
+	assert.ElementsMatch(t, []string{"fxmanifest.lua", "client.lua", "server.lua", "html/index.html"}, files,
+		"merged scripts of either side and bundler files should be left out")
+
+	manifest, err := os.ReadFile(filepath.Join(out, "fxmanifest.lua"))
+	require.NoError(t, err)
+	assert.Equal(t, "fx_version 'cerulean'\nui_page 'html/index.html'\n\nclient_scripts {\n    \"client.lua\",\n}\n\nserver_scripts {\n    \"server.lua\",\n}\n", string(manifest))
+	server, err := os.ReadFile(filepath.Join(out, "server.lua"))
+	require.NoError(t, err)
+	assert.Contains(t, string(server), "print('server')")
+	assert.Contains(t, string(server), "Config = {}")
+	assert.NotContains(t, string(server), "print('client')")
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var globalsCmd = &cobra.Command{
+	Use:   "globals",
+	Short: "Report globals shared between modules outside the require graph",
+	Long: `List the globals each bundled file defines and the globals it reads from
+other files, then flag every global that couples files implicitly.
+
+A module reading a global another module sets depends on that module having
+run first, which the require graph does not show. Such code breaks when
+modules are reordered or when each module gets its own environment.
+Standard globals and globals provided by the runtime are not reported.
+With --strict, exits with status 1 when any coupling is found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+		strict, _ := cmd.Flags().GetBool("strict")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+
+		usage, couplings := b.GlobalUsage()
+		fmt.Println()
+		fmt.Print(formatGlobalUsage(usage))
+
+		fmt.Println()
+		if len(couplings) == 0 {
+			fmt.Println(successStyle.Render("✅ No modules are coupled through globals"))
+			return
+		}
+		fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %d global(s) couple modules implicitly:", len(couplings))))
+		for _, c := range couplings {
+			fmt.Printf("  %s\n", formatCoupling(c))
+		}
+		if strict {
+			os.Exit(1)
+		}
+	},
+}
+
+// formatGlobalUsage lists the files that define or read shared globals
+func formatGlobalUsage(usage []bundler.GlobalUsage) string {
+	var out strings.Builder
+	for _, u := range usage {
+		if len(u.Defines) == 0 && len(u.Reads) == 0 {
+			continue
+		}
+		out.WriteString(u.Module + "\n")
+		if len(u.Defines) > 0 {
+			fmt.Fprintf(&out, "  defines: %s\n", strings.Join(u.Defines, ", "))
+		}
+		if len(u.Reads) > 0 {
+			fmt.Fprintf(&out, "  reads:   %s\n", strings.Join(u.Reads, ", "))
+		}
+	}
+	if out.Len() == 0 {
+		return "No file defines globals\n"
+	}
+	return out.String()
+}
+
+// formatCoupling describes one global shared between files
+func formatCoupling(c bundler.GlobalCoupling) string {
+	s := fmt.Sprintf("%s: defined by %s", c.Global, strings.Join(c.DefinedBy, ", "))
+	if len(c.ReadBy) > 0 {
+		s += fmt.Sprintf("; read by %s", strings.Join(c.ReadBy, ", "))
+	}
+	return s
+}
+
+func init() {
+	globalsCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	globalsCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: config target, then roblox)")
+	globalsCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	globalsCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	globalsCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	globalsCmd.Flags().Bool("strict", false, "Exit with status 1 when modules are coupled through globals")
+	addHTTPFlags(globalsCmd)
+
+	rootCmd.AddCommand(globalsCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobalsCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"globals"})
+	require.NoError(t, err, "globals should be registered")
+	assert.Equal(t, globalsCmd, cmd)
+
+	for _, name := range []string{"entry", "config", "lockfile", "strict", "proxy"} {
+		assert.NotNil(t, globalsCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestFormatGlobalUsage(t *testing.T) {
+	assert.Equal(t, "main.lua\n  reads:   Settings\nstate\n  defines: Settings, Shared\n", formatGlobalUsage([]bundler.GlobalUsage{
+		{Module: "main.lua", Reads: []string{"Settings"}},
+		{Module: "state", Defines: []string{"Settings", "Shared"}},
+		{Module: "util"},
+	}))
+	assert.Equal(t, "No file defines globals\n", formatGlobalUsage([]bundler.GlobalUsage{{Module: "main.lua"}}))
+
+	assert.Equal(t, "Settings: defined by state; read by main.lua, ui", formatCoupling(bundler.GlobalCoupling{Global: "Settings", DefinedBy: []string{"state"}, ReadBy: []string{"main.lua", "ui"}}))
+	assert.Equal(t, "Shared: defined by state, ui", formatCoupling(bundler.GlobalCoupling{Global: "Shared", DefinedBy: []string{"state", "ui"}}))
+}
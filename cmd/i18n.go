@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var i18nCmd = &cobra.Command{
+	Use:   "i18n",
+	Short: "Extract translatable strings into a locale file",
+	Long: `Collect the string literals passed to the locale function, L("Play") or
+L("menu.play", "Play"), from the entry file and every bundled module, and
+write them as a JSON locale file mapping each key to its source text.
+
+When the output file exists, its translations are kept: new strings are
+added with their source text and strings no longer used are reported, and
+removed with --prune. Translate a copy per language, such as locales/de.json,
+and bundle them with --locale.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		outputFile, _ := cmd.Flags().GetString("output")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+		prune, _ := cmd.Flags().GetBool("prune")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if err := applyStubs(b, cfg, nil, nil); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetLocaleFunction(cfg.LocaleFunction); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+		strs, err := b.ExtractStrings()
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		existing := map[string]string{}
+		if outputFile != "" {
+			if existing, err = readLocaleFile(outputFile); err != nil && !os.IsNotExist(err) {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+		}
+		merged, added, stale := mergeLocale(existing, strs, prune)
+
+		data, err := json.MarshalIndent(merged, "", "  ")
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		data = append(data, '\n')
+		if outputFile == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(outputFile, data, 0644); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write locale file: %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(successStyle.Render(fmt.Sprintf("✅ %d string(s) written to %s, %d new", len(merged), outputFile, len(added))))
+		if len(stale) > 0 {
+			verb := "kept"
+			if prune {
+				verb = "removed"
+			}
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %d unused string(s) %s: %s", len(stale), verb, strings.Join(stale, ", "))))
+		}
+	},
+}
+
+// mergeLocale merges the extracted strings into an existing locale: its
+// translations are kept and new keys get their source text. It returns the
+// merged locale, the added keys and the existing keys no longer extracted,
+// which are dropped when prune is set.
+func mergeLocale(existing map[string]string, strs []bundler.LocaleString, prune bool) (merged map[string]string, added, stale []string) {
+	merged = make(map[string]string, len(existing)+len(strs))
+	used := make(map[string]bool, len(strs))
+	for _, s := range strs {
+		used[s.Key] = true
+		if text, ok := existing[s.Key]; ok {
+			merged[s.Key] = text
+			continue
+		}
+		merged[s.Key] = s.Text
+		added = append(added, s.Key)
+	}
+	for key, text := range existing {
+		if used[key] {
+			continue
+		}
+		stale = append(stale, key)
+		if !prune {
+			merged[key] = text
+		}
+	}
+	sort.Strings(stale)
+	return merged, added, stale
+}
+
+// readLocaleFile reads a JSON locale file mapping keys to texts
+func readLocaleFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var texts map[string]string
+	if err := json.Unmarshal(data, &texts); err != nil {
+		return nil, fmt.Errorf("invalid locale file %s: %w", path, err)
+	}
+	return texts, nil
+}
+
+// loadLocales reads locale files by locale name, the file name without
+// extension; files of the same locale are merged, later ones taking
+// precedence. It also returns the locale names in order of first appearance.
+func loadLocales(paths []string) (map[string]map[string]string, []string, error) {
+	locales := make(map[string]map[string]string)
+	var names []string
+	for _, path := range paths {
+		texts, err := readLocaleFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read locale: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if locales[name] == nil {
+			locales[name] = make(map[string]string, len(texts))
+			names = append(names, name)
+		}
+		for key, text := range texts {
+			locales[name][key] = text
+		}
+	}
+	return locales, names, nil
+}
+
+// applyLocales enables translation when the config sets a locale function
+// or locale files are given, embedding the config locales and the --locale
+// files. Config paths are relative to the config file. The selected locale
+// is defaultLocale, the config locale, then the first one.
+func applyLocales(b *bundler.Bundler, cfg *config.Config, localeFiles []string, defaultLocale string) error {
+	paths := make([]string, 0, len(cfg.Locales)+len(localeFiles))
+	for _, path := range cfg.Locales {
+		if cfg.Path() != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(cfg.Path()), path)
+		}
+		paths = append(paths, path)
+	}
+	paths = append(paths, localeFiles...)
+	if len(paths) == 0 && cfg.LocaleFunction == "" {
+		return nil
+	}
+
+	function := cfg.LocaleFunction
+	if function == "" {
+		function = bundler.DefaultLocaleFunction
+	}
+	if err := b.SetLocaleFunction(function); err != nil {
+		return err
+	}
+
+	locales, names, err := loadLocales(paths)
+	if err != nil {
+		return err
+	}
+	if defaultLocale == "" {
+		defaultLocale = cfg.Locale
+	}
+	if defaultLocale == "" && len(names) > 0 {
+		defaultLocale = names[0]
+	}
+	return b.SetLocales(locales, defaultLocale)
+}
+
+func init() {
+	i18nCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	i18nCmd.Flags().StringP("output", "o", "", "Locale file to write or update (default: print to stdout)")
+	i18nCmd.Flags().Bool("prune", false, "Remove strings the sources no longer use from the output file")
+	i18nCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: config target, then roblox)")
+	i18nCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	i18nCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	i18nCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	addHTTPFlags(i18nCmd)
+
+	rootCmd.AddCommand(i18nCmd)
+}
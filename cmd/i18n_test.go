@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestI18nCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"i18n"})
+	require.NoError(t, err, "i18n should be registered")
+	assert.Equal(t, i18nCmd, cmd)
+
+	for _, name := range []string{"entry", "output", "prune", "config", "lockfile", "proxy"} {
+		assert.NotNil(t, i18nCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+	for _, name := range []string{"locale", "default-locale"} {
+		assert.NotNil(t, rootCmd.Flags().Lookup(name), "Flag %q not found", name)
+		assert.NotNil(t, packageCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestMergeLocale(t *testing.T) {
+	existing := map[string]string{"Hello": "Hallo", "Old": "Alt"}
+	strs := []bundler.LocaleString{{Key: "Hello", Text: "Hello"}, {Key: "menu.title", Text: "Main menu"}}
+
+	merged, added, stale := mergeLocale(existing, strs, false)
+	assert.Equal(t, map[string]string{"Hello": "Hallo", "menu.title": "Main menu", "Old": "Alt"}, merged)
+	assert.Equal(t, []string{"menu.title"}, added)
+	assert.Equal(t, []string{"Old"}, stale)
+
+	merged, _, stale = mergeLocale(existing, strs, true)
+	assert.NotContains(t, merged, "Old", "--prune should drop unused strings")
+	assert.Equal(t, []string{"Old"}, stale)
+}
+
+func TestApplyLocales(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("print(L(\"Hello\"))\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "locales"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "locales", "de.json"), []byte(`{"Hello": "Hallo", "Bye": "Tschüss"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte(`{"locales": ["locales/de.json"]}`), 0644))
+	override := filepath.Join(dir, "de.json")
+	require.NoError(t, os.WriteFile(override, []byte(`{"Bye": "Ciao"}`), 0644))
+	french := filepath.Join(dir, "fr.json")
+	require.NoError(t, os.WriteFile(french, []byte(`{"Hello": "Bonjour"}`), 0644))
+
+	cfg, err := loadConfig("", entry)
+	require.NoError(t, err)
+	b, err := bundler.NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, applyLocales(b, cfg, []string{override, french}, ""))
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, `local L = setmetatable({locale = "de", locales = {["de"] = {["Bye"] = "Ciao", ["Hello"] = "Hallo"}, ["fr"] = {["Hello"] = "Bonjour"}}}`,
+		"files of one locale should be merged, and the first locale selected")
+
+	assert.ErrorContains(t, applyLocales(b, cfg, nil, "es"), `unknown locale "es"`)
+	assert.Error(t, applyLocales(b, cfg, []string{filepath.Join(dir, "missing.json")}, ""))
+
+	b, err = bundler.NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, applyLocales(b, &config.Config{}, nil, ""))
+	result, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "local L =", "translation should stay disabled without locales or a locale function")
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/constt/lua-bundler/internal/inject"
+)
+
+// injectOptions are where --inject delivers the bundle
+type injectOptions struct {
+	Executors map[string]config.Executor // by name
+	Names     []string                   // the executors to deliver to
+	Autoexec  bool                       // also write to their autoexec folders
+	Execute   bool                       // also run the bundle through their execute APIs
+}
+
+// check returns an error when an executor is unknown or lacks what the
+// options need
+func (o injectOptions) check() error {
+	for _, name := range o.Names {
+		executor, ok := o.Executors[name]
+		if !ok {
+			known := make([]string, 0, len(o.Executors))
+			for n := range o.Executors {
+				known = append(known, n)
+			}
+			sort.Strings(known)
+			if len(known) == 0 {
+				return fmt.Errorf("unknown executor %q: add it to \"executors\" in %s", name, config.FileName)
+			}
+			return fmt.Errorf("unknown executor %q (configured: %s)", name, strings.Join(known, ", "))
+		}
+		workspace, autoexec := executor.Folders()
+		switch {
+		case workspace == "":
+			return fmt.Errorf("executor %s: set dir or workspace", name)
+		case o.Autoexec && autoexec == "":
+			return fmt.Errorf("executor %s: --autoexec needs dir or autoexec", name)
+		case o.Execute && executor.Execute == "":
+			return fmt.Errorf("executor %s: --execute needs an execute URL", name)
+		}
+	}
+	return nil
+}
+
+// injectResult is how the bundle reached one executor
+type injectResult struct {
+	Name       string
+	Files      []string // the copies written
+	Executed   bool
+	ExecuteErr error // executors not attached to a game refuse scripts, so this only warns
+}
+
+// injectBundle copies the bundle into the executors' folders and runs it
+// through their execute APIs, as opts asks. Relative folders start at dir.
+func injectBundle(opts injectOptions, bundle, dir string) ([]injectResult, error) {
+	var results []injectResult
+	for _, name := range opts.Names {
+		executor := opts.Executors[name]
+		result := injectResult{Name: name}
+		workspace, autoexec := executor.Folders()
+		folders := []string{workspace}
+		if opts.Autoexec {
+			folders = append(folders, autoexec)
+		}
+		for _, folder := range folders {
+			// The file is named, so folders with dots in their names work
+			file, err := syncCopy(filepath.Join(folder, filepath.Base(bundle)), bundle, dir)
+			if err != nil {
+				return results, fmt.Errorf("inject into %s failed: %w", name, err)
+			}
+			result.Files = append(result.Files, file)
+		}
+		if opts.Execute {
+			script, err := os.ReadFile(bundle)
+			if err != nil {
+				return results, err
+			}
+			result.ExecuteErr = inject.Execute(executor.Execute, string(script))
+			result.Executed = result.ExecuteErr == nil
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// printInject reports where the bundle was injected
+func printInject(results []injectResult) {
+	for _, r := range results {
+		fmt.Printf("%s %s\n", infoStyle.Render(fmt.Sprintf("💉 %s:", r.Name)), strings.Join(r.Files, ", "))
+		if r.Executed {
+			fmt.Printf("%s %s\n", infoStyle.Render(fmt.Sprintf("▶️  %s:", r.Name)), "executed")
+		}
+		if r.ExecuteErr != nil {
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %s did not run the bundle: %v", r.Name, r.ExecuteErr)))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectOptions_Check(t *testing.T) {
+	executors := map[string]config.Executor{
+		"krnl":   {Dir: "krnl"},
+		"custom": {Workspace: "custom/scripts"},
+	}
+	assert.NoError(t, injectOptions{Executors: executors, Names: []string{"krnl", "custom"}}.check())
+	assert.NoError(t, injectOptions{Executors: executors, Names: []string{"krnl"}, Autoexec: true}.check())
+
+	assert.EqualError(t, injectOptions{Executors: executors, Names: []string{"fluxus"}}.check(),
+		`unknown executor "fluxus" (configured: custom, krnl)`)
+	assert.EqualError(t, injectOptions{Names: []string{"fluxus"}}.check(),
+		`unknown executor "fluxus": add it to "executors" in lua-bundler.json`)
+	assert.EqualError(t, injectOptions{Executors: executors, Names: []string{"custom"}, Autoexec: true}.check(),
+		"executor custom: --autoexec needs dir or autoexec")
+	assert.EqualError(t, injectOptions{Executors: executors, Names: []string{"krnl"}, Execute: true}.check(),
+		"executor krnl: --execute needs an execute URL")
+	assert.EqualError(t, injectOptions{Executors: map[string]config.Executor{"x": {}}, Names: []string{"x"}}.check(),
+		"executor x: set dir or workspace")
+}
+
+func TestInjectBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "dist", "hub.lua")
+	require.NoError(t, os.MkdirAll(filepath.Dir(bundle), 0755))
+	require.NoError(t, os.WriteFile(bundle, []byte("print('hub')"), 0644))
+
+	var executed string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		executed = string(body)
+	}))
+	defer api.Close()
+
+	opts := injectOptions{
+		Executors: map[string]config.Executor{
+			"krnl":    {Dir: "krnl", Execute: api.URL},
+			"offline": {Dir: "offline.exe", Execute: "http://127.0.0.1:1"},
+		},
+		Names:    []string{"krnl", "offline"},
+		Autoexec: true,
+		Execute:  true,
+	}
+	results, err := injectBundle(opts, bundle, dir)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "krnl", "workspace", "hub.lua"),
+		filepath.Join(dir, "krnl", "autoexec", "hub.lua"),
+	}, results[0].Files)
+	for _, file := range append(results[0].Files, results[1].Files...) {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, "print('hub')", string(data))
+	}
+	assert.True(t, results[0].Executed)
+	assert.Equal(t, "print('hub')", executed)
+
+	assert.Equal(t, filepath.Join(dir, "offline.exe", "workspace", "hub.lua"), results[1].Files[0], "folders with dots work")
+	assert.False(t, results[1].Executed)
+	assert.Error(t, results[1].ExecuteErr, "executors that are not running only warn")
+}
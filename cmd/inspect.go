@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <bundle>",
+	Short: "Show the format, version and embedded modules of a bundle",
+	Long: `Read the header and module markers of a bundle written by any version of
+lua-bundler: the bundle format, the version, build ID and git revision it was
+built from, and the keys of the modules it embeds, in bundle order.
+
+Bundles in a format newer than this lua-bundler knows are refused rather than
+misread. Minified bundles keep no header and cannot be inspected; inspect
+the dev build, or read the manifest.json that package writes.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		code, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to read bundle: %v", err)))
+			os.Exit(1)
+		}
+		info, err := bundler.ParseBundle(string(code))
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(info, "", "  ")
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Print(formatBundleInfo(info))
+	},
+}
+
+// formatBundleInfo renders what inspect found in a bundle
+func formatBundleInfo(info bundler.BundleInfo) string {
+	var out strings.Builder
+	format := fmt.Sprint(info.Format)
+	if info.Format == bundler.BundleFormatLegacy {
+		format += " (no format line)"
+	}
+	fmt.Fprintf(&out, "%s %s\n", infoStyle.Render("📐 Format:"), format)
+	if info.Version != "" {
+		fmt.Fprintf(&out, "%s %s (build %s)\n", infoStyle.Render("🔖 Version:"), info.Version, info.BuildID)
+	}
+	if info.Git != "" {
+		fmt.Fprintf(&out, "%s %s\n", infoStyle.Render("🌿 Git:"), info.Git)
+	}
+	fmt.Fprintf(&out, "%s %d\n", infoStyle.Render("📦 Modules:"), len(info.Modules))
+	for _, m := range info.Modules {
+		fmt.Fprintf(&out, "  %s\n", m)
+	}
+	return out.String()
+}
+
+func init() {
+	inspectCmd.Flags().Bool("json", false, "Print the details as JSON")
+
+	rootCmd.AddCommand(inspectCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"inspect"})
+	require.NoError(t, err, "inspect should be registered")
+	assert.Equal(t, inspectCmd, cmd)
+	assert.NotNil(t, inspectCmd.Flags().Lookup("json"))
+}
+
+func TestFormatBundleInfo(t *testing.T) {
+	assert.Equal(t, ""+
+		"📐 Format: 2\n"+
+		"🔖 Version: 1.4.0 (build 27c7d3e8ec83)\n"+
+		"📦 Modules: 2\n"+
+		"  net/http\n"+
+		"  util\n",
+		formatBundleInfo(bundler.BundleInfo{Format: 2, Version: "1.4.0", BuildID: "27c7d3e8ec83", Modules: []string{"net/http", "util"}}))
+
+	assert.Equal(t, ""+
+		"📐 Format: 1 (no format line)\n"+
+		"🌿 Git: 3f2a9c1d (dirty)\n"+
+		"📦 Modules: 0\n",
+		formatBundleInfo(bundler.BundleInfo{Format: 1, Git: "3f2a9c1d (dirty)"}))
+}
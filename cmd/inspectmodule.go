@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var inspectModuleCmd = &cobra.Command{
+	Use:   "inspect-module <file>",
+	Short: "Show a module at each stage of the transform pipeline",
+	Long: `Run one local module through the stages of a release build and show what
+each stage made of it, to find the pass that breaks a file:
+
+  raw         as read from disk
+  obfuscated  after its obfuscation passes, as embedded
+  stripped    comments and print/warn statements removed
+  optimized   local names shortened (minify level 2 and up)
+  minified    whitespace removed (minify level 1 and up)
+
+Obfuscation, keep patterns and the log shim come from the config next to the
+entry file, as in a build. Without --stage a summary of the stages is
+printed; one stage prints that stage's code, several print them side by side.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file := args[0]
+		entryFile, _ := cmd.Flags().GetString("entry")
+		configPath, _ := cmd.Flags().GetString("config")
+		target, _ := cmd.Flags().GetString("target")
+		preset, _ := cmd.Flags().GetString("obfuscate")
+		minifyLevel, _ := cmd.Flags().GetInt("minify")
+		selected, _ := cmd.Flags().GetStringSlice("stage")
+		width, _ := cmd.Flags().GetInt("width")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		for _, stage := range selected {
+			if !bundler.IsStage(stage) {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ unknown stage %q (expected one of: %s)", stage, strings.Join(bundler.Stages, ", "))))
+				os.Exit(1)
+			}
+		}
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		b, err := bundler.New(entryFile, bundler.WithTarget(target))
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		obfuscation, err := obfuscationPasses(cfg, preset)
+		if err == nil {
+			err = applyObfuscation(b, cfg, obfuscation, nil)
+		}
+		if err == nil {
+			err = b.SetKeepPatterns(cfg.KeepPatterns)
+		}
+		if err == nil {
+			err = b.SetLogShim(cfg.LogShim)
+		}
+		if err == nil {
+			err = b.SetDebugCalls(cfg.DebugCalls)
+		}
+		if err == nil {
+			err = b.SetMinifyLevel(minifyLevel)
+		}
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		stages, err := b.ModuleStages(moduleKeyForFile(entryFile, file), file)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if len(selected) > 0 {
+			stages = selectStages(stages, selected)
+		}
+
+		switch {
+		case asJSON:
+			data, err := json.MarshalIndent(stages, "", "  ")
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		case len(selected) == 0:
+			fmt.Print(formatStageSummary(stages))
+		case len(stages) == 1:
+			fmt.Print(stages[0].Content)
+			if !strings.HasSuffix(stages[0].Content, "\n") {
+				fmt.Println()
+			}
+		default:
+			if width <= 0 {
+				width = terminalWidth()
+			}
+			fmt.Print(sideBySide(stages, width))
+		}
+	},
+}
+
+// moduleKeyForFile returns the require path naming file from the entry
+// file's directory, which obfuscation rules match modules by
+func moduleKeyForFile(entryFile, file string) string {
+	rel, err := filepath.Rel(filepath.Dir(entryFile), file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = file
+	}
+	rel = filepath.ToSlash(rel)
+	rel = strings.TrimSuffix(strings.TrimSuffix(rel, ".lua"), ".luau")
+	rel = strings.TrimSuffix(rel, "/init")
+	return strings.ReplaceAll(rel, "/", ".")
+}
+
+// selectStages returns the stages named in names, in pipeline order
+func selectStages(stages []bundler.ModuleStage, names []string) []bundler.ModuleStage {
+	var selected []bundler.ModuleStage
+	for _, stage := range stages {
+		if containsName(names, stage.Stage) {
+			selected = append(selected, stage)
+		}
+	}
+	return selected
+}
+
+// containsName reports whether names holds name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatStageSummary renders the size of a module at each stage and what
+// each stage changed
+func formatStageSummary(stages []bundler.ModuleStage) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%-12s %8s %7s  %s\n", "STAGE", "BYTES", "LINES", "CHANGE")
+	for i, stage := range stages {
+		lines := strings.Count(stage.Content, "\n")
+		if stage.Content != "" && !strings.HasSuffix(stage.Content, "\n") {
+			lines++
+		}
+		change := "-"
+		if i > 0 {
+			prev := stages[i-1].Content
+			switch {
+			case stage.Content == prev:
+				change = "unchanged"
+			case len(prev) > 0:
+				change = fmt.Sprintf("%+.1f%%", float64(len(stage.Content)-len(prev))*100/float64(len(prev)))
+			default:
+				change = "changed"
+			}
+		}
+		fmt.Fprintf(&out, "%-12s %8d %7d  %s\n", stage.Stage, len(stage.Content), lines, change)
+	}
+	return out.String()
+}
+
+// sideBySide renders stages in columns across width characters, wrapping
+// lines that do not fit their column
+func sideBySide(stages []bundler.ModuleStage, width int) string {
+	const gap = " │ " // three columns wide
+	col := (width - 3*(len(stages)-1)) / len(stages)
+	if col < 10 {
+		col = 10
+	}
+	columns := make([][]string, len(stages))
+	rows := 0
+	for i, stage := range stages {
+		columns[i] = wrapColumn(strings.TrimRight(stage.Content, "\n"), col)
+		if len(columns[i]) > rows {
+			rows = len(columns[i])
+		}
+	}
+
+	var out strings.Builder
+	header := make([]string, len(stages))
+	rule := make([]string, len(stages))
+	for i, stage := range stages {
+		header[i] = padColumn(stage.Stage, col)
+		rule[i] = strings.Repeat("─", col)
+	}
+	out.WriteString(strings.TrimRight(strings.Join(header, gap), " ") + "\n")
+	out.WriteString(strings.Join(rule, "─┼─") + "\n")
+	cells := make([]string, len(stages))
+	for row := 0; row < rows; row++ {
+		for i := range columns {
+			cell := ""
+			if row < len(columns[i]) {
+				cell = columns[i][row]
+			}
+			cells[i] = padColumn(cell, col)
+		}
+		out.WriteString(strings.TrimRight(strings.Join(cells, gap), " ") + "\n")
+	}
+	return out.String()
+}
+
+// wrapColumn splits content into lines of at most width characters, tabs
+// expanded to four spaces
+func wrapColumn(content string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\t", "    "), "\n") {
+		runes := []rune(line)
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// padColumn pads s with spaces to width characters
+func padColumn(s string, width int) string {
+	if n := len([]rune(s)); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// terminalWidth returns $COLUMNS, or 160 when it is not set
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 160
+}
+
+func init() {
+	inspectModuleCmd.Flags().StringP("entry", "e", "main.lua", "Entry file of the project, locating its config and naming modules")
+	inspectModuleCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	inspectModuleCmd.Flags().StringP("target", "t", "", "Runtime target (default: config target, then roblox)")
+	inspectModuleCmd.Flags().StringP("obfuscate", "O", "", "Obfuscation preset: none, light, medium, heavy, max, or level 0-3 (default: config obfuscation)")
+	inspectModuleCmd.Flags().Int("minify", -1, "Minification level: 0=none, 1=comments and whitespace, 2=+local names, 3=+semicolons and numbers (-1 = 1, as in release mode)")
+	inspectModuleCmd.Flags().StringSlice("stage", nil, "Stages to show: "+strings.Join(bundler.Stages, ", ")+" (comma-separated or repeatable)")
+	inspectModuleCmd.Flags().Int("width", 0, "Width of side-by-side output (default: $COLUMNS, then 160)")
+	inspectModuleCmd.Flags().Bool("json", false, "Print the selected stages, or all of them, as JSON")
+
+	rootCmd.AddCommand(inspectModuleCmd)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleKeyForFile(t *testing.T) {
+	entry := filepath.Join("game", "main.lua")
+	assert.Equal(t, "modules.tasks.cook", moduleKeyForFile(entry, filepath.Join("game", "modules", "tasks", "cook.lua")))
+	assert.Equal(t, "ui", moduleKeyForFile(entry, filepath.Join("game", "ui", "init.luau")))
+	assert.Equal(t, "other.lib", moduleKeyForFile(entry, "other/lib.lua"), "files outside the project keep their path")
+}
+
+func TestSelectStages(t *testing.T) {
+	stages := []bundler.ModuleStage{{Stage: bundler.StageRaw}, {Stage: bundler.StageObfuscated}, {Stage: bundler.StageMinified}}
+	selected := selectStages(stages, []string{bundler.StageMinified, bundler.StageRaw})
+	assert.Equal(t, []bundler.ModuleStage{{Stage: bundler.StageRaw}, {Stage: bundler.StageMinified}}, selected, "stages keep pipeline order")
+}
+
+func TestFormatStageSummary(t *testing.T) {
+	summary := formatStageSummary([]bundler.ModuleStage{
+		{Stage: bundler.StageRaw, Content: "-- c\nreturn 1\n"},
+		{Stage: bundler.StageObfuscated, Content: "-- c\nreturn 1\n"},
+		{Stage: bundler.StageStripped, Content: "return 1"},
+	})
+	lines := strings.Split(strings.TrimSpace(summary), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "raw                14       2  -", lines[1])
+	assert.Equal(t, "obfuscated         14       2  unchanged", lines[2])
+	assert.Equal(t, "stripped            8       1  -42.9%", lines[3])
+}
+
+func TestSideBySide(t *testing.T) {
+	out := sideBySide([]bundler.ModuleStage{
+		{Stage: bundler.StageRaw, Content: "local value = 1\nreturn value\n"},
+		{Stage: bundler.StageMinified, Content: "local a=1 return a"},
+	}, 27)
+	assert.Equal(t, strings.Join([]string{
+		"raw          │ minified",
+		"─────────────┼─────────────",
+		"local value  │ local a=1 re",
+		"= 1          │ turn a",
+		"return value │",
+		"",
+	}, "\n"), out)
+}
+
+func TestWrapColumn(t *testing.T) {
+	assert.Equal(t, []string{"abcde", "f", "", "    x"}, wrapColumn("abcdef\n\n\tx", 5))
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var licensesCmd = &cobra.Command{
+	Use:   "licenses",
+	Short: "Report the licenses of all bundled modules and write an attribution file",
+	Long: `Scan the entry file and every embedded module, local and remote, for
+SPDX-License-Identifier tags, well-known license texts and copyright lines,
+and write a consolidated attribution file.
+
+To ship the same notice inside the bundle, build with --append-licenses.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		outputFile, _ := cmd.Flags().GetString("output")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+
+		licenses := b.Licenses()
+		fmt.Println()
+		unknown := 0
+		for _, l := range licenses {
+			if l.Found() {
+				fmt.Printf("%s %s %s\n", successStyle.Render("✓"), l.Module, infoStyle.Render(l.SPDX))
+			} else {
+				unknown++
+				fmt.Printf("%s %s %s\n", warningStyle.Render("?"), l.Module, warningStyle.Render("no license information"))
+			}
+		}
+
+		if err := os.WriteFile(outputFile, []byte(bundler.FormatAttribution(licenses)), 0644); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write output: %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println()
+		if unknown > 0 {
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %d of %d files have no license information", unknown, len(licenses))))
+		}
+		fmt.Printf("%s %s\n", successStyle.Render("📄 Attribution:"), outputFile)
+	},
+}
+
+func init() {
+	licensesCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	licensesCmd.Flags().StringP("output", "o", "THIRD_PARTY_NOTICES.txt", "Attribution file to write")
+	licensesCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: config target, then roblox)")
+	licensesCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	licensesCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	licensesCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	addHTTPFlags(licensesCmd)
+
+	rootCmd.AddCommand(licensesCmd)
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLicensesCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"licenses"})
+	require.NoError(t, err, "licenses should be registered")
+	assert.Equal(t, licensesCmd, cmd)
+
+	for _, name := range []string{"entry", "output", "config", "lockfile", "proxy"} {
+		assert.NotNil(t, licensesCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+	assert.NotNil(t, rootCmd.Flags().Lookup("append-licenses"))
+}
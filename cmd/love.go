@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/archive"
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/constt/lua-bundler/internal/lockfile"
+)
+
+// loveArchive packs a bundle as a .love game: the bundle as main.lua, plus
+// every other file of the project directory, such as conf.lua, images and
+// sounds. The Lua files the bundle embeds, hidden files, bundler files and
+// the output itself are left out.
+func loveArchive(b *bundler.Bundler, bundle, entryFile, outputFile string) ([]byte, error) {
+	files, err := projectFiles(b, entryFile, outputFile, func(rel string) bool {
+		return rel == "main.lua" || strings.HasSuffix(rel, ".love")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect game files: %w", err)
+	}
+	files = append([]archive.File{{Name: "main.lua", Data: []byte(bundle)}}, files...)
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, archive.FormatZip, files, time.Now()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// projectFiles returns the files of the entry's directory that a bundle
+// does not replace, by slash-separated relative name: the bundled local
+// files, hidden files, bundler files, the output and the files skip reports
+// are left out
+func projectFiles(b *bundler.Bundler, entryFile, outputFile string, skip func(rel string) bool) ([]archive.File, error) {
+	root := filepath.Dir(entryFile)
+	bundled := map[string]bool{}
+	for _, file := range append(b.GetLocalFiles(), outputFile) {
+		if abs, err := filepath.Abs(file); err == nil {
+			bundled[abs] = true
+		}
+	}
+
+	var files []archive.File
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && bundled[abs] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == config.FileName || rel == lockfile.FileName || skip(rel) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, archive.File{Name: rel, Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// copyLoveConf copies the project's conf.lua next to a single-file LÖVE
+// bundle, since LÖVE reads it before main.lua and it cannot be embedded. It
+// returns the path written, or "" when there is nothing to copy.
+func copyLoveConf(entryFile, outputFile string) (string, error) {
+	conf := filepath.Join(filepath.Dir(entryFile), bundler.LoveConf)
+	data, err := os.ReadFile(conf)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", bundler.LoveConf, err)
+	}
+
+	dest := filepath.Join(filepath.Dir(outputFile), bundler.LoveConf)
+	if same, _ := sameFile(conf, dest); same {
+		return "", nil
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return dest, nil
+}
+
+// sameFile reports whether a and b are the same existing file
+func sameFile(a, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(ai, bi), nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLoveProject(t *testing.T) string {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.lua":            "local player = require(\"player\")\nfunction love.draw() player.draw() end\n",
+		"player.lua":          "return { draw = function() end }\n",
+		"conf.lua":            "function love.conf(t) t.window.title = \"Game\" end\n",
+		"assets/hero.png":     "png",
+		"levels/one.lua":      "return {}\n",
+		".git/HEAD":           "ref",
+		"lua-bundler.json":    "{}",
+		"dist/old-build.love": "zip",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoveArchive(t *testing.T) {
+	dir := writeLoveProject(t)
+	b, err := bundler.NewBundler(filepath.Join(dir, "main.lua"), false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetTarget(bundler.TargetLove2D))
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	data, err := loveArchive(b, bundle, filepath.Join(dir, "main.lua"), filepath.Join(dir, "dist", "game.love"))
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	contents := make(map[string]string)
+	var names []string
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(r)
+		require.NoError(t, err)
+		r.Close()
+		contents[f.Name] = buf.String()
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"assets/hero.png", "conf.lua", "levels/one.lua", "main.lua"}, names,
+		"embedded modules, hidden files, bundler files and .love files should be left out")
+	assert.Equal(t, bundle, contents["main.lua"])
+	assert.Contains(t, contents["conf.lua"], "function love.conf(t)")
+}
+
+func TestCopyLoveConf(t *testing.T) {
+	dir := writeLoveProject(t)
+	out := filepath.Join(t.TempDir(), "main.lua")
+
+	path, err := copyLoveConf(filepath.Join(dir, "main.lua"), out)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(out), "conf.lua"), path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "love.conf")
+
+	path, err = copyLoveConf(filepath.Join(dir, "main.lua"), filepath.Join(dir, "bundle.lua"))
+	require.NoError(t, err)
+	assert.Empty(t, path, "conf.lua already next to the output should not be copied onto itself")
+
+	path, err = copyLoveConf(filepath.Join(t.TempDir(), "main.lua"), out)
+	require.NoError(t, err)
+	assert.Empty(t, path, "projects without conf.lua have nothing to copy")
+}
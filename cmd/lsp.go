@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/lsp"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server publishing bundler diagnostics to editors",
+	Long: `Speak the Language Server Protocol over stdin and stdout.
+
+Open documents are checked as you type, including unsaved edits:
+requires of missing files, requires the bundler leaves external although a
+project file matches them, require cycles and standard global overrides.
+Go to definition follows require() the way the bundler resolves it, to the
+module file or to the field a module exports.
+
+Require paths resolve from the nearest directory with a lua-bundler.json,
+else the workspace folder.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		target, _ := cmd.Flags().GetString("target")
+
+		server := lsp.NewServer(func(dir string) (*bundler.Bundler, error) {
+			return projectBundler(filepath.Join(dir, "main.lua"), target, false, bundler.HTTPOptions{})
+		})
+		// stdout carries the protocol, so errors go to stderr
+		if err := server.Run(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	lspCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: each project's config target, then roblox)")
+	lspCmd.Flags().Bool("stdio", true, "Communicate over stdin and stdout (the only transport; accepted for editor clients that pass it)")
+
+	rootCmd.AddCommand(lspCmd)
+}
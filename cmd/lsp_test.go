@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLspCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"lsp"})
+	require.NoError(t, err, "lsp should be registered")
+	assert.Equal(t, lspCmd, cmd)
+
+	for _, name := range []string{"target", "stdio"} {
+		assert.NotNil(t, lspCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
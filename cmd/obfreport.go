@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/spf13/cobra"
+)
+
+var obfReportCmd = &cobra.Command{
+	Use:   "obf-report <bundle>",
+	Short: "Score a bundle against automated deobfuscators and list its active protections",
+	Long: `Analyze a produced bundle the way automated deobfuscators see it:
+
+  identifier recovery        renamed locals cannot be recovered
+  string decryptor lifting   a decryptor called with constants is evaluated
+                             to recover every string
+  constant propagation       constant expressions fold back to numbers
+  control flow unflattening  dispatcher loops are traced back to source order
+
+Each technique is rated resists, partial, defeated or exposed, and the bundle
+gets a score from 0 to 100. Obfuscation raises the effort of reading a
+script; it does not make it unreadable.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		code, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to read bundle: %v", err)))
+			os.Exit(1)
+		}
+		report, err := obfuscator.Analyze(string(code))
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Print(formatObfReport(report))
+	},
+}
+
+// formatObfReport renders the protections, the rating against each
+// deobfuscation technique and the score
+func formatObfReport(report obfuscator.Report) string {
+	var out strings.Builder
+	out.WriteString("Protections:\n")
+	for _, p := range report.Protections {
+		mark := "✗"
+		if p.Active {
+			mark = "✓"
+		}
+		fmt.Fprintf(&out, "  %s %s (%s)\n", mark, p.Name, p.Detail)
+	}
+
+	width := 0
+	for _, r := range report.Resistances {
+		width = max(width, len(r.Technique))
+	}
+	out.WriteString("\nAgainst automated deobfuscators:\n")
+	for _, r := range report.Resistances {
+		fmt.Fprintf(&out, "  %-*s  %-8s  %s\n", width, r.Technique, r.Rating, r.Detail)
+	}
+	fmt.Fprintf(&out, "\nScore: %d/100\n", report.Score)
+	return out.String()
+}
+
+func init() {
+	obfReportCmd.Flags().Bool("json", false, "Print the report as JSON")
+
+	rootCmd.AddCommand(obfReportCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObfReportCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"obf-report"})
+	require.NoError(t, err, "obf-report should be registered")
+	assert.Equal(t, obfReportCmd, cmd)
+	assert.NotNil(t, obfReportCmd.Flags().Lookup("json"))
+}
+
+func TestFormatObfReport(t *testing.T) {
+	report := obfuscator.Report{
+		Protections: []obfuscator.Protection{
+			{Name: "identifier renaming", Active: true, Detail: "9 of 10 local name(s) generated"},
+			{Name: "string encoding", Detail: "4 of 4 string literal(s) readable"},
+		},
+		Resistances: []obfuscator.Resistance{
+			{Technique: "identifier recovery", Rating: obfuscator.RatingResists, Detail: "renamed"},
+			{Technique: "constant propagation", Rating: obfuscator.RatingExposed, Detail: "plain"},
+		},
+		Score: 50,
+	}
+	assert.Equal(t, ""+
+		"Protections:\n"+
+		"  ✓ identifier renaming (9 of 10 local name(s) generated)\n"+
+		"  ✗ string encoding (4 of 4 string literal(s) readable)\n"+
+		"\n"+
+		"Against automated deobfuscators:\n"+
+		"  identifier recovery   resists   renamed\n"+
+		"  constant propagation  exposed   plain\n"+
+		"\n"+
+		"Score: 50/100\n",
+		formatObfReport(report))
+}
@@ -0,0 +1,466 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/archive"
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Build the bundle and pack it with its manifest and checksums into a release archive",
+	Long: `Bundle the entry file and write a versioned zip or tar.gz archive holding
+everything a release needs:
+
+  <name>.lua        the bundle
+  <name>.map.json   module line ranges in the bundle (not for --release builds)
+  manifest.json     build settings and the SHA-256 of every embedded module
+  names.json        original -> obfuscated identifiers (with a renaming pass)
+  SHA256SUMS        checksums of the files above, for sha256sum -c
+
+The archive name comes from --name-template, where {name}, {version} and
+{target} are replaced.
+
+With --changelog-from pointing at the previous release's archive or manifest,
+the modules added, removed and updated since then are listed as a Markdown
+section, printed or prepended to the file given by --changelog.`,
+	Example: `  lua-bundler package -e main.lua --version 1.4.0 --release
+  lua-bundler package -e main.lua --version 1.4.0 --archive tar.gz --name-template "{name}-{version}-{target}"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pkg := buildPackage(cmd)
+
+		fmt.Println()
+		fmt.Println(successStyle.Render("✅ Packaged!"))
+		for _, f := range pkg.files {
+			fmt.Printf("  %s\n", f.Name)
+		}
+		fmt.Printf("%s %s\n", infoStyle.Render("📦 Archive:"), pkg.path)
+		fmt.Printf("%s %s\n", infoStyle.Render("🔒 SHA-256:"), archive.SHA256(pkg.data))
+
+		if pkg.changelog != "" {
+			if changelogFile, _ := cmd.Flags().GetString("changelog"); changelogFile != "" {
+				fmt.Printf("%s %s\n", infoStyle.Render("📝 Changelog:"), changelogFile)
+			} else {
+				fmt.Println()
+				fmt.Print(pkg.changelog)
+			}
+		}
+	},
+}
+
+// builtPackage is a release archive written by buildPackage
+type builtPackage struct {
+	path      string         // archive file
+	data      []byte         // archive contents
+	files     []archive.File // files inside the archive
+	bundle    archive.File   // the bundle, named <name>.lua
+	changelog string         // changes since --changelog-from, if given
+}
+
+// buildPackage bundles the entry file and writes the release archive
+// described by the package flags on cmd, exiting on failure
+func buildPackage(cmd *cobra.Command) builtPackage {
+	entryFile, _ := cmd.Flags().GetString("entry")
+	outDir, _ := cmd.Flags().GetString("output-dir")
+	name, _ := cmd.Flags().GetString("name")
+	version, _ := cmd.Flags().GetString("version")
+	nameTemplate, _ := cmd.Flags().GetString("name-template")
+	archiveFormat, _ := cmd.Flags().GetString("archive")
+	release, _ := cmd.Flags().GetBool("release")
+	obfuscate, _ := cmd.Flags().GetString("obfuscate")
+	virtualize, _ := cmd.Flags().GetStringSlice("virtualize")
+	target, _ := cmd.Flags().GetString("target")
+	configPath, _ := cmd.Flags().GetString("config")
+	lockPath, _ := cmd.Flags().GetString("lockfile")
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	changelogFrom, _ := cmd.Flags().GetString("changelog-from")
+	changelogFile, _ := cmd.Flags().GetString("changelog")
+	defineFlags, _ := cmd.Flags().GetStringArray("define")
+	noWarn, _ := cmd.Flags().GetStringSlice("no-warn")
+	keepPatterns, _ := cmd.Flags().GetStringArray("keep-pattern")
+	logShim, _ := cmd.Flags().GetString("log-shim")
+	debugCalls, _ := cmd.Flags().GetString("debug-calls")
+	minifyLevel, _ := cmd.Flags().GetInt("minify")
+	preserveLines, _ := cmd.Flags().GetBool("minify-preserve-lines")
+	bannerFile, _ := cmd.Flags().GetString("banner-file")
+	footerFile, _ := cmd.Flags().GetString("footer-file")
+	loader, _ := cmd.Flags().GetString("loader")
+	inlineSmall, _ := cmd.Flags().GetInt("inline-small")
+	resolution, _ := cmd.Flags().GetString("resolution")
+	noMemoize, _ := cmd.Flags().GetStringArray("no-memoize")
+	stubFlags, _ := cmd.Flags().GetStringArray("stub")
+	omit, _ := cmd.Flags().GetStringArray("omit")
+	features, _ := cmd.Flags().GetStringSlice("features")
+	localeFiles, _ := cmd.Flags().GetStringArray("locale")
+	lualib, _ := cmd.Flags().GetStringArray("lualib")
+	addonLibs, _ := cmd.Flags().GetStringArray("addon-libs")
+	defaultLocale, _ := cmd.Flags().GetString("default-locale")
+	gitInfo, _ := cmd.Flags().GetBool("git-info")
+	requestShim, _ := cmd.Flags().GetBool("request-shim")
+	strict, _ := cmd.Flags().GetBool("strict")
+	safeWrap, _ := cmd.Flags().GetBool("safe-wrap")
+	stateKey, _ := cmd.Flags().GetString("state-key")
+	sizeLimit, _ := cmd.Flags().GetString("size-limit")
+	sourceEncoding, _ := cmd.Flags().GetString("source-encoding")
+	lineEndings, _ := cmd.Flags().GetString("line-endings")
+
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(entryFile), filepath.Ext(entryFile))
+	}
+	ext, err := archive.Extension(archiveFormat)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(configPath, entryFile)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if target == "" {
+		target = cfg.Target
+	}
+	if !cmd.Flags().Changed("version") && cfg.Version != "" {
+		version = cfg.Version
+	}
+	if target == "" {
+		target = bundler.TargetRoblox
+	}
+
+	base, err := archive.ExpandName(nameTemplate, map[string]string{"name": name, "version": version, "target": target, "features": featuresName(features, cmd.Flags().Changed("features"))})
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+
+	lock, err := loadLockfile(lockPath, entryFile)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+
+	b, err := bundler.NewBundler(entryFile, false, !noCache)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+		os.Exit(1)
+	}
+	if err := b.SetTarget(target); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	b.SetVariants(cfg.Variants)
+	b.SetRequireDecisions(cfg.Requires)
+	b.SetMirrors(cfg.Mirrors)
+	if lock != nil {
+		b.SetLockfile(lock)
+	}
+	if err := b.SetNamespace(namespace); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := applyDefines(b, cfg.Defines, defineFlags); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := b.SuppressWarnings(append(cfg.SuppressWarnings, noWarn...)); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := b.SetKeepPatterns(append(cfg.KeepPatterns, keepPatterns...)); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if logShim == "" {
+		logShim = cfg.LogShim
+	}
+	if err := b.SetLogShim(logShim); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if debugCalls == "" {
+		debugCalls = cfg.DebugCalls
+	}
+	if err := b.SetDebugCalls(debugCalls); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := b.SetMinifyLevel(minifyLevel); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	b.SetPreserveLines(preserveLines)
+	if loader == "" {
+		loader = cfg.Loader
+	}
+	if err := b.SetLoader(loader); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if inlineSmall < 0 {
+		inlineSmall = cfg.InlineSmall
+	}
+	if err := b.SetInlineSmall(inlineSmall); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if resolution == "" {
+		resolution = cfg.Resolution
+	}
+	if err := b.SetResolution(resolution); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	b.SetNoMemoize(append(cfg.NoMemoize, noMemoize...))
+	b.SetHooks(cfg.Prelude, cfg.Epilogue)
+	if err := applyStubs(b, cfg, stubFlags, omit); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := applyFeatures(b, cfg.Features, features, cmd.Flags().Changed("features")); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := applyLocales(b, cfg, localeFiles, defaultLocale); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	applyLualib(b, cfg, lualib)
+	applyAddonLibs(b, cfg, addonLibs)
+	if err := loadBanner(b, bannerFile, footerFile); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := b.SetVersion(version); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if gitInfo || cfg.GitInfo {
+		revision, err := readGitInfo(entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetGitInfo(revision)
+	}
+	b.SetRequestShim(requestShim || cfg.RequestShim)
+	b.SetStrict(strict || cfg.Strict)
+	b.SetSafeWrap(safeWrap || cfg.SafeWrap)
+	b.SetErrorHandler(cfg.ErrorHandler)
+	if stateKey == "" {
+		stateKey = cfg.StateKey
+	}
+	b.SetStateKey(stateKey)
+	if sizeLimit == "" {
+		sizeLimit = cfg.SizeLimit
+	}
+	if err := b.SetSizeLimit(sizeLimit); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	if err := applyEncoding(b, cfg, sourceEncoding, lineEndings); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+	obfuscation, err := obfuscationPasses(cfg, obfuscate)
+	if err == nil {
+		err = applyObfuscation(b, cfg, obfuscation, virtualize)
+	}
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+
+	fmt.Println(infoStyle.Render("🔄 Processing dependencies..."))
+	bundle, err := b.Bundle(release)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Bundling failed: %v", err)))
+		os.Exit(1)
+	}
+
+	files, err := packageFiles(b, base, name, version, bundle, release)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, archiveFormat, files, time.Now()); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create archive: %v", err)))
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create output directory: %v", err)))
+		os.Exit(1)
+	}
+	archivePath := filepath.Join(outDir, base+ext)
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write archive: %v", err)))
+		os.Exit(1)
+	}
+
+	if lock != nil {
+		if err := lock.Save(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+	}
+
+	var changelog string
+	if changelogFrom != "" {
+		previous, err := loadPreviousManifest(changelogFrom)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		title := fmt.Sprintf("%s - %s", version, time.Now().Format("2006-01-02"))
+		changelog = bundler.FormatChangelog(title, previous, b.Manifest(name, version, release))
+		if changelogFile != "" {
+			if err := bundler.PrependChangelog(changelogFile, changelog); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+		}
+	}
+
+	return builtPackage{
+		path:      archivePath,
+		data:      buf.Bytes(),
+		files:     files,
+		bundle:    archive.File{Name: name + ".lua", Data: []byte(bundle)},
+		changelog: changelog,
+	}
+}
+
+// packageFiles returns the files of a release archive, all inside a base/
+// directory and ending with the checksums of the others
+func packageFiles(b *bundler.Bundler, base, name, version, bundle string, release bool) ([]archive.File, error) {
+	bundleName := name + ".lua"
+	files := []archive.File{{Name: bundleName, Data: []byte(bundle)}}
+
+	// The bundler drops the line ranges when minifying or stripping moves lines
+	if b.SourceMap() != nil {
+		sourceMap, err := b.SourceMapJSON(bundleName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode source map: %w", err)
+		}
+		files = append(files, archive.File{Name: name + ".map.json", Data: append(sourceMap, '\n')})
+	}
+
+	manifest, err := json.MarshalIndent(b.Manifest(name, version, release), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	files = append(files, archive.File{Name: "manifest.json", Data: append(manifest, '\n')})
+
+	if names := b.GetNameMap(); len(names) > 0 {
+		data, err := json.MarshalIndent(names, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode name map: %w", err)
+		}
+		files = append(files, archive.File{Name: "names.json", Data: append(data, '\n')})
+	}
+
+	files = append(files, archive.File{Name: "SHA256SUMS", Data: archive.Checksums(files)})
+	for i := range files {
+		files[i].Name = base + "/" + files[i].Name
+	}
+	return files, nil
+}
+
+// loadPreviousManifest reads the manifest of an earlier build from a
+// manifest.json file or from the release archive containing it
+func loadPreviousManifest(path string) (bundler.Manifest, error) {
+	var data []byte
+	var err error
+	if archive.IsArchive(path) {
+		data, err = archive.Find(path, "manifest.json")
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return bundler.Manifest{}, fmt.Errorf("failed to read previous manifest: %w", err)
+	}
+	return bundler.ParseManifest(data)
+}
+
+func init() {
+	addPackageFlags(packageCmd)
+	rootCmd.AddCommand(packageCmd)
+}
+
+// addPackageFlags registers the flags read by buildPackage
+// featuresName returns the {features} archive name placeholder: the selected
+// features joined by +, "all" without --features and "none" for none
+func featuresName(features []string, given bool) string {
+	switch {
+	case !given:
+		return "all"
+	case len(features) == 0:
+		return "none"
+	}
+	return strings.Join(features, "+")
+}
+
+func addPackageFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	cmd.Flags().StringP("output-dir", "o", "dist", "Directory to write the archive to")
+	cmd.Flags().String("name", "", "Package and bundle name (default: entry file name)")
+	cmd.Flags().String("version", "dev", "Version recorded in the bundle, manifest and archive name (default: config version, then dev)")
+	cmd.Flags().String("name-template", "{name}-{version}", "Archive name template; {name}, {version}, {target} and {features} are replaced")
+	cmd.Flags().String("archive", archive.FormatZip, "Archive format: zip or tar.gz")
+	cmd.Flags().BoolP("release", "r", false, "Enable release mode (remove print/warn, minify); omits the source map")
+	cmd.Flags().StringP("obfuscate", "O", "", "Obfuscation preset or level 0-3 (default: config obfuscation, then none)")
+	cmd.Flags().StringSlice("virtualize", nil, "Compile local modules matching a pattern to bytecode run by a generated interpreter; much larger and slower")
+	cmd.Flags().StringP("target", "t", "", "Runtime target (default: config target, then roblox)")
+	cmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	cmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	cmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache")
+	cmd.Flags().String("namespace", "", "Prefix module keys and loader names")
+	cmd.Flags().Int("minify", -1, "Minification level: 0=none, 1=comments and whitespace, 2=+local names, 3=+semicolons and numbers (-1 = 1 with --release, else 0)")
+	cmd.Flags().Bool("minify-preserve-lines", false, "Keep every statement on its original line when minifying or stripping debug statements, so runtime error lines still match")
+	cmd.Flags().String("loader", "", "How modules are embedded: closure, inline or lazy (default: config loader, then closure)")
+	cmd.Flags().Int("inline-small", -1, fmt.Sprintf("Inline modules of fewer than N tokens that return a constant at their require sites, skipping the loader (bare flag = %d, 0 = off; default: config inlineSmall)", bundler.DefaultInlineSmall))
+	cmd.Flags().Lookup("inline-small").NoOptDefVal = fmt.Sprint(bundler.DefaultInlineSmall)
+	cmd.Flags().String("resolution", "", "What a require of a.b.c names: roblox-dots, lua-package or filesystem-only (default: config resolution, then roblox-dots)")
+	cmd.Flags().StringArray("no-memoize", nil, "Run this module again on every require (repeatable)")
+	cmd.Flags().StringArray("stub", nil, "Embed a file in place of a module as MODULE=PATH (repeatable)")
+	cmd.Flags().StringArray("omit", nil, "Replace a module with an empty table (repeatable)")
+	cmd.Flags().StringArray("addon-libs", nil, "Directory searched for the files a .toc entry lists that the addon folder lacks (repeatable)")
+	cmd.Flags().StringArray("lualib", nil, "Directory the openresty target reads resty.* modules from (repeatable)")
+	cmd.Flags().StringArray("locale", nil, "Embed a JSON translation file named after its locale, e.g. locales/de.json (repeatable)")
+	cmd.Flags().String("default-locale", "", "Locale selected when the script starts (default: config locale, then the first one)")
+	cmd.Flags().StringSlice("features", nil, "Config features to bundle, comma-separated (default: all, --features= for none)")
+	cmd.Flags().String("banner-file", "", "File prepended to the bundle verbatim, exempt from minification and obfuscation")
+	cmd.Flags().String("footer-file", "", "File appended to the bundle verbatim, exempt from minification and obfuscation")
+	cmd.Flags().String("log-shim", "", "In release mode, route print/warn through an embedded logger with this default level (info, warn or off; bare flag = off) instead of removing them")
+	cmd.Flags().Lookup("log-shim").NoOptDefVal = "off"
+	cmd.Flags().String("debug-calls", "", "In release mode, what to do with function calls in the arguments of removed print/warn statements: keep (print(save()) becomes save()) or strip (default: keep)")
+	cmd.Flags().StringArray("keep-pattern", nil, "Keep print/warn statements with a string argument matching this regular expression in release mode (repeatable)")
+	cmd.Flags().StringSlice("no-warn", nil, "Suppress a warning rule: "+strings.Join(bundler.WarningRules, ", ")+" (repeatable or comma-separated)")
+	cmd.Flags().StringArrayP("define", "D", nil, "Declare a constant at the top of the bundle as KEY=VALUE (repeatable)")
+	cmd.Flags().String("size-limit", "", "Largest bundle allowed, in bytes, none or a preset such as cc-floppy")
+	cmd.Flags().String("source-encoding", "", "How sources that are not valid UTF-8 are read: auto, utf-8 or bytes (default: config sourceEncoding, then auto)")
+	cmd.Flags().String("line-endings", "", "Line endings of the bundle: lf or crlf (default: config lineEndings, then lf)")
+	cmd.Flags().String("state-key", "", "Keep the keys the bundle assigns in getgenv(), shared and _G in one table under this key")
+	cmd.Flags().Bool("strict", false, "Fail on requires that are neither embedded, stubbed nor explicitly external")
+	cmd.Flags().Bool("safe-wrap", false, "Run the bundle in pcall and report errors with the build id (a Roblox notification and console warning by default) instead of failing silently")
+	cmd.Flags().Bool("request-shim", false, "Route syn.request, http.request, http_request and request calls through one injected cross-executor request function")
+	cmd.Flags().Bool("git-info", false, "Record the git commit, tag and dirty state in the bundle header and manifest and define GIT_COMMIT, GIT_TAG and GIT_DIRTY")
+	cmd.Flags().String("changelog-from", "", "Previous build's archive or manifest.json to list module changes against")
+	cmd.Flags().String("changelog", "", "Changelog file to prepend the changes to, e.g. CHANGELOG.md (used with --changelog-from)")
+	addHTTPFlags(cmd)
+}
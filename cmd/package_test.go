@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"package"})
+	require.NoError(t, err, "package should be registered")
+	assert.Equal(t, packageCmd, cmd)
+
+	for _, name := range []string{"entry", "output-dir", "version", "name-template", "archive", "release", "obfuscate", "virtualize", "request-shim", "strict", "safe-wrap", "resolution", "lualib", "addon-libs", "size-limit", "source-encoding", "line-endings", "proxy"} {
+		assert.NotNil(t, packageCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestPackageFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`local util = require("util")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "util.lua"), []byte(`local value = 1
+return value`), 0644))
+
+	b, err := bundler.NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	b.SetObfuscationLevel(2)
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	files, err := packageFiles(b, "hub-1.0.0", "hub", "1.0.0", bundle, false)
+	require.NoError(t, err)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"hub-1.0.0/hub.lua", "hub-1.0.0/hub.map.json", "hub-1.0.0/manifest.json", "hub-1.0.0/names.json", "hub-1.0.0/SHA256SUMS"}, names)
+	assert.Contains(t, string(files[2].Data), `"version": "1.0.0"`)
+	assert.Contains(t, string(files[2].Data), `"source": "util.lua"`, "sources should be relative to the project")
+
+	sums := strings.Split(strings.TrimSpace(string(files[4].Data)), "\n")
+	assert.Len(t, sums, 4, "every other file should be checksummed")
+	assert.True(t, strings.HasSuffix(sums[0], "  hub.lua"))
+
+	bundle, err = b.Bundle(true)
+	require.NoError(t, err)
+	files, err = packageFiles(b, "hub", "hub", "1.0.0", bundle, true)
+	require.NoError(t, err)
+	for _, f := range files {
+		assert.NotEqual(t, "hub/hub.map.json", f.Name, "release builds should not ship a source map")
+	}
+
+	b.SetPreserveLines(true)
+	bundle, err = b.Bundle(true)
+	require.NoError(t, err)
+	files, err = packageFiles(b, "hub", "hub", "1.0.0", bundle, true)
+	require.NoError(t, err)
+	assert.Equal(t, "hub/hub.map.json", files[1].Name, "line-preserving release builds keep the source map")
+}
+
+func TestFeaturesName(t *testing.T) {
+	assert.Equal(t, "all", featuresName(nil, false))
+	assert.Equal(t, "none", featuresName(nil, true))
+	assert.Equal(t, "esp+farm", featuresName([]string{"esp", "farm"}, true))
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var prefetchCmd = &cobra.Command{
+	Use:   "prefetch",
+	Short: "Download every dependency pinned in the lockfile into the cache",
+	Long: `Download every remote dependency listed in the lockfile into the download
+cache without building, verifying each against its pinned hash and trying
+the configured mirrors. A later build with --offline then reads them from
+the cache, expired or not, in an environment without network access.
+
+Dependencies already cached are not downloaded again. Exits with status 1
+when any dependency could not be fetched.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if lock == nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ No lockfile next to %s; build once with a lockfile or pass --lockfile", entryFile)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, true)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		b.SetMirrors(cfg.Mirrors)
+		b.SetLockfile(lock)
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		urls := lock.URLs()
+		fmt.Println(infoStyle.Render(fmt.Sprintf("📥 Prefetching %d dependency(ies) from %s...", len(urls), lock.Path())))
+		results := prefetch(b, urls)
+		failed := 0
+		for _, r := range results {
+			switch {
+			case r.Err != nil:
+				failed++
+				fmt.Println(errorStyle.Render(fmt.Sprintf("  ❌ %v", r.Err)))
+			case r.Fetched:
+				fmt.Printf("  📥 %s (%s)\n", r.URL, formatBytes(int64(r.Bytes)))
+			default:
+				fmt.Printf("  💾 %s (cached)\n", r.URL)
+			}
+		}
+
+		fmt.Println()
+		if failed > 0 {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %d of %d dependency(ies) could not be fetched", failed, len(results))))
+			os.Exit(1)
+		}
+		fmt.Println(successStyle.Render("✅ " + prefetchSummary(results)))
+	},
+}
+
+// prefetchResult is how one dependency was prefetched
+type prefetchResult struct {
+	URL     string
+	Bytes   int
+	Fetched bool // downloaded rather than already cached
+	Err     error
+}
+
+// prefetch downloads urls into b's cache, one after the other
+func prefetch(b *bundler.Bundler, urls []string) []prefetchResult {
+	results := make([]prefetchResult, 0, len(urls))
+	for _, url := range urls {
+		size, fetched, err := b.Prefetch(url)
+		results = append(results, prefetchResult{URL: url, Bytes: size, Fetched: fetched, Err: err})
+	}
+	return results
+}
+
+// prefetchSummary counts the dependencies downloaded and already cached,
+// and their bytes
+func prefetchSummary(results []prefetchResult) string {
+	fetched, cached := 0, 0
+	var fetchedBytes, cachedBytes int64
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		if r.Fetched {
+			fetched++
+			fetchedBytes += int64(r.Bytes)
+		} else {
+			cached++
+			cachedBytes += int64(r.Bytes)
+		}
+	}
+	return fmt.Sprintf("%d dependency(ies) ready for offline builds: %d fetched (%s), %d already cached (%s)",
+		fetched+cached, fetched, formatBytes(fetchedBytes), cached, formatBytes(cachedBytes))
+}
+
+func init() {
+	prefetchCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file; the lockfile and lua-bundler.json are looked up next to it")
+	prefetchCmd.Flags().StringP("config", "c", "", "Path to config file, for mirrors (default: lua-bundler.json next to the entry file)")
+	prefetchCmd.Flags().String("lockfile", "", "Lockfile listing the dependencies (default: lua-bundler.lock next to the entry file)")
+	addHTTPFlags(prefetchCmd)
+
+	rootCmd.AddCommand(prefetchCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/lockfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return '" + r.URL.Path + "'"))
+	}))
+	defer server.Close()
+
+	lock, err := lockfile.Load(filepath.Join(t.TempDir(), lockfile.FileName))
+	require.NoError(t, err)
+	lock.Set(server.URL+"/a.lua", lockfile.Entry{SHA256: lockfile.Hash("return '/a.lua'")})
+	lock.Set(server.URL+"/b.lua", lockfile.Entry{SHA256: lockfile.Hash("return 'tampered'")})
+
+	b, err := bundler.NewBundler("main.lua", false, true)
+	require.NoError(t, err)
+	b.SetLockfile(lock)
+	results := prefetch(b, lock.URLs())
+
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.True(t, results[0].Fetched)
+	assert.Equal(t, len("return '/a.lua'"), results[0].Bytes)
+	require.Error(t, results[1].Err)
+	assert.Contains(t, results[1].Err.Error(), "does not match lockfile hash")
+
+	results = prefetch(b, []string{server.URL + "/a.lua"})
+	assert.False(t, results[0].Fetched, "the second run is served from the cache")
+}
+
+func TestPrefetchSummary(t *testing.T) {
+	results := []prefetchResult{
+		{URL: "https://a", Bytes: 2048, Fetched: true},
+		{URL: "https://b", Bytes: 100},
+		{URL: "https://c", Err: errors.New("unreachable")},
+	}
+	assert.Equal(t, "2 dependency(ies) ready for offline builds: 1 fetched (2.0 KB), 1 already cached (100 B)", prefetchSummary(results))
+}
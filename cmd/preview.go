@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+)
+
+// previewBundle is the --preview value selecting the whole bundle
+const previewBundle = "bundle"
+
+// previewContent returns what --preview shows: the bundle, or the content
+// a module was embedded with, after its requires were rewritten and it was
+// obfuscated. Minification works on the whole bundle, so only the bundle
+// shows it.
+func previewContent(b *bundler.Bundler, bundle, module string) (string, error) {
+	if module == previewBundle {
+		return bundle, nil
+	}
+	content, ok := b.GetModules()[module]
+	if !ok {
+		return "", fmt.Errorf("module %q is not in the bundle (--graph lists its modules)", module)
+	}
+	return content, nil
+}
+
+// showPreview writes content through the pager when standard output is a
+// terminal, and straight to standard output otherwise
+func showPreview(content string) error {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	pager := pagerCommand(os.Getenv)
+	if len(pager) == 0 || !isTerminal(os.Stdout) {
+		_, err := io.WriteString(os.Stdout, content)
+		return err
+	}
+	if _, err := exec.LookPath(pager[0]); err != nil {
+		// No pager installed: the terminal scrollback will do
+		_, err := io.WriteString(os.Stdout, content)
+		return err
+	}
+	c := exec.Command(pager[0], pager[1:]...)
+	c.Stdin = strings.NewReader(content)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	return c.Run()
+}
+
+// pagerCommand returns the pager named by LUA_BUNDLER_PAGER or PAGER, or
+// the platform's default; nil when the pager is cat, which turns paging off
+func pagerCommand(getenv func(string) string) []string {
+	pager := getenv("LUA_BUNDLER_PAGER")
+	if pager == "" {
+		pager = getenv("PAGER")
+	}
+	if pager == "" {
+		pager = "less -R"
+		if runtime.GOOS == "windows" {
+			pager = "more"
+		}
+	}
+	fields := strings.Fields(pager)
+	if len(fields) == 0 || fields[0] == "cat" {
+		return nil
+	}
+	return fields
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
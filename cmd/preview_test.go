@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewContent(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("local util = require(\"util\")\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util.lua"), []byte("-- helpers\nreturn {}\n"), 0644))
+
+	b, err := bundler.NewBundler(entry, false, false)
+	require.NoError(t, err)
+	b.SetObfuscationLevel(1)
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	content, err := previewContent(b, bundle, previewBundle)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, content)
+
+	content, err = previewContent(b, bundle, "util")
+	require.NoError(t, err)
+	assert.Equal(t, "return {}", content, "modules are shown as obfuscated")
+
+	_, err = previewContent(b, bundle, "missing")
+	assert.ErrorContains(t, err, `module "missing" is not in the bundle`)
+}
+
+func TestPagerCommand(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+	assert.Equal(t, []string{"bat", "--language", "lua"}, pagerCommand(env(map[string]string{"LUA_BUNDLER_PAGER": "bat --language lua", "PAGER": "more"})))
+	assert.Equal(t, []string{"most"}, pagerCommand(env(map[string]string{"PAGER": "most"})))
+	assert.Nil(t, pagerCommand(env(map[string]string{"PAGER": "cat"})))
+	assert.NotEmpty(t, pagerCommand(env(nil)))
+}
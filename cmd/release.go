@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/github"
+	"github.com/spf13/cobra"
+)
+
+var releaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Package the bundle and upload it to a GitHub release",
+	Long: `Build the release archive like the package command, then create the GitHub
+release for --tag (or update it if it exists) and upload the archive and the
+bundle as release assets. Assets with the same name are replaced. Without
+--notes, the changelog from --changelog-from becomes the release notes.
+
+The token is read from GITHUB_TOKEN, or GH_TOKEN. The repository defaults to
+the GitHub "origin" remote of the entry file's git checkout.`,
+	Example: `  GITHUB_TOKEN=... lua-bundler release -e main.lua --tag v1.2.0 --release
+  lua-bundler release -e main.lua --tag v1.3.0-rc1 --prerelease --notes-file NOTES.md`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tag, _ := cmd.Flags().GetString("tag")
+		repo, _ := cmd.Flags().GetString("repo")
+		title, _ := cmd.Flags().GetString("title")
+		notes, _ := cmd.Flags().GetString("notes")
+		notesFile, _ := cmd.Flags().GetString("notes-file")
+		draft, _ := cmd.Flags().GetBool("draft")
+		prerelease, _ := cmd.Flags().GetBool("prerelease")
+		commitish, _ := cmd.Flags().GetString("target-commitish")
+		apiURL, _ := cmd.Flags().GetString("api-url")
+		entryFile, _ := cmd.Flags().GetString("entry")
+
+		if tag == "" {
+			fmt.Println(errorStyle.Render("❌ --tag is required"))
+			os.Exit(1)
+		}
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			token = os.Getenv("GH_TOKEN")
+		}
+		if token == "" {
+			fmt.Println(errorStyle.Render("❌ Set GITHUB_TOKEN (or GH_TOKEN) to a token allowed to create releases"))
+			os.Exit(1)
+		}
+		if repo == "" {
+			var err error
+			if repo, err = originRepo(entryFile); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v; pass --repo owner/name", err)))
+				os.Exit(1)
+			}
+		}
+		if notesFile != "" {
+			data, err := os.ReadFile(notesFile)
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to read release notes: %v", err)))
+				os.Exit(1)
+			}
+			notes = string(data)
+		}
+		if !cmd.Flags().Changed("version") {
+			_ = cmd.Flags().Set("version", strings.TrimPrefix(tag, "v"))
+		}
+		if title == "" {
+			title = tag
+		}
+
+		pkg := buildPackage(cmd)
+		fmt.Printf("%s %s\n", successStyle.Render("📦 Packaged:"), pkg.path)
+		if notes == "" {
+			notes = pkg.changelog
+		}
+
+		client := github.NewClient(token, apiURL)
+		release, created, err := client.EnsureRelease(repo, github.ReleaseOptions{
+			Tag:        tag,
+			Target:     commitish,
+			Name:       title,
+			Body:       notes,
+			Draft:      draft,
+			Prerelease: prerelease,
+		})
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if created {
+			fmt.Printf("%s %s on %s\n", successStyle.Render("🏷️  Created release"), tag, repo)
+		} else {
+			fmt.Printf("%s %s on %s\n", successStyle.Render("🏷️  Updated release"), tag, repo)
+		}
+
+		assets := []struct {
+			name string
+			data []byte
+		}{
+			{filepath.Base(pkg.path), pkg.data},
+			{pkg.bundle.Name, pkg.bundle.Data},
+		}
+		for _, a := range assets {
+			asset, err := client.UploadAsset(repo, release, a.name, a.data)
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+			fmt.Printf("%s %s\n", successStyle.Render("⬆️  Uploaded"), asset.BrowserDownloadURL)
+		}
+
+		fmt.Println()
+		fmt.Printf("%s %s\n", infoStyle.Render("🔗 Release:"), release.HTMLURL)
+	},
+}
+
+// originRepo returns the GitHub "owner/name" of the origin remote of the
+// git checkout containing entryFile
+func originRepo(entryFile string) (string, error) {
+	dir := "."
+	if !bundler.IsURL(entryFile) {
+		dir = filepath.Dir(entryFile)
+	}
+	out, err := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not read the git origin remote")
+	}
+	return github.RepoFromRemote(string(out))
+}
+
+func init() {
+	addPackageFlags(releaseCmd)
+	releaseCmd.Flags().String("tag", "", "Release tag, e.g. v1.2.0 (the version defaults to the tag without its leading v)")
+	releaseCmd.Flags().String("repo", "", "GitHub repository as owner/name (default: from the origin remote)")
+	releaseCmd.Flags().String("title", "", "Release title (default: the tag)")
+	releaseCmd.Flags().String("notes", "", "Release notes (default: the --changelog-from changes, if given)")
+	releaseCmd.Flags().String("notes-file", "", "Read release notes from a file")
+	releaseCmd.Flags().Bool("draft", false, "Mark the release as a draft")
+	releaseCmd.Flags().Bool("prerelease", false, "Mark the release as a prerelease")
+	releaseCmd.Flags().String("target-commitish", "", "Branch or commit to create the tag from if it does not exist (default: the repository's default branch)")
+	releaseCmd.Flags().String("api-url", github.DefaultAPIURL, "GitHub API URL (for GitHub Enterprise)")
+
+	rootCmd.AddCommand(releaseCmd)
+}
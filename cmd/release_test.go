@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"release"})
+	require.NoError(t, err, "release should be registered")
+	assert.Equal(t, releaseCmd, cmd)
+
+	for _, name := range []string{"tag", "repo", "notes-file", "draft", "prerelease", "api-url", "entry", "version", "archive"} {
+		assert.NotNil(t, releaseCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/codemod"
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Rename a variable across every module in the dependency graph",
+	Long: `Rename a variable across the entry file and every local module it requires.
+
+The rename is scope-aware: each local or global named --symbol is renamed along
+with all of its uses, while fields, strings and comments are left alone. The
+rename is refused when the new name would capture or shadow another variable.
+Use --fields to also rename field and method names (t.Name, t:Name(), {Name = ...}),
+which are renamed by name only.
+
+Remote modules are never modified. Use --dry-run to print a diff first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		symbol, _ := cmd.Flags().GetString("symbol")
+		to, _ := cmd.Flags().GetString("to")
+		fields, _ := cmd.Flags().GetBool("fields")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+
+		renamer, err := codemod.NewRenamer(symbol, to, fields)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, false)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		// Only local files are renamed, so remote scripts need not be fetched
+		b.SetFlattenDepth(0)
+
+		fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+
+		sources := make(map[string]string)
+		for _, file := range b.GetLocalFiles() {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to read %s: %v", file, err)))
+				os.Exit(1)
+			}
+			sources[file] = string(content)
+		}
+
+		outputs, changes, err := renamer.RenameFiles(sources)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		total := 0
+		for _, file := range b.GetLocalFiles() {
+			output, ok := outputs[file]
+			if !ok {
+				continue
+			}
+			total += len(changes[file])
+
+			if dryRun {
+				fmt.Print(codemod.Diff(file, sources[file], output))
+				continue
+			}
+			if err := os.WriteFile(file, []byte(output), 0644); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write %s: %v", file, err)))
+				os.Exit(1)
+			}
+			fmt.Printf("%s %s (%d)\n", successStyle.Render("✎"), file, len(changes[file]))
+		}
+
+		fmt.Println()
+		switch {
+		case total == 0:
+			fmt.Println(infoStyle.Render(fmt.Sprintf("No occurrences of %s found", symbol)))
+		case dryRun:
+			fmt.Println(infoStyle.Render(fmt.Sprintf("🔍 Dry run: %d occurrences in %d files would be renamed", total, len(outputs))))
+		default:
+			fmt.Println(successStyle.Render(fmt.Sprintf("✅ Renamed %d occurrences of %s to %s in %d files", total, symbol, to, len(outputs))))
+		}
+	},
+}
+
+func init() {
+	renameCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file")
+	renameCmd.Flags().String("symbol", "", "Variable to rename")
+	renameCmd.Flags().String("to", "", "New name")
+	renameCmd.Flags().Bool("fields", false, "Also rename field and method names")
+	renameCmd.Flags().Bool("dry-run", false, "Print a diff instead of writing files")
+	renameCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: config target, then roblox)")
+	renameCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+
+	rootCmd.AddCommand(renameCmd)
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"rename"})
+	require.NoError(t, err, "rename should be registered")
+	assert.Equal(t, renameCmd, cmd)
+
+	for _, name := range []string{"entry", "symbol", "to", "fields", "dry-run"} {
+		assert.NotNil(t, renameCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
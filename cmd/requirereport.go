@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+)
+
+// printRequireReport prints the require report as a table, or writes it as
+// JSON when dest is a file path
+func printRequireReport(uses []bundler.RequireUse, dest string) error {
+	if dest == "table" {
+		fmt.Println()
+		fmt.Println(infoStyle.Render("📋 Requires:"))
+		fmt.Print(formatRequireReport(uses))
+		return nil
+	}
+
+	data, err := json.MarshalIndent(uses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode require report: %w", err)
+	}
+	if err := os.WriteFile(dest, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write require report: %w", err)
+	}
+	fmt.Printf("%s %s\n", infoStyle.Render("📋 Require report:"), dest)
+	return nil
+}
+
+// formatRequireReport renders the require report as a table followed by
+// the count of each status
+func formatRequireReport(uses []bundler.RequireUse) string {
+	header := [3]string{"STATUS", "REQUIRE", "FROM"}
+	rows := make([][4]string, len(uses))
+	widths := [3]int{len(header[0]), len(header[1]), len(header[2])}
+	counts := make(map[string]int)
+	for i, use := range uses {
+		module := use.Module
+		if module == "" {
+			module = "(computed)"
+		}
+		detail := use.Source
+		if use.Reason != "" && detail != "" {
+			detail += " (" + use.Reason + ")"
+		} else if use.Reason != "" {
+			detail = use.Reason
+		}
+		rows[i] = [4]string{use.Status, module, fmt.Sprintf("%s:%d", use.File, use.Line), detail}
+		for j := range widths {
+			widths[j] = max(widths[j], len(rows[i][j]))
+		}
+		counts[use.Status]++
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%-*s  %-*s  %-*s  %s\n", widths[0], header[0], widths[1], header[1], widths[2], header[2], "SOURCE / REASON")
+	for _, row := range rows {
+		line := fmt.Sprintf("%-*s  %-*s  %-*s  %s", widths[0], row[0], widths[1], row[1], widths[2], row[2], row[3])
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	var summary []string
+	for _, status := range bundler.RequireStatuses {
+		summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	fmt.Fprintf(&out, "%d require(s): %s\n", len(uses), strings.Join(summary, ", "))
+	return out.String()
+}
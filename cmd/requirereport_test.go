@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRequireUses = []bundler.RequireUse{
+	{Module: "util", File: "main.lua", Line: 2, Status: bundler.RequireBundled, Source: "util.lua"},
+	{Module: "analytics", File: "main.lua", Line: 5, Status: bundler.RequireBundled, Reason: "omitted"},
+	{Module: "Players.Local", File: "util.lua", Line: 12, Status: bundler.RequireExternal, Reason: "starts with the Roblox service Players"},
+	{File: "main.lua", Line: 4, Status: bundler.RequireDynamic},
+}
+
+func TestFormatRequireReport(t *testing.T) {
+	assert.Equal(t, ""+
+		"STATUS    REQUIRE        FROM         SOURCE / REASON\n"+
+		"bundled   util           main.lua:2   util.lua\n"+
+		"bundled   analytics      main.lua:5   omitted\n"+
+		"external  Players.Local  util.lua:12  starts with the Roblox service Players\n"+
+		"dynamic   (computed)     main.lua:4\n"+
+		"4 require(s): 2 bundled, 0 duplicate, 1 external, 1 dynamic\n",
+		formatRequireReport(testRequireUses))
+}
+
+func TestPrintRequireReport_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requires.json")
+	require.NoError(t, printRequireReport(testRequireUses, path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var uses []bundler.RequireUse
+	require.NoError(t, json.Unmarshal(data, &uses))
+	assert.Equal(t, testRequireUses, uses)
+	assert.Contains(t, string(data), `"status": "dynamic"`)
+	assert.NotContains(t, string(data), `"module": ""`)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/codemod"
+	"github.com/spf13/cobra"
+)
+
+var rewriteRequiresCmd = &cobra.Command{
+	Use:   "rewrite-requires",
+	Short: "Rewrite require paths across the project to another convention",
+	Long: `Rewrite the string paths of require calls in every .lua/.luau file under --dir.
+
+--from/--to take either two styles or two path prefixes:
+  dot    modules.tasks.cook (resolved from the project root)
+  slash  /modules/tasks/cook, ./cook.lua, ../util
+
+  lua-bundler rewrite-requires --from dot --to slash
+  lua-bundler rewrite-requires --from old/folder --to new/folder
+
+Files are tokenized, so requires inside comments and other strings are left
+alone. Paths that cannot be expressed in the target style are skipped.
+Use --dry-run to print a diff without writing anything.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		dir, _ := cmd.Flags().GetString("dir")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		rewriter, err := codemod.NewRequireRewriter(from, to, dir)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		files, err := findLuaFiles(dir)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		changed, total := 0, 0
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to read %s: %v", file, err)))
+				os.Exit(1)
+			}
+
+			output, changes, err := rewriter.RewriteSource(file, string(content))
+			if err != nil {
+				fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  Skipping %v", err)))
+				continue
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			changed++
+			total += len(changes)
+
+			if dryRun {
+				name, err := filepath.Rel(dir, file)
+				if err != nil {
+					name = file
+				}
+				fmt.Print(codemod.Diff(filepath.ToSlash(name), string(content), output))
+				continue
+			}
+			if err := os.WriteFile(file, []byte(output), 0644); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write %s: %v", file, err)))
+				os.Exit(1)
+			}
+			fmt.Printf("%s %s (%d)\n", successStyle.Render("✎"), file, len(changes))
+		}
+
+		fmt.Println()
+		switch {
+		case total == 0:
+			fmt.Println(infoStyle.Render("No require paths to rewrite"))
+		case dryRun:
+			fmt.Println(infoStyle.Render(fmt.Sprintf("🔍 Dry run: %d requires in %d files would be rewritten", total, changed)))
+		default:
+			fmt.Println(successStyle.Render(fmt.Sprintf("✅ Rewrote %d requires in %d files", total, changed)))
+		}
+	},
+}
+
+// findLuaFiles returns the .lua and .luau files under dir, skipping hidden directories
+func findLuaFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext == ".lua" || ext == ".luau" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func init() {
+	rewriteRequiresCmd.Flags().String("from", "", "Current convention: dot, slash, or a path prefix")
+	rewriteRequiresCmd.Flags().String("to", "", "New convention: dot, slash, or a path prefix")
+	rewriteRequiresCmd.Flags().StringP("dir", "d", ".", "Project root to rewrite")
+	rewriteRequiresCmd.Flags().Bool("dry-run", false, "Print a diff instead of writing files")
+
+	rootCmd.AddCommand(rewriteRequiresCmd)
+}
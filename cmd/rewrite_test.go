@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteRequiresCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"rewrite-requires"})
+	require.NoError(t, err, "rewrite-requires should be registered")
+	assert.Equal(t, rewriteRequiresCmd, cmd)
+
+	for _, name := range []string{"from", "to", "dir", "dry-run"} {
+		assert.NotNil(t, rewriteRequiresCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestFindLuaFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	for _, name := range []string{"main.lua", "lib/util.luau", "lib/readme.md", ".git/hook.lua"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(""), 0644))
+	}
+
+	files, err := findLuaFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "lib", "util.luau"), filepath.Join(dir, "main.lua")}, files)
+}
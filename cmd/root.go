@@ -1,13 +1,27 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/constt/lua-bundler/internal/geoip"
+	"github.com/constt/lua-bundler/internal/git"
 	httpserver "github.com/constt/lua-bundler/internal/http"
+	"github.com/constt/lua-bundler/internal/lockfile"
+	"github.com/constt/lua-bundler/internal/notify"
+	"github.com/constt/lua-bundler/internal/obfuscator"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -53,7 +67,7 @@ var rootCmd = &cobra.Command{
 		"  • Bundle local Lua modules with require()",
 		"  • Embed HTTP dependencies from game:HttpGet()",
 		"  • Release mode to remove debug statements",
-		"  • Code obfuscation support (3 levels)",
+		"  • Code obfuscation presets and custom pass pipelines",
 		"  • HTTP server to serve bundled output",
 		"  • Beautiful terminal output with colors",
 		"",
@@ -62,18 +76,289 @@ var rootCmd = &cobra.Command{
 		"  lua-bundler -e main.lua -o bundle.lua --serve --port 8080",
 	),
 	Run: func(cmd *cobra.Command, args []string) {
+		started := time.Now()
 		entryFile, _ := cmd.Flags().GetString("entry")
 		outputFile, _ := cmd.Flags().GetString("output")
 		release, _ := cmd.Flags().GetBool("release")
 		verbose, _ := cmd.Flags().GetBool("verbose")
-		obfuscateLevel, _ := cmd.Flags().GetInt("obfuscate")
+		interactive, _ := cmd.Flags().GetBool("interactive")
+		obfuscate, _ := cmd.Flags().GetString("obfuscate")
+		virtualize, _ := cmd.Flags().GetStringSlice("virtualize")
 		serve, _ := cmd.Flags().GetBool("serve")
 		port, _ := cmd.Flags().GetInt("port")
+		bind, _ := cmd.Flags().GetString("bind")
+		socket, _ := cmd.Flags().GetString("socket")
+		tunnel, _ := cmd.Flags().GetString("tunnel")
+		watch, _ := cmd.Flags().GetBool("watch")
+		watchInterval, _ := cmd.Flags().GetDuration("watch-interval")
+		errorSnippet, _ := cmd.Flags().GetBool("error-snippet")
+		desktopNotify, _ := cmd.Flags().GetBool("notify")
+		skipSync, _ := cmd.Flags().GetBool("no-sync")
+		injectInto, _ := cmd.Flags().GetStringSlice("inject")
+		injectAutoexec, _ := cmd.Flags().GetBool("autoexec")
+		injectExecute, _ := cmd.Flags().GetBool("execute")
+		buildAPI, _ := cmd.Flags().GetBool("build-api")
+		buildToken, _ := cmd.Flags().GetString("build-token")
+		variantsToken, _ := cmd.Flags().GetString("variants-token")
+		dashboard, _ := cmd.Flags().GetBool("dashboard")
+		dashboardToken, _ := cmd.Flags().GetString("dashboard-token")
+		serveMaps, _ := cmd.Flags().GetBool("serve-maps")
+		serveVariants, _ := cmd.Flags().GetBool("serve-variants")
+		signedURLs, _ := cmd.Flags().GetBool("signed-urls")
+		signingSecret, _ := cmd.Flags().GetString("signing-secret")
+		mapsToken, _ := cmd.Flags().GetString("maps-token")
+		debugDir, _ := cmd.Flags().GetString("debug-files")
+		debugName, _ := cmd.Flags().GetString("debug-name")
+		buildMaxSize, _ := cmd.Flags().GetInt64("build-max-size")
+		buildWorkers, _ := cmd.Flags().GetInt("build-workers")
+		gitInfo, _ := cmd.Flags().GetBool("git-info")
+		requestShim, _ := cmd.Flags().GetBool("request-shim")
+		strict, _ := cmd.Flags().GetBool("strict")
+		safeWrap, _ := cmd.Flags().GetBool("safe-wrap")
+		stateKey, _ := cmd.Flags().GetString("state-key")
+		sizeLimit, _ := cmd.Flags().GetString("size-limit")
+		sourceEncoding, _ := cmd.Flags().GetString("source-encoding")
+		lineEndings, _ := cmd.Flags().GetString("line-endings")
+		largeModuleSize, _ := cmd.Flags().GetInt64("large-module-size")
+		profile, _ := cmd.Flags().GetBool("profile")
 		noCache, _ := cmd.Flags().GetBool("no-cache")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		httpOptions := httpOptionsFromFlags(cmd)
+		httpOptions.Offline, _ = cmd.Flags().GetBool("offline")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		showGraph, _ := cmd.Flags().GetBool("graph")
+		requireReport, _ := cmd.Flags().GetString("require-report")
+		preview, _ := cmd.Flags().GetString("preview")
+		appendLicenses, _ := cmd.Flags().GetBool("append-licenses")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		format, _ := cmd.Flags().GetString("format")
+		pluginFile, _ := cmd.Flags().GetString("plugin")
+		hostedURL, _ := cmd.Flags().GetString("hosted-url")
+		shortener, _ := cmd.Flags().GetString("shorten")
+		copySnippet, _ := cmd.Flags().GetBool("copy")
+		defineFlags, _ := cmd.Flags().GetStringArray("define")
+		noWarn, _ := cmd.Flags().GetStringSlice("no-warn")
+		keepPatterns, _ := cmd.Flags().GetStringArray("keep-pattern")
+		logShim, _ := cmd.Flags().GetString("log-shim")
+		debugCalls, _ := cmd.Flags().GetString("debug-calls")
+		minifyLevel, _ := cmd.Flags().GetInt("minify")
+		preserveLines, _ := cmd.Flags().GetBool("minify-preserve-lines")
+		bannerFile, _ := cmd.Flags().GetString("banner-file")
+		footerFile, _ := cmd.Flags().GetString("footer-file")
+		loader, _ := cmd.Flags().GetString("loader")
+		inlineSmall, _ := cmd.Flags().GetInt("inline-small")
+		bundleFormat, _ := cmd.Flags().GetInt("bundle-format")
+		resolution, _ := cmd.Flags().GetString("resolution")
+		noMemoize, _ := cmd.Flags().GetStringArray("no-memoize")
+		stubFlags, _ := cmd.Flags().GetStringArray("stub")
+		omit, _ := cmd.Flags().GetStringArray("omit")
+		features, _ := cmd.Flags().GetStringSlice("features")
+		localeFiles, _ := cmd.Flags().GetStringArray("locale")
+		lualib, _ := cmd.Flags().GetStringArray("lualib")
+		addonLibs, _ := cmd.Flags().GetStringArray("addon-libs")
+		side, _ := cmd.Flags().GetString("side")
+		defaultLocale, _ := cmd.Flags().GetString("default-locale")
+		flattenDepth, err := cmd.Flags().GetInt("flatten-depth")
+		if err != nil {
+			flattenDepth = -1
+		}
 
 		if entryFile == "" {
 			fmt.Println(errorStyle.Render("❌ Entry file is required"))
-			os.Exit(1)
+			exit(1)
+		}
+
+		gitSpec, _ := cmd.Flags().GetString("git")
+		if gitSpec != "" {
+			checkout, err := checkoutGit(gitSpec, entryFile)
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+			// Serving and watching only end on a signal, and errors exit
+			// without running deferred calls
+			atExit(func() { os.RemoveAll(checkout) })
+			defer runCleanups()
+			exitOnSignal()
+			entryFile = filepath.Join(checkout, filepath.FromSlash(entryFile))
+		}
+
+		if format == "" && filepath.Ext(outputFile) == "" {
+			if bundler.IsTOC(entryFile) {
+				format = bundler.FormatAddon
+			} else if bundler.IsFXManifest(entryFile) {
+				format = bundler.FormatResource
+			}
+		}
+		format, err = outputFormat(format, outputFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if pluginFile != "" && format != bundler.FormatLua {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ --plugin wraps Lua output and cannot be combined with --format %s", format)))
+			exit(1)
+		}
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		// A checkout's config is someone else's: its commands and the
+		// folders it writes to would run and be written on this machine
+		trustConfig, _ := cmd.Flags().GetBool("trust-config")
+		if gitSpec != "" && configPath == "" && !trustConfig && (len(cfg.Sync) > 0 || len(cfg.Executors) > 0) {
+			fmt.Println(warningStyle.Render("⚠️  Ignoring the sync actions and executors of the checkout's config (pass --trust-config to use them)"))
+			cfg.Sync, cfg.Executors = nil, nil
+		}
+		if skipSync {
+			cfg.Sync = nil
+		}
+		for _, action := range cfg.Sync {
+			if err := action.Validate(); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+		injection := injectOptions{Executors: cfg.Executors, Names: injectInto, Autoexec: injectAutoexec, Execute: injectExecute}
+		if (injectAutoexec || injectExecute) && len(injectInto) == 0 {
+			fmt.Println(errorStyle.Render("❌ --autoexec and --execute need --inject"))
+			exit(1)
+		}
+		if err := injection.check(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		obfuscation, err := obfuscationPasses(cfg, obfuscate)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" && format == bundler.FormatLove {
+			target = bundler.TargetLove2D
+		}
+		if target == "" && bundler.IsTOC(entryFile) {
+			target = bundler.TargetWoW
+		}
+		if target == "" && bundler.IsFXManifest(entryFile) {
+			target = bundler.TargetFiveM
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+		if format == bundler.FormatLove && target != bundler.TargetLove2D {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ .love output requires the %s target (got %s)", bundler.TargetLove2D, target)))
+			exit(1)
+		}
+		if format == bundler.FormatAddon && !bundler.IsTOC(entryFile) {
+			fmt.Println(errorStyle.Render("❌ addon output needs a .toc entry file"))
+			exit(1)
+		}
+		if bundler.IsTOC(entryFile) && target != bundler.TargetWoW {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ .toc entries require the %s target (got %s)", bundler.TargetWoW, target)))
+			exit(1)
+		}
+		if format == bundler.FormatResource && !bundler.IsFXManifest(entryFile) {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ resource output needs a %s entry file", bundler.FXManifest)))
+			exit(1)
+		}
+		if bundler.IsFXManifest(entryFile) && target != bundler.TargetFiveM {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %s entries require the %s target (got %s)", filepath.Base(entryFile), bundler.TargetFiveM, target)))
+			exit(1)
+		}
+
+		if dashboardToken == "" {
+			dashboardToken = os.Getenv("LUA_BUNDLER_DASHBOARD_TOKEN")
+		}
+		if dashboard && (!serve || dashboardToken == "") {
+			fmt.Println(errorStyle.Render("❌ --dashboard needs --serve and a token (--dashboard-token or $LUA_BUNDLER_DASHBOARD_TOKEN)"))
+			exit(1)
+		}
+		if mapsToken == "" {
+			mapsToken = os.Getenv("LUA_BUNDLER_MAPS_TOKEN")
+		}
+		if serveMaps && (!serve || mapsToken == "") {
+			fmt.Println(errorStyle.Render("❌ --serve-maps needs --serve and a token (--maps-token or $LUA_BUNDLER_MAPS_TOKEN)"))
+			exit(1)
+		}
+		if buildToken == "" {
+			buildToken = os.Getenv("LUA_BUNDLER_BUILD_TOKEN")
+		}
+		if buildAPI && (!serve || buildToken == "") {
+			fmt.Println(errorStyle.Render("❌ --build-api needs --serve and a token (--build-token or $LUA_BUNDLER_BUILD_TOKEN)"))
+			exit(1)
+		}
+		if variantsToken == "" {
+			variantsToken = os.Getenv("LUA_BUNDLER_VARIANTS_TOKEN")
+		}
+		if serveVariants && (!serve || variantsToken == "") {
+			fmt.Println(errorStyle.Render("❌ --serve-variants needs --serve and a token (--variants-token or $LUA_BUNDLER_VARIANTS_TOKEN)"))
+			exit(1)
+		}
+		if serveVariants && format != bundler.FormatLua {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ --serve-variants needs lua output (got %s)", format)))
+			exit(1)
+		}
+		if signingSecret == "" {
+			signingSecret = os.Getenv(signingSecretEnv)
+		}
+		if signedURLs && (!serve || signingSecret == "") {
+			fmt.Println(errorStyle.Render("❌ --signed-urls needs --serve and a secret (--signing-secret or $" + signingSecretEnv + ")"))
+			exit(1)
+		}
+		if tunnel != "" && (!serve || socket != "") {
+			fmt.Println(errorStyle.Render("❌ --tunnel needs --serve on a port, not a --socket"))
+			exit(1)
+		}
+		if tunnel != "" {
+			if err := httpserver.CheckTunnel(tunnel); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+		if errorSnippet && (!watch || !serve) {
+			fmt.Println(errorStyle.Render("❌ --error-snippet needs --watch and --serve"))
+			exit(1)
+		}
+		if preview != "" && (watch || serve) {
+			fmt.Println(errorStyle.Render("❌ --preview cannot be combined with --watch or --serve"))
+			exit(1)
+		}
+		if desktopNotify && !watch {
+			fmt.Println(errorStyle.Render("❌ --notify needs --watch"))
+			exit(1)
+		}
+		if watch && watchInterval <= 0 {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ --watch-interval must be positive (got %s)", watchInterval)))
+			exit(1)
+		}
+		if noCache && httpOptions.Offline {
+			fmt.Println(errorStyle.Render("❌ --offline builds from the cache and cannot be combined with --no-cache"))
+			exit(1)
+		}
+		access, err := accessOptions(cmd.Flags())
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+
+		var revision *bundler.GitInfo
+		if gitInfo || cfg.GitInfo {
+			if revision, err = readGitInfo(entryFile); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
 		}
 
 		// Print header
@@ -81,18 +366,33 @@ var rootCmd = &cobra.Command{
 		fmt.Println()
 		fmt.Println(infoStyle.Render("Configuration:"))
 		fmt.Printf("  Entry: %s\n", entryFile)
+		if gitSpec != "" {
+			fmt.Printf("  Git: %s\n", infoStyle.Render(gitSpec))
+		}
 		fmt.Printf("  Output: %s\n", outputFile)
+		fmt.Printf("  Target: %s\n", target)
+		if cfg.Path() != "" {
+			fmt.Printf("  Config: %s\n", cfg.Path())
+		}
+		if lock != nil {
+			fmt.Printf("  Lockfile: %s\n", lock.Path())
+		}
 		if release {
 			fmt.Printf("  Mode: %s\n", warningStyle.Render("Release (debug statements removed)"))
 		} else {
 			fmt.Printf("  Mode: %s\n", infoStyle.Render("Development"))
 		}
-		if obfuscateLevel > 0 {
-			levelName := []string{"None", "Basic", "Medium", "Heavy"}
-			if obfuscateLevel > 3 {
-				obfuscateLevel = 3
-			}
-			fmt.Printf("  Obfuscation: %s\n", warningStyle.Render(levelName[obfuscateLevel]))
+		if minifyLevel >= 0 {
+			fmt.Printf("  Minify: %s\n", infoStyle.Render(fmt.Sprintf("Level %d", minifyLevel)))
+		}
+		if len(obfuscation) > 0 {
+			fmt.Printf("  Obfuscation: %s\n", warningStyle.Render(obfuscator.Describe(obfuscation)))
+		}
+		if rules := len(cfg.Obfuscation.Modules); rules > 0 {
+			fmt.Printf("  Module obfuscation rules: %s\n", warningStyle.Render(fmt.Sprint(rules)))
+		}
+		if len(virtualize) > 0 {
+			fmt.Printf("  Virtualize: %s\n", warningStyle.Render(strings.Join(virtualize, ", ")))
 		}
 		if verbose {
 			fmt.Printf("  Verbose: %s\n", infoStyle.Render("Enabled"))
@@ -102,58 +402,623 @@ var rootCmd = &cobra.Command{
 		}
 		if noCache {
 			fmt.Printf("  HTTP Cache: %s\n", warningStyle.Render("Disabled"))
+		} else if httpOptions.Offline {
+			fmt.Printf("  HTTP Cache: %s\n", infoStyle.Render("Offline (no downloads)"))
 		} else {
 			fmt.Printf("  HTTP Cache: %s\n", infoStyle.Render("Enabled"))
 		}
+		if httpOptions.Proxy != "" {
+			fmt.Printf("  Proxy: %s\n", infoStyle.Render(redactProxy(httpOptions.Proxy)))
+		}
+		if httpOptions.RemoteCache != "" && !noCache && !httpOptions.Offline {
+			fmt.Printf("  Remote Cache: %s\n", infoStyle.Render(redactProxy(httpOptions.RemoteCache)+" ("+httpOptions.RemoteCacheMode+")"))
+		}
+		if cfg.Version != "" {
+			fmt.Printf("  Version: %s\n", infoStyle.Render(cfg.Version))
+		}
+		if format != bundler.FormatLua {
+			fmt.Printf("  Format: %s\n", infoStyle.Render(format))
+		}
+		if namespace != "" {
+			fmt.Printf("  Namespace: %s\n", infoStyle.Render(namespace))
+		}
+		if loader != "" {
+			fmt.Printf("  Loader: %s\n", infoStyle.Render(loader))
+		}
+		if resolution != "" {
+			fmt.Printf("  Resolution: %s\n", infoStyle.Render(resolution))
+		}
+		if flattenDepth >= 0 {
+			fmt.Printf("  Flatten Depth: %s\n", infoStyle.Render(fmt.Sprintf("%d", flattenDepth)))
+		}
+		if revision != nil {
+			fmt.Printf("  Revision: %s\n", infoStyle.Render(revision.String()))
+		}
+		if cmd.Flags().Changed("features") {
+			fmt.Printf("  Features: %s\n", infoStyle.Render(strings.Join(features, ", ")))
+		}
+		if len(localeFiles) > 0 {
+			fmt.Printf("  Locales: %s\n", infoStyle.Render(strings.Join(localeFiles, ", ")))
+		}
 		fmt.Println()
 
 		// Create bundler
 		b, err := bundler.NewBundler(entryFile, verbose, !noCache)
 		if err != nil {
 			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		// Set obfuscation level (will be applied per-module during bundling for local files only)
-		if obfuscateLevel > 0 {
-			b.SetObfuscationLevel(obfuscateLevel)
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		if interactive {
+			path := cfg.Path()
+			if path == "" {
+				path = filepath.Join(projectDir(entryFile), config.FileName)
+			}
+			b.SetAmbiguityResolver(promptResolver(os.Stdin, os.Stdout, path))
+		}
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		b.SetFlattenDepth(flattenDepth)
+		if err := b.SetNamespace(namespace); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+
+		if err := b.SetHTTPOptions(httpOptions); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := applyDefines(b, cfg.Defines, defineFlags); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := b.SuppressWarnings(append(cfg.SuppressWarnings, noWarn...)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := b.SetKeepPatterns(append(cfg.KeepPatterns, keepPatterns...)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if logShim == "" {
+			logShim = cfg.LogShim
+		}
+		if err := b.SetLogShim(logShim); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if debugCalls == "" {
+			debugCalls = cfg.DebugCalls
+		}
+		if err := b.SetDebugCalls(debugCalls); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := b.SetMinifyLevel(minifyLevel); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		b.SetPreserveLines(preserveLines)
+		if loader == "" {
+			loader = cfg.Loader
+		}
+		if err := b.SetLoader(loader); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if inlineSmall < 0 {
+			inlineSmall = cfg.InlineSmall
+		}
+		if err := b.SetInlineSmall(inlineSmall); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := b.SetBundleFormat(bundleFormat); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := applyEncoding(b, cfg, sourceEncoding, lineEndings); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if resolution == "" {
+			resolution = cfg.Resolution
+		}
+		if err := b.SetResolution(resolution); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		b.SetNoMemoize(append(cfg.NoMemoize, noMemoize...))
+		b.SetHooks(cfg.Prelude, cfg.Epilogue)
+		if err := applyStubs(b, cfg, stubFlags, omit); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := applyFeatures(b, cfg.Features, features, cmd.Flags().Changed("features")); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := applyLocales(b, cfg, localeFiles, defaultLocale); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		applyLualib(b, cfg, lualib)
+		applyAddonLibs(b, cfg, addonLibs)
+		if side != "" {
+			if err := b.SetSide(side); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+		if err := loadBanner(b, bannerFile, footerFile); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		if err := b.SetVersion(cfg.Version); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		b.SetGitInfo(revision)
+		b.SetRequestShim(requestShim || cfg.RequestShim)
+		b.SetStrict(strict || cfg.Strict)
+		b.SetSafeWrap(safeWrap || cfg.SafeWrap)
+		b.SetErrorHandler(cfg.ErrorHandler)
+		if stateKey == "" {
+			stateKey = cfg.StateKey
+		}
+		b.SetStateKey(stateKey)
+		if sizeLimit == "" {
+			sizeLimit = cfg.SizeLimit
+		}
+		if err := b.SetSizeLimit(sizeLimit); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+		b.SetLargeModuleSize(largeModuleSize)
+		b.SetProfile(profile)
+
+		// Set obfuscation passes (applied per-module during bundling for local files only)
+		if err := applyObfuscation(b, cfg, obfuscation, virtualize); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
 		}
 
 		// Bundle
 		fmt.Println(infoStyle.Render("🔄 Processing dependencies..."))
-		result, err := b.Bundle(release)
+		var result string
+		var timings *bundler.Timings
+		if format == bundler.FormatRbxmx {
+			name := strings.TrimSuffix(filepath.Base(outputFile), filepath.Ext(outputFile))
+			result, err = b.BundleModel(name, release)
+		} else {
+			var built *bundler.BundleResult
+			if built, err = b.BundleWithResult(release); err == nil {
+				result, timings = built.Output, &built.Timings
+			}
+		}
 		if err != nil {
 			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Bundling failed: %v", err)))
-			os.Exit(1)
+			exit(1)
+		}
+
+		// Appended after release mode so the notice survives comment stripping
+		if appendLicenses && format != bundler.FormatRbxmx {
+			result += "\n" + bundler.LicenseComment(b.Licenses())
+		}
+
+		// Preview instead of writing anything
+		if preview != "" {
+			content, err := previewContent(b, result, preview)
+			if err == nil {
+				err = showPreview(content)
+			}
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+			return
 		}
 
 		// Write output
-		if err := os.WriteFile(outputFile, []byte(result), 0644); err != nil {
+		data := []byte(result)
+		if format == bundler.FormatLove {
+			if data, err = loveArchive(b, result, entryFile, outputFile); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+		bundleFile := outputFile
+		if format == bundler.FormatAddon {
+			if bundleFile, err = writeAddon(b, result, entryFile, outputFile); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		} else if format == bundler.FormatResource {
+			if bundleFile, err = writeResource(b, result, release, appendLicenses, entryFile, outputFile); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		} else if err := os.WriteFile(outputFile, data, 0644); err != nil {
 			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write output: %v", err)))
-			os.Exit(1)
+			exit(1)
+		}
+		var loveConf string
+		if target == bundler.TargetLove2D && format == bundler.FormatLua {
+			if loveConf, err = copyLoveConf(entryFile, outputFile); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+
+		if lock != nil {
+			if err := lock.Save(); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+
+		if pluginFile != "" {
+			if err := writePlugin(b, result, pluginFile, cfg.Plugin); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+
+		if debugDir != "" {
+			if debugName == "" {
+				debugName = filepath.Base(outputFile)
+			}
+			if err := writeDebugFiles(b, debugDir, debugName, cfg.Version, release); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
 		}
 
 		// Success message
-		printSuccess(b, outputFile, obfuscateLevel)
+		printSuccess(b, bundleFile, obfuscation, timings)
+		if lines := profileReportLines(b.FileProfiles()); len(lines) > 0 {
+			fmt.Println(infoStyle.Render("📊 Module profile:"))
+			for _, line := range lines {
+				fmt.Println(line)
+			}
+		}
+		if format == bundler.FormatResource {
+			fmt.Printf("%s %s\n", infoStyle.Render("📦 Resource folder:"), outputFile)
+		}
+		if format == bundler.FormatAddon {
+			fmt.Printf("%s %s\n", infoStyle.Render("📦 Addon folder:"), outputFile)
+			if !addonFolderMatches(outputFile, entryFile) {
+				fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  WoW only loads %s from a folder of the same name", filepath.Base(entryFile))))
+			}
+		} else if xml := b.AddonXML(); len(xml) > 0 {
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  Not merged, list them after the bundle in the .toc: %s", strings.Join(xml, ", "))))
+		}
+		if pluginFile != "" {
+			fmt.Printf("%s %s\n", infoStyle.Render("🧩 Studio plugin:"), pluginFile)
+		}
+		if loveConf != "" {
+			fmt.Printf("%s %s\n", infoStyle.Render("⚙️  Copied conf.lua:"), loveConf)
+		}
+		if target == bundler.TargetLove2D && format == bundler.FormatLua && filepath.Base(outputFile) != "main.lua" {
+			fmt.Println(warningStyle.Render("⚠️  LÖVE runs main.lua; rename the output or write a .love file"))
+		}
+		deliverBundle := func() error {
+			results, err := runSync(cfg.Sync, outputFile, syncDir(cfg, entryFile))
+			printSync(results)
+			if err != nil {
+				return err
+			}
+			injected, err := injectBundle(injection, outputFile, syncDir(cfg, entryFile))
+			printInject(injected)
+			return err
+		}
+		if err := deliverBundle(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			exit(1)
+		}
+
+		if showGraph {
+			fmt.Println()
+			fmt.Println(infoStyle.Render("🌳 Dependency graph:"))
+			fmt.Print(b.FormatGraph())
+		}
+		if requireReport != "" {
+			if err := printRequireReport(b.RequireReport(), requireReport); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+		}
+
+		if shortener == "" {
+			shortener = cfg.Shortener
+		}
+		if hostedURL == "" && serve && socket == "" {
+			hostedURL = httpserver.LocalURL(outputFile, port)
+		}
+		if hostedURL != "" && format == bundler.FormatLua {
+			printLoader(hostedURL, shortener, copySnippet)
+		}
+
+		// Rebuilds write the debug files the server hands out as well
+		var maps *debugRefresh
+		if serve && serveMaps {
+			files, err := debugFiles(b, outputFile, cfg.Version, release, data)
+			if err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				exit(1)
+			}
+			maps = &debugRefresh{files: files, name: filepath.Base(outputFile)}
+		}
+
+		var watcher *watcher
+		if watch {
+			watcher = newWatcher(entryFile, outputFile, func(output string) (string, error) {
+				if maps == nil {
+					return buildVariant(cmd.Flags(), httpserver.Variant{}, output)
+				}
+				return maps.rebuild(output, func(extra ...string) (string, error) {
+					return buildVariant(cmd.Flags(), httpserver.Variant{}, output, extra...)
+				})
+			})
+			if desktopNotify {
+				watcher.notify = notify.Send
+			}
+			if len(cfg.Sync) > 0 || len(injectInto) > 0 {
+				watcher.afterBuild = deliverBundle
+			}
+			fmt.Println()
+			fmt.Println(infoStyle.Render(fmt.Sprintf("👀 Watching %s for changes...", watcher.dir)))
+			if !serve {
+				watcher.run(watchInterval, nil)
+				return
+			}
+			go watcher.run(watchInterval, nil)
+		}
 
 		// Start HTTP server if serve flag is enabled
 		if serve {
-			httpserver.StartServer(outputFile, port)
+			opts := httpserver.ServerOptions{
+				Access: access,
+				Bind:   bind,
+				Socket: socket,
+				Tunnel: tunnel,
+			}
+			if buildAPI {
+				opts.Build = httpserver.BuildOptions{Token: buildToken, MaxSize: buildMaxSize, Workers: buildWorkers}
+			}
+			if signedURLs {
+				opts.Signing.Secret = signingSecret
+			}
+			if watcher != nil {
+				opts.Watch = httpserver.WatchOptions{Status: watcher.status, ErrorSnippet: errorSnippet}
+			}
+			if dashboard {
+				build := func(extra ...string) (string, error) {
+					return rebuild(cmd.Flags(), outputFile, extra...)
+				}
+				opts.Dashboard = httpserver.DashboardOptions{Token: dashboardToken, BuildTime: time.Since(started), Rebuild: func() (string, error) {
+					if maps == nil {
+						return build()
+					}
+					return maps.rebuild(outputFile, build)
+				}}
+			}
+			if maps != nil {
+				opts.Debug = httpserver.DebugOptions{Token: mapsToken, Files: maps.files}
+			}
+			if serveVariants {
+				opts.Variants = httpserver.VariantOptions{Token: variantsToken, Build: variantBuilder(cmd.Flags())}
+			}
+			opts.Exit = exit
+			httpserver.StartServer(outputFile, port, opts)
 		}
 	},
 }
 
-func printSuccess(b *bundler.Bundler, outputFile string, obfuscateLevel int) {
+// childExecutable is the binary child builds run
+var childExecutable = os.Executable
+
+// rebuild runs this build again to output in a child process that does
+// not serve, with extra arguments, returning what it printed
+func rebuild(flags *pflag.FlagSet, output string, extra ...string) (string, error) {
+	exe, err := childExecutable()
+	if err != nil {
+		return "", err
+	}
+	args := append(rebuildArgs(flags, output), extra...)
+	out, err := exec.Command(exe, args...).CombinedOutput()
+	return string(out), err
+}
+
+// rebuildArgs returns the arguments of a build like the one flags were
+// parsed for, writing to output. The server flags are left out, as the
+// child would refuse them without --serve, but it delivers the bundle as
+// this build did.
+func rebuildArgs(flags *pflag.FlagSet, output string) []string {
+	args := variantArgs(flags, httpserver.Variant{}, output)
+	flags.Visit(func(f *pflag.Flag) {
+		if deliveryFlags[f.Name] {
+			args = append(args, flagArgs(f)...)
+		}
+	})
+	return args
+}
+
+// serverFlags are left out of variant builds, which only write a bundle
+var serverFlags = map[string]bool{
+	"output": true, "serve": true, "port": true, "bind": true, "socket": true, "tunnel": true,
+	"watch": true, "watch-interval": true, "error-snippet": true, "notify": true, "no-sync": true,
+	"inject": true, "autoexec": true, "execute": true, "build-api": true, "build-token": true, "variants-token": true, "build-max-size": true, "build-workers": true,
+	"dashboard": true, "dashboard-token": true, "serve-maps": true, "maps-token": true, "serve-variants": true,
+	"hosted-url": true, "shorten": true, "copy": true, "interactive": true, "plugin": true,
+	"allow-ip": true, "deny-ip": true, "trusted-proxy": true, "geoip-db": true,
+	"allow-country": true, "deny-country": true, "deny-asn": true, "signed-urls": true, "signing-secret": true,
+	"debug-files": true, "debug-name": true,
+}
+
+// deliveryFlags are the serverFlags that rebuilds keep
+var deliveryFlags = map[string]bool{"no-sync": true, "inject": true, "autoexec": true, "execute": true}
+
+// accessOptions returns who may use the --serve server, opening the GeoIP
+// databases and checking the rules up front
+func accessOptions(flags *pflag.FlagSet) (httpserver.AccessOptions, error) {
+	var opts httpserver.AccessOptions
+	opts.Allow, _ = flags.GetStringSlice("allow-ip")
+	opts.Deny, _ = flags.GetStringSlice("deny-ip")
+	opts.TrustedProxies, _ = flags.GetStringSlice("trusted-proxy")
+	opts.AllowCountries, _ = flags.GetStringSlice("allow-country")
+	opts.DenyCountries, _ = flags.GetStringSlice("deny-country")
+	opts.DenyASNs, _ = flags.GetUintSlice("deny-asn")
+	databases, _ := flags.GetStringSlice("geoip-db")
+	for _, path := range databases {
+		db, err := geoip.Open(path)
+		if err != nil {
+			return opts, err
+		}
+		opts.GeoIP = append(opts.GeoIP, db)
+	}
+	_, err := httpserver.NewAccessControl(opts)
+	return opts, err
+}
+
+// variantBuilder returns the function building variants of this build in
+// a child process
+func variantBuilder(flags *pflag.FlagSet) func(httpserver.Variant, string) (string, error) {
+	return func(v httpserver.Variant, output string) (string, error) {
+		return buildVariant(flags, v, output)
+	}
+}
+
+// buildVariant builds a variant of this build to output in a child
+// process, with extra arguments, returning what it printed
+func buildVariant(flags *pflag.FlagSet, v httpserver.Variant, output string, extra ...string) (string, error) {
+	exe, err := childExecutable()
+	if err != nil {
+		return "", err
+	}
+	// Variants are only served; the bundle alone is synced
+	args := append(append(variantArgs(flags, v, output), "--no-sync"), extra...)
+	out, err := exec.Command(exe, args...).CombinedOutput()
+	return string(out), err
+}
+
+// variantArgs returns the arguments of a build like the one flags were
+// parsed for, changed as v asks and writing to output. Values are passed
+// as --name=value, so none can be read as another flag.
+func variantArgs(flags *pflag.FlagSet, v httpserver.Variant, output string) []string {
+	args := []string{"--output=" + output}
+	flags.Visit(func(f *pflag.Flag) {
+		if serverFlags[f.Name] || (f.Name == "features" && v.Features != nil) {
+			return
+		}
+		args = append(args, flagArgs(f)...)
+	})
+
+	for _, override := range [][2]string{{"release", v.Release}, {"obfuscate", v.Obfuscate}, {"minify", v.Minify}, {"target", v.Target}} {
+		if override[1] != "" {
+			args = append(args, "--"+override[0]+"="+override[1])
+		}
+	}
+	if v.Features != nil {
+		args = append(args, "--features="+strings.Join(v.Features, ","))
+	}
+	for _, define := range v.Defines {
+		args = append(args, "--define="+define)
+	}
+	return args
+}
+
+// flagArgs returns the arguments setting f as it was parsed
+func flagArgs(f *pflag.Flag) []string {
+	slice, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return []string{"--" + f.Name + "=" + f.Value.String()}
+	}
+	var args []string
+	values := slice.GetSlice()
+	if len(values) == 0 && f.Value.Type() == "stringSlice" {
+		args = append(args, "--"+f.Name+"=")
+	}
+	for _, value := range values {
+		args = append(args, "--"+f.Name+"="+value)
+	}
+	return args
+}
+
+// printLoader prints the loadstring one-liner for the bundle at bundleURL,
+// optionally shortening the URL and copying the snippet to the clipboard
+func printLoader(bundleURL, shortener string, copySnippet bool) {
+	snippet := httpserver.LoaderSnippet(bundleURL)
+	fmt.Println()
+	fmt.Printf("%s %s\n", infoStyle.Render("📋 Loader:"), snippet)
+
+	if shortener != "" {
+		short, err := httpserver.Shorten(shortener, bundleURL)
+		if err != nil {
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %v", err)))
+		} else {
+			snippet = httpserver.LoaderSnippet(short)
+			fmt.Printf("%s %s\n", infoStyle.Render("🔗 Short link:"), short)
+			fmt.Printf("%s %s\n", infoStyle.Render("📋 Short loader:"), snippet)
+		}
+	}
+
+	if copySnippet {
+		if err := httpserver.CopyToClipboard(snippet); err != nil {
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  Failed to copy to clipboard: %v", err)))
+		} else {
+			fmt.Println(successStyle.Render("📎 Copied to clipboard"))
+		}
+	}
+}
+
+func printSuccess(b *bundler.Bundler, outputFile string, obfuscation []obfuscator.Pass, timings *bundler.Timings) {
 	fmt.Println()
 	fmt.Println(successStyle.Render("✅ Successfully bundled!"))
 	fmt.Printf("%s %d\n",
 		infoStyle.Render("📦 Modules embedded:"),
 		len(b.GetModules()))
 
-	if obfuscateLevel > 0 {
-		fmt.Printf("%s Level %d applied\n",
+	if warnings := b.GetWarnings(); len(warnings) > 0 {
+		fmt.Printf("%s %d\n",
+			warningStyle.Render("⚠️  Warnings:"),
+			len(warnings))
+	}
+
+	if stats := b.CacheStats(); stats.Lookups() > 0 {
+		if total, err := b.RecordCacheStats(); err == nil {
+			fmt.Printf("%s %s\n", infoStyle.Render("💾 Cache:"), cacheBuildSummary(stats, total))
+		}
+	}
+
+	if timings != nil {
+		fmt.Printf("%s %s\n", infoStyle.Render("⏱️  Build time:"), timingsSummary(*timings))
+	}
+
+	if inlined := b.GetInlinedModules(); len(inlined) > 0 {
+		fmt.Printf("%s %d (%s)\n",
+			infoStyle.Render("📥 Inlined at require sites:"),
+			len(inlined), strings.Join(inlined, ", "))
+	}
+
+	if len(obfuscation) > 0 {
+		fmt.Printf("%s %s applied\n",
 			infoStyle.Render("🔒 Obfuscation:"),
-			obfuscateLevel)
+			obfuscator.Describe(obfuscation))
+	}
+	if lines := hotReportLines(b.HotReports()); len(lines) > 0 {
+		fmt.Println(infoStyle.Render("🔥 Hot functions:"))
+		for _, line := range lines {
+			fmt.Println(line)
+		}
 	}
 
 	fmt.Printf("%s %s\n",
@@ -161,6 +1026,368 @@ func printSuccess(b *bundler.Bundler, outputFile string, obfuscateLevel int) {
 		outputFile)
 }
 
+// profileLines caps the modules profileReportLines lists
+const profileLines = 15
+
+// timingsSummary renders the time a build took and its steps
+func timingsSummary(t bundler.Timings) string {
+	return fmt.Sprintf("%s (resolve %s, generate %s, postprocess %s)",
+		formatDuration(t.Total), formatDuration(t.Resolve), formatDuration(t.Generate), formatDuration(t.Postprocess))
+}
+
+// profileReportLines lists what reading and obfuscating each local module
+// cost, the most allocating first, and the total
+func profileReportLines(profiles []bundler.FileProfile) []string {
+	if len(profiles) == 0 {
+		return nil
+	}
+	var lines []string
+	var total uint64
+	for i, p := range profiles {
+		total += p.Allocated
+		if i >= profileLines {
+			continue
+		}
+		line := fmt.Sprintf("  %s  %s read, %s embedded, %s allocated", p.Module, formatBytes(int64(p.Size)), formatBytes(int64(p.Embedded)), formatBytes(int64(p.Allocated)))
+		if p.Large {
+			line += " (large, not obfuscated)"
+		}
+		lines = append(lines, line)
+	}
+	if len(profiles) > profileLines {
+		lines = append(lines, fmt.Sprintf("  ... %d more", len(profiles)-profileLines))
+	}
+	return append(lines, fmt.Sprintf("  %d module(s), %s allocated", len(profiles), formatBytes(int64(total))))
+}
+
+// hotReportLines lists the functions tagged --@hot, whether obfuscation
+// kept their structure and the passes that left them out
+func hotReportLines(reports []bundler.HotReport) []string {
+	var lines []string
+	for _, r := range reports {
+		for _, f := range r.Functions {
+			mark := successStyle.Render("✓")
+			if !f.Intact {
+				mark = errorStyle.Render("✗")
+			}
+			line := fmt.Sprintf("  %s %s:%d %s", mark, r.Module, f.Line, f.Name)
+			if len(f.Skipped) > 0 {
+				line += " (skipped " + strings.Join(f.Skipped, ", ") + ")"
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// applyEncoding sets how the build reads sources that are not UTF-8 and
+// the bundle's line endings, from the flags or else the config
+func applyEncoding(b *bundler.Bundler, cfg *config.Config, encoding, endings string) error {
+	if encoding == "" {
+		encoding = cfg.SourceEncoding
+	}
+	if endings == "" {
+		endings = cfg.LineEndings
+	}
+	if err := b.SetSourceEncoding(encoding); err != nil {
+		return err
+	}
+	return b.SetLineEndings(endings)
+}
+
+// obfuscationPasses returns the passes of the --obfuscate preset, or of the
+// config's obfuscation block when the flag is not given
+func obfuscationPasses(cfg *config.Config, preset string) ([]obfuscator.Pass, error) {
+	if preset != "" {
+		return obfuscator.ParsePreset(preset)
+	}
+	return cfg.Obfuscation.Pipeline()
+}
+
+// applyObfuscation sets the build's obfuscation passes and the config's
+// per-module obfuscation rules. Modules matching a --virtualize pattern
+// take the build's passes plus virtualize, ahead of the config rules.
+func applyObfuscation(b *bundler.Bundler, cfg *config.Config, passes []obfuscator.Pass, virtualize []string) error {
+	if err := b.SetObfuscation(passes); err != nil {
+		return err
+	}
+	rules := make([]bundler.ObfuscationRule, 0, len(virtualize)+len(cfg.Obfuscation.Modules))
+	for _, pattern := range virtualize {
+		rules = append(rules, bundler.ObfuscationRule{Pattern: pattern, Passes: obfuscator.Virtualized(passes)})
+	}
+	for _, m := range cfg.Obfuscation.Modules {
+		modulePasses, err := m.Pipeline()
+		if err != nil {
+			return err
+		}
+		rules = append(rules, bundler.ObfuscationRule{Pattern: m.Match, Passes: modulePasses})
+	}
+	return b.SetObfuscationRules(rules)
+}
+
+// readGitInfo returns the revision of the git checkout holding entryFile
+func readGitInfo(entryFile string) (*bundler.GitInfo, error) {
+	if bundler.IsURL(entryFile) {
+		return nil, fmt.Errorf("git info needs a local entry file, got %s", entryFile)
+	}
+	info, err := git.Describe(context.Background(), filepath.Dir(entryFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git info: %w", err)
+	}
+	return &bundler.GitInfo{Commit: info.Commit, Tag: info.Tag, Dirty: info.Dirty}, nil
+}
+
+// applyStubs sets the config stubs and omitted modules, overridden by
+// module=path --stub flags and added to by --omit flags. Config stub paths
+// are relative to the config file, flag paths to the working directory.
+func applyStubs(b *bundler.Bundler, cfg *config.Config, stubFlags, omit []string) error {
+	stubs := make(map[string]string, len(cfg.Stubs)+len(cfg.Omit)+len(stubFlags)+len(omit))
+	for module, path := range cfg.Stubs {
+		if path == "" {
+			return fmt.Errorf("invalid stub for %s in config: empty path (use omit instead)", module)
+		}
+		if cfg.Path() != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(cfg.Path()), path)
+		}
+		stubs[module] = path
+	}
+	for _, module := range cfg.Omit {
+		stubs[module] = ""
+	}
+	for _, flag := range stubFlags {
+		module, path, ok := strings.Cut(flag, "=")
+		if !ok || module == "" || path == "" {
+			return fmt.Errorf("invalid --stub %q: expected MODULE=PATH", flag)
+		}
+		stubs[module] = path
+	}
+	for _, module := range omit {
+		stubs[module] = ""
+	}
+	b.SetStubs(stubs)
+	return nil
+}
+
+// applyLualib sets the lualib paths of the openresty target: those given
+// with --lualib, or else the config's, which are relative to the config file
+func applyLualib(b *bundler.Bundler, cfg *config.Config, paths []string) {
+	if len(paths) == 0 {
+		paths = configPaths(cfg, cfg.Lualib)
+	}
+	if len(paths) > 0 {
+		b.SetLualibPaths(paths)
+	}
+}
+
+// applyAddonLibs sets the directories searched for the files of a .toc
+// entry: those given with --addon-libs, or else the config's
+func applyAddonLibs(b *bundler.Bundler, cfg *config.Config, paths []string) {
+	if len(paths) == 0 {
+		paths = configPaths(cfg, cfg.AddonLibs)
+	}
+	b.SetAddonLibPaths(paths)
+}
+
+// configPaths resolves config paths relative to the config file
+func configPaths(cfg *config.Config, paths []string) []string {
+	var resolved []string
+	for _, path := range paths {
+		if !filepath.IsAbs(path) && cfg.Path() != "" {
+			path = filepath.Join(filepath.Dir(cfg.Path()), path)
+		}
+		resolved = append(resolved, path)
+	}
+	return resolved
+}
+
+// applyFeatures enables the selected config features, or all of them when
+// --features was not given
+func applyFeatures(b *bundler.Bundler, features map[string][]string, selected []string, given bool) error {
+	if !given {
+		if len(features) == 0 {
+			return nil
+		}
+		selected = bundler.FeatureNames(features)
+	}
+	return b.SetFeatures(features, selected)
+}
+
+// applyDefines sets the config defines overridden by KEY=VALUE --define flags
+func applyDefines(b *bundler.Bundler, configDefines map[string]string, flags []string) error {
+	defines := make(map[string]string, len(configDefines)+len(flags))
+	for name, value := range configDefines {
+		defines[name] = value
+	}
+	for _, flag := range flags {
+		name, value, ok := strings.Cut(flag, "=")
+		if !ok {
+			return fmt.Errorf("invalid --define %q: expected KEY=VALUE", flag)
+		}
+		defines[strings.TrimSpace(name)] = value
+	}
+	return b.SetDefines(defines)
+}
+
+// loadBanner reads the --banner-file and --footer-file contents into the bundler
+func loadBanner(b *bundler.Bundler, bannerFile, footerFile string) error {
+	var banner, footer []byte
+	var err error
+	if bannerFile != "" {
+		if banner, err = os.ReadFile(bannerFile); err != nil {
+			return fmt.Errorf("failed to read banner file: %w", err)
+		}
+	}
+	if footerFile != "" {
+		if footer, err = os.ReadFile(footerFile); err != nil {
+			return fmt.Errorf("failed to read footer file: %w", err)
+		}
+	}
+	b.SetBanner(string(banner), string(footer))
+	return nil
+}
+
+// writePlugin writes the bundle wrapped as a Studio plugin model to path,
+// naming the plugin after the file
+func writePlugin(b *bundler.Bundler, bundle, path string, cfg config.Plugin) error {
+	model, err := b.PluginModel(bundle, bundler.PluginInfo{
+		Name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Toolbar: cfg.Toolbar,
+		Button:  cfg.Button,
+		Tooltip: cfg.Tooltip,
+		Icon:    cfg.Icon,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(model), 0644); err != nil {
+		return fmt.Errorf("failed to write plugin: %w", err)
+	}
+	return nil
+}
+
+// outputFormat validates --format, inferring it from the output file extension when unset
+func outputFormat(format, outputFile string) (string, error) {
+	if format == "" {
+		if strings.EqualFold(filepath.Ext(outputFile), ".rbxmx") {
+			return bundler.FormatRbxmx, nil
+		}
+		if strings.EqualFold(filepath.Ext(outputFile), ".love") {
+			return bundler.FormatLove, nil
+		}
+		return bundler.FormatLua, nil
+	}
+	switch format {
+	case bundler.FormatLua, bundler.FormatRbxmx, bundler.FormatLove, bundler.FormatAddon, bundler.FormatResource:
+		return format, nil
+	case "rbxm":
+		return "", fmt.Errorf("binary rbxm output is not supported; use --format rbxmx (Studio and Rojo load both)")
+	}
+	return "", fmt.Errorf("unknown output format %q (supported: %s, %s, %s, %s, %s)", format, bundler.FormatLua, bundler.FormatRbxmx, bundler.FormatLove, bundler.FormatAddon, bundler.FormatResource)
+}
+
+// addHTTPFlags registers the flags controlling remote downloads
+func addHTTPFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("http-timeout", 30*time.Second, "Timeout for each remote download")
+	cmd.Flags().String("proxy", "", "Proxy URL for remote downloads: http://, https://, socks5:// or socks5h:// (default: HTTP_PROXY/HTTPS_PROXY from env)")
+	cmd.Flags().StringSlice("proxy-bypass", nil, "Hosts to fetch without the proxy (host, .domain, *.domain, IP or CIDR)")
+	cmd.Flags().Int("max-redirects", 10, "Maximum redirects followed per remote download")
+	cmd.Flags().Bool("no-redirects", false, "Fail remote downloads that redirect instead of following them")
+	cmd.Flags().String("remote-cache", "", "Shared download cache: http(s)://host/path or s3://bucket/prefix (default: $LUA_BUNDLER_REMOTE_CACHE)")
+	cmd.Flags().String("remote-cache-mode", cache.ModeReadWrite, "Remote cache mode: read (read-through), write (write-through) or readwrite")
+}
+
+// httpOptionsFromFlags builds download options from the flags registered by addHTTPFlags
+func httpOptionsFromFlags(cmd *cobra.Command) bundler.HTTPOptions {
+	timeout, _ := cmd.Flags().GetDuration("http-timeout")
+	proxy, _ := cmd.Flags().GetString("proxy")
+	proxyBypass, _ := cmd.Flags().GetStringSlice("proxy-bypass")
+	maxRedirects, _ := cmd.Flags().GetInt("max-redirects")
+	noRedirects, _ := cmd.Flags().GetBool("no-redirects")
+	remoteCache, _ := cmd.Flags().GetString("remote-cache")
+	remoteCacheMode, _ := cmd.Flags().GetString("remote-cache-mode")
+	if remoteCache == "" {
+		remoteCache = os.Getenv("LUA_BUNDLER_REMOTE_CACHE")
+	}
+
+	return bundler.HTTPOptions{
+		Timeout:         timeout,
+		Proxy:           proxy,
+		ProxyBypass:     proxyBypass,
+		MaxRedirects:    maxRedirects,
+		NoRedirects:     noRedirects,
+		RemoteCache:     remoteCache,
+		RemoteCacheMode: remoteCacheMode,
+	}
+}
+
+// redactProxy hides the password of a proxy URL for display
+func redactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return proxy
+	}
+	return u.Redacted()
+}
+
+// checkoutGit shallow-clones the repo@ref spec into a temporary directory
+// for bundling entryFile from it, returning the directory
+func checkoutGit(spec, entryFile string) (string, error) {
+	if bundler.IsURL(entryFile) || filepath.IsAbs(entryFile) {
+		return "", fmt.Errorf("with --git, the entry must be a path inside the repository, got %s", entryFile)
+	}
+	repo, ref := git.ParseSpec(spec)
+	dir, err := os.MkdirTemp("", "lua-bundler-git-*")
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Println(infoStyle.Render(fmt.Sprintf("📥 Fetching %s...", spec)))
+	if err := git.Clone(context.Background(), repo, ref, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// loadConfig loads the config from an explicit path, or lua-bundler.json next to the entry file
+func loadConfig(configPath, entryFile string) (*config.Config, error) {
+	if configPath != "" {
+		return config.Load(configPath)
+	}
+	return config.LoadFromDir(projectDir(entryFile))
+}
+
+// syncDir returns the directory relative sync paths start at: the
+// config file's, or the project's when there is none
+func syncDir(cfg *config.Config, entryFile string) string {
+	if cfg.Path() != "" {
+		return filepath.Dir(cfg.Path())
+	}
+	return projectDir(entryFile)
+}
+
+// projectDir returns the directory holding project files (config, lockfile) for an entry.
+// Remote entries use the current directory.
+func projectDir(entryFile string) string {
+	if bundler.IsURL(entryFile) {
+		return "."
+	}
+	return filepath.Dir(entryFile)
+}
+
+// loadLockfile loads the lockfile from an explicit path, or lua-bundler.lock next to
+// the entry file if it exists. Returns nil when no lockfile is in use.
+func loadLockfile(lockPath, entryFile string) (*lockfile.Lockfile, error) {
+	if lockPath == "" {
+		candidate := filepath.Join(projectDir(entryFile), lockfile.FileName)
+		if _, err := os.Stat(candidate); err != nil {
+			return nil, nil
+		}
+		lockPath = candidate
+	}
+	return lockfile.Load(lockPath)
+}
+
 // SetVersionInfo sets the version information from build-time variables
 func SetVersionInfo(v, date, commit string) {
 	version = v
@@ -184,12 +1411,103 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file")
+	rootCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	rootCmd.Flags().Bool("git-info", false, "Record the git commit, tag and dirty state in the bundle header and define GIT_COMMIT, GIT_TAG and GIT_DIRTY")
+	rootCmd.Flags().String("git", "", "Bundle from a git repository at a branch, tag or commit (repo@ref) without a checkout; --entry is relative to the repository")
+	rootCmd.Flags().Bool("trust-config", false, "Run the sync actions and use the executors of the --git checkout's config, which are ignored otherwise")
 	rootCmd.Flags().StringP("output", "o", "bundle.lua", "Output bundled file")
 	rootCmd.Flags().BoolP("release", "r", false, "Release mode: remove print and warn statements")
-	rootCmd.Flags().IntP("obfuscate", "O", 0, "Obfuscation level (0=none, 1=basic, 2=medium, 3=heavy)")
+	rootCmd.Flags().StringP("obfuscate", "O", "", "Obfuscation preset: none, light, medium, heavy, max, or level 0-3 (default: config obfuscation, then none)")
+	rootCmd.Flags().StringSlice("virtualize", nil, "Compile local modules matching a pattern (module key, path glob or dir/) to bytecode run by a generated interpreter; much larger and slower")
 	rootCmd.Flags().BoolP("verbose", "v", false, "Enable verbose output")
+	rootCmd.Flags().Bool("strict", false, "Fail the build on requires that are neither embedded, stubbed nor explicitly external, such as names left to the runtime by the Roblox service heuristic and requires with computed arguments")
+	rootCmd.Flags().Bool("safe-wrap", false, "Run the bundle in pcall and report errors with the build id (a Roblox notification and console warning by default) instead of failing silently")
+	rootCmd.Flags().BoolP("interactive", "i", false, "Ask how each ambiguous require resolves and record the answers in lua-bundler.json, instead of failing with a list of them")
 	rootCmd.Flags().BoolP("serve", "s", false, "Start HTTP server to serve the output file")
 	rootCmd.Flags().IntP("port", "p", 8080, "Port for HTTP server (used with --serve)")
+	rootCmd.Flags().BoolP("watch", "w", false, "Rebuild the bundle when the project's files change, keeping the last good bundle when a build fails; with --serve, also serves /status")
+	rootCmd.Flags().Duration("watch-interval", 500*time.Millisecond, "How often --watch checks the project's files")
+	rootCmd.Flags().Bool("notify", false, "Show a desktop notification with the result of every --watch rebuild")
+	rootCmd.Flags().Bool("error-snippet", false, "While the last --watch build failed, serve a script printing its error in-game instead of the bundle (used with --serve)")
+	rootCmd.Flags().String("bind", httpserver.DefaultBind, "Address the HTTP server listens on; 0.0.0.0 serves every network interface (used with --serve)")
+	rootCmd.Flags().String("tunnel", "", "Give the --serve server a public HTTPS URL through a tunnel ("+strings.Join(httpserver.TunnelProviders, " or ")+") and print its loader")
+	rootCmd.Flags().String("socket", "", "UNIX socket the HTTP server listens on instead of --bind and --port, for a reverse proxy in front of it (used with --serve)")
+	rootCmd.Flags().Bool("build-api", false, "Enable POST /build on the --serve server for clients sending --build-token")
+	rootCmd.Flags().String("build-token", "", "Bearer token clients must send to --build-api (default: $LUA_BUNDLER_BUILD_TOKEN)")
+	rootCmd.Flags().Bool("dashboard", false, "Serve a dashboard with the bundle's hash, size and recent requests, and a rebuild button, at /dashboard/ (used with --serve)")
+	rootCmd.Flags().String("dashboard-token", "", "Password of the --dashboard page, sent with HTTP basic auth under any user name (default: $LUA_BUNDLER_DASHBOARD_TOKEN)")
+	rootCmd.Flags().Bool("serve-maps", false, "Also serve the bundle's source map (<name>.map.json) and manifest.json to clients sending --maps-token (used with --serve)")
+	rootCmd.Flags().String("maps-token", "", "Bearer token clients must send to fetch --serve-maps files (default: $LUA_BUNDLER_MAPS_TOKEN)")
+	// Set by --serve-maps servers on the builds rebuilding their bundle
+	rootCmd.Flags().String("debug-files", "", "Directory to write the bundle's source map and manifest to")
+	rootCmd.Flags().String("debug-name", "", "Name of the bundle the --debug-files describe (default: the output's)")
+	rootCmd.Flags().MarkHidden("debug-files")
+	rootCmd.Flags().MarkHidden("debug-name")
+	rootCmd.Flags().Bool("serve-variants", false, "Build variants of the served bundle on demand from query parameters (release, obfuscate, minify, target, features, define) for clients sending --variants-token (used with --serve)")
+	rootCmd.Flags().String("variants-token", "", "Bearer token clients must send for --serve-variants builds (default: $LUA_BUNDLER_VARIANTS_TOKEN)")
+	rootCmd.Flags().Bool("signed-urls", false, "Only serve files to URLs signed with the signing secret until they expire; make them with lua-bundler sign (used with --serve)")
+	rootCmd.Flags().String("signing-secret", "", "Secret --signed-urls links are signed with (default: $"+signingSecretEnv+")")
+	rootCmd.Flags().StringSlice("allow-ip", nil, "Only let these IPs and CIDRs use the --serve server")
+	rootCmd.Flags().StringSlice("deny-ip", nil, "Refuse these IPs and CIDRs on the --serve server")
+	rootCmd.Flags().StringSlice("trusted-proxy", nil, "IPs and CIDRs of reverse proxies whose X-Forwarded-For header gives the client address")
+	rootCmd.Flags().StringSlice("geoip-db", nil, "MaxMind (.mmdb) country or ASN databases --allow-country, --deny-country and --deny-asn look addresses up in")
+	rootCmd.Flags().StringSlice("allow-country", nil, "Only let these ISO country codes use the --serve server (needs --geoip-db)")
+	rootCmd.Flags().StringSlice("deny-country", nil, "Refuse these ISO country codes on the --serve server (needs --geoip-db)")
+	rootCmd.Flags().UintSlice("deny-asn", nil, "Refuse these autonomous system numbers, such as hosting providers', on the --serve server (needs --geoip-db)")
+	rootCmd.Flags().Int64("build-max-size", httpserver.DefaultBuildMaxSize, "Largest project POST /build accepts, in bytes, compressed and unpacked")
+	rootCmd.Flags().Int("build-workers", 0, "Builds POST /build runs at once; later requests wait for a free worker (0 = one per CPU)")
+	rootCmd.Flags().String("hosted-url", "", "URL the bundle will be hosted at; prints its loadstring one-liner (--serve uses the local server URL)")
+	rootCmd.Flags().String("shorten", "", "URL shortener API with a {url} placeholder for a short loader link (default: shortener from config)")
+	rootCmd.Flags().Bool("copy", false, "Copy the loadstring one-liner to the clipboard (OSC 52)")
 	rootCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache for remote scripts")
+	rootCmd.Flags().StringSlice("inject", nil, "Write the bundle to the workspace folders of these executors from the config's \"executors\"")
+	rootCmd.Flags().Bool("autoexec", false, "Also write the bundle to the --inject executors' autoexec folders")
+	rootCmd.Flags().Bool("execute", false, "Also run the bundle through the --inject executors' execute APIs")
+	rootCmd.Flags().Bool("no-sync", false, "Skip the sync actions of the config after the build")
+	rootCmd.Flags().Bool("offline", false, "Take remote scripts from the cache only, expired or not, and never download (fill it with lua-bundler prefetch)")
+	rootCmd.Flags().StringP("target", "t", "", "Runtime target ("+strings.Join(bundler.Targets, ", ")+"), default roblox")
+	addHTTPFlags(rootCmd)
+	rootCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	rootCmd.Flags().Int("flatten-depth", -1, "Levels of nested remote loadstring chains to embed; deeper loaders stay runtime fetches (-1 = unlimited)")
+	rootCmd.Flags().Bool("graph", false, "Print the dependency graph after bundling")
+	rootCmd.Flags().String("require-report", "", "After bundling, list every require as bundled (with its source), duplicate, external (with why) or dynamic; bare flag = print a table, =path = write JSON")
+	rootCmd.Flags().Lookup("require-report").NoOptDefVal = "table"
+	rootCmd.Flags().String("preview", "", "Show the bundle in a pager ($LUA_BUNDLER_PAGER, $PAGER or less) instead of writing any file; =module shows that module as embedded, after require rewriting and obfuscation")
+	rootCmd.Flags().Lookup("preview").NoOptDefVal = previewBundle
+	rootCmd.Flags().String("plugin", "", "Also write the bundle as a Studio plugin .rbxmx with a toolbar button running it (roblox target)")
+	rootCmd.Flags().String("format", "", "Output format: lua, rbxmx for a Roblox model of ModuleScripts, love for a zipped LÖVE game, addon for a rebuilt WoW addon folder, or resource for a rebuilt FiveM resource folder (default: from the output extension; addon or resource for .toc and fxmanifest.lua entries written without one)")
+	rootCmd.Flags().Int("minify", -1, "Minification level: 0=none, 1=comments and whitespace, 2=+local names, 3=+semicolons and numbers (-1 = 1 with --release, else 0)")
+	rootCmd.Flags().Bool("minify-preserve-lines", false, "Keep every statement on its original line when minifying or stripping debug statements, so runtime error lines still match")
+	rootCmd.Flags().String("loader", "", "How modules are embedded: closure (a function per module), inline (bodies run up front in dependency order, no loader) or lazy (source strings compiled on first require) (default: config loader, then closure)")
+	rootCmd.Flags().Int("inline-small", -1, fmt.Sprintf("Inline modules of fewer than N tokens that return a constant at their require sites, skipping the loader (bare flag = %d, 0 = off; default: config inlineSmall)", bundler.DefaultInlineSmall))
+	rootCmd.Flags().Lookup("inline-small").NoOptDefVal = fmt.Sprint(bundler.DefaultInlineSmall)
+	rootCmd.Flags().String("source-encoding", "", "How sources that are not valid UTF-8 are read: auto (as Windows-1252, with a warning), utf-8 (fail) or bytes (as they are) (default: config sourceEncoding, then auto)")
+	rootCmd.Flags().String("line-endings", "", "Line endings of the bundle: lf or crlf (default: config lineEndings, then lf)")
+	rootCmd.Flags().Int("bundle-format", bundler.BundleFormat, "Layout of the bundle, for tools that parse it: 2 (format line in the header, modules sorted by key) or 1 (the first release's header and loader)")
+	rootCmd.Flags().String("resolution", "", "What a require of a.b.c names: roblox-dots (the file a/b/c.lua; Roblox services stay external), lua-package (a/b/c.lua or a/b/c/init.lua as package.path finds them; packages the project lacks stay external) or filesystem-only (the file a.b.c.lua next to the requiring file) (default: config resolution, then roblox-dots)")
+	rootCmd.Flags().Bool("request-shim", false, "Route syn.request, http.request, http_request and request calls through one injected cross-executor request function")
+	rootCmd.Flags().Int64("large-module-size", bundler.DefaultLargeModuleSize, "Size in bytes from which local modules, such as generated data tables, are embedded without obfuscation (0 = no limit)")
+	rootCmd.Flags().Bool("profile", false, "Report the bytes each local module was read as, embedded as and allocated while reading and obfuscating it")
+	rootCmd.Flags().String("size-limit", "", "Largest bundle allowed, in bytes, none or a preset ("+strings.Join(bundler.SizeLimitNames(), ", ")+"); computercraft and opencomputers default to cc-computer and oc-hdd1")
+	rootCmd.Flags().String("state-key", "", "Keep the keys the bundle assigns in getgenv(), shared and _G in one table under this key, so bundles using the same names do not collide")
+	rootCmd.Flags().StringArray("no-memoize", nil, "Run this module again on every require instead of returning its cached result (repeatable)")
+	rootCmd.Flags().StringArray("stub", nil, "Embed a file in place of a module as MODULE=PATH, e.g. analytics=stubs/analytics.lua (repeatable)")
+	rootCmd.Flags().StringArray("omit", nil, "Replace a module with an empty table (repeatable)")
+	rootCmd.Flags().StringSlice("features", nil, "Config features to bundle, comma-separated; modules of the others are omitted (default: all, --features= for none)")
+	rootCmd.Flags().StringArray("addon-libs", nil, "Directory searched for the files a .toc entry lists that the addon folder lacks, such as an Ace3 checkout (repeatable)")
+	rootCmd.Flags().String("side", bundler.SideClient, "Side of a FiveM resource a single-file bundle of fxmanifest.lua holds: client or server")
+	rootCmd.Flags().StringArray("lualib", nil, "Directory the openresty target reads resty.* modules from when the project lacks them (repeatable; default: /usr/local/openresty/site/lualib, /usr/local/openresty/lualib)")
+	rootCmd.Flags().StringArray("locale", nil, "Embed a JSON translation file named after its locale, e.g. locales/de.json (repeatable; files of one locale are merged)")
+	rootCmd.Flags().String("default-locale", "", "Locale selected when the script starts (default: config locale, then the first one)")
+	rootCmd.Flags().String("banner-file", "", "File whose contents are prepended to the bundle verbatim, exempt from minification and obfuscation (license header, loader guard)")
+	rootCmd.Flags().String("footer-file", "", "File whose contents are appended to the bundle verbatim, exempt from minification and obfuscation")
+	rootCmd.Flags().String("log-shim", "", "In release mode, route print/warn through an embedded logger with this default level (info, warn or off; bare flag = off) instead of removing them")
+	rootCmd.Flags().Lookup("log-shim").NoOptDefVal = "off"
+	rootCmd.Flags().String("debug-calls", "", "In release mode, what to do with function calls in the arguments of removed print/warn statements: keep (print(save()) becomes save()) or strip (default: keep)")
+	rootCmd.Flags().StringArray("keep-pattern", nil, "Keep print/warn statements with a string argument matching this regular expression in release mode (repeatable)")
+	rootCmd.Flags().StringSlice("no-warn", nil, "Suppress a warning rule: "+strings.Join(bundler.WarningRules, ", ")+" (repeatable or comma-separated)")
+	rootCmd.Flags().StringArrayP("define", "D", nil, "Declare a constant at the top of the bundle as KEY=VALUE (repeatable; true/false/nil/numbers stay literal, anything else is a string)")
+	rootCmd.Flags().String("namespace", "", "Prefix module keys and loader names so bundles can be concatenated or loaded side by side")
+	rootCmd.Flags().Bool("append-licenses", false, "Append the license notices of all bundled modules as a comment block")
+	rootCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
 }
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [entry...] [-- build flags]",
+	Short: "Compare bundles with stored golden copies to catch unexpected output changes",
+	Long: `Build each entry file (main.lua by default) and compare the bundle with its
+snapshot, <dir>/<entry>.snap, failing when they differ. Commit the snapshots
+and run this in CI to catch bundler upgrades or refactors that change the
+output without meaning to.
+
+Bundles are normalized before they are compared, so what changes from build
+to build is not a difference: the version and git revision in the header,
+the VERSION, BUILD_ID and GIT_ defines, and line endings. Snapshot builds are
+not obfuscated, since obfuscation renames at random; a bundle that still
+differs when built again is reported as nondeterministic rather than changed.
+
+Flags after -- are passed to every build, as to lua-bundler itself. Run with
+--update after a change to the output that is meant, and commit the new
+snapshots with it. Missing snapshots are written, unless --ci is given.`,
+	Example: `  lua-bundler snapshot
+  lua-bundler snapshot main.lua tools/admin.lua -- --release --minify 2
+  lua-bundler snapshot --update`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("dir")
+		update, _ := cmd.Flags().GetBool("update")
+		ci, _ := cmd.Flags().GetBool("ci")
+
+		entries, buildArgs := args, []string(nil)
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			entries, buildArgs = args[:dash], args[dash:]
+		}
+		if len(entries) == 0 {
+			entries = []string{"main.lua"}
+		}
+		if update && ci {
+			fmt.Println(errorStyle.Render("❌ --update cannot be used with --ci"))
+			os.Exit(1)
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		build := func(entry string) (string, error) {
+			return snapshotBuild(exe, entry, buildArgs)
+		}
+
+		failed := 0
+		for _, entry := range entries {
+			result := checkSnapshot(entry, snapshotPath(dir, entry), build, update, ci)
+			printSnapshot(result)
+			if result.Failed() {
+				failed++
+			}
+		}
+		fmt.Println()
+		if failed > 0 {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %d of %d snapshot(s) failed; run with --update if the changes are meant", failed, len(entries))))
+			os.Exit(1)
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("✅ %d snapshot(s) passed", len(entries))))
+	},
+}
+
+// Snapshot states
+const (
+	snapshotMatched  = "matched"
+	snapshotWritten  = "written"
+	snapshotUpdated  = "updated"
+	snapshotMissing  = "missing"
+	snapshotChanged  = "changed"
+	snapshotUnstable = "nondeterministic"
+	snapshotError    = "error"
+)
+
+// snapshotResult is how the bundle of one entry compared with its snapshot
+type snapshotResult struct {
+	Entry  string
+	Path   string // the snapshot file
+	State  string
+	Diff   string // the first differing lines, when changed
+	Detail string // the build output or error, when it failed
+}
+
+// Failed reports whether the snapshot check fails
+func (r snapshotResult) Failed() bool {
+	switch r.State {
+	case snapshotMatched, snapshotWritten, snapshotUpdated:
+		return false
+	}
+	return true
+}
+
+// checkSnapshot builds entry and compares the normalized bundle with the
+// snapshot at path, writing it when update is set or, unless ci is, when
+// there is none yet. A bundle differing from the snapshot is built once
+// more, so output that changes from build to build is told apart from a
+// change.
+func checkSnapshot(entry, path string, build func(entry string) (string, error), update, ci bool) snapshotResult {
+	result := snapshotResult{Entry: entry, Path: path}
+	bundle, err := build(entry)
+	if err != nil {
+		result.State, result.Detail = snapshotError, err.Error()
+		return result
+	}
+	bundle = normalizeBundle(bundle)
+
+	stored, err := os.ReadFile(path)
+	switch {
+	case err == nil && string(stored) == bundle:
+		result.State = snapshotMatched
+		return result
+	case err != nil && !os.IsNotExist(err):
+		result.State, result.Detail = snapshotError, err.Error()
+		return result
+	case err != nil && ci:
+		result.State = snapshotMissing
+		return result
+	case err != nil:
+		result.State = snapshotWritten
+	case update:
+		result.State = snapshotUpdated
+	default:
+		again, err := build(entry)
+		if err != nil {
+			result.State, result.Detail = snapshotError, err.Error()
+			return result
+		}
+		if normalizeBundle(again) != bundle {
+			result.State = snapshotUnstable
+			result.Diff = snapshotDiff(path, bundle, normalizeBundle(again))
+			return result
+		}
+		result.State = snapshotChanged
+		result.Diff = snapshotDiff(path, string(stored), bundle)
+		return result
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		result.State, result.Detail = snapshotError, err.Error()
+		return result
+	}
+	if err := os.WriteFile(path, []byte(bundle), 0644); err != nil {
+		result.State, result.Detail = snapshotError, err.Error()
+	}
+	return result
+}
+
+// snapshotBuild bundles entry with a child process of exe, passing args on,
+// and returns the bundle
+func snapshotBuild(exe, entry string, args []string) (string, error) {
+	tmp, err := os.MkdirTemp("", "lua-bundler-snapshot-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	output := filepath.Join(tmp, "bundle.lua")
+	// Later flags win, so args may turn obfuscation back on
+	cmdArgs := append([]string{"--entry=" + entry, "--output=" + output, "--obfuscate=none", "--no-sync"}, args...)
+	out, err := exec.Command(exe, cmdArgs...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("build failed:\n%s", strings.TrimSpace(buildOutput(string(out))))
+	}
+	bundle, err := os.ReadFile(output)
+	if err != nil {
+		return "", err
+	}
+	return string(bundle), nil
+}
+
+// snapshotPath returns the snapshot file of entry in dir. Entries outside
+// the working directory are named by their file name alone.
+func snapshotPath(dir, entry string) string {
+	name := filepath.Clean(entry)
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		name = filepath.Base(name)
+	}
+	return filepath.Join(dir, name+".snap")
+}
+
+// volatileLines match the bundle lines that change between builds of the
+// same sources, with the part to keep
+var volatileLines = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?m)^-- Version: .*$`), "-- Version: <version>"},
+	{regexp.MustCompile(`(?m)^-- Git: .*$`), "-- Git: <revision>"},
+	{regexp.MustCompile(`(?m)^local (VERSION|BUILD_ID|GIT_COMMIT|GIT_TAG|GIT_DIRTY) = .*$`), "local $1 = <$1>"},
+}
+
+// normalizeBundle replaces what changes between builds of the same
+// sources in bundle, and its line endings
+func normalizeBundle(bundle string) string {
+	bundle = strings.ReplaceAll(bundle, "\r\n", "\n")
+	for _, v := range volatileLines {
+		bundle = v.pattern.ReplaceAllString(bundle, v.replacement)
+	}
+	return bundle
+}
+
+// snapshotDiffLines caps the lines shown from each side of a difference
+const snapshotDiffLines = 12
+
+// snapshotDiff renders the lines between the common start and end of old
+// and new, unified-style, showing at most snapshotDiffLines of each
+func snapshotDiff(name, old, new string) string {
+	oldLines := strings.Split(old, "\n")
+	newLines := strings.Split(new, "\n")
+	start := 0
+	for start < len(oldLines) && start < len(newLines) && oldLines[start] == newLines[start] {
+		start++
+	}
+	oldEnd, newEnd := len(oldLines), len(newLines)
+	for oldEnd > start && newEnd > start && oldLines[oldEnd-1] == newLines[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ build\n@@ -%d,%d +%d,%d @@\n", name, start+1, oldEnd-start, start+1, newEnd-start)
+	for _, side := range []struct {
+		prefix string
+		lines  []string
+	}{{"-", oldLines[start:oldEnd]}, {"+", newLines[start:newEnd]}} {
+		for i, line := range side.lines {
+			if i == snapshotDiffLines {
+				fmt.Fprintf(&out, "%s... %d more line(s)\n", side.prefix, len(side.lines)-i)
+				break
+			}
+			fmt.Fprintf(&out, "%s%s\n", side.prefix, line)
+		}
+	}
+	return out.String()
+}
+
+// printSnapshot prints the outcome of one snapshot check
+func printSnapshot(r snapshotResult) {
+	switch r.State {
+	case snapshotMatched:
+		fmt.Printf("  ✓ %s\n", r.Entry)
+	case snapshotWritten:
+		fmt.Println(infoStyle.Render(fmt.Sprintf("  + %s: wrote %s", r.Entry, r.Path)))
+	case snapshotUpdated:
+		fmt.Println(warningStyle.Render(fmt.Sprintf("  ↻ %s: updated %s", r.Entry, r.Path)))
+	case snapshotMissing:
+		fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ %s: no snapshot at %s", r.Entry, r.Path)))
+	case snapshotChanged:
+		fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ %s: bundle differs from %s", r.Entry, r.Path)))
+		printDiff(r.Diff)
+	case snapshotUnstable:
+		fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ %s: bundle differs between two builds; is it obfuscated?", r.Entry)))
+		printDiff(r.Diff)
+	default:
+		// Styled text is padded to its widest line, so the build output
+		// goes below
+		summary, output, _ := strings.Cut(r.Detail, "\n")
+		fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ %s: %s", r.Entry, summary)))
+		if output != "" {
+			printDiff(output)
+		}
+	}
+}
+
+// printDiff prints a snapshot diff or build output indented under its entry
+func printDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		fmt.Printf("      %s\n", line)
+	}
+}
+
+func init() {
+	snapshotCmd.Flags().String("dir", "snapshots", "Directory holding the snapshots")
+	snapshotCmd.Flags().BoolP("update", "u", false, "Write the bundles as the new snapshots instead of comparing")
+	snapshotCmd.Flags().Bool("ci", false, "Fail on entries without a snapshot instead of writing one")
+
+	rootCmd.AddCommand(snapshotCmd)
+}
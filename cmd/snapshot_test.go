@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeBundle(t *testing.T) {
+	bundle := "-- Bundled Lua Script\r\n" +
+		"-- Version: 1.4.0 (build 27c7d3e8ec83)\r\n" +
+		"-- Git: 3f2a9c1d (v1.4.0, dirty)\r\n" +
+		"local BUILD_ID = \"27c7d3e8ec83\"\n" +
+		"local DEBUG = false\n" +
+		"local GIT_COMMIT = \"3f2a9c1d\"\n" +
+		"local GIT_TAG = nil\n" +
+		"local VERSION = \"1.4.0\"\n" +
+		"print(VERSION)\n"
+
+	assert.Equal(t, "-- Bundled Lua Script\n"+
+		"-- Version: <version>\n"+
+		"-- Git: <revision>\n"+
+		"local BUILD_ID = <BUILD_ID>\n"+
+		"local DEBUG = false\n"+
+		"local GIT_COMMIT = <GIT_COMMIT>\n"+
+		"local GIT_TAG = <GIT_TAG>\n"+
+		"local VERSION = <VERSION>\n"+
+		"print(VERSION)\n", normalizeBundle(bundle))
+}
+
+func TestSnapshotPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("snapshots", "main.lua.snap"), snapshotPath("snapshots", "main.lua"))
+	assert.Equal(t, filepath.Join("snapshots", "tools", "admin.lua.snap"), snapshotPath("snapshots", "./tools/admin.lua"))
+	assert.Equal(t, filepath.Join("snapshots", "shared.lua.snap"), snapshotPath("snapshots", "../lib/shared.lua"), "entries outside the project keep their name only")
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	diff := snapshotDiff("main.lua.snap", "a\nb\nc\nd\n", "a\nB\nx\nd\n")
+	assert.Equal(t, "--- main.lua.snap\n+++ build\n@@ -2,2 +2,2 @@\n-b\n-c\n+B\n+x\n", diff)
+
+	long := ""
+	for i := 0; i < snapshotDiffLines+3; i++ {
+		long += "line\n"
+	}
+	diff = snapshotDiff("main.lua.snap", "a\n", "a\n"+long)
+	assert.Contains(t, diff, "+... 3 more line(s)\n")
+}
+
+func TestCheckSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots", "main.lua.snap")
+	bundle := "-- Version: 1.0.0 (build aaa)\nprint(1)\n"
+	build := func(string) (string, error) { return bundle, nil }
+
+	result := checkSnapshot("main.lua", path, build, false, true)
+	assert.Equal(t, snapshotMissing, result.State, "--ci does not write missing snapshots")
+	assert.True(t, result.Failed())
+	assert.NoFileExists(t, path)
+
+	result = checkSnapshot("main.lua", path, build, false, false)
+	assert.Equal(t, snapshotWritten, result.State)
+	assert.False(t, result.Failed())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "-- Version: <version>\nprint(1)\n", string(data))
+
+	bundle = "-- Version: 1.1.0 (build bbb)\nprint(1)\n"
+	result = checkSnapshot("main.lua", path, build, false, true)
+	assert.Equal(t, snapshotMatched, result.State, "the version is normalized")
+
+	bundle = "-- Version: 1.1.0 (build bbb)\nprint(2)\n"
+	result = checkSnapshot("main.lua", path, build, false, false)
+	assert.Equal(t, snapshotChanged, result.State)
+	assert.True(t, result.Failed())
+	assert.Contains(t, result.Diff, "-print(1)\n+print(2)\n")
+
+	result = checkSnapshot("main.lua", path, build, true, false)
+	assert.Equal(t, snapshotUpdated, result.State)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "-- Version: <version>\nprint(2)\n", string(data))
+}
+
+func TestCheckSnapshot_Nondeterministic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.lua.snap")
+	require.NoError(t, os.WriteFile(path, []byte("local a = 0\n"), 0644))
+	builds := 0
+	build := func(string) (string, error) {
+		builds++
+		return "local a = " + string(rune('0'+builds)) + "\n", nil
+	}
+
+	result := checkSnapshot("main.lua", path, build, false, false)
+	assert.Equal(t, snapshotUnstable, result.State)
+	assert.Equal(t, 2, builds)
+	assert.Contains(t, result.Diff, "-local a = 1\n+local a = 2\n")
+}
+
+func TestCheckSnapshot_BuildError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.lua.snap")
+	build := func(string) (string, error) { return "", errors.New("build failed:\n❌ missing module") }
+
+	result := checkSnapshot("main.lua", path, build, false, false)
+	assert.Equal(t, snapshotError, result.State)
+	assert.Equal(t, "build failed:\n❌ missing module", result.Detail)
+	assert.NoFileExists(t, path)
+}
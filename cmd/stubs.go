@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/stubs"
+	"github.com/spf13/cobra"
+)
+
+var stubsCmd = &cobra.Command{
+	Use:   "stubs",
+	Short: "Generate stub files for the requires the bundle leaves to the runtime",
+	Long: `Resolve the dependency graph and write a stub for every external require
+(Roblox instance paths such as game.ReplicatedStorage.Shared.Util, and other
+requires the bundler does not embed). Each stub declares the members the
+bundled code uses: functions, methods and values.
+
+For the roblox target the stubs are typed Luau, and a Rojo-format
+sourcemap.json maps the instance paths to them, so luau-lsp resolves the
+requires when pointed at it. Other targets get LuaLS definition files.`,
+	Example: `  lua-bundler stubs -e main.lua -d types
+  # then set luau-lsp.sourcemap.sourcemapFile to types/sourcemap.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		dir, _ := cmd.Flags().GetString("dir")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+
+		externals := b.ExternalRequires()
+		if len(externals) == 0 {
+			fmt.Println(successStyle.Render("✅ No external requires; nothing to stub"))
+			return
+		}
+
+		generated := generateStubs(b, externals, target == bundler.TargetRoblox)
+		for _, s := range generated {
+			path := filepath.Join(dir, filepath.FromSlash(s.File))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				os.Exit(1)
+			}
+			if err := os.WriteFile(path, []byte(s.Content), 0644); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write stub: %v", err)))
+				os.Exit(1)
+			}
+			fmt.Printf("%s %s\n", successStyle.Render("✓"), path)
+		}
+
+		sourcemap, err := stubs.Sourcemap(generated, filepath.ToSlash(dir))
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == bundler.TargetRoblox {
+			path := filepath.Join(dir, "sourcemap.json")
+			if err := os.WriteFile(path, append(sourcemap, '\n'), 0644); err != nil {
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to write sourcemap: %v", err)))
+				os.Exit(1)
+			}
+			fmt.Printf("%s %s\n", successStyle.Render("✓"), path)
+		}
+
+		fmt.Println()
+		fmt.Println(successStyle.Render(fmt.Sprintf("✅ Generated %d stubs", len(generated))))
+	},
+}
+
+// generateStubs builds a stub for each external require from the members
+// used by the files requiring it
+func generateStubs(b *bundler.Bundler, externals []bundler.ExternalRequire, luau bool) []stubs.Stub {
+	var generated []stubs.Stub
+	for _, ext := range externals {
+		seen := make(map[string]int)
+		var members []stubs.Member
+		for _, key := range ext.RequiredBy {
+			src, ok := b.GetSource(key)
+			if !ok {
+				continue
+			}
+			used, err := stubs.Members(src, ext.Path)
+			if err != nil {
+				fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %s: %v", key, err)))
+				continue
+			}
+			for _, m := range used {
+				if i, ok := seen[m.Name]; ok {
+					if m.Kind > members[i].Kind {
+						members[i].Kind = m.Kind
+					}
+					continue
+				}
+				seen[m.Name] = len(members)
+				members = append(members, m)
+			}
+		}
+		generated = append(generated, stubs.Generate(ext.Path, ext.RequiredBy, members, luau))
+	}
+	return generated
+}
+
+func init() {
+	stubsCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	stubsCmd.Flags().StringP("dir", "d", "types", "Directory to write the stubs to")
+	stubsCmd.Flags().StringP("target", "t", "", "Runtime target (default: config target, then roblox); roblox writes Luau stubs")
+	stubsCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	stubsCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	stubsCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	addHTTPFlags(stubsCmd)
+
+	rootCmd.AddCommand(stubsCmd)
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStubsCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"stubs"})
+	require.NoError(t, err, "stubs should be registered")
+	assert.Equal(t, stubsCmd, cmd)
+
+	for _, name := range []string{"entry", "dir", "target", "config", "lockfile", "no-cache", "proxy"} {
+		assert.NotNil(t, stubsCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+	assert.Equal(t, "types", stubsCmd.Flags().Lookup("dir").DefValue)
+}
+
+func TestGenerateStubs(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte(`local Util = require(game.ReplicatedStorage.Util)
+Util.format("x")
+require("helper")
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.lua"), []byte(`local Util = require(game.ReplicatedStorage.Util)
+Util:Connect()
+Util.format = nil
+return {}
+`), 0644))
+
+	b, err := bundler.NewBundler(entry, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	generated := generateStubs(b, b.ExternalRequires(), true)
+	require.Len(t, generated, 1)
+	assert.Equal(t, "ReplicatedStorage/Util.luau", generated[0].File)
+	assert.Contains(t, generated[0].Content, "function Util.format(", "a call in one file should win over a plain use in another")
+	assert.Contains(t, generated[0].Content, "function Util:Connect(")
+}
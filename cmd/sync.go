@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/config"
+)
+
+// syncResult is how one sync action went
+type syncResult struct {
+	Action config.SyncAction
+	Detail string // where the bundle went, or the command's output
+	Err    error
+}
+
+// runSync runs the sync actions for the bundle at bundle, in order,
+// stopping at the first one that fails unless it is optional. Relative
+// paths start at dir.
+func runSync(actions []config.SyncAction, bundle, dir string) ([]syncResult, error) {
+	abs, err := filepath.Abs(bundle)
+	if err != nil {
+		return nil, err
+	}
+	var results []syncResult
+	for _, action := range actions {
+		result := syncResult{Action: action}
+		if len(action.Run) > 0 {
+			result.Detail, result.Err = syncRun(action.Run, abs, dir)
+		} else {
+			result.Detail, result.Err = syncCopy(action.CopyTo, abs, dir)
+		}
+		results = append(results, result)
+		if result.Err != nil && !action.Optional {
+			return results, fmt.Errorf("sync %s failed: %w", action.Label(), result.Err)
+		}
+	}
+	return results, nil
+}
+
+// syncCopy copies the bundle into the directory to, or to the file to
+// when it has an extension, returning the file written
+func syncCopy(to, bundle, dir string) (string, error) {
+	dest := expandPath(to, dir)
+	if info, err := os.Stat(dest); (err == nil && info.IsDir()) || filepath.Ext(dest) == "" {
+		dest = filepath.Join(dest, filepath.Base(bundle))
+	}
+	data, err := os.ReadFile(bundle)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	// Executors may load the file at any time, so it is replaced whole
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return dest, nil
+}
+
+// syncRun runs a command with the bundle's placeholders filled in,
+// returning its output
+func syncRun(command []string, bundle, dir string) (string, error) {
+	replacer := strings.NewReplacer("{bundle}", bundle, "{name}", filepath.Base(bundle))
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = replacer.Replace(arg)
+	}
+	c := exec.Command(args[0], args[1:]...)
+	c.Dir = dir
+	out, err := c.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil && output != "" {
+		return output, fmt.Errorf("%w: %s", err, output)
+	}
+	return output, err
+}
+
+// expandPath expands ~ and environment variables in path, relative paths
+// starting at dir
+func expandPath(path, dir string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+// printSync reports the sync actions that ran
+func printSync(results []syncResult) {
+	for _, r := range results {
+		switch {
+		case r.Err != nil && r.Action.Optional:
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  Sync %s failed: %v", r.Action.Label(), r.Err)))
+		case r.Err != nil:
+		case r.Action.CopyTo != "":
+			fmt.Printf("%s %s\n", infoStyle.Render("📤 Synced:"), r.Detail)
+		default:
+			fmt.Printf("%s %s\n", infoStyle.Render("📤 Synced:"), r.Action.Label())
+		}
+	}
+}
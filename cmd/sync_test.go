@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSync_Copy(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "bundle.lua")
+	require.NoError(t, os.WriteFile(bundle, []byte("print(1)"), 0644))
+	t.Setenv("EXECUTOR_DIR", filepath.Join(dir, "executor"))
+
+	results, err := runSync([]config.SyncAction{
+		{CopyTo: "$EXECUTOR_DIR/autoexec"},
+		{CopyTo: "workspace/hub.lua"},
+	}, bundle, dir)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, filepath.Join(dir, "executor", "autoexec", "bundle.lua"), results[0].Detail)
+	assert.Equal(t, filepath.Join(dir, "workspace", "hub.lua"), results[1].Detail, "paths with an extension are files")
+	for _, r := range results {
+		data, err := os.ReadFile(r.Detail)
+		require.NoError(t, err)
+		assert.Equal(t, "print(1)", string(data))
+	}
+}
+
+func TestRunSync_Run(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "bundle.lua")
+	require.NoError(t, os.WriteFile(bundle, []byte("print(1)"), 0644))
+
+	results, err := runSync([]config.SyncAction{
+		{Name: "flaky", Run: []string{"sh", "-c", "echo offline >&2; exit 1"}, Optional: true},
+		{Run: []string{"sh", "-c", `cp "$1" "$2"`, "sh", "{bundle}", "copy-of-{name}"}},
+		{Name: "upload", Run: []string{"sh", "-c", "echo denied; exit 2"}},
+		{CopyTo: "never"},
+	}, bundle, dir)
+	assert.EqualError(t, err, "sync upload failed: exit status 2: denied")
+	require.Len(t, results, 3, "actions stop at the first required failure")
+	assert.EqualError(t, results[0].Err, "exit status 1: offline", "optional failures go on")
+	assert.NoError(t, results[1].Err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "copy-of-bundle.lua"))
+	require.NoError(t, err, "commands run in the config's directory")
+	assert.Equal(t, "print(1)", string(data))
+}
+
+func TestExpandPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "autoexec"), expandPath("~/autoexec", "/project"))
+	assert.Equal(t, filepath.Join("/project", "dist"), expandPath("dist", "/project"))
+	assert.Equal(t, "/abs", expandPath("/abs", "/project"))
+}
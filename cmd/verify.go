@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/luavm"
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/spf13/cobra"
+)
+
+var verifyObfuscationCmd = &cobra.Command{
+	Use:   "verify-obfuscation",
+	Short: "Check that obfuscation keeps every module's behavior on a test script",
+	Long: `Run a test script against the project's modules as written, then once per
+local module with only that module obfuscated, and compare what each run
+prints. A module whose run prints something else, or fails where the
+baseline did not, is broken by the obfuscator.
+
+The test script requires the modules it exercises, as the entry file does,
+and prints what it observes. Runs use the Lua 5.1 VM built into
+lua-bundler, so the modules and the script must be Lua 5.1 without Luau
+syntax, with a deterministic stub environment: clocks stand still, random
+numbers repeat, and missing Roblox globals such as game are stubs.
+
+Exits with status 1 when any module diverges.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		entryFile, _ := cmd.Flags().GetString("entry")
+		testFile, _ := cmd.Flags().GetString("test")
+		obfuscate, _ := cmd.Flags().GetString("obfuscate")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+
+		if testFile == "" {
+			fmt.Println(errorStyle.Render("❌ A test script is required (--test)"))
+			os.Exit(1)
+		}
+		testScript, err := os.ReadFile(testFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to read test script: %v", err)))
+			os.Exit(1)
+		}
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		obfuscation, err := obfuscationPasses(cfg, obfuscate)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if len(obfuscation) == 0 {
+			obfuscation = obfuscator.Presets["medium"]
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render("🔄 Resolving dependency graph..."))
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+		checks, err := b.ObfuscationChecks(obfuscation, string(testScript))
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render(fmt.Sprintf("🧪 Running %s with %d module(s) obfuscated (%s)...", testFile, len(checks)-1, obfuscator.Describe(obfuscation))))
+		results, err := verifyObfuscation(checks, func(program string) (string, error) {
+			return luavm.Run(program, timeout)
+		})
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println()
+		failed := 0
+		for _, r := range results {
+			if r.Problem == "" {
+				fmt.Printf("  ✓ %s\n", r.Module)
+				continue
+			}
+			failed++
+			fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ %s: %s", r.Module, r.Problem)))
+		}
+		fmt.Println()
+		if failed > 0 {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Obfuscation changes the behavior of %d module(s)", failed)))
+			os.Exit(1)
+		}
+		fmt.Println(successStyle.Render("✅ Every module behaves the same obfuscated"))
+	},
+}
+
+// verifyResult is the outcome of the test script with one module obfuscated
+type verifyResult struct {
+	Module  string
+	Problem string // how the run diverged from the baseline, "" when it did not
+}
+
+// verifyObfuscation runs the baseline check, then compares the output and
+// failure of every other check with it. A failing baseline is an error,
+// since nothing can be compared with it.
+func verifyObfuscation(checks []bundler.ObfuscationCheck, run func(program string) (string, error)) ([]verifyResult, error) {
+	baseline, err := run(checks[0].Program)
+	if err != nil {
+		return nil, fmt.Errorf("the test script fails without obfuscation: %w", err)
+	}
+
+	var results []verifyResult
+	for _, check := range checks[1:] {
+		output, err := run(check.Program)
+		problem := bundler.OutputDifference(baseline, output)
+		if err != nil {
+			problem = fmt.Sprintf("fails: %v", err)
+		}
+		results = append(results, verifyResult{Module: check.Module, Problem: problem})
+	}
+	return results, nil
+}
+
+func init() {
+	verifyObfuscationCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	verifyObfuscationCmd.Flags().String("test", "", "Lua test script requiring the modules and printing what it observes")
+	verifyObfuscationCmd.Flags().StringP("obfuscate", "O", "", "Obfuscation preset or level to verify (default: config obfuscation, then medium)")
+	verifyObfuscationCmd.Flags().Duration("timeout", 10*time.Second, "Longest a single run may take")
+	verifyObfuscationCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: config target, then roblox)")
+	verifyObfuscationCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	verifyObfuscationCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	verifyObfuscationCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	addHTTPFlags(verifyObfuscationCmd)
+
+	rootCmd.AddCommand(verifyObfuscationCmd)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/luavm"
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyObfuscationCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"verify-obfuscation"})
+	require.NoError(t, err, "verify-obfuscation should be registered")
+	assert.Equal(t, verifyObfuscationCmd, cmd)
+
+	for _, name := range []string{"entry", "test", "obfuscate", "timeout", "config", "proxy"} {
+		assert.NotNil(t, verifyObfuscationCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
+
+func TestVerifyObfuscation(t *testing.T) {
+	checks := []bundler.ObfuscationCheck{
+		{Program: "baseline"},
+		{Module: "util", Program: "same"},
+		{Module: "ui", Program: "different"},
+		{Module: "net", Program: "broken"},
+	}
+	outputs := map[string]string{"baseline": "1\n2\n", "same": "1\n2\n", "different": "1\n3\n", "broken": "1\n"}
+	run := func(program string) (string, error) {
+		if program == "broken" {
+			return outputs[program], errors.New("attempt to call a nil value")
+		}
+		return outputs[program], nil
+	}
+
+	results, err := verifyObfuscation(checks, run)
+	require.NoError(t, err)
+	assert.Equal(t, []verifyResult{
+		{Module: "util"},
+		{Module: "ui", Problem: `line 2: got "3", want "2"`},
+		{Module: "net", Problem: "fails: attempt to call a nil value"},
+	}, results)
+
+	_, err = verifyObfuscation(checks[3:], run)
+	assert.ErrorContains(t, err, "the test script fails without obfuscation")
+}
+
+func TestVerifyObfuscation_EmbeddedVM(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`local util = require("util")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util.lua"), []byte(`local step = 1
+local greeting = "hello"
+return {
+    next = function(n) return n + step end,
+    greet = function(name) return greeting .. ", " .. name .. " at " .. os.time() end,
+}
+`), 0644))
+	b, err := bundler.NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	test := "local util = require(\"util\")\nprint(util.next(1), util.next(2))\nprint(util.greet(\"dev\"))\n"
+	checks, err := b.ObfuscationChecks(obfuscator.Presets["medium"], test)
+	require.NoError(t, err)
+	baseline, err := luavm.Run(checks[0].Program, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "2\t3\nhello, dev at 0\n", baseline, "the stub environment stops the clock")
+
+	results, err := verifyObfuscation(checks, func(program string) (string, error) {
+		return luavm.Run(program, time.Second)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []verifyResult{{Module: "util"}}, results)
+}
+
+func TestVerifyObfuscation_MaxPreset(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`local util = require("util")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util.lua"), []byte(`local step = 1
+return {
+    next = function(n) return n + step end,
+    shout = function(s) return string.upper(s) .. "!" end,
+}
+`), 0644))
+	b, err := bundler.NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	// The max preset's anti-tamper checks that builtins are native before running
+	checks, err := b.ObfuscationChecks(obfuscator.Presets["max"], "local util = require(\"util\")\nprint(util.next(1), util.shout(\"hi\"))\n")
+	require.NoError(t, err)
+	results, err := verifyObfuscation(checks, func(program string) (string, error) {
+		return luavm.Run(program, time.Second)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []verifyResult{{Module: "util"}}, results)
+}
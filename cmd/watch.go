@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	httpserver "github.com/constt/lua-bundler/internal/http"
+	"github.com/constt/lua-bundler/internal/notify"
+)
+
+// watchedExts are the extensions of project files a change of which
+// rebuilds a --watch bundle
+var watchedExts = map[string]bool{".lua": true, ".luau": true, ".json": true, ".lock": true, ".toc": true}
+
+// fileStamp identifies a version of a file without reading it
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watcher rebuilds a bundle when the files of its project change. Builds
+// write to a temporary file renamed over the bundle when they succeed, so
+// the bundle on disk is always the last good one.
+type watcher struct {
+	dir    string // project directory scanned for changes
+	output string // the bundle
+	build  func(output string) (string, error)
+	status *httpserver.BuildStatus
+	stamps map[string]fileStamp
+	notify func(notify.Notification) error // desktop notifications of build results, nil for none
+
+	afterBuild func() error // run after each successful rebuild, such as the sync actions
+}
+
+// newWatcher returns a watcher of the project of entryFile rebuilding
+// outputFile with build
+func newWatcher(entryFile, outputFile string, build func(output string) (string, error)) *watcher {
+	w := &watcher{
+		dir:    filepath.Dir(entryFile),
+		output: outputFile,
+		build:  build,
+		status: httpserver.NewBuildStatus(),
+	}
+	w.stamps = w.scan()
+	return w
+}
+
+// scan returns the stamps of the project's files, leaving out hidden
+// files and directories and the bundle itself
+func (w *watcher) scan() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	output, _ := filepath.Abs(w.output)
+	filepath.WalkDir(w.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != w.dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !watchedExts[filepath.Ext(path)] {
+			return nil
+		}
+		if abs, _ := filepath.Abs(path); abs == output {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+	return stamps
+}
+
+// changed returns the files added, changed or removed since the last
+// call, and takes their new stamps
+func (w *watcher) changed() []string {
+	stamps := w.scan()
+	var files []string
+	for path, stamp := range stamps {
+		if w.stamps[path] != stamp {
+			files = append(files, path)
+		}
+	}
+	for path := range w.stamps {
+		if _, ok := stamps[path]; !ok {
+			files = append(files, path)
+		}
+	}
+	w.stamps = stamps
+	sort.Strings(files)
+	return files
+}
+
+// rebuild builds the bundle again after changed files changed
+func (w *watcher) rebuild(changed []string) error {
+	w.status.Start(changed)
+	tmp := filepath.Join(filepath.Dir(w.output), "."+filepath.Base(w.output)+".watch")
+	defer os.Remove(tmp)
+
+	out, err := w.build(tmp)
+	if err == nil {
+		err = os.Rename(tmp, w.output)
+	}
+	w.status.Finish(buildOutput(out), err)
+	// Files the build itself wrote do not start another one
+	w.stamps = w.scan()
+	return err
+}
+
+// run rebuilds the bundle whenever the project changes, checking every
+// interval until stop is closed
+func (w *watcher) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			changed := w.changed()
+			if len(changed) == 0 {
+				continue
+			}
+			fmt.Println(infoStyle.Render(fmt.Sprintf("🔄 %s changed, rebuilding...", watchSummary(changed))))
+			if err := w.rebuild(changed); err != nil {
+				report := w.status.Report()
+				fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Build failed: %s", report.Error)))
+				fmt.Println(warningStyle.Render("⚠️  Keeping the last good bundle"))
+				w.announce(notify.Notification{Title: "Build failed", Message: report.Error, Failure: true})
+				continue
+			}
+			report := w.status.Report()
+			fmt.Println(successStyle.Render(fmt.Sprintf("✅ Rebuilt %s in %dms", w.output, report.DurationMs)))
+			if w.afterBuild != nil {
+				if err := w.afterBuild(); err != nil {
+					fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+				}
+			}
+			w.announce(notify.Notification{
+				Title:   "Build succeeded",
+				Message: fmt.Sprintf("%s rebuilt in %dms after %s changed", filepath.Base(w.output), report.DurationMs, watchSummary(changed)),
+			})
+		case <-stop:
+			return
+		}
+	}
+}
+
+// announce sends a desktop notification of a build result, turning
+// notifications off with a warning the first time one cannot be shown
+func (w *watcher) announce(n notify.Notification) {
+	if w.notify == nil {
+		return
+	}
+	if err := w.notify(n); err != nil {
+		fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  Desktop notifications are off: %v", err)))
+		w.notify = nil
+	}
+}
+
+// buildOutput returns what a build printed after its banner and
+// configuration
+func buildOutput(out string) string {
+	if i := strings.Index(out, "Processing dependencies"); i >= 0 {
+		if end := strings.IndexByte(out[i:], '\n'); end >= 0 {
+			return out[i+end+1:]
+		}
+	}
+	return out
+}
+
+// watchSummary names the changed files, or counts them when there are many
+func watchSummary(changed []string) string {
+	if len(changed) > 3 {
+		return fmt.Sprintf("%d files", len(changed))
+	}
+	return strings.Join(changed, ", ")
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	httpserver "github.com/constt/lua-bundler/internal/http"
+	"github.com/constt/lua-bundler/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	output := filepath.Join(dir, "bundle.lua")
+	write := func(name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("main.lua", "print(1)")
+	write("src/mod.lua", "return 1")
+	write(".git/HEAD", "ref: refs/heads/main")
+	write("bundle.lua", "-- good")
+
+	fail := false
+	w := newWatcher(entry, output, func(out string) (string, error) {
+		if fail {
+			return "Processing dependencies...\n❌ Bundling failed: boom\n", errors.New("exit status 1")
+		}
+		// Builds may write next to the bundle; that starts no other build
+		write("report.json", "{}")
+		return "", os.WriteFile(out, []byte("-- rebuilt"), 0644)
+	})
+	assert.Empty(t, w.changed())
+
+	// Stamps compare modification times, which may be coarse
+	time.Sleep(10 * time.Millisecond)
+	write("src/mod.lua", "return 2")
+	write("README.md", "not watched")
+	write("bundle.lua", "-- the bundle is not watched")
+	changed := w.changed()
+	assert.Equal(t, []string{filepath.Join(dir, "src", "mod.lua")}, changed)
+
+	require.NoError(t, w.rebuild(changed))
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "-- rebuilt", string(data))
+	assert.Empty(t, w.changed(), "files the build wrote are taken in")
+	assert.Equal(t, httpserver.StateOK, w.status.Report().State)
+
+	fail = true
+	require.NoError(t, os.Remove(filepath.Join(dir, "src", "mod.lua")))
+	changed = w.changed()
+	assert.Equal(t, []string{filepath.Join(dir, "src", "mod.lua")}, changed, "removed files count")
+	assert.Error(t, w.rebuild(changed))
+	data, _ = os.ReadFile(output)
+	assert.Equal(t, "-- rebuilt", string(data), "a failed build keeps the last good bundle")
+	report := w.status.Report()
+	assert.Equal(t, httpserver.StateError, report.State)
+	assert.Equal(t, "Bundling failed: boom", report.Error)
+	assert.Equal(t, []string{"❌ Bundling failed: boom"}, report.Diagnostics, "the banner is left out")
+	_, err := os.Stat(filepath.Join(dir, ".bundle.lua.watch"))
+	assert.True(t, os.IsNotExist(err), "the temporary bundle is removed")
+}
+
+func TestWatchSummary(t *testing.T) {
+	assert.Equal(t, "a.lua, b.lua", watchSummary([]string{"a.lua", "b.lua"}))
+	assert.Equal(t, "4 files", watchSummary([]string{"a", "b", "c", "d"}))
+}
+
+func TestWatcher_Announce(t *testing.T) {
+	w := &watcher{}
+	w.announce(notify.Notification{Title: "Build failed"}) // no notifications set
+
+	var sent []notify.Notification
+	w.notify = func(n notify.Notification) error {
+		sent = append(sent, n)
+		if len(sent) == 2 {
+			return errors.New("notify-send not found")
+		}
+		return nil
+	}
+	w.announce(notify.Notification{Title: "Build failed", Failure: true})
+	w.announce(notify.Notification{Title: "Build succeeded"})
+	assert.Nil(t, w.notify, "notifications stop after one cannot be shown")
+	w.announce(notify.Notification{Title: "Build succeeded"})
+	assert.Len(t, sent, 2)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/spf13/cobra"
+)
+
+var whyCmd = &cobra.Command{
+	Use:   "why <module>",
+	Short: "Show why a module or URL is included in the bundle",
+	Long: `Print every require/HttpGet chain from the entry file to a module.
+
+<module> is a require path as written in the source (modules.config), a local
+file path (src/modules/config.lua) or a remote URL.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		module := args[0]
+		entryFile, _ := cmd.Flags().GetString("entry")
+		target, _ := cmd.Flags().GetString("target")
+		configPath, _ := cmd.Flags().GetString("config")
+		lockPath, _ := cmd.Flags().GetString("lockfile")
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+		flattenDepth, _ := cmd.Flags().GetInt("flatten-depth")
+
+		cfg, err := loadConfig(configPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		if target == "" {
+			target = cfg.Target
+		}
+		if target == "" {
+			target = bundler.TargetRoblox
+		}
+
+		lock, err := loadLockfile(lockPath, entryFile)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		b, err := bundler.NewBundler(entryFile, false, !noCache)
+		if err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to create bundler: %v", err)))
+			os.Exit(1)
+		}
+		if err := b.SetTarget(target); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+		b.SetVariants(cfg.Variants)
+		b.SetRequireDecisions(cfg.Requires)
+		b.SetMirrors(cfg.Mirrors)
+		if lock != nil {
+			b.SetLockfile(lock)
+		}
+		b.SetFlattenDepth(flattenDepth)
+		if err := b.SetHTTPOptions(httpOptionsFromFlags(cmd)); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+			os.Exit(1)
+		}
+
+		if _, err := b.Resolve(); err != nil {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Resolving failed: %v", err)))
+			os.Exit(1)
+		}
+
+		chains := b.Chains(module)
+		if len(chains) == 0 {
+			fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %s is not in the dependency graph of %s", module, entryFile)))
+			os.Exit(1)
+		}
+
+		fmt.Println(infoStyle.Render(fmt.Sprintf("🔎 %d chain(s) from %s to %s:", len(chains), entryFile, module)))
+		for _, chain := range chains {
+			fmt.Println()
+			printChain(chain)
+		}
+	},
+}
+
+// printChain prints a dependency chain as an indented tree
+func printChain(chain []string) {
+	fmt.Printf("  %s\n", chain[0])
+	for i, key := range chain[1:] {
+		fmt.Printf("  %s└── %s\n", strings.Repeat("    ", i), key)
+	}
+}
+
+func init() {
+	whyCmd.Flags().StringP("entry", "e", "main.lua", "Entry point Lua file or http(s) URL")
+	whyCmd.Flags().StringP("target", "t", "", "Runtime target used to pick module variants (default: config target, then roblox)")
+	whyCmd.Flags().StringP("config", "c", "", "Path to config file (default: lua-bundler.json next to the entry file)")
+	whyCmd.Flags().String("lockfile", "", "Lockfile pinning remote dependency hashes (default: lua-bundler.lock next to the entry file, if present)")
+	whyCmd.Flags().BoolP("no-cache", "n", false, "Disable HTTP cache while resolving the graph")
+	whyCmd.Flags().Int("flatten-depth", -1, "Levels of nested remote loadstring chains to follow (-1 = unlimited)")
+	addHTTPFlags(whyCmd)
+
+	rootCmd.AddCommand(whyCmd)
+}
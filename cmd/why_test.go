@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhyCmd(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"why"})
+	require.NoError(t, err, "why should be registered")
+	assert.Equal(t, whyCmd, cmd)
+	assert.Error(t, whyCmd.Args(whyCmd, nil), "why requires a module argument")
+
+	for _, name := range []string{"entry", "target", "config", "flatten-depth", "proxy"} {
+		assert.NotNil(t, whyCmd.Flags().Lookup(name), "Flag %q not found", name)
+	}
+}
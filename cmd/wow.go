@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+)
+
+// writeAddon writes a bundled .toc entry as an addon folder at dir: a .toc
+// named after the entry that loads the bundle, the bundle itself, and the
+// other files of the addon folder, such as frame XML, textures and sounds.
+// Merged Lua and XML files and other .toc files are left out. It returns
+// the path of the bundle.
+func writeAddon(b *bundler.Bundler, bundle, entryFile, dir string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(entryFile), filepath.Ext(entryFile))
+	files, err := projectFiles(b, entryFile, dir, func(rel string) bool {
+		return bundler.IsTOC(rel)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to collect addon files: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	luaFile := filepath.Join(dir, name+".lua")
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, f.Data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := os.WriteFile(luaFile, []byte(bundle), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", luaFile, err)
+	}
+	tocFile := filepath.Join(dir, name+".toc")
+	if err := os.WriteFile(tocFile, []byte(b.AddonTOC(name+".lua")), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", tocFile, err)
+	}
+	return luaFile, nil
+}
+
+// addonFolderMatches reports whether WoW loads the .toc named toc from the
+// folder dir: the names must match, apart from a client suffix such as
+// _Mainline or _Vanilla
+func addonFolderMatches(dir, toc string) bool {
+	folder := filepath.Base(dir)
+	name := strings.TrimSuffix(filepath.Base(toc), filepath.Ext(toc))
+	return name == folder || strings.HasPrefix(name, folder+"_")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAddon(t *testing.T) {
+	dir := t.TempDir()
+	libs := t.TempDir()
+	for path, content := range map[string]string{
+		filepath.Join(dir, "MyAddon.toc"):             "## Title: My Addon\nLibs\\LibStub\\LibStub.lua\nCore.lua\nFrames.xml\n",
+		filepath.Join(dir, "MyAddon_Vanilla.toc"):     "Core.lua\n",
+		filepath.Join(dir, "Core.lua"):                "local name, ns = ...\n",
+		filepath.Join(dir, "Frames.xml"):              "<Ui><Frame name=\"F\"/><Script file=\"FrameCode.lua\"/></Ui>\n",
+		filepath.Join(dir, "FrameCode.lua"):           "print(1)\n",
+		filepath.Join(dir, "Media", "icon.tga"):       "tga",
+		filepath.Join(dir, "lua-bundler.json"):        "{}",
+		filepath.Join(libs, "LibStub", "LibStub.lua"): "LibStub = {}\n",
+		filepath.Join(dir, ".git", "HEAD"):            "ref",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	entry := filepath.Join(dir, "MyAddon.toc")
+	out := filepath.Join(dir, "dist", "MyAddon")
+
+	b, err := bundler.NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetTarget(bundler.TargetWoW))
+	b.SetAddonLibPaths([]string{libs})
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	luaFile, err := writeAddon(b, bundle, entry, out)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(out, "MyAddon.lua"), luaFile)
+
+	var files []string
+	require.NoError(t, filepath.WalkDir(out, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(out, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	}))
+	assert.ElementsMatch(t, []string{"MyAddon.toc", "MyAddon.lua", "Frames.xml", "FrameCode.lua", "Media/icon.tga"}, files,
+		"merged files, other .toc files, hidden and bundler files should be left out")
+
+	toc, err := os.ReadFile(filepath.Join(out, "MyAddon.toc"))
+	require.NoError(t, err)
+	assert.Equal(t, "## Title: My Addon\n\nMyAddon.lua\nFrames.xml\n", string(toc))
+	data, err := os.ReadFile(luaFile)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, string(data))
+	assert.Contains(t, bundle, "LibStub = {}", "libraries from the lib paths should be merged")
+}
+
+func TestAddonFolderMatches(t *testing.T) {
+	assert.True(t, addonFolderMatches("dist/MyAddon", "src/MyAddon.toc"))
+	assert.True(t, addonFolderMatches("dist/MyAddon", "src/MyAddon_Mainline.toc"))
+	assert.False(t, addonFolderMatches("dist/build", "src/MyAddon.toc"))
+}
@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	pathpkg "path"
+	"strings"
+	"time"
+)
+
+// Archive formats
+const (
+	FormatZip   = "zip"
+	FormatTarGz = "tar.gz"
+)
+
+// File is a file stored in an archive
+type File struct {
+	Name string // slash-separated path inside the archive
+	Data []byte
+}
+
+// Extension returns the file extension for an archive format
+func Extension(format string) (string, error) {
+	switch format {
+	case FormatZip:
+		return ".zip", nil
+	case FormatTarGz, "tgz":
+		return ".tar.gz", nil
+	}
+	return "", fmt.Errorf("unknown archive format %q (supported: %s, %s)", format, FormatZip, FormatTarGz)
+}
+
+// Write writes files to w as a zip or gzipped tar archive, in order, with
+// every entry stamped with modTime
+func Write(w io.Writer, format string, files []File, modTime time.Time) error {
+	switch format {
+	case FormatZip:
+		return writeZip(w, files, modTime)
+	case FormatTarGz, "tgz":
+		return writeTarGz(w, files, modTime)
+	}
+	_, err := Extension(format)
+	return err
+}
+
+func writeZip(w io.Writer, files []File, modTime time.Time) error {
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		header := &zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: modTime}
+		header.SetMode(0644)
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", f.Name, err)
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, files []File, modTime time.Time) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    f.Name,
+			Mode:    0644,
+			Size:    int64(len(f.Data)),
+			ModTime: modTime,
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to add %s: %w", f.Name, err)
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Find returns the first file in the zip or gzipped tar archive at path
+// whose base name is name
+func Find(path, name string) ([]byte, error) {
+	if strings.HasSuffix(path, ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if pathpkg.Base(f.Name) == name {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("%s not found in %s", name, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in %s", name, path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if pathpkg.Base(header.Name) == name {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// IsArchive reports whether path names a zip or gzipped tar archive
+func IsArchive(path string) bool {
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checksums returns a SHA256SUMS file for files, in the format read by
+// `sha256sum -c`
+func Checksums(files []File) []byte {
+	var out strings.Builder
+	for _, f := range files {
+		out.WriteString(SHA256(f.Data) + "  " + f.Name + "\n")
+	}
+	return []byte(out.String())
+}
+
+// SHA256 returns the hex SHA-256 digest of data
+func SHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ExpandName fills {placeholders} in a file name template, e.g.
+// "{name}-{version}" with {"name": "hub", "version": "1.2.0"}
+func ExpandName(template string, values map[string]string) (string, error) {
+	name := template
+	for key, value := range values {
+		name = strings.ReplaceAll(name, "{"+key+"}", value)
+	}
+	if i := strings.Index(name, "{"); i >= 0 && strings.Contains(name[i:], "}") {
+		return "", fmt.Errorf("unknown placeholder in name template %q", template)
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("name template %q must expand to a plain file name", template)
+	}
+	return name, nil
+}
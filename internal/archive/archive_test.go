@@ -0,0 +1,104 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFiles = []File{
+	{Name: "hub-1.0.0/hub.lua", Data: []byte("print('hi')\n")},
+	{Name: "hub-1.0.0/manifest.json", Data: []byte("{}\n")},
+}
+
+func TestWrite_Zip(t *testing.T) {
+	var buf bytes.Buffer
+	modTime := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+	require.NoError(t, Write(&buf, FormatZip, testFiles, modTime))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "hub-1.0.0/hub.lua", zr.File[0].Name)
+	assert.True(t, zr.File[0].Modified.Equal(modTime))
+
+	rc, err := zr.File[0].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "print('hi')\n", string(data))
+}
+
+func TestWrite_TarGz(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatTarGz, testFiles, time.Unix(0, 0)))
+
+	gr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{"hub-1.0.0/hub.lua", "hub-1.0.0/manifest.json"}, names)
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	assert.Error(t, Write(io.Discard, "rar", testFiles, time.Now()))
+}
+
+func TestChecksums(t *testing.T) {
+	sums := string(Checksums(testFiles[:1]))
+	assert.Equal(t, SHA256([]byte("print('hi')\n"))+"  hub-1.0.0/hub.lua\n", sums)
+	assert.Len(t, SHA256(nil), 64)
+}
+
+func TestExpandName(t *testing.T) {
+	name, err := ExpandName("{name}-{version}-{target}", map[string]string{"name": "hub", "version": "1.2.0", "target": "roblox"})
+	require.NoError(t, err)
+	assert.Equal(t, "hub-1.2.0-roblox", name)
+
+	_, err = ExpandName("{name}-{commit}", map[string]string{"name": "hub"})
+	assert.ErrorContains(t, err, "unknown placeholder")
+
+	_, err = ExpandName("../{name}", map[string]string{"name": "hub"})
+	assert.Error(t, err)
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	for _, format := range []string{FormatZip, FormatTarGz} {
+		ext, err := Extension(format)
+		require.NoError(t, err)
+		path := filepath.Join(dir, "hub"+ext)
+
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, format, testFiles, time.Now()))
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+		assert.True(t, IsArchive(path))
+		data, err := Find(path, "manifest.json")
+		require.NoError(t, err, format)
+		assert.Equal(t, "{}\n", string(data))
+
+		_, err = Find(path, "missing.txt")
+		assert.Error(t, err, format)
+	}
+	assert.False(t, IsArchive("manifest.json"))
+}
@@ -0,0 +1,150 @@
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DecisionExternal is the decision leaving a require to the runtime; other
+// decisions name the file to embed, relative to the project directory
+const DecisionExternal = "external"
+
+// Ambiguity is a require the resolution rules cannot settle alone: a file
+// exists for a name they leave external, or several files match it
+type Ambiguity struct {
+	Module     string   // the require path
+	File       string   // the file requiring it, relative to the project directory
+	Candidates []string // files it could load, relative to the project directory, in resolution order
+	External   bool     // whether it could also stay a runtime require
+}
+
+// Choices returns the decisions that settle the require: its candidates,
+// then DecisionExternal when the rules would leave it external
+func (a Ambiguity) Choices() []string {
+	choices := append([]string{}, a.Candidates...)
+	if a.External {
+		choices = append(choices, DecisionExternal)
+	}
+	return choices
+}
+
+// String describes the ambiguity on one line
+func (a Ambiguity) String() string {
+	return fmt.Sprintf("%s (required by %s): %s", a.Module, a.File, strings.Join(a.Choices(), " or "))
+}
+
+// AmbiguityResolver chooses how an ambiguous require resolves, returning
+// one of its Choices
+type AmbiguityResolver func(Ambiguity) (string, error)
+
+// SetRequireDecisions sets how requires resolve regardless of the
+// resolution rules: module path -> DecisionExternal or the file to embed,
+// relative to the project directory
+func (b *Bundler) SetRequireDecisions(decisions map[string]string) {
+	b.requireDecisions = make(map[string]string, len(decisions))
+	for module, choice := range decisions {
+		b.requireDecisions[module] = choice
+	}
+}
+
+// SetAmbiguityResolver sets the function asked to settle ambiguous requires
+// no pragma or decision covers. Without one, Resolve fails listing them.
+func (b *Bundler) SetAmbiguityResolver(resolver AmbiguityResolver) {
+	b.ambiguityResolver = resolver
+}
+
+// requireDecision returns how a require of modulePath in filePath was
+// decided: DecisionExternal, the file to embed, or "" when the resolution
+// rules settle it. Ambiguous requires are put to the resolver, or recorded
+// for Resolve to report when there is none.
+func (b *Bundler) requireDecision(filePath, modulePath string) (string, error) {
+	if choice, ok := b.requireDecisions[modulePath]; ok {
+		return b.decisionFile(choice), nil
+	}
+	a, ok := b.ambiguity(filePath, modulePath)
+	if !ok {
+		return "", nil
+	}
+	if b.ambiguityResolver == nil {
+		for _, seen := range b.ambiguities {
+			if seen.Module == modulePath {
+				return "", nil
+			}
+		}
+		b.ambiguities = append(b.ambiguities, a)
+		return "", nil
+	}
+
+	choice, err := b.ambiguityResolver(a)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", modulePath, err)
+	}
+	if !containsString(a.Choices(), choice) {
+		return "", fmt.Errorf("invalid choice %q for %s (expected %s)", choice, modulePath, strings.Join(a.Choices(), " or "))
+	}
+	if b.requireDecisions == nil {
+		b.requireDecisions = make(map[string]string)
+	}
+	b.requireDecisions[modulePath] = choice
+	return b.decisionFile(choice), nil
+}
+
+// decisionFile returns the file a decision embeds, or DecisionExternal
+func (b *Bundler) decisionFile(choice string) string {
+	if choice == DecisionExternal || filepath.IsAbs(choice) {
+		return choice
+	}
+	return filepath.Join(b.baseDir, filepath.FromSlash(choice))
+}
+
+// ambiguity reports whether a require of modulePath in filePath is
+// ambiguous, and how
+func (b *Bundler) ambiguity(filePath, modulePath string) (Ambiguity, bool) {
+	if strings.Contains(modulePath, "::") {
+		return Ambiguity{}, false
+	}
+	a := Ambiguity{Module: modulePath, File: b.displaySource(filePath)}
+	for _, candidate := range b.requireCandidates(filePath, modulePath) {
+		a.Candidates = append(a.Candidates, b.displaySource(candidate))
+	}
+	if !b.isLocalModule(modulePath) {
+		a.External = true
+		return a, len(a.Candidates) > 0
+	}
+	return a, len(a.Candidates) > 1
+}
+
+// requireCandidates returns the existing files a require of modulePath in
+// currentFile could load, in the order the resolution rules try them
+func (b *Bundler) requireCandidates(currentFile, modulePath string) []string {
+	var paths []string
+	switch {
+	case b.resolution == ResolveFilesystem || isPathRequire(modulePath) || strings.HasPrefix(modulePath, "/"):
+	case b.target == TargetOpenResty && strings.HasPrefix(modulePath, "resty."):
+		paths = b.lualibCandidates(modulePath)
+	case b.resolution == ResolveLuaPackage || b.target == TargetLove2D:
+		paths = b.packageCandidates(modulePath)
+	}
+	if paths == nil {
+		paths = []string{b.resolveModulePath(currentFile, modulePath)}
+	}
+
+	var existing []string
+	for _, path := range paths {
+		if _, err := b.fs.Stat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+	return existing
+}
+
+// ambiguityError reports the ambiguous requires no resolver settled
+func (b *Bundler) ambiguityError() error {
+	lines := make([]string, len(b.ambiguities))
+	for i, a := range b.ambiguities {
+		lines[i] = a.String()
+	}
+	return fmt.Errorf("%d ambiguous require(s); settle each with a %s or %s pragma, a \"requires\" entry in lua-bundler.json, or an interactive build:\n  %s",
+		len(lines), PragmaLocal, PragmaExternal, strings.Join(lines, "\n  "))
+}
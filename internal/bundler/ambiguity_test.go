@@ -0,0 +1,124 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAmbiguousBundler(t *testing.T) *Bundler {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":          "local json = require(\"lib.json\")\n",
+		"lib/json.lua":      "return { flat = true }\n",
+		"lib/json/init.lua": "return { nested = true }\n",
+	})
+	require.NoError(t, b.SetTarget(TargetLua51))
+	require.NoError(t, b.SetResolution(ResolveLuaPackage))
+	return b
+}
+
+func TestResolve_AmbiguousRequiresFail(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":          "local players = require(\"Players.Local\")\nlocal other = require(\"helper\")\n",
+		"helper.lua":        "return require(\"Players.Local\")\n",
+		"Players/Local.lua": "return {}\n",
+	})
+
+	_, err = b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 ambiguous require(s)")
+	assert.Contains(t, err.Error(), "\n  Players.Local (required by main.lua): Players/Local.lua or external")
+}
+
+func TestResolve_AmbiguityResolver(t *testing.T) {
+	b := newAmbiguousBundler(t)
+	require.NoError(t, b.SetTarget(TargetOpenComputers))
+	b.SetFileSystem(MemoryFS{
+		"main.lua":          "local c = require(\"component\")\nlocal json = require(\"lib.json\")\nlocal h = require(\"helper\")\n",
+		"helper.lua":        "return require(\"lib.json\")\n",
+		"component.lua":     "return { fake = true }\n",
+		"lib/json.lua":      "return { flat = true }\n",
+		"lib/json/init.lua": "return { nested = true }\n",
+	})
+
+	var asked []Ambiguity
+	b.SetAmbiguityResolver(func(a Ambiguity) (string, error) {
+		asked = append(asked, a)
+		if a.External {
+			return DecisionExternal, nil
+		}
+		return a.Candidates[1], nil
+	})
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	require.Len(t, asked, 2, "each module should be asked about once")
+	assert.Equal(t, Ambiguity{Module: "component", File: "main.lua", Candidates: []string{"component.lua"}, External: true}, asked[0])
+	assert.Equal(t, []string{"lib/json.lua", "lib/json/init.lua"}, asked[1].Candidates)
+	assert.Contains(t, bundle, `local c = require("component")`)
+	assert.NotContains(t, bundle, "fake = true")
+	assert.Contains(t, bundle, "nested = true")
+	assert.NotContains(t, bundle, "flat = true")
+}
+
+func TestResolve_AmbiguityResolverInvalidChoice(t *testing.T) {
+	b := newAmbiguousBundler(t)
+	b.SetAmbiguityResolver(func(a Ambiguity) (string, error) {
+		return "elsewhere.lua", nil
+	})
+
+	_, err := b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid choice "elsewhere.lua" for lib.json`)
+}
+
+func TestResolve_RequireDecisions(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":          "local players = require(\"Players.Local\")\nlocal json = require(\"lib.json\")\n",
+		"Players/Local.lua": "return { mine = true }\n",
+		"lib/json.lua":      "return { flat = true }\n",
+		"lib/json/init.lua": "return { nested = true }\n",
+	})
+	require.NoError(t, b.SetResolution(ResolveLuaPackage))
+	b.SetRequireDecisions(map[string]string{"Players.Local": "Players/Local.lua", "lib.json": DecisionExternal})
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, `local players = loadModule("Players.Local")`)
+	assert.Contains(t, bundle, "mine = true")
+	assert.Contains(t, bundle, `local json = require("lib.json")`)
+	assert.NotContains(t, bundle, "flat = true")
+}
+
+func TestResolve_PragmaSettlesAmbiguity(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":          "local players = require(\"Players.Local\") --@bundle-as-external\n",
+		"Players/Local.lua": "return {}\n",
+	})
+
+	_, err = b.Resolve()
+	require.NoError(t, err)
+}
+
+func TestResolveRequire_Decisions(t *testing.T) {
+	b, err := NewBundler("/project/main.lua", false, false)
+	require.NoError(t, err)
+	b.SetRequireDecisions(map[string]string{"Players.Local": "Players/Local.lua", "utils.log": DecisionExternal})
+
+	path, external := b.ResolveRequire("/project/main.lua", "Players.Local")
+	assert.Equal(t, "/project/Players/Local.lua", path)
+	assert.False(t, external)
+
+	path, external = b.ResolveRequire("/project/main.lua", "utils.log")
+	assert.Equal(t, "/project/utils/log.lua", path)
+	assert.True(t, external)
+}
@@ -0,0 +1,100 @@
+package bundler
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// RemoteAudit is the health report for a single remote dependency
+type RemoteAudit struct {
+	URL        string
+	StatusCode int
+	FinalURL   string // URL after following redirects
+	Dead       bool   // request failed or returned an error status
+	Redirected bool
+	Insecure   bool // served over plain HTTP (originally or after redirect)
+	Changed    bool // content no longer matches the lockfile hash
+	Err        error
+}
+
+// HasIssues reports whether the audit found anything worth acting on
+func (a RemoteAudit) HasIssues() bool {
+	return a.Dead || a.Redirected || a.Insecure || a.Changed
+}
+
+// DiscoverRemoteURLs resolves the dependency graph to list its remote
+// dependencies for an audit. Unlike Resolve, downloads are not held to the
+// lockfile, and a dependency that cannot be downloaded is listed with
+// nothing below it instead of failing, since those are what an audit
+// reports. The lockfile is left unchanged.
+func (b *Bundler) DiscoverRemoteURLs() ([]string, error) {
+	lock := b.lockfile
+	b.lockfile, b.discovering = nil, true
+	defer func() { b.lockfile, b.discovering = lock, false }()
+	if _, err := b.Resolve(); err != nil {
+		return nil, err
+	}
+	return b.GetRemoteURLs(), nil
+}
+
+// AuditRemote checks a remote dependency without embedding it. A HEAD request
+// establishes liveness and redirects; when the lockfile pins the URL the body is
+// fetched with GET to compare hashes.
+func (b *Bundler) AuditRemote(url string) RemoteAudit {
+	audit := RemoteAudit{URL: url, FinalURL: url}
+
+	resp, err := b.httpClient.Head(url)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		// Some hosts reject HEAD; fall back to GET
+		resp.Body.Close()
+		resp, err = b.httpClient.Get(url)
+	}
+	if err != nil {
+		audit.Dead = true
+		audit.Err = err
+		audit.Insecure = strings.HasPrefix(url, "http://")
+		return audit
+	}
+	resp.Body.Close()
+
+	audit.StatusCode = resp.StatusCode
+	audit.FinalURL = resp.Request.URL.String()
+	audit.Redirected = audit.FinalURL != url
+	audit.Dead = resp.StatusCode >= http.StatusBadRequest
+	audit.Insecure = strings.HasPrefix(url, "http://") || strings.HasPrefix(audit.FinalURL, "http://")
+
+	if audit.Dead || b.lockfile == nil {
+		return audit
+	}
+	if _, pinned := b.lockfile.Get(url); !pinned {
+		return audit
+	}
+
+	resp, err = b.httpClient.Get(url)
+	if err != nil {
+		audit.Err = err
+		return audit
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		audit.Err = err
+		return audit
+	}
+	audit.Changed = !b.lockfile.Verify(url, string(body))
+
+	return audit
+}
+
+// Ping sends a HEAD request to url with the configured HTTP client, returning
+// an error only when no response arrives; any HTTP status counts as reachable
+func (b *Bundler) Ping(url string) error {
+	resp, err := b.httpClient.Head(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
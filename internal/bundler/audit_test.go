@@ -0,0 +1,125 @@
+package bundler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/lockfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRemote(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'ok'"))
+	})
+	mux.HandleFunc("/moved.lua", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok.lua", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/nohead.lua", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("return 'nohead'"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	lock, err := lockfile.Load(filepath.Join(t.TempDir(), lockfile.FileName))
+	require.NoError(t, err)
+	lock.Set(server.URL+"/ok.lua", lockfile.Entry{SHA256: lockfile.Hash("return 'old'")})
+
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+	b.SetLockfile(lock)
+
+	t.Run("dead link", func(t *testing.T) {
+		audit := b.AuditRemote(server.URL + "/missing.lua")
+		assert.True(t, audit.Dead)
+		assert.Equal(t, http.StatusNotFound, audit.StatusCode)
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		audit := b.AuditRemote(server.URL + "/moved.lua")
+		assert.False(t, audit.Dead)
+		assert.True(t, audit.Redirected)
+		assert.Equal(t, server.URL+"/ok.lua", audit.FinalURL)
+	})
+
+	t.Run("plain http", func(t *testing.T) {
+		audit := b.AuditRemote(server.URL + "/nohead.lua")
+		assert.False(t, audit.Dead, "HEAD rejection should fall back to GET")
+		assert.True(t, audit.Insecure, "httptest serves plain HTTP")
+		assert.False(t, audit.Changed, "unpinned URLs are never reported as changed")
+	})
+
+	t.Run("content changed", func(t *testing.T) {
+		audit := b.AuditRemote(server.URL + "/ok.lua")
+		assert.True(t, audit.Changed)
+		assert.True(t, audit.HasIssues())
+	})
+
+	t.Run("connection failure", func(t *testing.T) {
+		audit := b.AuditRemote("http://127.0.0.1:1/unreachable.lua")
+		assert.True(t, audit.Dead)
+		assert.Error(t, audit.Err)
+	})
+}
+
+func TestDiscoverRemoteURLs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/changed.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("local nested = loadstring(game:HttpGet(\"" + "http://" + r.Host + "/nested.lua\"))()\nreturn nested\n"))
+	})
+	mux.HandleFunc("/nested.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'nested'"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(
+		"local dead = loadstring(game:HttpGet(\""+server.URL+"/missing.lua\"))()\n"+
+			"local changed = loadstring(game:HttpGet(\""+server.URL+"/changed.lua\"))()\n"), 0644))
+
+	lock, err := lockfile.Load(filepath.Join(dir, lockfile.FileName))
+	require.NoError(t, err)
+	lock.Set(server.URL+"/changed.lua", lockfile.Entry{SHA256: lockfile.Hash("return 'old'")})
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	b.SetLockfile(lock)
+
+	_, err = b.Resolve()
+	require.Error(t, err, "a build fails on the dead URL")
+
+	b, err = NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	b.SetLockfile(lock)
+	urls, err := b.DiscoverRemoteURLs()
+	require.NoError(t, err, "discovery carries on past dead and changed URLs")
+	assert.Equal(t, []string{server.URL + "/changed.lua", server.URL + "/missing.lua", server.URL + "/nested.lua"}, urls)
+
+	assert.True(t, b.AuditRemote(server.URL+"/missing.lua").Dead)
+	assert.True(t, b.AuditRemote(server.URL+"/changed.lua").Changed, "the lockfile still applies to the audit")
+	entry, _ := lock.Get(server.URL + "/changed.lua")
+	assert.Equal(t, lockfile.Hash("return 'old'"), entry.SHA256, "discovery leaves the lockfile alone")
+	_, pinned := lock.Get(server.URL + "/nested.lua")
+	assert.False(t, pinned)
+}
+
+func TestGetRemoteURLs(t *testing.T) {
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+
+	b.httpModules["https://b.example.com/lib.lua"] = true
+	b.httpModules["https://a.example.com/lib.lua"] = true
+
+	assert.Equal(t, []string{"https://a.example.com/lib.lua", "https://b.example.com/lib.lua"}, b.GetRemoteURLs())
+}
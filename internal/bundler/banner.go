@@ -0,0 +1,34 @@
+package bundler
+
+import "strings"
+
+// SetBanner sets text written verbatim before and after the bundle, such as
+// a license header or a loader guard. Neither is minified or obfuscated.
+func (b *Bundler) SetBanner(banner, footer string) {
+	b.banner = banner
+	b.footer = footer
+}
+
+// wrapBanner adds the banner and footer to a generated script, each on its
+// own lines, and moves the source map down past the banner
+func (b *Bundler) wrapBanner(content string) string {
+	if b.banner != "" {
+		banner := b.banner
+		if !strings.HasSuffix(banner, "\n") {
+			banner += "\n"
+		}
+		content = banner + content
+		shift := strings.Count(banner, "\n")
+		for i := range b.sourceMap {
+			b.sourceMap[i].StartLine += shift
+			b.sourceMap[i].EndLine += shift
+		}
+	}
+	if b.footer != "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += b.footer
+	}
+	return content
+}
@@ -0,0 +1,60 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle_BannerAndFooter(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("local greeting = require(\"helper\")\nprint(greeting)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.lua"), []byte("return \"hi\"\n"), 0644))
+
+	banner := "-- Copyright (c) Example\n--   All rights reserved.\nif not game then return end"
+	footer := "-- Usage: loadstring(...)()\n"
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	b.SetBanner(banner, footer)
+	require.NoError(t, b.SetMinifyLevel(MinifyAggressive))
+	result, err := b.Bundle(true)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(result, banner+"\n"), "banner is kept verbatim on its own lines")
+	assert.True(t, strings.HasSuffix(result, "\n"+footer), "footer is kept verbatim on its own lines")
+	body := strings.TrimSuffix(strings.TrimPrefix(result, banner+"\n"), "\n"+footer)
+	assert.NotContains(t, body, "\n", "the bundle itself is still minified")
+}
+
+func TestBundle_BannerShiftsSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("local greeting = require(\"helper\")\nprint(greeting)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.lua"), []byte("return \"hi\"\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	plain := append([]SourceMapping(nil), b.SourceMap()...)
+
+	b, err = NewBundler(entry, false, false)
+	require.NoError(t, err)
+	b.SetBanner("-- one\n-- two\n", "")
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	lines := strings.Split(result, "\n")
+	require.Len(t, b.SourceMap(), len(plain))
+	for i, m := range b.SourceMap() {
+		assert.Equal(t, plain[i].StartLine+2, m.StartLine)
+		assert.Equal(t, plain[i].EndLine+2, m.EndLine)
+	}
+	assert.Equal(t, "-- Module: helper", lines[b.SourceMap()[0].StartLine-1])
+}
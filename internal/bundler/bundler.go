@@ -2,29 +2,129 @@ package bundler
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/constt/lua-bundler/internal/lockfile"
 	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/constt/lua-bundler/internal/parser"
 )
 
 type Bundler struct {
-	modules        map[string]string // path -> content
-	httpModules    map[string]bool   // track which modules are from HTTP
-	baseDir        string
-	entryFile      string
-	httpClient     *http.Client
-	cache          *cache.Cache
-	verbose        bool
-	obfuscator     *obfuscator.Obfuscator
-	obfuscateLevel int
+	modules           map[string]string // path -> content
+	httpModules       map[string]bool   // track which modules are from HTTP
+	moduleSources     map[string]string // module key -> file or URL it was loaded from
+	entryContent      string            // entry file content as read, before obfuscation
+	baseDir           string
+	entryFile         string
+	httpClient        *http.Client
+	cache             *cache.Cache
+	cacheStats        cache.Stats // how the last Resolve's downloads were served
+	offline           bool        // remote scripts come from the cache only
+	verbose           bool
+	log               io.Writer // progress messages and warnings, nil for standard output
+	obfuscator        *obfuscator.Obfuscator
+	obfuscateLevel    int                                 // -1 for pipelines other than the level presets
+	obfuscationRules  []ObfuscationRule                   // per-module passes, first match wins
+	ruleObfuscators   []*obfuscator.Obfuscator            // per rule, nil for rules without passes
+	hotFunctions      map[string][]obfuscator.HotFunction // module key or entry file -> functions tagged --@hot
+	target            string
+	variants          map[string]map[string]string // module -> target -> path
+	polyfills         []string                     // polyfills injected into the last bundle
+	mirrors           map[string][]string          // url -> ordered fallback URLs
+	lockfile          *lockfile.Lockfile
+	discovering       bool                         // DiscoverRemoteURLs: failed downloads are leaves, not errors
+	remoteRequires    map[string]map[string]string // remote script -> require path -> URL key of the module
+	warnings          []string
+	diagnostics       []Diagnostic                 // source problems found while resolving
+	flattenDepth      int                          // remote loader levels to embed (-1 = unlimited)
+	runtimeFetches    map[string]bool              // URLs left as runtime fetches
+	graph             map[string][]Dependency      // parent key -> dependencies
+	namespace         string                       // prefix for module keys and loader names
+	sourceMap         []SourceMapping              // module line ranges in the last bundle
+	defines           map[string]string            // constants declared at the top of the bundle
+	version           string                       // version written to the bundle header
+	gitInfo           *GitInfo                     // source revision written to the header and manifest
+	buildID           string                       // content hash of the last bundle's sources
+	externals         map[string][]string          // external require path -> keys requiring it
+	suppressed        map[string]bool              // warning rules disabled by SuppressWarnings
+	keepPatterns      []*regexp.Regexp             // print/warn messages kept in release mode
+	logLevel          string                       // default level of the release logging shim ("" = strip instead)
+	debugCalls        string                       // DebugCalls* for calls in the arguments of stripped statements
+	minifyLevel       int                          // Minify* level; MinifyAuto follows release mode
+	preserveLines     bool                         // keep statements on their original lines
+	banner            string                       // text written verbatim before the bundle
+	footer            string                       // text written verbatim after the bundle
+	loader            string                       // Loader* strategy for embedding modules
+	inlineSmall       int                          // token limit of constant modules inlined at their requires (0 = off)
+	inlinedSmall      map[string]string            // modules inlined into the last bundle -> their expression
+	bundleFormat      int                          // layout of the bundle, BundleFormat unless set
+	sourceEncoding    string                       // Encoding* for sources that are not UTF-8
+	lineEndings       string                       // LineEndings* of the bundle
+	largeModuleSize   int64                        // bytes from which local files are not obfuscated, 0 for no limit
+	profile           bool                         // record fileProfiles
+	fileProfiles      []FileProfile                // cost of the local modules of the last build
+	noMemoize         map[string]bool              // modules run again on every require
+	stubs             map[string]string            // module key -> replacement file, "" to omit
+	features          map[string][]string          // feature name -> module patterns only it uses
+	enabledFeatures   map[string]bool              // features kept in the build
+	requestShim       bool                         // route executor request functions through one shim
+	requestShimUsed   bool                         // the last Resolve rewrote request calls
+	stateKey          string                       // key isolating getgenv(), shared and _G state, "" for none
+	stateUsed         bool                         // the last Resolve isolated shared state
+	localeFunction    string                       // global marking translatable strings, "" when disabled
+	locales           map[string]map[string]string // locale -> key -> translated text
+	locale            string                       // locale selected at startup
+	lualibPaths       []string                     // directories resty.* modules are read from, nil for the defaults
+	apis              map[string]string            // os.loadAPI path -> source, for the computercraft target
+	sizeLimit         string                       // largest bundle, in bytes or a SizeLimits name; "" for the target's
+	addon             *addon                       // the addon of a .toc entry, nil for Lua entries
+	addonLibPaths     []string                     // directories searched for addon files the addon folder lacks
+	includes          map[string]map[string]string // module key -> include path -> embedded module key, for gmod
+	resource          *resource                    // the FiveM resource of a manifest entry, nil for Lua entries
+	side              string                       // Side* a manifest entry is bundled for
+	resolution        string                       // Resolve* mode mapping require strings to files
+	requireDecisions  map[string]string            // module path -> DecisionExternal or file to embed
+	ambiguityResolver AmbiguityResolver            // asked to settle ambiguous requires, nil to fail
+	ambiguities       []Ambiguity                  // ambiguous requires the last Resolve left unsettled
+	strict            bool                         // fail on requires not embedded or explicitly external
+	requireCalls      []requireCall                // require calls of the processed files, in processing order
+	requireScanErrors []string                     // files whose require calls could not be read
+	prelude           []string                     // modules run before the entry file
+	epilogue          []string                     // modules run after the entry file returns
+	safeWrap          bool                         // run the bundle in pcall, reporting errors
+	errorHandler      string                       // module reporting errors of a safe-wrapped bundle, "" for the default
+	fs                FileSystem                   // where local sources are read from
 }
 
+var namespaceRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// NewBundler returns a bundler for entryFile that prints its progress when
+// verbose is set and caches remote scripts on disk when useCache is
 func NewBundler(entryFile string, verbose bool, useCache bool) (*Bundler, error) {
+	c, err := cache.NewCache(useCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	return New(entryFile, WithVerbose(verbose), WithCache(c))
+}
+
+// New returns a bundler for entryFile configured by opts, applied in order.
+// Without options it targets Roblox, does not cache remote scripts and
+// prints warnings to standard output.
+func New(entryFile string, opts ...Option) (*Bundler, error) {
 	baseDir := filepath.Dir(entryFile)
+	if IsURL(entryFile) {
+		// Remote entries have no project directory; resolve local paths from cwd
+		baseDir = "."
+	}
 	if baseDir == "." {
 		var err error
 		baseDir, err = os.Getwd()
@@ -33,81 +133,369 @@ func NewBundler(entryFile string, verbose bool, useCache bool) (*Bundler, error)
 		}
 	}
 
-	// Initialize cache
-	c, err := cache.NewCache(useCache)
+	c, err := cache.NewCache(false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize cache: %w", err)
 	}
 
-	return &Bundler{
-		modules:     make(map[string]string),
-		httpModules: make(map[string]bool),
-		baseDir:     baseDir,
-		entryFile:   entryFile,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		cache:          c,
-		verbose:        verbose,
-		obfuscateLevel: 0,
-	}, nil
+	httpClient, err := newHTTPClient(HTTPOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundler{
+		modules:         make(map[string]string),
+		httpModules:     make(map[string]bool),
+		moduleSources:   make(map[string]string),
+		baseDir:         baseDir,
+		entryFile:       entryFile,
+		httpClient:      httpClient,
+		cache:           c,
+		obfuscateLevel:  0,
+		target:          TargetRoblox,
+		flattenDepth:    -1,
+		minifyLevel:     MinifyAuto,
+		loader:          LoaderClosure,
+		bundleFormat:    BundleFormat,
+		largeModuleSize: DefaultLargeModuleSize,
+		sourceEncoding:  EncodingAuto,
+		lineEndings:     LineEndingsLF,
+		debugCalls:      DebugCallsKeep,
+		side:            SideClient,
+		resolution:      ResolveRobloxDots,
+		fs:              osFileSystem{},
+	}
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
 }
 
 // SetObfuscationLevel sets the obfuscation level for local modules
 func (b *Bundler) SetObfuscationLevel(level int) {
 	b.obfuscateLevel = level
+	b.obfuscator = nil
 	if level > 0 {
 		b.obfuscator = obfuscator.NewObfuscator(level)
 	}
+	b.deriveRuleObfuscators()
 }
 
-func (b *Bundler) Bundle(releaseMode bool) (string, error) {
-	// Read entry file
-	content, err := os.ReadFile(b.entryFile)
+// SetObfuscation sets the obfuscation passes run over local modules, in
+// order; no passes disables obfuscation
+func (b *Bundler) SetObfuscation(passes []obfuscator.Pass) error {
+	if len(passes) == 0 {
+		b.SetObfuscationLevel(0)
+		return nil
+	}
+	o, err := obfuscator.NewPipeline(passes)
 	if err != nil {
-		return "", fmt.Errorf("failed to read entry file: %w", err)
+		return err
 	}
+	b.obfuscateLevel = obfuscator.Level(passes)
+	b.obfuscator = o
+	b.deriveRuleObfuscators()
+	return nil
+}
 
-	mainContent := string(content)
+// SetMirrors sets ordered fallback URLs per remote dependency
+func (b *Bundler) SetMirrors(mirrors map[string][]string) {
+	b.mirrors = mirrors
+}
+
+// SetFlattenDepth limits how many levels of remote loadstring chains are embedded;
+// deeper levels stay as runtime fetches. A negative depth embeds everything.
+func (b *Bundler) SetFlattenDepth(depth int) {
+	b.flattenDepth = depth
+}
+
+// SetNamespace prefixes module keys and loader names so several bundles can be
+// concatenated or loaded side by side without clobbering each other
+func (b *Bundler) SetNamespace(namespace string) error {
+	if namespace != "" && !namespaceRegex.MatchString(namespace) {
+		return fmt.Errorf("invalid namespace %q: use letters, digits and underscores, not starting with a digit", namespace)
+	}
+	b.namespace = namespace
+	return nil
+}
+
+// SetKeepPatterns keeps print and warn statements with a string argument
+// matching one of the regular expressions in release mode
+func (b *Bundler) SetKeepPatterns(patterns []string) error {
+	b.keepPatterns = nil
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid keep pattern %q: %w", pattern, err)
+		}
+		b.keepPatterns = append(b.keepPatterns, re)
+	}
+	return nil
+}
+
+// SetLockfile enables hash verification and pinning of remote dependencies
+func (b *Bundler) SetLockfile(l *lockfile.Lockfile) {
+	b.lockfile = l
+}
+
+// Resolve reads the entry file and processes all of its dependencies without
+// generating a bundle. It returns the entry file content.
+func (b *Bundler) Resolve() (string, error) {
+	b.cacheStats = cache.Stats{}
+
+	// Read entry file (remote entries go through the cache, lockfile and content checks)
+	var mainContent string
+	if IsURL(b.entryFile) {
+		content, err := b.downloadHTTP(b.entryFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read entry file: %w", err)
+		}
+		b.httpModules[b.entryFile] = true
+		mainContent = content
+	} else {
+		content, err := b.readSource(b.entryFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read entry file: %w", err)
+		}
+		mainContent = content
+	}
 
 	// Process all dependencies
 	if b.verbose {
-		fmt.Println("🔍 Processing dependencies...")
+		b.logf("🔍 Processing dependencies...\n")
 	}
-	if err := b.processFile(b.entryFile, mainContent); err != nil {
+	if IsTOC(b.entryFile) || IsFXManifest(b.entryFile) {
+		// The listed files are processed as they are merged
+		resolve := b.resolveAddon
+		if IsFXManifest(b.entryFile) {
+			resolve = b.resolveManifest
+		}
+		merged, err := resolve(mainContent)
+		if err != nil {
+			return "", err
+		}
+		mainContent = merged
+		b.entryContent = mainContent
+	} else {
+		b.entryContent = mainContent
+		if err := b.processFile(b.entryFile, b.entryFile, mainContent, 0); err != nil {
+			return "", err
+		}
+		mainContent = b.rewriteRemoteRequires(b.entryFile, mainContent)
+		b.entryContent = mainContent
+	}
+	if err := b.resolveHooks(); err != nil {
 		return "", err
 	}
+	if len(b.ambiguities) > 0 {
+		return "", b.ambiguityError()
+	}
+	if b.strict {
+		if err := b.strictError(); err != nil {
+			return "", err
+		}
+	}
+	b.warnUnusedStubs()
+	b.warnUntranslated()
+	b.warnUnmatchedFeatures()
+	if b.target == TargetOpenResty {
+		b.warnOpenRestyGlobals()
+	}
+	if b.requestShim {
+		mainContent = b.shimRequests(mainContent)
+	}
+	if b.stateKey != "" {
+		mainContent = b.isolateState(mainContent)
+	}
+	if len(b.apis) > 0 {
+		mainContent = b.loadBundledAPIs(mainContent)
+	}
+	if len(b.includes) > 0 {
+		mainContent = b.rewriteIncludes(mainContent)
+	}
 
-	// Obfuscate main content (entry file) if obfuscation is enabled
-	if b.obfuscateLevel > 0 && b.obfuscator != nil {
-		mainContent = b.obfuscator.Obfuscate(mainContent)
+	return mainContent, nil
+}
+
+func (b *Bundler) Bundle(releaseMode bool) (string, error) {
+	return b.bundle(releaseMode, &Timings{})
+}
+
+// bundle builds the bundle, recording the time spent in each step
+func (b *Bundler) bundle(releaseMode bool, timings *Timings) (string, error) {
+	start := time.Now()
+	defer func() { timings.Total = time.Since(start) }()
+
+	if err := b.checkPrimitives(); err != nil {
+		return "", err
+	}
+	mainContent, err := b.Resolve()
+	if err != nil {
+		return "", err
 	}
 
+	// Obfuscate main content (entry file) if obfuscation is enabled
+	mainContent = b.obfuscate("", b.entryFile, mainContent)
+	timings.Resolve = time.Since(start)
+
 	// Generate bundle
-	bundleOutput := b.generateBundle(mainContent)
+	step := time.Now()
+	bundleOutput := b.generateBundle(mainContent, releaseMode)
+	timings.Generate = time.Since(step)
+	step = time.Now()
+	if b.verbose && len(b.polyfills) > 0 {
+		b.logf("🧩 Injected polyfills (%s): %s\n", b.target, strings.Join(b.polyfills, ", "))
+	}
 
 	// Apply release mode if enabled
+	expectedStatements := -1 // statements left once print/warn are removed, -1 when not checked
 	if releaseMode {
-		if b.verbose {
-			fmt.Println("🚀 Applying release mode...")
-			fmt.Println("  - Removing print/warn statements...")
+		chunk, err := parser.Parse(bundleOutput)
+		if err != nil {
+			b.warnf("release mode: bundle could not be parsed (%v); print/warn statements were left in place", err)
+		} else if b.logLevel == "" {
+			expectedStatements = b.checkDebugRemoval(chunk, bundleOutput, func(offset int) string {
+				return b.bundleLocation(bundleOutput, offset)
+			})
+		}
+		if b.logLevel != "" {
+			if b.verbose {
+				b.logf("🚀 Applying release mode...\n")
+				b.logf("  - Routing print/warn statements through the logging shim...\n")
+			}
+			// The shim's own print and warn calls must not be rewritten, so it
+			// is added afterwards
+			logger, levelGlobal := b.loggerNames()
+			var shim strings.Builder
+			writeLogger(&shim, logger, levelGlobal, b.logLevel)
+			bundleOutput = b.stripDebugStatements(bundleOutput)
+			if b.preserveLines {
+				// One line in front of the header comment keeps the numbering
+				bundleOutput = minifyCode(shim.String(), minifyOptions{}) + " " + bundleOutput
+			} else {
+				bundleOutput = shim.String() + bundleOutput
+			}
+		} else {
+			if b.verbose {
+				b.logf("🚀 Applying release mode...\n")
+				b.logf("  - Removing print/warn statements...\n")
+			}
+			bundleOutput = b.stripDebugStatements(bundleOutput)
 		}
-		bundleOutput = removeDebugStatements(bundleOutput)
 
-		if b.verbose {
-			fmt.Println("  - Removing comments...")
+		// Line ranges no longer apply once statements are removed
+		if !b.preserveLines {
+			b.sourceMap = nil
 		}
-		bundleOutput = removeComments(bundleOutput)
+	}
 
+	if level := b.effectiveMinifyLevel(releaseMode); level > MinifyNone {
 		if b.verbose {
-			fmt.Println("  - Minifying to single line...")
+			b.logf("🗜️  Minifying (level %d)...\n", level)
 		}
-		bundleOutput = minifyCode(bundleOutput)
+		bundleOutput = b.minify(bundleOutput, level)
+		if !b.preserveLines {
+			b.sourceMap = nil
+		}
+	}
+	if expectedStatements >= 0 {
+		b.checkReleaseOutput("bundle", bundleOutput, expectedStatements)
 	}
 
+	bundleOutput = b.applyLineEndings(b.wrapBanner(bundleOutput))
+	timings.Postprocess = time.Since(step)
+	if err := b.checkSizeLimit(bundleOutput); err != nil {
+		return "", err
+	}
 	return bundleOutput, nil
 }
 
+// warnf prints a warning and records it for the build summary
+func (b *Bundler) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	b.warnings = append(b.warnings, msg)
+	b.logf("⚠️  %s\n", msg)
+}
+
+// GetWarnings returns the warnings raised during the last build
+func (b *Bundler) GetWarnings() []string {
+	return b.warnings
+}
+
+// CacheStats returns how the downloads of the last Resolve were served
+func (b *Bundler) CacheStats() cache.Stats {
+	return b.cacheStats
+}
+
+// RecordCacheStats adds the last Resolve's cache stats to those kept in the
+// cache directory, for the cache stats command, and returns the totals
+func (b *Bundler) RecordCacheStats() (cache.Stats, error) {
+	return b.cache.RecordStats(b.cacheStats)
+}
+
+// GetDiagnostics returns the source problems found by the last Resolve, with
+// the file and line of each, for editors and other tools
+func (b *Bundler) GetDiagnostics() []Diagnostic {
+	return b.diagnostics
+}
+
 func (b *Bundler) GetModules() map[string]string {
 	return b.modules
 }
+
+// GetLocalFiles returns the entry file (unless remote) followed by the sorted
+// files of all embedded local modules, and for a .toc or manifest entry the
+// files it merges
+func (b *Bundler) GetLocalFiles() []string {
+	var files []string
+	seen := map[string]bool{b.entryFile: true}
+	var merged []string
+	if b.addon != nil {
+		merged = append(append(merged, b.addon.files...), b.addon.expanded...)
+	}
+	if b.resource != nil {
+		merged = append(merged, b.resource.files...)
+	}
+	for _, file := range merged {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, file := range b.moduleSources {
+		if !seen[file] && !IsURL(file) {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	if !IsURL(b.entryFile) {
+		files = append([]string{b.entryFile}, files...)
+	}
+	return files
+}
+
+// GetNameMap returns the original -> obfuscated identifier names of the last
+// build, or nil when identifiers were not renamed
+func (b *Bundler) GetNameMap() map[string]string {
+	// The obfuscators share their renames
+	for _, o := range b.obfuscators() {
+		if o.Renames() {
+			return o.NameMap()
+		}
+	}
+	return nil
+}
+
+// GetRemoteURLs returns the sorted URLs of all embedded HTTP modules
+func (b *Bundler) GetRemoteURLs() []string {
+	urls := make([]string, 0, len(b.httpModules))
+	for url := range b.httpModules {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -1,11 +1,15 @@
 package bundler
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/constt/lua-bundler/internal/obfuscator"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -169,3 +173,108 @@ func TestGetModules(t *testing.T) {
 	assert.Len(t, modules, 1, "GetModules() should return map with 1 item")
 	assert.Equal(t, "content", modules["test"], "GetModules() should return correct content")
 }
+
+func TestBundle_RemoteEntry(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scripts/loader.lua", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "local util = require('./util.lua')\nlocal payload = loadstring(game:HttpGet('http://%s/payload.lua'))()\nreturn util", r.Host)
+	})
+	mux.HandleFunc("/scripts/util.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return { name = 'util' }"))
+	})
+	mux.HandleFunc("/payload.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'payload'"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	entry := server.URL + "/scripts/loader.lua"
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err, "NewBundler() should accept URL entries")
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err, "Bundle() should not fail")
+
+	assert.Contains(t, result, "return { name = 'util' }", "relative require should be fetched from the entry URL")
+	assert.Contains(t, result, "return 'payload'", "nested HttpGet should be embedded")
+	assert.Contains(t, b.GetRemoteURLs(), entry)
+	assert.Contains(t, b.GetRemoteURLs(), server.URL+"/scripts/util.lua")
+}
+
+func TestBundle_RemoteEntryFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	b, err := NewBundler(server.URL+"/missing.lua", false, false)
+	require.NoError(t, err)
+
+	_, err = b.Bundle(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read entry file")
+}
+
+func TestGetLocalFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`local b = require("b")
+local a = require("a")
+local again = require("./a.lua")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.lua"), []byte("return 'a'"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "b.lua"), []byte("return require('a')"), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		mainFile,
+		filepath.Join(tempDir, "a.lua"),
+		filepath.Join(tempDir, "b.lua"),
+	}, b.GetLocalFiles())
+}
+
+func TestBundleFromMemoryFS(t *testing.T) {
+	b, err := NewBundler("src/main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"src/main.lua":         `local helper = require("utils.helper")`,
+		"src/utils/helper.lua": `return require("./log")`,
+		"src/utils/log.lua":    `return {}`,
+	})
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, `EmbeddedModules["utils.helper"]`)
+	assert.Contains(t, out, `EmbeddedModules["./log"]`)
+	assert.Equal(t, []string{"src/main.lua", filepath.FromSlash("/src/utils/helper.lua"), filepath.FromSlash("/src/utils/log.lua")}, b.GetLocalFiles())
+}
+
+func TestSetObfuscation(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":  "local greet = require(\"greet\")\nprint(greet(\"hi\"))\n",
+		"greet.lua": "return function(name) return \"hello \" .. name end\n",
+	})
+	require.NoError(t, b.SetObfuscation([]obfuscator.Pass{{Name: obfuscator.PassStrings, Method: obfuscator.StringsEscape}}))
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, `local greet = loadModule("greet")`, "require paths stay for the bundler")
+	assert.Contains(t, out, `print(greet("\104\105"))`)
+	assert.Contains(t, out, `return "\104\101\108\108\111\032" .. name`)
+	assert.Nil(t, b.GetNameMap(), "nothing was renamed")
+
+	m := b.Manifest("game", "1.0.0", false)
+	assert.Equal(t, -1, m.Obfuscation)
+	assert.Equal(t, []string{"strings"}, m.ObfuscationPasses)
+
+	assert.Error(t, b.SetObfuscation([]obfuscator.Pass{{Name: "shuffle"}}))
+	require.NoError(t, b.SetObfuscation(obfuscator.Presets["medium"]))
+	assert.Equal(t, 2, b.Manifest("game", "1.0.0", false).Obfuscation)
+	require.NoError(t, b.SetObfuscation(nil))
+	out, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, `print(greet("hi"))`)
+}
@@ -0,0 +1,139 @@
+package bundler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ModuleChange is a module added, removed or changed between two builds
+type ModuleChange struct {
+	Key       string
+	Source    string
+	Remote    bool
+	OldSHA256 string // empty for added modules
+	NewSHA256 string // empty for removed modules
+}
+
+// ManifestDiff lists the module changes between two build manifests
+type ManifestDiff struct {
+	Added   []ModuleChange
+	Removed []ModuleChange
+	Updated []ModuleChange
+}
+
+// Empty reports whether no modules changed
+func (d ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+// ParseManifest decodes a manifest.json written by the package command
+func ParseManifest(data []byte) (Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return m, nil
+}
+
+// DiffManifests compares the modules of two builds. Both manifests list
+// modules sorted by key, so the changes come out sorted too.
+func DiffManifests(old, new Manifest) ManifestDiff {
+	var d ManifestDiff
+	i, j := 0, 0
+	for i < len(old.Modules) || j < len(new.Modules) {
+		switch {
+		case j == len(new.Modules) || (i < len(old.Modules) && old.Modules[i].Key < new.Modules[j].Key):
+			m := old.Modules[i]
+			d.Removed = append(d.Removed, ModuleChange{Key: m.Key, Source: m.Source, Remote: m.Remote, OldSHA256: m.SHA256})
+			i++
+		case i == len(old.Modules) || new.Modules[j].Key < old.Modules[i].Key:
+			m := new.Modules[j]
+			d.Added = append(d.Added, ModuleChange{Key: m.Key, Source: m.Source, Remote: m.Remote, NewSHA256: m.SHA256})
+			j++
+		default:
+			o, m := old.Modules[i], new.Modules[j]
+			if o.SHA256 != m.SHA256 || o.Source != m.Source {
+				d.Updated = append(d.Updated, ModuleChange{Key: m.Key, Source: m.Source, Remote: m.Remote, OldSHA256: o.SHA256, NewSHA256: m.SHA256})
+			}
+			i++
+			j++
+		}
+	}
+	return d
+}
+
+// FormatChangelog renders a Markdown changelog section for the changes
+// between two builds, headed by title (e.g. "1.4.0 - 2024-05-01")
+func FormatChangelog(title string, old, new Manifest) string {
+	d := DiffManifests(old, new)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "## %s\n\n", title)
+	if old.Version != "" {
+		fmt.Fprintf(&out, "Changes since %s.\n\n", old.Version)
+	}
+	if d.Empty() {
+		out.WriteString("No module changes.\n")
+		return out.String()
+	}
+
+	section := func(heading string, changes []ModuleChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&out, "### %s\n\n", heading)
+		for _, c := range changes {
+			fmt.Fprintf(&out, "- `%s`", c.Key)
+			if c.Source != c.Key {
+				fmt.Fprintf(&out, " (%s)", c.Source)
+			}
+			if c.Remote && c.OldSHA256 != "" && c.NewSHA256 != "" {
+				fmt.Fprintf(&out, ": `%s` → `%s`", shortHash(c.OldSHA256), shortHash(c.NewSHA256))
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString("\n")
+	}
+	section("Added", d.Added)
+	section("Removed", d.Removed)
+	section("Updated", d.Updated)
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// PrependChangelog inserts section at the top of the changelog file at path,
+// after a leading "# " title if there is one, creating the file if needed
+func PrependChangelog(path, section string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read changelog: %w", err)
+	}
+
+	content := string(existing)
+	var head string
+	if strings.HasPrefix(content, "# ") {
+		end := strings.Index(content, "\n")
+		if end < 0 {
+			end = len(content)
+		}
+		head = content[:end] + "\n\n"
+		content = strings.TrimLeft(content[end:], "\n")
+	}
+
+	updated := head + strings.TrimRight(section, "\n") + "\n"
+	if content != "" {
+		updated += "\n" + content
+	}
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		return fmt.Errorf("failed to write changelog: %w", err)
+	}
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,71 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldManifest = Manifest{Version: "1.0.0", Modules: []ManifestModule{
+		{Key: "https://example.com/lib.lua", Source: "https://example.com/lib.lua", Remote: true, SHA256: "aaaaaaaaaaaaaaaa"},
+		{Key: "legacy", Source: "legacy.lua", SHA256: "11"},
+		{Key: "util", Source: "util.lua", SHA256: "22"},
+	}}
+	newManifest = Manifest{Version: "1.1.0", Modules: []ManifestModule{
+		{Key: "extra", Source: "extra.lua", SHA256: "33"},
+		{Key: "https://example.com/lib.lua", Source: "https://example.com/lib.lua", Remote: true, SHA256: "bbbbbbbbbbbbbbbb"},
+		{Key: "util", Source: "util.lua", SHA256: "22"},
+	}}
+)
+
+func TestDiffManifests(t *testing.T) {
+	d := DiffManifests(oldManifest, newManifest)
+	require.Len(t, d.Added, 1)
+	assert.Equal(t, "extra", d.Added[0].Key)
+	require.Len(t, d.Removed, 1)
+	assert.Equal(t, "legacy", d.Removed[0].Key)
+	require.Len(t, d.Updated, 1)
+	assert.Equal(t, "aaaaaaaaaaaaaaaa", d.Updated[0].OldSHA256)
+	assert.Equal(t, "bbbbbbbbbbbbbbbb", d.Updated[0].NewSHA256)
+
+	assert.True(t, DiffManifests(newManifest, newManifest).Empty())
+}
+
+func TestFormatChangelog(t *testing.T) {
+	changelog := FormatChangelog("1.1.0", oldManifest, newManifest)
+	assert.Equal(t, "## 1.1.0\n\nChanges since 1.0.0.\n\n"+
+		"### Added\n\n- `extra` (extra.lua)\n\n"+
+		"### Removed\n\n- `legacy` (legacy.lua)\n\n"+
+		"### Updated\n\n- `https://example.com/lib.lua`: `aaaaaaaaaaaa` → `bbbbbbbbbbbb`\n", changelog)
+
+	assert.Contains(t, FormatChangelog("1.1.1", newManifest, newManifest), "No module changes.")
+}
+
+func TestPrependChangelog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+
+	require.NoError(t, PrependChangelog(path, "## 1.0.0\n\n- first\n"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "## 1.0.0\n\n- first\n", string(data), "a missing changelog should be created")
+
+	require.NoError(t, os.WriteFile(path, []byte("# Changelog\n\n## 1.0.0\n\n- first\n"), 0644))
+	require.NoError(t, PrependChangelog(path, "## 1.1.0\n\n- second\n"))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# Changelog\n\n## 1.1.0\n\n- second\n\n## 1.0.0\n\n- first\n", string(data))
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest([]byte(`{"version": "2.0.0", "modules": [{"key": "a", "source": "a.lua", "remote": false, "sha256": "ff"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", m.Version)
+	assert.Equal(t, "ff", m.Modules[0].SHA256)
+
+	_, err = ParseManifest([]byte("not json"))
+	assert.Error(t, err)
+}
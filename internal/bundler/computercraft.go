@@ -0,0 +1,196 @@
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ccExternals are the modules ComputerCraft's ROM provides, such as
+// cc.pretty; they stay runtime requires
+var ccExternals = []string{"cc"}
+
+// ocExternals are the libraries OpenComputers' OpenOS provides
+var ocExternals = []string{"buffer", "colors", "component", "computer", "event", "filesystem", "internet", "keyboard", "note", "process", "serialization", "sh", "shell", "sides", "term", "text", "thread", "transforms", "unicode", "uuid", "vt100"}
+
+// SizeLimits are the named --size-limit presets, in bytes, matching the
+// storage of ComputerCraft and OpenComputers machines and media
+var SizeLimits = map[string]int{
+	"cc-computer": 1000000, // default ComputerCraft computer space
+	"cc-floppy":   125000,  // ComputerCraft floppy disk
+	"oc-eeprom":   4096,    // OpenComputers EEPROM code
+	"oc-floppy":   524288,  // OpenComputers floppy disk
+	"oc-hdd1":     1048576, // OpenComputers tier 1 hard disk
+	"oc-hdd2":     2097152, // OpenComputers tier 2 hard disk
+	"oc-hdd3":     4194304, // OpenComputers tier 3 hard disk
+}
+
+// SizeLimitNames returns the SizeLimits preset names, sorted
+func SizeLimitNames() []string {
+	return sortedKeys(SizeLimits)
+}
+
+// targetSizeLimits are the presets applied to a target's bundles unless
+// SetSizeLimit overrides them
+var targetSizeLimits = map[string]string{
+	TargetComputerCraft: "cc-computer",
+	TargetOpenComputers: "oc-hdd1",
+}
+
+var (
+	// ccHTTPGetRegex matches ComputerCraft's one-line remote loader,
+	// load(http.get(url).readAll())()
+	ccHTTPGetRegex = regexp.MustCompile(`(?:loadstring|load)\s*\(\s*http\.get\s*\(\s*['"]([^'"]+)['"]\s*\)\s*\.readAll\s*\(\s*\)\s*\)\s*\(\s*\)`)
+	// ccFuncCallHTTPGetRegex matches that loader passed to a function call
+	ccFuncCallHTTPGetRegex = regexp.MustCompile(`\w+\s*\([^)]*(?:loadstring|load)\s*\(\s*http\.get`)
+
+	loadAPIRegex = regexp.MustCompile(`os\.loadAPI\s*\(\s*['"]([^'"]+)['"]\s*\)`)
+)
+
+// remoteLoaderPatterns returns the target's one-line remote loader and the
+// same loader inside a function call, which is left alone
+func (b *Bundler) remoteLoaderPatterns() (*regexp.Regexp, *regexp.Regexp) {
+	if b.target == TargetComputerCraft {
+		return ccHTTPGetRegex, ccFuncCallHTTPGetRegex
+	}
+	return httpGetRegex, funcCallHttpGetRegex
+}
+
+// SetSizeLimit sets the largest bundle Bundle produces, as bytes or one of
+// SizeLimits, such as cc-floppy. "none" removes the limit, and "" keeps the
+// target's default.
+func (b *Bundler) SetSizeLimit(limit string) error {
+	switch limit {
+	case "", "none":
+		b.sizeLimit = limit
+		return nil
+	}
+	if _, ok := SizeLimits[limit]; ok {
+		b.sizeLimit = limit
+		return nil
+	}
+	if n, err := strconv.Atoi(limit); err == nil && n > 0 {
+		b.sizeLimit = limit
+		return nil
+	}
+	return fmt.Errorf("invalid size limit %q (expected bytes, none or one of: %s)", limit, strings.Join(SizeLimitNames(), ", "))
+}
+
+// checkSizeLimit returns an error when bundle exceeds the size limit
+func (b *Bundler) checkSizeLimit(bundle string) error {
+	limit := b.sizeLimit
+	if limit == "" {
+		limit = targetSizeLimits[b.target]
+	}
+	if limit == "" || limit == "none" {
+		return nil
+	}
+	max, ok := SizeLimits[limit]
+	name := limit
+	if !ok {
+		max, _ = strconv.Atoi(limit)
+		name = "the size limit"
+	}
+	if len(bundle) > max {
+		return fmt.Errorf("bundle is %d bytes, over the %d bytes of %s; minify it with --minify or choose a larger --size-limit", len(bundle), max, name)
+	}
+	return nil
+}
+
+// collectAPIs reads the files src loads with os.loadAPI, which
+// ComputerCraft runs in an environment of their own and publishes as a
+// global named after the file
+func (b *Bundler) collectAPIs(src string) error {
+	for _, m := range loadAPIRegex.FindAllStringSubmatch(src, -1) {
+		path := m[1]
+		if _, ok := b.apis[path]; ok || isROMPath(path) {
+			continue
+		}
+		file := filepath.Join(b.baseDir, filepath.FromSlash(strings.TrimPrefix(path, "/")))
+		content, err := b.readSource(file)
+		if err != nil && !strings.HasSuffix(file, ".lua") {
+			content, err = b.readSource(file + ".lua")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read API %s: %w", path, err)
+		}
+		if b.apis == nil {
+			b.apis = make(map[string]string)
+		}
+		b.apis[path] = content
+		if b.verbose {
+			b.logf("📄 Processed API: %s\n", path)
+		}
+	}
+	return nil
+}
+
+// isROMPath reports whether path is in the computer's read-only ROM, whose
+// APIs every computer has
+func isROMPath(path string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(path, "/"), "rom/")
+}
+
+// apiLoaderName returns the local replacing os.loadAPI for bundled APIs
+func (b *Bundler) apiLoaderName() string {
+	if b.namespace == "" {
+		return "BundleLoadAPI"
+	}
+	return b.namespace + "_BundleLoadAPI"
+}
+
+// loadBundledAPIs replaces os.loadAPI calls of bundled APIs in the entry
+// content and modules with the API loader
+func (b *Bundler) loadBundledAPIs(mainContent string) string {
+	name := b.apiLoaderName()
+	replace := func(src string) string {
+		return loadAPIRegex.ReplaceAllStringFunc(src, func(match string) string {
+			path := loadAPIRegex.FindStringSubmatch(match)[1]
+			if _, ok := b.apis[path]; !ok {
+				return match
+			}
+			return name + "(" + quoteLua(path) + ")"
+		})
+	}
+	for key, content := range b.modules {
+		b.modules[key] = replace(content)
+	}
+	return replace(mainContent)
+}
+
+// writeAPILoader writes the API loader, which does what os.loadAPI does for
+// the embedded API sources and calls os.loadAPI for any other path
+func writeAPILoader(out *strings.Builder, name string, apis map[string]string) {
+	out.WriteString("-- os.loadAPI for bundled APIs\n")
+	fmt.Fprintf(out, "local %s\n", name)
+	out.WriteString("do\n")
+	out.WriteString("    local sources = {\n")
+	for _, path := range sortedKeys(apis) {
+		fmt.Fprintf(out, "        [%s] = %s,\n", quoteLua(path), longString(apis[path]))
+	}
+	out.WriteString("    }\n")
+	fmt.Fprintf(out, "    function %s(path)\n", name)
+	out.WriteString("        local source = sources[path]\n")
+	out.WriteString("        if not source then\n")
+	out.WriteString("            return os.loadAPI(path)\n")
+	out.WriteString("        end\n")
+	out.WriteString("        local api = (path:match(\"[^/]+$\"):gsub(\"%.lua$\", \"\"))\n")
+	out.WriteString("        local env = setmetatable({}, { __index = _G })\n")
+	out.WriteString("        local fn, err = load(source, \"@\" .. path, \"t\", env)\n")
+	out.WriteString("        if not fn then\n")
+	out.WriteString("            error(err, 2)\n")
+	out.WriteString("        end\n")
+	out.WriteString("        fn()\n")
+	out.WriteString("        local t = {}\n")
+	out.WriteString("        for k, v in pairs(env) do\n")
+	out.WriteString("            if k ~= \"_ENV\" then\n")
+	out.WriteString("                t[k] = v\n")
+	out.WriteString("            end\n")
+	out.WriteString("        end\n")
+	out.WriteString("        _G[api] = t\n")
+	out.WriteString("        return true\n")
+	out.WriteString("    end\n")
+	out.WriteString("end\n\n")
+}
@@ -0,0 +1,116 @@
+package bundler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle_ComputerCraft(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("print('remote')\n"))
+	}))
+	defer server.Close()
+
+	b, err := NewBundler("startup.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"startup.lua":  "local pretty = require(\"cc.pretty\")\nos.loadAPI(\"apis/turtlex\")\nos.loadAPI(\"/rom/apis/missing\")\nload(http.get(\"" + server.URL + "/lib.lua\").readAll())()\nturtlex.dig()\n",
+		"apis/turtlex": "function dig() turtle.dig() end\n",
+	})
+	require.NoError(t, b.SetTarget(TargetComputerCraft))
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, "local BundleLoadAPI\n")
+	assert.Contains(t, bundle, `["apis/turtlex"] = `)
+	assert.Contains(t, bundle, "function dig() turtle.dig() end")
+	assert.Contains(t, bundle, `BundleLoadAPI("apis/turtlex")`)
+	assert.Contains(t, bundle, `os.loadAPI("/rom/apis/missing")`, "APIs from the ROM should stay os.loadAPI calls")
+	assert.Contains(t, bundle, "print('remote')", "http.get loaders should be embedded")
+	assert.NotContains(t, bundle, "http.get(")
+
+	var externals []string
+	for _, ext := range b.ExternalRequires() {
+		externals = append(externals, ext.Path)
+	}
+	assert.Equal(t, []string{"cc.pretty"}, externals)
+}
+
+func TestBundle_OpenComputersExternals(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "local component = require(\"component\")\nlocal event = require(\"event\")\nlocal util = require(\"util\")\n",
+		"util.lua": "return {}\n",
+	})
+	require.NoError(t, b.SetTarget(TargetOpenComputers))
+
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.lua", "util.lua"}, b.GetLocalFiles())
+	var externals []string
+	for _, ext := range b.ExternalRequires() {
+		externals = append(externals, ext.Path)
+	}
+	assert.ElementsMatch(t, []string{"component", "event"}, externals)
+}
+
+func TestSetSizeLimit(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+
+	for _, limit := range []string{"", "none", "cc-floppy", "4096"} {
+		assert.NoError(t, b.SetSizeLimit(limit), limit)
+	}
+	for _, limit := range []string{"0", "-5", "floppy", "4kb"} {
+		err := b.SetSizeLimit(limit)
+		require.Error(t, err, limit)
+		assert.Contains(t, err.Error(), "oc-eeprom")
+	}
+}
+
+func TestBundle_SizeLimit(t *testing.T) {
+	newBundler := func(t *testing.T) *Bundler {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFileSystem(MemoryFS{"main.lua": "print(\"" + strings.Repeat("x", 5000) + "\")\n"})
+		return b
+	}
+
+	t.Run("preset", func(t *testing.T) {
+		b := newBundler(t)
+		require.NoError(t, b.SetTarget(TargetOpenComputers))
+		require.NoError(t, b.SetSizeLimit("oc-eeprom"))
+		_, err := b.Bundle(false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "4096 bytes of oc-eeprom")
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		b := newBundler(t)
+		require.NoError(t, b.SetSizeLimit("100"))
+		_, err := b.Bundle(false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "over the 100 bytes of the size limit")
+	})
+
+	t.Run("target default", func(t *testing.T) {
+		b := newBundler(t)
+		require.NoError(t, b.SetTarget(TargetComputerCraft))
+		_, err := b.Bundle(false)
+		assert.NoError(t, err, "the bundle fits a ComputerCraft computer")
+	})
+
+	t.Run("none", func(t *testing.T) {
+		b := newBundler(t)
+		require.NoError(t, b.SetTarget(TargetOpenComputers))
+		require.NoError(t, b.SetSizeLimit("none"))
+		_, err := b.Bundle(false)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,156 @@
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+var (
+	identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	numberRegex     = regexp.MustCompile(`^-?(?:\d+(?:\.\d+)?(?:[eE][+-]?\d+)?|0[xX][0-9a-fA-F]+)$`)
+)
+
+// SetDefines sets constants declared as locals at the top of the bundle,
+// visible to the entry file and every module. Values that are true, false,
+// nil or a number are emitted as such; anything else becomes a string.
+func (b *Bundler) SetDefines(defines map[string]string) error {
+	for name := range defines {
+		if !identifierRegex.MatchString(name) || parser.IsKeyword(name) {
+			return fmt.Errorf("invalid define %q: must be a Lua identifier", name)
+		}
+	}
+	b.defines = defines
+	return nil
+}
+
+// SetVersion sets the version written to the bundle header. It is also
+// defined as VERSION, with the build's content hash as BUILD_ID, unless
+// those are defined explicitly.
+func (b *Bundler) SetVersion(version string) error {
+	if strings.ContainsAny(version, "\r\n") {
+		return fmt.Errorf("invalid version %q", version)
+	}
+	b.version = version
+	return nil
+}
+
+// GitInfo identifies the source revision a bundle was built from
+type GitInfo struct {
+	Commit string `json:"commit"`
+	Tag    string `json:"tag,omitempty"`
+	Dirty  bool   `json:"dirty"`
+}
+
+// SetGitInfo records the source revision in the bundle header and the
+// manifest. It is also defined as GIT_COMMIT, GIT_TAG (nil when untagged)
+// and GIT_DIRTY, unless those are defined explicitly. nil records none.
+func (b *Bundler) SetGitInfo(info *GitInfo) {
+	b.gitInfo = info
+}
+
+// GetBuildID returns the content hash identifying the last bundle's sources
+func (b *Bundler) GetBuildID() string {
+	return b.buildID
+}
+
+// computeBuildID hashes the entry and module sources, independent of map order
+func (b *Bundler) computeBuildID(mainContent string) string {
+	keys := make([]string, 0, len(b.modules))
+	for key := range b.modules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(mainContent))
+	for _, key := range keys {
+		fmt.Fprintf(h, "\x00%s\x00%s", key, b.modules[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// bundleDefines returns the defines to emit, including VERSION and BUILD_ID
+// when a version is set, the GIT_ constants when git info is, and a
+// FEATURE_ constant per feature, and the locale function when translation
+// is enabled
+func (b *Bundler) bundleDefines() map[string]string {
+	defines := make(map[string]string, len(b.defines)+len(b.features)+5)
+	if b.version != "" {
+		defines["VERSION"] = quoteLua(b.version)
+		defines["BUILD_ID"] = quoteLua(b.buildID)
+	}
+	if b.gitInfo != nil {
+		defines["GIT_COMMIT"] = quoteLua(b.gitInfo.Commit)
+		defines["GIT_TAG"] = "nil"
+		if b.gitInfo.Tag != "" {
+			defines["GIT_TAG"] = quoteLua(b.gitInfo.Tag)
+		}
+		defines["GIT_DIRTY"] = fmt.Sprint(b.gitInfo.Dirty)
+	}
+	for name := range b.features {
+		defines[featureDefineName(name)] = fmt.Sprint(b.enabledFeatures[name])
+	}
+	if b.localeFunction != "" {
+		defines[b.localeFunction] = b.localeTable()
+	}
+	for name, value := range b.defines {
+		defines[name] = luaLiteral(value)
+	}
+	return defines
+}
+
+// writeDefines declares the defines as locals, sorted by name
+func writeDefines(output *strings.Builder, defines map[string]string) {
+	if len(defines) == 0 {
+		return
+	}
+	names := make([]string, 0, len(defines))
+	for name := range defines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	output.WriteString("-- Defines\n")
+	for _, name := range names {
+		output.WriteString(fmt.Sprintf("local %s = %s\n", name, defines[name]))
+	}
+	output.WriteString("\n")
+}
+
+// luaLiteral returns value as a Lua literal
+func luaLiteral(value string) string {
+	switch {
+	case value == "true" || value == "false" || value == "nil":
+		return value
+	case numberRegex.MatchString(value):
+		return value
+	}
+	return quoteLua(value)
+}
+
+// String describes the revision as in the bundle header, such as
+// "3f2a9c1d... (v1.4.0, dirty)"
+func (info *GitInfo) String() string {
+	var notes []string
+	if info.Tag != "" {
+		notes = append(notes, info.Tag)
+	}
+	if info.Dirty {
+		notes = append(notes, "dirty")
+	}
+	if len(notes) == 0 {
+		return info.Commit
+	}
+	return fmt.Sprintf("%s (%s)", info.Commit, strings.Join(notes, ", "))
+}
+
+// quoteLua returns s as a double-quoted Lua string
+func quoteLua(s string) string {
+	return "\"" + strings.NewReplacer("\n", "\\n", "\r", "\\r").Replace(escapeString(s)) + "\""
+}
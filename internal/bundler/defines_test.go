@@ -0,0 +1,98 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinesAndVersion(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`print(VERSION, DEBUG)`), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetVersion("1.4.0"))
+	require.NoError(t, b.SetDefines(map[string]string{
+		"DEBUG":   "false",
+		"RETRIES": "3",
+		"API":     `https://example.com/"v1"`,
+	}))
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	buildID := b.GetBuildID()
+	assert.Len(t, buildID, 12)
+	assert.Contains(t, result, "-- Version: 1.4.0 (build "+buildID+")\n")
+	assert.Contains(t, result, "-- Defines\n"+
+		"local API = \"https://example.com/\\\"v1\\\"\"\n"+
+		"local BUILD_ID = \""+buildID+"\"\n"+
+		"local DEBUG = false\n"+
+		"local RETRIES = 3\n"+
+		"local VERSION = \"1.4.0\"\n")
+
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Equal(t, buildID, b.GetBuildID(), "the build ID should only depend on the sources")
+}
+
+func TestDefines_Override(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetVersion("1.0.0"))
+	require.NoError(t, b.SetDefines(map[string]string{"VERSION": "custom"}))
+	assert.Equal(t, `"custom"`, b.bundleDefines()["VERSION"], "explicit defines should win")
+}
+
+func TestGitInfo(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": `print(GIT_COMMIT, GIT_TAG, GIT_DIRTY)`})
+	b.SetGitInfo(&GitInfo{Commit: "3f2a9c1d", Tag: "v1.4.0", Dirty: true})
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "-- Git: 3f2a9c1d (v1.4.0, dirty)\n")
+	assert.Contains(t, result, "-- Defines\n"+
+		"local GIT_COMMIT = \"3f2a9c1d\"\n"+
+		"local GIT_DIRTY = true\n"+
+		"local GIT_TAG = \"v1.4.0\"\n")
+	assert.Equal(t, &GitInfo{Commit: "3f2a9c1d", Tag: "v1.4.0", Dirty: true}, b.Manifest("main", "dev", false).Git)
+
+	b.SetGitInfo(&GitInfo{Commit: "3f2a9c1d"})
+	assert.Equal(t, "3f2a9c1d", b.gitInfo.String())
+	assert.Equal(t, "nil", b.bundleDefines()["GIT_TAG"], "untagged commits should define GIT_TAG as nil")
+	assert.Equal(t, "false", b.bundleDefines()["GIT_DIRTY"])
+
+	require.NoError(t, b.SetDefines(map[string]string{"GIT_TAG": "manual"}))
+	assert.Equal(t, `"manual"`, b.bundleDefines()["GIT_TAG"], "explicit defines should win")
+
+	b.SetGitInfo(nil)
+	result, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "-- Git:")
+	assert.Nil(t, b.Manifest("main", "dev", false).Git)
+}
+
+func TestSetDefines_Invalid(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	for _, name := range []string{"1abc", "my-flag", "end", ""} {
+		assert.Error(t, b.SetDefines(map[string]string{name: "1"}), "define %q should be rejected", name)
+	}
+	assert.Error(t, b.SetVersion("1.0\n0"))
+}
+
+func TestLuaLiteral(t *testing.T) {
+	assert.Equal(t, "true", luaLiteral("true"))
+	assert.Equal(t, "nil", luaLiteral("nil"))
+	assert.Equal(t, "-1.5e3", luaLiteral("-1.5e3"))
+	assert.Equal(t, "0xFF", luaLiteral("0xFF"))
+	assert.Equal(t, `"1.4.0"`, luaLiteral("1.4.0"))
+	assert.Equal(t, `"a\\b\nc"`, luaLiteral("a\\b\nc"))
+}
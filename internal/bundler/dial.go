@@ -0,0 +1,17 @@
+//go:build !js
+
+package bundler
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// dialContext returns the dialer for remote downloads
+func dialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+}
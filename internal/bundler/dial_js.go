@@ -0,0 +1,13 @@
+package bundler
+
+import (
+	"context"
+	"net"
+)
+
+// dialContext returns no dialer under WebAssembly: a transport without one
+// sends requests through the browser's fetch API, the only network access
+// there
+func dialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return nil
+}
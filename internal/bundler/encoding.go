@@ -0,0 +1,155 @@
+package bundler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Source encodings, for files that are not valid UTF-8
+const (
+	EncodingAuto  = "auto"  // read files that are not UTF-8 as Windows-1252, with a warning
+	EncodingUTF8  = "utf-8" // fail on files that are not UTF-8
+	EncodingBytes = "bytes" // keep the bytes of files as they are
+)
+
+// Encodings lists the supported source encodings
+var Encodings = []string{EncodingAuto, EncodingUTF8, EncodingBytes}
+
+// Line endings of the bundle
+const (
+	LineEndingsLF   = "lf"
+	LineEndingsCRLF = "crlf"
+)
+
+// LineEndings lists the supported line endings
+var LineEndings = []string{LineEndingsLF, LineEndingsCRLF}
+
+// SetSourceEncoding sets how source files that are not valid UTF-8, such as
+// ones saved as Windows-1252 by older Windows editors, are read. Byte order
+// marks are dropped and UTF-16 files transcoded whatever the encoding. An
+// empty encoding selects auto.
+func (b *Bundler) SetSourceEncoding(encoding string) error {
+	if encoding == "" {
+		encoding = EncodingAuto
+	}
+	if !containsString(Encodings, encoding) {
+		return fmt.Errorf("invalid source encoding %q (expected one of: %s)", encoding, strings.Join(Encodings, ", "))
+	}
+	b.sourceEncoding = encoding
+	return nil
+}
+
+// SetLineEndings sets the line endings of the bundle. Sources are read with
+// any line endings; an empty value selects lf.
+func (b *Bundler) SetLineEndings(endings string) error {
+	if endings == "" {
+		endings = LineEndingsLF
+	}
+	if !containsString(LineEndings, endings) {
+		return fmt.Errorf("invalid line endings %q (expected one of: %s)", endings, strings.Join(LineEndings, ", "))
+	}
+	b.lineEndings = endings
+	return nil
+}
+
+// readSource reads a Lua source file as normalized UTF-8 text
+func (b *Bundler) readSource(name string) (string, error) {
+	content, err := readString(b.fs, name)
+	if err != nil {
+		return "", err
+	}
+	return b.normalizeSource(b.displaySource(name), content)
+}
+
+// normalizeSource drops the byte order mark of content, transcodes it to
+// UTF-8 and turns its line endings into \n. Lua reads \r\n and \r as one
+// line break, in long strings too, so this changes neither what the code
+// does nor its line numbers.
+func (b *Bundler) normalizeSource(name, content string) (string, error) {
+	switch {
+	case strings.HasPrefix(content, "\xef\xbb\xbf"):
+		content = content[3:]
+	case strings.HasPrefix(content, "\xff\xfe"):
+		content = decodeUTF16(content[2:], binary.LittleEndian)
+	case strings.HasPrefix(content, "\xfe\xff"):
+		content = decodeUTF16(content[2:], binary.BigEndian)
+	}
+
+	if b.sourceEncoding != EncodingBytes && !utf8.ValidString(content) {
+		line := invalidUTF8Line(content)
+		if b.sourceEncoding == EncodingUTF8 {
+			return "", fmt.Errorf("%s is not valid UTF-8 (line %d); save it as UTF-8, or pass --source-encoding auto", name, line)
+		}
+		b.warnf("%s is not valid UTF-8 (line %d); it was read as Windows-1252", name, line)
+		content = decodeWindows1252(content)
+	}
+
+	if strings.IndexByte(content, '\r') >= 0 {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+		content = strings.ReplaceAll(content, "\r", "\n")
+	}
+	return content, nil
+}
+
+// applyLineEndings writes the bundle's line breaks as SetLineEndings chose
+func (b *Bundler) applyLineEndings(bundle string) string {
+	if strings.Contains(bundle, "\r\n") {
+		// Banners and footers come from config files as written
+		bundle = strings.ReplaceAll(bundle, "\r\n", "\n")
+	}
+	if b.lineEndings == LineEndingsCRLF {
+		bundle = strings.ReplaceAll(bundle, "\n", "\r\n")
+	}
+	return bundle
+}
+
+// invalidUTF8Line returns the line of the first byte of content that is not
+// part of a UTF-8 sequence
+func invalidUTF8Line(content string) int {
+	for i, r := range content {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(content[i:]); size == 1 {
+				return strings.Count(content[:i], "\n") + 1
+			}
+		}
+	}
+	return 0
+}
+
+// decodeUTF16 decodes UTF-16 text with the given byte order
+func decodeUTF16(content string, order binary.ByteOrder) string {
+	data := []byte(content)
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// windows1252 maps the bytes 0x80 to 0x9f of Windows-1252, where it differs
+// from Latin-1; bytes it leaves undefined map to the control characters
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// decodeWindows1252 decodes Windows-1252 text
+func decodeWindows1252(content string) string {
+	var out strings.Builder
+	out.Grow(len(content) + len(content)/8)
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c < 0x80:
+			out.WriteByte(c)
+		case c < 0xa0:
+			out.WriteRune(windows1252[c-0x80])
+		default:
+			out.WriteRune(rune(c))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,92 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEncodingBundler(t *testing.T, files MemoryFS) *Bundler {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(files)
+	return b
+}
+
+func TestSetSourceEncoding(t *testing.T) {
+	b := newEncodingBundler(t, MemoryFS{})
+	assert.Equal(t, EncodingAuto, b.sourceEncoding)
+	require.NoError(t, b.SetSourceEncoding(EncodingUTF8))
+	assert.Equal(t, EncodingUTF8, b.sourceEncoding)
+	require.NoError(t, b.SetSourceEncoding(""))
+	assert.Equal(t, EncodingAuto, b.sourceEncoding)
+	assert.EqualError(t, b.SetSourceEncoding("latin1"), "invalid source encoding \"latin1\" (expected one of: auto, utf-8, bytes)")
+
+	assert.Equal(t, LineEndingsLF, b.lineEndings)
+	require.NoError(t, b.SetLineEndings(LineEndingsCRLF))
+	assert.Equal(t, LineEndingsCRLF, b.lineEndings)
+	assert.Error(t, b.SetLineEndings("cr"))
+}
+
+func TestNormalizeSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", "print(1)\n", "print(1)\n"},
+		{"utf-8 bom", "\xef\xbb\xbfprint(\"é\")\n", "print(\"é\")\n"},
+		{"crlf", "local a = 1\r\nprint(a)\r\n", "local a = 1\nprint(a)\n"},
+		{"cr", "local a = 1\rprint(a)", "local a = 1\nprint(a)"},
+		{"utf-16le bom", "\xff\xfep\x00(\x00\xe9\x00)\x00\r\x00\n\x00", "p(é)\n"},
+		{"utf-16be bom", "\xfe\xff\x00p\x00(\x00\xe9\x00)", "p(é)"},
+		{"windows-1252", "print(\"caf\xe9 \x80\x96\")\r\n", "print(\"café €–\")\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newEncodingBundler(t, MemoryFS{})
+			got, err := b.normalizeSource("main.lua", tt.content)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNormalizeSource_InvalidUTF8(t *testing.T) {
+	b := newEncodingBundler(t, MemoryFS{})
+	_, err := b.normalizeSource("main.lua", "print(1)\nprint(\"caf\xe9\")\n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.lua is not valid UTF-8 (line 2); it was read as Windows-1252"}, b.GetWarnings())
+
+	require.NoError(t, b.SetSourceEncoding(EncodingUTF8))
+	_, err = b.normalizeSource("main.lua", "print(1)\nprint(\"caf\xe9\")\n")
+	assert.EqualError(t, err, "main.lua is not valid UTF-8 (line 2); save it as UTF-8, or pass --source-encoding auto")
+
+	require.NoError(t, b.SetSourceEncoding(EncodingBytes))
+	got, err := b.normalizeSource("main.lua", "print(\"\xff\")\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "print(\"\xff\")\n", got, "bytes are kept, line endings still normalized")
+}
+
+func TestBundle_LineEndings(t *testing.T) {
+	files := MemoryFS{
+		"main.lua": "\xef\xbb\xbflocal util = require(\"util\")\r\nprint(util.name)\r\n",
+		"util.lua": "return { name = \"caf\xe9\" }\r\n",
+	}
+	b := newEncodingBundler(t, files)
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "\r")
+	assert.NotContains(t, out, "\xef\xbb\xbf")
+	assert.Contains(t, out, "local util = loadModule(\"util\")\nprint(util.name)\n")
+	assert.Contains(t, out, "return { name = \"café\" }")
+
+	b = newEncodingBundler(t, files)
+	require.NoError(t, b.SetLineEndings(LineEndingsCRLF))
+	out, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, "-- Bundled Lua Script\r\n")
+	assert.Contains(t, out, "local util = loadModule(\"util\")\r\nprint(util.name)\r\n")
+	assert.NotContains(t, out, "\r\r")
+}
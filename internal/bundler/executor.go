@@ -0,0 +1,311 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Executors in compatibility reports
+const (
+	ExecutorSynapse    = "synapse"
+	ExecutorScriptWare = "scriptware"
+	ExecutorFluxus     = "fluxus"
+	ExecutorKRNL       = "krnl"
+)
+
+// Executors lists the executors compatibility is reported for, in report
+// column order
+var Executors = []string{ExecutorSynapse, ExecutorScriptWare, ExecutorFluxus, ExecutorKRNL}
+
+// ExecutorNames are the display names of Executors
+var ExecutorNames = map[string]string{
+	ExecutorSynapse:    "Synapse X",
+	ExecutorScriptWare: "Script-Ware",
+	ExecutorFluxus:     "Fluxus",
+	ExecutorKRNL:       "KRNL",
+}
+
+var allExecutors = Executors
+
+// executorAPIs maps executor-specific globals, and fields of executor
+// libraries such as syn.request, to the executors providing them, following
+// each executor's documented API. A library name alone, such as syn, stands
+// for fields not listed separately.
+var executorAPIs = map[string][]string{
+	// Environment and closures
+	"getgenv":             allExecutors,
+	"getrenv":             allExecutors,
+	"getsenv":             allExecutors,
+	"getreg":              allExecutors,
+	"getgc":               allExecutors,
+	"getrawmetatable":     allExecutors,
+	"setreadonly":         allExecutors,
+	"isreadonly":          allExecutors,
+	"make_writeable":      {ExecutorSynapse},
+	"hookfunction":        allExecutors,
+	"hookmetamethod":      allExecutors,
+	"newcclosure":         allExecutors,
+	"iscclosure":          allExecutors,
+	"islclosure":          allExecutors,
+	"checkcaller":         allExecutors,
+	"getnamecallmethod":   allExecutors,
+	"getcallingscript":    allExecutors,
+	"getconnections":      allExecutors,
+	"getscriptclosure":    {ExecutorSynapse, ExecutorScriptWare, ExecutorFluxus},
+	"decompile":           {ExecutorSynapse, ExecutorScriptWare, ExecutorKRNL},
+	"identifyexecutor":    allExecutors,
+	"is_synapse_function": {ExecutorSynapse},
+	"isexecutorclosure":   {ExecutorScriptWare, ExecutorFluxus, ExecutorKRNL},
+	"debug.getupvalue":    allExecutors,
+	"debug.getupvalues":   allExecutors,
+	"debug.setupvalue":    allExecutors,
+	"debug.getconstant":   allExecutors,
+	"debug.getconstants":  allExecutors,
+	"debug.setconstant":   allExecutors,
+	"debug.getproto":      allExecutors,
+	"debug.getprotos":     allExecutors,
+	"debug.getstack":      allExecutors,
+
+	// Input and interaction
+	"fireclickdetector":   allExecutors,
+	"firetouchinterest":   allExecutors,
+	"fireproximityprompt": {ExecutorSynapse, ExecutorScriptWare, ExecutorFluxus},
+	"mouse1click":         allExecutors,
+	"mousemoverel":        allExecutors,
+	"keypress":            allExecutors,
+	"setclipboard":        allExecutors,
+	"setfpscap":           allExecutors,
+
+	// HTTP and teleports
+	"syn.request":           {ExecutorSynapse},
+	"http.request":          {ExecutorScriptWare},
+	"request":               {ExecutorScriptWare, ExecutorFluxus, ExecutorKRNL},
+	"http_request":          {ExecutorFluxus, ExecutorKRNL},
+	"syn.queue_on_teleport": {ExecutorSynapse},
+	"queue_on_teleport":     {ExecutorScriptWare, ExecutorFluxus, ExecutorKRNL},
+
+	// UI and drawing
+	"syn.protect_gui": {ExecutorSynapse},
+	"gethui":          {ExecutorScriptWare, ExecutorFluxus, ExecutorKRNL},
+	"Drawing.new":     allExecutors,
+	"rconsoleprint":   {ExecutorSynapse, ExecutorScriptWare, ExecutorFluxus},
+
+	// Files
+	"readfile":   allExecutors,
+	"writefile":  allExecutors,
+	"appendfile": allExecutors,
+	"isfile":     allExecutors,
+	"isfolder":   allExecutors,
+	"makefolder": allExecutors,
+	"listfiles":  allExecutors,
+	"delfile":    allExecutors,
+
+	// Libraries
+	"syn":   {ExecutorSynapse},
+	"crypt": {ExecutorScriptWare, ExecutorFluxus, ExecutorKRNL},
+}
+
+// ExecutorAPIUse is an executor-specific API the bundle uses
+type ExecutorAPIUse struct {
+	API       string   // global or library field, such as syn.request
+	Modules   []string // files using it
+	Executors []string // executors providing it
+	Optional  bool     // only used where code checks for it first
+}
+
+// ExecutorRequirement is an API, or a set of alternatives the code falls
+// back between as in syn and syn.request or request, that an executor must
+// provide for the bundle to run
+type ExecutorRequirement struct {
+	APIs      []string
+	Executors []string // executors providing any of APIs
+}
+
+// ExecutorReport is the executor compatibility of a bundle
+type ExecutorReport struct {
+	APIs         []ExecutorAPIUse
+	Requirements []ExecutorRequirement
+}
+
+// Supported returns the executors meeting every requirement
+func (r ExecutorReport) Supported() []string {
+	var supported []string
+	for _, executor := range Executors {
+		if len(r.Missing(executor)) == 0 {
+			supported = append(supported, executor)
+		}
+	}
+	return supported
+}
+
+// Missing returns the requirements executor does not meet
+func (r ExecutorReport) Missing(executor string) []ExecutorRequirement {
+	var missing []ExecutorRequirement
+	for _, req := range r.Requirements {
+		if !containsString(req.Executors, executor) {
+			missing = append(missing, req)
+		}
+	}
+	return missing
+}
+
+// ExecutorCompatibility reports the executor-specific APIs the entry file
+// and modules use and the executors providing them. APIs in one chain of or
+// expressions, or in one if statement probing for APIs, are alternatives;
+// such an if without else is optional, as the code runs without them. Call
+// after Resolve. Files the parser rejects are skipped.
+func (b *Bundler) ExecutorCompatibility() ExecutorReport {
+	keys := append([]string{b.entryFile}, sortedKeys(b.modules)...)
+
+	users := make(map[string]map[string]bool)
+	required := make(map[string]bool)
+	requirements := make(map[string][]string)
+	for _, key := range keys {
+		source, _ := b.GetSource(key)
+		for _, group := range executorAPIGroups(source) {
+			for _, api := range group.apis {
+				if users[api] == nil {
+					users[api] = make(map[string]bool)
+				}
+				users[api][key] = true
+				if !group.optional {
+					required[api] = true
+				}
+			}
+			if !group.optional {
+				requirements[strings.Join(group.apis, " | ")] = group.apis
+			}
+		}
+	}
+
+	var report ExecutorReport
+	for _, api := range sortedKeys(users) {
+		report.APIs = append(report.APIs, ExecutorAPIUse{API: api, Modules: sortedKeys(users[api]), Executors: executorAPIs[api], Optional: !required[api]})
+	}
+	for _, id := range sortedKeys(requirements) {
+		req := ExecutorRequirement{APIs: requirements[id]}
+		for _, executor := range Executors {
+			for _, api := range req.APIs {
+				if containsString(executorAPIs[api], executor) {
+					req.Executors = append(req.Executors, executor)
+					break
+				}
+			}
+		}
+		report.Requirements = append(report.Requirements, req)
+	}
+	return report
+}
+
+// apiGroup is a set of executor APIs code uses together: one API, the
+// alternatives of an or chain, or those of an if statement probing for APIs
+type apiGroup struct {
+	apis     []string
+	optional bool // an if without else guards them
+}
+
+// executorAPIGroups returns the executor APIs src uses, grouped into
+// alternatives
+func executorAPIGroups(src string) []apiGroup {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return nil
+	}
+
+	var groups []apiGroup
+	seen := make(map[string]bool)
+	add := func(apis map[string]bool, optional bool) {
+		group := apiGroup{apis: sortedKeys(apis), optional: optional}
+		id := fmt.Sprintf("%s/%t", strings.Join(group.apis, " | "), optional)
+		if len(group.apis) > 0 && !seen[id] {
+			seen[id] = true
+			groups = append(groups, group)
+		}
+	}
+
+	consumed := make(map[parser.Node]bool)
+	var collect func(n parser.Node, apis map[string]bool) bool
+	collect = func(n parser.Node, apis map[string]bool) bool {
+		if apis == nil {
+			optional := false
+			switch s := n.(type) {
+			case *parser.BinaryExpr:
+				if s.Op != "or" {
+					break
+				}
+				chain := make(map[string]bool)
+				parser.Walk(s, func(m parser.Node) bool { return collect(m, chain) })
+				add(chain, false)
+				return false
+			case *parser.IfStmt:
+				if !probesExecutorAPI(s.Conds) {
+					break
+				}
+				optional = s.Else == nil
+				branches := make(map[string]bool)
+				parser.Walk(s, func(m parser.Node) bool { return collect(m, branches) })
+				add(branches, optional)
+				return false
+			}
+		}
+		if consumed[n] {
+			return true
+		}
+		api := executorAPI(n, consumed)
+		if api == "" {
+			return true
+		}
+		if apis != nil {
+			apis[api] = true
+		} else {
+			add(map[string]bool{api: true}, false)
+		}
+		return true
+	}
+	parser.Walk(chunk, func(n parser.Node) bool {
+		return collect(n, nil)
+	})
+
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.Join(groups[i].apis, " ") < strings.Join(groups[j].apis, " ")
+	})
+	return groups
+}
+
+// probesExecutorAPI reports whether a condition mentions an executor API,
+// as in if syn then or if hookmetamethod then
+func probesExecutorAPI(conds []parser.Expr) bool {
+	found := false
+	for _, cond := range conds {
+		parser.Walk(cond, func(n parser.Node) bool {
+			if executorAPI(n, map[parser.Node]bool{}) != "" {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// executorAPI returns the executor API n names: a library field such as
+// syn.request, whose library name is then marked consumed, or a global
+func executorAPI(n parser.Node, consumed map[parser.Node]bool) string {
+	switch e := n.(type) {
+	case *parser.FieldExpr:
+		if id, ok := e.X.(*parser.Ident); ok && id.Binding != nil && id.Binding.Global() {
+			name := id.Name + "." + e.Name.Value
+			if _, ok := executorAPIs[name]; ok {
+				consumed[id] = true
+				return name
+			}
+		}
+	case *parser.Ident:
+		if _, ok := executorAPIs[e.Name]; ok && e.Binding != nil && e.Binding.Global() {
+			return e.Name
+		}
+	}
+	return ""
+}
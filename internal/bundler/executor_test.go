@@ -0,0 +1,51 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutorCompatibility(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "local net = require(\"net\")\nlocal old = hookfunction(print, function() end)\nif syn then syn.protect_gui(gui) end\n",
+		"net.lua":  "local request = syn and syn.request or http_request or request\nreturn { get = function(url) return request({ Url = url }) end, clip = setclipboard }\n",
+	})
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	report := b.ExecutorCompatibility()
+	var apis []string
+	for _, api := range report.APIs {
+		apis = append(apis, api.API)
+	}
+	assert.Equal(t, []string{"hookfunction", "http_request", "request", "setclipboard", "syn", "syn.protect_gui", "syn.request"}, apis)
+	assert.Equal(t, []string{"net"}, report.APIs[1].Modules)
+	assert.True(t, report.APIs[5].Optional, "APIs behind an if probing for them should be optional")
+	assert.False(t, report.APIs[6].Optional)
+
+	require.Len(t, report.Requirements, 3)
+	assert.Equal(t, ExecutorRequirement{APIs: []string{"hookfunction"}, Executors: Executors}, report.Requirements[0])
+	assert.Equal(t, []string{"http_request", "request", "syn", "syn.request"}, report.Requirements[1].APIs)
+	assert.Equal(t, Executors, report.Requirements[1].Executors, "alternatives should be met by any executor providing one of them")
+	assert.Equal(t, Executors, report.Supported())
+}
+
+func TestExecutorCompatibility_Missing(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "syn.request({ Url = url })\nlocal ui = gethui()\nlocal function getgenv() return _G end\ngetgenv()\n"})
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	report := b.ExecutorCompatibility()
+	require.Len(t, report.APIs, 2, "locals shadowing executor APIs should not count")
+	assert.Empty(t, report.Supported())
+	missing := report.Missing(ExecutorSynapse)
+	require.Len(t, missing, 1)
+	assert.Equal(t, []string{"gethui"}, missing[0].APIs)
+	assert.Equal(t, []string{"syn.request"}, report.Missing(ExecutorKRNL)[0].APIs)
+}
@@ -0,0 +1,56 @@
+package bundler
+
+import (
+	"sort"
+)
+
+// ExternalRequire is a require the bundle leaves to the runtime, such as a
+// Roblox instance path
+type ExternalRequire struct {
+	Path       string
+	RequiredBy []string // keys of the entry file and modules requiring it
+}
+
+// recordExternal notes that the module key requires an external path
+func (b *Bundler) recordExternal(path, key string) {
+	if b.externals == nil {
+		b.externals = make(map[string][]string)
+	}
+	for _, k := range b.externals[path] {
+		if k == key {
+			return
+		}
+	}
+	b.externals[path] = append(b.externals[path], key)
+}
+
+// ExternalRequires returns the external requires found while resolving,
+// sorted by path
+func (b *Bundler) ExternalRequires() []ExternalRequire {
+	externals := make([]ExternalRequire, 0, len(b.externals))
+	for path, keys := range b.externals {
+		requiredBy := append([]string(nil), keys...)
+		sort.Strings(requiredBy)
+		externals = append(externals, ExternalRequire{Path: path, RequiredBy: requiredBy})
+	}
+	sort.Slice(externals, func(i, j int) bool { return externals[i].Path < externals[j].Path })
+	return externals
+}
+
+// GetSource returns the unobfuscated source of the entry file or a module key
+func (b *Bundler) GetSource(key string) (string, bool) {
+	if key == b.entryFile {
+		return b.entryContent, true
+	}
+	content, ok := b.modules[key]
+	if !ok {
+		return "", false
+	}
+	// Local modules may be stored obfuscated; prefer the file as written
+	if source := b.moduleSource(key); !IsURL(source) {
+		if raw, err := b.fs.ReadFile(source); err == nil {
+			return string(raw), true
+		}
+	}
+	return content, true
+}
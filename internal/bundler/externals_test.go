@@ -0,0 +1,43 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalRequires(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte(`local Util = require(game.ReplicatedStorage.Util)
+local helper = require("helper")
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.lua"), []byte(`local Util = require(game.ReplicatedStorage.Util)
+local Net = require(ReplicatedStorage.Net)
+return {}
+`), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	assert.Equal(t, []ExternalRequire{
+		{Path: "ReplicatedStorage.Net", RequiredBy: []string{"helper"}},
+		{Path: "game.ReplicatedStorage.Util", RequiredBy: []string{entry, "helper"}},
+	}, b.ExternalRequires())
+
+	src, ok := b.GetSource("helper")
+	require.True(t, ok)
+	assert.Contains(t, src, "ReplicatedStorage.Net")
+
+	src, ok = b.GetSource(entry)
+	require.True(t, ok)
+	assert.Contains(t, src, `require("helper")`)
+
+	_, ok = b.GetSource("missing")
+	assert.False(t, ok)
+}
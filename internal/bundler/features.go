@@ -0,0 +1,116 @@
+package bundler
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var featureNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// SetFeatures selects the optional features of a build. features maps a
+// feature name to the modules only it uses, by require path or URL, or by a
+// path.Match pattern such as "esp.*". Modules of features not in enabled
+// are omitted as SetStubs omits them, unless an enabled feature lists them
+// too. Each feature is defined as FEATURE_<NAME>, true when enabled, unless
+// defined explicitly.
+func (b *Bundler) SetFeatures(features map[string][]string, enabled []string) error {
+	for name, modules := range features {
+		if !featureNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid feature %q: must be letters, digits, _ or -", name)
+		}
+		for _, pattern := range modules {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid module pattern %q in feature %s: %w", pattern, name, err)
+			}
+		}
+	}
+
+	on := make(map[string]bool, len(enabled))
+	for _, name := range enabled {
+		if _, ok := features[name]; !ok {
+			return fmt.Errorf("unknown feature %q (available: %s)", name, strings.Join(FeatureNames(features), ", "))
+		}
+		on[name] = true
+	}
+	b.features = features
+	b.enabledFeatures = on
+	return nil
+}
+
+// FeatureNames returns the sorted names of features
+func FeatureNames(features map[string][]string) []string {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetEnabledFeatures returns the sorted names of the enabled features
+func (b *Bundler) GetEnabledFeatures() []string {
+	var names []string
+	for name := range b.enabledFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// disabledFeature returns the disabled feature a module belongs to, or ""
+// when it is not left out
+func (b *Bundler) disabledFeature(key string) string {
+	disabled := ""
+	for _, name := range FeatureNames(b.features) {
+		if !featureHasModule(b.features[name], key) {
+			continue
+		}
+		if b.enabledFeatures[name] {
+			return ""
+		}
+		if disabled == "" {
+			disabled = name
+		}
+	}
+	return disabled
+}
+
+// featureHasModule reports whether a module matches one of a feature's
+// patterns
+func featureHasModule(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// featureDefineName returns the define of a feature, such as FEATURE_AUTO_FARM
+// for auto-farm
+func featureDefineName(name string) string {
+	return "FEATURE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// warnUnmatchedFeatures warns about patterns of enabled features matching
+// no module, which are usually misspelled. Disabled features are skipped:
+// the modules their omitted modules require are never resolved.
+func (b *Bundler) warnUnmatchedFeatures() {
+	for _, name := range b.GetEnabledFeatures() {
+		for _, pattern := range b.features[name] {
+			matched := false
+			for key := range b.modules {
+				if ok, _ := path.Match(pattern, key); ok {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				b.warnf("feature %s: %s matches no required module", name, pattern)
+			}
+		}
+	}
+}
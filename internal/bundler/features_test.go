@@ -0,0 +1,78 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var featureProject = MemoryFS{
+	"main.lua":       "local esp = require(\"esp.core\")\nlocal farm = require(\"farm\")\nlocal ui = require(\"ui\")\n",
+	"esp/core.lua":   `return require("esp.render")`,
+	"esp/render.lua": `return { draw = "esp" }`,
+	"farm.lua":       `return { run = "farm" }`,
+	"ui.lua":         `return {}`,
+}
+
+var featureConfig = map[string][]string{
+	"esp":       {"esp.*"},
+	"auto-farm": {"farm"},
+}
+
+func TestSetFeatures(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(featureProject)
+	require.NoError(t, b.SetFeatures(featureConfig, []string{"esp"}))
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, `draw = "esp"`, "enabled feature modules should be bundled")
+	assert.NotContains(t, out, `run = "farm"`, "disabled feature modules should be omitted")
+	assert.Equal(t, "return {}", b.GetModules()["farm"])
+	assert.Contains(t, out, "local FEATURE_AUTO_FARM = false\nlocal FEATURE_ESP = true\n")
+	assert.Equal(t, []string{"esp"}, b.Manifest("hub", "dev", false).Features)
+	assert.Empty(t, b.GetWarnings())
+
+	b, err = NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(featureProject)
+	require.NoError(t, b.SetFeatures(featureConfig, nil))
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Equal(t, "return {}", b.GetModules()["esp.core"])
+	assert.NotContains(t, b.GetModules(), "esp.render", "modules only omitted modules require should not be resolved")
+	assert.Contains(t, b.GetModules(), "ui", "modules outside features should always be bundled")
+}
+
+func TestSetFeatures_SharedModule(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(featureProject)
+	require.NoError(t, b.SetFeatures(map[string][]string{"a": {"ui"}, "b": {"ui"}}, []string{"b"}))
+	assert.Equal(t, "", b.disabledFeature("ui"), "a module of any enabled feature should be kept")
+
+	require.NoError(t, b.SetFeatures(map[string][]string{"a": {"ui"}, "b": {"ui"}}, nil))
+	assert.Equal(t, "a", b.disabledFeature("ui"))
+}
+
+func TestSetFeatures_Invalid(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, b.SetFeatures(featureConfig, []string{"aimbot"}), "unknown feature \"aimbot\" (available: auto-farm, esp)")
+	assert.Error(t, b.SetFeatures(map[string][]string{"bad name": nil}, nil))
+	assert.Error(t, b.SetFeatures(map[string][]string{"esp": {"esp.["}}, nil))
+}
+
+func TestSetFeatures_UnmatchedPattern(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(featureProject)
+	require.NoError(t, b.SetFeatures(map[string][]string{"esp": {"esp.*", "esp.rendr"}}, []string{"esp"}))
+
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature esp: esp.rendr matches no required module"}, b.GetWarnings())
+}
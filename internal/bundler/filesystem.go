@@ -0,0 +1,197 @@
+package bundler
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSystem is where the bundler reads local sources: the entry file,
+// required modules and their target variants
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// fileLister is implemented by file systems that can list a directory's
+// files, which expanding globs needs
+type fileLister interface {
+	// ListFiles returns every file under dir, recursively, by
+	// slash-separated path relative to dir
+	ListFiles(dir string) ([]string, error)
+}
+
+// stringReader is implemented by file systems that can read a file
+// straight into a string, without a byte slice copied into it after
+type stringReader interface {
+	ReadString(name string) (string, error)
+}
+
+// readString reads a file of fsys as a string
+func readString(fsys FileSystem, name string) (string, error) {
+	if r, ok := fsys.(stringReader); ok {
+		return r.ReadString(name)
+	}
+	data, err := fsys.ReadFile(name)
+	return string(data), err
+}
+
+// osFileSystem reads from the disk
+type osFileSystem struct{}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+// ReadString reads a file in chunks into a buffer of the file's size, so
+// even a multi-megabyte data file is held in memory once
+func (osFileSystem) ReadString(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var content strings.Builder
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		content.Grow(int(info.Size()))
+	}
+	if _, err := io.Copy(&content, f); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+func (osFileSystem) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (osFileSystem) ListFiles(dir string) ([]string, error) {
+	return listFiles(os.DirFS(dir), ".")
+}
+
+// MemoryFS is a FileSystem holding file contents by slash-separated path
+// relative to the project root, such as "main.lua" or "utils/log.lua"
+type MemoryFS map[string]string
+
+// ReadFile returns the contents of a file. Absolute paths are looked up
+// relative to the root.
+func (m MemoryFS) ReadFile(name string) ([]byte, error) {
+	content, ok := m[memoryPath(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return []byte(content), nil
+}
+
+// ReadString returns the contents of a file without copying them
+func (m MemoryFS) ReadString(name string) (string, error) {
+	content, ok := m[memoryPath(name)]
+	if !ok {
+		return "", &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return content, nil
+}
+
+// Stat describes a file
+func (m MemoryFS) Stat(name string) (fs.FileInfo, error) {
+	content, ok := m[memoryPath(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memoryFileInfo{name: path.Base(memoryPath(name)), size: int64(len(content))}, nil
+}
+
+// ListFiles returns the files under dir
+func (m MemoryFS) ListFiles(dir string) ([]string, error) {
+	prefix := memoryPath(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+	var files []string
+	for name := range m {
+		if rel := strings.TrimPrefix(memoryPath(name), prefix); rel != memoryPath(name) || prefix == "" {
+			files = append(files, rel)
+		}
+	}
+	return files, nil
+}
+
+// memoryPath returns the MemoryFS key of a path
+func memoryPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// ioFileSystem adapts an fs.FS, whose paths are unrooted and
+// slash-separated, to a FileSystem
+type ioFileSystem struct {
+	fsys fs.FS
+}
+
+func (f ioFileSystem) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, ioPath(name))
+}
+
+func (f ioFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, ioPath(name))
+}
+
+func (f ioFileSystem) ListFiles(dir string) ([]string, error) {
+	return listFiles(f.fsys, ioPath(dir))
+}
+
+// listFiles returns the files under dir in fsys, relative to dir
+func listFiles(fsys fs.FS, dir string) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel := name
+		if dir != "." {
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+// ioPath returns the fs.FS path of a path
+func ioPath(name string) string {
+	if p := memoryPath(name); p != "" {
+		return p
+	}
+	return "."
+}
+
+// memoryFileInfo describes a MemoryFS file
+type memoryFileInfo struct {
+	name string
+	size int64
+}
+
+func (i memoryFileInfo) Name() string       { return i.name }
+func (i memoryFileInfo) Size() int64        { return i.size }
+func (i memoryFileInfo) Mode() fs.FileMode  { return 0644 }
+func (i memoryFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memoryFileInfo) IsDir() bool        { return false }
+func (i memoryFileInfo) Sys() interface{}   { return nil }
+
+// SetFileSystem makes the bundler read local sources from fsys instead of
+// the disk, for builds without one such as the WebAssembly playground. The
+// root of fsys stands in for the current directory: a relative entry file
+// is looked up in it and require paths resolve against it.
+func (b *Bundler) SetFileSystem(fsys FileSystem) {
+	b.fs = fsys
+	if !IsURL(b.entryFile) && !filepath.IsAbs(b.entryFile) {
+		b.baseDir = filepath.Dir(filepath.Join(string(filepath.Separator), b.entryFile))
+	}
+}
+
+// SetFS makes the bundler read local sources from fsys, such as an
+// embed.FS, a zip.Reader or an fstest.MapFS. Paths are rooted as with
+// SetFileSystem.
+func (b *Bundler) SetFS(fsys fs.FS) {
+	b.SetFileSystem(ioFileSystem{fsys: fsys})
+}
@@ -0,0 +1,104 @@
+package bundler
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryFS(t *testing.T) {
+	m := MemoryFS{"utils/log.lua": "return {}"}
+
+	for _, name := range []string{"utils/log.lua", "/utils/log.lua", "./utils/../utils/log.lua", filepath.FromSlash("/utils/log.lua")} {
+		data, err := m.ReadFile(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, "return {}", string(data))
+	}
+
+	info, err := m.Stat("/utils/log.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "log.lua", info.Name())
+	assert.Equal(t, int64(9), info.Size())
+
+	_, err = m.ReadFile("utils/missing.lua")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = m.Stat("utils")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestSetFS(t *testing.T) {
+	files := fstest.MapFS{
+		"main.lua":         {Data: []byte(`local helper = require("utils.helper")`)},
+		"utils/helper.lua": {Data: []byte(`return {}`)},
+	}
+
+	t.Run("map", func(t *testing.T) {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFS(files)
+
+		out, err := b.Bundle(false)
+		require.NoError(t, err)
+		assert.Contains(t, out, `EmbeddedModules["utils.helper"]`)
+	})
+
+	t.Run("zip archive", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, file := range files {
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write(file.Data)
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFS(zr)
+
+		out, err := b.Bundle(false)
+		require.NoError(t, err)
+		assert.Contains(t, out, `EmbeddedModules["utils.helper"]`)
+	})
+
+	t.Run("missing module", func(t *testing.T) {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFS(fstest.MapFS{"main.lua": files["main.lua"]})
+
+		_, err = b.Bundle(false)
+		assert.Error(t, err)
+	})
+}
+
+func TestListFiles(t *testing.T) {
+	m := MemoryFS{"main.lua": "", "client/a.lua": "", "client/ui/b.lua": "", "clients.lua": ""}
+	files, err := m.ListFiles("/client")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.lua", "ui/b.lua"}, files)
+	files, err = m.ListFiles("/")
+	require.NoError(t, err)
+	assert.Len(t, files, 4)
+
+	iofs := ioFileSystem{fsys: fstest.MapFS{"client/a.lua": {}, "client/ui/b.lua": {}, "main.lua": {}}}
+	files, err = iofs.ListFiles("/client")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.lua", "ui/b.lua"}, files)
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ui", "b.lua"), nil, 0644))
+	files, err = osFileSystem{}.ListFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ui/b.lua"}, files)
+}
@@ -0,0 +1,249 @@
+package bundler
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+)
+
+// FXManifest is the manifest of a FiveM resource
+const FXManifest = "fxmanifest.lua"
+
+// Sides of a FiveM resource a manifest entry is bundled for
+const (
+	SideClient = "client"
+	SideServer = "server"
+)
+
+// Sides lists the sides of a FiveM resource
+var Sides = []string{SideClient, SideServer}
+
+// FXScripts holds the script lists of a FiveM resource manifest
+type FXScripts struct {
+	Content string              // the manifest as written
+	Scripts map[string][]string // shared, client or server -> files and globs, in load order
+}
+
+// resource is the FiveM resource a manifest entry describes
+type resource struct {
+	manifest *FXScripts
+	files    []string // Lua files merged into the bundle, in load order
+}
+
+var (
+	// scriptDeclRegex matches the script declarations of a manifest:
+	// client_script 'a.lua', server_scripts { ... } or shared_script('a.lua')
+	scriptDeclRegex = regexp.MustCompile(`(?m)^[ \t]*(shared|client|server)_scripts?\s*(\{[^}]*\}|\(?\s*(?:'[^']*'|"[^"]*")\s*\)?)[ \t]*\n?`)
+	luaStringRegex  = regexp.MustCompile(`'([^']*)'|"([^"]*)"`)
+)
+
+// IsFXManifest reports whether path is a FiveM resource manifest, or the
+// __resource.lua of older resources
+func IsFXManifest(path string) bool {
+	base := filepath.Base(path)
+	return base == FXManifest || base == "__resource.lua"
+}
+
+// ParseFXManifest reads the script declarations of a resource manifest
+func ParseFXManifest(content string) *FXScripts {
+	m := &FXScripts{Content: content, Scripts: make(map[string][]string)}
+	for _, decl := range scriptDeclRegex.FindAllStringSubmatch(content, -1) {
+		for _, s := range luaStringRegex.FindAllStringSubmatch(decl[2], -1) {
+			m.Scripts[decl[1]] = append(m.Scripts[decl[1]], s[1]+s[2])
+		}
+	}
+	return m
+}
+
+// SideScripts returns the scripts side loads: the shared ones, then its own
+func (m *FXScripts) SideScripts(side string) []string {
+	return append(append([]string{}, m.Scripts["shared"]...), m.Scripts[side]...)
+}
+
+// SetSide sets the side of a FiveM resource a manifest entry is bundled for
+func (b *Bundler) SetSide(side string) error {
+	if !containsString(Sides, side) {
+		return fmt.Errorf("invalid side %q (expected %s)", side, strings.Join(Sides, " or "))
+	}
+	b.side = side
+	return nil
+}
+
+// ForSide returns a bundler with the settings of b and none of its build
+// state, for another side of a manifest entry
+func (b *Bundler) ForSide(side string) (*Bundler, error) {
+	c := *b
+	c.modules = make(map[string]string)
+	c.httpModules = make(map[string]bool)
+	c.moduleSources = make(map[string]string)
+	c.entryContent = ""
+	c.polyfills = nil
+	c.warnings = nil
+	c.diagnostics = nil
+	c.runtimeFetches = nil
+	c.graph = nil
+	c.sourceMap = nil
+	c.buildID = ""
+	c.externals = nil
+	c.requestShimUsed = false
+	c.stateUsed = false
+	c.apis = nil
+	c.addon = nil
+	c.includes = nil
+	c.resource = nil
+	c.ambiguities = nil
+	c.requireCalls = nil
+	c.requireScanErrors = nil
+	c.noMemoize = make(map[string]bool, len(b.noMemoize))
+	for key := range b.noMemoize {
+		c.noMemoize[key] = true
+	}
+	if b.obfuscator != nil {
+		c.obfuscator, _ = obfuscator.NewPipeline(b.obfuscator.Passes())
+	}
+	c.deriveRuleObfuscators()
+	return &c, c.SetSide(side)
+}
+
+// resolveManifest reads the scripts the manifest entry loads on the
+// bundler's side, expanding globs, and returns them merged into one chunk.
+// Scripts of other resources (@resource/file.lua) stay in the manifest.
+func (b *Bundler) resolveManifest(content string) (string, error) {
+	b.resource = &resource{manifest: ParseFXManifest(content)}
+
+	var out strings.Builder
+	for _, entry := range b.resource.manifest.SideScripts(b.side) {
+		if strings.HasPrefix(entry, "@") {
+			continue
+		}
+		files, err := b.expandGlob(entry)
+		if err != nil {
+			return "", err
+		}
+		if len(files) == 0 {
+			return "", fmt.Errorf("failed to read %s listed in %s: file does not exist", entry, b.displaySource(b.entryFile))
+		}
+		for _, file := range files {
+			if containsString(b.resource.files, file) || !strings.EqualFold(filepath.Ext(file), ".lua") {
+				continue
+			}
+			data, err := b.readSource(file)
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			b.resource.files = append(b.resource.files, file)
+			key := b.displaySource(file)
+			if b.verbose {
+				b.logf("📄 Processed %s script: %s\n", b.side, key)
+			}
+			if err := b.processFile(key, file, data, 0); err != nil {
+				return "", err
+			}
+			writeMergedFile(&out, key, data)
+		}
+	}
+	return out.String(), nil
+}
+
+// expandGlob returns the files matching a manifest entry, which may use *
+// within a directory and ** across directories, in name order
+func (b *Bundler) expandGlob(entry string) ([]string, error) {
+	if !strings.ContainsAny(entry, "*?") {
+		file := filepath.Join(b.baseDir, filepath.FromSlash(entry))
+		if _, err := b.fs.Stat(file); err != nil {
+			return nil, nil
+		}
+		return []string{file}, nil
+	}
+
+	lister, ok := b.fs.(fileLister)
+	if !ok {
+		return nil, fmt.Errorf("cannot expand %s: the file system cannot list files", entry)
+	}
+	names, err := lister.ListFiles(b.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand %s: %w", entry, err)
+	}
+	pattern := globRegexp(entry)
+	var files []string
+	for _, name := range names {
+		if pattern.MatchString(name) {
+			files = append(files, filepath.Join(b.baseDir, filepath.FromSlash(name)))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// globRegexp compiles a manifest glob: * and ? stay within a directory,
+// ** spans directories
+func globRegexp(glob string) *regexp.Regexp {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			re.WriteString("[^/]*")
+		case glob[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String())
+}
+
+// ResourceManifest returns the manifest of the rebuilt resource: the
+// entry's manifest without its script declarations, followed by
+// declarations loading the scripts of other resources and then the bundle
+// of each side in bundles. It returns "" unless the entry is a manifest.
+func (b *Bundler) ResourceManifest(bundles map[string]string) string {
+	if b.resource == nil {
+		return ""
+	}
+	m := b.resource.manifest
+	var out strings.Builder
+	out.WriteString(strings.TrimRight(scriptDeclRegex.ReplaceAllString(m.Content, ""), "\n"))
+	out.WriteString("\n")
+	for _, kind := range append([]string{"shared"}, Sides...) {
+		var scripts []string
+		for _, entry := range m.Scripts[kind] {
+			if strings.HasPrefix(entry, "@") {
+				scripts = append(scripts, entry)
+			}
+		}
+		if bundle, ok := bundles[kind]; ok {
+			scripts = append(scripts, path.Clean(filepath.ToSlash(bundle)))
+		}
+		if len(scripts) == 0 {
+			continue
+		}
+		out.WriteString("\n" + kind + "_scripts {\n")
+		for _, s := range scripts {
+			fmt.Fprintf(&out, "    %s,\n", quoteLua(s))
+		}
+		out.WriteString("}\n")
+	}
+	return out.String()
+}
+
+// ResourceScripts returns the scripts a manifest entry loads on the
+// bundler's side, other resources' excluded, or nil for other entries
+func (b *Bundler) ResourceScripts() []string {
+	if b.resource == nil {
+		return nil
+	}
+	return b.resource.files
+}
@@ -0,0 +1,130 @@
+package bundler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFXManifest = `fx_version 'cerulean'
+game 'gta5'
+
+shared_scripts {
+    '@ox_lib/init.lua',
+    'config.lua',
+}
+client_scripts {
+    'client/**.lua',
+}
+server_script 'server/main.lua'
+client_script("client/zz_last.lua")
+
+files { 'html/index.html' }
+`
+
+func newResourceBundler(t *testing.T) *Bundler {
+	b, err := NewBundler("fxmanifest.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"fxmanifest.lua":     testFXManifest,
+		"config.lua":         "Config = { debug = true }\n",
+		"client/main.lua":    "local util = require(\"shared.util\")\nRegisterCommand(\"hello\", function() end)\n",
+		"client/ui/menu.lua": "print(\"menu\")\n",
+		"client/zz_last.lua": "print(\"last\")\n",
+		"server/main.lua":    "local password = \"secret\"\n",
+		"shared/util.lua":    "return {}\n",
+		"html/index.html":    "<html></html>\n",
+	})
+	require.NoError(t, b.SetTarget(TargetFiveM))
+	return b
+}
+
+func TestParseFXManifest(t *testing.T) {
+	m := ParseFXManifest(testFXManifest)
+	assert.Equal(t, []string{"@ox_lib/init.lua", "config.lua"}, m.Scripts["shared"])
+	assert.Equal(t, []string{"client/**.lua", "client/zz_last.lua"}, m.Scripts["client"])
+	assert.Equal(t, []string{"server/main.lua"}, m.Scripts["server"])
+	assert.Equal(t, []string{"@ox_lib/init.lua", "config.lua", "server/main.lua"}, m.SideScripts(SideServer))
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob, name string
+		want       bool
+	}{
+		{"client/*.lua", "client/main.lua", true},
+		{"client/*.lua", "client/ui/menu.lua", false},
+		{"client/**.lua", "client/ui/menu.lua", true},
+		{"client/**/*.lua", "client/main.lua", true},
+		{"client/**/*.lua", "client/ui/menu.lua", true},
+		{"client/?.lua", "client/a.lua", true},
+		{"client/*.lua", "clientXmain.lua", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, globRegexp(tt.glob).MatchString(tt.name), "%s ~ %s", tt.glob, tt.name)
+	}
+}
+
+func TestBundle_FiveMSides(t *testing.T) {
+	b := newResourceBundler(t)
+	client, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, client, "-- File: config.lua\ndo\n(function(...)\nConfig = { debug = true }\nend)()\nend\n")
+	assert.Contains(t, client, `local util = loadModule("shared.util")`)
+	assert.Less(t, strings.Index(client, "Config = "), strings.Index(client, "RegisterCommand"), "shared scripts should load first")
+	assert.Less(t, strings.Index(client, `print("menu")`), strings.Index(client, `print("last")`))
+	assert.NotContains(t, client, "secret", "server scripts must not reach the client bundle")
+	assert.Equal(t, []string{"/config.lua", "/client/main.lua", "/client/ui/menu.lua", "/client/zz_last.lua"}, b.ResourceScripts())
+
+	server, err := b.ForSide(SideServer)
+	require.NoError(t, err)
+	serverBundle, err := server.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, serverBundle, `local password = "secret"`)
+	assert.NotContains(t, serverBundle, "RegisterCommand")
+	assert.Equal(t, []string{"/config.lua", "/server/main.lua"}, server.ResourceScripts())
+	assert.Len(t, b.ResourceScripts(), 4, "bundling the server side should leave the client bundler alone")
+
+	manifest := b.ResourceManifest(map[string]string{SideClient: "client.lua", SideServer: "server.lua"})
+	assert.Equal(t, `fx_version 'cerulean'
+game 'gta5'
+
+
+files { 'html/index.html' }
+
+shared_scripts {
+    "@ox_lib/init.lua",
+}
+
+client_scripts {
+    "client.lua",
+}
+
+server_scripts {
+    "server.lua",
+}
+`, manifest)
+}
+
+func TestSetSide(t *testing.T) {
+	b := newResourceBundler(t)
+	assert.Error(t, b.SetSide("shared"))
+	require.NoError(t, b.SetSide(SideServer))
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, "secret")
+}
+
+func TestBundle_FiveMMissingScript(t *testing.T) {
+	b, err := NewBundler("fxmanifest.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"fxmanifest.lua": "client_script 'missing/*.lua'\n"})
+	require.NoError(t, b.SetTarget(TargetFiveM))
+
+	_, err = b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing/*.lua listed in fxmanifest.lua")
+}
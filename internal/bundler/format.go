@@ -0,0 +1,113 @@
+package bundler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Bundle output formats, named in the header by a "-- Format: N" line so
+// tools reading bundles can tell layouts apart
+const (
+	// BundleFormatLegacy is the layout from before the format line: no
+	// format line, modules in no particular order, and a loader running
+	// modules on every require
+	BundleFormatLegacy = 1
+	// BundleFormat is the current layout: the format line after the
+	// "Generated by" line, and modules sorted by key
+	BundleFormat = 2
+)
+
+// ErrNotBundle is returned by ParseBundle for text without a bundle header
+var ErrNotBundle = errors.New("not a lua-bundler bundle, or minified without its header")
+
+// SetBundleFormat selects the layout of the bundles written. Older formats
+// keep tools that parse bundles working until they are updated. 0 selects
+// the current format.
+func (b *Bundler) SetBundleFormat(format int) error {
+	if format == 0 {
+		format = BundleFormat
+	}
+	if format < BundleFormatLegacy || format > BundleFormat {
+		return fmt.Errorf("invalid bundle format %d (expected %d to %d)", format, BundleFormatLegacy, BundleFormat)
+	}
+	b.bundleFormat = format
+	return nil
+}
+
+// writeHeader writes the comments at the top of a bundle
+func (b *Bundler) writeHeader(output *strings.Builder) {
+	output.WriteString("-- Bundled Lua Script\n")
+	output.WriteString("-- Generated by Lua Bundler\n")
+	if b.bundleFormat != BundleFormatLegacy {
+		output.WriteString(fmt.Sprintf("-- Format: %d\n", b.bundleFormat))
+	}
+	if b.version != "" {
+		output.WriteString(fmt.Sprintf("-- Version: %s (build %s)\n", b.version, b.buildID))
+	}
+	if b.gitInfo != nil {
+		output.WriteString(fmt.Sprintf("-- Git: %s\n", b.gitInfo))
+	}
+}
+
+// BundleInfo is what the header and module markers of a bundle tell
+type BundleInfo struct {
+	Format  int      `json:"format"`
+	Version string   `json:"version,omitempty"`
+	BuildID string   `json:"buildId,omitempty"`
+	Git     string   `json:"git,omitempty"`
+	Modules []string `json:"modules"` // embedded module keys, in bundle order
+}
+
+var (
+	generatedLine = regexp.MustCompile(`(?m)^-- Generated by Lua Bundler\r?$`)
+	formatLine    = regexp.MustCompile(`(?m)^-- Format: (\d+)\r?$`)
+	versionLine   = regexp.MustCompile(`(?m)^-- Version: (.*) \(build ([0-9a-f]+)\)\r?$`)
+	gitLine       = regexp.MustCompile(`(?m)^-- Git: (.*?)\r?$`)
+	moduleLine    = regexp.MustCompile(`(?m)^-- Module: (.*?)\r?$`)
+)
+
+// ParseBundle reads the header and module markers of a bundle in any format
+// up to BundleFormat. A banner before the header is skipped. Bundles in a
+// newer format are refused, as their layout is unknown.
+func ParseBundle(content string) (BundleInfo, error) {
+	loc := generatedLine.FindStringIndex(content)
+	if loc == nil {
+		return BundleInfo{}, ErrNotBundle
+	}
+	header, body := content[loc[1]:], content[loc[1]:]
+	// The header ends at the first line that is not a comment
+	for i := 0; i < len(header); {
+		end := strings.IndexByte(header[i:], '\n')
+		if end < 0 {
+			break
+		}
+		line := strings.TrimSpace(header[i : i+end])
+		if line != "" && !strings.HasPrefix(line, "--") {
+			header, body = header[:i], header[i:]
+			break
+		}
+		i += end + 1
+	}
+
+	info := BundleInfo{Format: BundleFormatLegacy, Modules: []string{}}
+	if m := formatLine.FindStringSubmatch(header); m != nil {
+		format, err := strconv.Atoi(m[1])
+		if err != nil || format > BundleFormat {
+			return BundleInfo{}, fmt.Errorf("bundle format %s is newer than this lua-bundler reads (up to %d); upgrade lua-bundler", m[1], BundleFormat)
+		}
+		info.Format = format
+	}
+	if m := versionLine.FindStringSubmatch(header); m != nil {
+		info.Version, info.BuildID = m[1], m[2]
+	}
+	if m := gitLine.FindStringSubmatch(header); m != nil {
+		info.Git = m[1]
+	}
+	for _, m := range moduleLine.FindAllStringSubmatch(body, -1) {
+		info.Modules = append(info.Modules, m[1])
+	}
+	return info, nil
+}
@@ -0,0 +1,146 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetBundleFormat(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	assert.Equal(t, BundleFormat, b.bundleFormat)
+
+	require.NoError(t, b.SetBundleFormat(BundleFormatLegacy))
+	assert.Equal(t, BundleFormatLegacy, b.bundleFormat)
+	require.NoError(t, b.SetBundleFormat(0))
+	assert.Equal(t, BundleFormat, b.bundleFormat, "0 selects the current format")
+
+	assert.Error(t, b.SetBundleFormat(BundleFormat+1))
+	assert.Error(t, b.SetBundleFormat(-1))
+}
+
+func TestGenerateBundle_Format(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	for _, key := range []string{"zeta", "alpha", "mid", "beta"} {
+		b.modules[key] = "return 1"
+	}
+
+	out := b.generateBundle("print(1)", false)
+	assert.True(t, strings.HasPrefix(out, "-- Bundled Lua Script\n-- Generated by Lua Bundler\n-- Format: 2\n"))
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, out, b.generateBundle("print(1)", false), "the same sources give the same bundle")
+	}
+
+	info, err := ParseBundle(out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alpha", "beta", "mid", "zeta"}, info.Modules, "modules are sorted by key")
+
+	require.NoError(t, b.SetBundleFormat(BundleFormatLegacy))
+	out = b.generateBundle("print(1)", false)
+	assert.NotContains(t, out, "-- Format:")
+}
+
+func TestParseBundle(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.modules["util"] = "return {}"
+	b.modules["net/http"] = "return {}"
+	require.NoError(t, b.SetVersion("1.4.0"))
+	b.SetGitInfo(&GitInfo{Commit: "3f2a9c1d", Tag: "v1.4.0"})
+	b.SetBanner("-- Copyright (c) Example\nlocal GUARD = true", "")
+	out := b.wrapBanner(b.generateBundle("print(1)", false))
+
+	info, err := ParseBundle(out)
+	require.NoError(t, err)
+	assert.Equal(t, BundleFormat, info.Format)
+	assert.Equal(t, "1.4.0", info.Version)
+	assert.Equal(t, b.GetBuildID(), info.BuildID)
+	assert.Equal(t, "3f2a9c1d (v1.4.0)", info.Git)
+	assert.Equal(t, []string{"net/http", "util"}, info.Modules)
+}
+
+func TestParseBundle_Legacy(t *testing.T) {
+	legacy := "-- Bundled Lua Script\r\n" +
+		"-- Generated by Lua Bundler\r\n" +
+		"-- Version: 1.0.0 (build 27c7d3e8ec83)\r\n" +
+		"local EmbeddedModules = {}\r\n\r\n" +
+		"-- Module: b\r\nEmbeddedModules[\"b\"] = function(...)\r\nend\r\n\r\n" +
+		"-- Module: a\r\nEmbeddedModules[\"a\"] = function(...)\r\nend\r\n"
+
+	info, err := ParseBundle(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, BundleFormatLegacy, info.Format)
+	assert.Equal(t, "1.0.0", info.Version)
+	assert.Equal(t, "27c7d3e8ec83", info.BuildID)
+	assert.Equal(t, []string{"b", "a"}, info.Modules, "modules are listed in bundle order")
+}
+
+func TestParseBundle_Errors(t *testing.T) {
+	_, err := ParseBundle("local a=1 print(a)")
+	assert.ErrorIs(t, err, ErrNotBundle)
+
+	_, err = ParseBundle("-- Bundled Lua Script\n-- Generated by Lua Bundler\n-- Format: 99\nprint(1)\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "newer")
+
+	// A format line in the code below the header is not the bundle's
+	info, err := ParseBundle("-- Generated by Lua Bundler\nprint(1)\n-- Format: 99\n")
+	require.NoError(t, err)
+	assert.Equal(t, BundleFormatLegacy, info.Format)
+}
+
+// baselineBundle is what the first lua-bundler release wrote for the
+// project in TestGenerateBundle_LegacyGolden; format 1 bundles match it
+const baselineBundle = `-- Bundled Lua Script
+-- Generated by Lua Bundler
+local EmbeddedModules = {}
+
+-- Load module helper function
+local function loadModule(url)
+    -- Try embedded module first
+    if EmbeddedModules[url] then
+        return EmbeddedModules[url]()
+    end
+    
+    -- Fallback to original require
+    return require(url)
+end
+
+-- Module: util
+EmbeddedModules["util"] = function()
+    -- Helper module
+    local util = {}
+
+    function util.greet(name)
+        return "Hello, " .. name
+    end
+
+    return util
+
+end
+
+-- Main Script
+local util = loadModule("util")
+
+print(util.greet("World"))
+`
+
+func TestGenerateBundle_LegacyGolden(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte("local util = require(\"util\")\n\nprint(util.greet(\"World\"))\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util.lua"), []byte("-- Helper module\nlocal util = {}\n\nfunction util.greet(name)\n    return \"Hello, \" .. name\nend\n\nreturn util\n"), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetBundleFormat(BundleFormatLegacy))
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Equal(t, baselineBundle, out)
+}
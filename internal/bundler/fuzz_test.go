@@ -0,0 +1,36 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+func FuzzMinify(f *testing.F) {
+	seeds := []string{
+		"local name = \"héllo  wörld\" -- ünïcode\nprint(name)\n",
+		"local msg = [[\nDear name,\n\n  l'été  ]]\nlocal n = 1e3 + 0x10\nreturn msg, n\n",
+		"local s = \"it's\" -- don't\nlocal v = 'a -- b'; (print)(s .. v)\n",
+		"local t = { label = `{1}  €`, count = 2; }\nlocal function go(label: string) return t.label .. label end\nreturn go('x')\n",
+		"#!/usr/bin/lua\n--[==[ 多行 ]==]\nlocal x = 10 local y = x * 2 return y\n",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		if _, err := parser.Parse(src); err != nil {
+			return
+		}
+		if shortened, err := shortenLocals(src); err == nil {
+			if _, err := parser.Parse(shortened); err != nil {
+				t.Fatalf("shortened locals do not parse (%v):\n%s\n--- from:\n%s", err, shortened, src)
+			}
+		}
+		for _, opts := range []minifyOptions{{}, {aggressive: true, doubles: true}, {preserveLines: true}} {
+			out := minifyCode(removeComments(src), opts)
+			if _, err := parser.Parse(out); err != nil {
+				t.Fatalf("%+v: minified code does not parse (%v):\n%s\n--- from:\n%s", opts, err, out, src)
+			}
+		}
+	})
+}
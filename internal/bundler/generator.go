@@ -1,116 +1,475 @@
 package bundler
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 )
 
-// generateBundle creates the final bundled output
-func (b *Bundler) generateBundle(mainContent string) string {
+// scratchPool holds the buffers module bodies are rewritten in, so builds
+// reuse their capacity instead of growing a new one per module
+var scratchPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledScratch is the largest buffer returned to scratchPool; bigger
+// ones are left to the collector rather than held between builds
+const maxPooledScratch = 4 << 20
+
+// generateBundle creates the final bundled output. Release mode is passed on
+// to the lazy loader, whose modules the bundle-wide passes cannot reach.
+func (b *Bundler) generateBundle(mainContent string, releaseMode bool) string {
 	var output strings.Builder
+	output.Grow(b.bundleSizeHint(mainContent))
 
-	output.WriteString("-- Bundled Lua Script\n")
-	output.WriteString("-- Generated by Lua Bundler\n")
+	b.buildID = b.computeBuildID(mainContent)
+	b.inlinedSmall = b.smallModules(mainContent)
+	b.writeHeader(&output)
+
+	// Inject polyfills referenced by the bundled code for the current target
+	sources := []string{mainContent}
+	for _, content := range b.modules {
+		sources = append(sources, content)
+	}
+	needed := b.requiredPolyfills(sources...)
+	b.polyfills = b.polyfills[:0]
+	for _, p := range needed {
+		b.polyfills = append(b.polyfills, p.name)
+		if p.warning != "" {
+			b.warnf("polyfill %s", p.warning)
+		}
+	}
+	writePolyfills(&output, needed)
+	if b.requestShimUsed {
+		writeRequestShim(&output, b.requestShimName())
+	}
+	if b.stateUsed {
+		writeStateTable(&output, b.stateName(), b.stateKey)
+	}
+	if len(b.apis) > 0 {
+		writeAPILoader(&output, b.apiLoaderName(), b.apis)
+	}
+	writeDefines(&output, b.bundleDefines())
+
+	modulesTable, _ := b.loaderNames()
 
 	// Generate EmbeddedModules table
-	output.WriteString("local EmbeddedModules = {}\n\n")
-
-	// Add loadModule function
-	output.WriteString("-- Load module helper function\n")
-	output.WriteString("local function loadModule(url)\n")
-	output.WriteString("    -- Try embedded module first\n")
-	output.WriteString("    if EmbeddedModules[url] then\n")
-	output.WriteString("        return EmbeddedModules[url]()\n")
-	output.WriteString("    end\n")
-	output.WriteString("    \n")
-	output.WriteString("    -- Fallback to original require\n")
-	output.WriteString("    return require(url)\n")
-	output.WriteString("end\n\n")
-
-	// Add all modules
-	for path, content := range b.modules {
-		output.WriteString(fmt.Sprintf("-- Module: %s\n", path))
-		output.WriteString(fmt.Sprintf("EmbeddedModules[\"%s\"] = function()\n", escapeString(path)))
-
-		// Process module content to replace nested requires with loadModule calls
-		processedContent := b.replaceModuleCalls(content)
-
-		// Indent content
-		lines := strings.Split(processedContent, "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) != "" {
-				output.WriteString("    " + line + "\n")
-			} else {
-				output.WriteString("\n")
-			}
+	output.WriteString(fmt.Sprintf("local %s = {}\n\n", modulesTable))
+
+	var inlined []inlinedModule
+	strategy := b.loader
+	if strategy == LoaderInline && len(b.epilogue) > 0 {
+		// Inlined modules run before the main script
+		b.warnf("inline loader: epilogue modules must run after the main script; using the closure loader")
+		strategy = LoaderClosure
+	} else if strategy == LoaderInline && b.safeWrap {
+		// Inlined modules run before the safe wrap catches errors
+		b.warnf("inline loader: --safe-wrap must catch errors of modules too; using the closure loader")
+		strategy = LoaderClosure
+	}
+	if strategy == LoaderInline {
+		var err error
+		if inlined, err = b.inlineModules(); err != nil {
+			b.warnf("inline loader: %v; using the closure loader", err)
+			strategy = LoaderClosure
 		}
+	}
 
-		output.WriteString("end\n\n")
+	// Add all modules, recording the lines each one occupies
+	b.sourceMap = b.sourceMap[:0]
+	line := strings.Count(output.String(), "\n") + 1
+	// Sections are written straight into the output, never built as strings
+	// of their own, so a module is not copied once more per section
+	addModule := func(path string, write func()) {
+		start := output.Len()
+		write()
+		startLine := line
+		line += strings.Count(output.String()[start:], "\n")
+		b.sourceMap = append(b.sourceMap, SourceMapping{
+			Module:    path,
+			Source:    b.displaySource(b.moduleSource(path)),
+			StartLine: startLine,
+			EndLine:   line - 2,
+		})
 	}
 
-	// Replace require() and loadstring() in main content
-	processedMain := b.replaceModuleCalls(mainContent)
+	// Format 1 gave every module a body of its own
+	dedupe := b.bundleFormat != BundleFormatLegacy
+	var processedMain string
+	switch strategy {
+	case LoaderInline:
+		for _, m := range inlined {
+			addModule(m.key, func() {
+				fmt.Fprintf(&output, "-- Module: %s\ndo\n", m.key)
+				writeIndented(&output, m.body)
+				output.WriteString("end\n\n")
+			})
+		}
+		processedMain = b.replaceModuleCallsWith(mainContent, b.inlineReference)
+	case LoaderLazy:
+		params := b.lazyParams(needed, releaseMode)
+		output.WriteString(b.loaderFunction(params))
+		line = strings.Count(output.String(), "\n") + 1
+		seen := make(map[string]string)
+		for _, path := range sortedKeys(b.modules) {
+			if _, ok := b.inlinedSmall[path]; ok {
+				continue
+			}
+			source := b.lazySource(path, b.replaceModuleCalls(b.modules[path]), params, releaseMode)
+			addModule(path, func() {
+				fmt.Fprintf(&output, "-- Module: %s\n%s[\"%s\"] = ", path, modulesTable, escapeString(b.moduleKey(path)))
+				if first, ok := seen[source]; ok && dedupe {
+					fmt.Fprintf(&output, "%s[\"%s\"]\n\n", modulesTable, escapeString(b.moduleKey(first)))
+					return
+				}
+				seen[source] = path
+				output.WriteString(longString(source))
+				output.WriteString("\n\n")
+			})
+		}
+		processedMain = b.replaceModuleCalls(mainContent)
+	default:
+		output.WriteString(b.loaderFunction(nil))
+		line = strings.Count(output.String(), "\n") + 1
+		// Modules go in key order, so the same sources give the same bundle.
+		// Modules identical once their requires are replaced, such as a
+		// library vendored twice, share the first one's function; they still
+		// run and cache separately, as the loader is keyed by name.
+		seen := make(map[string]string)
+		for _, path := range sortedKeys(b.modules) {
+			if _, ok := b.inlinedSmall[path]; ok {
+				continue
+			}
+			// Process module content to replace nested requires with loadModule calls
+			processedContent := b.replaceModuleCalls(b.modules[path])
+			addModule(path, func() {
+				fmt.Fprintf(&output, "-- Module: %s\n%s[\"%s\"] = ", path, modulesTable, escapeString(b.moduleKey(path)))
+				if first, ok := seen[processedContent]; ok && dedupe {
+					fmt.Fprintf(&output, "%s[\"%s\"]\n\n", modulesTable, escapeString(b.moduleKey(first)))
+					return
+				}
+				seen[processedContent] = path
+				if b.bundleFormat == BundleFormatLegacy {
+					output.WriteString("function()\n")
+				} else {
+					output.WriteString("function(...)\n")
+				}
+				writeIndented(&output, processedContent)
+				output.WriteString("end\n\n")
+			})
+		}
+		processedMain = b.replaceModuleCalls(mainContent)
+	}
+
+	run := b.loadModuleCall
+	if strategy == LoaderInline {
+		run = func(key string) string {
+			reference, _ := b.inlineReference(key)
+			return reference
+		}
+	}
+	openWrap, closeWrap := b.wrapSafely(run)
+	output.WriteString(openWrap)
+	b.writePrelude(&output, run)
+	openEpilogue, closeEpilogue := b.wrapEpilogue(run)
+	if closeEpilogue+closeWrap != "" && !strings.HasSuffix(processedMain, "\n") {
+		processedMain += "\n"
+	}
+	output.WriteString(openEpilogue)
+	line = strings.Count(output.String(), "\n") + 1
 
 	output.WriteString("-- Main Script\n")
 	output.WriteString(processedMain)
+	output.WriteString(closeEpilogue)
+	output.WriteString(closeWrap)
+	b.sourceMap = append(b.sourceMap, SourceMapping{
+		Module:    b.displaySource(b.entryFile),
+		Source:    b.displaySource(b.entryFile),
+		StartLine: line,
+		EndLine:   line + strings.Count(strings.TrimRight(processedMain, "\n"), "\n") + 1,
+	})
 
 	return output.String()
 }
 
-// replaceModuleCalls replaces require() and loadstring() calls with loadModule() calls
+// loaderFunction returns the loader called in place of require. Like
+// require, it passes the module its name as ..., runs it once and caches the
+// results, with true standing in for a nil first result, except for modules
+// opted out with SetNoMemoize. Every result is returned, not just the first.
+// Closure modules are called directly; with lazyParams, modules are source
+// strings compiled on first use and passed the bundle locals they cannot see.
+func (b *Bundler) loaderFunction(lazyParams []string) string {
+	if b.bundleFormat == BundleFormatLegacy {
+		return b.legacyLoaderFunction(lazyParams)
+	}
+	modulesTable, loader := b.loaderNames()
+	loaded := b.loadedName()
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("local %s = {}\n", loaded))
+	uncached := b.uncachedKeys()
+	if len(uncached) > 0 {
+		out.WriteString("local uncachedModules = {\n")
+		for _, key := range uncached {
+			out.WriteString(fmt.Sprintf("    [\"%s\"] = true,\n", escapeString(b.moduleKey(key))))
+		}
+		out.WriteString("}\n")
+	}
+	out.WriteString("local unpackResults = table.unpack or unpack\n")
+	out.WriteString("local function packResults(...)\n")
+	out.WriteString("    return { n = select(\"#\", ...), ... }\n")
+	out.WriteString("end\n\n")
+
+	name := "url"
+	if b.namespace != "" {
+		name = fmt.Sprintf("(url:gsub(\"^%s:\", \"\"))", b.namespace)
+	}
+
+	out.WriteString("-- Load module helper function\n")
+	out.WriteString(fmt.Sprintf("local function %s(url)\n", loader))
+	out.WriteString("    -- Modules run once; later requires return the cached results\n")
+	out.WriteString(fmt.Sprintf("    local loaded = %s[url]\n", loaded))
+	out.WriteString(fmt.Sprintf("    if loaded == %s then\n", loaded))
+	out.WriteString("        error(\"loop or previous error loading module '\" .. url .. \"'\", 2)\n")
+	out.WriteString("    elseif loaded ~= nil then\n")
+	out.WriteString("        return unpackResults(loaded, 1, loaded.n)\n")
+	out.WriteString("    end\n")
+	out.WriteString("    \n")
+	out.WriteString("    -- Try embedded module first\n")
+	out.WriteString(fmt.Sprintf("    local module = %s[url]\n", modulesTable))
+	if lazyParams != nil {
+		out.WriteString("    if type(module) == \"string\" then\n")
+		out.WriteString("        -- Compile embedded modules on first use\n")
+		compile, _ := b.chunkCompiler()
+		out.WriteString(fmt.Sprintf("        module = assert(%s(module, \"=\" .. url))(%s)\n", compile, strings.Join(lazyParams, ", ")))
+		out.WriteString(fmt.Sprintf("        %s[url] = module\n", modulesTable))
+		out.WriteString("    end\n")
+	}
+	out.WriteString("    if module then\n")
+	if len(uncached) > 0 {
+		out.WriteString("        if uncachedModules[url] then\n")
+		out.WriteString(fmt.Sprintf("            return module(%s)\n", name))
+		out.WriteString("        end\n")
+	}
+	out.WriteString(fmt.Sprintf("        %s[url] = %s -- loading; requiring it again is a loop\n", loaded, loaded))
+	out.WriteString(fmt.Sprintf("        local results = packResults(module(%s))\n", name))
+	out.WriteString("        if results[1] == nil then\n")
+	out.WriteString("            results[1] = true\n")
+	out.WriteString("            results.n = math.max(results.n, 1)\n")
+	out.WriteString("        end\n")
+	out.WriteString(fmt.Sprintf("        %s[url] = results\n", loaded))
+	out.WriteString("        return unpackResults(results, 1, results.n)\n")
+	out.WriteString("    end\n")
+	out.WriteString("    \n")
+	out.WriteString("    -- Fallback to original require\n")
+	if b.namespace != "" {
+		out.WriteString(fmt.Sprintf("    return require(%s)\n", name))
+	} else {
+		out.WriteString("    return require(url)\n")
+	}
+	out.WriteString("end\n\n")
+	return out.String()
+}
+
+// legacyLoaderFunction returns the loader of format 1 bundles, which runs a
+// module on every require, passing it nothing, and returns its first result
+func (b *Bundler) legacyLoaderFunction(lazyParams []string) string {
+	modulesTable, loader := b.loaderNames()
+
+	var out strings.Builder
+	out.WriteString("-- Load module helper function\n")
+	out.WriteString(fmt.Sprintf("local function %s(url)\n", loader))
+	if lazyParams != nil {
+		out.WriteString(fmt.Sprintf("    if type(%s[url]) == \"string\" then\n", modulesTable))
+		out.WriteString("        -- Compile embedded modules on first use\n")
+		compile, _ := b.chunkCompiler()
+		out.WriteString(fmt.Sprintf("        %s[url] = assert(%s(%s[url], \"=\" .. url))(%s)\n", modulesTable, compile, modulesTable, strings.Join(lazyParams, ", ")))
+		out.WriteString("    end\n")
+	}
+	out.WriteString("    -- Try embedded module first\n")
+	out.WriteString(fmt.Sprintf("    if %s[url] then\n", modulesTable))
+	out.WriteString(fmt.Sprintf("        return %s[url]()\n", modulesTable))
+	out.WriteString("    end\n")
+	out.WriteString("    \n")
+	out.WriteString("    -- Fallback to original require\n")
+	if b.namespace != "" {
+		out.WriteString(fmt.Sprintf("    return require((url:gsub(\"^%s:\", \"\")))\n", b.namespace))
+	} else {
+		out.WriteString("    return require(url)\n")
+	}
+	out.WriteString("end\n\n")
+	return out.String()
+}
+
+// bundleSizeHint estimates the size of the bundle of mainContent and the
+// modules, so the output is allocated once rather than grown by doubling
+func (b *Bundler) bundleSizeHint(mainContent string) int {
+	size := len(mainContent) + 8<<10 // header, polyfills and loader
+	for _, content := range b.modules {
+		// Indentation adds a few bytes per line, sections ~100 bytes each
+		size += len(content) + len(content)/8 + 128
+	}
+	return size
+}
+
+// indent indents every non-blank line of a module body by four spaces,
+// ending it with a newline
+func indent(content string) string {
+	var out strings.Builder
+	out.Grow(len(content) + len(content)/8 + 1)
+	writeIndented(&out, content)
+	return out.String()
+}
+
+// writeIndented writes content to out as indent returns it
+func writeIndented(out *strings.Builder, content string) {
+	for {
+		line, rest, found := strings.Cut(content, "\n")
+		if strings.TrimSpace(line) != "" {
+			out.WriteString("    ")
+			out.WriteString(line)
+		}
+		out.WriteByte('\n')
+		if !found {
+			return
+		}
+		content = rest
+	}
+}
+
+// loadedName returns the name of the table caching module results
+func (b *Bundler) loadedName() string {
+	if b.namespace == "" {
+		return "LoadedModules"
+	}
+	return b.namespace + "_LoadedModules"
+}
+
+// loaderNames returns the names of the embedded modules table and loader
+// function, prefixed with the namespace when one is set
+func (b *Bundler) loaderNames() (string, string) {
+	if b.namespace == "" {
+		return "EmbeddedModules", "loadModule"
+	}
+	return b.namespace + "_EmbeddedModules", b.namespace + "_loadModule"
+}
+
+// moduleKey returns the key a module is embedded under
+func (b *Bundler) moduleKey(key string) string {
+	if b.namespace == "" {
+		return key
+	}
+	return b.namespace + ":" + key
+}
+
+// loadModuleCall returns the loader call replacing a require or HttpGet of key
+func (b *Bundler) loadModuleCall(key string) string {
+	_, loader := b.loaderNames()
+	return fmt.Sprintf("%s(\"%s\")", loader, escapeString(b.moduleKey(key)))
+}
+
+// replaceModuleCalls replaces require() and loadstring() calls with loadModule() calls,
+// or with the expression of a module inlined at its requires
 func (b *Bundler) replaceModuleCalls(content string) string {
-	// Support both quoted strings: require("path.to.file") and unquoted: require(path.to.file)
-	requireRegex := regexp.MustCompile(`require\s*\(\s*(?:['"]([^'"]+)['"]|([a-zA-Z_][a-zA-Z0-9_.]*))\s*\)`)
-	httpGetRegex := regexp.MustCompile(`loadstring\s*\(\s*game:HttpGet\s*\(\s*['"]([^'"]+)['"]\s*\)\s*\)\s*\(\s*\)`)
+	return b.replaceModuleCallsWith(content, func(key string) (string, bool) {
+		if expr, ok := b.inlinedSmall[key]; ok {
+			return expr, true
+		}
+		return b.loadModuleCall(key), true
+	})
+}
+
+// replaceModuleCallsWith replaces require() and loadstring() calls of bundled
+// modules with the expression returned by call, keeping calls it rejects
+func (b *Bundler) replaceModuleCallsWith(content string, call func(key string) (string, bool)) string {
 	// Pattern to detect HttpGet inside function calls (should NOT be replaced)
-	funcCallHttpGetRegex := regexp.MustCompile(`\w+\s*\([^)]*loadstring\s*\(\s*game:HttpGet`)
+	httpGetRegex, funcCallHttpGetRegex := b.remoteLoaderPatterns()
 
 	processedContent := content
 
-	// Replace loadstring(game:HttpGet(...))() - but skip if inside function calls
+	// Replace loadstring(game:HttpGet(...))() - but skip if inside function
+	// calls. Most modules fetch nothing and are not split into lines at all.
+	if httpGetRegex.MatchString(processedContent) {
+		processedContent = b.replaceRemoteLoaders(processedContent, httpGetRegex, funcCallHttpGetRegex, call)
+	}
+
+	matches := requireRegex.FindAllStringSubmatchIndex(processedContent, -1)
+	if len(matches) == 0 {
+		return processedContent
+	}
+
+	// Replace require() for bundled modules (check b.modules first, then
+	// isLocalModule), keeping those a pragma marks external
 	lines := strings.Split(processedContent, "\n")
+	out := scratchPool.Get().(*bytes.Buffer)
+	out.Reset()
+	out.Grow(len(processedContent) + len(processedContent)/4)
+	last, line := 0, 0
+	for _, loc := range matches {
+		line += strings.Count(processedContent[last:loc[0]], "\n")
+		out.WriteString(processedContent[last:loc[0]])
+		last = loc[0]
+
+		// Group 1 is a quoted string, group 2 an unquoted identifier
+		modulePath := ""
+		if loc[2] >= 0 {
+			modulePath = processedContent[loc[2]:loc[3]]
+		} else if loc[4] >= 0 {
+			modulePath = processedContent[loc[4]:loc[5]]
+		}
+		if modulePath == "" {
+			continue
+		}
+		pragma := requirePragma(lines, line)
+		if pragma == "external" {
+			continue
+		}
+		// If module is in b.modules (already bundled), replace with loadModule
+		_, exists := b.modules[modulePath]
+		// Otherwise, check if it's a local module
+		if exists || b.isLocalRequire(modulePath, pragma) {
+			if replacement, ok := call(modulePath); ok {
+				line += strings.Count(processedContent[loc[0]:loc[1]], "\n")
+				out.WriteString(replacement)
+				last = loc[1]
+			}
+		}
+	}
+	out.WriteString(processedContent[last:])
+
+	result := out.String()
+	if out.Cap() <= maxPooledScratch {
+		scratchPool.Put(out)
+	}
+	return result
+}
+
+// replaceRemoteLoaders replaces the HttpGet loaders of bundled modules in
+// content with the expression returned by call, line by line
+func (b *Bundler) replaceRemoteLoaders(content string, loader, inCall *regexp.Regexp, call func(key string) (string, bool)) string {
+	lines := strings.Split(content, "\n")
 	for i, line := range lines {
 		// Skip lines with HttpGet inside function calls
-		if funcCallHttpGetRegex.MatchString(line) {
+		if inCall.MatchString(line) {
 			continue
 		}
 		// Replace HttpGet pattern in this line
-		lines[i] = httpGetRegex.ReplaceAllStringFunc(line, func(match string) string {
-			matches := httpGetRegex.FindStringSubmatch(match)
+		lines[i] = loader.ReplaceAllStringFunc(line, func(match string) string {
+			matches := loader.FindStringSubmatch(match)
 			if len(matches) > 1 {
 				url := matches[1]
-				return fmt.Sprintf("loadModule(\"%s\")", escapeString(url))
+				// Keep loaders beyond --flatten-depth as runtime fetches
+				if _, embedded := b.modules[url]; b.runtimeFetches[url] && !embedded {
+					return match
+				}
+				if replacement, ok := call(url); ok {
+					return replacement
+				}
 			}
 			return match
 		})
 	}
-	processedContent = strings.Join(lines, "\n")
-
-	// Replace require() for bundled modules (check b.modules first, then isLocalModule)
-	processedContent = requireRegex.ReplaceAllStringFunc(processedContent, func(match string) string {
-		matches := requireRegex.FindStringSubmatch(match)
-		if len(matches) > 1 {
-			// matches[1] is quoted string, matches[2] is unquoted identifier
-			modulePath := matches[1]
-			if modulePath == "" && len(matches) > 2 {
-				modulePath = matches[2]
-			}
-			if modulePath != "" {
-				// If module is in b.modules (already bundled), replace with loadModule
-				if _, exists := b.modules[modulePath]; exists {
-					return fmt.Sprintf("loadModule(\"%s\")", escapeString(modulePath))
-				}
-				// Otherwise, check if it's a local module
-				if b.isLocalModule(modulePath) {
-					return fmt.Sprintf("loadModule(\"%s\")", escapeString(modulePath))
-				}
-			}
-		}
-		return match
-	})
-
-	return processedContent
+	return strings.Join(lines, "\n")
 }
 
 // escapeString escapes special characters in strings for Lua
@@ -1,6 +1,7 @@
 package bundler
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -31,7 +32,7 @@ local remote = loadstring(game:HttpGet('https://example.com/remote.lua'))()
 print(helper.greet())
 print(remote.fetch())`
 
-	result := b.generateBundle(mainContent)
+	result := b.generateBundle(mainContent, false)
 
 	tests := []struct {
 		name    string
@@ -161,7 +162,7 @@ func TestGenerateBundle_EmptyModules(t *testing.T) {
 
 	mainContent := `print("Hello World")`
 
-	result := b.generateBundle(mainContent)
+	result := b.generateBundle(mainContent, false)
 
 	// Should still generate valid structure even with no modules
 	assert.Contains(t, result, "local EmbeddedModules = {}", "generateBundle() should contain EmbeddedModules table even with no modules")
@@ -186,7 +187,7 @@ return test`
 
 	b.modules["test"] = moduleContent
 
-	result := b.generateBundle("local t = require('test')")
+	result := b.generateBundle("local t = require('test')", false)
 
 	// Check that indentation is properly added (4 spaces for each line)
 	lines := strings.Split(result, "\n")
@@ -194,7 +195,7 @@ return test`
 	moduleEnded := false
 
 	for _, line := range lines {
-		if strings.Contains(line, `EmbeddedModules["test"] = function()`) {
+		if strings.Contains(line, `EmbeddedModules["test"] = function(...)`) {
 			moduleStarted = true
 			continue
 		}
@@ -238,7 +239,7 @@ return methods`
 	mainContent := `local config = require("modules.config")
 print(config.locations.spots[1])`
 
-	result := b.generateBundle(mainContent)
+	result := b.generateBundle(mainContent, false)
 
 	// Verify that nested requires in modules.config are replaced with loadModule
 	assert.Contains(t, result, `loadModule("modules.locations")`, "should replace nested require in module with loadModule")
@@ -271,7 +272,7 @@ return methods`
 
 	mainContent := `local locations = require("modules.locations")`
 
-	result := b.generateBundle(mainContent)
+	result := b.generateBundle(mainContent, false)
 
 	// Verify that nested require in modules.locations is replaced
 	assert.Contains(t, result, `loadModule("modules.fishing_methods")`, "should replace nested require in modules.locations with loadModule")
@@ -300,7 +301,7 @@ return methods`
 
 	mainContent := `local config = require("modules.config")`
 
-	result := b.generateBundle(mainContent)
+	result := b.generateBundle(mainContent, false)
 
 	// Print full result for debugging
 	t.Logf("Full generated bundle:\n%s", result)
@@ -332,3 +333,85 @@ return methods`
 	assert.NotContains(t, configModuleContent, `require("modules.locations")`, "should not contain require in modules.config")
 	assert.NotContains(t, configModuleContent, `require("modules.fishing_methods")`, "should not contain require in modules.config")
 }
+
+func TestGenerateBundle_Namespace(t *testing.T) {
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+	require.NoError(t, b.SetNamespace("myproj"))
+
+	b.modules["utils.helper"] = `return require("utils.log")`
+	b.modules["utils.log"] = `return {}`
+	b.modules["https://example.com/lib.lua"] = `return {}`
+
+	result := b.generateBundle(`local helper = require("utils.helper")
+local lib = loadstring(game:HttpGet('https://example.com/lib.lua'))()
+local missing = require("missing")`, false)
+
+	assert.Contains(t, result, "local myproj_EmbeddedModules = {}")
+	assert.Contains(t, result, "local function myproj_loadModule(url)")
+	assert.Contains(t, result, `myproj_EmbeddedModules["myproj:utils.helper"] = function(...)`)
+	assert.Contains(t, result, `return myproj_loadModule("myproj:utils.log")`)
+	assert.Contains(t, result, `local lib = myproj_loadModule("myproj:https://example.com/lib.lua")`)
+	assert.Contains(t, result, `local missing = myproj_loadModule("myproj:missing")`)
+	assert.Contains(t, result, `return require((url:gsub("^myproj:", "")))`, "fallback require should strip the namespace")
+	assert.NotContains(t, result, "local EmbeddedModules")
+	assert.NotContains(t, result, "loadModule(\"utils")
+}
+
+func TestSetNamespace_Invalid(t *testing.T) {
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+
+	for _, ns := range []string{"my-proj", "1proj", "a b", "x.y"} {
+		assert.Error(t, b.SetNamespace(ns), "namespace %q should be rejected", ns)
+	}
+	assert.NoError(t, b.SetNamespace(""), "empty namespace disables prefixing")
+}
+
+func TestGenerateBundle_DuplicateModules(t *testing.T) {
+	for _, loader := range []string{LoaderClosure, LoaderLazy} {
+		t.Run(loader, func(t *testing.T) {
+			b, err := NewBundler("main.lua", false, false)
+			require.NoError(t, err)
+			require.NoError(t, b.SetLoader(loader))
+			b.modules["vendor/a/json"] = "local json = {}\nreturn json\n"
+			b.modules["vendor/b/json"] = "local json = {}\nreturn json\n"
+			b.modules["util"] = "return {}\n"
+
+			out := b.generateBundle("print(1)", false)
+			assert.Equal(t, 1, strings.Count(out, "local json = {}"), "the body is written once")
+			assert.Contains(t, out, "-- Module: vendor/b/json\nEmbeddedModules[\"vendor/b/json\"] = EmbeddedModules[\"vendor/a/json\"]\n\n")
+
+			info, err := ParseBundle(out)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"util", "vendor/a/json", "vendor/b/json"}, info.Modules)
+
+			require.NoError(t, b.SetBundleFormat(BundleFormatLegacy))
+			out = b.generateBundle("print(1)", false)
+			assert.Equal(t, 2, strings.Count(out, "local json = {}"), "format 1 writes every body")
+		})
+	}
+}
+
+func TestIndent(t *testing.T) {
+	assert.Equal(t, "\n", indent(""))
+	assert.Equal(t, "    a\n\n    b\n", indent("a\n  \nb"))
+	assert.Equal(t, "    a\n\n", indent("a\n"))
+}
+
+func BenchmarkGenerateBundle(b *testing.B) {
+	bundler, err := NewBundler("main.lua", false, false)
+	require.NoError(b, err)
+	// ~10MB of modules, some requiring others
+	row := strings.Repeat("    { id = 1, name = \"item\", tags = { \"a\", \"b\" } },\n", 2000)
+	for i := 0; i < 100; i++ {
+		bundler.modules[fmt.Sprintf("data/part%d", i)] = fmt.Sprintf("local util = require(\"util\")\nlocal part = %d\nreturn {\n%s}\n", i, row)
+	}
+	bundler.modules["util"] = "return {}\n"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bundler.generateBundle("local data = require(\"data/part1\")\n", false)
+	}
+}
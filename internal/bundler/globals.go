@@ -0,0 +1,184 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Global override warning rules
+const (
+	RuleGlobalOverride  = "global-override"  // print = ..., function require() ... end
+	RuleLibraryOverride = "library-override" // string.format = ..., function table.find() ... end
+	RuleGlobalShadow    = "global-shadow"    // local print = function() ... end
+)
+
+// WarningRules lists the rules that can be passed to SuppressWarnings
+var WarningRules = []string{RuleGlobalOverride, RuleLibraryOverride, RuleGlobalShadow, RuleStateConnection, RuleStateAccumulate, RuleStateGlobal, RuleReleaseSideEffect, RuleReleaseEmptyBranch}
+
+// standardGlobals are the globals the bundle loader, release-mode stripping
+// and polyfills rely on behaving as standard
+var standardGlobals = map[string]bool{
+	"assert": true, "collectgarbage": true, "dofile": true, "error": true,
+	"getmetatable": true, "ipairs": true, "load": true, "loadfile": true,
+	"loadstring": true, "next": true, "pairs": true, "pcall": true,
+	"print": true, "rawequal": true, "rawget": true, "rawlen": true,
+	"rawset": true, "require": true, "select": true, "setmetatable": true,
+	"tonumber": true, "tostring": true, "type": true, "unpack": true,
+	"xpcall": true, "_G": true,
+	// Roblox
+	"warn": true, "game": true, "typeof": true, "task": true,
+}
+
+// standardLibraries are the global library tables
+var standardLibraries = map[string]bool{
+	"string": true, "table": true, "math": true, "os": true, "io": true,
+	"coroutine": true, "debug": true, "utf8": true, "bit32": true,
+}
+
+// IsWarningRule reports whether rule names a warning rule
+func IsWarningRule(rule string) bool {
+	for _, r := range WarningRules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// SuppressWarnings disables the given global override warning rules
+func (b *Bundler) SuppressWarnings(rules []string) error {
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if !IsWarningRule(rule) {
+			return fmt.Errorf("unknown warning rule %q (expected one of: %s)", rule, strings.Join(WarningRules, ", "))
+		}
+		if b.suppressed == nil {
+			b.suppressed = make(map[string]bool)
+		}
+		b.suppressed[rule] = true
+	}
+	return nil
+}
+
+// globalWarning is a standard global override found in a source file
+type globalWarning struct {
+	rule string
+	line int
+	span parser.Span // the offending name
+	msg  string
+}
+
+// checkGlobals warns about assignments to and shadowing of standard globals
+// in a module, which interact badly with release-mode stripping and the
+// injected loader, and about shared state breaking when the bundle runs
+// again. Sources the parser rejects are skipped.
+func (b *Bundler) checkGlobals(filePath, content string) {
+	if len(b.suppressed) == len(WarningRules) {
+		return
+	}
+	for _, w := range append(findGlobalOverrides(content), findStateHazards(content)...) {
+		if b.suppressed[w.rule] {
+			continue
+		}
+		b.warnf("%s:%d: %s [%s]", b.displaySource(filePath), w.line, w.msg, w.rule)
+		b.diagnostics = append(b.diagnostics, w.diagnostic(filePath))
+	}
+}
+
+// findGlobalOverrides returns the standard global overrides in src, in
+// source order
+func findGlobalOverrides(src string) []globalWarning {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return nil
+	}
+
+	var warnings []globalWarning
+	add := func(rule string, span parser.Span, format string, args ...interface{}) {
+		line := strings.Count(src[:span.Start], "\n") + 1
+		warnings = append(warnings, globalWarning{rule: rule, line: line, span: span, msg: fmt.Sprintf(format, args...)})
+	}
+	target := func(e parser.Expr, span parser.Span) {
+		switch t := e.(type) {
+		case *parser.Ident:
+			if t.Binding != nil && t.Binding.Global() && standardGlobals[t.Name] {
+				add(RuleGlobalOverride, span, "overrides standard global %s", t.Name)
+			}
+		case *parser.FieldExpr:
+			if isGlobal(t.X, "_G") && standardGlobals[t.Name.Value] {
+				add(RuleGlobalOverride, span, "overrides standard global %s", t.Name.Value)
+			} else if lib := libraryName(t.X); lib != "" {
+				add(RuleLibraryOverride, span, "modifies standard library %s.%s", lib, t.Name.Value)
+			}
+		case *parser.IndexExpr:
+			if lib := libraryName(t.X); lib != "" {
+				add(RuleLibraryOverride, span, "modifies standard library table %s", lib)
+			}
+		}
+	}
+
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch s := n.(type) {
+		case *parser.AssignStmt:
+			for _, t := range s.Targets {
+				target(t, t.Range())
+			}
+		case *parser.FunctionStmt:
+			if s.Method == nil {
+				target(s.Target, s.Target.Range())
+			} else if lib := libraryName(s.Target); lib != "" {
+				add(RuleLibraryOverride, s.Target.Range(), "modifies standard library %s:%s", lib, s.Method.Value)
+			}
+		case *parser.LocalStmt:
+			for i, name := range s.Names {
+				if !standardGlobals[name.Name] && !standardLibraries[name.Name] {
+					continue
+				}
+				// local print = print and local unpack = unpack or table.unpack
+				// cache or polyfill the global and are harmless
+				if i < len(s.Values) && mentionsGlobal(s.Values[i], name.Name) {
+					continue
+				}
+				add(RuleGlobalShadow, name.Range(), "local %s shadows the standard global", name.Name)
+			}
+		case *parser.LocalFunctionStmt:
+			if standardGlobals[s.Name.Name] || standardLibraries[s.Name.Name] {
+				add(RuleGlobalShadow, s.Name.Range(), "local function %s shadows the standard global", s.Name.Name)
+			}
+		}
+		return true
+	})
+
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].line < warnings[j].line })
+	return warnings
+}
+
+// isGlobal reports whether e is the global variable name
+func isGlobal(e parser.Expr, name string) bool {
+	id, ok := e.(*parser.Ident)
+	return ok && id.Name == name && id.Binding != nil && id.Binding.Global()
+}
+
+// mentionsGlobal reports whether the global variable name appears in e
+func mentionsGlobal(e parser.Expr, name string) bool {
+	found := false
+	parser.Walk(e, func(n parser.Node) bool {
+		if id, ok := n.(*parser.Ident); ok && isGlobal(id, name) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// libraryName returns the library name if e is a global standard library table
+func libraryName(e parser.Expr) string {
+	id, ok := e.(*parser.Ident)
+	if !ok || !standardLibraries[id.Name] || !isGlobal(id, id.Name) {
+		return ""
+	}
+	return id.Name
+}
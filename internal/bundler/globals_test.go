@@ -0,0 +1,79 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindGlobalOverrides(t *testing.T) {
+	src := `print = function() end
+function require(name) end
+string.trim = function(s) return s end
+function table.find(t, v) end
+function string:shout() end
+_G.pcall = nil
+math["floor"] = nil
+local warn = function() end
+local function tostring(v) end
+local print = print
+local unpack = unpack or table.unpack
+local config = {}
+config.print = true
+myGlobal = 1
+local function f(type) return type end
+`
+	var got []string
+	var lines []int
+	for _, w := range findGlobalOverrides(src) {
+		got = append(got, w.rule+": "+w.msg)
+		lines = append(lines, w.line)
+	}
+	assert.Equal(t, []string{
+		"global-override: overrides standard global print",
+		"global-override: overrides standard global require",
+		"library-override: modifies standard library string.trim",
+		"library-override: modifies standard library table.find",
+		"library-override: modifies standard library string:shout",
+		"global-override: overrides standard global pcall",
+		"library-override: modifies standard library table math",
+		"global-shadow: local warn shadows the standard global",
+		"global-shadow: local function tostring shadows the standard global",
+	}, got)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, lines)
+}
+
+func TestFindGlobalOverridesParseError(t *testing.T) {
+	assert.Empty(t, findGlobalOverrides("print = = 1"))
+}
+
+func TestCheckGlobalsSuppression(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("print = nil\nlocal warn = nil\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"main.lua:1: overrides standard global print [global-override]",
+		"main.lua:2: local warn shadows the standard global [global-shadow]",
+	}, b.GetWarnings())
+	assert.Equal(t, []Diagnostic{
+		{File: entry, Line: 1, Start: 0, End: 5, Severity: SeverityWarning, Rule: RuleGlobalOverride, Message: "overrides standard global print"},
+		{File: entry, Line: 2, Start: 18, End: 22, Severity: SeverityWarning, Rule: RuleGlobalShadow, Message: "local warn shadows the standard global"},
+	}, b.GetDiagnostics())
+
+	b, err = NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SuppressWarnings([]string{" global-shadow"}))
+	_, err = b.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.lua:1: overrides standard global print [global-override]"}, b.GetWarnings())
+
+	assert.Error(t, b.SuppressWarnings([]string{"shadowing"}))
+}
@@ -0,0 +1,140 @@
+package bundler
+
+import (
+	"sort"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// GlobalUsage is how one bundled file shares state through globals
+type GlobalUsage struct {
+	Module  string   // module key, or the entry file
+	Defines []string // globals it assigns: x = ..., function x() end, _G.x = ...
+	Reads   []string // globals it reads that another file defines
+}
+
+// GlobalCoupling is a global that one file defines and others read, an
+// implicit dependency the require graph does not show
+type GlobalCoupling struct {
+	Global    string
+	DefinedBy []string
+	ReadBy    []string
+}
+
+// GlobalUsage reports the globals each file defines and the globals it reads
+// from other files, skipping standard globals and the environment's. Call
+// after Resolve. Files the parser rejects are reported without globals.
+func (b *Bundler) GlobalUsage() ([]GlobalUsage, []GlobalCoupling) {
+	keys := append([]string{b.entryFile}, sortedKeys(b.modules)...)
+
+	defines := make(map[string]map[string]bool, len(keys))
+	reads := make(map[string]map[string]bool, len(keys))
+	definedBy := make(map[string][]string)
+	for _, key := range keys {
+		source, _ := b.GetSource(key)
+		defines[key], reads[key] = moduleGlobals(source)
+		for name := range defines[key] {
+			definedBy[name] = append(definedBy[name], key)
+		}
+	}
+
+	usage := make([]GlobalUsage, 0, len(keys))
+	readBy := make(map[string][]string)
+	for _, key := range keys {
+		u := GlobalUsage{Module: key, Defines: sortedKeys(defines[key])}
+		for _, name := range sortedKeys(reads[key]) {
+			if len(definedBy[name]) > 0 && !defines[key][name] {
+				u.Reads = append(u.Reads, name)
+				readBy[name] = append(readBy[name], key)
+			}
+		}
+		usage = append(usage, u)
+	}
+
+	var couplings []GlobalCoupling
+	for _, name := range sortedKeys(definedBy) {
+		// A global defined in several files couples them even unread
+		if len(readBy[name]) > 0 || len(definedBy[name]) > 1 {
+			couplings = append(couplings, GlobalCoupling{Global: name, DefinedBy: definedBy[name], ReadBy: readBy[name]})
+		}
+	}
+	return usage, couplings
+}
+
+// moduleGlobals returns the non-standard globals src assigns and reads,
+// including those accessed as fields of _G
+func moduleGlobals(src string) (map[string]bool, map[string]bool) {
+	defines, reads := make(map[string]bool), make(map[string]bool)
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return defines, reads
+	}
+
+	written := make(map[parser.Node]bool)
+	define := func(e parser.Expr) {
+		if name, ok := globalName(e); ok {
+			written[e] = true
+			if !standardGlobals[name] && !standardLibraries[name] {
+				defines[name] = true
+			}
+		}
+	}
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch s := n.(type) {
+		case *parser.AssignStmt:
+			for _, t := range s.Targets {
+				define(t)
+			}
+		case *parser.FunctionStmt:
+			if s.Method == nil {
+				define(s.Target)
+			}
+		}
+		return true
+	})
+
+	parser.Walk(chunk, func(n parser.Node) bool {
+		e, ok := n.(parser.Expr)
+		if !ok || written[n] {
+			return true
+		}
+		if name, ok := globalName(e); ok && name != "_G" && !standardGlobals[name] && !standardLibraries[name] {
+			reads[name] = true
+		}
+		return true
+	})
+	return defines, reads
+}
+
+// globalName returns the global e names: a global variable, _G.name or
+// _G["name"]
+func globalName(e parser.Expr) (string, bool) {
+	switch e := e.(type) {
+	case *parser.Ident:
+		if e.Binding != nil && e.Binding.Global() {
+			return e.Name, true
+		}
+	case *parser.FieldExpr:
+		if isGlobal(e.X, "_G") {
+			return e.Name.Value, true
+		}
+	case *parser.IndexExpr:
+		if str, ok := e.Key.(*parser.StringExpr); ok && isGlobal(e.X, "_G") {
+			return str.Token.Unquote()
+		}
+	}
+	return "", false
+}
+
+// sortedKeys returns the keys of m in order, or nil when it is empty
+func sortedKeys[V any](m map[string]V) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,49 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleGlobals(t *testing.T) {
+	defines, reads := moduleGlobals(`Config = { debug = true }
+function Logger(msg) print(msg) end
+_G.State = {}
+_G["Version"] = "1.0"
+local cache = {}
+function Config.reset() Config.debug = false end
+if Theme.dark then print(_G.Player, game.Name, string.rep("x", 2)) end
+local function helper(self) return self, cache end
+`)
+
+	assert.Equal(t, []string{"Config", "Logger", "State", "Version"}, sortedKeys(defines))
+	assert.Equal(t, []string{"Config", "Player", "Theme"}, sortedKeys(reads), "standard globals and locals are not reported")
+}
+
+func TestGlobalUsage(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("require(\"state\")\nrequire(\"ui\")\nprint(Settings.volume)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "state.lua"), []byte("Settings = { volume = 1 }\nShared = {}\nreturn {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ui.lua"), []byte("Shared = Shared or {}\nlocal v = Settings.volume\nreturn { v = v, w = workspace }\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	usage, couplings := b.GlobalUsage()
+	assert.Equal(t, []GlobalUsage{
+		{Module: entry, Reads: []string{"Settings"}},
+		{Module: "state", Defines: []string{"Settings", "Shared"}},
+		{Module: "ui", Defines: []string{"Shared"}, Reads: []string{"Settings"}},
+	}, usage, "environment globals such as workspace are not reads from other files")
+	assert.Equal(t, []GlobalCoupling{
+		{Global: "Settings", DefinedBy: []string{"state"}, ReadBy: []string{entry, "ui"}},
+		{Global: "Shared", DefinedBy: []string{"state", "ui"}},
+	}, couplings)
+}
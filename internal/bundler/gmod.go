@@ -0,0 +1,127 @@
+package bundler
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// includeRegex matches Garry's Mod include("file.lua") calls
+	includeRegex = regexp.MustCompile(`(^|[^.:\w])include\s*\(\s*['"]([^'"]+)['"]\s*\)`)
+	// addCSLuaFileRegex matches AddCSLuaFile("file.lua") calls, which send a
+	// file to clients
+	addCSLuaFileRegex = regexp.MustCompile(`(^|[^.:\w])AddCSLuaFile\s*\(\s*['"]([^'"]+)['"]\s*\)`)
+)
+
+// gmodLuaRoot returns the lua folder of the addon holding the entry file,
+// which include paths also resolve from, or the entry's directory when it
+// is not inside one
+func (b *Bundler) gmodLuaRoot() string {
+	for dir := b.baseDir; ; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "lua" {
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			return b.baseDir
+		}
+	}
+}
+
+// resolveInclude resolves an include path the way Garry's Mod does: from
+// the including file's directory, then from the lua folder. It returns the
+// file and its module key, the path relative to the lua folder.
+func (b *Bundler) resolveInclude(currentFile, name string) (string, string, bool) {
+	root := b.gmodLuaRoot()
+	for _, file := range []string{
+		filepath.Join(filepath.Dir(currentFile), filepath.FromSlash(name)),
+		filepath.Join(root, filepath.FromSlash(name)),
+	} {
+		if _, err := b.fs.Stat(file); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = name
+		}
+		return file, filepath.ToSlash(rel), true
+	}
+	return "", "", false
+}
+
+// processIncludes embeds the files content includes. Garry's Mod runs a
+// file again on every include, so included modules are not cached. Files
+// include cannot find, such as those of other addons, stay runtime includes.
+func (b *Bundler) processIncludes(key, filePath, content string, depth int) error {
+	if IsURL(filePath) {
+		return nil
+	}
+	for _, m := range includeRegex.FindAllStringSubmatch(content, -1) {
+		resolved, moduleKey, ok := b.resolveInclude(filePath, m[2])
+		if !ok {
+			b.recordExternal(m[2], key)
+			continue
+		}
+		if b.includes == nil {
+			b.includes = make(map[string]map[string]string)
+		}
+		if b.includes[key] == nil {
+			b.includes[key] = make(map[string]string)
+		}
+		b.includes[key][m[2]] = moduleKey
+		if b.noMemoize == nil {
+			b.noMemoize = make(map[string]bool)
+		}
+		b.noMemoize[moduleKey] = true
+		if err := b.embedLocalFile(key, moduleKey, resolved, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteIncludes replaces the include calls of embedded files with loader
+// calls, in the entry content and modules. AddCSLuaFile calls of embedded
+// files become AddCSLuaFile(), which sends the bundle itself to clients.
+func (b *Bundler) rewriteIncludes(mainContent string) string {
+	rewrite := func(key, src string) string {
+		included := b.includes[key]
+		src = includeRegex.ReplaceAllStringFunc(src, func(match string) string {
+			m := includeRegex.FindStringSubmatch(match)
+			moduleKey, ok := included[m[2]]
+			if !ok {
+				return match
+			}
+			return m[1] + b.loadModuleCall(moduleKey)
+		})
+		return addCSLuaFileRegex.ReplaceAllStringFunc(src, func(match string) string {
+			m := addCSLuaFileRegex.FindStringSubmatch(match)
+			if !b.embedsInclude(key, m[2]) {
+				return match
+			}
+			return m[1] + "AddCSLuaFile()"
+		})
+	}
+	for key, content := range b.modules {
+		b.modules[key] = rewrite(key, content)
+	}
+	return rewrite(b.entryFile, mainContent)
+}
+
+// embedsInclude reports whether the file name, as key would include it, is
+// embedded in the bundle
+func (b *Bundler) embedsInclude(key, name string) bool {
+	if moduleKey, ok := b.includes[key][name]; ok {
+		_, embedded := b.modules[moduleKey]
+		return embedded
+	}
+	file := b.entryFile
+	if source, ok := b.moduleSources[key]; ok {
+		file = source
+	}
+	if _, moduleKey, ok := b.resolveInclude(file, name); ok {
+		_, embedded := b.modules[moduleKey]
+		return embedded
+	}
+	return false
+}
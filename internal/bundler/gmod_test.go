@@ -0,0 +1,46 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle_GModIncludes(t *testing.T) {
+	b, err := NewBundler("lua/autorun/myaddon.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"lua/autorun/myaddon.lua": "AddCSLuaFile()\ninclude(\"myaddon/sh_init.lua\")\ninclude(\"othermod/api.lua\")\n",
+		"lua/myaddon/sh_init.lua": "if SERVER then\n    AddCSLuaFile(\"cl_hud.lua\")\n    AddCSLuaFile(\"cl_menu.lua\")\nend\nif CLIENT then include(\"cl_hud.lua\") end\n",
+		"lua/myaddon/cl_hud.lua":  "hook.Add(\"HUDPaint\", \"MyAddon\", function() end)\n",
+		"lua/myaddon/cl_menu.lua": "print(\"menu\")\n",
+	})
+	require.NoError(t, b.SetTarget(TargetGMod))
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, `loadModule("myaddon/sh_init.lua")`)
+	assert.Contains(t, bundle, `if CLIENT then loadModule("myaddon/cl_hud.lua") end`, "includes should resolve from the including file's directory")
+	assert.Contains(t, bundle, "    AddCSLuaFile()\n", "AddCSLuaFile of an embedded file should send the bundle")
+	assert.Contains(t, bundle, `AddCSLuaFile("cl_menu.lua")`, "files that are not embedded should still be sent")
+	assert.Contains(t, bundle, `include("othermod/api.lua")`, "files of other addons should stay runtime includes")
+	assert.Contains(t, bundle, "[\"myaddon/cl_hud.lua\"] = true", "included files should run again on every include")
+
+	var externals []string
+	for _, ext := range b.ExternalRequires() {
+		externals = append(externals, ext.Path)
+	}
+	assert.Equal(t, []string{"othermod/api.lua"}, externals)
+}
+
+func TestCheckPrimitives_GModLazyLoader(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetTarget(TargetGMod))
+	require.NoError(t, b.SetLoader(LoaderLazy))
+
+	err = b.checkPrimitives()
+	require.Error(t, err, "Garry's Mod has no loadstring or load")
+	assert.Contains(t, err.Error(), "gmod")
+}
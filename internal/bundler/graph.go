@@ -0,0 +1,126 @@
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Dependency is an edge in the dependency graph
+type Dependency struct {
+	Key     string `json:"key"`               // module key (require path) or URL
+	Remote  bool   `json:"remote"`            // pulled in via loadstring(game:HttpGet(...))() or from a remote script
+	Runtime bool   `json:"runtime,omitempty"` // left as a runtime fetch instead of being embedded
+	Depth   int    `json:"depth,omitempty"`   // remote loader depth (0 for local modules)
+}
+
+// addDependency records that parent depends on dep, ignoring duplicates
+func (b *Bundler) addDependency(parent string, dep Dependency) {
+	if b.graph == nil {
+		b.graph = make(map[string][]Dependency)
+	}
+	for _, existing := range b.graph[parent] {
+		if existing.Key == dep.Key {
+			return
+		}
+	}
+	b.graph[parent] = append(b.graph[parent], dep)
+}
+
+// GetGraph returns the dependency graph keyed by parent (the entry file or a module key)
+func (b *Bundler) GetGraph() map[string][]Dependency {
+	return b.graph
+}
+
+// GetEntryKey returns the graph key of the entry file
+func (b *Bundler) GetEntryKey() string {
+	return b.entryFile
+}
+
+// FormatGraph renders the dependency graph as a tree rooted at the entry file
+func (b *Bundler) FormatGraph() string {
+	var out strings.Builder
+	out.WriteString(b.entryFile + "\n")
+	b.formatGraphNode(&out, b.entryFile, "", map[string]bool{b.entryFile: true}, map[string]bool{})
+	return out.String()
+}
+
+func (b *Bundler) formatGraphNode(out *strings.Builder, key, prefix string, path, shown map[string]bool) {
+	deps := b.graph[key]
+	for i, dep := range deps {
+		branch, childPrefix := "├── ", prefix+"│   "
+		if i == len(deps)-1 {
+			branch, childPrefix = "└── ", prefix+"    "
+		}
+
+		label := dep.Key
+		switch {
+		case dep.Runtime:
+			label += fmt.Sprintf(" (runtime fetch, depth %d)", dep.Depth)
+		case dep.Remote && dep.Depth > 0:
+			label += fmt.Sprintf(" (remote, depth %d)", dep.Depth)
+		}
+
+		switch {
+		case path[dep.Key]:
+			out.WriteString(prefix + branch + label + " (cycle)\n")
+		case shown[dep.Key] && len(b.graph[dep.Key]) > 0:
+			out.WriteString(prefix + branch + label + " (see above)\n")
+		default:
+			out.WriteString(prefix + branch + label + "\n")
+			shown[dep.Key] = true
+			path[dep.Key] = true
+			b.formatGraphNode(out, dep.Key, childPrefix, path, shown)
+			delete(path, dep.Key)
+		}
+	}
+}
+
+// maxChains bounds Chains on graphs with many diamond-shaped dependencies
+const maxChains = 100
+
+// Chains returns every dependency chain from the entry file to the module
+// matching target, which is a module key, URL or local file path. Each chain
+// starts with the entry key and ends with the matched key.
+func (b *Bundler) Chains(target string) [][]string {
+	var chains [][]string
+	path := []string{b.entryFile}
+	onPath := map[string]bool{b.entryFile: true}
+
+	var visit func(key string)
+	visit = func(key string) {
+		for _, dep := range b.graph[key] {
+			if len(chains) >= maxChains || onPath[dep.Key] {
+				continue
+			}
+			path = append(path, dep.Key)
+			if b.matchesModule(dep.Key, target) {
+				chains = append(chains, append([]string(nil), path...))
+			} else {
+				onPath[dep.Key] = true
+				visit(dep.Key)
+				delete(onPath, dep.Key)
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	visit(b.entryFile)
+	return chains
+}
+
+// matchesModule reports whether the graph node key refers to target
+func (b *Bundler) matchesModule(key, target string) bool {
+	if key == target {
+		return true
+	}
+	file, ok := b.moduleSources[key]
+	if !ok {
+		return false
+	}
+	if IsURL(file) || IsURL(target) {
+		return file == target
+	}
+	absFile, err1 := filepath.Abs(file)
+	absTarget, err2 := filepath.Abs(target)
+	return err1 == nil && err2 == nil && absFile == absTarget
+}
@@ -0,0 +1,137 @@
+package bundler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLoaderChain serves loader.lua -> stage2.lua -> payload.lua
+func newLoaderChain(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loader.lua":
+			fmt.Fprintf(w, "return loadstring(game:HttpGet('%s/stage2.lua'))()", srv.URL)
+		case "/stage2.lua":
+			fmt.Fprintf(w, "return loadstring(game:HttpGet('%s/payload.lua'))()", srv.URL)
+		case "/payload.lua":
+			fmt.Fprint(w, "return 'payload'")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeLoaderEntry(t *testing.T, srv *httptest.Server) string {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	main := fmt.Sprintf("local util = require(\"util\")\nlocal lib = loadstring(game:HttpGet('%s/loader.lua'))()", srv.URL)
+	require.NoError(t, os.WriteFile(mainFile, []byte(main), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "util.lua"), []byte("return {}"), 0644))
+	return mainFile
+}
+
+func TestBundle_FlattenDepth(t *testing.T) {
+	srv := newLoaderChain(t)
+
+	tests := []struct {
+		name     string
+		depth    int
+		embedded []string
+		runtime  []string
+	}{
+		{"unlimited", -1, []string{"/loader.lua", "/stage2.lua", "/payload.lua"}, nil},
+		{"depth 0", 0, nil, []string{"/loader.lua"}},
+		{"depth 2", 2, []string{"/loader.lua", "/stage2.lua"}, []string{"/payload.lua"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBundler(writeLoaderEntry(t, srv), false, false)
+			require.NoError(t, err, "NewBundler should not fail")
+			b.SetFlattenDepth(tt.depth)
+
+			result, err := b.Bundle(false)
+			require.NoError(t, err, "Bundle() should not fail")
+
+			for _, path := range tt.embedded {
+				assert.Contains(t, b.GetModules(), srv.URL+path, "%s should be embedded", path)
+				assert.Contains(t, result, fmt.Sprintf("loadModule(\"%s%s\")", srv.URL, path))
+			}
+			for _, path := range tt.runtime {
+				assert.NotContains(t, b.GetModules(), srv.URL+path, "%s should not be embedded", path)
+				assert.Contains(t, result, fmt.Sprintf("loadstring(game:HttpGet('%s%s'))()", srv.URL, path), "%s should stay a runtime fetch", path)
+			}
+		})
+	}
+}
+
+func TestFormatGraph(t *testing.T) {
+	srv := newLoaderChain(t)
+	mainFile := writeLoaderEntry(t, srv)
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+	b.SetFlattenDepth(2)
+
+	_, err = b.Bundle(false)
+	require.NoError(t, err, "Bundle() should not fail")
+
+	want := mainFile + "\n" +
+		"├── util\n" +
+		"└── " + srv.URL + "/loader.lua (remote, depth 1)\n" +
+		"    └── " + srv.URL + "/stage2.lua (remote, depth 2)\n" +
+		"        └── " + srv.URL + "/payload.lua (runtime fetch, depth 3)\n"
+	assert.Equal(t, want, b.FormatGraph())
+}
+
+func TestFormatGraph_Cycle(t *testing.T) {
+	b := &Bundler{entryFile: "main.lua"}
+	b.addDependency("main.lua", Dependency{Key: "a"})
+	b.addDependency("main.lua", Dependency{Key: "b"})
+	b.addDependency("a", Dependency{Key: "b"})
+	b.addDependency("a", Dependency{Key: "b"})
+	b.addDependency("b", Dependency{Key: "a"})
+
+	want := "main.lua\n" +
+		"├── a\n" +
+		"│   └── b\n" +
+		"│       └── a (cycle)\n" +
+		"└── b (see above)\n"
+	assert.Equal(t, want, b.FormatGraph())
+}
+
+func TestChains(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "modules"), 0755))
+	require.NoError(t, os.WriteFile(mainFile, []byte(`local config = require("modules.config")
+local ui = require("modules.ui")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "modules", "config.lua"), []byte(`return require("modules.log")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "modules", "ui.lua"), []byte(`local c = require("modules.config")
+return require("modules.log")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "modules", "log.lua"), []byte(`return {}`), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	want := [][]string{
+		{mainFile, "modules.config", "modules.log"},
+		{mainFile, "modules.ui", "modules.config", "modules.log"},
+		{mainFile, "modules.ui", "modules.log"},
+	}
+	assert.Equal(t, want, b.Chains("modules.log"))
+	assert.Equal(t, want, b.Chains(filepath.Join(tempDir, "modules", "log.lua")), "file paths should match too")
+	assert.Empty(t, b.Chains("modules.missing"))
+}
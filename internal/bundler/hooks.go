@@ -0,0 +1,65 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetHooks runs modules around the entry file without it requiring them:
+// prelude modules before the entry, in order, and epilogue modules after it
+// returns, such as environment checks, feature flag setup or telemetry.
+// Each is a require path resolved from the entry file, and embedded like a
+// module the entry requires. The bundle still returns what the entry does.
+func (b *Bundler) SetHooks(prelude, epilogue []string) {
+	b.prelude = prelude
+	b.epilogue = epilogue
+}
+
+// resolveHooks embeds the prelude and epilogue modules, and the error
+// handler of a safe-wrapped bundle
+func (b *Bundler) resolveHooks() error {
+	hooks := append(append([]string{}, b.prelude...), b.epilogue...)
+	if b.safeWrap && b.errorHandler != "" {
+		hooks = append(hooks, b.errorHandler)
+	}
+	for _, hook := range hooks {
+		if IsURL(hook) {
+			return fmt.Errorf("hook %s: prelude, epilogue and error handler modules must be project files", hook)
+		}
+		resolvedPath := b.resolveVariant(hook, b.resolveModulePath(b.entryFile, hook))
+		if err := b.embedLocalFile(b.entryFile, hook, resolvedPath, 0); err != nil {
+			return fmt.Errorf("hook %s: %w", hook, err)
+		}
+	}
+	return nil
+}
+
+// writePrelude runs the prelude modules with run, which returns the
+// expression loading a module
+func (b *Bundler) writePrelude(output *strings.Builder, run func(key string) string) {
+	if len(b.prelude) == 0 {
+		return
+	}
+	output.WriteString("-- Prelude\n")
+	for _, hook := range b.prelude {
+		output.WriteString(fmt.Sprintf("local _ = %s\n", run(hook)))
+	}
+	output.WriteString("\n")
+}
+
+// wrapEpilogue returns the lines opening and closing the main script so
+// that the epilogue modules run after it, passing its results through
+func (b *Bundler) wrapEpilogue(run func(key string) string) (string, string) {
+	if len(b.epilogue) == 0 {
+		return "", ""
+	}
+	var open strings.Builder
+	open.WriteString("-- Epilogue, run with the results of the main script\n")
+	open.WriteString("return (function(...)\n")
+	for _, hook := range b.epilogue {
+		open.WriteString(fmt.Sprintf("    local _ = %s\n", run(hook)))
+	}
+	open.WriteString("    return ...\n")
+	open.WriteString("end)((function(...)\n")
+	return open.String(), "end)(...))\n"
+}
@@ -0,0 +1,77 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHookBundler(t *testing.T) *Bundler {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":           "local flags = require(\"flags\")\nreturn flags.ready",
+		"flags.lua":          "return { ready = true }\n",
+		"checks/env.lua":     "assert(game, \"not running in Roblox\")\n",
+		"telemetry/init.lua": "print(\"done\")\n",
+	})
+	return b
+}
+
+func TestBundle_Prelude(t *testing.T) {
+	b := newHookBundler(t)
+	b.SetHooks([]string{"checks.env", "flags"}, nil)
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "-- Prelude\nlocal _ = loadModule(\"checks.env\")\nlocal _ = loadModule(\"flags\")\n\n-- Main Script\n")
+	assert.Contains(t, result, "-- Module: checks.env\n")
+	assert.Equal(t, []string{"main.lua", "checks.env"}, b.Chains("checks.env")[0], "hooks depend on the entry file")
+	_, err = parser.Parse(result)
+	assert.NoError(t, err)
+}
+
+func TestBundle_Epilogue(t *testing.T) {
+	b := newHookBundler(t)
+	b.SetHooks(nil, []string{"telemetry.init"})
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "-- Epilogue, run with the results of the main script\n"+
+		"return (function(...)\n"+
+		"    local _ = loadModule(\"telemetry.init\")\n"+
+		"    return ...\n"+
+		"end)((function(...)\n"+
+		"-- Main Script\n"+
+		"local flags = loadModule(\"flags\")\n"+
+		"return flags.ready\n"+
+		"end)(...))\n")
+	_, err = parser.Parse(result)
+	assert.NoError(t, err)
+
+	main := b.SourceMap()[len(b.SourceMap())-1]
+	assert.Equal(t, "main.lua", main.Module)
+	assert.Equal(t, 2, main.EndLine-main.StartLine)
+}
+
+func TestBundle_EpilogueUsesClosureLoader(t *testing.T) {
+	b := newHookBundler(t)
+	require.NoError(t, b.SetLoader(LoaderInline))
+	b.SetHooks(nil, []string{"telemetry.init"})
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "local _ = loadModule(\"telemetry.init\")")
+	assert.Contains(t, b.GetWarnings()[0], "epilogue modules must run after the main script")
+}
+
+func TestResolve_MissingHook(t *testing.T) {
+	b := newHookBundler(t)
+	b.SetHooks([]string{"checks.missing"}, nil)
+
+	_, err := b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hook checks.missing: failed to read file /checks/missing.lua")
+}
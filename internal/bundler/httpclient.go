@@ -0,0 +1,186 @@
+package bundler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/cache"
+)
+
+const (
+	defaultHTTPTimeout     = 30 * time.Second
+	defaultMaxConnsPerHost = 8
+	defaultMaxRedirects    = 10
+)
+
+// HTTPOptions configures the client used to download remote modules
+type HTTPOptions struct {
+	Timeout         time.Duration // overall request timeout (default 30s)
+	Proxy           string        // explicit proxy URL (http, https, socks5, socks5h); empty falls back to HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	ProxyBypass     []string      // hosts fetched directly: exact host, ".domain"/"*.domain" suffix, IP or CIDR
+	MaxConnsPerHost int           // per-host connection limit (default 8)
+	MaxRedirects    int           // redirects followed per request (default 10)
+	NoRedirects     bool          // fail on any redirect instead of following it
+	RemoteCache     string        // shared cache: http(s)://host/path or s3://bucket/prefix, "" for none
+	RemoteCacheMode string        // cache.Mode* of RemoteCache (default readwrite)
+	Offline         bool          // serve remote scripts from the local cache only, expired or not
+}
+
+// supportedProxySchemes lists proxy URL schemes understood by the transport.
+// socks5h resolves hostnames on the proxy side, which is what Tor expects.
+var supportedProxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// newHTTPClient builds an HTTP/2-capable client with pooled keep-alive connections
+func newHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultHTTPTimeout
+	}
+	if opts.MaxConnsPerHost <= 0 {
+		opts.MaxConnsPerHost = defaultMaxConnsPerHost
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = defaultMaxRedirects
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", opts.Proxy)
+		}
+		if !containsString(supportedProxySchemes, proxyURL.Scheme) {
+			return nil, fmt.Errorf("unsupported proxy scheme %q (supported: %s)", proxyURL.Scheme, strings.Join(supportedProxySchemes, ", "))
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	if len(opts.ProxyBypass) > 0 {
+		proxy = bypassProxy(proxy, opts.ProxyBypass)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialContext(),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opts.MaxConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout:       opts.Timeout,
+		Transport:     transport,
+		CheckRedirect: redirectPolicy(opts),
+	}, nil
+}
+
+// redirectPolicy enforces --no-redirects and --max-redirects
+func redirectPolicy(opts HTTPOptions) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if opts.NoRedirects {
+			return fmt.Errorf("redirects are disabled (%s redirects to %s)", via[0].URL, req.URL)
+		}
+		if len(via) >= opts.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+		}
+		return nil
+	}
+}
+
+// redirectChain returns every URL visited to produce resp, oldest first
+func redirectChain(resp *http.Response) []*url.URL {
+	var chain []*url.URL
+	for req := resp.Request; req != nil; {
+		chain = append([]*url.URL{req.URL}, chain...)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	return chain
+}
+
+// redirectWarnings describes risky hops in a redirect chain: cross-domain
+// redirects and HTTPS to HTTP downgrades
+func redirectWarnings(chain []*url.URL) []string {
+	var warnings []string
+	for i := 1; i < len(chain); i++ {
+		from, to := chain[i-1], chain[i]
+		if from.Scheme == "https" && to.Scheme == "http" {
+			warnings = append(warnings, fmt.Sprintf("insecure redirect from HTTPS to HTTP: %s -> %s", from, to))
+		} else if !strings.EqualFold(from.Hostname(), to.Hostname()) {
+			warnings = append(warnings, fmt.Sprintf("cross-domain redirect: %s -> %s", from, to))
+		}
+	}
+	return warnings
+}
+
+// bypassProxy wraps a proxy func so that requests to bypassed hosts connect directly
+func bypassProxy(proxy func(*http.Request) (*url.URL, error), bypass []string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if shouldBypassProxy(req.URL.Hostname(), bypass) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+// shouldBypassProxy reports whether host matches any bypass rule
+func shouldBypassProxy(host string, bypass []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, rule := range bypass {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch {
+		case rule == "":
+			continue
+		case rule == "*" || rule == host:
+			return true
+		case strings.HasPrefix(rule, "*."):
+			if strings.HasSuffix(host, rule[1:]) {
+				return true
+			}
+		case strings.HasPrefix(rule, "."):
+			if strings.HasSuffix(host, rule) || host == rule[1:] {
+				return true
+			}
+		case strings.Contains(rule, "/"):
+			if _, cidr, err := net.ParseCIDR(rule); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// SetHTTPOptions replaces the HTTP client used for remote downloads and
+// shares the download cache through the remote cache, if any
+func (b *Bundler) SetHTTPOptions(opts HTTPOptions) error {
+	client, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	b.httpClient = client
+	b.offline = opts.Offline
+	b.cache.SetOffline(opts.Offline)
+	if opts.RemoteCache == "" || opts.Offline {
+		return nil
+	}
+	backend, err := cache.NewBackend(opts.RemoteCache, client)
+	if err != nil {
+		return err
+	}
+	mode := opts.RemoteCacheMode
+	if mode == "" {
+		mode = cache.ModeReadWrite
+	}
+	return b.cache.SetRemote(backend, mode)
+}
@@ -0,0 +1,172 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// DefaultLocaleFunction is the global function marking translatable strings
+// when none is configured
+const DefaultLocaleFunction = "L"
+
+// LocaleString is a translatable string: the argument of a L("text") call,
+// keyed by the text itself, or the text of a L("key", "text") call
+type LocaleString struct {
+	Key  string
+	Text string
+	File string // local path or URL
+	Line int
+}
+
+// SetLocaleFunction enables translation: calls of the global function name
+// with string literals, such as L("Play") or L("menu.play", "Play"), are
+// translatable strings, and the bundle defines name as a callable table
+// looking them up in the locales set with SetLocales. An empty name
+// disables translation.
+func (b *Bundler) SetLocaleFunction(name string) error {
+	if name != "" && (!identifierRegex.MatchString(name) || parser.IsKeyword(name)) {
+		return fmt.Errorf("invalid locale function %q: must be a Lua identifier", name)
+	}
+	b.localeFunction = name
+	return nil
+}
+
+// SetLocales embeds translations: locales maps a locale name, such as "de",
+// to translated texts by key. locale is the one selected when the script
+// starts; scripts switch at runtime by setting L.locale. Keys missing from
+// the selected locale fall back to the source text.
+func (b *Bundler) SetLocales(locales map[string]map[string]string, locale string) error {
+	if locale != "" {
+		if _, ok := locales[locale]; !ok {
+			names := make([]string, 0, len(locales))
+			for name := range locales {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown locale %q (available: %s)", locale, strings.Join(names, ", "))
+		}
+	}
+	b.locales = locales
+	b.locale = locale
+	return nil
+}
+
+// ExtractStrings returns the translatable strings of the last Resolve,
+// sorted by key. Local files are read from disk, so obfuscation does not
+// hide them. A key used with two different texts is an error.
+func (b *Bundler) ExtractStrings() ([]LocaleString, error) {
+	name := b.localeFunction
+	if name == "" {
+		name = DefaultLocaleFunction
+	}
+
+	sources := map[string]string{b.entryFile: b.entryContent}
+	for key, content := range b.modules {
+		source := b.moduleSource(key)
+		if !IsURL(source) {
+			if raw, err := b.fs.ReadFile(source); err == nil {
+				content = string(raw)
+			}
+		}
+		sources[source] = content
+	}
+	files := make([]string, 0, len(sources))
+	for file := range sources {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	byKey := make(map[string]LocaleString)
+	for _, file := range files {
+		tokens, err := parser.Tokenize(sources[file])
+		if err != nil {
+			continue
+		}
+		for _, call := range parser.FindStringCalls(tokens, name) {
+			s := LocaleString{Key: call.Args[0], Text: call.Args[0], File: b.displaySource(file), Line: call.Token.Line}
+			if len(call.Args) > 1 {
+				s.Text = call.Args[1]
+			}
+			if prev, ok := byKey[s.Key]; ok {
+				if prev.Text != s.Text {
+					return nil, fmt.Errorf("string %q has different texts at %s:%d and %s:%d", s.Key, prev.File, prev.Line, s.File, s.Line)
+				}
+				continue
+			}
+			byKey[s.Key] = s
+		}
+	}
+
+	strs := make([]LocaleString, 0, len(byKey))
+	for _, s := range byKey {
+		strs = append(strs, s)
+	}
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Key < strs[j].Key })
+	return strs, nil
+}
+
+// warnUntranslated warns, per embedded locale, about translatable strings
+// it has no text for
+func (b *Bundler) warnUntranslated() {
+	if b.localeFunction == "" || len(b.locales) == 0 {
+		return
+	}
+	strs, err := b.ExtractStrings()
+	if err != nil {
+		b.warnf("i18n: %v", err)
+		return
+	}
+
+	names := make([]string, 0, len(b.locales))
+	for name := range b.locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var missing []string
+		for _, s := range strs {
+			if _, ok := b.locales[name][s.Key]; !ok {
+				missing = append(missing, s.Key)
+			}
+		}
+		if len(missing) > 0 {
+			b.warnf("locale %s: %d of %d strings untranslated, such as %q", name, len(missing), len(strs), missing[0])
+		}
+	}
+}
+
+// localeTable returns the Lua expression defining the locale function: a
+// table holding the selected locale and the translations, called as
+// L(key, text)
+func (b *Bundler) localeTable() string {
+	names := make([]string, 0, len(b.locales))
+	for name := range b.locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var locales []string
+	for _, name := range names {
+		keys := make([]string, 0, len(b.locales[name]))
+		for key := range b.locales[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]string, 0, len(keys))
+		for _, key := range keys {
+			entries = append(entries, fmt.Sprintf("[%s] = %s", quoteLua(key), quoteLua(b.locales[name][key])))
+		}
+		locales = append(locales, fmt.Sprintf("[%s] = {%s}", quoteLua(name), strings.Join(entries, ", ")))
+	}
+
+	locale := "nil"
+	if b.locale != "" {
+		locale = quoteLua(b.locale)
+	}
+	return fmt.Sprintf("setmetatable({locale = %s, locales = {%s}}, {__call = function(self, key, text) "+
+		"local strings = self.locales[self.locale or \"\"] return strings and strings[key] or text or key end})",
+		locale, strings.Join(locales, ", "))
+}
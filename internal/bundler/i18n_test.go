@@ -0,0 +1,75 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var i18nProject = MemoryFS{
+	"main.lua": "local menu = require(\"menu\")\nprint(L(\"Hello\"))\nprint(ui.L(\"not a string\"))\n",
+	"menu.lua": "return { title = L(\"menu.title\", \"Main menu\"), again = L \"Hello\", dynamic = L(name) }\n",
+}
+
+func TestExtractStrings(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(i18nProject)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	strs, err := b.ExtractStrings()
+	require.NoError(t, err)
+	require.Len(t, strs, 2)
+	assert.Equal(t, LocaleString{Key: "Hello", Text: "Hello", File: "main.lua", Line: 2}, strs[0])
+	assert.Equal(t, "menu.title", strs[1].Key)
+	assert.Equal(t, "Main menu", strs[1].Text)
+	assert.Equal(t, 1, strs[1].Line)
+}
+
+func TestExtractStrings_Conflict(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "L(\"quit\", \"Quit\")\nL(\"quit\", \"Exit\")\n"})
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	_, err = b.ExtractStrings()
+	assert.ErrorContains(t, err, `string "quit" has different texts at main.lua:1 and main.lua:2`)
+}
+
+func TestSetLocales(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(i18nProject)
+	require.NoError(t, b.SetLocaleFunction(DefaultLocaleFunction))
+	require.NoError(t, b.SetLocales(map[string]map[string]string{
+		"de": {"Hello": "Hallo", "menu.title": "Hauptmenü"},
+		"fr": {"Hello": "Bonjour"},
+	}, "de"))
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, `local L = setmetatable({locale = "de", locales = {["de"] = {["Hello"] = "Hallo", ["menu.title"] = "Hauptmenü"}, ["fr"] = {["Hello"] = "Bonjour"}}}`)
+	assert.Equal(t, []string{`locale fr: 1 of 2 strings untranslated, such as "menu.title"`}, b.GetWarnings())
+}
+
+func TestSetLocales_Invalid(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, b.SetLocales(map[string]map[string]string{"de": {}, "en": {}}, "fr"), `unknown locale "fr" (available: de, en)`)
+	assert.Error(t, b.SetLocaleFunction("end"))
+	assert.Error(t, b.SetLocaleFunction("tr.get"))
+}
+
+func TestLocaleFunction_Disabled(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(i18nProject)
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "local L =", "the locale function should only be defined when enabled")
+}
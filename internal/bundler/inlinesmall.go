@@ -0,0 +1,200 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// DefaultInlineSmall is the token limit of --inline-small without a value
+const DefaultInlineSmall = 16
+
+// SetInlineSmall inlines modules of fewer than maxTokens tokens that only
+// return a constant, such as a version string or a small settings table,
+// at their require sites instead of embedding them behind the loader. A
+// module returning a table is only inlined when it is required once, so
+// every require still sees the same table. 0 turns inlining off.
+func (b *Bundler) SetInlineSmall(maxTokens int) error {
+	if maxTokens < 0 {
+		return fmt.Errorf("invalid inline size %d (expected a token count, or 0 to turn inlining off)", maxTokens)
+	}
+	b.inlineSmall = maxTokens
+	return nil
+}
+
+// GetInlinedModules returns the sorted modules the last generated bundle
+// inlined at their require sites rather than embedding them
+func (b *Bundler) GetInlinedModules() []string {
+	return sortedKeys(b.inlinedSmall)
+}
+
+// smallModules returns the modules to inline, mapped to the parenthesized
+// expression replacing their requires. Every require of such a module must
+// be a plain require of its key in an expression, not a statement or the
+// start of one, so that the expression can take its place.
+func (b *Bundler) smallModules(mainContent string) map[string]string {
+	if b.inlineSmall <= 0 {
+		return nil
+	}
+	hooks := make(map[string]bool, len(b.prelude)+len(b.epilogue))
+	for _, key := range append(append([]string{}, b.prelude...), b.epilogue...) {
+		hooks[key] = true
+	}
+
+	candidates := make(map[string]string)
+	tables := make(map[string]bool)
+	for key, content := range b.modules {
+		// Prelude and epilogue modules are run by key, remote modules can
+		// be fetched by loadstring as well as required
+		if hooks[key] || b.httpModules[key] {
+			continue
+		}
+		if expr, table, ok := smallConstant(content, b.inlineSmall); ok {
+			candidates[key] = "(" + expr + ")"
+			tables[key] = table
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sites := make(map[string]int)
+	blocked := make(map[string]bool)
+	if !requireSites(mainContent, candidates, sites, blocked) {
+		return nil
+	}
+	for _, content := range b.modules {
+		if !requireSites(content, candidates, sites, blocked) {
+			return nil
+		}
+	}
+	for key := range candidates {
+		if blocked[key] || sites[key] == 0 || tables[key] && sites[key] > 1 {
+			delete(candidates, key)
+		}
+	}
+	return candidates
+}
+
+// smallConstant returns the expression a module of fewer than maxTokens
+// tokens consists of a single return of, and whether it builds a table. ok
+// is false for anything that could read a variable or call a function,
+// since those could mean something else at the require site. require turns
+// a nil result into true, so return nil is inlined as true, and anything
+// else that could be nil is not inlined.
+func smallConstant(content string, maxTokens int) (expr string, table, ok bool) {
+	tokens, err := parser.Tokenize(content)
+	if err != nil {
+		return "", false, false
+	}
+	count := 0
+	for _, tok := range tokens {
+		if tok.Kind != parser.Comment && tok.Kind != parser.EOF {
+			count++
+		}
+	}
+	if count >= maxTokens {
+		return "", false, false
+	}
+
+	chunk, err := parser.Parse(content)
+	if err != nil || len(chunk.Block.Stmts) != 1 {
+		return "", false, false
+	}
+	ret, isReturn := chunk.Block.Stmts[0].(*parser.ReturnStmt)
+	if !isReturn || len(ret.Values) != 1 {
+		return "", false, false
+	}
+	ok = true
+	parser.Walk(ret.Values[0], func(n parser.Node) bool {
+		switch n.(type) {
+		case *parser.TableExpr:
+			table = true
+		case *parser.NilExpr, *parser.TrueExpr, *parser.FalseExpr, *parser.NumberExpr, *parser.StringExpr,
+			*parser.TableField, *parser.BinaryExpr, *parser.UnaryExpr, *parser.ParenExpr, *parser.IfExpr, *parser.TypeAssertExpr:
+		default:
+			ok = false
+		}
+		return ok
+	})
+	value := ret.Values[0]
+	if _, isNil := value.(*parser.NilExpr); isNil {
+		return "true", false, ok
+	}
+	if mayBeNil(value) {
+		return "", false, false
+	}
+	span := value.Range()
+	return content[span.Start:span.End], table, ok
+}
+
+// mayBeNil reports whether a constant expression could evaluate to nil
+func mayBeNil(e parser.Expr) bool {
+	switch e := e.(type) {
+	case *parser.NilExpr:
+		return true
+	case *parser.ParenExpr:
+		return mayBeNil(e.X)
+	case *parser.TypeAssertExpr:
+		return mayBeNil(e.X)
+	case *parser.BinaryExpr:
+		return (e.Op == "and" || e.Op == "or") && (mayBeNil(e.X) || mayBeNil(e.Y))
+	case *parser.IfExpr:
+		for _, then := range e.Thens {
+			if mayBeNil(then) {
+				return true
+			}
+		}
+		return mayBeNil(e.Else)
+	}
+	return false
+}
+
+// requireSites counts the requires of candidates in content into sites,
+// and marks candidates blocked when a require of them is or starts a
+// statement. It returns false when content does not parse, as its requires
+// cannot be told apart.
+func requireSites(content string, candidates map[string]string, sites map[string]int, blocked map[string]bool) bool {
+	if !strings.Contains(content, "require") {
+		return true
+	}
+	chunk, err := parser.Parse(content)
+	if err != nil {
+		return false
+	}
+	starts := make(map[int]bool)
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if stmt, ok := n.(parser.Stmt); ok {
+			starts[stmt.Range().Start] = true
+		}
+		call, ok := n.(*parser.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		if fn, ok := call.Fn.(*parser.Ident); !ok || fn.Name != "require" {
+			return true
+		}
+		key := requireArgument(content, call.Args[0])
+		if _, ok := candidates[key]; !ok {
+			return true
+		}
+		if starts[call.Start] {
+			blocked[key] = true
+		}
+		sites[key]++
+		return true
+	})
+	return true
+}
+
+// requireArgument returns the module a require argument names: the
+// contents of a quoted string, or the source of anything else
+func requireArgument(content string, arg parser.Expr) string {
+	span := arg.Range()
+	text := content[span.Start:span.End]
+	if _, ok := arg.(*parser.StringExpr); ok && len(text) >= 2 && (text[0] == '"' || text[0] == '\'') {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
@@ -0,0 +1,139 @@
+package bundler
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeInlineProject writes a main.lua requiring small and not so small modules
+func writeInlineProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.lua": `local version = require("version")
+local settings = require("settings")
+local shared = require("shared")
+require("setup")
+local other = require("shared")
+local pi = require("pi")
+local big = require("big")
+local loaded = require("nilmod")
+print(version, require("version"), settings.debug, shared, other, pi, big)
+`,
+		"version.lua":  "-- Release version\nreturn \"1.2.0\"\n",
+		"settings.lua": "return { debug = false, retries = 2 * 3 }\n",
+		"shared.lua":   "return {}\n",
+		"setup.lua":    "return true\n",
+		"pi.lua":       "return math.pi\n",
+		"big.lua":      "return { 1, 2, 3, 4, 5, 6, 7, 8, 9, 10 }\n",
+		"nilmod.lua":   "return nil\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return filepath.Join(dir, "main.lua")
+}
+
+func TestSetInlineSmall(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, b.inlineSmall)
+	assert.NoError(t, b.SetInlineSmall(16))
+	assert.Equal(t, 16, b.inlineSmall)
+	assert.Error(t, b.SetInlineSmall(-1))
+}
+
+func TestSmallConstant(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		expr    string
+		table   bool
+		ok      bool
+	}{
+		{"string", "return \"1.2.0\"\n", "\"1.2.0\"", false, true},
+		{"comment", "-- version\nreturn 3 -- patch\n", "3", false, true},
+		{"table", "return { debug = false, [\"k\"] = -1 }", "{ debug = false, [\"k\"] = -1 }", true, true},
+		{"operators", "return (2 ^ 10) .. \"kb\"", "(2 ^ 10) .. \"kb\"", false, true},
+		{"nil", "return nil\n", "true", false, true},
+		{"maybe nil", "return false or nil", "", false, false},
+		{"parenthesized nil", "return (nil)", "", false, false},
+		{"global", "return math.pi", "", false, false},
+		{"call", "return setmetatable({}, {})", "", false, false},
+		{"function", "return function() end", "", false, false},
+		{"vararg", "return ...", "", false, false},
+		{"statements", "local x = 1\nreturn 1", "", false, false},
+		{"two values", "return 1, 2", "", false, false},
+		{"too long", "return { 1, 2, 3, 4, 5, 6, 7, 8 }", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, table, ok := smallConstant(tt.content, 16)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.expr, expr)
+				assert.Equal(t, tt.table, table)
+			}
+		})
+	}
+}
+
+func TestBundle_InlineSmall(t *testing.T) {
+	b, err := New(writeInlineProject(t), WithLogger(io.Discard))
+	require.NoError(t, err)
+	require.NoError(t, b.SetInlineSmall(16))
+	result, err := b.BundleWithResult(false)
+	require.NoError(t, err)
+	output := result.Output
+
+	assert.Equal(t, []string{"nilmod", "settings", "version"}, result.Inlined)
+	assert.Contains(t, output, "local loaded = (true)\n", "require yields true for a module returning nil")
+	assert.Contains(t, output, "local version = (\"1.2.0\")\n")
+	assert.Contains(t, output, "print(version, (\"1.2.0\"), settings.debug")
+	assert.Contains(t, output, "local settings = ({ debug = false, retries = 2 * 3 })\n")
+	assert.NotContains(t, output, `EmbeddedModules["version"]`)
+	assert.NotContains(t, output, `EmbeddedModules["settings"]`)
+
+	// Shared tables, requires as statements, globals and big modules stay
+	for _, key := range []string{"shared", "setup", "pi", "big"} {
+		assert.Contains(t, output, "EmbeddedModules[\""+key+"\"] = function(...)")
+	}
+	_, err = parser.Parse(output)
+	assert.NoError(t, err)
+	assert.Len(t, b.GetModules(), 7, "inlined modules are still part of the build")
+}
+
+func TestBundle_InlineSmallLoaders(t *testing.T) {
+	for _, loader := range []string{LoaderInline, LoaderLazy} {
+		t.Run(loader, func(t *testing.T) {
+			b, err := New(writeInlineProject(t), WithLogger(io.Discard))
+			require.NoError(t, err)
+			require.NoError(t, b.SetInlineSmall(16))
+			require.NoError(t, b.SetLoader(loader))
+			output, err := b.Bundle(false)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"nilmod", "settings", "version"}, b.GetInlinedModules())
+			assert.Contains(t, output, "local loaded = (true)\n")
+			assert.Contains(t, output, "local version = (\"1.2.0\")\n")
+			assert.NotContains(t, output, "-- Module: version\n")
+			assert.Contains(t, output, "-- Module: shared\n")
+		})
+	}
+}
+
+func TestBundle_InlineSmallOff(t *testing.T) {
+	b, err := New(writeInlineProject(t), WithLogger(io.Discard))
+	require.NoError(t, err)
+	output, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Empty(t, b.GetInlinedModules())
+	assert.Contains(t, output, `EmbeddedModules["version"] = function(...)`)
+}
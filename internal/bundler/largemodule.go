@@ -0,0 +1,80 @@
+package bundler
+
+import (
+	"runtime"
+	"sort"
+)
+
+// DefaultLargeModuleSize is the size in bytes from which a local file
+// counts as large, such as a generated data table rather than code
+const DefaultLargeModuleSize = 1 << 20
+
+// FileProfile is what reading and obfuscating one local module cost a build
+type FileProfile struct {
+	Module    string `json:"module"`    // module key
+	Source    string `json:"source"`    // file it was read from
+	Size      int    `json:"size"`      // bytes read
+	Embedded  int    `json:"embedded"`  // bytes embedded, after obfuscation
+	Allocated uint64 `json:"allocated"` // heap bytes allocated reading and obfuscating it
+	Large     bool   `json:"large"`     // at or over the large module size, so not obfuscated
+}
+
+// SetLargeModuleSize sets the size in bytes from which local files count as
+// large. Large files are embedded without obfuscation, which would take
+// long over megabytes of data and hide nothing worth hiding; a warning
+// names each one an obfuscator would otherwise have run on. 0 or less
+// treats no file as large.
+func (b *Bundler) SetLargeModuleSize(size int64) {
+	b.largeModuleSize = size
+}
+
+// SetProfile records a FileProfile for every local module the next build
+// embeds. Measuring stops the world briefly per file, so it is off by
+// default.
+func (b *Bundler) SetProfile(enabled bool) {
+	b.profile = enabled
+	b.fileProfiles = nil
+}
+
+// FileProfiles returns the profiles of the local files of the last build,
+// the most allocating first
+func (b *Bundler) FileProfiles() []FileProfile {
+	profiles := append([]FileProfile(nil), b.fileProfiles...)
+	sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Allocated > profiles[j].Allocated })
+	return profiles
+}
+
+// isLarge reports whether content is at or over the large module size
+func (b *Bundler) isLarge(content string) bool {
+	return b.largeModuleSize > 0 && int64(len(content)) >= b.largeModuleSize
+}
+
+// readModule reads and obfuscates the local file source embedded as key,
+// returning the file as written and as embedded. With profiling on, the
+// heap it allocated is recorded.
+func (b *Bundler) readModule(key, source string) (string, string, error) {
+	var before runtime.MemStats
+	if b.profile {
+		runtime.ReadMemStats(&before)
+	}
+
+	content, err := b.readSource(source)
+	if err != nil {
+		return "", "", err
+	}
+	embedded := b.obfuscate(key, source, content)
+
+	if b.profile {
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		b.fileProfiles = append(b.fileProfiles, FileProfile{
+			Module:    key,
+			Source:    b.displaySource(source),
+			Size:      len(content),
+			Embedded:  len(embedded),
+			Allocated: after.TotalAlloc - before.TotalAlloc,
+			Large:     b.isLarge(content),
+		})
+	}
+	return content, embedded, nil
+}
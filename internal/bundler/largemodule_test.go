@@ -0,0 +1,91 @@
+package bundler
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLargeModuleBundler(t *testing.T) *Bundler {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "local data = require(\"data\")\nlocal util = require(\"util\")\n",
+		"data.lua": "return {" + strings.Repeat("{id = 1, name = \"item\"},\n", 100) + "}\n",
+		"util.lua": "local helper = 1\nreturn helper\n",
+	})
+	b.SetObfuscationLevel(1)
+	return b
+}
+
+func TestLargeModule_NotObfuscated(t *testing.T) {
+	b := newLargeModuleBundler(t)
+	b.SetLargeModuleSize(1024)
+
+	_, err := b.Resolve()
+	require.NoError(t, err)
+	data, _ := b.fs.ReadFile("data.lua")
+	assert.Equal(t, string(data), b.modules["data"], "large modules are embedded as written")
+	assert.Equal(t, []string{"data is 2510 bytes, over the large module size of 1024; it is embedded without obfuscation"}, b.GetWarnings())
+}
+
+func TestLargeModule_NoLimit(t *testing.T) {
+	b := newLargeModuleBundler(t)
+	b.SetLargeModuleSize(0)
+
+	_, err := b.Resolve()
+	require.NoError(t, err)
+	assert.Empty(t, b.GetWarnings())
+}
+
+func TestFileProfiles(t *testing.T) {
+	b := newLargeModuleBundler(t)
+	b.SetLargeModuleSize(1024)
+	_, err := b.Resolve()
+	require.NoError(t, err)
+	assert.Empty(t, b.FileProfiles(), "profiling is off by default")
+
+	b = newLargeModuleBundler(t)
+	b.SetLargeModuleSize(1024)
+	b.SetProfile(true)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	profiles := b.FileProfiles()
+	require.Len(t, profiles, 2)
+	byModule := map[string]FileProfile{}
+	for i, p := range profiles {
+		byModule[p.Module] = p
+		if i > 0 {
+			assert.GreaterOrEqual(t, profiles[i-1].Allocated, p.Allocated, "most allocating first")
+		}
+	}
+	assert.True(t, byModule["data"].Large)
+	assert.Equal(t, 2510, byModule["data"].Size)
+	assert.Equal(t, byModule["data"].Size, byModule["data"].Embedded)
+	assert.False(t, byModule["util"].Large)
+	assert.Equal(t, "util.lua", byModule["util"].Source)
+}
+
+func TestReadString(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.lua")
+	content := strings.Repeat("return 1\n", 10000)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	got, err := readString(osFileSystem{}, path)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	_, err = readString(osFileSystem{}, path+".missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	got, err = readString(MemoryFS{"a.lua": "return 2"}, "./a.lua")
+	require.NoError(t, err)
+	assert.Equal(t, "return 2", got)
+	_, err = readString(MemoryFS{}, "a.lua")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,94 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/license"
+)
+
+// ModuleLicense is the license information of one bundled file
+type ModuleLicense struct {
+	Module string // module key, or the entry file
+	Source string // file path or URL
+	license.Info
+}
+
+// Licenses scans the entry file and every embedded module for license
+// headers. Call after Resolve or Bundle. Local files are re-read so headers
+// removed by obfuscation are still found.
+func (b *Bundler) Licenses() []ModuleLicense {
+	licenses := []ModuleLicense{{
+		Module: b.entryFile,
+		Source: b.entryFile,
+		Info:   license.Detect(b.entryContent),
+	}}
+
+	keys := make([]string, 0, len(b.modules))
+	for key := range b.modules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		source := b.moduleSource(key)
+		content := b.modules[key]
+		if !IsURL(source) {
+			if raw, err := b.fs.ReadFile(source); err == nil {
+				content = string(raw)
+			}
+		}
+		licenses = append(licenses, ModuleLicense{Module: key, Source: source, Info: license.Detect(content)})
+	}
+	return licenses
+}
+
+// FormatAttribution renders a consolidated third-party notice file
+func FormatAttribution(licenses []ModuleLicense) string {
+	var out strings.Builder
+	out.WriteString("THIRD-PARTY NOTICES\n")
+	out.WriteString("Generated by Lua Bundler\n")
+
+	var unknown []ModuleLicense
+	for _, l := range licenses {
+		if !l.Found() {
+			unknown = append(unknown, l)
+			continue
+		}
+
+		out.WriteString("\n" + strings.Repeat("=", 72) + "\n")
+		out.WriteString(l.Module + "\n")
+		if l.Source != l.Module {
+			out.WriteString("Source: " + l.Source + "\n")
+		}
+		out.WriteString("License: " + l.SPDX + "\n")
+		for _, c := range l.Copyrights {
+			out.WriteString(c + "\n")
+		}
+		if l.Notice != "" {
+			out.WriteString("\n" + strings.TrimSpace(l.Notice) + "\n")
+		}
+	}
+
+	if len(unknown) > 0 {
+		out.WriteString("\n" + strings.Repeat("=", 72) + "\n")
+		out.WriteString("No license information found:\n")
+		for _, l := range unknown {
+			fmt.Fprintf(&out, "  - %s (%s)\n", l.Module, l.Source)
+		}
+	}
+	return out.String()
+}
+
+// LicenseComment wraps the attribution in a long comment that can be
+// appended to a bundle after release-mode stripping
+func LicenseComment(licenses []ModuleLicense) string {
+	text := FormatAttribution(licenses)
+	level := 0
+	for strings.Contains(text, "]"+strings.Repeat("=", level)+"]") {
+		level++
+	}
+	eq := strings.Repeat("=", level)
+	return "--[" + eq + "[\n" + text + "]" + eq + "]\n"
+}
@@ -0,0 +1,55 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/license"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLicenses(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`local json = require("json")
+local util = require("util")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "json.lua"), []byte(`-- SPDX-License-Identifier: MIT
+-- Copyright (c) 2020 rxi
+return {}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "util.lua"), []byte("return {}"), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	b.SetObfuscationLevel(3)
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	licenses := b.Licenses()
+	require.Len(t, licenses, 3)
+	assert.Equal(t, mainFile, licenses[0].Module)
+	assert.Equal(t, "json", licenses[1].Module)
+	assert.Equal(t, "MIT", licenses[1].SPDX, "headers should be found even when obfuscated")
+	assert.Equal(t, filepath.Join(tempDir, "json.lua"), licenses[1].Source)
+	assert.Equal(t, license.Unknown, licenses[2].SPDX)
+
+	attribution := FormatAttribution(licenses)
+	assert.Contains(t, attribution, "json\nSource: "+filepath.Join(tempDir, "json.lua")+"\nLicense: MIT\nCopyright (c) 2020 rxi\n")
+	assert.Contains(t, attribution, "No license information found:\n  - "+mainFile)
+	assert.Contains(t, attribution, "  - util (")
+}
+
+func TestLicenseComment(t *testing.T) {
+	licenses := []ModuleLicense{{
+		Module: "lib",
+		Source: "lib.lua",
+		Info:   license.Info{SPDX: "MIT", Notice: "contains ]] and ]=] markers"},
+	}}
+
+	comment := LicenseComment(licenses)
+	assert.True(t, strings.HasPrefix(comment, "--[==[\n"), "level should avoid closing markers in the text")
+	assert.True(t, strings.HasSuffix(comment, "]==]\n"))
+	assert.Contains(t, comment, "License: MIT")
+}
@@ -0,0 +1,150 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Lint rules reported by Lint besides the WarningRules
+const (
+	RuleUnresolvedRequire = "unresolved-require" // require of a file that does not exist
+	RuleExternalPrefix    = "external-prefix"    // require left external although a project file matches
+	RuleRequireCycle      = "require-cycle"      // module requiring itself through other modules
+)
+
+// Diagnostic is a problem found in a source file
+type Diagnostic struct {
+	File     string // local path or URL of the file
+	Line     int    // 1-based line, 0 when unknown
+	Start    int    // byte offset where the problem starts
+	End      int    // byte offset where it ends, 0 when unknown
+	Severity string // SeverityError or SeverityWarning
+	Rule     string
+	Message  string
+}
+
+// diagnostic returns the warning as a diagnostic of file
+func (w globalWarning) diagnostic(file string) Diagnostic {
+	return Diagnostic{File: file, Line: w.line, Start: w.span.Start, End: w.span.End, Severity: SeverityWarning, Rule: w.rule, Message: w.msg}
+}
+
+// ResolveRequire returns the file a require of modulePath in currentFile
+// resolves to, target variants included, and whether the require is left to
+// the runtime as external. External requires still get the file they would
+// resolve to if they were local. Recorded decisions take precedence over
+// the resolution rules.
+func (b *Bundler) ResolveRequire(currentFile, modulePath string) (string, bool) {
+	path := b.resolveVariant(modulePath, b.resolveModulePath(currentFile, modulePath))
+	if choice, ok := b.requireDecisions[modulePath]; ok {
+		if choice == DecisionExternal {
+			return path, true
+		}
+		return b.decisionFile(choice), false
+	}
+	return path, !b.isLocalModule(modulePath)
+}
+
+// Lint checks a source file without building it: requires of missing files,
+// requires left external although a project file matches them, requires
+// leading back to the file, and the standard global warnings. read returns
+// the contents of other files, so editors can supply unsaved buffers.
+// Unlike Resolve, Lint prints nothing.
+func (b *Bundler) Lint(file, content string, read func(path string) (string, error)) []Diagnostic {
+	var diags []Diagnostic
+	for _, w := range append(findGlobalOverrides(content), findStateHazards(content)...) {
+		if !b.suppressed[w.rule] {
+			diags = append(diags, w.diagnostic(file))
+		}
+	}
+
+	tokens, err := parser.Tokenize(content)
+	if err != nil {
+		return diags
+	}
+	lines := strings.Split(content, "\n")
+	for _, call := range parser.FindRequires(tokens) {
+		d := Diagnostic{File: file, Line: call.Token.Line, Start: call.Token.Start, End: call.Token.End}
+		path, external := b.ResolveRequire(file, call.Path)
+		pragma := requirePragma(lines, call.Token.Line-1)
+		if pragma == "external" || b.requireDecisions[call.Path] == DecisionExternal {
+			continue
+		}
+		external = external && pragma != "local"
+		_, readErr := read(path)
+
+		switch {
+		case external:
+			if readErr != nil {
+				continue
+			}
+			d.Severity, d.Rule = SeverityWarning, RuleExternalPrefix
+			d.Message = fmt.Sprintf("%s is left as an external require, but %s exists and will not be bundled", call.Path, b.displaySource(path))
+		case readErr != nil:
+			d.Severity, d.Rule = SeverityError, RuleUnresolvedRequire
+			d.Message = fmt.Sprintf("cannot resolve %s: %s not found", call.Path, b.displaySource(path))
+			if prefix := externalPrefixFold(call.Path); prefix != "" {
+				d.Message += fmt.Sprintf(" (external requires must start with %s, matching case)", prefix)
+			}
+		default:
+			chain := b.requireChain(path, file, read, map[string]bool{})
+			if chain == nil && path != file {
+				continue
+			}
+			d.Severity, d.Rule = SeverityWarning, RuleRequireCycle
+			d.Message = fmt.Sprintf("circular require: %s -> %s", b.displaySource(file), strings.Join(append([]string{call.Path}, chain...), " -> "))
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// requireChain returns the require paths leading from file to target, or
+// nil when target cannot be reached
+func (b *Bundler) requireChain(file, target string, read func(path string) (string, error), seen map[string]bool) []string {
+	if seen[file] || file == target {
+		return nil
+	}
+	seen[file] = true
+
+	content, err := read(file)
+	if err != nil {
+		return nil
+	}
+	tokens, err := parser.Tokenize(content)
+	if err != nil {
+		return nil
+	}
+	for _, call := range parser.FindRequires(tokens) {
+		path, external := b.ResolveRequire(file, call.Path)
+		if external {
+			continue
+		}
+		if path == target {
+			return []string{call.Path}
+		}
+		if rest := b.requireChain(path, target, read, seen); rest != nil {
+			return append([]string{call.Path}, rest...)
+		}
+	}
+	return nil
+}
+
+// externalPrefixFold returns the external prefix the first segment of a
+// local require path matches in all but case, such as players for Players
+func externalPrefixFold(modulePath string) string {
+	first := strings.Split(modulePath, ".")[0]
+	for _, prefix := range externalPrefixes {
+		if strings.EqualFold(first, prefix) && first != prefix {
+			return prefix
+		}
+	}
+	return ""
+}
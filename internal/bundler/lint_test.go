@@ -0,0 +1,88 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "utils"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Players"), 0755))
+	files := map[string]string{
+		"utils/a.lua":       `return require("utils.b")`,
+		"utils/b.lua":       `return require("utils.a")`,
+		"utils/ok.lua":      `return {}`,
+		"Players/Local.lua": `return {}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	entry := filepath.Join(dir, "main.lua")
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+
+	src := `local ok = require("utils.ok")
+local missing = require("utils.missing")
+local players = require("Players.Local")
+local typo = require("players.Hud")
+print = nil`
+	diags := b.Lint(entry, src, func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	})
+
+	require.Len(t, diags, 4)
+	assert.Equal(t, Diagnostic{File: entry, Line: 5, Start: 149, End: 154, Severity: SeverityWarning, Rule: RuleGlobalOverride, Message: "overrides standard global print"}, diags[0])
+	assert.Equal(t, Diagnostic{File: entry, Line: 2, Start: 55, End: 70, Severity: SeverityError, Rule: RuleUnresolvedRequire, Message: "cannot resolve utils.missing: utils/missing.lua not found"}, diags[1])
+	assert.Equal(t, RuleExternalPrefix, diags[2].Rule)
+	assert.Equal(t, "Players.Local is left as an external require, but Players/Local.lua exists and will not be bundled", diags[2].Message)
+	assert.Equal(t, "cannot resolve players.Hud: players/Hud.lua not found (external requires must start with Players, matching case)", diags[3].Message)
+
+	a := filepath.Join(dir, "utils", "a.lua")
+	diags = b.Lint(a, files["utils/a.lua"], func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	})
+	require.Len(t, diags, 1)
+	assert.Equal(t, RuleRequireCycle, diags[0].Rule)
+	assert.Equal(t, "circular require: utils/a.lua -> utils.b -> utils.a", diags[0].Message)
+}
+
+func TestLintReadsUnsavedBuffers(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+
+	buffers := map[string]string{filepath.Join(dir, "draft.lua"): `return require("main")`}
+	diags := b.Lint(entry, `require("draft")`, func(path string) (string, error) {
+		if content, ok := buffers[path]; ok {
+			return content, nil
+		}
+		return "", os.ErrNotExist
+	})
+	require.Len(t, diags, 1)
+	assert.Equal(t, "circular require: main.lua -> draft -> main", diags[0].Message)
+}
+
+func TestResolveRequire(t *testing.T) {
+	b, err := NewBundler("/project/main.lua", false, false)
+	require.NoError(t, err)
+
+	path, external := b.ResolveRequire("/project/src/init.lua", "utils.log")
+	assert.Equal(t, filepath.FromSlash("/project/utils/log.lua"), path)
+	assert.False(t, external)
+
+	path, external = b.ResolveRequire("/project/src/init.lua", "./helper")
+	assert.Equal(t, filepath.FromSlash("/project/src/helper.lua"), path)
+	assert.False(t, external)
+
+	_, external = b.ResolveRequire("/project/main.lua", "ReplicatedStorage.Shared")
+	assert.True(t, external)
+}
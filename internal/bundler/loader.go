@@ -0,0 +1,300 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Loader strategies
+const (
+	LoaderClosure = "closure" // each module is a function called by the loader
+	LoaderInline  = "inline"  // module bodies are inlined in dependency order
+	LoaderLazy    = "lazy"    // modules are embedded as strings, compiled on first require
+)
+
+// Loaders lists the supported loader strategies
+var Loaders = []string{LoaderClosure, LoaderInline, LoaderLazy}
+
+// IsLoader reports whether strategy is one of Loaders
+func IsLoader(strategy string) bool {
+	for _, l := range Loaders {
+		if l == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLoader sets how modules are embedded. The closure loader wraps each
+// module in a function run on require. The inline loader runs every module
+// body once, up front and in dependency order, with no loader at all; it
+// falls back to closures when a module cannot be inlined. The lazy loader
+// keeps modules as strings compiled on first require, with loadstring or, on
+// targets without it, load, so unused modules are never parsed. An empty
+// strategy selects closures.
+func (b *Bundler) SetLoader(strategy string) error {
+	if strategy == "" {
+		strategy = LoaderClosure
+	}
+	if !IsLoader(strategy) {
+		return fmt.Errorf("invalid loader %q (expected one of: %s)", strategy, strings.Join(Loaders, ", "))
+	}
+	b.loader = strategy
+	return nil
+}
+
+// SetNoMemoize opts modules out of result caching: each require of one of
+// them runs it again, as dofile would. Modules are named by the key they are
+// embedded under, the require path or URL.
+func (b *Bundler) SetNoMemoize(modules []string) {
+	b.noMemoize = make(map[string]bool, len(modules))
+	for _, m := range modules {
+		b.noMemoize[m] = true
+	}
+}
+
+// uncachedKeys returns the sorted embedded modules opted out of caching,
+// warning about names that match no module
+func (b *Bundler) uncachedKeys() []string {
+	var keys []string
+	for key := range b.noMemoize {
+		if _, ok := b.modules[key]; ok {
+			keys = append(keys, key)
+		} else {
+			b.warnf("no-memoize: %s is not an embedded module", key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// inlinedModule is a module body rewritten to store its result instead of
+// returning it
+type inlinedModule struct {
+	key  string
+	body string
+}
+
+// inlineModules returns the bodies of all modules in dependency order, each
+// assigning its return value to the modules table, or an error naming the
+// first module that cannot be inlined
+func (b *Bundler) inlineModules() ([]inlinedModule, error) {
+	order, err := b.dependencyOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	modulesTable, _ := b.loaderNames()
+	modules := make([]inlinedModule, 0, len(order))
+	for _, key := range order {
+		if _, ok := b.inlinedSmall[key]; ok {
+			continue
+		}
+		if b.noMemoize[key] {
+			return nil, fmt.Errorf("module %s is not memoized, so it must run on every require", key)
+		}
+		target := fmt.Sprintf("%s[\"%s\"]", modulesTable, escapeString(b.moduleKey(key)))
+		body, err := inlineBody(b.replaceModuleCallsWith(b.modules[key], b.inlineReference), target)
+		if err != nil {
+			return nil, fmt.Errorf("module %s cannot be inlined: %w", key, err)
+		}
+		modules = append(modules, inlinedModule{key: key, body: body})
+	}
+	return modules, nil
+}
+
+// inlineReference replaces a require of an embedded module with its entry
+// in the modules table, which the inline loader fills before it is read, or
+// with its expression when it is inlined at its requires
+func (b *Bundler) inlineReference(key string) (string, bool) {
+	if _, ok := b.modules[key]; !ok {
+		return "", false
+	}
+	if expr, ok := b.inlinedSmall[key]; ok {
+		return expr, true
+	}
+	modulesTable, _ := b.loaderNames()
+	return fmt.Sprintf("%s[\"%s\"]", modulesTable, escapeString(b.moduleKey(key))), true
+}
+
+// dependencyOrder returns the embedded modules so that every module comes
+// after the modules it requires. Circular requires are an error, since one
+// of the modules would read the other before it ran.
+func (b *Bundler) dependencyOrder() ([]string, error) {
+	var order []string
+	state := make(map[string]int) // 1 = visiting, 2 = done
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch state[key] {
+		case 1:
+			return fmt.Errorf("circular require: %s", strings.Join(append(path, key), " -> "))
+		case 2:
+			return nil
+		}
+		state[key] = 1
+		for _, dep := range b.graph[key] {
+			if _, embedded := b.modules[dep.Key]; !embedded {
+				continue
+			}
+			if err := visit(dep.Key, append(path, key)); err != nil {
+				return err
+			}
+		}
+		state[key] = 2
+		if _, embedded := b.modules[key]; embedded {
+			order = append(order, key)
+		}
+		return nil
+	}
+
+	if err := visit(b.entryFile, nil); err != nil {
+		return nil, err
+	}
+	// Modules the graph does not reach still have to be defined
+	var rest []string
+	for key := range b.modules {
+		if state[key] == 0 {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	for _, key := range rest {
+		if err := visit(key, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// inlineBody rewrites a module's final return statement into an assignment
+// to target, storing true when the module returns nothing or nil, as require
+// does. Modules that return from anywhere else, such as an early return
+// inside an if, cannot run without being wrapped in a function, and neither
+// can modules returning several values or reading their name from ...
+func inlineBody(content, target string) (string, error) {
+	chunk, err := parser.Parse(content)
+	if err != nil {
+		return "", err
+	}
+
+	var returns []*parser.ReturnStmt
+	var vararg *parser.VarargExpr
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case *parser.FunctionExpr:
+			return false
+		case *parser.ReturnStmt:
+			returns = append(returns, n)
+		case *parser.VarargExpr:
+			if vararg == nil {
+				vararg = n
+			}
+		}
+		return true
+	})
+	if vararg != nil {
+		return "", fmt.Errorf("line %d reads the module name from ...", lineAt(content, vararg.Start))
+	}
+	if len(returns) == 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return content + target + " = true\n", nil
+	}
+
+	stmts := chunk.Block.Stmts
+	ret := returns[0]
+	if len(returns) > 1 || stmts[len(stmts)-1] != parser.Stmt(ret) {
+		return "", fmt.Errorf("line %d returns before the end of the module", lineAt(content, ret.Start))
+	}
+	if len(ret.Values) == 0 {
+		return content[:ret.Start] + target + " = true" + content[ret.Start+len("return"):], nil
+	}
+	if len(ret.Values) > 1 {
+		return "", fmt.Errorf("line %d returns more than one value", lineAt(content, ret.Start))
+	}
+	body := content[:ret.Start] + target + " =" + content[ret.Start+len("return"):ret.End]
+	switch ret.Values[0].(type) {
+	case *parser.TableExpr, *parser.FunctionExpr, *parser.StringExpr, *parser.NumberExpr, *parser.TrueExpr, *parser.FalseExpr:
+	default:
+		body += fmt.Sprintf(" if %s == nil then %s = true end", target, target)
+	}
+	return body + content[ret.End:], nil
+}
+
+// lineAt returns the line number of a byte offset
+func lineAt(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// lazyParams returns the bundle locals a lazily compiled module needs: the
+// loader, the defines, the request shim, the state table and the polyfills
+// declared as locals. The logger is included when release mode shims print
+// and warn.
+func (b *Bundler) lazyParams(needed []polyfill, releaseMode bool) []string {
+	_, loader := b.loaderNames()
+	params := []string{loader}
+	for _, p := range needed {
+		if strings.HasPrefix(p.code, "local "+p.name+" ") {
+			params = append(params, p.name)
+		}
+	}
+	if b.requestShimUsed {
+		params = append(params, b.requestShimName())
+	}
+	if b.stateUsed {
+		params = append(params, b.stateName())
+	}
+	if len(b.apis) > 0 {
+		params = append(params, b.apiLoaderName())
+	}
+	defines := b.bundleDefines()
+	names := make([]string, 0, len(defines))
+	for name := range defines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params = append(params, names...)
+	if releaseMode && b.logLevel != "" {
+		logger, _ := b.loggerNames()
+		params = append(params, logger)
+	}
+	return params
+}
+
+// lazySource prepares the module path for the lazy loader: release mode,
+// with its checks, and minification are applied to it on its own, since the
+// bundle-wide passes leave string contents alone. The compiled chunk
+// receives the bundle locals as arguments on the first line, so line
+// numbers still match, and returns the module function the loader calls.
+func (b *Bundler) lazySource(path, content string, params []string, releaseMode bool) string {
+	expected := -1 // statements left once print/warn are removed, -1 when not checked
+	if releaseMode {
+		if chunk, err := parser.Parse(content); err == nil && b.logLevel == "" {
+			expected = b.checkDebugRemoval(chunk, content, b.moduleLocation(path, content))
+		}
+		content = b.stripDebugStatements(content)
+	}
+	content = b.minify(content, b.effectiveMinifyLevel(releaseMode))
+	if expected >= 0 {
+		b.checkReleaseOutput("module "+path, content, expected)
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return fmt.Sprintf("local %s = ...; return function(...) %send", strings.Join(params, ", "), content)
+}
+
+// longString returns s as a Lua long string, using the lowest level whose
+// closing bracket does not occur in s. The newline after the opening bracket
+// is skipped by Lua, so the string starts on the next line.
+func longString(s string) string {
+	level := ""
+	for strings.Contains(s+"]", "]"+level+"]") {
+		level += "="
+	}
+	return "[" + level + "[\n" + s + "]" + level + "]"
+}
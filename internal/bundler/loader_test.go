@@ -0,0 +1,233 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLoaderProject writes main -> utils.helper -> utils.log
+func writeLoaderProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "utils"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.lua"), []byte("local helper = require(\"utils.helper\")\nprint(helper.greet(\"x\"))\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "utils", "helper.lua"), []byte(`local log = require("utils.log")
+local M = {}
+function M.greet(n)
+    log("greet")
+    return "hi " .. n
+end
+return M
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "utils", "log.lua"), []byte("return function(msg) print(\"[log] \" .. msg) end\n"), 0644))
+	return filepath.Join(dir, "main.lua")
+}
+
+func TestSetLoader(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	assert.Equal(t, LoaderClosure, b.loader)
+
+	for _, l := range Loaders {
+		assert.NoError(t, b.SetLoader(l))
+	}
+	assert.NoError(t, b.SetLoader(""))
+	assert.Equal(t, LoaderClosure, b.loader)
+	assert.Error(t, b.SetLoader("eager"))
+}
+
+func TestInlineBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		err      bool
+	}{
+		{"final return", "local M = {}\nreturn M\n", "local M = {}\nT = M if T == nil then T = true end\n", false},
+		{"never nil", "return { x = 1 }", "T = { x = 1 }", false},
+		{"false", "return false", "T = false", false},
+		{"no return", "x = 1", "x = 1\nT = true\n", false},
+		{"bare return", "x = 1\nreturn\n", "x = 1\nT = true\n", false},
+		{"returns in functions", "local function f() return 1 end\nreturn function() return f() end", "local function f() return 1 end\nT = function() return f() end", false},
+		{"early return", "if x then return 1 end\nreturn 2", "", true},
+		{"multiple values", "return 1, 2", "", true},
+		{"module name", "local name = ...\nreturn name", "", true},
+		{"varargs in functions", "return function(...) return ... end", "T = function(...) return ... end", false},
+		{"return in block", "do return 1 end", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := inlineBody(tt.content, "T")
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, body)
+		})
+	}
+}
+
+func TestLongString(t *testing.T) {
+	assert.Equal(t, "[[\nx]]", longString("x"))
+	assert.Equal(t, "[=[\nt[a[1]]]=]", longString("t[a[1]]"))
+	assert.Equal(t, "[=[\nx]]=]", longString("x]"), "a trailing bracket must not close the string")
+	assert.Equal(t, "[==[\n]]]=]]==]", longString("]]]=]"))
+}
+
+func TestBundle_InlineLoader(t *testing.T) {
+	b, err := NewBundler(writeLoaderProject(t), false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLoader(LoaderInline))
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.NotContains(t, result, "loadModule")
+	assert.Less(t, strings.Index(result, "-- Module: utils.log"), strings.Index(result, "-- Module: utils.helper"), "dependencies come first")
+	assert.Contains(t, result, "    local log = EmbeddedModules[\"utils.log\"]\n")
+	assert.Contains(t, result, "    EmbeddedModules[\"utils.helper\"] = M if EmbeddedModules[\"utils.helper\"] == nil then EmbeddedModules[\"utils.helper\"] = true end\n")
+	assert.Contains(t, result, "local helper = EmbeddedModules[\"utils.helper\"]\n")
+	_, err = parser.Parse(result)
+	assert.NoError(t, err)
+
+	lines := strings.Split(result, "\n")
+	for _, m := range b.SourceMap()[:2] {
+		assert.Equal(t, "-- Module: "+m.Module, lines[m.StartLine-1])
+		assert.Equal(t, "end", lines[m.EndLine-1])
+	}
+}
+
+func TestBundle_InlineLoaderFallsBack(t *testing.T) {
+	entry := writeLoaderProject(t)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(entry), "utils", "log.lua"), []byte("if not print then return nil end\nreturn print\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLoader(LoaderInline))
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "local function loadModule(url)")
+	require.Len(t, b.GetWarnings(), 1)
+	assert.Contains(t, b.GetWarnings()[0], "utils.log cannot be inlined: line 1")
+}
+
+func TestDependencyOrder_Cycle(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.modules = map[string]string{"a": "", "b": ""}
+	b.addDependency("main.lua", Dependency{Key: "a"})
+	b.addDependency("a", Dependency{Key: "b"})
+	b.addDependency("b", Dependency{Key: "a"})
+
+	_, err = b.dependencyOrder()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "main.lua -> a -> b -> a")
+}
+
+func TestBundle_LazyLoader(t *testing.T) {
+	b, err := NewBundler(writeLoaderProject(t), false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLoader(LoaderLazy))
+	require.NoError(t, b.SetDefines(map[string]string{"DEBUG": "true"}))
+	result, err := b.Bundle(true)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, `loadstring(module,"="..url)`)
+	assert.Contains(t, result, `module=assert(loadstring(module,"="..url))(loadModule,DEBUG)`)
+	assert.Contains(t, result, "EmbeddedModules[\"utils.log\"]=[[\nlocal loadModule, DEBUG = ...; return function(...) return function(msg)end\nend]]")
+	assert.NotContains(t, result, "print(", "release mode reaches the module strings")
+
+	chunk, err := parser.Parse(result)
+	require.NoError(t, err)
+	for _, tok := range chunk.Tokens {
+		if tok.Kind == parser.String && strings.HasPrefix(tok.Value, "[[") {
+			_, err := parser.Parse(strings.TrimSuffix(strings.TrimPrefix(tok.Value, "[[\n"), "]]"))
+			assert.NoError(t, err, tok.Value)
+		}
+	}
+}
+
+func TestBundle_LazyLoaderLogShim(t *testing.T) {
+	b, err := NewBundler(writeLoaderProject(t), false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLoader(LoaderLazy))
+	require.NoError(t, b.SetLogShim("warn"))
+	require.NoError(t, b.SetMinifyLevel(MinifyNone))
+	result, err := b.Bundle(true)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "        module = assert(loadstring(module, \"=\" .. url))(loadModule, BundleLogger)\n")
+	assert.Contains(t, result, "local loadModule, BundleLogger = ...; return function(...) return function(msg) BundleLogger.info(\"[log] \" .. msg) end\nend]]")
+}
+
+func TestBundle_MemoizedRequire(t *testing.T) {
+	b, err := NewBundler(writeLoaderProject(t), false, false)
+	require.NoError(t, err)
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "local LoadedModules = {}\n")
+	assert.Contains(t, result, "    elseif loaded ~= nil then\n        return unpackResults(loaded, 1, loaded.n)\n")
+	assert.Contains(t, result, "        if results[1] == nil then\n            results[1] = true\n")
+	assert.Contains(t, result, "        LoadedModules[url] = results\n        return unpackResults(results, 1, results.n)\n")
+	assert.NotContains(t, result, "uncachedModules")
+	_, err = parser.Parse(result)
+	assert.NoError(t, err)
+}
+
+func TestBundle_NoMemoize(t *testing.T) {
+	b, err := NewBundler(writeLoaderProject(t), false, false)
+	require.NoError(t, err)
+	b.SetNoMemoize([]string{"utils.log", "utils.missing"})
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "local uncachedModules = {\n    [\"utils.log\"] = true,\n}\n")
+	assert.Contains(t, result, "        if uncachedModules[url] then\n            return module(url)\n        end\n")
+	assert.Equal(t, []string{"no-memoize: utils.missing is not an embedded module"}, b.GetWarnings())
+
+	// Inlined modules run exactly once, so opting out needs the closure loader
+	b, err = NewBundler(writeLoaderProject(t), false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLoader(LoaderInline))
+	b.SetNoMemoize([]string{"utils.log"})
+	result, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "local uncachedModules = {")
+	assert.Contains(t, b.GetWarnings()[0], "utils.log is not memoized")
+}
+
+func TestBundle_ModuleVarargsAndResults(t *testing.T) {
+	entry := writeLoaderProject(t)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(entry), "utils", "log.lua"), []byte("local name = ...\nreturn function(msg) print(name, msg) end, name\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "EmbeddedModules[\"utils.log\"] = function(...)\n    local name = ...\n")
+	assert.Contains(t, result, "local results = packResults(module(url))")
+
+	// The module name is the require path, without the namespace
+	b, err = NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetNamespace("ns"))
+	result, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, `local results = packResults(module((url:gsub("^ns:", ""))))`)
+
+	// Inlining would lose both
+	b, err = NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLoader(LoaderInline))
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, b.GetWarnings()[0], "utils.log cannot be inlined: line 1 reads the module name from ...")
+}
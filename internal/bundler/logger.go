@@ -0,0 +1,88 @@
+package bundler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Log levels of the release-mode logging shim, from most to least verbose
+var LogLevels = []string{"info", "warn", "off"}
+
+// loggerMethods maps the debug functions to the logger methods replacing them
+var loggerMethods = map[string]string{"print": "info", "warn": "warn"}
+
+// SetLogShim makes release mode rewrite print and warn statements into calls
+// to a logger embedded in the bundle instead of removing them. level is the
+// default level; scripts can change it at runtime through a global (see
+// loggerNames). An empty level disables the shim.
+func (b *Bundler) SetLogShim(level string) error {
+	if level != "" && !IsLogLevel(level) {
+		return fmt.Errorf("invalid log level %q (expected one of: %s)", level, strings.Join(LogLevels, ", "))
+	}
+	b.logLevel = level
+	return nil
+}
+
+// IsLogLevel reports whether level is one of LogLevels
+func IsLogLevel(level string) bool {
+	for _, l := range LogLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// loggerNames returns the name of the logger local and of the global holding
+// the runtime log level, prefixed with the namespace when one is set
+func (b *Bundler) loggerNames() (string, string) {
+	if b.namespace == "" {
+		return "BundleLogger", "LUA_BUNDLER_LOG_LEVEL"
+	}
+	return b.namespace + "_BundleLogger", b.namespace + "_LOG_LEVEL"
+}
+
+// writeLogger writes the logger the shimmed print and warn statements call.
+// The level global is read on every call, so setting it re-enables logging
+// in a deployed script without a rebuild.
+func writeLogger(out *strings.Builder, logger, levelGlobal, level string) {
+	out.WriteString("-- Release logging shim\n")
+	fmt.Fprintf(out, "local %s = { level = \"%s\" }\n", logger, level)
+	out.WriteString("do\n")
+	out.WriteString("    local levels = { info = 1, warn = 2, off = 3 }\n")
+	out.WriteString("    local function enabled(level)\n")
+	fmt.Fprintf(out, "        local current = levels[_G.%s] or levels[%s.level] or levels.off\n", levelGlobal, logger)
+	out.WriteString("        return levels[level] >= current\n")
+	out.WriteString("    end\n")
+	fmt.Fprintf(out, "    function %s.setLevel(level)\n", logger)
+	fmt.Fprintf(out, "        %s.level = level\n", logger)
+	out.WriteString("    end\n")
+	fmt.Fprintf(out, "    function %s.info(...)\n", logger)
+	out.WriteString("        if enabled(\"info\") then print(...) end\n")
+	out.WriteString("    end\n")
+	fmt.Fprintf(out, "    function %s.warn(...)\n", logger)
+	out.WriteString("        if enabled(\"warn\") then (warn or print)(...) end\n")
+	out.WriteString("    end\n")
+	out.WriteString("end\n\n")
+}
+
+// shimDebugStatements rewrites print() and warn() call statements into
+// calls to logger, keeping those matched as described in debugStatements.
+// Content the parser rejects is returned unchanged.
+func shimDebugStatements(content string, keep []*regexp.Regexp, logger string) string {
+	calls, err := debugStatements(content, keep)
+	if err != nil {
+		return content
+	}
+
+	var out strings.Builder
+	pos := 0
+	for _, call := range calls {
+		out.WriteString(content[pos:call.fn.Start])
+		out.WriteString(logger + "." + loggerMethods[call.fn.Name])
+		pos = call.fn.End
+	}
+	out.WriteString(content[pos:])
+	return out.String()
+}
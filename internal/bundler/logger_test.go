@@ -0,0 +1,78 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLogShim(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+
+	for _, level := range []string{"", "info", "warn", "off"} {
+		assert.NoError(t, b.SetLogShim(level), level)
+	}
+	assert.Error(t, b.SetLogShim("debug"))
+}
+
+func TestLoggerNames(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+
+	logger, level := b.loggerNames()
+	assert.Equal(t, "BundleLogger", logger)
+	assert.Equal(t, "LUA_BUNDLER_LOG_LEVEL", level)
+
+	require.NoError(t, b.SetNamespace("MyLib"))
+	logger, level = b.loggerNames()
+	assert.Equal(t, "MyLib_BundleLogger", logger)
+	assert.Equal(t, "MyLib_LOG_LEVEL", level)
+}
+
+func TestShimDebugStatements(t *testing.T) {
+	input := `print("debug", x)
+if bad then warn("careful") end
+print("Loaded") --@keep
+print("[ERROR] failed")
+local s = "print(1)"
+obj.print("kept")`
+
+	expected := `BundleLogger.info("debug", x)
+if bad then BundleLogger.warn("careful") end
+print("Loaded") --@keep
+print("[ERROR] failed")
+local s = "print(1)"
+obj.print("kept")`
+
+	keep := []*regexp.Regexp{regexp.MustCompile(`^\[ERROR\]`)}
+	assert.Equal(t, expected, shimDebugStatements(input, keep, "BundleLogger"))
+}
+
+func TestBundle_LogShim(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("print(\"hello\")\nwarn(\"careful\")\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLogShim("warn"))
+
+	result, err := b.Bundle(true)
+	require.NoError(t, err)
+	assert.Contains(t, result, `local BundleLogger={level="warn"}`)
+	assert.Contains(t, result, "_G.LUA_BUNDLER_LOG_LEVEL")
+	assert.Contains(t, result, `BundleLogger.info("hello")`)
+	assert.Contains(t, result, `BundleLogger.warn("careful")`)
+	assert.Equal(t, 1, strings.Count(result, "print(...)"), "the shim's own print must not be rewritten")
+
+	// Without release mode, print and warn are left alone
+	result, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "BundleLogger")
+}
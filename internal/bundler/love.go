@@ -0,0 +1,22 @@
+package bundler
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LoveConf is the file LÖVE runs before main.lua to configure the game
+const LoveConf = "conf.lua"
+
+// resolveLoveModule resolves a module name the way LÖVE's require does:
+// from the game root, the directory of main.lua, trying name.lua and then
+// name/init.lua, with dots or slashes separating directories
+func (b *Bundler) resolveLoveModule(name string) string {
+	path := filepath.Join(b.baseDir, filepath.FromSlash(strings.ReplaceAll(name, ".", "/")))
+	if _, err := b.fs.Stat(path + ".lua"); err != nil {
+		if _, err := b.fs.Stat(filepath.Join(path, "init.lua")); err == nil {
+			return filepath.Join(path, "init.lua")
+		}
+	}
+	return path + ".lua"
+}
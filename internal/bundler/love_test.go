@@ -0,0 +1,40 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle_Love2D(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":              "local Player = require(\"entities.player\")\nlocal ui = require(\"ui\")\nfunction love.draw() ui.draw(Player) end\n",
+		"entities/player.lua":   "local vec = require(\"lib.vec\")\nreturn { pos = vec(0, 0) }\n",
+		"entities/lib/vec.lua":  "return function() return \"wrong\" end\n",
+		"lib/vec.lua":           "return function(x, y) return { x = x, y = y } end\n",
+		"ui/init.lua":           "local theme = require(\"ui/theme\")\nreturn { draw = function() end, theme = theme }\n",
+		"ui/theme.lua":          "return { font = 12 }\n",
+		"entities/ui/theme.lua": "return {}\n",
+	})
+	require.NoError(t, b.SetTarget(TargetLove2D))
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"main.lua", "/entities/player.lua", "/lib/vec.lua", "/ui/init.lua", "/ui/theme.lua"}, b.GetLocalFiles(),
+		"requires should resolve from the game root, with init.lua for directories")
+}
+
+func TestResolveRequire_Love2DRelative(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "", "states/menu.lua": "", "states/util.lua": ""})
+	require.NoError(t, b.SetTarget(TargetLove2D))
+
+	path, _ := b.ResolveRequire("states/menu.lua", "./util")
+	assert.Equal(t, "states/util.lua", path, "explicitly relative requires keep resolving from the file")
+	path, _ = b.ResolveRequire("states/menu.lua", "util")
+	assert.Equal(t, "/util.lua", path, "other requires resolve from the game root")
+}
@@ -0,0 +1,97 @@
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+)
+
+// Manifest describes a build: how it was made and which modules it embeds
+type Manifest struct {
+	Name              string           `json:"name"`
+	Format            int              `json:"format"` // the BundleFormat of the bundle
+	Version           string           `json:"version"`
+	Entry             string           `json:"entry"`
+	Target            string           `json:"target"`
+	Release           bool             `json:"release"`
+	Obfuscation       int              `json:"obfuscation"` // the level, -1 for other pipelines
+	ObfuscationPasses []string         `json:"obfuscationPasses,omitempty"`
+	Namespace         string           `json:"namespace,omitempty"`
+	Polyfills         []string         `json:"polyfills,omitempty"`
+	Git               *GitInfo         `json:"git,omitempty"`
+	Features          []string         `json:"features,omitempty"`
+	Modules           []ManifestModule `json:"modules"`
+}
+
+// ManifestModule is an embedded module and the hash of its source. Local
+// files are hashed as read from disk, so obfuscation does not change the hash.
+// Obfuscation names the module's pipeline when obfuscation rules are set.
+type ManifestModule struct {
+	Key         string `json:"key"`
+	Source      string `json:"source"`
+	Remote      bool   `json:"remote"`
+	SHA256      string `json:"sha256"`
+	Obfuscation string `json:"obfuscation,omitempty"`
+}
+
+// Manifest returns the manifest of the last build, with modules sorted by key
+func (b *Bundler) Manifest(name, version string, releaseMode bool) Manifest {
+	m := Manifest{
+		Name:        name,
+		Format:      b.bundleFormat,
+		Version:     version,
+		Entry:       b.displaySource(b.entryFile),
+		Target:      b.target,
+		Release:     releaseMode,
+		Obfuscation: b.obfuscateLevel,
+		Namespace:   b.namespace,
+		Polyfills:   b.polyfills,
+		Git:         b.gitInfo,
+		Features:    b.GetEnabledFeatures(),
+		Modules:     b.manifestModules(),
+	}
+	if b.obfuscator != nil {
+		for _, pass := range b.obfuscator.Passes() {
+			m.ObfuscationPasses = append(m.ObfuscationPasses, pass.Name)
+		}
+	}
+	return m
+}
+
+// manifestModules returns the modules of the last build with the hashes of
+// their sources, sorted by key
+func (b *Bundler) manifestModules() []ManifestModule {
+	modules := make([]ManifestModule, 0, len(b.modules))
+	keys := make([]string, 0, len(b.modules))
+	for key := range b.modules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		source := b.moduleSource(key)
+		content := b.modules[key]
+		if !IsURL(source) {
+			if raw, err := b.fs.ReadFile(source); err == nil {
+				content = string(raw)
+			}
+		}
+		sum := sha256.Sum256([]byte(content))
+		module := ManifestModule{
+			Key:    key,
+			Source: b.displaySource(source),
+			Remote: IsURL(source),
+			SHA256: hex.EncodeToString(sum[:]),
+		}
+		if len(b.obfuscationRules) > 0 && !module.Remote {
+			module.Obfuscation = "none"
+			if o := b.obfuscatorFor(key, source); o != nil {
+				module.Obfuscation = obfuscator.Describe(o.Passes())
+			}
+		}
+		modules = append(modules, module)
+	}
+	return modules
+}
@@ -0,0 +1,50 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestAndSourceMap(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(mainFile, []byte("local util = require(\"lib.util\")\nprint(util)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "lib", "util.lua"), []byte("local util = {}\n\nreturn util"), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	m := b.Manifest("game", "2.1.0", false)
+	assert.Equal(t, "main.lua", m.Entry)
+	assert.Equal(t, TargetRoblox, m.Target)
+	require.Len(t, m.Modules, 1)
+	assert.Equal(t, "lib.util", m.Modules[0].Key)
+	assert.Equal(t, "lib/util.lua", m.Modules[0].Source)
+	assert.False(t, m.Modules[0].Remote)
+	assert.Len(t, m.Modules[0].SHA256, 64)
+
+	mappings := b.SourceMap()
+	require.Len(t, mappings, 2)
+	lines := strings.Split(bundle, "\n")
+	util := mappings[0]
+	assert.Equal(t, "lib/util.lua", util.Source)
+	assert.Equal(t, "-- Module: lib.util", lines[util.StartLine-1])
+	assert.Equal(t, "end", lines[util.EndLine-1])
+	assert.Equal(t, "    return util", lines[util.EndLine-2])
+
+	entry := mappings[1]
+	assert.Equal(t, "-- Main Script", lines[entry.StartLine-1])
+	assert.Equal(t, "print(util)", lines[entry.EndLine-1])
+
+	_, err = b.Bundle(true)
+	require.NoError(t, err)
+	assert.Empty(t, b.SourceMap(), "release output is a single line")
+}
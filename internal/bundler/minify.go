@@ -0,0 +1,183 @@
+package bundler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Minification levels
+const (
+	MinifyAuto       = -1 // MinifyWhitespace in release mode, else MinifyNone
+	MinifyNone       = 0
+	MinifyWhitespace = 1 // comments and whitespace
+	MinifyNames      = 2 // plus local name shortening
+	MinifyAggressive = 3 // plus semicolon elision and number shortening
+)
+
+// SetMinifyLevel sets how much the bundle is minified, independent of
+// release mode
+func (b *Bundler) SetMinifyLevel(level int) error {
+	if level < MinifyAuto || level > MinifyAggressive {
+		return fmt.Errorf("invalid minify level %d (expected 0-3)", level)
+	}
+	b.minifyLevel = level
+	return nil
+}
+
+// SetPreserveLines keeps every statement on its original line when minifying
+// and removing debug statements, so runtime error line numbers and the
+// source map still match the unminified bundle
+func (b *Bundler) SetPreserveLines(preserve bool) {
+	b.preserveLines = preserve
+}
+
+// effectiveMinifyLevel resolves MinifyAuto for a build
+func (b *Bundler) effectiveMinifyLevel(releaseMode bool) int {
+	if b.minifyLevel != MinifyAuto {
+		return b.minifyLevel
+	}
+	if releaseMode {
+		return MinifyWhitespace
+	}
+	return MinifyNone
+}
+
+// minify applies a minification level to Lua source
+func (b *Bundler) minify(content string, level int) string {
+	if level <= MinifyNone {
+		return content
+	}
+
+	// minifyCode drops comments too; removeComments also drops blank lines
+	if !b.preserveLines {
+		if b.verbose {
+			b.logf("  - Removing comments...\n")
+		}
+		content = removeComments(content)
+	}
+
+	if level >= MinifyNames {
+		if b.verbose {
+			b.logf("  - Shortening local names...\n")
+		}
+		shortened, err := shortenLocals(content)
+		if err != nil {
+			b.warnf("minify: local names were not shortened (%v)", err)
+		} else {
+			content = shortened
+		}
+	}
+
+	if b.verbose {
+		if b.preserveLines {
+			b.logf("  - Removing whitespace, keeping line numbers...\n")
+		} else {
+			b.logf("  - Minifying to single line...\n")
+		}
+	}
+	return minifyCode(content, minifyOptions{
+		aggressive:    level >= MinifyAggressive,
+		doubles:       b.doubleNumbers(),
+		preserveLines: b.preserveLines,
+	})
+}
+
+// doubleNumbers reports whether every number of the target is a double, so
+// integer literals may be written with exponents
+func (b *Bundler) doubleNumbers() bool {
+	switch b.target {
+	case TargetRoblox, TargetLua51, TargetLuaJIT, TargetLove2D, TargetOpenResty, TargetComputerCraft, TargetWoW, TargetGMod, TargetLua52:
+		return true
+	}
+	return false
+}
+
+var (
+	hexNumberRegex     = regexp.MustCompile(`^0[xX]([0-9a-fA-F]+)$`)
+	binaryNumberRegex  = regexp.MustCompile(`^0[bB]([01]+)$`)
+	decimalNumberRegex = regexp.MustCompile(`^([0-9]*)(?:\.([0-9]*))?(?:[eE]([+-]?)([0-9]+))?$`)
+)
+
+// shortenNumber returns the shortest spelling of a numeric literal with the
+// same value and type: hex and binary integers in decimal, no redundant
+// zeros, no "+" in exponents, and with doubles, integers such as 1000000 as
+// 1e6. Literals it does not recognise, such as LuaJIT's 1ULL, are returned
+// unchanged.
+func shortenNumber(value string, doubles bool) string {
+	v := strings.ReplaceAll(value, "_", "") // Luau digit separators
+
+	if m := hexNumberRegex.FindStringSubmatch(v); m != nil {
+		v = integerLiteral(m[1], 16, v)
+	} else if m := binaryNumberRegex.FindStringSubmatch(v); m != nil {
+		v = integerLiteral(m[1], 2, v)
+	} else if m := decimalNumberRegex.FindStringSubmatch(v); m != nil && m[0] != "" && m[0] != "." {
+		v = decimalLiteral(v, m, doubles)
+	} else {
+		return value
+	}
+
+	if len(v) < len(value) {
+		return v
+	}
+	return value
+}
+
+// integerLiteral converts digits in base to decimal when the value is exactly
+// representable as a double, else returns literal
+func integerLiteral(digits string, base int, literal string) string {
+	n, err := strconv.ParseUint(digits, base, 64)
+	if err != nil || n >= 1<<53 {
+		return literal
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// decimalLiteral shortens a decimal literal from its decimalNumberRegex match
+func decimalLiteral(literal string, m []string, doubles bool) string {
+	intPart, frac, sign, exp := m[1], m[2], m[3], m[4]
+	isFloat := strings.Contains(literal, ".") || exp != ""
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if !isFloat {
+		if intPart == "" {
+			return "0"
+		}
+		// 1000000 -> 1e6 when integers and floats are the same type
+		if zeros := len(intPart) - len(strings.TrimRight(intPart, "0")); doubles && zeros > 2 {
+			return intPart[:len(intPart)-zeros] + "e" + strconv.Itoa(zeros)
+		}
+		return intPart
+	}
+
+	frac = strings.TrimRight(frac, "0")
+	var out string
+	switch {
+	case frac != "":
+		out = intPart + "." + frac
+	case strings.Contains(literal, "."):
+		// Keep the point so the literal stays a float
+		if intPart == "" {
+			intPart = "0"
+		}
+		out = intPart + ".0"
+	default:
+		out = intPart
+		if out == "" {
+			out = "0"
+		}
+	}
+
+	if exp != "" {
+		exp = strings.TrimLeft(exp, "0")
+		if exp == "" {
+			exp = "0"
+		}
+		if sign == "-" {
+			exp = "-" + exp
+		}
+		out += "e" + exp
+	}
+	return out
+}
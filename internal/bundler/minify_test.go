@@ -0,0 +1,160 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortenNumber(t *testing.T) {
+	tests := []struct {
+		value    string
+		doubles  bool
+		expected string
+	}{
+		{"0xFF", false, "255"},
+		{"0x10", false, "16"},
+		{"0xFFFFFF", false, "0xFFFFFF"},
+		{"0xFFFFFFFFFFFFFFFF", false, "0xFFFFFFFFFFFFFFFF"},
+		{"0b1010", true, "10"},
+		{"1_000_000", true, "1e6"},
+		{"1000000", true, "1e6"},
+		{"1000000", false, "1000000"},
+		{"100", true, "100"},
+		{"007", false, "7"},
+		{"0.50", false, ".5"},
+		{"1.500", false, "1.5"},
+		{"1.0", false, "1.0"},
+		{"0.0", false, "0.0"},
+		{"1e+05", false, "1e5"},
+		{"2.50E-03", false, "2.5e-3"},
+		{"1ULL", false, "1ULL"},
+		{"12", false, "12"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, shortenNumber(tt.value, tt.doubles), tt.value)
+	}
+}
+
+func TestMinifyCode_Aggressive(t *testing.T) {
+	input := `local a = 0xFF;
+local t = { 1; 2; 0.50 };
+f();
+(g)();
+type T = { x: number; y: number }
+return a;`
+
+	assert.Equal(t, `local a=255 local t={1;2;.5}f();(g)()type T={x:number;y:number}return a`, minifyCode(input, minifyOptions{aggressive: true}))
+	assert.Equal(t, `local a=0xFF;local t={1;2;0.50};f();(g)();type T={x:number;y:number}return a;`, minifyCode(input, minifyOptions{}))
+}
+
+func TestSetMinifyLevel(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, MinifyNone, b.effectiveMinifyLevel(false))
+	assert.Equal(t, MinifyWhitespace, b.effectiveMinifyLevel(true), "release mode minifies by default")
+
+	require.NoError(t, b.SetMinifyLevel(MinifyNone))
+	assert.Equal(t, MinifyNone, b.effectiveMinifyLevel(true))
+	require.NoError(t, b.SetMinifyLevel(MinifyAggressive))
+	assert.Equal(t, MinifyAggressive, b.effectiveMinifyLevel(false))
+
+	assert.Error(t, b.SetMinifyLevel(4))
+	assert.Error(t, b.SetMinifyLevel(-2))
+}
+
+func TestBundle_MinifyDecoupledFromRelease(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("-- entry\nlocal greeting = \"hi\"\nprint(greeting)\n"), 0644))
+
+	// Minified development build keeps print
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetMinifyLevel(MinifyWhitespace))
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "\n")
+	assert.NotContains(t, result, "-- entry")
+	assert.Contains(t, result, "print(greeting)")
+
+	// Release build without minifying keeps the layout
+	b, err = NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetMinifyLevel(MinifyNone))
+	result, err = b.Bundle(true)
+	require.NoError(t, err)
+	assert.Contains(t, result, "-- entry\n")
+	assert.NotContains(t, result, "print(")
+}
+
+func TestMinifyCode_PreserveLines(t *testing.T) {
+	input := `-- header
+local   a = 1   -- one
+
+local s = [[
+two]]   local t = {
+    x = 2,
+}
+return a`
+
+	assert.Equal(t, `
+local a=1
+
+local s=[[
+two]]local t={
+x=2,
+}
+return a`, minifyCode(input, minifyOptions{preserveLines: true}))
+}
+
+func TestBlankDebugStatements(t *testing.T) {
+	input := `local a = 1
+print(
+    "multi"
+)
+print("Loaded") --@keep
+if a then print("x") end
+return a`
+
+	assert.Equal(t, `local a = 1
+
+
+
+print("Loaded") --@keep
+if a then  end
+return a`, blankDebugStatements(input, nil, false))
+}
+
+func TestBundle_PreserveLines(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	src := "-- entry\nlocal greeting = require(\"helper\")\nprint(greeting)\nerror(\"boom\")\n"
+	require.NoError(t, os.WriteFile(entry, []byte(src), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.lua"), []byte("-- helper\nreturn \"hi\"\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	unminified, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	b, err = NewBundler(entry, false, false)
+	require.NoError(t, err)
+	b.SetPreserveLines(true)
+	require.NoError(t, b.SetLogShim("off"))
+	result, err := b.Bundle(true)
+	require.NoError(t, err)
+
+	lineOf := func(s, substr string) int {
+		return strings.Count(s[:strings.Index(s, substr)], "\n") + 1
+	}
+	assert.Equal(t, lineOf(unminified, `error("boom")`), lineOf(result, `error("boom")`))
+	assert.Equal(t, lineOf(unminified, `return "hi"`), lineOf(result, `return"hi"`))
+	assert.NotContains(t, result, "-- entry")
+	assert.NotEmpty(t, b.SourceMap(), "line ranges still apply")
+}
@@ -0,0 +1,198 @@
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatLua      = "lua"
+	FormatRbxmx    = "rbxmx"
+	FormatLove     = "love"     // zipped LÖVE game, for the love2d target
+	FormatAddon    = "addon"    // rebuilt WoW addon folder, for .toc entries
+	FormatResource = "resource" // rebuilt FiveM resource folder, for manifest entries
+)
+
+// modelNode is an instance in a Roblox model
+type modelNode struct {
+	name     string
+	class    string // Folder, Script or ModuleScript
+	source   string
+	parent   *modelNode
+	children []*modelNode
+}
+
+func (n *modelNode) child(name string) *modelNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &modelNode{name: name, class: "Folder", parent: n}
+	n.children = append(n.children, c)
+	return c
+}
+
+// path returns the nodes from the root's child down to n
+func (n *modelNode) path() []*modelNode {
+	var path []*modelNode
+	for node := n; node.parent != nil; node = node.parent {
+		path = append([]*modelNode{node}, path...)
+	}
+	return path
+}
+
+// BundleModel resolves the dependency graph and returns an rbxmx model in
+// which the entry file is a Script and every module a ModuleScript, laid out
+// in Folders that mirror the source tree. Requires between modules become
+// require(script.Parent...) references. name is the root Folder's name.
+func (b *Bundler) BundleModel(name string, releaseMode bool) (string, error) {
+	if b.target != TargetRoblox {
+		return "", fmt.Errorf("rbxmx output requires the %s target (got %s)", TargetRoblox, b.target)
+	}
+
+	mainContent, err := b.Resolve()
+	if err != nil {
+		return "", err
+	}
+	mainContent = b.obfuscate("", b.entryFile, mainContent)
+
+	root := &modelNode{name: name, class: "Folder"}
+	nodes := make(map[string]*modelNode)
+
+	keys := make([]string, 0, len(b.modules))
+	for key := range b.modules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Modules resolving to the same instance share a node
+	for _, key := range keys {
+		node := root
+		for _, segment := range b.modelPath(key) {
+			node = node.child(segment)
+		}
+		if node.class == "Folder" {
+			node.class = "ModuleScript"
+			node.source = b.modules[key]
+		}
+		nodes[key] = node
+	}
+
+	entryName := strings.TrimSuffix(filepath.Base(b.entryFile), filepath.Ext(b.entryFile))
+	entry := root.child(entryName)
+	if entry.class != "Folder" {
+		return "", fmt.Errorf("entry script %q collides with a module of the same name", entryName)
+	}
+	entry.class = "Script"
+	entry.source = mainContent
+	nodes[b.entryFile] = entry
+
+	// Rewrite each script's requires to point at the module instances
+	var rewrite func(n *modelNode)
+	rewrite = func(n *modelNode) {
+		if n.class != "Folder" {
+			from := n
+			n.source = b.replaceModuleCallsWith(n.source, func(key string) (string, bool) {
+				to, ok := nodes[key]
+				if !ok || to == from {
+					return "", false
+				}
+				return "require(" + instanceReference(from, to) + ")", true
+			})
+			if releaseMode && b.preserveLines {
+				n.source = blankDebugStatements(n.source, b.keepPatterns, b.keepDebugCalls())
+			} else if releaseMode {
+				n.source = removeDebugStatements(n.source, b.keepPatterns, b.keepDebugCalls())
+			}
+			n.source = b.minify(n.source, b.effectiveMinifyLevel(releaseMode))
+		}
+		for _, c := range n.children {
+			rewrite(c)
+		}
+	}
+	rewrite(root)
+	entry.source = b.wrapBanner(entry.source)
+
+	var out strings.Builder
+	out.WriteString(`<roblox xmlns:xmime="http://www.w3.org/2005/05/xmlmime" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation="http://www.roblox.com/roblox.xsd" version="4">` + "\n")
+	referent := 0
+	writeModelItem(&out, root, 1, &referent)
+	out.WriteString("</roblox>\n")
+	return out.String(), nil
+}
+
+// modelPath returns the instance names a module is placed under: its path
+// relative to the project for local files, or _remote/<url> for remote ones
+func (b *Bundler) modelPath(key string) []string {
+	source, ok := b.moduleSources[key]
+	if !ok {
+		source = key
+	}
+	if IsURL(source) {
+		return []string{"_remote", source[strings.Index(source, "://")+3:]}
+	}
+
+	if !ok {
+		return strings.Split(key, ".")
+	}
+	rel, err := filepath.Rel(b.baseDir, source)
+	if err != nil {
+		return strings.Split(key, ".")
+	}
+	rel = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+	segments := strings.Split(rel, "/")
+	for i, s := range segments {
+		if s == ".." {
+			segments[i] = "_parent"
+		}
+	}
+	return segments
+}
+
+// instanceReference returns a Lua expression evaluating to instance to when
+// run from the script instance from
+func instanceReference(from, to *modelNode) string {
+	fromPath, toPath := from.path(), to.path()
+	common := 0
+	for common < len(fromPath) && common < len(toPath) && fromPath[common] == toPath[common] {
+		common++
+	}
+
+	ref := "script" + strings.Repeat(".Parent", len(fromPath)-common)
+	for _, n := range toPath[common:] {
+		ref += "[\"" + escapeString(n.name) + "\"]"
+	}
+	return ref
+}
+
+func writeModelItem(out *strings.Builder, n *modelNode, depth int, referent *int) {
+	indent := strings.Repeat("\t", depth)
+	fmt.Fprintf(out, "%s<Item class=\"%s\" referent=\"RBX%d\">\n", indent, n.class, *referent)
+	*referent++
+	fmt.Fprintf(out, "%s\t<Properties>\n", indent)
+	fmt.Fprintf(out, "%s\t\t<string name=\"Name\">%s</string>\n", indent, xmlEscape(n.name))
+	if n.class != "Folder" {
+		fmt.Fprintf(out, "%s\t\t<ProtectedString name=\"Source\"><![CDATA[%s]]></ProtectedString>\n", indent, cdataEscape(n.source))
+	}
+	fmt.Fprintf(out, "%s\t</Properties>\n", indent)
+
+	children := append([]*modelNode(nil), n.children...)
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	for _, c := range children {
+		writeModelItem(out, c, depth+1, referent)
+	}
+	fmt.Fprintf(out, "%s</Item>\n", indent)
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// cdataEscape splits any "]]>" in s across two CDATA sections
+func cdataEscape(s string) string {
+	return strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>")
+}
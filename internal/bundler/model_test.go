@@ -0,0 +1,61 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleModel(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "lib"), 0755))
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`local util = require("lib.util")
+print(util.answer)`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "lib", "util.lua"), []byte(`local strings = require("lib.strings")
+return { answer = strings.wrap("42]]>") }`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "lib", "strings.lua"), []byte(`return { wrap = function(s) return "<" .. s .. ">" end }`), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+
+	model, err := b.BundleModel("MyGame", false)
+	require.NoError(t, err)
+
+	assert.Contains(t, model, `<Item class="Folder" referent="RBX0">`)
+	assert.Contains(t, model, `<string name="Name">MyGame</string>`)
+	assert.Contains(t, model, `<Item class="Script"`)
+	assert.Contains(t, model, `<string name="Name">lib</string>`)
+	assert.Contains(t, model, `local util = require(script.Parent["lib"]["util"])`, "entry should require its sibling folder")
+	assert.Contains(t, model, `local strings = require(script.Parent["strings"])`, "modules should require relative to themselves")
+	assert.Contains(t, model, `strings.wrap("42]]]]><![CDATA[>")`, "]]> should be split across CDATA sections")
+	assert.Contains(t, model, `return "<" .. s .. ">"`, "sources live in CDATA and are not entity-escaped")
+	assert.NotContains(t, model, "EmbeddedModules", "models use real ModuleScripts instead of the embedded loader")
+}
+
+func TestBundleModel_RequiresRobloxTarget(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(`print("hi")`), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetTarget(TargetLua51))
+
+	_, err = b.BundleModel("main", false)
+	assert.Error(t, err)
+}
+
+func TestInstanceReference(t *testing.T) {
+	root := &modelNode{name: "root", class: "Folder"}
+	entry := root.child("main")
+	deep := root.child("a").child("b").child("c")
+	other := root.child("x").child("y")
+
+	assert.Equal(t, `script.Parent["a"]["b"]["c"]`, instanceReference(entry, deep))
+	assert.Equal(t, `script.Parent.Parent.Parent["x"]["y"]`, instanceReference(deep, other))
+	assert.Equal(t, `script.Parent["y"]`, instanceReference(root.child("x").child("z"), other))
+}
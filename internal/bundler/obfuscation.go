@@ -0,0 +1,148 @@
+package bundler
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+)
+
+// ObfuscationRule runs its own passes over the local modules it matches,
+// instead of the build's
+type ObfuscationRule struct {
+	// Pattern is a path.Match glob against the module key or the module's
+	// file relative to the project; one ending in / matches every file
+	// under that directory
+	Pattern string
+	// Passes run in order; none leaves the modules unobfuscated
+	Passes []obfuscator.Pass
+}
+
+// matches reports whether the rule applies to the module key loaded from
+// source, relative to the project
+func (r ObfuscationRule) matches(key, source string) bool {
+	if strings.HasSuffix(r.Pattern, "/") {
+		return strings.HasPrefix(source, r.Pattern)
+	}
+	for _, name := range []string{key, source} {
+		if ok, _ := path.Match(r.Pattern, name); ok && name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SetObfuscationRules sets per-module obfuscation. The first rule matching
+// a module decides its passes; modules no rule matches get the build's.
+func (b *Bundler) SetObfuscationRules(rules []ObfuscationRule) error {
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("obfuscation rule %d has no pattern", i+1)
+		}
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("obfuscation rule %d: invalid pattern %q: %w", i+1, rule.Pattern, err)
+		}
+		if err := obfuscator.Validate(rule.Passes); err != nil {
+			return fmt.Errorf("obfuscation rule %d (%s): %w", i+1, rule.Pattern, err)
+		}
+	}
+	b.obfuscationRules = rules
+	b.deriveRuleObfuscators()
+	return nil
+}
+
+// deriveRuleObfuscators creates the obfuscators of the rules, sharing the
+// build obfuscator's renames so names stay consistent across modules
+func (b *Bundler) deriveRuleObfuscators() {
+	b.ruleObfuscators = nil
+	if len(b.obfuscationRules) == 0 {
+		return
+	}
+	base := b.obfuscator
+	if base == nil {
+		base, _ = obfuscator.NewPipeline(nil)
+	}
+	b.ruleObfuscators = make([]*obfuscator.Obfuscator, len(b.obfuscationRules))
+	for i, rule := range b.obfuscationRules {
+		if len(rule.Passes) > 0 {
+			b.ruleObfuscators[i], _ = base.WithPasses(rule.Passes)
+		}
+	}
+}
+
+// obfuscatorFor returns the obfuscator of the local module key loaded from
+// source, or nil when it is left as written. The entry file has no key.
+func (b *Bundler) obfuscatorFor(key, source string) *obfuscator.Obfuscator {
+	source = b.displaySource(source)
+	for i, rule := range b.obfuscationRules {
+		if rule.matches(key, source) {
+			return b.ruleObfuscators[i]
+		}
+	}
+	return b.obfuscator
+}
+
+// obfuscate runs the obfuscator of the local module key loaded from source
+// over content, recording what it did to functions tagged --@hot
+func (b *Bundler) obfuscate(key, source, content string) string {
+	o := b.obfuscatorFor(key, source)
+	if o == nil {
+		return content
+	}
+	module := key
+	if module == "" {
+		module = b.displaySource(source)
+	}
+	if b.isLarge(content) {
+		b.warnf("%s is %d bytes, over the large module size of %d; it is embedded without obfuscation", module, len(content), b.largeModuleSize)
+		return content
+	}
+	result, hot := o.ObfuscateReport(content)
+	if len(hot) == 0 {
+		return result
+	}
+	if b.hotFunctions == nil {
+		b.hotFunctions = make(map[string][]obfuscator.HotFunction)
+	}
+	b.hotFunctions[module] = hot
+	for _, f := range hot {
+		if !f.Intact {
+			b.warnf("obfuscation changed the structure of hot function %s (%s:%d)", f.Name, module, f.Line)
+		}
+	}
+	return result
+}
+
+// HotReport is what obfuscation did to the functions of a module tagged
+// --@hot
+type HotReport struct {
+	Module    string // module key, or the entry file
+	Functions []obfuscator.HotFunction
+}
+
+// HotReports returns the hot function reports of the obfuscated modules,
+// by module
+func (b *Bundler) HotReports() []HotReport {
+	reports := make([]HotReport, 0, len(b.hotFunctions))
+	for module, functions := range b.hotFunctions {
+		reports = append(reports, HotReport{Module: module, Functions: functions})
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Module < reports[j].Module })
+	return reports
+}
+
+// obfuscators returns every obfuscator the build may run
+func (b *Bundler) obfuscators() []*obfuscator.Obfuscator {
+	var all []*obfuscator.Obfuscator
+	if b.obfuscator != nil {
+		all = append(all, b.obfuscator)
+	}
+	for _, o := range b.ruleObfuscators {
+		if o != nil {
+			all = append(all, o)
+		}
+	}
+	return all
+}
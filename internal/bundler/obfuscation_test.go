@@ -0,0 +1,92 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var obfuscationProject = MemoryFS{
+	"main.lua":         "local aes = require(\"crypto.aes\")\nlocal json = require(\"vendor.json\")\nlocal hud = require(\"hud\")\nprint(\"main\")\n",
+	"crypto/aes.lua":   `return { name = "aes" }`,
+	"vendor/json.lua":  `return { name = "json" }`,
+	"hud.lua":          `return { name = "hud" }`,
+	"vendor/other.lua": `return {}`,
+}
+
+var escapeStrings = []obfuscator.Pass{{Name: obfuscator.PassStrings, Method: obfuscator.StringsEscape}}
+
+func TestSetObfuscationRules(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(obfuscationProject)
+	require.NoError(t, b.SetObfuscation(escapeStrings))
+	require.NoError(t, b.SetObfuscationRules([]ObfuscationRule{
+		{Pattern: "vendor/"},
+		{Pattern: "crypto.*", Passes: []obfuscator.Pass{{Name: obfuscator.PassStrings, Method: obfuscator.StringsEscape}, {Name: obfuscator.PassMinify}}},
+		{Pattern: "crypto/aes.lua", Passes: obfuscator.Presets["light"]},
+	}))
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	modules := b.GetModules()
+	assert.Equal(t, `return { name = "json" }`, modules["vendor.json"], "vendor/ is left as written")
+	assert.Equal(t, `return { name = "\097\101\115" }`, modules["crypto.aes"], "the first matching rule wins")
+	assert.Equal(t, `return { name = "\104\117\100" }`, modules["hud"], "unmatched modules get the build's passes")
+	assert.Contains(t, out, `print("\109\097\105\110")`)
+
+	m := b.Manifest("game", "1.0.0", false)
+	require.Len(t, m.Modules, 3)
+	assert.Equal(t, "strings → minify", m.Modules[0].Obfuscation)
+	assert.Equal(t, "strings", m.Modules[1].Obfuscation)
+	assert.Equal(t, "none", m.Modules[2].Obfuscation)
+}
+
+func TestSetObfuscationRules_WithoutBuildPasses(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(obfuscationProject)
+	require.NoError(t, b.SetObfuscationRules([]ObfuscationRule{
+		{Pattern: "crypto/", Passes: obfuscator.Presets["medium"]},
+		{Pattern: "hud", Passes: obfuscator.Presets["medium"]},
+	}))
+	// Setting the build's passes afterwards keeps the rules
+	require.NoError(t, b.SetObfuscation(nil))
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, `print("main")`, "the entry file matches no rule")
+	assert.Equal(t, `return { name = "json" }`, b.GetModules()["vendor.json"])
+	assert.NotNil(t, b.GetNameMap(), "renames of rule passes are reported")
+}
+
+func TestSetObfuscationRules_Invalid(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+
+	assert.EqualError(t, b.SetObfuscationRules([]ObfuscationRule{{}}), "obfuscation rule 1 has no pattern")
+	assert.EqualError(t, b.SetObfuscationRules([]ObfuscationRule{{Pattern: "ui/", Passes: nil}, {Pattern: "[a"}}),
+		`obfuscation rule 2: invalid pattern "[a": syntax error in pattern`)
+	assert.EqualError(t, b.SetObfuscationRules([]ObfuscationRule{{Pattern: "ui/", Passes: []obfuscator.Pass{{Name: "shuffle"}}}}),
+		`obfuscation rule 1 (ui/): pass 1: unknown pass "shuffle" (want one of rename, strings, numbers, flow, junk, antitamper, minify, virtualize)`)
+}
+
+func TestHotReports(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":    "local physics = require(\"physics\")\nprint(\"main\")\n",
+		"physics.lua": "local M = {}\n\n--@hot\nfunction M.step(x)\n\treturn x * \"2\"\nend\n\nreturn M\n",
+	})
+	require.NoError(t, b.SetObfuscation(escapeStrings))
+
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, b.GetModules()["physics"], `return x * "2"`)
+	assert.Equal(t, []HotReport{{Module: "physics", Functions: []obfuscator.HotFunction{
+		{Name: "M.step", Line: 4, Skipped: []string{obfuscator.PassStrings}, Intact: true},
+	}}}, b.HotReports())
+	assert.Empty(t, b.GetWarnings())
+}
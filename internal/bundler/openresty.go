@@ -0,0 +1,64 @@
+package bundler
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DefaultLualibPaths are where OpenResty installs its own Lua libraries and
+// the ones opm adds, searched for resty.* modules unless SetLualibPaths
+// replaces them
+var DefaultLualibPaths = []string{"/usr/local/openresty/site/lualib", "/usr/local/openresty/lualib"}
+
+// openRestyExternals are the modules OpenResty provides built in or preloads:
+// they stay runtime requires, as do their submodules such as ngx.ssl
+var openRestyExternals = []string{"ngx", "ndk", "ffi", "jit", "bit", "cjson", "table.new", "table.clear", "table.nkeys", "string.buffer", "resty.core"}
+
+// SetLualibPaths sets the directories resty.* modules the project does not
+// contain are read from, in order. Nil restores DefaultLualibPaths.
+func (b *Bundler) SetLualibPaths(paths []string) {
+	b.lualibPaths = paths
+}
+
+// resolveLualib resolves a resty.* module from the project, then from the
+// lualib paths. When none has it, the last candidate is returned so the
+// read error names a lualib path.
+func (b *Bundler) resolveLualib(modulePath string) string {
+	candidates := b.lualibCandidates(modulePath)
+	for i, candidate := range candidates {
+		if _, err := b.fs.Stat(candidate); err == nil {
+			if i > 0 && b.verbose {
+				b.logf("📚 lualib: %s -> %s\n", modulePath, candidate)
+			}
+			return candidate
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// lualibCandidates returns the files a resty.* module may be read from, in
+// the order resolveLualib tries them
+func (b *Bundler) lualibCandidates(modulePath string) []string {
+	rel := filepath.FromSlash(strings.ReplaceAll(modulePath, ".", "/")) + ".lua"
+	paths := b.lualibPaths
+	if paths == nil {
+		paths = DefaultLualibPaths
+	}
+	candidates := []string{filepath.Join(b.baseDir, rel)}
+	for _, dir := range paths {
+		candidates = append(candidates, filepath.Join(dir, rel))
+	}
+	return candidates
+}
+
+// warnOpenRestyGlobals warns about globals the bundled files assign: an
+// OpenResty worker loads the bundle once and shares its globals between
+// every request it serves
+func (b *Bundler) warnOpenRestyGlobals() {
+	usage, _ := b.GlobalUsage()
+	for _, u := range usage {
+		for _, name := range u.Defines {
+			b.warnf("%s: sets global %s, which OpenResty shares between the requests of a worker; use a local or ngx.shared", b.displaySource(b.moduleSource(u.Module)), name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle_OpenResty(t *testing.T) {
+	b, err := NewBundler("app.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"app.lua":                           "local http = require(\"resty.http\")\nlocal cache = require(\"resty.lrucache\")\nlocal ssl = require(\"ngx.ssl\")\nlocal cjson = require(\"cjson.safe\")\nlocal M = {}\nfunction M.init_worker() end\nreturn M\n",
+		"opt/lualib/resty/http.lua":         "local base = require(\"resty.core.base\")\nlocal headers = require(\"resty.http_headers\")\nreturn {}\n",
+		"opt/lualib/resty/http_headers.lua": "return {}\n",
+		"resty/lrucache.lua":                "counter = 0\nreturn {}\n",
+	})
+	require.NoError(t, b.SetTarget(TargetOpenResty))
+	b.SetLualibPaths([]string{"/missing", "/opt/lualib"})
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app.lua", "/opt/lualib/resty/http.lua", "/opt/lualib/resty/http_headers.lua", "/resty/lrucache.lua"}, b.GetLocalFiles(),
+		"resty modules should come from the project first, then the lualib paths")
+
+	var externals []string
+	for _, ext := range b.ExternalRequires() {
+		externals = append(externals, ext.Path)
+	}
+	assert.ElementsMatch(t, []string{"ngx.ssl", "cjson.safe", "resty.core.base"}, externals)
+	assert.Contains(t, bundle, "return M\n")
+	assert.Equal(t, []string{"resty/lrucache.lua: sets global counter, which OpenResty shares between the requests of a worker; use a local or ngx.shared"}, b.GetWarnings())
+}
+
+func TestResolveLualib_Missing(t *testing.T) {
+	b, err := NewBundler("app.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"app.lua": "local http = require(\"resty.http\")\n"})
+	require.NoError(t, b.SetTarget(TargetOpenResty))
+
+	_, err = b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/usr/local/openresty/lualib/resty/http.lua", "the error should name the last lualib path tried")
+}
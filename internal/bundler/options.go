@@ -0,0 +1,90 @@
+package bundler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/constt/lua-bundler/internal/obfuscator"
+)
+
+// Option configures a Bundler made by New
+type Option func(*Bundler) error
+
+// WithVerbose prints the progress of builds, not only their warnings
+func WithVerbose(verbose bool) Option {
+	return func(b *Bundler) error {
+		b.verbose = verbose
+		return nil
+	}
+}
+
+// WithCache serves and stores remote scripts through c. Without it remote
+// scripts are downloaded on every build.
+func WithCache(c *cache.Cache) Option {
+	return func(b *Bundler) error {
+		if c == nil {
+			return fmt.Errorf("WithCache: cache is nil")
+		}
+		b.cache = c
+		return nil
+	}
+}
+
+// WithHTTPClient downloads remote scripts with client instead of one made
+// from the default HTTPOptions
+func WithHTTPClient(client *http.Client) Option {
+	return func(b *Bundler) error {
+		if client == nil {
+			return fmt.Errorf("WithHTTPClient: client is nil")
+		}
+		b.httpClient = client
+		return nil
+	}
+}
+
+// WithResolver settles ambiguous requires, as SetAmbiguityResolver does
+func WithResolver(resolver AmbiguityResolver) Option {
+	return func(b *Bundler) error {
+		b.ambiguityResolver = resolver
+		return nil
+	}
+}
+
+// WithLogger writes progress messages and warnings to w instead of standard
+// output; io.Discard silences them. Warnings are still returned with the
+// build.
+func WithLogger(w io.Writer) Option {
+	return func(b *Bundler) error {
+		if w == nil {
+			return fmt.Errorf("WithLogger: writer is nil")
+		}
+		b.log = w
+		return nil
+	}
+}
+
+// WithObfuscation runs passes over local modules, as SetObfuscation does
+func WithObfuscation(passes []obfuscator.Pass) Option {
+	return func(b *Bundler) error {
+		return b.SetObfuscation(passes)
+	}
+}
+
+// WithTarget sets the runtime target, as SetTarget does
+func WithTarget(target string) Option {
+	return func(b *Bundler) error {
+		return b.SetTarget(target)
+	}
+}
+
+// logf writes a progress message or warning to the bundler's logger
+func (b *Bundler) logf(format string, args ...interface{}) {
+	w := b.log
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintf(w, format, args...)
+}
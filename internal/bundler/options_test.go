@@ -0,0 +1,77 @@
+package bundler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Defaults(t *testing.T) {
+	b, err := New("main.lua")
+	require.NoError(t, err)
+	assert.Equal(t, TargetRoblox, b.GetTarget())
+	assert.False(t, b.verbose)
+	assert.NotNil(t, b.cache)
+	assert.NotNil(t, b.httpClient)
+}
+
+func TestNew_Options(t *testing.T) {
+	c, err := cache.NewCache(false)
+	require.NoError(t, err)
+	client := &http.Client{}
+	resolver := func(Ambiguity) (string, error) { return "", nil }
+
+	b, err := New("main.lua",
+		WithVerbose(true),
+		WithCache(c),
+		WithHTTPClient(client),
+		WithResolver(resolver),
+		WithLogger(io.Discard),
+		WithObfuscation([]obfuscator.Pass{{Name: obfuscator.PassRename}}),
+		WithTarget(TargetLua54),
+	)
+	require.NoError(t, err)
+	assert.True(t, b.verbose)
+	assert.Same(t, c, b.cache)
+	assert.Same(t, client, b.httpClient)
+	assert.NotNil(t, b.ambiguityResolver)
+	assert.Equal(t, io.Discard, b.log)
+	require.NotNil(t, b.obfuscator)
+	assert.Equal(t, TargetLua54, b.GetTarget())
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	_, err := New("main.lua", WithTarget("playstation"))
+	assert.Error(t, err)
+
+	_, err = New("main.lua", WithCache(nil))
+	assert.Error(t, err)
+
+	_, err = New("main.lua", WithHTTPClient(nil))
+	assert.Error(t, err)
+
+	_, err = New("main.lua", WithLogger(nil))
+	assert.Error(t, err)
+}
+
+func TestWithLogger(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte("local util = require(\"util\")\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "util.lua"), []byte("return {}"), 0644))
+
+	var log bytes.Buffer
+	b, err := New(mainFile, WithVerbose(true), WithLogger(&log))
+	require.NoError(t, err)
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, log.String(), "📄 Processed: util")
+}
@@ -0,0 +1,67 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PluginInfo describes the Studio plugin wrapping a bundle
+type PluginInfo struct {
+	Name    string // plugin Script name
+	Toolbar string // toolbar title (default: Name)
+	Button  string // button text (default: Run)
+	Tooltip string // button tooltip
+	Icon    string // button image, such as rbxassetid://1234
+}
+
+// PluginModel wraps a Lua bundle as a Studio plugin: an rbxmx model holding
+// one Script that adds a toolbar button and runs the bundle on each click.
+// Saved to the Studio Plugins folder, the same bundle built for executors
+// runs as a plugin, where the plugin global is set.
+func (b *Bundler) PluginModel(bundle string, info PluginInfo) (string, error) {
+	if b.target != TargetRoblox {
+		return "", fmt.Errorf("plugin output requires the %s target (got %s)", TargetRoblox, b.target)
+	}
+	if info.Name == "" {
+		return "", fmt.Errorf("plugin name is empty")
+	}
+	if info.Toolbar == "" {
+		info.Toolbar = info.Name
+	}
+	if info.Button == "" {
+		info.Button = "Run"
+	}
+
+	script := &modelNode{name: info.Name, class: "Script", source: pluginSource(bundle, info)}
+	var out strings.Builder
+	out.WriteString(`<roblox xmlns:xmime="http://www.w3.org/2005/05/xmlmime" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:noNamespaceSchemaLocation="http://www.roblox.com/roblox.xsd" version="4">` + "\n")
+	referent := 0
+	writeModelItem(&out, script, 1, &referent)
+	out.WriteString("</roblox>\n")
+	return out.String(), nil
+}
+
+// pluginSource returns the plugin Script source: the toolbar button stub,
+// with the bundle as the function its Click handler runs
+func pluginSource(bundle string, info PluginInfo) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "-- Studio plugin: %s\n", info.Name)
+	fmt.Fprintf(&out, "local PluginToolbar = plugin:CreateToolbar(%s)\n", quoteLua(info.Toolbar))
+	fmt.Fprintf(&out, "local PluginButton = PluginToolbar:CreateButton(%s, %s, %s, %s)\n",
+		quoteLua(info.Button), quoteLua(info.Tooltip), quoteLua(info.Icon), quoteLua(info.Button))
+	out.WriteString("PluginButton.ClickableWhenViewportHidden = true\n\n")
+	out.WriteString("local function runBundle(...)\n")
+	out.WriteString(bundle)
+	if !strings.HasSuffix(bundle, "\n") {
+		out.WriteString("\n")
+	}
+	out.WriteString("end\n\n")
+	out.WriteString("PluginButton.Click:Connect(function()\n")
+	out.WriteString("    local ok, err = pcall(runBundle)\n")
+	out.WriteString("    if not ok then\n")
+	out.WriteString("        warn(err)\n")
+	out.WriteString("    end\n")
+	out.WriteString("    PluginButton:SetActive(false)\n")
+	out.WriteString("end)\n")
+	return out.String()
+}
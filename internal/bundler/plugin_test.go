@@ -0,0 +1,45 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginModel(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "local util = require(\"util\")\nutil.run(...)\n",
+		"util.lua": "return { run = function() print(\"]]>\") end }\n",
+	})
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	model, err := b.PluginModel(bundle, PluginInfo{Name: "MyTool", Tooltip: "Open \"My Tool\"", Icon: "rbxassetid://1234"})
+	require.NoError(t, err)
+
+	assert.Contains(t, model, `<Item class="Script" referent="RBX0">`)
+	assert.Contains(t, model, `<string name="Name">MyTool</string>`)
+	assert.Contains(t, model, "local PluginToolbar = plugin:CreateToolbar(\"MyTool\")\n")
+	assert.Contains(t, model, "PluginToolbar:CreateButton(\"Run\", \"Open \\\"My Tool\\\"\", \"rbxassetid://1234\", \"Run\")\n")
+	assert.Contains(t, model, "local function runBundle(...)\n-- Bundled Lua Script\n")
+	assert.Contains(t, model, "local ok, err = pcall(runBundle)")
+	assert.Contains(t, model, `print("]]]]><![CDATA[>")`)
+
+	source := pluginSource(bundle, PluginInfo{Name: "MyTool", Toolbar: "MyTool", Button: "Run"})
+	_, err = parser.Parse(source)
+	assert.NoError(t, err, "the bundle should compile inside the Click handler's function")
+}
+
+func TestPluginModel_RequiresRobloxTarget(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetTarget(TargetLua51))
+
+	_, err = b.PluginModel("print(1)\n", PluginInfo{Name: "MyTool"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires the roblox target")
+}
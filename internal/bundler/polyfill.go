@@ -0,0 +1,240 @@
+package bundler
+
+import (
+	"regexp"
+	"strings"
+)
+
+// polyfill is a built-in shim injected when bundled code references a
+// feature missing from the selected target
+type polyfill struct {
+	name    string
+	targets []string // targets lacking the feature natively
+	detect  *regexp.Regexp
+	code    string
+	warning string // how the shim differs from the real feature, raised when it is injected
+}
+
+var nonRobloxTargets = []string{TargetLua51, TargetLua52, TargetLua53, TargetLua54, TargetLuaJIT, TargetLove2D, TargetOpenResty, TargetComputerCraft, TargetOpenComputers, TargetWoW, TargetGMod, TargetFiveM}
+
+// envTargets are the targets with _ENV instead of loadstring and setfenv
+var envTargets = []string{TargetLua52, TargetLua53, TargetLua54, TargetOpenComputers, TargetFiveM}
+
+var polyfills = []polyfill{
+	{
+		name:    "bit32",
+		targets: []string{TargetLua51, TargetLua53, TargetLua54, TargetLuaJIT, TargetLove2D, TargetOpenResty, TargetOpenComputers, TargetWoW, TargetGMod, TargetFiveM},
+		detect:  regexp.MustCompile(`\bbit32\s*\.`),
+		code: `local bit32 = bit32 or (function()
+    local MOD = 2 ^ 32
+    local function norm(x) return x % MOD end
+    local function bitop(a, b, fn)
+        local r, p = 0, 1
+        a, b = norm(a), norm(b)
+        for _ = 1, 32 do
+            local ra, rb = a % 2, b % 2
+            if fn(ra, rb) then r = r + p end
+            a, b, p = (a - ra) / 2, (b - rb) / 2, p * 2
+        end
+        return r
+    end
+    local lib = {}
+    function lib.band(a, b) return bitop(a, b, function(x, y) return x == 1 and y == 1 end) end
+    function lib.bor(a, b) return bitop(a, b, function(x, y) return x == 1 or y == 1 end) end
+    function lib.bxor(a, b) return bitop(a, b, function(x, y) return x ~= y end) end
+    function lib.bnot(a) return MOD - 1 - norm(a) end
+    function lib.btest(a, b) return lib.band(a, b) ~= 0 end
+    function lib.lshift(a, n) return norm(norm(a) * 2 ^ n) end
+    function lib.rshift(a, n) return math.floor(norm(a) / 2 ^ n) end
+    return lib
+end)()`,
+	},
+	{
+		name:    "loadstring",
+		targets: envTargets,
+		detect:  regexp.MustCompile(`(?:^|[^.:\w])loadstring\b`),
+		code:    `local loadstring = loadstring or load`,
+	},
+	{
+		// Functions reach globals through their _ENV upvalue, so swapping
+		// that upvalue for one holding env changes their environment
+		name:    "setfenv",
+		targets: envTargets,
+		detect:  regexp.MustCompile(`(?:^|[^.:\w])setfenv\b`),
+		code: `local setfenv = setfenv or function(fn, env)
+    if type(fn) == "number" then fn = debug.getinfo(fn + 1, "f").func end
+    local i = 1
+    while true do
+        local name = debug.getupvalue(fn, i)
+        if name == "_ENV" then
+            debug.upvaluejoin(fn, i, function() return env end, 1)
+            break
+        elseif not name then
+            break
+        end
+        i = i + 1
+    end
+    return fn
+end`,
+	},
+	{
+		name:    "getfenv",
+		targets: envTargets,
+		detect:  regexp.MustCompile(`(?:^|[^.:\w])getfenv\b`),
+		code: `local getfenv = getfenv or function(fn)
+    fn = fn or 1
+    if fn == 0 then return _G end
+    if type(fn) == "number" then fn = debug.getinfo(fn + 1, "f").func end
+    local i = 1
+    while true do
+        local name, value = debug.getupvalue(fn, i)
+        if name == "_ENV" then return value end
+        if not name then return _G end
+        i = i + 1
+    end
+end`,
+	},
+	{
+		name:    "table.clear",
+		targets: nonRobloxTargets,
+		detect:  regexp.MustCompile(`\btable\s*\.\s*clear\b`),
+		code: `if not table.clear then
+    table.clear = function(t)
+        for k in pairs(t) do t[k] = nil end
+    end
+end`,
+	},
+	{
+		name:    "string.split",
+		targets: nonRobloxTargets,
+		detect:  regexp.MustCompile(`\bstring\s*\.\s*split\b|:\s*split\s*\(`),
+		code: `if not string.split then
+    string.split = function(s, sep)
+        sep = sep or ","
+        local parts, start = {}, 1
+        if sep == "" then
+            for i = 1, #s do parts[i] = s:sub(i, i) end
+            return parts
+        end
+        while true do
+            local i, j = s:find(sep, start, true)
+            if not i then
+                parts[#parts + 1] = s:sub(start)
+                return parts
+            end
+            parts[#parts + 1] = s:sub(start, i - 1)
+            start = j + 1
+        end
+    end
+end`,
+	},
+	{
+		// A cooperative scheduler: threads yield in task.wait and queued
+		// work runs when the main thread waits or calls task.step, as
+		// plain Lua has no event loop to resume them
+		name:    "task",
+		targets: nonRobloxTargets,
+		detect:  regexp.MustCompile(`\btask\s*\.\s*(wait|spawn|defer|delay|cancel)\b`),
+		warning: "task: the shim has no event loop; queued and waiting threads only run while the main thread is in task.wait, which busy-waits, or calls task.step",
+		code: `local task = task or (function()
+    local now, unpack = os.clock, unpack or table.unpack
+    local queue, cancelled = {}, setmetatable({}, { __mode = "k" })
+    local function thread(f)
+        return type(f) == "thread" and f or coroutine.create(f)
+    end
+    local function schedule(co, at, ...)
+        queue[#queue + 1] = { co = co, at = at, args = { n = select("#", ...), ... } }
+        return co
+    end
+    local lib = {}
+    function lib.step()
+        local t, due, rest = now(), {}, {}
+        for _, item in ipairs(queue) do
+            if item.at <= t then due[#due + 1] = item else rest[#rest + 1] = item end
+        end
+        queue = rest
+        for _, item in ipairs(due) do
+            if not cancelled[item.co] and coroutine.status(item.co) == "suspended" then
+                coroutine.resume(item.co, unpack(item.args, 1, item.args.n))
+            end
+        end
+        return #queue > 0
+    end
+    function lib.wait(t)
+        local start = now()
+        t = t or 0
+        local co, main = coroutine.running()
+        if co and not main then
+            schedule(co, start + t)
+            coroutine.yield()
+        else
+            repeat lib.step() until now() - start >= t
+        end
+        return now() - start
+    end
+    function lib.spawn(f, ...)
+        local co = thread(f)
+        coroutine.resume(co, ...)
+        return co
+    end
+    function lib.defer(f, ...)
+        return schedule(thread(f), now(), ...)
+    end
+    function lib.delay(t, f, ...)
+        return schedule(thread(f), now() + (t or 0), ...)
+    end
+    function lib.cancel(co)
+        cancelled[co] = true
+        for i = #queue, 1, -1 do
+            if queue[i].co == co then table.remove(queue, i) end
+        end
+        if coroutine.close then pcall(coroutine.close, co) end
+    end
+    return lib
+end)()`,
+	},
+}
+
+// requiredPolyfills returns the polyfills needed by the given sources for the current target
+func (b *Bundler) requiredPolyfills(sources ...string) []polyfill {
+	var needed []polyfill
+	for _, p := range polyfills {
+		if !containsString(p.targets, b.target) {
+			continue
+		}
+		for _, src := range sources {
+			if p.detect.MatchString(src) {
+				needed = append(needed, p)
+				break
+			}
+		}
+	}
+	return needed
+}
+
+// GetPolyfills returns the names of polyfills injected into the last generated bundle
+func (b *Bundler) GetPolyfills() []string {
+	return b.polyfills
+}
+
+// writePolyfills writes the given polyfills to the bundle output
+func writePolyfills(output *strings.Builder, needed []polyfill) {
+	if len(needed) == 0 {
+		return
+	}
+	output.WriteString("-- Polyfills\n")
+	for _, p := range needed {
+		output.WriteString(p.code)
+		output.WriteString("\n\n")
+	}
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
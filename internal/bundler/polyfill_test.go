@@ -0,0 +1,140 @@
+package bundler
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/luavm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredPolyfills(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		source string
+		want   []string
+	}{
+		{
+			name:   "roblox needs nothing",
+			target: TargetRoblox,
+			source: `table.clear(t) local parts = ("a,b"):split(",") task.wait(1)`,
+			want:   nil,
+		},
+		{
+			name:   "lua51 bit ops",
+			target: TargetLua51,
+			source: `local x = bit32.band(a, 0xff)`,
+			want:   []string{"bit32"},
+		},
+		{
+			name:   "lua52 has native bit32",
+			target: TargetLua52,
+			source: `local x = bit32.band(a, 0xff)`,
+			want:   nil,
+		},
+		{
+			name:   "method-style split",
+			target: TargetLua54,
+			source: `local parts = line:split(",")`,
+			want:   []string{"string.split"},
+		},
+		{
+			name:   "multiple polyfills",
+			target: TargetLuaJIT,
+			source: "table.clear(cache)\ntask.spawn(run)",
+			want:   []string{"table.clear", "task"},
+		},
+		{
+			name:   "lua54 environment functions",
+			target: TargetLua54,
+			source: "local f = loadstring(src)\nsetfenv(f, sandbox)\nlocal env = getfenv(2)",
+			want:   []string{"loadstring", "setfenv", "getfenv"},
+		},
+		{
+			name:   "lua51 has native environment functions",
+			target: TargetLua51,
+			source: "setfenv(loadstring(src), sandbox)",
+			want:   nil,
+		},
+		{
+			name:   "fields named like environment functions",
+			target: TargetLua53,
+			source: "lib.loadstring(src)\nobj:setfenv(env)",
+			want:   nil,
+		},
+		{
+			name:   "unreferenced features are not injected",
+			target: TargetLua51,
+			source: `print("hello")`,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBundler("test.lua", false, false)
+			require.NoError(t, err, "NewBundler should not fail")
+			require.NoError(t, b.SetTarget(tt.target))
+
+			var got []string
+			for _, p := range b.requiredPolyfills(tt.source) {
+				got = append(got, p.name)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGenerateBundle_Polyfills(t *testing.T) {
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+	require.NoError(t, b.SetTarget(TargetLua51))
+
+	b.modules["util"] = `return { clear = function(t) table.clear(t) end }`
+
+	result := b.generateBundle(`local util = require("util")`, false)
+
+	assert.Contains(t, result, "-- Polyfills", "bundle should contain polyfill section")
+	assert.Contains(t, result, "table.clear = function(t)", "bundle should contain table.clear polyfill")
+	assert.NotContains(t, result, "local bit32", "bundle should not contain unreferenced polyfills")
+	assert.Equal(t, []string{"table.clear"}, b.GetPolyfills())
+}
+
+func TestTaskPolyfill(t *testing.T) {
+	var task polyfill
+	for _, p := range polyfills {
+		if p.name == "task" {
+			task = p
+		}
+	}
+	script := `
+local log = {}
+local function add(s) log[#log + 1] = s end
+task.spawn(function()
+    add("spawned")
+    local waited = task.wait(0.02)
+    add(waited >= 0.02 and "resumed" or "resumed early")
+end)
+task.delay(0, function(n) add("delayed " .. n) end, 1)
+task.defer(function() add("deferred") end)
+task.cancel(task.delay(0, function() add("cancelled") end))
+add("main")
+task.wait(0.1)
+print(table.concat(log, ", "))
+`
+	out, err := luavm.Run(task.code+"\n"+script, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "spawned, main, delayed 1, deferred, resumed\n", out,
+		"waiting threads yield, queued work runs while the main thread waits and cancelled work never runs")
+
+	b, err := New("test.lua", WithLogger(io.Discard))
+	require.NoError(t, err)
+	require.NoError(t, b.SetTarget(TargetLua51))
+	b.generateBundle(`task.wait(1)`, false)
+	assert.Equal(t, []string{"task"}, b.GetPolyfills())
+	require.Len(t, b.GetWarnings(), 1)
+	assert.Contains(t, b.GetWarnings()[0], "polyfill task: the shim has no event loop")
+}
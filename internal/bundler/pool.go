@@ -0,0 +1,41 @@
+package bundler
+
+import (
+	"context"
+	"runtime"
+)
+
+// Pool bounds how many builds run at once, for servers building on behalf
+// of several clients. A Bundler is not safe for concurrent use and Bundle
+// consumes it, so every build in a pool creates its own with NewBundler;
+// bundlers share nothing but the disk cache, which tolerates concurrent
+// builds and processes.
+type Pool struct {
+	slots chan struct{}
+}
+
+// NewPool returns a pool running up to workers builds at once, one per CPU
+// when workers is 0 or less
+func NewPool(workers int) *Pool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Pool{slots: make(chan struct{}, workers)}
+}
+
+// Workers returns how many builds the pool runs at once
+func (p *Pool) Workers() int {
+	return cap(p.slots)
+}
+
+// Do runs build once a worker is free, returning its error, or ctx's error
+// when ctx is done first
+func (p *Pool) Do(ctx context.Context, build func() error) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.slots }()
+	return build()
+}
@@ -0,0 +1,104 @@
+package bundler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPool(t *testing.T) {
+	assert.Equal(t, 3, NewPool(3).Workers())
+	assert.Equal(t, runtime.NumCPU(), NewPool(0).Workers())
+}
+
+func TestPool_BoundsBuilds(t *testing.T) {
+	pool := NewPool(2)
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Do(context.Background(), func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), peak)
+}
+
+func TestPool_Errors(t *testing.T) {
+	pool := NewPool(1)
+	failed := errors.New("failed")
+	assert.Equal(t, failed, pool.Do(context.Background(), func() error { return failed }))
+
+	// A build waiting for a worker gives up with its context
+	release := make(chan struct{})
+	go pool.Do(context.Background(), func() error { <-release; return nil })
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ran := false
+	err := pool.Do(ctx, func() error { ran = true; return nil })
+	close(release)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, ran)
+}
+
+func TestPool_ParallelBundles(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("m%d.lua", i)), []byte(fmt.Sprintf("return %d\n", i)), 0644))
+	}
+	main := ""
+	for i := 0; i < 10; i++ {
+		main += fmt.Sprintf("local m%d = require(\"m%d\")\n", i, i)
+	}
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte(main), 0644))
+
+	pool := NewPool(4)
+	bundles := make([]string, 8)
+	errs := make([]error, len(bundles))
+	var wg sync.WaitGroup
+	for i := range bundles {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = pool.Do(context.Background(), func() error {
+				b, err := NewBundler(entry, false, false)
+				if err != nil {
+					return err
+				}
+				bundles[i], err = b.Bundle(i%2 == 0)
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range bundles {
+		require.NoError(t, errs[i])
+		assert.Equal(t, bundles[i%2], bundles[i], "builds in parallel give the same bundle")
+	}
+}
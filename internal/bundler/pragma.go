@@ -0,0 +1,51 @@
+package bundler
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Require pragmas, comments overriding whether the requires they annotate
+// are bundled
+const (
+	PragmaLocal    = "--@bundle-as-local"    // embed the required file whatever its name looks like
+	PragmaExternal = "--@bundle-as-external" // leave the require to the runtime
+)
+
+// pragmaRegex matches a require pragma, capturing local or external
+var pragmaRegex = regexp.MustCompile(`--@bundle-as-(local|external)\b`)
+
+// requirePragma returns "local" or "external" when a pragma governs the
+// requires on line i of lines: one trailing the line, or one alone on the
+// line above. It returns "" otherwise.
+func requirePragma(lines []string, i int) string {
+	if i < 0 || i >= len(lines) {
+		return ""
+	}
+	if m := pragmaRegex.FindStringSubmatch(lines[i]); m != nil {
+		return m[1]
+	}
+	if i > 0 {
+		above := strings.TrimSpace(lines[i-1])
+		if m := pragmaRegex.FindStringSubmatch(above); m != nil && strings.HasPrefix(above, m[0]) {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// isLocalRequire reports whether a require of modulePath is bundled: as the
+// pragma governing it says, as a recorded decision says, or as
+// isLocalModule guesses when neither exists
+func (b *Bundler) isLocalRequire(modulePath, pragma string) bool {
+	switch pragma {
+	case "local":
+		return true
+	case "external":
+		return false
+	}
+	if choice, ok := b.requireDecisions[modulePath]; ok {
+		return choice != DecisionExternal
+	}
+	return b.isLocalModule(modulePath)
+}
@@ -0,0 +1,80 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirePragma(t *testing.T) {
+	lines := []string{
+		"--@bundle-as-local",
+		"local a = require(\"Players.util\")",
+		"local b = require(\"tasks.cook\") --@bundle-as-external",
+		"local c = require(\"x\")",
+		"print(\"--@bundle-as-external\")",
+		"local d = require(\"y\")",
+		"",
+	}
+	assert.Equal(t, "local", requirePragma(lines, 1), "a pragma alone on the line above applies")
+	assert.Equal(t, "external", requirePragma(lines, 2), "a trailing pragma applies")
+	assert.Equal(t, "", requirePragma(lines, 3), "a pragma trailing the line above does not apply")
+	assert.Equal(t, "", requirePragma(lines, 5), "a pragma inside a string on the line above does not apply")
+	assert.Equal(t, "", requirePragma(lines, 7))
+}
+
+func TestBundle_RequirePragmas(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "--@bundle-as-local\n" +
+			"local theme = require(\"Lighting.theme\")\n" +
+			"local shared = require(\"shared.util\") --@bundle-as-external\n" +
+			"local util = require(\"shared.util\")\n",
+		"Lighting/theme.lua": "return { dark = true }\n",
+		"shared/util.lua":    "return {}\n",
+	})
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, `local theme = loadModule("Lighting.theme")`, "bundle-as-local should embed a name read as a Roblox service")
+	assert.Contains(t, bundle, "dark = true")
+	assert.Contains(t, bundle, `local shared = require("shared.util") --@bundle-as-external`, "bundle-as-external should keep the require even when the module is embedded")
+	assert.Contains(t, bundle, `local util = loadModule("shared.util")`, "pragmas apply to their own require only")
+}
+
+func TestBundle_RequirePragmaExternalOnly(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "--@bundle-as-external\nlocal json = require(\"json\")\n"})
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, `local json = require("json")`)
+
+	var externals []string
+	for _, ext := range b.ExternalRequires() {
+		externals = append(externals, ext.Path)
+	}
+	assert.Equal(t, []string{"json"}, externals)
+}
+
+func TestLint_RequirePragmas(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Players"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Players", "Local.lua"), []byte("return {}"), 0644))
+
+	entry := filepath.Join(dir, "main.lua")
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+
+	src := "--@bundle-as-local\nlocal players = require(\"Players.Local\")\nlocal missing = require(\"missing\") --@bundle-as-external\n"
+	diags := b.Lint(entry, src, func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	})
+	assert.Empty(t, diags, "pragmas should silence the external-prefix and unresolved-require checks")
+}
@@ -0,0 +1,79 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Runtime primitives generated code may rely on
+const (
+	PrimitiveLoadstring = "loadstring" // loadstring(source, name), Lua 5.1 and Luau
+	PrimitiveLoad       = "load"       // load(source, name) accepting source strings
+	PrimitiveSetfenv    = "setfenv"    // setfenv and getfenv function environments
+	PrimitiveEnv        = "_ENV"       // lexical _ENV environments, Lua 5.2 and later
+)
+
+// targetPrimitives lists the primitives each target provides natively.
+// Lua 5.1's load only accepts reader functions, so it does not count.
+var targetPrimitives = map[string][]string{
+	TargetRoblox:    {PrimitiveLoadstring, PrimitiveSetfenv},
+	TargetLua51:     {PrimitiveLoadstring, PrimitiveSetfenv},
+	TargetLua52:     {PrimitiveLoad, PrimitiveEnv},
+	TargetLua53:     {PrimitiveLoad, PrimitiveEnv},
+	TargetLua54:     {PrimitiveLoad, PrimitiveEnv},
+	TargetLuaJIT:    {PrimitiveLoadstring, PrimitiveLoad, PrimitiveSetfenv},
+	TargetLove2D:    {PrimitiveLoadstring, PrimitiveLoad, PrimitiveSetfenv},
+	TargetOpenResty: {PrimitiveLoadstring, PrimitiveLoad, PrimitiveSetfenv},
+	// CC: Tweaked keeps the Lua 5.1 functions unless disable_lua51_features is set
+	TargetComputerCraft: {PrimitiveLoadstring, PrimitiveLoad, PrimitiveSetfenv},
+	TargetOpenComputers: {PrimitiveLoad, PrimitiveEnv},
+	TargetWoW:           {PrimitiveLoadstring, PrimitiveSetfenv},
+	// Garry's Mod removes loadstring in favour of CompileString
+	TargetGMod:  {PrimitiveSetfenv},
+	TargetFiveM: {PrimitiveLoad, PrimitiveEnv},
+}
+
+// obfuscationPrimitives lists the primitives each obfuscation pass needs
+// at runtime; a pass needs one of them. The current passes only emit
+// portable Lua, so none needs any.
+var obfuscationPrimitives = map[string][]string{}
+
+// hasPrimitive reports whether the target provides primitive
+func (b *Bundler) hasPrimitive(primitive string) bool {
+	return containsString(targetPrimitives[b.target], primitive)
+}
+
+// chunkCompiler returns the function compiling source strings on the
+// target: loadstring where it exists, load otherwise
+func (b *Bundler) chunkCompiler() (string, bool) {
+	switch {
+	case b.hasPrimitive(PrimitiveLoadstring):
+		return "loadstring", true
+	case b.hasPrimitive(PrimitiveLoad):
+		return "load", true
+	}
+	return "", false
+}
+
+// checkPrimitives returns an error when the loader or obfuscation passes
+// needs primitives the target lacks, naming what is missing
+func (b *Bundler) checkPrimitives() error {
+	if b.loader == LoaderLazy {
+		if _, ok := b.chunkCompiler(); !ok {
+			return fmt.Errorf("the %s loader compiles modules with loadstring or load, which the %s target does not provide; use the %s loader instead", LoaderLazy, b.target, LoaderClosure)
+		}
+	}
+	for _, o := range b.obfuscators() {
+		for _, pass := range o.Passes() {
+			needed := obfuscationPrimitives[pass.Name]
+			provided := len(needed) == 0
+			for _, primitive := range needed {
+				provided = provided || b.hasPrimitive(primitive)
+			}
+			if !provided {
+				return fmt.Errorf("the %s obfuscation pass needs %s, which the %s target does not provide", pass.Name, strings.Join(needed, " or "), b.target)
+			}
+		}
+	}
+	return nil
+}
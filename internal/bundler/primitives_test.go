@@ -0,0 +1,68 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkCompiler(t *testing.T) {
+	for target, want := range map[string]string{
+		TargetRoblox: "loadstring",
+		TargetLua51:  "loadstring",
+		TargetLuaJIT: "loadstring",
+		TargetLua52:  "load",
+		TargetLua54:  "load",
+	} {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		require.NoError(t, b.SetTarget(target))
+		compile, ok := b.chunkCompiler()
+		assert.True(t, ok, target)
+		assert.Equal(t, want, compile, target)
+	}
+}
+
+func TestBundle_LazyLoaderLoad(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "local util = require(\"util\")\nutil.run()\n",
+		"util.lua": "local f = loadstring(\"return 1\")\nsetfenv(f, {})\nreturn { run = f }\n",
+	})
+	require.NoError(t, b.SetTarget(TargetLua53))
+	require.NoError(t, b.SetLoader(LoaderLazy))
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, result, "module = assert(load(module, \"=\" .. url))(loadModule, loadstring, setfenv)\n")
+	assert.Contains(t, result, "local loadstring = loadstring or load\n")
+	assert.Contains(t, result, "local setfenv = setfenv or function(fn, env)\n")
+	assert.NotContains(t, result, "(loadstring or load)")
+}
+
+func TestCheckPrimitives(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "print(1)\n"})
+	require.NoError(t, b.SetLoader(LoaderLazy))
+	b.target = "sandbox" // a target compiling no source strings
+
+	_, err = b.Bundle(false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the lazy loader compiles modules with loadstring or load, which the sandbox target does not provide")
+
+	require.NoError(t, b.SetLoader(LoaderClosure))
+	obfuscationPrimitives[obfuscator.PassRename] = []string{PrimitiveSetfenv}
+	defer delete(obfuscationPrimitives, obfuscator.PassRename)
+	b.SetObfuscationLevel(2)
+	require.NoError(t, b.SetTarget(TargetLua54))
+	err = b.checkPrimitives()
+	require.Error(t, err)
+	assert.Equal(t, "the rename obfuscation pass needs setfenv, which the lua54 target does not provide", err.Error())
+
+	require.NoError(t, b.SetTarget(TargetLua51))
+	assert.NoError(t, b.checkPrimitives())
+}
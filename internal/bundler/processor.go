@@ -1,59 +1,196 @@
 package bundler
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
-	"os"
+	neturl "net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/cache"
+	"github.com/constt/lua-bundler/internal/lockfile"
 )
 
-// downloadHTTP downloads content from HTTP URL
+// downloadHTTP downloads a Lua source from an HTTP URL as normalized UTF-8 text
 func (b *Bundler) downloadHTTP(url string) (string, error) {
+	content, err := b.download(url)
+	if err != nil {
+		return "", err
+	}
+	return b.normalizeSource(url, content)
+}
+
+// downloadRemote downloads a remote module found in a script. While
+// DiscoverRemoteURLs runs, a URL that cannot be downloaded is recorded as a
+// remote module with nothing below it and ok is false, so the caller moves
+// on instead of failing.
+func (b *Bundler) downloadRemote(url string) (content string, ok bool, err error) {
+	content, err = b.downloadHTTP(url)
+	if err != nil && b.discovering {
+		if b.verbose {
+			b.logf("⚠️  %v\n", err)
+		}
+		b.httpModules[url] = true
+		return "", false, nil
+	}
+	return content, true, err
+}
+
+// download downloads content from an HTTP URL, falling back to configured mirrors.
+// When a lockfile is set, content must match the pinned hash; unpinned URLs get pinned.
+// The content is cached and hashed as served.
+func (b *Bundler) download(url string) (string, error) {
 	// Check cache first
 	if b.cache.IsEnabled() {
-		if content, found, err := b.cache.Get(url); err == nil && found {
+		content, found, err := b.cache.Get(url)
+		switch {
+		case errors.Is(err, cache.ErrCorrupt):
+			b.warnf("cached copy of %s does not match its stored hash; downloading it again", url)
+			b.cacheStats.Revalidations++
+		case err == nil && found && (b.lockfile == nil || b.lockfile.Verify(url, content)):
 			if b.verbose {
-				fmt.Printf("� Using cached: %s\n", url)
+				b.logf("💾 Using cached: %s\n", url)
 			}
+			b.cacheStats.Hits++
+			b.cacheStats.CacheBytes += int64(len(content))
 			return content, nil
+		case err == nil && found:
+			if b.verbose {
+				b.logf("⚠️  Cached copy of %s does not match lockfile, refetching\n", url)
+			}
+			b.cacheStats.Revalidations++
+		default:
+			b.cacheStats.Misses++
+		}
+	}
+	if b.offline {
+		return "", fmt.Errorf("%s is not cached and downloads are off (--offline); run lua-bundler prefetch first", url)
+	}
+
+	candidates := append([]string{url}, b.mirrors[url]...)
+	var errs []error
+
+	for _, candidate := range candidates {
+		started := time.Now()
+		contentStr, finalURL, err := b.fetchURL(candidate)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		b.cacheStats.Downloads++
+		b.cacheStats.NetworkBytes += int64(len(contentStr))
+		b.cacheStats.NetworkTime += time.Since(started)
+
+		if b.lockfile != nil && !b.lockfile.Verify(url, contentStr) {
+			entry, _ := b.lockfile.Get(url)
+			errs = append(errs, fmt.Errorf("content of %s does not match lockfile hash (expected %s, got %s)",
+				candidate, entry.SHA256, lockfile.Hash(contentStr)))
+			continue
+		}
+
+		if candidate != url && b.verbose {
+			b.logf("🪞 Using mirror %s for %s\n", candidate, url)
+		}
+
+		if b.lockfile != nil {
+			resolved := ""
+			if finalURL != url {
+				resolved = finalURL
+			}
+			b.lockfile.Set(url, lockfile.Entry{SHA256: lockfile.Hash(contentStr), Resolved: resolved})
+		}
+
+		// Store in cache
+		if b.cache.IsEnabled() {
+			if err := b.cache.Set(url, contentStr); err != nil {
+				// Log warning but don't fail
+				if b.verbose {
+					b.logf("⚠️  Failed to cache %s: %v\n", url, err)
+				}
+			}
 		}
+
+		return contentStr, nil
 	}
 
+	if len(errs) == 1 {
+		return "", errs[0]
+	}
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return "", fmt.Errorf("failed to download %s from %d sources:\n  %s", url, len(candidates), strings.Join(messages, "\n  "))
+}
+
+// Prefetch downloads url into the cache as a build would, verified against
+// the lockfile, and returns its size and whether it came from the network
+// rather than the cache
+func (b *Bundler) Prefetch(url string) (int, bool, error) {
+	downloads := b.cacheStats.Downloads
+	content, err := b.download(url)
+	return len(content), b.cacheStats.Downloads > downloads, err
+}
+
+// fetchURL performs a single GET request and returns the response body and the
+// final URL after redirects
+func (b *Bundler) fetchURL(url string) (string, string, error) {
 	if b.verbose {
-		fmt.Printf("�📥 Downloading: %s\n", url)
+		b.logf("📥 Downloading: %s\n", url)
 	}
 
 	resp, err := b.httpClient.Get(url)
 	if err != nil {
-		return "", fmt.Errorf("failed to download %s: %w", url, err)
+		return "", "", fmt.Errorf("failed to download %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
+	finalURL := resp.Request.URL.String()
+	if finalURL != url {
+		if b.verbose {
+			b.logf("↪️  Redirected: %s -> %s\n", url, finalURL)
+		}
+		for _, warning := range redirectWarnings(redirectChain(resp)) {
+			b.warnf("%s", warning)
+		}
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
+		return "", "", fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
 	}
 
 	content, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response from %s: %w", url, err)
+		return "", "", fmt.Errorf("failed to read response from %s: %w", url, err)
 	}
 
-	contentStr := string(content)
+	if err := checkLuaContent(url, resp.Header.Get("Content-Type"), string(content)); err != nil {
+		return "", "", err
+	}
 
-	// Store in cache
-	if b.cache.IsEnabled() {
-		if err := b.cache.Set(url, contentStr); err != nil {
-			// Log warning but don't fail
-			if b.verbose {
-				fmt.Printf("⚠️  Failed to cache %s: %v\n", url, err)
-			}
+	return string(content), finalURL, nil
+}
+
+// externalPrefixes are first path segments naming Roblox services and
+// roots; requires starting with one stay runtime requires
+var externalPrefixes = []string{"game", "workspace", "ReplicatedStorage", "ServerStorage", "StarterGui", "StarterPack", "StarterPlayer", "Lighting", "SoundService", "TweenService", "HttpService", "RunService", "UserInputService", "Players", "Teams", "Debris", "CollectionService"}
+
+// externalPrefix returns the Roblox service or root modulePath starts
+// with, or "" when it starts with none
+func externalPrefix(modulePath string) string {
+	firstPart := strings.Split(modulePath, ".")[0]
+	for _, prefix := range externalPrefixes {
+		if firstPart == prefix {
+			return prefix
 		}
 	}
-
-	return contentStr, nil
+	return ""
 }
 
 // isLocalModule checks if a module path refers to a local file
@@ -71,13 +208,25 @@ func (b *Bundler) isLocalModule(modulePath string) bool {
 		return false
 	}
 
-	// Check for common external module prefixes (Roblox API, etc.)
-	externalPrefixes := []string{"game", "workspace", "ReplicatedStorage", "ServerStorage", "StarterGui", "StarterPack", "StarterPlayer", "Lighting", "SoundService", "TweenService", "HttpService", "RunService", "UserInputService", "Players", "Teams", "Debris", "CollectionService"}
-	firstPart := strings.Split(modulePath, ".")[0]
-	for _, prefix := range externalPrefixes {
-		if firstPart == prefix {
-			return false
+	if b.isTargetExternal(modulePath) {
+		return false
+	}
+
+	switch b.resolution {
+	case ResolveFilesystem:
+		return true
+	case ResolveLuaPackage:
+		if isPathRequire(modulePath) || strings.HasPrefix(modulePath, "/") {
+			return true
 		}
+		// Packages the project does not hold are left to package.path
+		_, err := b.fs.Stat(b.resolveModulePath(b.entryFile, modulePath))
+		return err == nil
+	}
+
+	// Check for common external module prefixes (Roblox API, etc.)
+	if externalPrefix(modulePath) != "" {
+		return false
 	}
 
 	return strings.HasPrefix(modulePath, ".") ||
@@ -102,8 +251,22 @@ func (b *Bundler) resolveModulePath(currentFile, modulePath string) string {
 		return resolvedPath
 	}
 
+	if b.resolution == ResolveFilesystem {
+		return b.resolveRelativePath(currentFile, modulePath)
+	}
+	if b.target == TargetLove2D && !strings.HasPrefix(modulePath, ".") && !strings.HasSuffix(modulePath, ".lua") {
+		return b.resolveLoveModule(modulePath)
+	}
+	if b.target == TargetOpenResty && strings.HasPrefix(modulePath, "resty.") {
+		return b.resolveLualib(modulePath)
+	}
+	if b.resolution == ResolveLuaPackage && !isPathRequire(modulePath) {
+		path, _ := b.resolvePackage(modulePath)
+		return path
+	}
+
 	// Handle dot-separated absolute paths (e.g., tasks.cook -> tasks/cook.lua from base)
-	if strings.Contains(modulePath, ".") && !strings.Contains(modulePath, "/") && !strings.Contains(modulePath, "::") {
+	if strings.Contains(modulePath, ".") && !strings.Contains(modulePath, "/") && !strings.Contains(modulePath, "::") && !strings.HasSuffix(modulePath, ".lua") {
 		// Convert dots to slashes: tasks.cook -> tasks/cook
 		pathWithSlashes := strings.ReplaceAll(modulePath, ".", "/")
 		resolvedPath := filepath.Join(b.baseDir, pathWithSlashes)
@@ -113,7 +276,12 @@ func (b *Bundler) resolveModulePath(currentFile, modulePath string) string {
 		return resolvedPath
 	}
 
-	// Handle relative paths
+	return b.resolveRelativePath(currentFile, modulePath)
+}
+
+// resolveRelativePath resolves modulePath as a file path from the directory
+// of currentFile
+func (b *Bundler) resolveRelativePath(currentFile, modulePath string) string {
 	currentDir := filepath.Dir(currentFile)
 	resolvedPath := filepath.Join(currentDir, modulePath)
 
@@ -128,18 +296,64 @@ func (b *Bundler) resolveModulePath(currentFile, modulePath string) string {
 	return resolvedPath
 }
 
-// processFile recursively processes a file and its dependencies
-func (b *Bundler) processFile(filePath string, content string) error {
-	// Regex patterns
-	// Support both quoted strings: require("path.to.file") and unquoted: require(path.to.file)
-	requireRegex := regexp.MustCompile(`require\s*\(\s*(?:['"]([^'"]+)['"]|([a-zA-Z_][a-zA-Z0-9_.]*))\s*\)`)
-	httpGetRegex := regexp.MustCompile(`loadstring\s*\(\s*game:HttpGet\s*\(\s*['"]([^'"]+)['"]\s*\)\s*\)\s*\(\s*\)`)
+// resolveRemoteModulePath resolves a require path against the URL of the remote
+// script containing it, using the same extension rules as local paths. Dots
+// become slashes when dots is set, as they do for local paths outside the
+// filesystem-only resolution mode.
+func resolveRemoteModulePath(currentURL, modulePath string, dots bool) string {
+	modulePath = strings.Trim(modulePath, "'\"")
+
+	if dots && strings.Contains(modulePath, ".") && !strings.Contains(modulePath, "/") && !strings.HasSuffix(modulePath, ".lua") {
+		modulePath = strings.ReplaceAll(modulePath, ".", "/")
+	}
+	modulePath = strings.TrimPrefix(modulePath, "/")
+	if !strings.HasSuffix(modulePath, ".lua") {
+		modulePath += ".lua"
+	}
+
+	base, err := neturl.Parse(currentURL)
+	if err != nil {
+		return modulePath
+	}
+	ref, err := neturl.Parse(modulePath)
+	if err != nil {
+		return modulePath
+	}
+	return base.ResolveReference(ref).String()
+}
+
+var (
+	// requireRegex matches quoted requires, require("path.to.file"), and
+	// unquoted ones, require(path.to.file)
+	requireRegex = regexp.MustCompile(`require\s*\(\s*(?:['"]([^'"]+)['"]|([a-zA-Z_][a-zA-Z0-9_.]*))\s*\)`)
+	httpGetRegex = regexp.MustCompile(`loadstring\s*\(\s*game:HttpGet\s*\(\s*['"]([^'"]+)['"]\s*\)\s*\)\s*\(\s*\)`)
+	// funcCallHttpGetRegex matches HttpGet inside function calls, such as
+	// queue_on_teleport("loadstring(...)")
+	funcCallHttpGetRegex = regexp.MustCompile(`\w+\s*\([^)]*loadstring\s*\(\s*game:HttpGet`)
+)
+
+// IsURL reports whether path is an http(s) URL rather than a local file
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// processFile recursively processes a file and its dependencies. key is the
+// file's node in the dependency graph and depth its remote loader depth.
+func (b *Bundler) processFile(key, filePath, content string, depth int) error {
+	b.checkGlobals(filePath, content)
+	b.collectRequireCalls(filePath, content)
+	if b.target == TargetComputerCraft {
+		if err := b.collectAPIs(content); err != nil {
+			return err
+		}
+	}
+
 	// Pattern to detect HttpGet inside function calls (should NOT be bundled)
-	funcCallHttpGetRegex := regexp.MustCompile(`\w+\s*\([^)]*loadstring\s*\(\s*game:HttpGet`)
+	httpGetRegex, funcCallHttpGetRegex := b.remoteLoaderPatterns()
 
 	lines := strings.Split(content, "\n")
 
-	for _, line := range lines {
+	for i, line := range lines {
 		// Skip if HttpGet is inside a function call (e.g., queue_on_teleport("loadstring(...)"))
 		if funcCallHttpGetRegex.MatchString(line) {
 			continue
@@ -149,13 +363,38 @@ func (b *Bundler) processFile(filePath string, content string) error {
 		if matches := httpGetRegex.FindStringSubmatch(line); len(matches) > 1 {
 			url := matches[1]
 
+			// Beyond --flatten-depth, leave the loader chain as a runtime fetch
+			if b.flattenDepth >= 0 && depth+1 > b.flattenDepth {
+				b.addDependency(key, Dependency{Key: url, Remote: true, Runtime: true, Depth: depth + 1})
+				if b.runtimeFetches == nil {
+					b.runtimeFetches = make(map[string]bool)
+				}
+				b.runtimeFetches[url] = true
+				if b.verbose {
+					b.logf("⏭️  Leaving runtime fetch (depth %d > %d): %s\n", depth+1, b.flattenDepth, url)
+				}
+				continue
+			}
+
+			b.addDependency(key, Dependency{Key: url, Remote: true, Depth: depth + 1})
+
+			if stubbed, err := b.stubModule(url, depth+1); stubbed || err != nil {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
 			// Skip if already processed
 			if _, exists := b.modules[url]; exists {
 				continue
 			}
 
 			// Download content from URL
-			httpContent, err := b.downloadHTTP(url)
+			httpContent, ok, err := b.downloadRemote(url)
+			if !ok {
+				continue
+			}
 			if err != nil {
 				return err
 			}
@@ -165,7 +404,7 @@ func (b *Bundler) processFile(filePath string, content string) error {
 			b.modules[url] = httpContent
 
 			// Process downloaded content (might have requires in it)
-			if err := b.processFile(url, httpContent); err != nil {
+			if err := b.processFile(url, url, httpContent, depth+1); err != nil {
 				return err
 			}
 		}
@@ -177,42 +416,168 @@ func (b *Bundler) processFile(filePath string, content string) error {
 			if modulePath == "" && len(matches) > 2 {
 				modulePath = matches[2]
 			}
+			pragma := requirePragma(lines, i)
+			decided := ""
+			if modulePath != "" && pragma == "" && !IsURL(filePath) {
+				decision, err := b.requireDecision(filePath, modulePath)
+				if err != nil {
+					return err
+				}
+				switch decision {
+				case "":
+				case DecisionExternal:
+					pragma = "external"
+				default:
+					pragma, decided = "local", decision
+				}
+			}
 
-			// Process local files (relative, absolute from base, or subdirectory)
-			if modulePath != "" && b.isLocalModule(modulePath) {
-				resolvedPath := b.resolveModulePath(filePath, modulePath)
-
-				// Skip if already processed
-				if _, exists := b.modules[modulePath]; exists {
+			// Requires inside remote scripts resolve relative to the script's
+			// URL and are embedded under it, so that scripts from different
+			// places requiring the same name get their own module
+			if modulePath != "" && IsURL(filePath) && b.isLocalRequire(modulePath, pragma) {
+				if stubbed, err := b.stubModule(modulePath, depth); stubbed || err != nil {
+					b.addDependency(key, Dependency{Key: modulePath, Remote: true, Depth: depth})
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				url := resolveRemoteModulePath(filePath, modulePath, b.resolution != ResolveFilesystem)
+				b.addDependency(key, Dependency{Key: url, Remote: true, Depth: depth})
+				b.recordRemoteRequire(filePath, modulePath, url)
+				if _, exists := b.modules[url]; exists {
 					continue
 				}
 
-				// Read local file
-				fileContent, err := os.ReadFile(resolvedPath)
+				httpContent, ok, err := b.downloadRemote(url)
+				if !ok {
+					continue
+				}
 				if err != nil {
-					return fmt.Errorf("failed to read file %s: %w", resolvedPath, err)
+					return err
 				}
 
-				moduleContent := string(fileContent)
+				// Mark as HTTP module (do not obfuscate)
+				b.httpModules[url] = true
+				b.modules[url] = httpContent
+				b.moduleSources[url] = url
 
-				// Obfuscate local module if obfuscation is enabled
-				if b.obfuscateLevel > 0 && b.obfuscator != nil {
-					moduleContent = b.obfuscator.Obfuscate(moduleContent)
+				if b.verbose {
+					b.logf("📄 Processed: %s (%s)\n", modulePath, url)
 				}
 
-				b.modules[modulePath] = moduleContent
-
-				if b.verbose {
-					fmt.Printf("📄 Processed: %s\n", modulePath)
+				if err := b.processFile(url, url, httpContent, depth); err != nil {
+					return err
 				}
+				continue
+			}
+
+			// Requires of Roblox instances and host modules stay runtime
+			// requires, as do those a pragma marks external
+			if modulePath != "" && !b.isLocalRequire(modulePath, pragma) {
+				b.recordExternal(modulePath, key)
+				continue
+			}
 
-				// Process file recursively
-				if err := b.processFile(resolvedPath, string(fileContent)); err != nil {
+			// Process local files (relative, absolute from base, or subdirectory)
+			if modulePath != "" {
+				resolvedPath := decided
+				if resolvedPath == "" {
+					resolvedPath = b.resolveVariant(modulePath, b.resolveModulePath(filePath, modulePath))
+				}
+				if err := b.embedLocalFile(key, modulePath, resolvedPath, depth); err != nil {
 					return err
 				}
 			}
 		}
 	}
 
+	if module, ok := b.modules[key]; ok && len(b.remoteRequires[filePath]) > 0 {
+		b.modules[key] = b.rewriteRemoteRequires(filePath, module)
+	}
+
+	if b.target == TargetGMod {
+		return b.processIncludes(key, filePath, content, depth)
+	}
 	return nil
 }
+
+// recordRemoteRequire records that the require of modulePath in the remote
+// script file resolved to url, the key the module is embedded under
+func (b *Bundler) recordRemoteRequire(file, modulePath, url string) {
+	if b.remoteRequires == nil {
+		b.remoteRequires = make(map[string]map[string]string)
+	}
+	if b.remoteRequires[file] == nil {
+		b.remoteRequires[file] = make(map[string]string)
+	}
+	b.remoteRequires[file][modulePath] = url
+}
+
+// requireKey returns the key a require of modulePath in file is embedded
+// under: the URL it resolved to inside a remote script, the path otherwise
+func (b *Bundler) requireKey(file, modulePath string) string {
+	if url, ok := b.remoteRequires[file][modulePath]; ok {
+		return url
+	}
+	return modulePath
+}
+
+// rewriteRemoteRequires points the requires of the remote script file, of
+// which content is the source, at the URLs their modules are embedded under
+func (b *Bundler) rewriteRemoteRequires(file, content string) string {
+	urls := b.remoteRequires[file]
+	if len(urls) == 0 {
+		return content
+	}
+	return requireRegex.ReplaceAllStringFunc(content, func(match string) string {
+		m := requireRegex.FindStringSubmatch(match)
+		modulePath := m[1]
+		if modulePath == "" {
+			modulePath = m[2]
+		}
+		if url, ok := urls[modulePath]; ok {
+			return fmt.Sprintf("require(\"%s\")", escapeString(url))
+		}
+		return match
+	})
+}
+
+// embedLocalFile embeds the local file at resolvedPath as the module
+// modulePath, which key depends on, and processes it recursively. Stubbed
+// and already embedded modules are left as they are.
+func (b *Bundler) embedLocalFile(key, modulePath, resolvedPath string, depth int) error {
+	b.addDependency(key, Dependency{Key: modulePath, Depth: depth})
+
+	if stubbed, err := b.stubModule(modulePath, depth); stubbed || err != nil {
+		return err
+	}
+
+	// Skip if already processed
+	if _, exists := b.modules[modulePath]; exists {
+		return nil
+	}
+
+	// Read local file, obfuscating it if obfuscation is enabled
+	fileContent, moduleContent, err := b.readModule(modulePath, resolvedPath)
+	if err != nil {
+		err = fmt.Errorf("failed to read file %s: %w", resolvedPath, err)
+		if errors.Is(err, fs.ErrNotExist) {
+			if suggestions := b.moduleSuggestions(resolvedPath); len(suggestions) > 0 {
+				return fmt.Errorf("%w\n  did you mean %s?", err, strings.Join(suggestions, " or "))
+			}
+		}
+		return err
+	}
+
+	b.moduleSources[modulePath] = resolvedPath
+	b.modules[modulePath] = moduleContent
+
+	if b.verbose {
+		b.logf("📄 Processed: %s\n", modulePath)
+	}
+
+	// Process file recursively
+	return b.processFile(modulePath, resolvedPath, fileContent, depth)
+}
@@ -1,8 +1,14 @@
 package bundler
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 
+	"github.com/constt/lua-bundler/internal/lockfile"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -59,7 +65,7 @@ func TestIsLocalModule(t *testing.T) {
 		{
 			name:       "roblox service",
 			modulePath: "ReplicatedStorage",
-			want:       true, // Current implementation treats this as local
+			want:       false, // Listed in externalPrefixes
 		},
 		{
 			name:       "dot-separated absolute path",
@@ -166,3 +172,307 @@ func TestResolveModulePath(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveVariant(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+	b.SetFS(fstest.MapFS{
+		"net.lua":        {Data: []byte("return 'generic'")},
+		"net.roblox.lua": {Data: []byte("return 'roblox'")},
+		"impl/net51.lua": {Data: []byte("return '5.1'")},
+	})
+	root := b.baseDir
+	generic := filepath.Join(root, "net.lua")
+
+	t.Run("suffix convention", func(t *testing.T) {
+		require.NoError(t, b.SetTarget(TargetRoblox))
+		assert.Equal(t, filepath.Join(root, "net.roblox.lua"), b.resolveVariant("net", generic))
+	})
+
+	t.Run("no variant for target", func(t *testing.T) {
+		require.NoError(t, b.SetTarget(TargetLua53))
+		assert.Equal(t, generic, b.resolveVariant("net", generic))
+	})
+
+	t.Run("config mapping", func(t *testing.T) {
+		require.NoError(t, b.SetTarget(TargetLua51))
+		b.SetVariants(map[string]map[string]string{
+			"net": {TargetLua51: "impl/net51.lua"},
+		})
+		assert.Equal(t, filepath.Join(root, "impl", "net51.lua"), b.resolveVariant("net", generic))
+	})
+}
+
+func TestSetTarget_Invalid(t *testing.T) {
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+
+	assert.Equal(t, TargetRoblox, b.GetTarget(), "default target should be roblox")
+	assert.Error(t, b.SetTarget("python"), "SetTarget should reject unknown targets")
+}
+
+func TestBundle_TargetVariant(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+	b.SetFS(fstest.MapFS{
+		"main.lua":      {Data: []byte(`local net = require("net")`)},
+		"net.lua":       {Data: []byte("return 'generic'")},
+		"net.lua51.lua": {Data: []byte("return 'lua51'")},
+	})
+	require.NoError(t, b.SetTarget(TargetLua51))
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err, "Bundle() should not fail")
+	assert.Contains(t, result, "return 'lua51'", "bundle should embed the lua51 variant")
+	assert.NotContains(t, result, "return 'generic'", "bundle should not embed the generic module")
+}
+
+func TestDownloadHTTP_Mirrors(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusNotFound)
+	}))
+	defer primary.Close()
+
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'tampered'"))
+	}))
+	defer tampered.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'lib'"))
+	}))
+	defer good.Close()
+
+	url := primary.URL + "/lib.lua"
+
+	t.Run("falls back to mirror", func(t *testing.T) {
+		b, err := NewBundler("test.lua", false, false)
+		require.NoError(t, err, "NewBundler should not fail")
+		b.SetMirrors(map[string][]string{url: {good.URL + "/lib.lua"}})
+
+		content, err := b.downloadHTTP(url)
+		require.NoError(t, err, "downloadHTTP should succeed via mirror")
+		assert.Equal(t, "return 'lib'", content)
+	})
+
+	t.Run("skips mirrors failing lockfile verification", func(t *testing.T) {
+		lock, err := lockfile.Load(filepath.Join(t.TempDir(), lockfile.FileName))
+		require.NoError(t, err)
+		lock.Set(url, lockfile.Entry{SHA256: lockfile.Hash("return 'lib'")})
+
+		b, err := NewBundler("test.lua", false, false)
+		require.NoError(t, err, "NewBundler should not fail")
+		b.SetLockfile(lock)
+		b.SetMirrors(map[string][]string{url: {tampered.URL + "/lib.lua", good.URL + "/lib.lua"}})
+
+		content, err := b.downloadHTTP(url)
+		require.NoError(t, err, "downloadHTTP should succeed via the verified mirror")
+		assert.Equal(t, "return 'lib'", content)
+	})
+
+	t.Run("all sources fail", func(t *testing.T) {
+		lock, err := lockfile.Load(filepath.Join(t.TempDir(), lockfile.FileName))
+		require.NoError(t, err)
+		lock.Set(url, lockfile.Entry{SHA256: lockfile.Hash("return 'lib'")})
+
+		b, err := NewBundler("test.lua", false, false)
+		require.NoError(t, err, "NewBundler should not fail")
+		b.SetLockfile(lock)
+		b.SetMirrors(map[string][]string{url: {tampered.URL + "/lib.lua"}})
+
+		_, err = b.downloadHTTP(url)
+		require.Error(t, err, "downloadHTTP should fail when no source verifies")
+		assert.Contains(t, err.Error(), "from 2 sources")
+		assert.Contains(t, err.Error(), "does not match lockfile hash")
+	})
+
+	t.Run("pins unlocked URLs", func(t *testing.T) {
+		lock, err := lockfile.Load(filepath.Join(t.TempDir(), lockfile.FileName))
+		require.NoError(t, err)
+
+		b, err := NewBundler("test.lua", false, false)
+		require.NoError(t, err, "NewBundler should not fail")
+		b.SetLockfile(lock)
+
+		goodURL := good.URL + "/lib.lua"
+		_, err = b.downloadHTTP(goodURL)
+		require.NoError(t, err)
+
+		entry, ok := lock.Get(goodURL)
+		require.True(t, ok, "downloaded URL should be pinned")
+		assert.Equal(t, lockfile.Hash("return 'lib'"), entry.SHA256)
+	})
+}
+
+func TestDownloadHTTP_CorruptCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("return 'lib'"))
+	}))
+	defer server.Close()
+	url := server.URL + "/lib.lua"
+
+	b, err := NewBundler("test.lua", false, true)
+	require.NoError(t, err)
+	_, err = b.downloadHTTP(url)
+	require.NoError(t, err)
+	_, err = b.downloadHTTP(url)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests, "the second download is served from the cache")
+
+	// Tamper with the cached copy
+	entries, err := os.ReadDir(b.cache.GetCacheDir())
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".lua" {
+			require.NoError(t, os.WriteFile(filepath.Join(b.cache.GetCacheDir(), entry.Name()), []byte("return 'evil'"), 0644))
+		}
+	}
+
+	content, err := b.downloadHTTP(url)
+	require.NoError(t, err)
+	assert.Equal(t, "return 'lib'", content)
+	assert.Equal(t, 2, requests)
+	require.Len(t, b.GetWarnings(), 1)
+	assert.Contains(t, b.GetWarnings()[0], "does not match its stored hash")
+}
+
+func TestDownloadHTTP_CacheStats(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'lib'"))
+	}))
+	defer server.Close()
+
+	b, err := NewBundler("test.lua", false, true)
+	require.NoError(t, err)
+	for _, path := range []string{"/a.lua", "/b.lua", "/a.lua", "/a.lua"} {
+		_, err = b.downloadHTTP(server.URL + path)
+		require.NoError(t, err)
+	}
+
+	// A lockfile expecting other content sends the cached copy back to the network
+	lock, err := lockfile.Load(filepath.Join(t.TempDir(), lockfile.FileName))
+	require.NoError(t, err)
+	lock.Set(server.URL+"/b.lua", lockfile.Entry{SHA256: lockfile.Hash("return 'other'")})
+	b.SetLockfile(lock)
+	_, err = b.downloadHTTP(server.URL + "/b.lua")
+	require.Error(t, err)
+
+	stats := b.CacheStats()
+	assert.Equal(t, 2, stats.Hits)
+	assert.Equal(t, 2, stats.Misses)
+	assert.Equal(t, 1, stats.Revalidations)
+	assert.Equal(t, 3, stats.Downloads)
+	assert.Equal(t, int64(2*len("return 'lib'")), stats.CacheBytes)
+
+	total, err := b.RecordCacheStats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, total.Builds)
+	assert.Equal(t, 2, total.Hits)
+}
+
+func TestPrefetch_Offline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("return 'lib'"))
+	}))
+	defer server.Close()
+	url := server.URL + "/lib.lua"
+
+	b, err := NewBundler("test.lua", false, true)
+	require.NoError(t, err)
+	size, fetched, err := b.Prefetch(url)
+	require.NoError(t, err)
+	assert.Equal(t, len("return 'lib'"), size)
+	assert.True(t, fetched)
+	_, fetched, err = b.Prefetch(url)
+	require.NoError(t, err)
+	assert.False(t, fetched, "cached dependencies are not downloaded again")
+
+	offline, err := NewBundler("test.lua", false, true)
+	require.NoError(t, err)
+	require.NoError(t, offline.SetHTTPOptions(HTTPOptions{Offline: true}))
+	content, err := offline.downloadHTTP(url)
+	require.NoError(t, err)
+	assert.Equal(t, "return 'lib'", content)
+	_, err = offline.downloadHTTP(server.URL + "/other.lua")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "run lua-bundler prefetch first")
+	assert.Equal(t, 1, requests, "offline builds never download")
+}
+
+func TestResolveRemoteModulePath(t *testing.T) {
+	tests := []struct {
+		modulePath string
+		want       string
+	}{
+		{"./util.lua", "https://example.com/scripts/util.lua"},
+		{"util", "https://example.com/scripts/util.lua"},
+		{"lib.net", "https://example.com/scripts/lib/net.lua"},
+		{"../shared/log", "https://example.com/shared/log.lua"},
+		{"/core.lua", "https://example.com/scripts/core.lua"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modulePath, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolveRemoteModulePath("https://example.com/scripts/main.lua", tt.modulePath, true))
+		})
+	}
+}
+
+func TestResolve_RemoteRequiresKeyedByURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a/main.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("local util = require(\"util\")\nreturn util\n"))
+	})
+	mux.HandleFunc("/a/util.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'a'\n"))
+	})
+	mux.HandleFunc("/b/main.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("local util = require(\"util\")\nreturn util\n"))
+	})
+	mux.HandleFunc("/b/util.lua", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("return 'b'\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(
+		"local a = loadstring(game:HttpGet(\""+server.URL+"/a/main.lua\"))()\n"+
+			"local b = loadstring(game:HttpGet(\""+server.URL+"/b/main.lua\"))()\n"+
+			"local util = require(\"util\")\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util.lua"), []byte("return 'local'\n"), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	output, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	modules := b.GetModules()
+	assert.Equal(t, "return 'a'\n", modules[server.URL+"/a/util.lua"])
+	assert.Equal(t, "return 'b'\n", modules[server.URL+"/b/util.lua"])
+	assert.Equal(t, "return 'local'\n", modules["util"])
+	assert.Contains(t, modules[server.URL+"/a/main.lua"], "require(\""+server.URL+"/a/util.lua\")")
+	assert.Contains(t, modules[server.URL+"/b/main.lua"], "require(\""+server.URL+"/b/util.lua\")")
+
+	assert.Contains(t, output, "loadModule(\""+server.URL+"/a/util.lua\")")
+	assert.Contains(t, output, "loadModule(\""+server.URL+"/b/util.lua\")")
+	assert.Contains(t, output, "local util = loadModule(\"util\")")
+
+	reported := false
+	for _, use := range b.RequireReport() {
+		if use.Module == "util" && use.File == server.URL+"/b/main.lua" {
+			reported = true
+			assert.Equal(t, RequireBundled, use.Status)
+			assert.Equal(t, server.URL+"/b/util.lua", use.Source)
+		}
+	}
+	assert.True(t, reported)
+}
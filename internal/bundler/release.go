@@ -0,0 +1,193 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// What release mode does with the function calls in the arguments of the
+// print and warn statements it removes
+const (
+	DebugCallsKeep  = "keep"  // keep the calls, dropping only the print or warn
+	DebugCallsStrip = "strip" // remove the statement whole, with a warning
+)
+
+// DebugCallsModes lists the supported debug call modes
+var DebugCallsModes = []string{DebugCallsKeep, DebugCallsStrip}
+
+// SetDebugCalls sets what release mode does with calls made by the
+// arguments of removed print and warn statements: print(save()) becomes
+// save() with keep, and is removed whole with strip. An empty mode selects
+// keep.
+func (b *Bundler) SetDebugCalls(mode string) error {
+	if mode == "" {
+		mode = DebugCallsKeep
+	}
+	if !containsString(DebugCallsModes, mode) {
+		return fmt.Errorf("invalid debug calls mode %q (expected one of: %s)", mode, strings.Join(DebugCallsModes, ", "))
+	}
+	b.debugCalls = mode
+	return nil
+}
+
+// keepDebugCalls reports whether removed debug statements leave their
+// argument calls behind
+func (b *Bundler) keepDebugCalls() bool {
+	return b.debugCalls != DebugCallsStrip
+}
+
+// Release mode warning rules
+const (
+	RuleReleaseSideEffect  = "release-side-effect"  // print(doWork()) stripped along with the call in it, with --debug-calls strip
+	RuleReleaseEmptyBranch = "release-empty-branch" // if debug then print(...) end left with an empty branch
+)
+
+// checkDebugRemoval warns about the print and warn statements release mode
+// is about to remove from content, parsed as chunk, when removing them
+// changes more than the output: calls made by their arguments, unless those
+// are kept, and if branches holding nothing else. locate names the file and
+// line of an offset in content. It returns how many statements content
+// should have once they are removed.
+func (b *Bundler) checkDebugRemoval(chunk *parser.Chunk, content string, locate func(offset int) string) int {
+	calls := debugStatementsIn(chunk, content, b.keepPatterns)
+	removed := make(map[parser.Span]bool, len(calls))
+	replaced := make(map[parser.Span]int)
+	for _, call := range calls {
+		if kept, _, ok := keptCalls(content, call); ok && b.keepDebugCalls() {
+			replaced[call.stmt] = countStatements(kept)
+			continue
+		}
+		removed[call.stmt] = true
+		if callee, ok := sideEffectCall(call.args); ok && !b.suppressed[RuleReleaseSideEffect] {
+			b.warnf("%s: %s(...) calls %s(); release mode removes that call with it (move the call out, tag the line --@keep, or use --debug-calls keep) [%s]",
+				locate(call.stmt.Start), call.fn.Name, content[callee.Start:callee.End], RuleReleaseSideEffect)
+		}
+	}
+
+	statements := 0
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if stmt, ok := n.(parser.Stmt); ok {
+			if removed[stmt.Range()] {
+				return false
+			}
+			if count, ok := replaced[stmt.Range()]; ok {
+				statements += count
+				return false
+			}
+			statements++
+		}
+		if stmt, ok := n.(*parser.IfStmt); ok && !b.suppressed[RuleReleaseEmptyBranch] {
+			for i, block := range stmt.Blocks {
+				branch := "then"
+				if i > 0 {
+					branch = "elseif"
+				}
+				b.checkEmptiedBranch(locate, block, branch, removed)
+			}
+			if stmt.Else != nil {
+				b.checkEmptiedBranch(locate, stmt.Else, "else", removed)
+			}
+		}
+		return true
+	})
+	return statements
+}
+
+// countStatements returns the number of statements in code, nested ones
+// included, or 0 when it does not parse
+func countStatements(code string) int {
+	chunk, err := parser.Parse(code)
+	if err != nil {
+		return 0
+	}
+	statements := 0
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if _, ok := n.(parser.Stmt); ok {
+			statements++
+		}
+		return true
+	})
+	return statements
+}
+
+// checkEmptiedBranch warns when every statement of an if branch is one
+// release mode removes
+func (b *Bundler) checkEmptiedBranch(locate func(offset int) string, block *parser.Block, branch string, removed map[parser.Span]bool) {
+	if len(block.Stmts) == 0 {
+		return
+	}
+	for _, stmt := range block.Stmts {
+		if !removed[stmt.Range()] {
+			return
+		}
+	}
+	b.warnf("%s: the %s branch only prints; release mode leaves it empty, so the if does nothing there [%s]",
+		locate(block.Stmts[0].Range().Start), branch, RuleReleaseEmptyBranch)
+}
+
+// checkReleaseOutput warns when the stripped and minified content, the
+// bundle or the module named by what, does not parse, or has a different
+// number of statements than checkDebugRemoval expected
+func (b *Bundler) checkReleaseOutput(what, content string, expected int) {
+	if _, err := parser.Parse(content); err != nil {
+		b.warnf("release mode: the stripped %s no longer parses (%v); build without --release to compare", what, err)
+		return
+	}
+	if statements := countStatements(content); statements != expected {
+		b.warnf("release mode: the %s has %d statements after stripping and minification, %d expected; it may behave differently from the dev build", what, statements, expected)
+	}
+}
+
+// moduleLocation returns a locate function for checkDebugRemoval naming
+// offsets in the content of the embedded module path as file:line
+func (b *Bundler) moduleLocation(path, content string) func(offset int) string {
+	source := b.displaySource(b.moduleSource(path))
+	return func(offset int) string {
+		return fmt.Sprintf("%s:%d", source, lineAt(content, offset))
+	}
+}
+
+// sideEffectCall returns the span of the function called by the first call
+// among args, outside function bodies, which run only when called
+func sideEffectCall(args []parser.Expr) (parser.Span, bool) {
+	var callee parser.Span
+	found := false
+	for _, arg := range args {
+		parser.Walk(arg, func(n parser.Node) bool {
+			if found {
+				return false
+			}
+			switch n := n.(type) {
+			case *parser.FunctionExpr:
+				return false
+			case *parser.CallExpr:
+				callee, found = n.Fn.Range(), true
+			case *parser.MethodCallExpr:
+				callee, found = parser.Span{Start: n.Recv.Range().Start, End: n.Name.End}, true
+			}
+			return !found
+		})
+	}
+	return callee, found
+}
+
+// bundleLocation names the module and line of an offset in the bundle as
+// file:line, or the bundle line when the source map does not cover it
+func (b *Bundler) bundleLocation(bundle string, offset int) string {
+	line := lineAt(bundle, offset)
+	entry := b.displaySource(b.entryFile)
+	for _, m := range b.sourceMap {
+		// Modules start below their header and function lines, the entry
+		// file below its header
+		first := m.StartLine + 2
+		if m.Module == entry {
+			first = m.StartLine + 1
+		}
+		if line >= first && line <= m.EndLine {
+			return fmt.Sprintf("%s:%d", m.Source, line-first+1)
+		}
+	}
+	return fmt.Sprintf("bundle line %d", line)
+}
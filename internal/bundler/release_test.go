@@ -0,0 +1,161 @@
+package bundler
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// releaseWarnings bundles main in release mode and returns its warnings
+func releaseWarnings(t *testing.T, main string, setup func(b *Bundler)) []string {
+	t.Helper()
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(main), 0644))
+	b, err := New(mainFile, WithLogger(io.Discard))
+	require.NoError(t, err)
+	if setup != nil {
+		setup(b)
+	}
+	_, err = b.Bundle(true)
+	require.NoError(t, err)
+	return b.GetWarnings()
+}
+
+// stripDebugCalls sets a bundler to remove debug statements with their calls
+func stripDebugCalls(t *testing.T) func(b *Bundler) {
+	return func(b *Bundler) {
+		require.NoError(t, b.SetDebugCalls(DebugCallsStrip))
+	}
+}
+
+func TestReleaseWarnings_SideEffects(t *testing.T) {
+	warnings := releaseWarnings(t, strings.Join([]string{
+		"local function doWork() return 1 end",
+		"print(doWork())",
+		"warn(\"count\", #queue:drain())",
+		"print(\"plain\", 1 + 2)",
+		"print(\"deferred\", function() return doWork() end)",
+	}, "\n")+"\n", stripDebugCalls(t))
+
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "main.lua:2: print(...) calls doWork(); release mode removes that call with it (move the call out, tag the line --@keep, or use --debug-calls keep) [release-side-effect]", warnings[0])
+	assert.Contains(t, warnings[1], "main.lua:3: warn(...) calls queue:drain()")
+}
+
+func TestReleaseWarnings_EmptyBranches(t *testing.T) {
+	warnings := releaseWarnings(t, strings.Join([]string{
+		"local verbose = false",
+		"if verbose then",
+		"    print(\"debugging\")",
+		"elseif os then",
+		"    verbose = true",
+		"else",
+		"    warn(\"no os\")",
+		"end",
+		"if verbose then print(\"a\") print(\"b\") verbose = false end",
+	}, "\n")+"\n", nil)
+
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "main.lua:3: the then branch only prints; release mode leaves it empty, so the if does nothing there [release-empty-branch]", warnings[0])
+	assert.Contains(t, warnings[1], "main.lua:7: the else branch")
+}
+
+func TestReleaseWarnings_Suppressed(t *testing.T) {
+	main := "if ready then print(status()) end\n"
+	assert.Len(t, releaseWarnings(t, main, stripDebugCalls(t)), 2)
+
+	warnings := releaseWarnings(t, main, func(b *Bundler) {
+		require.NoError(t, b.SetDebugCalls(DebugCallsStrip))
+		require.NoError(t, b.SuppressWarnings([]string{RuleReleaseSideEffect, RuleReleaseEmptyBranch}))
+	})
+	assert.Empty(t, warnings)
+}
+
+func TestReleaseWarnings_KeptStatements(t *testing.T) {
+	main := "if ready then print(status()) --@keep\nend\n"
+	assert.Empty(t, releaseWarnings(t, main, nil), "kept statements stay in the bundle")
+
+	warnings := releaseWarnings(t, "if ready then print(status()) end\n", func(b *Bundler) {
+		require.NoError(t, b.SetLogShim("info"))
+	})
+	assert.Empty(t, warnings, "the log shim keeps the calls")
+}
+
+func TestReleaseMode_KeepsDebugCalls(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte(strings.Join([]string{
+		"local n = 0",
+		"local function save() n = n + 1 return n end",
+		"if ready then print(save()) end",
+		"warn(\"saved\", save(), tostring(n))",
+		"print(\"plain\", n)",
+	}, "\n")+"\n"), 0644))
+
+	b, err := New(mainFile, WithLogger(io.Discard))
+	require.NoError(t, err)
+	require.NoError(t, b.SetMinifyLevel(MinifyNone))
+	output, err := b.Bundle(true)
+	require.NoError(t, err)
+
+	assert.Contains(t, output, "if ready then save() end")
+	assert.Contains(t, output, "do local _ = save(), tostring(n) end")
+	assert.NotContains(t, output, "print")
+	assert.NotContains(t, output, "warn(")
+	assert.Empty(t, b.GetWarnings(), "kept calls neither empty the branch nor change the statement count")
+
+	assert.Error(t, b.SetDebugCalls("drop"))
+}
+
+func TestReleaseWarnings_Loaders(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte("local worker = require(\"worker\")\nworker.run()\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "worker.lua"), []byte(strings.Join([]string{
+		"local M = {}",
+		"local function doWork() return 1 end",
+		"function M.run()",
+		"    print(doWork())",
+		"    if M.verbose then print(\"verbose\") end",
+		"end",
+		"return M",
+	}, "\n")+"\n"), 0644))
+
+	for _, loader := range Loaders {
+		t.Run(loader, func(t *testing.T) {
+			b, err := New(mainFile, WithLogger(io.Discard))
+			require.NoError(t, err)
+			require.NoError(t, b.SetLoader(loader))
+			require.NoError(t, b.SetDebugCalls(DebugCallsStrip))
+			_, err = b.Bundle(true)
+			require.NoError(t, err)
+
+			warnings := b.GetWarnings()
+			require.Len(t, warnings, 2, "every loader checks module bodies")
+			assert.True(t, strings.HasPrefix(warnings[0], "worker.lua:4: print(...) calls doWork()"), warnings[0])
+			assert.True(t, strings.HasPrefix(warnings[1], "worker.lua:5: the then branch only prints"), warnings[1])
+		})
+	}
+}
+
+func TestCheckReleaseOutput(t *testing.T) {
+	b, err := New("main.lua", WithLogger(io.Discard))
+	require.NoError(t, err)
+
+	b.checkReleaseOutput("bundle", "local a = 1\nreturn a\n", 2)
+	assert.Empty(t, b.GetWarnings())
+
+	b.checkReleaseOutput("bundle", "local a = 1\n", 2)
+	require.Len(t, b.GetWarnings(), 1)
+	assert.Contains(t, b.GetWarnings()[0], "the bundle has 1 statements after stripping and minification, 2 expected")
+
+	b.checkReleaseOutput("module utils", "local a = \n", 1)
+	require.Len(t, b.GetWarnings(), 2)
+	assert.Contains(t, b.GetWarnings()[1], "the stripped module utils no longer parses")
+}
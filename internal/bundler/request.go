@@ -0,0 +1,144 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// requestFunctions are the executor HTTP request functions the request shim
+// replaces; library fields are written library.field
+var requestFunctions = map[string]bool{
+	"syn.request":    true,
+	"http.request":   true,
+	"fluxus.request": true,
+	"http_request":   true,
+	"request":        true,
+}
+
+// requestLibraries are the executor libraries guarding request functions,
+// as in syn and syn.request
+var requestLibraries = map[string]bool{"syn": true, "http": true, "fluxus": true}
+
+// SetRequestShim rewrites calls of the executor request functions,
+// syn.request, http.request, http_request and the like, including the usual
+// syn and syn.request or http_request or request fallback chains, into calls
+// of one shim injected into the bundle, which picks whichever function the
+// running executor provides
+func (b *Bundler) SetRequestShim(enabled bool) {
+	b.requestShim = enabled
+}
+
+// requestShimName returns the local holding the request shim
+func (b *Bundler) requestShimName() string {
+	if b.namespace == "" {
+		return "BundleRequest"
+	}
+	return b.namespace + "_BundleRequest"
+}
+
+// shimRequests applies the request shim to the entry content and every
+// module, recording whether any code uses it
+func (b *Bundler) shimRequests(mainContent string) string {
+	name := b.requestShimName()
+	mainContent, b.requestShimUsed = shimRequestCalls(mainContent, name)
+	for key, content := range b.modules {
+		shimmed, used := shimRequestCalls(content, name)
+		if used {
+			b.modules[key] = shimmed
+			b.requestShimUsed = true
+		}
+	}
+	if b.verbose && b.requestShimUsed {
+		b.logf("🌐 Routed HTTP requests through the request shim\n")
+	}
+	return mainContent
+}
+
+// shimRequestCalls replaces the request functions and fallback chains
+// between them in src with name, leaving assignment targets alone. Content
+// the parser rejects is returned unchanged.
+func shimRequestCalls(src, name string) (string, bool) {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return src, false
+	}
+
+	targets := make(map[parser.Node]bool)
+	var spans []parser.Span
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case *parser.AssignStmt:
+			for _, t := range n.Targets {
+				targets[t] = true
+			}
+		case parser.Expr:
+			if !targets[n] && isRequestExpr(n) {
+				spans = append(spans, n.Range())
+				return false
+			}
+		}
+		return true
+	})
+	if len(spans) == 0 {
+		return src, false
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	var out strings.Builder
+	pos := 0
+	for _, span := range spans {
+		out.WriteString(src[pos:span.Start])
+		out.WriteString(name)
+		pos = span.End
+	}
+	out.WriteString(src[pos:])
+	return out.String(), true
+}
+
+// isRequestExpr reports whether e evaluates to a request function or nil:
+// a request function, lib and <request expr>, or an or chain of them
+func isRequestExpr(e parser.Expr) bool {
+	switch e := e.(type) {
+	case *parser.ParenExpr:
+		return isRequestExpr(e.X)
+	case *parser.Ident:
+		return e.Binding != nil && e.Binding.Global() && requestFunctions[e.Name]
+	case *parser.FieldExpr:
+		id, ok := e.X.(*parser.Ident)
+		return ok && id.Binding != nil && id.Binding.Global() && requestFunctions[id.Name+"."+e.Name.Value]
+	case *parser.BinaryExpr:
+		switch e.Op {
+		case "or":
+			return isRequestExpr(e.X) && isRequestExpr(e.Y)
+		case "and":
+			return isRequestGuard(e.X) && isRequestExpr(e.Y)
+		}
+	}
+	return false
+}
+
+// isRequestGuard reports whether e checks for a request library or function
+// before using it, as syn does in syn and syn.request
+func isRequestGuard(e parser.Expr) bool {
+	if p, ok := e.(*parser.ParenExpr); ok {
+		return isRequestGuard(p.X)
+	}
+	if id, ok := e.(*parser.Ident); ok && id.Binding != nil && id.Binding.Global() && requestLibraries[id.Name] {
+		return true
+	}
+	return isRequestExpr(e)
+}
+
+// writeRequestShim writes the request shim: the first request function the
+// executor provides, or a function raising an error when there is none
+func writeRequestShim(out *strings.Builder, name string) {
+	out.WriteString("-- Cross-executor HTTP request shim\n")
+	fmt.Fprintf(out, "local %s = (syn and syn.request) or (http and http.request) or http_request\n", name)
+	out.WriteString("    or (fluxus and fluxus.request) or request\n")
+	out.WriteString("    or function()\n")
+	out.WriteString("        error(\"HTTP requests are not supported by this executor\", 2)\n")
+	out.WriteString("    end\n\n")
+}
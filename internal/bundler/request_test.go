@@ -0,0 +1,77 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShimRequestCalls(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"fallback chain", "local req = syn and syn.request or http and http.request or http_request or request\n", "local req = BundleRequest\n"},
+		{"parenthesized chain", "local req = (syn and syn.request) or request\n", "local req = BundleRequest\n"},
+		{"direct call", "local res = syn.request({ Url = url })\n", "local res = BundleRequest({ Url = url })\n"},
+		{"table call", "request { Url = url }\n", "BundleRequest { Url = url }\n"},
+		{"chain with other fallback", "local req = http_request or error(\"no http\")\n", "local req = BundleRequest or error(\"no http\")\n"},
+		{"local shadowing", "local request = function() end\nrequest()\n", "local request = function() end\nrequest()\n"},
+		{"assignment target", "request = request or http_request\n", "request = BundleRequest\n"},
+		{"unrelated field", "local s = game:GetService(\"HttpService\")\nlocal r = ui.request\n", "local s = game:GetService(\"HttpService\")\nlocal r = ui.request\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, used := shimRequestCalls(tt.src, "BundleRequest")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.src != tt.want, used)
+		})
+	}
+}
+
+func TestRequestShim(t *testing.T) {
+	project := MemoryFS{
+		"main.lua": "local api = require(\"api\")\nprint(api.get(\"https://example.com\"))\n",
+		"api.lua":  "local req = syn and syn.request or request\nreturn { get = function(url) return req({ Url = url }).Body end }\n",
+	}
+
+	for _, loader := range []string{LoaderClosure, LoaderLazy} {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFileSystem(project)
+		require.NoError(t, b.SetLoader(loader))
+		b.SetRequestShim(true)
+
+		out, err := b.Bundle(false)
+		require.NoError(t, err)
+		assert.Contains(t, out, "-- Cross-executor HTTP request shim\nlocal BundleRequest = (syn and syn.request)", loader)
+		assert.Contains(t, out, "local req = BundleRequest\n", loader)
+		assert.NotContains(t, out, "syn and syn.request or request", loader)
+	}
+
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(project)
+	require.NoError(t, b.SetNamespace("hub"))
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "BundleRequest", "the shim should be opt-in")
+
+	b.SetRequestShim(true)
+	out, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, "local hub_BundleRequest =")
+}
+
+func TestRequestShim_Unused(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "print('no http')\n"})
+	b.SetRequestShim(true)
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "BundleRequest", "the shim should only be injected when used")
+}
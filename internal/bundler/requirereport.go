@@ -0,0 +1,167 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Statuses of a require in the require report
+const (
+	RequireBundled   = "bundled"   // the module is embedded
+	RequireExternal  = "external"  // the require is left to the runtime
+	RequireDynamic   = "dynamic"   // the argument is computed, so no module can be embedded
+	RequireDuplicate = "duplicate" // the module's file is also embedded under another require path
+)
+
+// RequireStatuses lists the statuses of the require report, in report order
+var RequireStatuses = []string{RequireBundled, RequireDuplicate, RequireExternal, RequireDynamic}
+
+// RequireUse is a require call of the bundled code and what the bundler did
+// with it
+type RequireUse struct {
+	Module string `json:"module,omitempty"` // the require path, "" for dynamic requires
+	File   string `json:"file"`             // the requiring file or URL
+	Line   int    `json:"line"`
+	Status string `json:"status"`           // one of RequireStatuses
+	Source string `json:"source,omitempty"` // the file or URL embedded, for bundled modules
+	Reason string `json:"reason,omitempty"` // why it is external, stubbed or duplicated
+}
+
+// requireCall is a require call of a processed file
+type requireCall struct {
+	file   string // the requiring file, as displaySource shows it
+	line   int
+	index  int    // position among the requires of the line
+	path   string // the module path, "" for a dynamic require
+	pragma string // the require pragma governing the line
+}
+
+// collectRequireCalls records the require calls of content, which filePath
+// holds, for the require report and strict mode
+func (b *Bundler) collectRequireCalls(filePath, content string) {
+	file := b.displaySource(filePath)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		pragma := requirePragma(lines, i)
+		for j, m := range requireRegex.FindAllStringSubmatch(line, -1) {
+			path := m[1]
+			if path == "" {
+				path = m[2]
+			}
+			b.requireCalls = append(b.requireCalls, requireCall{file: file, line: i + 1, index: j, path: path, pragma: pragma})
+		}
+	}
+
+	tokens, err := parser.Tokenize(content)
+	if err != nil {
+		b.requireScanErrors = append(b.requireScanErrors, fmt.Sprintf("%s: cannot check requires: %v", file, err))
+		return
+	}
+	for _, tok := range parser.FindDynamicRequires(tokens) {
+		b.requireCalls = append(b.requireCalls, requireCall{file: file, line: tok.Line, pragma: requirePragma(lines, tok.Line-1)})
+	}
+}
+
+// RequireReport returns every require call of the files the last Resolve
+// processed, by file in processing order and then by line, with what the
+// bundler did with each
+func (b *Bundler) RequireReport() []RequireUse {
+	// Sources are compared as displayed since relative and base directory
+	// resolution spell the same file differently
+	keysBySource := make(map[string][]string)
+	for key, source := range b.moduleSources {
+		keysBySource[b.displaySource(source)] = append(keysBySource[b.displaySource(source)], key)
+	}
+
+	uses := make([]RequireUse, 0, len(b.requireCalls))
+	for _, call := range b.requireCalls {
+		use := RequireUse{Module: call.path, File: call.file, Line: call.line}
+		key := b.requireKey(call.file, call.path)
+		_, embedded := b.modules[key]
+		switch {
+		case call.path == "":
+			use.Status = RequireDynamic
+			if call.pragma == "external" {
+				use.Reason = "marked " + PragmaExternal
+			}
+		case call.pragma != "external" && embedded:
+			use.Status = RequireBundled
+			source, ok := b.moduleSources[key]
+			switch {
+			case !ok:
+				use.Reason = "omitted"
+				if feature := b.disabledFeature(call.path); feature != "" {
+					use.Reason = fmt.Sprintf("omitted: feature %s is disabled", feature)
+				}
+			case b.stubs[call.path] != "":
+				use.Source = b.displaySource(source)
+				use.Reason = "stub"
+			default:
+				use.Source = b.displaySource(source)
+			}
+			if others := otherKeys(keysBySource[use.Source], key); ok && len(others) > 0 {
+				use.Status = RequireDuplicate
+				use.Reason = "also embedded as " + strings.Join(others, ", ")
+			}
+		default:
+			use.Status = RequireExternal
+			use.Reason = b.externalReason(call)
+		}
+		uses = append(uses, use)
+	}
+
+	// Dynamic requires are found in a second pass over each file
+	order := make(map[string]int)
+	for _, use := range uses {
+		if _, ok := order[use.File]; !ok {
+			order[use.File] = len(order)
+		}
+	}
+	sort.SliceStable(uses, func(i, j int) bool {
+		if uses[i].File != uses[j].File {
+			return order[uses[i].File] < order[uses[j].File]
+		}
+		return uses[i].Line < uses[j].Line
+	})
+	return uses
+}
+
+// externalReason explains why a require is left to the runtime
+func (b *Bundler) externalReason(call requireCall) string {
+	switch {
+	case call.pragma == "external":
+		return "marked " + PragmaExternal
+	case b.requireDecisions[call.path] == DecisionExternal:
+		return `decided "external" in the config`
+	case b.isTargetExternal(call.path):
+		return fmt.Sprintf("provided by the %s target", b.target)
+	case strings.Contains(call.path, "::"):
+		return "not a module path"
+	case b.isLocalRequire(call.path, call.pragma):
+		if call.index > 0 {
+			return "not followed: only the first require of a line is bundled"
+		}
+		return "not followed"
+	case b.resolution == ResolveLuaPackage:
+		return "no project file; left to package.path"
+	}
+	if prefix := externalPrefix(call.path); prefix != "" {
+		return "starts with the Roblox service " + prefix
+	}
+	return "left to the runtime"
+}
+
+// otherKeys returns keys without key, sorted
+func otherKeys(keys []string, key string) []string {
+	var others []string
+	for _, k := range keys {
+		if k != key {
+			others = append(others, k)
+		}
+	}
+	sort.Strings(others)
+	return others
+}
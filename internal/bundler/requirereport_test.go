@@ -0,0 +1,62 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireReport(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "local Remote = require(ReplicatedStorage.Remote)\n" +
+			"local util = require(\"util\")\n" +
+			"local log, c = require(\"utils.log\"), require(\"c\")\n" +
+			"local plugin = require(\"plugins.\" .. name)\n" +
+			"local analytics = require(\"analytics\")\n" +
+			"local helper = require(\"helper\") --@bundle-as-external\n",
+		"util.lua":      "local log = require(\"./utils/log\")\nreturn {}\n",
+		"utils/log.lua": "return {}\n",
+		"c.lua":         "return {}\n",
+	})
+	b.SetStubs(map[string]string{"analytics": ""})
+
+	_, err = b.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, []RequireUse{
+		{Module: "ReplicatedStorage.Remote", File: "main.lua", Line: 1, Status: RequireExternal, Reason: "starts with the Roblox service ReplicatedStorage"},
+		{Module: "util", File: "main.lua", Line: 2, Status: RequireBundled, Source: "util.lua"},
+		{Module: "utils.log", File: "main.lua", Line: 3, Status: RequireDuplicate, Source: "utils/log.lua", Reason: "also embedded as ./utils/log"},
+		{Module: "c", File: "main.lua", Line: 3, Status: RequireExternal, Reason: "not followed: only the first require of a line is bundled"},
+		{Module: "", File: "main.lua", Line: 4, Status: RequireDynamic},
+		{Module: "analytics", File: "main.lua", Line: 5, Status: RequireBundled, Reason: "omitted"},
+		{Module: "helper", File: "main.lua", Line: 6, Status: RequireExternal, Reason: "marked " + PragmaExternal},
+		{Module: "./utils/log", File: "util.lua", Line: 1, Status: RequireDuplicate, Source: "utils/log.lua", Reason: "also embedded as utils.log"},
+	}, b.RequireReport())
+}
+
+func TestRequireReport_ExternalReasons(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "local shell = require(\"shell\")\n" +
+		"local json = require(\"cjson\")\n" +
+		"local cfg = require(\"settings\")\n"})
+	require.NoError(t, b.SetTarget(TargetOpenComputers))
+	require.NoError(t, b.SetResolution(ResolveLuaPackage))
+	b.SetRequireDecisions(map[string]string{"settings": DecisionExternal})
+
+	_, err = b.Resolve()
+	require.NoError(t, err)
+	reasons := make(map[string]string)
+	for _, use := range b.RequireReport() {
+		assert.Equal(t, RequireExternal, use.Status, use.Module)
+		reasons[use.Module] = use.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"shell":    "provided by the opencomputers target",
+		"cjson":    "no project file; left to package.path",
+		"settings": `decided "external" in the config`,
+	}, reasons)
+}
@@ -0,0 +1,60 @@
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Resolution modes, deciding what a require string such as "a.b.c" names
+const (
+	ResolveRobloxDots = "roblox-dots"     // dots are path separators from the base directory; Roblox services stay external
+	ResolveLuaPackage = "lua-package"     // package.path lookup of ?.lua and ?/init.lua; names with no such file stay external
+	ResolveFilesystem = "filesystem-only" // the string is a file path from the requiring file; dots are kept
+)
+
+// ResolutionModes lists the supported resolution modes
+var ResolutionModes = []string{ResolveRobloxDots, ResolveLuaPackage, ResolveFilesystem}
+
+// SetResolution sets how require strings map to files. roblox-dots turns
+// every dot into a slash and keeps Roblox service paths external. lua-package
+// resolves dotted names the way package.path does, so requires of packages
+// the project does not hold, such as socket.http, stay runtime requires.
+// filesystem-only treats every require as a file path, so a.b.c reads
+// a.b.c.lua. An empty mode selects roblox-dots.
+func (b *Bundler) SetResolution(mode string) error {
+	if mode == "" {
+		mode = ResolveRobloxDots
+	}
+	if !containsString(ResolutionModes, mode) {
+		return fmt.Errorf("invalid resolution mode %q (expected one of: %s)", mode, strings.Join(ResolutionModes, ", "))
+	}
+	b.resolution = mode
+	return nil
+}
+
+// isPathRequire reports whether a require string is written as a file path
+// rather than a module name
+func isPathRequire(modulePath string) bool {
+	return strings.HasPrefix(modulePath, ".") ||
+		strings.Contains(modulePath, "/") ||
+		strings.HasSuffix(modulePath, ".lua")
+}
+
+// resolvePackage returns the file package.path would load name from,
+// ./?.lua then ./?/init.lua from the base directory, and whether it exists
+func (b *Bundler) resolvePackage(name string) (string, bool) {
+	candidates := b.packageCandidates(name)
+	for _, candidate := range candidates {
+		if _, err := b.fs.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return candidates[0], false
+}
+
+// packageCandidates returns the files package.path tries for name, in order
+func (b *Bundler) packageCandidates(name string) []string {
+	path := filepath.Join(b.baseDir, filepath.FromSlash(strings.ReplaceAll(name, ".", "/")))
+	return []string{path + ".lua", filepath.Join(path, "init.lua")}
+}
@@ -0,0 +1,92 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResolutionBundler(t *testing.T, mode string, files MemoryFS) *Bundler {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(files)
+	require.NoError(t, b.SetTarget(TargetLua51))
+	require.NoError(t, b.SetResolution(mode))
+	return b
+}
+
+func TestSetResolution(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	assert.Equal(t, ResolveRobloxDots, b.resolution)
+	require.NoError(t, b.SetResolution(ResolveLuaPackage))
+	require.NoError(t, b.SetResolution(""))
+	assert.Equal(t, ResolveRobloxDots, b.resolution, "an empty mode should select roblox-dots")
+	err = b.SetResolution("node")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "filesystem-only")
+}
+
+func TestBundle_ResolutionLuaPackage(t *testing.T) {
+	b := newResolutionBundler(t, ResolveLuaPackage, MemoryFS{
+		"main.lua":           "local http = require(\"socket.http\")\nlocal ui = require(\"game.ui\")\nlocal json = require(\"lib.json\")\n",
+		"game/ui/init.lua":   "return {}\n",
+		"lib/json.lua":       "return {}\n",
+		"socket/README.txt":  "not lua\n",
+		"unrelated/util.lua": "return {}\n",
+	})
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, `local ui = loadModule("game.ui")`, "game is not a Roblox service outside roblox-dots")
+	assert.Contains(t, bundle, `local json = loadModule("lib.json")`)
+	assert.Contains(t, bundle, `require("socket.http")`, "packages the project lacks should stay runtime requires")
+
+	var externals []string
+	for _, ext := range b.ExternalRequires() {
+		externals = append(externals, ext.Path)
+	}
+	assert.Equal(t, []string{"socket.http"}, externals)
+}
+
+func TestBundle_ResolutionFilesystemOnly(t *testing.T) {
+	b := newResolutionBundler(t, ResolveFilesystem, MemoryFS{
+		"main.lua":           "local cfg = require(\"config.default\")\nlocal h = require(\"src/helper\")\n",
+		"config.default.lua": "return { debug = false }\n",
+		"src/helper.lua":     "return require(\"util\")\n",
+		"src/util.lua":       "return {}\n",
+		"config/default.lua": "error(\"dots are not separators\")\n",
+	})
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, bundle, "debug = false")
+	assert.NotContains(t, bundle, "dots are not separators")
+	assert.Equal(t, "src/util.lua", b.moduleSources["util"], "requires should resolve from the requiring file")
+}
+
+func TestIsLocalModule_Resolution(t *testing.T) {
+	files := MemoryFS{"main.lua": "", "tasks/cook.lua": ""}
+	tests := []struct {
+		mode, module string
+		want         bool
+	}{
+		{ResolveRobloxDots, "ReplicatedStorage.Shared", false},
+		{ResolveRobloxDots, "socket.http", true},
+		{ResolveLuaPackage, "ReplicatedStorage.Shared", false},
+		{ResolveLuaPackage, "tasks.cook", true},
+		{ResolveLuaPackage, "socket.http", false},
+		{ResolveLuaPackage, "./anything", true},
+		{ResolveFilesystem, "ReplicatedStorage.Shared", true},
+		{ResolveFilesystem, "std::vector", false},
+	}
+	for _, tt := range tests {
+		b := newResolutionBundler(t, tt.mode, files)
+		assert.Equal(t, tt.want, b.isLocalModule(tt.module), "%s: %s", tt.mode, tt.module)
+	}
+}
+
+func TestResolveRemoteModulePath_KeepsDots(t *testing.T) {
+	assert.Equal(t, "https://example.com/scripts/config.default.lua", resolveRemoteModulePath("https://example.com/scripts/main.lua", "config.default", false))
+}
@@ -0,0 +1,54 @@
+package bundler
+
+import (
+	"time"
+
+	"github.com/constt/lua-bundler/internal/cache"
+)
+
+// BundleResult is a bundle and what went into building it
+type BundleResult struct {
+	Output   string                  `json:"output"`
+	BuildID  string                  `json:"buildId"`
+	Modules  []ManifestModule        `json:"modules"` // sorted by key, hashed as in the manifest
+	Warnings []string                `json:"warnings"`
+	Timings  Timings                 `json:"timings"`
+	Cache    cache.Stats             `json:"cache"`             // NetworkBytes counts the remote bytes fetched
+	Graph    map[string][]Dependency `json:"graph"`             // parent key -> dependencies
+	Inlined  []string                `json:"inlined,omitempty"` // modules inlined at their require sites
+}
+
+// Timings is the time spent in each step of a build, in nanoseconds
+type Timings struct {
+	Resolve     time.Duration `json:"resolve"`     // reading, downloading and rewriting modules
+	Generate    time.Duration `json:"generate"`    // writing the bundle
+	Postprocess time.Duration `json:"postprocess"` // release mode, minification and line endings
+	Total       time.Duration `json:"total"`
+}
+
+// BundleWithResult bundles like Bundle and returns the bundle with its
+// modules, warnings, timings, cache stats and dependency graph
+func (b *Bundler) BundleWithResult(releaseMode bool) (*BundleResult, error) {
+	var timings Timings
+	output, err := b.bundle(releaseMode, &timings)
+	if err != nil {
+		return nil, err
+	}
+	result := &BundleResult{
+		Output:   output,
+		BuildID:  b.buildID,
+		Modules:  b.manifestModules(),
+		Warnings: b.warnings,
+		Timings:  timings,
+		Cache:    b.cacheStats,
+		Graph:    b.graph,
+		Inlined:  b.GetInlinedModules(),
+	}
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+	if result.Graph == nil {
+		result.Graph = map[string][]Dependency{}
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package bundler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleWithResult(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(mainFile, []byte("local util = require(\"lib.util\")\nlocal log = require(\"lib.log\")\nprint(util, log)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "lib", "util.lua"), []byte("return {}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "lib", "log.lua"), []byte("return print"), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	result, err := b.BundleWithResult(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, result.Output, `EmbeddedModules["lib.util"]`)
+	assert.Equal(t, b.GetBuildID(), result.BuildID)
+	require.Len(t, result.Modules, 2)
+	assert.Equal(t, "lib.log", result.Modules[0].Key)
+	assert.Equal(t, "lib.util", result.Modules[1].Key)
+	assert.Len(t, result.Modules[1].SHA256, 64)
+	assert.Equal(t, b.Manifest("main", "", false).Modules, result.Modules, "modules are hashed as in the manifest")
+	assert.Equal(t, []string{}, result.Warnings)
+	assert.Zero(t, result.Cache.NetworkBytes)
+
+	deps := result.Graph[b.GetEntryKey()]
+	require.Len(t, deps, 2)
+	assert.Equal(t, "lib.util", deps[0].Key)
+
+	assert.Positive(t, result.Timings.Total)
+	assert.GreaterOrEqual(t, result.Timings.Total, result.Timings.Resolve+result.Timings.Generate+result.Timings.Postprocess)
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"graph":{`)
+	assert.Contains(t, string(data), `{"key":"lib.util","remote":false}`)
+
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Equal(t, result.Output, bundle, "Bundle returns the same output")
+}
+
+func TestBundleWithResult_Error(t *testing.T) {
+	tempDir := t.TempDir()
+	mainFile := filepath.Join(tempDir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte("local missing = require(\"missing\")\n"), 0644))
+
+	b, err := NewBundler(mainFile, false, false)
+	require.NoError(t, err)
+	result, err := b.BundleWithResult(false)
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,58 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetSafeWrap runs the bundle in pcall so an error reaches an error handler
+// instead of failing silently: by default a console message with the build
+// id and, on Roblox, a StarterGui notification. The entry file's results
+// are returned as usual when nothing fails.
+func (b *Bundler) SetSafeWrap(wrap bool) {
+	b.safeWrap = wrap
+}
+
+// SetErrorHandler replaces the default error handler of a safe-wrapped
+// bundle with a module, by require path resolved from the entry file,
+// returning a function called with the error message and the build id
+func (b *Bundler) SetErrorHandler(module string) {
+	b.errorHandler = module
+}
+
+// wrapSafely returns the lines opening and closing the pcall around the
+// prelude, main script and epilogue. run returns the expression loading
+// a module.
+func (b *Bundler) wrapSafely(run func(key string) string) (string, string) {
+	if !b.safeWrap {
+		return "", ""
+	}
+	var open strings.Builder
+	open.WriteString("-- Safe wrap: errors reach the error handler instead of failing silently\n")
+	open.WriteString("local function reportBundleError(message, buildID)\n")
+	switch {
+	case b.errorHandler != "":
+		open.WriteString(fmt.Sprintf("    local handler = %s\n", run(b.errorHandler)))
+		open.WriteString("    handler(message, buildID)\n")
+	case b.target == TargetRoblox:
+		open.WriteString("    warn(\"Script error (build \" .. buildID .. \"): \" .. tostring(message)) --@keep\n")
+		open.WriteString("    pcall(function()\n")
+		open.WriteString("        game:GetService(\"StarterGui\"):SetCore(\"SendNotification\", {\n")
+		open.WriteString("            Title = \"Script error\",\n")
+		open.WriteString("            Text = \"Something went wrong (build \" .. buildID .. \"). Details are in the console.\",\n")
+		open.WriteString("            Duration = 10,\n")
+		open.WriteString("        })\n")
+		open.WriteString("    end)\n")
+	default:
+		open.WriteString("    print(\"Script error (build \" .. buildID .. \"): \" .. tostring(message)) --@keep\n")
+	}
+	open.WriteString("end\n")
+	open.WriteString("return (function(ok, ...)\n")
+	open.WriteString("    if not ok then\n")
+	open.WriteString(fmt.Sprintf("        reportBundleError((...), %s)\n", quoteLua(b.buildID)))
+	open.WriteString("        return\n")
+	open.WriteString("    end\n")
+	open.WriteString("    return ...\n")
+	open.WriteString("end)(pcall(function(...)\n")
+	return open.String() + "\n", "end, ...))\n"
+}
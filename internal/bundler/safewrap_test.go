@@ -0,0 +1,73 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSafeWrapBundler(t *testing.T) *Bundler {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":        "local ui = require(\"ui\")\nreturn ui",
+		"ui.lua":          "print(\"loading\")\nreturn {}\n",
+		"errors/show.lua": "return function(message, buildID) end\n",
+	})
+	b.SetSafeWrap(true)
+	return b
+}
+
+func TestBundle_SafeWrap(t *testing.T) {
+	b := newSafeWrapBundler(t)
+	require.NoError(t, b.SetMinifyLevel(MinifyNone))
+
+	result, err := b.Bundle(true)
+	require.NoError(t, err)
+	assert.Contains(t, result, "local function reportBundleError(message, buildID)\n"+
+		"    warn(\"Script error (build \" .. buildID .. \"): \" .. tostring(message)) --@keep\n")
+	assert.Contains(t, result, "game:GetService(\"StarterGui\"):SetCore(\"SendNotification\", {")
+	assert.Contains(t, result, "        reportBundleError((...), \""+b.GetBuildID()+"\")\n")
+	assert.Contains(t, result, "end)(pcall(function(...)\n\n-- Main Script\nlocal ui = loadModule(\"ui\")\nreturn ui\nend, ...))\n")
+	assert.NotContains(t, result, "print(\"loading\")", "release mode still strips the modules' prints")
+	_, err = parser.Parse(result)
+	assert.NoError(t, err)
+}
+
+func TestBundle_SafeWrapOtherTargets(t *testing.T) {
+	b := newSafeWrapBundler(t)
+	require.NoError(t, b.SetTarget(TargetLove2D))
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "    print(\"Script error (build \" .. buildID .. \"): \" .. tostring(message)) --@keep\n")
+	assert.NotContains(t, result, "StarterGui")
+}
+
+func TestBundle_SafeWrapErrorHandler(t *testing.T) {
+	b := newSafeWrapBundler(t)
+	b.SetErrorHandler("errors.show")
+	b.SetHooks([]string{"ui"}, []string{"ui"})
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, result, "-- Module: errors.show\n")
+	assert.Contains(t, result, "    local handler = loadModule(\"errors.show\")\n    handler(message, buildID)\n")
+	assert.Contains(t, result, "end)(pcall(function(...)\n\n-- Prelude\n")
+	assert.Contains(t, result, "\nend)(...))\nend, ...))\n", "the epilogue runs inside the safe wrap")
+	_, err = parser.Parse(result)
+	assert.NoError(t, err)
+}
+
+func TestBundle_NoSafeWrapByDefault(t *testing.T) {
+	b := newSafeWrapBundler(t)
+	b.SetSafeWrap(false)
+	b.SetErrorHandler("errors.show")
+
+	result, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "reportBundleError")
+	assert.NotContains(t, result, "errors.show", "the handler is only embedded for safe-wrapped bundles")
+}
@@ -0,0 +1,134 @@
+package bundler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// shortNameChars are the characters short names are built from; names start
+// with a letter
+const shortNameChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
+
+// shortName returns the i-th short name: a..Z, then aa, ab, ...
+func shortName(i int) string {
+	const letters = 52
+	if i < letters {
+		return shortNameChars[i : i+1]
+	}
+	i -= letters
+	var suffix []byte
+	for {
+		suffix = append([]byte{shortNameChars[i%len(shortNameChars)]}, suffix...)
+		i /= len(shortNameChars)
+		if i < letters {
+			return shortNameChars[i:i+1] + string(suffix)
+		}
+		i -= letters
+	}
+}
+
+// shortenLocals renames local variables, parameters and loop variables to the
+// shortest names that keep every reference resolving to the same variable.
+// Two locals may share a name unless one is visible where the other is used.
+// Globals, fields and string contents are untouched, and names are assigned
+// in a fixed order so the output is deterministic.
+func shortenLocals(src string) (string, error) {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return "", err
+	}
+
+	// Names a new name must never take: globals used anywhere in the chunk,
+	// implicit self and _ENV, which are never renamed
+	reserved := map[string]bool{"self": true, "_ENV": true}
+	var locals []*parser.Binding
+	for _, b := range chunk.Bindings {
+		switch {
+		case b.Global():
+			reserved[b.Name] = true
+		case b.Decl != nil && b.Name != "_ENV":
+			locals = append(locals, b)
+		}
+	}
+
+	// Locals visible at an occurrence of another local conflict with it
+	conflicts := make(map[*parser.Binding]map[*parser.Binding]bool)
+	conflict := func(a, b *parser.Binding) {
+		if conflicts[a] == nil {
+			conflicts[a] = make(map[*parser.Binding]bool)
+		}
+		conflicts[a][b] = true
+	}
+	for _, b := range chunk.Bindings {
+		if b.Global() {
+			continue
+		}
+		for _, id := range b.Idents {
+			for scope := id.Scope; scope != nil; scope = scope.Parent {
+				for _, other := range scope.Bindings {
+					if other != b && other.Seq >= 0 && other.Seq < id.Seq {
+						conflict(b, other)
+						conflict(other, b)
+					}
+				}
+			}
+		}
+	}
+
+	// Most used locals get the shortest names
+	sort.SliceStable(locals, func(i, j int) bool { return len(locals[i].Idents) > len(locals[j].Idents) })
+
+	names := make(map[*parser.Binding]string)
+	for _, b := range chunk.Bindings {
+		if !b.Global() && (b.Decl == nil || b.Name == "_ENV") {
+			names[b] = b.Name
+		}
+	}
+	for _, b := range locals {
+		taken := make(map[string]bool)
+		for other := range conflicts[b] {
+			if name, ok := names[other]; ok {
+				taken[name] = true
+			}
+		}
+		name := ""
+		for i := 0; ; i++ {
+			name = shortName(i)
+			if !taken[name] && !reserved[name] && !parser.IsKeyword(name) {
+				break
+			}
+		}
+		// Keep names that are already as short, unless a neighbour took them
+		if len(name) >= len(b.Name) && !taken[b.Name] {
+			name = b.Name
+		}
+		names[b] = name
+	}
+
+	type edit struct {
+		span parser.Span
+		name string
+	}
+	var edits []edit
+	for _, b := range locals {
+		if names[b] == b.Name {
+			continue
+		}
+		for _, id := range b.Idents {
+			edits = append(edits, edit{id.Span, names[b]})
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].span.Start < edits[j].span.Start })
+
+	var out strings.Builder
+	pos := 0
+	for _, e := range edits {
+		out.WriteString(src[pos:e.span.Start])
+		out.WriteString(e.name)
+		pos = e.span.End
+	}
+	out.WriteString(src[pos:])
+	return out.String(), nil
+}
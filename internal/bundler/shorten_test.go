@@ -0,0 +1,171 @@
+package bundler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortName(t *testing.T) {
+	assert.Equal(t, "a", shortName(0))
+	assert.Equal(t, "Z", shortName(51))
+	assert.Equal(t, "aa", shortName(52))
+	assert.Equal(t, "ab", shortName(53))
+	assert.Equal(t, "ba", shortName(52+63))
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		name := shortName(i)
+		assert.False(t, seen[name], "duplicate name %s at %d", name, i)
+		seen[name] = true
+	}
+}
+
+// resolution describes which variable each identifier refers to: globals by
+// name and locals by the index of their first occurrence
+func resolution(t *testing.T, src string) []string {
+	chunk, err := parser.Parse(src)
+	require.NoError(t, err)
+
+	var idents []*parser.Ident
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if id, ok := n.(*parser.Ident); ok {
+			idents = append(idents, id)
+		}
+		return true
+	})
+
+	first := make(map[*parser.Binding]int)
+	var out []string
+	for i, id := range idents {
+		if id.Binding.Global() {
+			out = append(out, "global "+id.Name)
+			continue
+		}
+		if _, ok := first[id.Binding]; !ok {
+			first[id.Binding] = i
+		}
+		out = append(out, fmt.Sprintf("local %d", first[id.Binding]))
+	}
+	return out
+}
+
+func TestShortenLocals(t *testing.T) {
+	src := `local counter = 0
+local function increment(amount)
+    counter = counter + amount
+    return counter
+end
+print(increment(2), undefinedGlobal)`
+
+	result, err := shortenLocals(src)
+	require.NoError(t, err)
+	assert.Equal(t, `local a = 0
+local function b(c)
+    a = a + c
+    return a
+end
+print(b(2), undefinedGlobal)`, result)
+	assert.Equal(t, resolution(t, src), resolution(t, result))
+}
+
+func TestShortenLocals_Scoping(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"shadowing", `local value = 1
+do
+    local value = 2
+    print(value)
+end
+print(value)`},
+		{"capture", `local outer = 1
+local function f()
+    local inner = 2
+    return outer + inner
+end
+return f`},
+		{"globals are never taken", `local first = a
+local second = b
+local third = c
+return first, second, third, a, b, c`},
+		{"methods keep self", `local Class = {}
+function Class:method(arg)
+    local other = arg
+    return self, other
+end
+return Class`},
+		{"loops and repeat", `local total = 0
+for index = 1, 10 do total = total + index end
+for key, value in pairs(t) do total = total + value end
+repeat local done = true until done
+return total`},
+		{"fields and strings untouched", `local name = "name"
+local t = { name = name }
+t.name = name
+return t.name, "name"`},
+		{"same-line reuse", `local function f(x) return x end
+local function g(y) return y end
+return f, g`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := shortenLocals(tt.src)
+			require.NoError(t, err)
+			assert.Equal(t, resolution(t, tt.src), resolution(t, result), result)
+		})
+	}
+}
+
+func TestShortenLocals_ReusesNames(t *testing.T) {
+	result, err := shortenLocals(`local function first(alpha) return alpha end
+local function second(beta) return beta end
+return first, second`)
+	require.NoError(t, err)
+	assert.Equal(t, `local function a(b) return b end
+local function b(c) return c end
+return a, b`, result, "alpha is never visible where second is used, so they share a name")
+}
+
+func TestShortenLocals_KeepsShortNames(t *testing.T) {
+	result, err := shortenLocals("local x = 1\nreturn x")
+	require.NoError(t, err)
+	assert.Equal(t, "local x = 1\nreturn x", result)
+}
+
+func TestShortenLocals_Deterministic(t *testing.T) {
+	src := `local alpha, beta, gamma = 1, 2, 3
+local function delta(epsilon) return epsilon + alpha + beta + gamma end
+return delta`
+	first, err := shortenLocals(src)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		again, err := shortenLocals(src)
+		require.NoError(t, err)
+		assert.Equal(t, first, again)
+	}
+}
+
+func TestBundle_ShortenNames(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(entry, []byte("local message = require(\"helper\")\nreturn message\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.lua"), []byte("local greeting = \"hello\"\nreturn greeting\n"), 0644))
+
+	b, err := NewBundler(entry, false, false)
+	require.NoError(t, err)
+	require.NoError(t, b.SetMinifyLevel(MinifyNames))
+
+	result, err := b.Bundle(true)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "greeting")
+	assert.NotContains(t, result, "EmbeddedModules")
+	assert.Contains(t, result, `["helper"]`)
+	assert.Contains(t, result, `"hello"`)
+}
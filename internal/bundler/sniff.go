@@ -0,0 +1,86 @@
+package bundler
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// sniffLength is how much of a response is inspected for markup
+const sniffLength = 512
+
+// markupPrefixes are document starts that can never begin a Lua chunk
+var markupPrefixes = []string{"<!doctype", "<html", "<head", "<body", "<?xml", "<!--"}
+
+// nonLuaTypes are media types, or prefixes of them, that error and
+// rate-limit responses come with and Lua files do not
+var nonLuaTypes = []string{"application/json", "application/problem+json", "text/html", "application/xhtml+xml",
+	"application/xml", "text/xml", "image/", "audio/", "video/", "application/pdf"}
+
+// checkLuaContent rejects downloaded content that is clearly not Lua, such as
+// HTML interstitials, captcha walls, JSON errors or rate-limit pages served
+// with status 200. Content that does not parse as Lua is rejected too; the
+// content type only tells why, since hosts serve Lua as text/html as well.
+func checkLuaContent(url, contentType, content string) error {
+	source := strings.TrimPrefix(content, "\uFEFF")
+	head := strings.TrimSpace(source)
+	if len(head) > sniffLength {
+		head = head[:sniffLength]
+	}
+	lower := strings.ToLower(head)
+
+	for _, prefix := range markupPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return htmlContentError(url, contentType)
+		}
+	}
+
+	// "<" is not a valid way to start a Lua chunk; treat any leading tag as markup
+	if strings.HasPrefix(lower, "<") && len(lower) > 1 && (lower[1] >= 'a' && lower[1] <= 'z') {
+		return htmlContentError(url, contentType)
+	}
+
+	// A first line starting with # is skipped by Lua, as for a shebang
+	if strings.HasPrefix(source, "#") {
+		if i := strings.IndexByte(source, '\n'); i >= 0 {
+			source = source[i:]
+		} else {
+			source = ""
+		}
+	}
+	if _, err := parser.Parse(source); err != nil {
+		if nonLuaType(contentType) {
+			return fmt.Errorf("content from %s is %s, not Lua; the host may be serving an error or rate-limit response", url, contentType)
+		}
+		return fmt.Errorf("content from %s does not parse as Lua (%v); the host may be serving an error or rate-limit page", url, err)
+	}
+	return nil
+}
+
+// nonLuaType reports whether a Content-Type header names a format that is
+// not Lua source
+func nonLuaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	for _, t := range nonLuaTypes {
+		if mediaType == t || strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func htmlContentError(url, contentType string) error {
+	if contentType == "" {
+		contentType = "unknown"
+	}
+	return fmt.Errorf("content from %s looks like an HTML page, not Lua (content-type: %s); "+
+		"the host may be serving an error, captcha or rate-limit page", url, contentType)
+}
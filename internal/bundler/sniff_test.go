@@ -0,0 +1,129 @@
+package bundler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckLuaContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		content     string
+		wantErr     bool
+	}{
+		{
+			name:        "plain lua",
+			contentType: "text/plain; charset=utf-8",
+			content:     "local M = {}\nreturn M",
+		},
+		{
+			name:        "lua served as text/html",
+			contentType: "text/html",
+			content:     "-- <html> in a comment\nreturn {}",
+		},
+		{
+			name:    "lua with BOM",
+			content: "\uFEFFreturn 1",
+		},
+		{
+			name:        "html document",
+			contentType: "text/html; charset=utf-8",
+			content:     "<!DOCTYPE html>\n<html><body>Rate limited</body></html>",
+			wantErr:     true,
+		},
+		{
+			name:    "html without content type",
+			content: "  \n<html><head><title>Just a moment...</title></head></html>",
+			wantErr: true,
+		},
+		{
+			name:    "bare tag",
+			content: "<div>Paste removed</div>",
+			wantErr: true,
+		},
+		{
+			name:    "lua with shebang",
+			content: "#!/usr/bin/env lua\nprint(1)\n",
+		},
+		{
+			name:        "json error",
+			contentType: "application/json",
+			content:     `{"error": "rate limited", "retry_after": 60}`,
+			wantErr:     true,
+		},
+		{
+			name:        "json array",
+			contentType: "text/plain",
+			content:     `[{"message": "Not Found"}]`,
+			wantErr:     true,
+		},
+		{
+			name:        "plain text rate limit",
+			contentType: "text/plain; charset=utf-8",
+			content:     "429: Too Many Requests. Try again in a minute.",
+			wantErr:     true,
+		},
+		{
+			name:        "html body after whitespace with text/html",
+			contentType: "text/html",
+			content:     "\n\n  <!doctype html><p>captcha</p>",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLuaContent("https://example.com/lib.lua", tt.contentType, tt.content)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "https://example.com/lib.lua", "error should name the URL")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDownloadHTTP_RejectsHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<!DOCTYPE html><html><body>Too many requests</body></html>"))
+	}))
+	defer server.Close()
+
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+
+	_, err = b.downloadHTTP(server.URL + "/lib.lua")
+	require.Error(t, err, "downloadHTTP should reject HTML responses")
+	assert.Contains(t, err.Error(), "looks like an HTML page")
+}
+
+func TestDownloadHTTP_RejectsNonLua(t *testing.T) {
+	bodies := map[string][2]string{
+		"/json.lua": {"application/json", `{"message": "API rate limit exceeded"}`},
+		"/text.lua": {"text/plain", "Too many requests, slow down"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := bodies[r.URL.Path]
+		w.Header().Set("Content-Type", body[0])
+		w.Write([]byte(body[1]))
+	}))
+	defer server.Close()
+
+	b, err := NewBundler("test.lua", false, false)
+	require.NoError(t, err, "NewBundler should not fail")
+
+	_, err = b.downloadHTTP(server.URL + "/json.lua")
+	require.Error(t, err, "downloadHTTP should reject JSON responses")
+	assert.Contains(t, err.Error(), "is application/json, not Lua")
+
+	_, err = b.downloadHTTP(server.URL + "/text.lua")
+	require.Error(t, err, "downloadHTTP should reject text that is not Lua")
+	assert.Contains(t, err.Error(), "does not parse as Lua")
+}
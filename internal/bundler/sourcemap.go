@@ -0,0 +1,55 @@
+package bundler
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// SourceMapping records the lines a module occupies in the generated bundle
+type SourceMapping struct {
+	Module    string `json:"module"`
+	Source    string `json:"source"`
+	StartLine int    `json:"startLine"` // the "-- Module:" header line
+	EndLine   int    `json:"endLine"`   // the closing "end" of the module function
+}
+
+// SourceMap returns the module line ranges of the last bundle, with the entry
+// file last. It is empty after a release build, which joins everything into
+// one line.
+func (b *Bundler) SourceMap() []SourceMapping {
+	return b.sourceMap
+}
+
+// SourceMapJSON encodes the source map of the last bundle for the named bundle file
+func (b *Bundler) SourceMapJSON(bundleName string) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Version  int             `json:"version"`
+		Bundle   string          `json:"bundle"`
+		Mappings []SourceMapping `json:"mappings"`
+	}{1, bundleName, b.sourceMap}, "", "  ")
+}
+
+// moduleSource returns the file or URL a module was loaded from
+func (b *Bundler) moduleSource(key string) string {
+	if source, ok := b.moduleSources[key]; ok {
+		return source
+	}
+	return key
+}
+
+// GetModuleSource returns the file or URL a module key was loaded from
+func (b *Bundler) GetModuleSource(key string) string {
+	return b.moduleSource(key)
+}
+
+// displaySource returns a module's source relative to the project directory,
+// so published files do not leak local paths; URLs are returned unchanged
+func (b *Bundler) displaySource(source string) string {
+	if IsURL(source) {
+		return source
+	}
+	if rel, err := filepath.Rel(b.baseDir, source); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return source
+}
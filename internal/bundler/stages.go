@@ -0,0 +1,87 @@
+package bundler
+
+import "fmt"
+
+// Stages a local module goes through in a release build, in order
+const (
+	StageRaw        = "raw"        // as read, transcoded to UTF-8 with \n line endings
+	StageObfuscated = "obfuscated" // after the module's obfuscation passes, as embedded
+	StageStripped   = "stripped"   // comments and print/warn statements removed as release mode does
+	StageOptimized  = "optimized"  // local names shortened, at minify level 2 and up
+	StageMinified   = "minified"   // whitespace removed, numbers and semicolons shortened at level 3
+)
+
+// Stages lists the module stages in the order they are applied
+var Stages = []string{StageRaw, StageObfuscated, StageStripped, StageOptimized, StageMinified}
+
+// ModuleStage is a local module as it stands after one stage of a build
+type ModuleStage struct {
+	Stage   string `json:"stage"`
+	Content string `json:"content"`
+}
+
+// ModuleStages runs the local file source, embedded as key, through each
+// stage of a release build on its own, with the bundler's obfuscation,
+// release mode and minification settings. A build applies the last three
+// stages to the whole bundle, so their output is what the module becomes
+// there, apart from its requires being rewritten. A minify level of 0
+// leaves the module as stripped.
+func (b *Bundler) ModuleStages(key, source string) ([]ModuleStage, error) {
+	content, err := b.readSource(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", source, err)
+	}
+	stages := make([]ModuleStage, 0, len(Stages))
+	add := func(stage, content string) {
+		stages = append(stages, ModuleStage{Stage: stage, Content: content})
+	}
+	add(StageRaw, content)
+
+	content = b.obfuscate(key, source, content)
+	add(StageObfuscated, content)
+
+	content = b.stripDebugStatements(content)
+	if !b.preserveLines {
+		content = removeComments(content)
+	}
+	add(StageStripped, content)
+
+	level := b.effectiveMinifyLevel(true)
+	if level >= MinifyNames {
+		if shortened, err := shortenLocals(content); err != nil {
+			b.warnf("minify: local names were not shortened (%v)", err)
+		} else {
+			content = shortened
+		}
+	}
+	add(StageOptimized, content)
+
+	if level > MinifyNone {
+		content = minifyCode(content, minifyOptions{
+			aggressive:    level >= MinifyAggressive,
+			doubles:       b.doubleNumbers(),
+			preserveLines: b.preserveLines,
+		})
+	}
+	add(StageMinified, content)
+	return stages, nil
+}
+
+// stripDebugStatements handles the print and warn statements of content as
+// release mode does: routed through the logging shim when it is enabled,
+// removed otherwise
+func (b *Bundler) stripDebugStatements(content string) string {
+	if b.logLevel != "" {
+		logger, _ := b.loggerNames()
+		return shimDebugStatements(content, b.keepPatterns, logger)
+	}
+	if b.preserveLines {
+		return blankDebugStatements(content, b.keepPatterns, b.keepDebugCalls())
+	}
+	return removeDebugStatements(content, b.keepPatterns, b.keepDebugCalls())
+}
+
+// IsStage reports whether name is one of Stages
+func IsStage(name string) bool {
+	return containsString(Stages, name)
+}
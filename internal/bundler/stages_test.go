@@ -0,0 +1,53 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleStages(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "cook.lua")
+	source := "-- Cooking task\r\nlocal function cook(recipe)\r\n    print(\"cooking\", recipe)\r\n    return recipe\r\nend\r\n\r\nreturn cook\r\n"
+	require.NoError(t, os.WriteFile(file, []byte(source), 0644))
+
+	b, err := New(filepath.Join(tempDir, "main.lua"))
+	require.NoError(t, err)
+	require.NoError(t, b.SetMinifyLevel(MinifyNames))
+	stages, err := b.ModuleStages("cook", file)
+	require.NoError(t, err)
+	require.Len(t, stages, len(Stages))
+	for i, stage := range stages {
+		assert.Equal(t, Stages[i], stage.Stage)
+	}
+
+	raw := stages[0].Content
+	assert.NotContains(t, raw, "\r", "raw is the source as the bundler reads it")
+	assert.Equal(t, raw, stages[1].Content, "no obfuscation is configured")
+	assert.NotContains(t, stages[2].Content, "Cooking task")
+	assert.NotContains(t, stages[2].Content, "print(")
+	assert.NotContains(t, stages[3].Content, "recipe", "local names are shortened")
+	assert.NotContains(t, stages[4].Content, "\n")
+}
+
+func TestModuleStages_Obfuscation(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "cook.lua")
+	require.NoError(t, os.WriteFile(file, []byte("local secret = \"sauce\"\nreturn secret\n"), 0644))
+
+	b, err := New(filepath.Join(tempDir, "main.lua"))
+	require.NoError(t, err)
+	b.SetObfuscationLevel(2)
+	require.NoError(t, b.SetMinifyLevel(MinifyNone))
+	stages, err := b.ModuleStages("cook", file)
+	require.NoError(t, err)
+	assert.NotEqual(t, stages[0].Content, stages[1].Content)
+	assert.Equal(t, stages[3].Content, stages[4].Content, "minify level 0 leaves the module as stripped")
+
+	_, err = b.ModuleStages("missing", filepath.Join(tempDir, "missing.lua"))
+	assert.ErrorContains(t, err, "failed to read file")
+}
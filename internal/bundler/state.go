@@ -0,0 +1,282 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Shared state warning rules
+const (
+	RuleStateConnection = "state-connection" // getgenv().conn = signal:Connect(...) never disconnected
+	RuleStateAccumulate = "state-accumulate" // table.insert(shared.list, ...), _G.n = _G.n + 1
+	RuleStateGlobal     = "state-global"     // _G.flag = 1 with print(flag), which --state-key cannot isolate
+)
+
+// stateEnv returns how e names an environment shared between executions,
+// getgenv(), shared or _G, or "" when it names none
+func stateEnv(e parser.Expr) string {
+	switch e := e.(type) {
+	case *parser.CallExpr:
+		if isGlobal(e.Fn, "getgenv") && len(e.Args) == 0 {
+			return "getgenv()"
+		}
+	case *parser.Ident:
+		if isGlobal(e, "shared") || isGlobal(e, "_G") {
+			return e.Name
+		}
+	}
+	return ""
+}
+
+// stateField returns the shared environment expression and key e accesses,
+// as in getgenv().Key or shared["Key"]
+func stateField(e parser.Expr) (parser.Expr, string, bool) {
+	switch e := e.(type) {
+	case *parser.FieldExpr:
+		if stateEnv(e.X) != "" {
+			return e.X, e.Name.Value, true
+		}
+	case *parser.IndexExpr:
+		if str, ok := e.Key.(*parser.StringExpr); ok && stateEnv(e.X) != "" {
+			if key, ok := str.Token.Unquote(); ok {
+				return e.X, key, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// findStateHazards returns uses of getgenv(), shared and _G that break when
+// the bundle runs again in the same session: connections stored there and
+// never disconnected, whose handlers stay connected and pile up, and state
+// growing on every execution
+func findStateHazards(src string) []globalWarning {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return nil
+	}
+
+	var warnings []globalWarning
+	add := func(rule string, span parser.Span, format string, args ...interface{}) {
+		line := strings.Count(src[:span.Start], "\n") + 1
+		warnings = append(warnings, globalWarning{rule: rule, line: line, span: span, msg: fmt.Sprintf(format, args...)})
+	}
+	name := func(e parser.Expr) string {
+		env, key, _ := stateField(e)
+		return stateEnv(env) + "." + key
+	}
+
+	disconnected := make(map[string]bool)
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if call, ok := n.(*parser.MethodCallExpr); ok && call.Name.Value == "Disconnect" {
+			if _, _, ok := stateField(call.Recv); ok {
+				disconnected[name(call.Recv)] = true
+			}
+		}
+		return true
+	})
+
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch s := n.(type) {
+		case *parser.AssignStmt:
+			for i, t := range s.Targets {
+				if _, _, ok := stateField(t); !ok || i >= len(s.Values) {
+					continue
+				}
+				field := name(t)
+				if isConnect(s.Values[i]) && !disconnected[field] {
+					add(RuleStateConnection, t.Range(), "%s holds a connection that is never disconnected; running the bundle again leaves the old one connected", field)
+				}
+				if bin, ok := s.Values[i].(*parser.BinaryExpr); ok && (bin.Op == "+" || bin.Op == "..") {
+					if _, _, ok := stateField(bin.X); ok && name(bin.X) == field {
+						add(RuleStateAccumulate, t.Range(), "%s grows every time the bundle runs", field)
+					}
+				}
+			}
+		case *parser.CallExpr:
+			if field, ok := s.Fn.(*parser.FieldExpr); ok && isGlobal(field.X, "table") && field.Name.Value == "insert" && len(s.Args) > 0 {
+				if _, _, ok := stateField(s.Args[0]); ok {
+					add(RuleStateAccumulate, s.Args[0].Range(), "%s grows every time the bundle runs", name(s.Args[0]))
+				}
+			}
+		}
+		return true
+	})
+
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].line < warnings[j].line })
+	return warnings
+}
+
+// isConnect reports whether e is a signal:Connect(...) call
+func isConnect(e parser.Expr) bool {
+	call, ok := e.(*parser.MethodCallExpr)
+	return ok && (call.Name.Value == "Connect" || call.Name.Value == "connect")
+}
+
+// SetStateKey isolates the state the bundle keeps in getgenv(), shared and
+// _G under key: keys any bundled file assigns there are read and written in
+// one table stored under key instead, so bundles using the same names, such
+// as Settings or Loaded, no longer collide. An empty key disables it.
+func (b *Bundler) SetStateKey(key string) {
+	b.stateKey = key
+}
+
+// stateName returns the local holding the isolated state table
+func (b *Bundler) stateName() string {
+	if b.namespace == "" {
+		return "BundleState"
+	}
+	return b.namespace + "_BundleState"
+}
+
+// isolateState rewrites the shared state keys the entry content and modules
+// assign to go through the isolated state table, recording whether any do
+func (b *Bundler) isolateState(mainContent string) string {
+	sources := []string{mainContent}
+	for _, content := range b.modules {
+		sources = append(sources, content)
+	}
+	keys := make(map[string]bool)
+	for _, src := range sources {
+		for key := range assignedStateKeys(src) {
+			keys[key] = true
+		}
+	}
+	b.keepGlobalStateKeys(mainContent, keys)
+	if len(keys) == 0 {
+		b.stateUsed = false
+		return mainContent
+	}
+
+	name := b.stateName()
+	b.stateUsed = true
+	mainContent = rewriteStateAccess(mainContent, keys, name)
+	for key, content := range b.modules {
+		b.modules[key] = rewriteStateAccess(content, keys, name)
+	}
+	if b.verbose {
+		b.logf("🔒 Isolated shared state under %q: %s\n", b.stateKey, strings.Join(sortedKeys(keys), ", "))
+	}
+	return mainContent
+}
+
+// keepGlobalStateKeys drops the keys any bundled file also uses as a bare
+// global variable, with a warning. Whether a global reaches getgenv(),
+// shared or _G depends on the executor, so moving the key into the state
+// table could leave the global reading another value.
+func (b *Bundler) keepGlobalStateKeys(mainContent string, keys map[string]bool) {
+	files := []string{b.entryFile}
+	sources := []string{mainContent}
+	for _, key := range sortedKeys(b.modules) {
+		files = append(files, b.moduleSource(key))
+		sources = append(sources, b.modules[key])
+	}
+	for i, src := range sources {
+		for _, use := range globalStateUses(src, keys) {
+			if !keys[use.name] {
+				continue
+			}
+			delete(keys, use.name)
+			if !b.suppressed[RuleStateGlobal] {
+				b.warnf("%s:%d: %s is also used as a global variable; --state-key leaves it shared so both keep seeing the same value [%s]",
+					b.displaySource(files[i]), use.line, use.name, RuleStateGlobal)
+			}
+		}
+	}
+}
+
+// globalStateUse is a bare global variable named like a state key
+type globalStateUse struct {
+	name string
+	line int
+}
+
+// globalStateUses returns the first use in src of each of keys as a bare
+// global variable, in source order
+func globalStateUses(src string, keys map[string]bool) []globalStateUse {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return nil
+	}
+	var uses []globalStateUse
+	seen := make(map[string]bool)
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if id, ok := n.(*parser.Ident); ok && keys[id.Name] && !seen[id.Name] && isGlobal(id, id.Name) {
+			seen[id.Name] = true
+			uses = append(uses, globalStateUse{name: id.Name, line: strings.Count(src[:id.Start], "\n") + 1})
+		}
+		return true
+	})
+	sort.SliceStable(uses, func(i, j int) bool { return uses[i].line < uses[j].line })
+	return uses
+}
+
+// assignedStateKeys returns the keys src assigns in getgenv(), shared or _G
+func assignedStateKeys(src string) map[string]bool {
+	keys := make(map[string]bool)
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return keys
+	}
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch s := n.(type) {
+		case *parser.AssignStmt:
+			for _, t := range s.Targets {
+				if _, key, ok := stateField(t); ok {
+					keys[key] = true
+				}
+			}
+		case *parser.FunctionStmt:
+			if _, key, ok := stateField(s.Target); ok && s.Method == nil {
+				keys[key] = true
+			}
+		}
+		return true
+	})
+	return keys
+}
+
+// rewriteStateAccess replaces the environment of every access to keys in
+// src with name. Content the parser rejects is returned unchanged.
+func rewriteStateAccess(src string, keys map[string]bool, name string) string {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return src
+	}
+
+	var spans []parser.Span
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if e, ok := n.(parser.Expr); ok {
+			if env, key, ok := stateField(e); ok && keys[key] {
+				spans = append(spans, env.Range())
+			}
+		}
+		return true
+	})
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	var out strings.Builder
+	pos := 0
+	for _, span := range spans {
+		out.WriteString(src[pos:span.Start])
+		out.WriteString(name)
+		pos = span.End
+	}
+	out.WriteString(src[pos:])
+	return out.String()
+}
+
+// writeStateTable writes the isolated state table, created under key in the
+// executor environment on the first run and reused by later ones
+func writeStateTable(out *strings.Builder, name, key string) {
+	fmt.Fprintf(out, "-- Shared state, isolated under %s\n", quoteLua(key))
+	fmt.Fprintf(out, "local %s\n", name)
+	out.WriteString("do\n")
+	out.WriteString("    local env = getgenv and getgenv() or _G\n")
+	fmt.Fprintf(out, "    env[%s] = env[%s] or {}\n", quoteLua(key), quoteLua(key))
+	fmt.Fprintf(out, "    %s = env[%s]\n", name, quoteLua(key))
+	out.WriteString("end\n\n")
+}
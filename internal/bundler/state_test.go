@@ -0,0 +1,107 @@
+package bundler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/luavm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindStateHazards(t *testing.T) {
+	src := `getgenv().Conn = game.Players.PlayerAdded:Connect(function() end)
+if shared.Heartbeat then shared.Heartbeat:Disconnect() end
+shared.Heartbeat = game:GetService("RunService").Heartbeat:Connect(function() end)
+table.insert(_G.Hooks, function() end)
+getgenv()["Runs"] = getgenv()["Runs"] + 1
+getgenv().Loaded = true
+local conn = workspace.ChildAdded:Connect(print)
+`
+	warnings := findStateHazards(src)
+	require.Len(t, warnings, 3)
+	assert.Equal(t, RuleStateConnection, warnings[0].rule)
+	assert.Equal(t, 1, warnings[0].line)
+	assert.Equal(t, "getgenv().Conn holds a connection that is never disconnected; running the bundle again leaves the old one connected", warnings[0].msg)
+	assert.Equal(t, RuleStateAccumulate, warnings[1].rule)
+	assert.Equal(t, "_G.Hooks grows every time the bundle runs", warnings[1].msg)
+	assert.Equal(t, 5, warnings[2].line)
+}
+
+func TestStateHazardWarnings(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "table.insert(shared.Log, 1)\n"})
+	_, err = b.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.lua:1: shared.Log grows every time the bundle runs [state-accumulate]"}, b.GetWarnings())
+
+	b, err = NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "table.insert(shared.Log, 1)\n"})
+	require.NoError(t, b.SuppressWarnings([]string{RuleStateAccumulate}))
+	_, err = b.Resolve()
+	require.NoError(t, err)
+	assert.Empty(t, b.GetWarnings())
+}
+
+func TestSetStateKey(t *testing.T) {
+	project := MemoryFS{
+		"main.lua":     "if getgenv().Loaded then return end\ngetgenv().Loaded = true\nlocal settings = require(\"settings\")\nprint(_G.print, getgenv().request)\n",
+		"settings.lua": "shared[\"Settings\"] = shared[\"Settings\"] or { speed = 16 }\nreturn getgenv().Settings\n",
+	}
+
+	for _, loader := range []string{LoaderClosure, LoaderLazy} {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFileSystem(project)
+		require.NoError(t, b.SetLoader(loader))
+		b.SetStateKey("my-hub")
+
+		out, err := b.Bundle(false)
+		require.NoError(t, err)
+		assert.Contains(t, out, "-- Shared state, isolated under \"my-hub\"\nlocal BundleState\ndo\n", loader)
+		assert.Contains(t, out, "if BundleState.Loaded then return end", loader)
+		assert.Contains(t, out, "BundleState[\"Settings\"] = BundleState[\"Settings\"] or", loader)
+		assert.Contains(t, out, "return BundleState.Settings", loader)
+		assert.Contains(t, out, "print(_G.print, getgenv().request)", "keys the bundle never assigns should be left alone")
+	}
+
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "print(getgenv().request)\n"})
+	b.SetStateKey("my-hub")
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "BundleState", "the state table should only be injected when used")
+}
+
+func TestSetStateKey_BareGlobals(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua": "_G.flag = 1\n_G.count = 2\nrequire(\"util\")\nprint(_G.count)\n",
+		"util.lua": "print(flag)\nflag = 3\nprint(_G.flag)\n",
+	})
+	b.SetStateKey("my-hub")
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, out, "_G.flag = 1\n", "a key also used as a bare global stays shared")
+	assert.Contains(t, out, "BundleState.count = 2\n")
+	assert.Equal(t, []string{"util.lua:1: flag is also used as a global variable; --state-key leaves it shared so both keep seeing the same value [state-global]"}, b.GetWarnings())
+
+	printed, err := luavm.Run(out, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "1\n3\n2\n", printed, "the bundle behaves as without --state-key")
+
+	b, err = NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "_G.flag = 1\nprint(flag)\n"})
+	b.SetStateKey("my-hub")
+	require.NoError(t, b.SuppressWarnings([]string{RuleStateGlobal}))
+	_, err = b.Bundle(false)
+	require.NoError(t, err)
+	assert.Empty(t, b.GetWarnings())
+}
@@ -0,0 +1,53 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetStrict makes every require of the bundled code a build error unless it
+// is embedded, stubbed or explicitly external: marked --@bundle-as-external,
+// decided external, or provided by the target. Requires the heuristics
+// leave external and requires whose argument is computed are reported.
+func (b *Bundler) SetStrict(strict bool) {
+	b.strict = strict
+}
+
+// strictError reports the requires strict mode rejects, or nil
+func (b *Bundler) strictError() error {
+	problems := append([]string{}, b.requireScanErrors...)
+	seen := make(map[string]bool)
+	for _, r := range b.requireCalls {
+		if r.pragma == "external" {
+			continue
+		}
+		var problem string
+		switch {
+		case r.path == "":
+			problem = fmt.Sprintf("%s:%d: require with a computed argument cannot be bundled", r.file, r.line)
+		case b.isStrictlyResolved(r.path):
+			continue
+		default:
+			problem = fmt.Sprintf("%s:%d: %s is left to the runtime", r.file, r.line, r.path)
+		}
+		if !seen[problem] {
+			seen[problem] = true
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("strict mode: %d unresolved require(s); embed or stub each module, or mark it with %s:\n  %s",
+		len(problems), PragmaExternal, strings.Join(problems, "\n  "))
+}
+
+// isStrictlyResolved reports whether strict mode accepts a require of
+// modulePath: it is embedded or stubbed, decided external or provided by
+// the target
+func (b *Bundler) isStrictlyResolved(modulePath string) bool {
+	if _, embedded := b.modules[modulePath]; embedded {
+		return true
+	}
+	return b.requireDecisions[modulePath] == DecisionExternal || b.isTargetExternal(modulePath)
+}
@@ -0,0 +1,71 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStrictBundler(t *testing.T, files MemoryFS) *Bundler {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(files)
+	b.SetStrict(true)
+	return b
+}
+
+func TestResolve_StrictReportsUnresolvedRequires(t *testing.T) {
+	b := newStrictBundler(t, MemoryFS{
+		"main.lua": "local Remote = require(ReplicatedStorage.Remote)\n" +
+			"local a, c = require(\"a\"), require(\"c\")\n" +
+			"local plugin = require(\"plugins.\" .. name)\n",
+		"a.lua": "return require(game.Workspace.Thing)\n",
+		"c.lua": "return {}\n",
+	})
+
+	_, err := b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "strict mode: 4 unresolved require(s)")
+	assert.Contains(t, err.Error(), "\n  main.lua:1: ReplicatedStorage.Remote is left to the runtime")
+	assert.Contains(t, err.Error(), "\n  main.lua:2: c is left to the runtime", "only the first require of a line is followed")
+	assert.Contains(t, err.Error(), "\n  main.lua:3: require with a computed argument cannot be bundled")
+	assert.Contains(t, err.Error(), "\n  a.lua:1: game.Workspace.Thing is left to the runtime")
+}
+
+func TestResolve_StrictAcceptsExplicitRequires(t *testing.T) {
+	b := newStrictBundler(t, MemoryFS{
+		"main.lua": "--@bundle-as-external\n" +
+			"local Remote = require(ReplicatedStorage.Remote)\n" +
+			"local plugin = require(\"plugins.\" .. name) --@bundle-as-external\n" +
+			"local analytics = require(\"analytics\")\n" +
+			"local Players = require(\"Players.Local\")\n" +
+			"local util = require(\"util\")\n",
+		"util.lua": "return {}\n",
+	})
+	b.SetStubs(map[string]string{"analytics": ""})
+	b.SetRequireDecisions(map[string]string{"Players.Local": DecisionExternal})
+
+	_, err := b.Resolve()
+	require.NoError(t, err)
+}
+
+func TestResolve_StrictAcceptsTargetModules(t *testing.T) {
+	b := newStrictBundler(t, MemoryFS{"main.lua": "local shell = require(\"shell\")\nlocal json = require(\"cjson\")\n"})
+	require.NoError(t, b.SetTarget(TargetOpenComputers))
+	require.NoError(t, b.SetResolution(ResolveLuaPackage))
+
+	_, err := b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "strict mode: 1 unresolved require(s)")
+	assert.Contains(t, err.Error(), "main.lua:2: cjson is left to the runtime", "OpenOS provides shell, but not cjson")
+}
+
+func TestResolve_NotStrictByDefault(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "local Remote = require(ReplicatedStorage.Remote)\n"})
+
+	_, err = b.Resolve()
+	require.NoError(t, err)
+}
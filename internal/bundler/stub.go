@@ -0,0 +1,68 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SetStubs replaces modules at bundle time, such as optional analytics or
+// debug panels left out of a distribution build. stubs maps a module key,
+// the require path or URL, to the file embedded in its place; an empty file
+// omits the module, embedding an empty table. The replaced module is never
+// read or downloaded. Requires in a stub file are followed as usual.
+func (b *Bundler) SetStubs(stubs map[string]string) {
+	b.stubs = stubs
+}
+
+// stubModule embeds the replacement of a stubbed module, or of a module of
+// a disabled feature, under key and reports whether key is replaced
+func (b *Bundler) stubModule(key string, depth int) (bool, error) {
+	stubPath, ok := b.stubs[key]
+	feature := ""
+	if !ok {
+		if feature = b.disabledFeature(key); feature == "" {
+			return false, nil
+		}
+	}
+	if _, exists := b.modules[key]; exists {
+		return true, nil
+	}
+
+	if stubPath == "" {
+		b.modules[key] = "return {}"
+		if b.verbose && feature != "" {
+			b.logf("🚫 Omitted: %s (feature %s disabled)\n", key, feature)
+		} else if b.verbose {
+			b.logf("🚫 Omitted: %s\n", key)
+		}
+		return true, nil
+	}
+
+	content, err := b.readSource(stubPath)
+	if err != nil {
+		return true, fmt.Errorf("failed to read stub for %s: %w", key, err)
+	}
+	moduleContent := content
+	b.moduleSources[key] = stubPath
+	moduleContent = b.obfuscate(key, stubPath, moduleContent)
+	b.modules[key] = moduleContent
+	if b.verbose {
+		b.logf("🧩 Stubbed: %s (%s)\n", key, stubPath)
+	}
+	return true, b.processFile(key, stubPath, content, depth)
+}
+
+// warnUnusedStubs warns about stubbed modules nothing requires, which are
+// usually misspelled
+func (b *Bundler) warnUnusedStubs() {
+	keys := make([]string, 0, len(b.stubs))
+	for key := range b.stubs {
+		if _, ok := b.modules[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.warnf("stub: %s is not required by any module", key)
+	}
+}
@@ -0,0 +1,58 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetStubs(t *testing.T) {
+	files := MemoryFS{
+		"main.lua":            "local analytics = require(\"analytics\")\nlocal panel = require(\"debug.panel\")\nlocal util = require(\"util\")\n",
+		"analytics.lua":       `return require("heavy.tracker")`,
+		"debug/panel.lua":     `error("debug panel must not ship")`,
+		"util.lua":            `return {}`,
+		"stubs/analytics.lua": `local util = require("../util") return { track = function() end }`,
+	}
+
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(files)
+	b.SetStubs(map[string]string{
+		"analytics":   "stubs/analytics.lua",
+		"debug.panel": "",
+	})
+
+	out, err := b.Bundle(false)
+	require.NoError(t, err)
+	assert.Contains(t, out, "return { track = function() end }", "the stub should be embedded")
+	assert.NotContains(t, out, "heavy.tracker", "the stubbed module should not be read")
+	assert.NotContains(t, out, "debug panel must not ship", "the omitted module should not be read")
+	assert.Equal(t, "return {}", b.GetModules()["debug.panel"])
+	assert.Contains(t, b.GetModules(), "util", "requires in stubs should be followed")
+	assert.Empty(t, b.GetWarnings())
+}
+
+func TestSetStubs_Errors(t *testing.T) {
+	t.Run("missing stub file", func(t *testing.T) {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFileSystem(MemoryFS{"main.lua": `require("analytics")`})
+		b.SetStubs(map[string]string{"analytics": "stubs/missing.lua"})
+
+		_, err = b.Bundle(false)
+		assert.ErrorContains(t, err, "failed to read stub for analytics")
+	})
+
+	t.Run("unused stub", func(t *testing.T) {
+		b, err := NewBundler("main.lua", false, false)
+		require.NoError(t, err)
+		b.SetFileSystem(MemoryFS{"main.lua": `print("hi")`})
+		b.SetStubs(map[string]string{"analytcs": ""})
+
+		_, err = b.Bundle(false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"stub: analytcs is not required by any module"}, b.GetWarnings())
+	})
+}
@@ -0,0 +1,115 @@
+package bundler
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions caps the files a missing module error suggests
+const maxSuggestions = 3
+
+// moduleSuggestions returns the project files closest to missing, a Lua
+// file that does not exist: the same path in other case, the same file one
+// folder level up or down, or a path a few edits away. They are relative
+// to the base directory, closest first.
+func (b *Bundler) moduleSuggestions(missing string) []string {
+	lister, ok := b.fs.(fileLister)
+	if !ok {
+		return nil
+	}
+	rel, err := filepath.Rel(b.baseDir, missing)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	names, err := lister.ListFiles(b.baseDir)
+	if err != nil {
+		return nil
+	}
+
+	want := strings.ToLower(filepath.ToSlash(rel))
+	type match struct {
+		name  string
+		score int
+	}
+	var matches []match
+	for _, name := range names {
+		if !strings.EqualFold(path.Ext(name), ".lua") || strings.HasPrefix(name, ".") || strings.Contains(name, "/.") {
+			continue
+		}
+		if score, ok := suggestionScore(want, strings.ToLower(name)); ok {
+			matches = append(matches, match{name, score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score < matches[j].score
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	var suggestions []string
+	for _, m := range matches {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, m.name)
+	}
+	return suggestions
+}
+
+// suggestionScore reports whether name, lowercased, is close enough to the
+// lowercased path want to suggest, and how close: 0 for a case difference,
+// 1 for a folder level, more for each edit
+func suggestionScore(want, name string) (int, bool) {
+	if want == name {
+		return 0, true
+	}
+	if folderLevelApart(want, name) {
+		return 1, true
+	}
+	distance := editDistance(want, name)
+	return distance + 1, distance <= 2+len(want)/10
+}
+
+// folderLevelApart reports whether a and b differ by one folder: dropping
+// one directory from the longer path gives the shorter
+func folderLevelApart(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	if len(as) < len(bs) {
+		as, bs = bs, as
+	}
+	if len(as) != len(bs)+1 {
+		return false
+	}
+	shorter := strings.Join(bs, "/")
+	for i := 0; i < len(as)-1; i++ {
+		dropped := append(append([]string{}, as[:i]...), as[i+1:]...)
+		if strings.Join(dropped, "/") == shorter {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
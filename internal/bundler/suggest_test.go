@@ -0,0 +1,59 @@
+package bundler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleSuggestions(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":            "",
+		"utils/log.lua":       "",
+		"Utils/Format.lua":    "",
+		"src/net/http.lua":    "",
+		"ui/theme.lua":        "",
+		"ui/theme.json":       "",
+		".cache/utils/lg.lua": "",
+	})
+
+	tests := []struct {
+		missing string
+		want    []string
+	}{
+		{"/utils/lgo.lua", []string{"utils/log.lua"}},
+		{"/utils/format.lua", []string{"Utils/Format.lua"}},
+		{"/net/http.lua", []string{"src/net/http.lua"}},
+		{"/src/net/client/http.lua", []string{"src/net/http.lua"}},
+		{"/ui/thme.lua", []string{"ui/theme.lua"}},
+		{"/database/users.lua", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, b.moduleSuggestions(tt.missing), tt.missing)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("log", "log"))
+	assert.Equal(t, 2, editDistance("lgo", "log"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+	assert.Equal(t, 1, editDistance("utils", "util"))
+}
+
+func TestBundle_MissingModuleSuggestions(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":      "local log = require(\"utils.lgo\")\n",
+		"utils/log.lua": "return {}\n",
+		"utils/lag.lua": "return {}\n",
+	})
+
+	_, err = b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read file /utils/lgo.lua")
+	assert.Contains(t, err.Error(), "\n  did you mean utils/lag.lua or utils/log.lua?")
+}
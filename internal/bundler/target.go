@@ -0,0 +1,101 @@
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Supported runtime targets
+const (
+	TargetRoblox    = "roblox"
+	TargetLua51     = "lua51"
+	TargetLua52     = "lua52"
+	TargetLua53     = "lua53"
+	TargetLua54     = "lua54"
+	TargetLuaJIT    = "luajit"
+	TargetLove2D    = "love2d"    // LÖVE games, running on LuaJIT
+	TargetOpenResty = "openresty" // OpenResty (nginx) modules, running on LuaJIT
+
+	TargetComputerCraft = "computercraft" // ComputerCraft (CC: Tweaked) programs
+	TargetOpenComputers = "opencomputers" // OpenComputers programs, on OpenOS
+	TargetWoW           = "wow"           // World of Warcraft addons, Lua 5.1
+	TargetGMod          = "gmod"          // Garry's Mod addons, on LuaJIT
+	TargetFiveM         = "fivem"         // FiveM resources, on Lua 5.4
+)
+
+// Targets lists every runtime target accepted by --target
+var Targets = []string{TargetRoblox, TargetLua51, TargetLua52, TargetLua53, TargetLua54, TargetLuaJIT, TargetLove2D, TargetOpenResty, TargetComputerCraft, TargetOpenComputers, TargetWoW, TargetGMod, TargetFiveM}
+
+// targetExternals are the modules each target provides itself; they and
+// their submodules stay runtime requires
+var targetExternals = map[string][]string{
+	TargetOpenResty:     openRestyExternals,
+	TargetComputerCraft: ccExternals,
+	TargetOpenComputers: ocExternals,
+}
+
+// isTargetExternal reports whether the target provides modulePath
+func (b *Bundler) isTargetExternal(modulePath string) bool {
+	for _, name := range targetExternals[b.target] {
+		if modulePath == name || strings.HasPrefix(modulePath, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidTarget reports whether target is a known runtime target
+func IsValidTarget(target string) bool {
+	for _, t := range Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTarget sets the runtime target used to select module variants
+func (b *Bundler) SetTarget(target string) error {
+	if !IsValidTarget(target) {
+		return fmt.Errorf("unknown target %q (supported: %s)", target, strings.Join(Targets, ", "))
+	}
+	b.target = target
+	return nil
+}
+
+// GetTarget returns the runtime target
+func (b *Bundler) GetTarget() string {
+	return b.target
+}
+
+// SetVariants sets explicit per-target module mappings (module -> target -> path)
+func (b *Bundler) SetVariants(variants map[string]map[string]string) {
+	b.variants = variants
+}
+
+// resolveVariant returns the target-specific file for a module if one exists.
+// An explicit mapping takes precedence over the name.<target>.lua suffix convention.
+func (b *Bundler) resolveVariant(modulePath, resolvedPath string) string {
+	if byTarget, ok := b.variants[modulePath]; ok {
+		if variantPath, ok := byTarget[b.target]; ok {
+			if !filepath.IsAbs(variantPath) {
+				variantPath = filepath.Join(b.baseDir, variantPath)
+			}
+			if b.verbose {
+				b.logf("🎯 Variant (%s): %s -> %s\n", b.target, modulePath, variantPath)
+			}
+			return variantPath
+		}
+	}
+
+	variantPath := strings.TrimSuffix(resolvedPath, ".lua") + "." + b.target + ".lua"
+	if _, err := b.fs.Stat(variantPath); err == nil {
+		if b.verbose {
+			b.logf("🎯 Variant (%s): %s -> %s\n", b.target, modulePath, variantPath)
+		}
+		return variantPath
+	}
+
+	return resolvedPath
+}
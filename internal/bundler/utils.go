@@ -2,172 +2,334 @@ package bundler
 
 import (
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
 )
 
-// removeDebugStatements removes print() and warn() statements for release mode
-func removeDebugStatements(content string) string {
-	lines := strings.Split(content, "\n")
-	var result []string
+// debugFunctions are the globals whose call statements release mode removes
+var debugFunctions = map[string]bool{"print": true, "warn": true}
 
-	// Regex patterns for detecting print and warn statements
-	printRegex := regexp.MustCompile(`^\s*print\s*\(`)
-	warnRegex := regexp.MustCompile(`^\s*warn\s*\(`)
+// keepTag in a comment following a print or warn statement on the same line
+// keeps it in release mode
+const keepTag = "@keep"
 
-	inMultilineStatement := false
-	parenDepth := 0
+// debugCall is a print or warn call statement found by debugStatements
+type debugCall struct {
+	stmt parser.Span
+	fn   *parser.Ident
+	args []parser.Expr
+}
 
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
+// debugStatements returns the print() and warn() call statements release
+// mode rewrites, in source order. Statements are found in the syntax tree,
+// so calls inside strings and comments are never included. Statements tagged
+// --@keep, or with a string argument matching one of keep, are skipped.
+func debugStatements(content string, keep []*regexp.Regexp) ([]debugCall, error) {
+	chunk, err := parser.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	return debugStatementsIn(chunk, content, keep), nil
+}
 
-		// Skip empty lines
-		if trimmed == "" {
-			result = append(result, line)
-			continue
+// debugStatementsIn returns the debug statements of content, parsed as chunk
+func debugStatementsIn(chunk *parser.Chunk, content string, keep []*regexp.Regexp) []debugCall {
+	var calls []debugCall
+	parser.Walk(chunk, func(n parser.Node) bool {
+		stmt, ok := n.(*parser.CallStmt)
+		if !ok {
+			return true
 		}
+		call, ok := stmt.Call.(*parser.CallExpr)
+		if !ok {
+			return true
+		}
+		fn, ok := call.Fn.(*parser.Ident)
+		if !ok || !debugFunctions[fn.Name] || fn.Binding == nil || !fn.Binding.Global() {
+			return true
+		}
+		if !keepTagged(content, chunk.Tokens, stmt.End) && !matchesKeep(call.Args, keep) {
+			calls = append(calls, debugCall{stmt: stmt.Span, fn: fn, args: call.Args})
+		}
+		return false
+	})
+	return calls
+}
 
-		// Check if this line starts a print/warn statement
-		if !inMultilineStatement && (printRegex.MatchString(line) || warnRegex.MatchString(line)) {
-			inMultilineStatement = true
-			parenDepth = 0
-
-			// Count parentheses in this line
-			for _, char := range line {
-				if char == '(' {
-					parenDepth++
-				} else if char == ')' {
-					parenDepth--
-				}
-			}
+// removeDebugStatements removes print() and warn() call statements for
+// release mode, keeping those matched as described in debugStatements.
+// With keepCalls, a statement whose arguments call functions leaves those
+// calls behind, as keptCalls writes them. Content the parser rejects is
+// returned unchanged.
+func removeDebugStatements(content string, keep []*regexp.Regexp, keepCalls bool) string {
+	calls, err := debugStatements(content, keep)
+	if err != nil {
+		return content
+	}
 
-			// If statement ends on the same line
-			if parenDepth <= 0 {
-				inMultilineStatement = false
+	var out strings.Builder
+	pos := 0
+	for _, call := range calls {
+		if kept, _, ok := keptCalls(content, call); keepCalls && ok {
+			if call.stmt.Start < pos {
+				continue
 			}
-			continue // Skip this line
+			out.WriteString(content[pos:call.stmt.Start])
+			out.WriteString(kept)
+			pos = call.stmt.End
+			continue
+		}
+		start, end := statementLines(content, call.stmt)
+		if start < pos {
+			continue
 		}
+		out.WriteString(content[pos:start])
+		pos = end
+	}
+	out.WriteString(content[pos:])
+	return out.String()
+}
 
-		// If we're in a multiline print/warn statement
-		if inMultilineStatement {
-			for _, char := range line {
-				if char == '(' {
-					parenDepth++
-				} else if char == ')' {
-					parenDepth--
-				}
-			}
+// blankDebugStatements removes the same statements as removeDebugStatements
+// but keeps their line breaks, so every other line keeps its number. Calls
+// kept from the arguments stay on the line they were on.
+func blankDebugStatements(content string, keep []*regexp.Regexp, keepCalls bool) string {
+	calls, err := debugStatements(content, keep)
+	if err != nil {
+		return content
+	}
 
-			if parenDepth <= 0 {
-				inMultilineStatement = false
-			}
-			continue // Skip this line
+	var out strings.Builder
+	pos := 0
+	for _, call := range calls {
+		out.WriteString(content[pos:call.stmt.Start])
+		stmt := content[call.stmt.Start:call.stmt.End]
+		if kept, start, ok := keptCalls(content, call); keepCalls && ok {
+			before := strings.Count(content[call.stmt.Start:start], "\n")
+			out.WriteString(strings.Repeat("\n", before))
+			out.WriteString(kept)
+			out.WriteString(strings.Repeat("\n", strings.Count(stmt, "\n")-before-strings.Count(kept, "\n")))
+		} else {
+			out.WriteString(strings.Repeat("\n", strings.Count(stmt, "\n")))
 		}
+		pos = call.stmt.End
+	}
+	out.WriteString(content[pos:])
+	return out.String()
+}
 
-		// Add lines that are not print/warn
-		result = append(result, line)
+// keptCalls returns the code that keeps the function calls made by the
+// arguments of a debug statement once the statement is removed: a lone call
+// becomes a statement of its own, and other arguments that call functions
+// are assigned to a throwaway local, in order, inside a do block. start is
+// the offset of the first argument kept; ok is false when no argument calls
+// a function outside a function body.
+func keptCalls(content string, call debugCall) (code string, start int, ok bool) {
+	var exprs []string
+	var only parser.Expr
+	for _, arg := range call.args {
+		if _, ok := sideEffectCall([]parser.Expr{arg}); ok {
+			span := arg.Range()
+			if len(exprs) == 0 {
+				start = span.Start
+			}
+			exprs = append(exprs, content[span.Start:span.End])
+			only = arg
+		}
 	}
+	if len(exprs) == 0 {
+		return "", 0, false
+	}
+	if len(exprs) == 1 && !strings.HasPrefix(exprs[0], "(") {
+		// A statement starting with ( would be read as a call of the one
+		// before it
+		switch only.(type) {
+		case *parser.CallExpr, *parser.MethodCallExpr:
+			return exprs[0], start, true
+		}
+	}
+	return "do local _ = " + strings.Join(exprs, ", ") + " end", start, true
+}
 
-	return strings.Join(result, "\n")
+// keepTagged reports whether the first token after end is a comment on the
+// same line carrying the keep tag
+func keepTagged(content string, tokens []parser.Token, end int) bool {
+	i := sort.Search(len(tokens), func(i int) bool { return tokens[i].Start >= end })
+	if i == len(tokens) || tokens[i].Kind != parser.Comment || strings.Contains(content[end:tokens[i].Start], "\n") {
+		return false
+	}
+	text := strings.TrimSpace(strings.TrimPrefix(tokens[i].Value, "--"))
+	return text == keepTag || strings.HasPrefix(text, keepTag+" ")
 }
 
-// removeComments removes all Lua comments (-- and --[[ ]]) from code
-func removeComments(content string) string {
-	lines := strings.Split(content, "\n")
-	var result []string
-	inMultilineComment := false
-
-	for _, line := range lines {
-		if inMultilineComment {
-			// Check for end of multiline comment
-			if strings.Contains(line, "--]]") || strings.Contains(line, "]]") {
-				inMultilineComment = false
-				// Get content after the closing ]]
-				if idx := strings.Index(line, "]]"); idx != -1 {
-					remaining := line[idx+2:]
-					if strings.TrimSpace(remaining) != "" {
-						result = append(result, remaining)
-					}
-				}
-			}
+// matchesKeep reports whether a string literal among args matches a keep pattern
+func matchesKeep(args []parser.Expr, keep []*regexp.Regexp) bool {
+	for _, arg := range args {
+		str, ok := arg.(*parser.StringExpr)
+		if !ok {
 			continue
 		}
-
-		// Check for start of multiline comment
-		if strings.Contains(line, "--[[") {
-			inMultilineComment = true
-			// Get content before the opening --[[
-			if idx := strings.Index(line, "--[["); idx > 0 {
-				before := line[:idx]
-				if strings.TrimSpace(before) != "" {
-					result = append(result, before)
-				}
-			}
-			// Check if comment ends on same line
-			if strings.Contains(line, "]]") {
-				inMultilineComment = false
-			}
+		value, ok := str.Token.Unquote()
+		if !ok {
 			continue
 		}
-
-		// Remove single line comments
-		if idx := strings.Index(line, "--"); idx != -1 {
-			// Check if -- is inside a string
-			inString := false
-			stringChar := rune(0)
-			for i, char := range line {
-				if i == idx && !inString {
-					// Found comment outside string
-					line = line[:idx]
-					break
-				}
-				if char == '"' || char == '\'' {
-					if !inString {
-						inString = true
-						stringChar = char
-					} else if char == stringChar {
-						inString = false
-					}
-				}
+		for _, re := range keep {
+			if re.MatchString(value) {
+				return true
 			}
 		}
+	}
+	return false
+}
 
-		// Add non-empty lines
-		if strings.TrimSpace(line) != "" {
-			result = append(result, line)
-		}
+// statementLines widens a statement span to the whole lines it occupies when
+// nothing but whitespace or a line comment shares them, so removing it leaves
+// no blank line behind
+func statementLines(content string, span parser.Span) (int, int) {
+	lineStart := strings.LastIndexByte(content[:span.Start], '\n') + 1
+	if strings.TrimSpace(content[lineStart:span.Start]) != "" {
+		return span.Start, span.End
+	}
+
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[span.End:], '\n'); i >= 0 {
+		lineEnd = span.End + i
+	}
+	rest := strings.TrimSpace(content[span.End:lineEnd])
+	if rest != "" && (!strings.HasPrefix(rest, "--") || strings.HasPrefix(rest, "--[")) {
+		return span.Start, span.End
 	}
 
-	return strings.Join(result, "\n")
+	if lineEnd < len(content) {
+		return lineStart, lineEnd + 1
+	}
+	// Last line: take the preceding newline instead
+	if lineStart > 0 {
+		lineStart--
+	}
+	return lineStart, lineEnd
 }
 
-// minifyCode converts code to single line by removing unnecessary whitespace
-func minifyCode(content string) string {
-	lines := strings.Split(content, "\n")
-	var result []string
+// removeComments removes all Lua comments (-- and --[[ ]]) from code, along
+// with the blank lines between statements. String contents, including long
+// strings, are never modified; content the lexer rejects is returned unchanged.
+func removeComments(content string) string {
+	tokens, err := parser.Tokenize(content)
+	if err != nil {
+		return content
+	}
+
+	var out strings.Builder
+	pos := 0         // end of the last token written
+	gap := ""        // whitespace since the last token written
+	dropped := false // whether a comment was removed since the last token written
+	for _, tok := range tokens {
+		gap += content[pos:tok.Start]
+		pos = tok.End
+		if tok.Kind == parser.Comment {
+			dropped = true
+			continue
+		}
+		if tok.Kind == parser.EOF {
+			break
+		}
 
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" {
-			result = append(result, trimmed)
+		switch {
+		case out.Len() == 0:
+			// Keep the first line's indentation only
+			gap = gap[strings.LastIndexByte(gap, '\n')+1:]
+		case strings.Contains(gap, "\n"):
+			gap = "\n" + gap[strings.LastIndexByte(gap, '\n')+1:]
+		case gap == "" && dropped:
+			gap = " "
 		}
+		out.WriteString(gap)
+		out.WriteString(tok.Value)
+		gap, dropped = "", false
 	}
+	return out.String()
+}
 
-	// Join with space to maintain separation between statements
-	minified := strings.Join(result, " ")
+// minifyOptions select the optional minifyCode transforms
+type minifyOptions struct {
+	aggressive    bool // drop unneeded semicolons and shorten numbers
+	doubles       bool // numbers are doubles, see shortenNumber
+	preserveLines bool // keep every token on its original line
+}
 
-	// Clean up excessive spaces
-	minified = regexp.MustCompile(`\s+`).ReplaceAllString(minified, " ")
+// minifyCode converts code to a single line, keeping a space between two
+// tokens only where they would otherwise lex differently. Comments are
+// dropped; string contents are kept as written. With preserveLines, tokens
+// stay on their original lines instead, so line numbers in runtime errors
+// still match. Content the lexer rejects is returned unchanged.
+func minifyCode(content string, opts minifyOptions) string {
+	tokens, err := parser.Tokenize(content)
+	if err != nil {
+		return content
+	}
 
-	// Remove spaces around operators and punctuation where safe
-	minified = regexp.MustCompile(`\s*([,;=(){}[\]])\s*`).ReplaceAllString(minified, "$1")
+	var out strings.Builder
+	var prev *parser.Token
+	braces := 0
+	line := 1
+	for i := range tokens {
+		tok := &tokens[i]
+		if tok.Kind == parser.Comment || tok.Kind == parser.EOF {
+			continue
+		}
+		if opts.aggressive {
+			switch {
+			case tok.Kind == parser.Number:
+				tok.Value = shortenNumber(tok.Value, opts.doubles)
+			case tok.Value == "{":
+				braces++
+			case tok.Value == "}":
+				braces--
+			case tok.Value == ";" && braces == 0 && !startsCall(tokens[i+1:]):
+				// Outside table constructors and type tables, a semicolon
+				// only matters before a statement starting with "("
+				continue
+			}
+		}
+		switch {
+		case opts.preserveLines && tok.Line > line:
+			out.WriteString(strings.Repeat("\n", tok.Line-line))
+		case prev != nil && needsSpace(*prev, *tok):
+			out.WriteByte(' ')
+		}
+		out.WriteString(tok.Value)
+		line = tok.Line + strings.Count(tok.Value, "\n")
+		prev = tok
+	}
+	return out.String()
+}
 
-	// Add space after keywords that require it
-	keywords := []string{"local", "function", "if", "then", "else", "elseif", "end", "for", "while", "do", "return", "in", "and", "or", "not"}
-	for _, keyword := range keywords {
-		minified = regexp.MustCompile(`\b`+keyword+`\b`).ReplaceAllStringFunc(minified, func(match string) string {
-			return match + " "
-		})
+// startsCall reports whether the next token that is not a comment or
+// semicolon is "(", which a dropped semicolon would turn into a call
+func startsCall(tokens []parser.Token) bool {
+	for _, tok := range tokens {
+		if tok.Kind != parser.Comment && tok.Value != ";" {
+			return tok.Kind == parser.Symbol && tok.Value == "("
+		}
 	}
+	return false
+}
 
-	return minified
+// needsSpace reports whether writing a directly before b would change how
+// they are lexed, as with two names, "- -" or "[ [["
+func needsSpace(a, b parser.Token) bool {
+	// Lua reads every alphanumeric after a numeral into it, so 1do is malformed
+	if a.Kind == parser.Number {
+		c := b.Value[0]
+		if c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			return true
+		}
+	}
+	joined, err := parser.Tokenize(a.Value + b.Value)
+	if err != nil || len(joined) != 3 {
+		return true
+	}
+	return joined[0].Value != a.Value || joined[1].Value != b.Value
 }
@@ -1,6 +1,7 @@
 package bundler
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
@@ -124,7 +125,7 @@ return calculate()`,
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := removeDebugStatements(tt.input)
+			result := removeDebugStatements(tt.input, nil, false)
 
 			// Normalize line endings for comparison
 			expected := strings.ReplaceAll(tt.expected, "\r\n", "\n")
@@ -188,7 +189,7 @@ local function processData(data)
 end
 `
 
-	result := removeDebugStatements(input)
+	result := removeDebugStatements(input, nil, false)
 
 	// Normalize line endings
 	expected = strings.ReplaceAll(expected, "\r\n", "\n")
@@ -196,3 +197,150 @@ end
 
 	assert.Equal(t, expected, result, "removeDebugStatements() complex case should match expected output")
 }
+
+func TestRemoveDebugStatements_StringsAndComments(t *testing.T) {
+	input := `local help = [[
+print("usage")
+warn("x")
+]]
+--[[
+print("commented out")
+]]
+local s = "print(1)" print("inline") local t = 2
+if debug then print("a") end
+print("tagged") -- trailing comment
+local function f(print) print("param") end
+local x = obj.print("kept")
+obj:warn("kept")`
+
+	expected := `local help = [[
+print("usage")
+warn("x")
+]]
+--[[
+print("commented out")
+]]
+local s = "print(1)"  local t = 2
+if debug then  end
+local function f(print) print("param") end
+local x = obj.print("kept")
+obj:warn("kept")`
+
+	assert.Equal(t, expected, removeDebugStatements(input, nil, false))
+}
+
+func TestRemoveDebugStatements_ParseError(t *testing.T) {
+	input := "print(\"x\")\nlocal = ="
+	assert.Equal(t, input, removeDebugStatements(input, nil, false), "unparseable content should be left alone")
+}
+
+func TestRemoveComments(t *testing.T) {
+	input := `-- header
+local a = 1 -- trailing
+
+--[==[
+block ]] still comment
+]==]
+local b = "-- not a comment"
+local c = [[
+-- kept
+
+inside]]
+local--[[x]]d = a--[[y]]-b
+    return c`
+
+	expected := `local a = 1
+local b = "-- not a comment"
+local c = [[
+-- kept
+
+inside]]
+local d = a -b
+    return c`
+
+	assert.Equal(t, expected, removeComments(input))
+}
+
+func TestMinifyCode(t *testing.T) {
+	input := `local a = 1
+local b = a - -a
+local s = [[
+two  lines]]
+local t = x[ [[k]] ]
+-- comment
+for i = 1, 10 do s = s .. 1 .. "x" end
+return a..b`
+
+	expected := `local a=1 local b=a- -a local s=[[
+two  lines]]local t=x[ [[k]]]for i=1,10 do s=s..1 .."x"end return a..b`
+
+	assert.Equal(t, expected, minifyCode(input, minifyOptions{}))
+}
+
+func TestRemoveDebugStatements_Keep(t *testing.T) {
+	input := `print("debug")
+print("Loaded!") --@keep
+warn("careful") -- @keep shown to users
+print("tagged on the next line")
+--@keep
+print("[ERROR] failed to load")
+warn("[error] lowercase")
+print(
+    "multi"
+) --@keep
+print("not a keep tag") -- @keeper`
+
+	expected := `print("Loaded!") --@keep
+warn("careful") -- @keep shown to users
+--@keep
+print("[ERROR] failed to load")
+print(
+    "multi"
+) --@keep`
+
+	keep := []*regexp.Regexp{regexp.MustCompile(`^\[ERROR\]`)}
+	assert.Equal(t, expected, removeDebugStatements(input, keep, false))
+}
+
+func TestRemoveDebugStatements_KeepCalls(t *testing.T) {
+	input := `local n = 0
+print(save())
+warn("saved", queue:drain(), n)
+print("count", save(), tostring(n))
+print((save()))
+print("deferred", function() return save() end)
+if n > 0 then print("plain", n) end`
+
+	expected := `local n = 0
+save()
+queue:drain()
+do local _ = save(), tostring(n) end
+do local _ = (save()) end
+if n > 0 then  end`
+
+	assert.Equal(t, expected, removeDebugStatements(input, nil, true))
+}
+
+func TestBlankDebugStatements_KeepCalls(t *testing.T) {
+	input := `local a = 1
+print(
+    "count",
+    save()
+)
+return a`
+
+	assert.Equal(t, `local a = 1
+
+
+save()
+
+return a`, blankDebugStatements(input, nil, true))
+}
+
+func TestSetKeepPatterns(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, b.SetKeepPatterns([]string{`^\[ERROR\]`, "fatal"}))
+	assert.Len(t, b.keepPatterns, 2)
+	assert.Error(t, b.SetKeepPatterns([]string{"[ERROR"}))
+}
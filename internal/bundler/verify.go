@@ -0,0 +1,146 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+)
+
+// ObfuscationCheck is a program running a test script against the resolved
+// modules, all as written or with one of them obfuscated
+type ObfuscationCheck struct {
+	Module  string // the obfuscated module, "" for the baseline
+	Program string
+}
+
+// verifyEnvironment makes runs of the test script deterministic: clocks
+// stand still, random numbers repeat, and Roblox globals the VM lacks
+// are stubs that absorb any use
+const verifyEnvironment = `-- Deterministic stub environment
+local function stub(name)
+    return setmetatable({}, {
+        __index = function(_, key) return stub(name .. "." .. tostring(key)) end,
+        __newindex = function() end,
+        __call = function() return stub(name .. "()") end,
+        __tostring = function() return name end,
+    })
+end
+if math.randomseed then math.randomseed(1) end
+if os then
+    os.time = function() return 0 end
+    os.clock = function() return 0 end
+end
+tick = function() return 0 end
+time = function() return 0 end
+wait = function() return 0, 0 end
+spawn = function(f, ...) f(...) end
+delay = function(_, f, ...) f(...) end
+if task == nil then
+    task = { wait = wait, spawn = spawn, defer = spawn, delay = delay }
+end
+game = game or stub("game")
+workspace = workspace or stub("workspace")
+script = script or stub("script")
+Instance = Instance or stub("Instance")
+
+`
+
+// ObfuscationChecks returns the baseline program, running testScript
+// against the resolved local modules as written, followed by one program
+// per local module with only that module run through passes. Diverging
+// output pins obfuscator breakage on a module. Resolve must have run
+// without obfuscation; remote modules are never obfuscated and stay as
+// they are.
+func (b *Bundler) ObfuscationChecks(passes []obfuscator.Pass, testScript string) ([]ObfuscationCheck, error) {
+	if len(b.obfuscators()) > 0 {
+		return nil, fmt.Errorf("obfuscation checks need the modules as written; resolve without obfuscation")
+	}
+
+	keys := make([]string, 0, len(b.modules))
+	for key := range b.modules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// One obfuscator renames identifiers consistently across modules, as
+	// in a bundle
+	o, err := obfuscator.NewPipeline(passes)
+	if err != nil {
+		return nil, err
+	}
+	checks := []ObfuscationCheck{{Program: b.verifyProgram(keys, "", "", testScript)}}
+	for _, key := range keys {
+		if source, ok := b.moduleSources[key]; !ok || IsURL(source) {
+			continue
+		}
+		obfuscated := o.Obfuscate(b.modules[key])
+		checks = append(checks, ObfuscationCheck{
+			Module:  key,
+			Program: b.verifyProgram(keys, key, obfuscated, testScript),
+		})
+	}
+	return checks, nil
+}
+
+// verifyProgram returns the stub environment, a require serving the
+// modules, the modules with key's source replaced by replacement when key
+// is set, and the test script
+func (b *Bundler) verifyProgram(keys []string, key, replacement, testScript string) string {
+	var out strings.Builder
+	out.WriteString(verifyEnvironment)
+	out.WriteString(`local modules, loaded = {}, {}
+local hostRequire = require
+local function require(name)
+    if loaded[name] == nil then
+        local module = modules[name]
+        if module == nil then
+            return hostRequire(name)
+        end
+        local result = module(name)
+        if result == nil then
+            result = true
+        end
+        loaded[name] = result
+    end
+    return loaded[name]
+end
+
+`)
+	for _, k := range keys {
+		source := b.modules[k]
+		if k == key {
+			source = replacement
+		}
+		out.WriteString(fmt.Sprintf("modules[\"%s\"] = function(...)\n%send\n\n", escapeString(k), indent(source)))
+	}
+	out.WriteString("-- Test script\n")
+	out.WriteString(testScript)
+	if !strings.HasSuffix(testScript, "\n") {
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// OutputDifference describes where the output of a check first departs
+// from the baseline's, or returns "" when they match
+func OutputDifference(baseline, output string) string {
+	if baseline == output {
+		return ""
+	}
+	want, got := strings.Split(baseline, "\n"), strings.Split(output, "\n")
+	for i := 0; i < max(len(want), len(got)); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d: got %q, want %q", i+1, g, w)
+		}
+	}
+	return ""
+}
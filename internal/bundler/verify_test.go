@@ -0,0 +1,59 @@
+package bundler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObfuscationChecks(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"main.lua":      "local util = require(\"util\")\nlocal log = require(\"utils.log\")\n",
+		"util.lua":      "-- helpers\nlocal counter = 0\nreturn { next = function() counter = counter + 1 return counter end }\n",
+		"utils/log.lua": "return function(msg) print(\"[log] \" .. msg) end\n",
+	})
+	_, err = b.Resolve()
+	require.NoError(t, err)
+
+	test := "local util = require(\"util\")\nprint(util.next(), util.next())"
+	checks, err := b.ObfuscationChecks(obfuscator.Presets["light"], test)
+	require.NoError(t, err)
+	require.Len(t, checks, 3)
+
+	assert.Equal(t, "", checks[0].Module)
+	assert.Contains(t, checks[0].Program, "-- Deterministic stub environment\n")
+	assert.Contains(t, checks[0].Program, "modules[\"util\"] = function(...)\n    -- helpers\n")
+	assert.True(t, strings.HasSuffix(checks[0].Program, "-- Test script\n"+test+"\n"))
+
+	assert.Equal(t, "util", checks[1].Module)
+	assert.NotContains(t, checks[1].Program, "-- helpers", "util is obfuscated")
+	assert.Contains(t, checks[1].Program, "modules[\"utils.log\"] = function(...)\n    return function(msg) print(\"[log] \" .. msg) end\n", "other modules are as written")
+	assert.Equal(t, "utils.log", checks[2].Module)
+	assert.Contains(t, checks[2].Program, "-- helpers")
+
+	for _, check := range checks {
+		_, err := parser.Parse(check.Program)
+		assert.NoError(t, err, check.Module)
+	}
+}
+
+func TestObfuscationChecks_NeedsModulesAsWritten(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetObfuscationLevel(2)
+
+	_, err = b.ObfuscationChecks(obfuscator.Presets["medium"], "")
+	assert.Error(t, err)
+}
+
+func TestOutputDifference(t *testing.T) {
+	assert.Equal(t, "", OutputDifference("1\n2\n", "1\n2\n"))
+	assert.Equal(t, `line 2: got "3", want "2"`, OutputDifference("1\n2\n", "1\n3\n"))
+	assert.Equal(t, `line 2: got "", want "2"`, OutputDifference("1\n2\n", "1\n"))
+}
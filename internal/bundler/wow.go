@@ -0,0 +1,201 @@
+package bundler
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TOC is a parsed World of Warcraft addon .toc file
+type TOC struct {
+	Header []string // metadata (## Key: Value) and comment lines, as written
+	Files  []string // listed files in load order, with forward slashes
+}
+
+// addon is the addon a .toc entry describes
+type addon struct {
+	toc      *TOC
+	files    []string // Lua files merged into the bundle, in load order
+	expanded []string // XML files whose scripts were merged
+	kept     []string // XML files with frames, relative to the addon folder
+}
+
+var (
+	// xmlFileRegex matches the Script and Include elements of addon XML
+	xmlFileRegex = regexp.MustCompile(`<(Script|Include)\s+file\s*=\s*"([^"]+)"\s*(?:/>|>\s*</(?:Script|Include)>)`)
+	// xmlWrapperRegex matches the parts of an XML file that hold no frames
+	xmlWrapperRegex = regexp.MustCompile(`<\?xml[^>]*\?>|<!--[\s\S]*?-->|</?Ui\b[^>]*>`)
+)
+
+// IsTOC reports whether path is a WoW addon .toc file
+func IsTOC(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toc")
+}
+
+// ParseTOC parses the content of a .toc file
+func ParseTOC(content string) *TOC {
+	toc := &TOC{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			toc.Header = append(toc.Header, line)
+		default:
+			toc.Files = append(toc.Files, strings.ReplaceAll(line, `\`, "/"))
+		}
+	}
+	return toc
+}
+
+// SetAddonLibPaths sets the directories searched for the files of a .toc
+// entry that the addon folder does not contain, such as a shared Ace3
+// checkout
+func (b *Bundler) SetAddonLibPaths(paths []string) {
+	b.addonLibPaths = paths
+}
+
+// resolveAddonFile returns where the file at rel, relative to dir, is read
+// from: the addon folder, then each lib path, with and without the first
+// directory of rel (Libs/AceGUI-3.0/... is found in an Ace3 checkout as
+// AceGUI-3.0/...). When none has it, the addon folder path is returned so
+// the read error names it.
+func (b *Bundler) resolveAddonFile(dir, rel string) (string, bool) {
+	file := filepath.Join(dir, filepath.FromSlash(rel))
+	if _, err := b.fs.Stat(file); err == nil {
+		return file, true
+	}
+	r, err := filepath.Rel(b.baseDir, file)
+	if err != nil || strings.HasPrefix(r, "..") {
+		return file, false
+	}
+	rel = filepath.ToSlash(r)
+
+	candidates := []string{rel}
+	if i := strings.Index(rel, "/"); i >= 0 {
+		candidates = append(candidates, rel[i+1:])
+	}
+	for _, lib := range b.addonLibPaths {
+		for _, c := range candidates {
+			candidate := filepath.Join(lib, filepath.FromSlash(c))
+			if _, err := b.fs.Stat(candidate); err == nil {
+				if b.verbose {
+					b.logf("📚 Addon library: %s -> %s\n", rel, candidate)
+				}
+				return candidate, true
+			}
+		}
+	}
+	return file, false
+}
+
+// resolveAddon reads the .toc entry and the files it lists, expanding XML
+// files that only load scripts, and returns them merged into one chunk. Each
+// file runs in a function of its own, with the addon name and table WoW
+// passes to every file as its arguments.
+func (b *Bundler) resolveAddon(content string) (string, error) {
+	b.addon = &addon{toc: ParseTOC(content)}
+	nameVar, tableVar := b.addonArgNames()
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "local %s, %s = ...\n", nameVar, tableVar)
+	var add func(dir, rel string) error
+	add = func(dir, rel string) error {
+		file, ok := b.resolveAddonFile(dir, rel)
+		if !ok {
+			return fmt.Errorf("failed to read %s listed in %s: file does not exist", rel, b.displaySource(b.entryFile))
+		}
+		data, err := b.readSource(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		if strings.EqualFold(path.Ext(rel), ".xml") {
+			src := data
+			rest := strings.TrimSpace(xmlWrapperRegex.ReplaceAllString(xmlFileRegex.ReplaceAllString(src, ""), ""))
+			if rest != "" {
+				// Frames and templates cannot be merged; the XML stays as is
+				if r, err := filepath.Rel(b.baseDir, file); err == nil && !strings.HasPrefix(r, "..") {
+					b.addon.kept = append(b.addon.kept, filepath.ToSlash(r))
+				} else {
+					b.warnf("%s defines frames and is outside the addon folder; it was left out", b.displaySource(file))
+				}
+				return nil
+			}
+			if containsString(b.addon.expanded, file) {
+				return nil
+			}
+			b.addon.expanded = append(b.addon.expanded, file)
+			for _, m := range xmlFileRegex.FindAllStringSubmatch(src, -1) {
+				if err := add(filepath.Dir(file), strings.ReplaceAll(m[2], `\`, "/")); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		key := b.displaySource(file)
+		if containsString(b.addon.files, file) {
+			return nil
+		}
+		b.addon.files = append(b.addon.files, file)
+		if b.verbose {
+			b.logf("📄 Processed addon file: %s\n", key)
+		}
+		if err := b.processFile(key, file, data, 0); err != nil {
+			return err
+		}
+		writeMergedFile(&out, key, data, nameVar, tableVar)
+		return nil
+	}
+
+	for _, rel := range b.addon.toc.Files {
+		if err := add(b.baseDir, rel); err != nil {
+			return "", err
+		}
+	}
+	return out.String(), nil
+}
+
+// writeMergedFile writes a file merged into a bundle, in a function of its
+// own called with args, so its locals and returns stay its own
+func writeMergedFile(out *strings.Builder, key, content string, args ...string) {
+	fmt.Fprintf(out, "\n-- File: %s\ndo\n(function(...)\n%s\nend)(%s)\nend\n", key, strings.TrimRight(content, "\n"), strings.Join(args, ", "))
+}
+
+// addonArgNames returns the locals holding the addon name and table
+func (b *Bundler) addonArgNames() (string, string) {
+	if b.namespace == "" {
+		return "BundleAddonName", "BundleAddonTable"
+	}
+	return b.namespace + "_BundleAddonName", b.namespace + "_BundleAddonTable"
+}
+
+// AddonTOC returns the .toc file of the rebuilt addon: the entry's metadata,
+// then luaFile holding the bundle, then the XML files with frames, which
+// load after it. It returns "" unless the entry is a .toc file.
+func (b *Bundler) AddonTOC(luaFile string) string {
+	if b.addon == nil {
+		return ""
+	}
+	var out strings.Builder
+	for _, line := range b.addon.toc.Header {
+		out.WriteString(line + "\n")
+	}
+	out.WriteString("\n" + luaFile + "\n")
+	for _, file := range b.addon.kept {
+		out.WriteString(strings.ReplaceAll(file, "/", `\`) + "\n")
+	}
+	return out.String()
+}
+
+// AddonXML returns the XML files of a .toc entry that hold frames and were
+// not merged, relative to the addon folder
+func (b *Bundler) AddonXML() []string {
+	if b.addon == nil {
+		return nil
+	}
+	return b.addon.kept
+}
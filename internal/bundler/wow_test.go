@@ -0,0 +1,77 @@
+package bundler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTOC(t *testing.T) {
+	toc := ParseTOC("## Interface: 110002\r\n## Title: My Addon\n# comment\n\nLibs\\LibStub\\LibStub.lua\r\n  Core.lua  \n")
+	assert.Equal(t, []string{"## Interface: 110002", "## Title: My Addon", "# comment"}, toc.Header)
+	assert.Equal(t, []string{"Libs/LibStub/LibStub.lua", "Core.lua"}, toc.Files)
+}
+
+func TestIsTOC(t *testing.T) {
+	assert.True(t, IsTOC("MyAddon/MyAddon.toc"))
+	assert.True(t, IsTOC("MyAddon_Vanilla.TOC"))
+	assert.False(t, IsTOC("main.lua"))
+}
+
+func newAddonBundler(t *testing.T) *Bundler {
+	b, err := NewBundler("MyAddon.toc", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{
+		"MyAddon.toc":                        "## Title: My Addon\n## SavedVariables: MyAddonDB\nLibs\\LibStub\\LibStub.lua\nLibs\\embeds.xml\nCore.lua\nFrames.xml\n",
+		"Libs/LibStub/LibStub.lua":           "LibStub = LibStub or {}\n",
+		"Libs/embeds.xml":                    "<Ui xmlns=\"http://www.blizzard.com/wow/ui/\">\n<!-- Ace3 -->\n<Include file=\"AceAddon-3.0\\AceAddon-3.0.xml\"/>\n<Script file=\"AceAddon-3.0\\AceAddon-3.0.lua\"></Script>\n</Ui>\n",
+		"ace3/AceAddon-3.0/AceAddon-3.0.xml": "<Ui>\n<Script file=\"AceAddon-3.0.lua\"/>\n</Ui>\n",
+		"ace3/AceAddon-3.0/AceAddon-3.0.lua": "local MAJOR = \"AceAddon-3.0\"\n",
+		"Core.lua":                           "local name, ns = ...\nlocal util = require(\"util\")\nns.ready = true\n",
+		"util.lua":                           "return {}\n",
+		"Frames.xml":                         "<Ui>\n<Frame name=\"MyAddonFrame\"/>\n<Script file=\"FrameCode.lua\"/>\n</Ui>\n",
+	})
+	require.NoError(t, b.SetTarget(TargetWoW))
+	b.SetAddonLibPaths([]string{"/missing", "/ace3"})
+	return b
+}
+
+func TestBundle_Addon(t *testing.T) {
+	b := newAddonBundler(t)
+	bundle, err := b.Bundle(false)
+	require.NoError(t, err)
+
+	assert.Contains(t, bundle, "local BundleAddonName, BundleAddonTable = ...\n")
+	assert.Contains(t, bundle, "-- File: Libs/LibStub/LibStub.lua\ndo\n(function(...)\nLibStub = LibStub or {}\nend)(BundleAddonName, BundleAddonTable)\nend\n")
+	assert.Equal(t, 1, strings.Count(bundle, `local MAJOR = "AceAddon-3.0"`), "a library loaded twice should be merged once")
+	assert.Contains(t, bundle, `local util = loadModule("util")`, "requires in addon files should still be bundled")
+	assert.Less(t, strings.Index(bundle, "LibStub = LibStub"), strings.Index(bundle, "ns.ready = true"), "files should keep the .toc load order")
+	assert.NotContains(t, bundle, "FrameCode.lua", "scripts of frame XML stay with the XML")
+
+	assert.Equal(t, []string{"MyAddon.toc", "/Core.lua", "/Libs/LibStub/LibStub.lua", "/Libs/embeds.xml", "/ace3/AceAddon-3.0/AceAddon-3.0.lua", "/ace3/AceAddon-3.0/AceAddon-3.0.xml", "/util.lua"}, b.GetLocalFiles())
+	assert.Equal(t, []string{"Frames.xml"}, b.AddonXML())
+	assert.Equal(t, "## Title: My Addon\n## SavedVariables: MyAddonDB\n\nMyAddon.lua\nFrames.xml\n", b.AddonTOC("MyAddon.lua"))
+}
+
+func TestBundle_AddonMissingFile(t *testing.T) {
+	b, err := NewBundler("MyAddon.toc", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"MyAddon.toc": "Libs\\AceGUI-3.0\\AceGUI-3.0.xml\n"})
+	require.NoError(t, b.SetTarget(TargetWoW))
+
+	_, err = b.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Libs/AceGUI-3.0/AceGUI-3.0.xml listed in MyAddon.toc")
+}
+
+func TestAddonTOC_LuaEntry(t *testing.T) {
+	b, err := NewBundler("main.lua", false, false)
+	require.NoError(t, err)
+	b.SetFileSystem(MemoryFS{"main.lua": "print(1)\n"})
+	_, err = b.Resolve()
+	require.NoError(t, err)
+	assert.Empty(t, b.AddonTOC("main.lua"))
+	assert.Nil(t, b.AddonXML())
+}
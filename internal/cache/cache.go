@@ -2,21 +2,32 @@ package cache
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 const (
 	cacheDirName = ".lua-bundler-cache"
 	cacheExpiry  = 24 * time.Hour // Cache expires after 24 hours
+	hashSuffix   = ".sha256"      // file next to an entry holding its content's SHA-256
 )
 
+// ErrCorrupt is returned by Get for an entry whose content no longer
+// matches the hash stored with it; the entry is removed
+var ErrCorrupt = errors.New("cache entry does not match its stored hash")
+
 type Cache struct {
 	cacheDir string
 	enabled  bool
+	remote   Backend // shared store, nil for none
+	mode     string  // Mode* of the remote
+	offline  bool    // entries never expire and the remote is not used
 }
 
 // NewCache creates a new cache instance
@@ -50,7 +61,29 @@ func (c *Cache) generateCacheKey(url string) string {
 	return hex.EncodeToString(hash[:]) + ".lua"
 }
 
-// Get retrieves content from cache if it exists and is not expired
+// SetRemote shares the cache through backend: in ModeRead, local misses
+// are looked up there and kept locally; in ModeWrite, entries set are also
+// stored there; ModeReadWrite does both
+func (c *Cache) SetRemote(backend Backend, mode string) error {
+	switch mode {
+	case ModeRead, ModeWrite, ModeReadWrite:
+	default:
+		return fmt.Errorf("unknown remote cache mode %q (want %s, %s or %s)", mode, ModeRead, ModeWrite, ModeReadWrite)
+	}
+	c.remote, c.mode = backend, mode
+	return nil
+}
+
+// SetOffline makes entries never expire and stops Get from looking up the
+// remote, for builds that must not touch the network
+func (c *Cache) SetOffline(offline bool) {
+	c.offline = offline
+}
+
+// Get retrieves content from cache if it exists and is not expired. Entries
+// are checked against their stored SHA-256: a mismatch is a miss returning
+// ErrCorrupt, and an entry without a hash, as older versions wrote, is a
+// plain miss. Local misses are looked up in a readable remote.
 func (c *Cache) Get(url string) (string, bool, error) {
 	if !c.enabled {
 		return "", false, nil
@@ -58,7 +91,15 @@ func (c *Cache) Get(url string) (string, bool, error) {
 
 	cacheKey := c.generateCacheKey(url)
 	cachePath := filepath.Join(c.cacheDir, cacheKey)
+	content, found, err := c.getLocal(url, cachePath)
+	if found || err != nil || c.remote == nil || c.mode == ModeWrite || c.offline {
+		return content, found, err
+	}
+	return c.getRemote(url, cacheKey, cachePath)
+}
 
+// getLocal reads the entry at cachePath
+func (c *Cache) getLocal(url, cachePath string) (string, bool, error) {
 	// Check if cache file exists
 	info, err := os.Stat(cachePath)
 	if os.IsNotExist(err) {
@@ -69,9 +110,9 @@ func (c *Cache) Get(url string) (string, bool, error) {
 	}
 
 	// Check if cache is expired
-	if time.Since(info.ModTime()) > cacheExpiry {
+	if !c.offline && time.Since(info.ModTime()) > cacheExpiry {
 		// Delete expired cache
-		os.Remove(cachePath)
+		c.remove(cachePath)
 		return "", false, nil
 	}
 
@@ -80,10 +121,49 @@ func (c *Cache) Get(url string) (string, bool, error) {
 	if err != nil {
 		return "", false, err
 	}
+	stored, err := os.ReadFile(cachePath + hashSuffix)
+	if os.IsNotExist(err) {
+		c.remove(cachePath)
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if strings.TrimSpace(string(stored)) != hash(content) {
+		c.remove(cachePath)
+		return "", false, fmt.Errorf("%s: %w", url, ErrCorrupt)
+	}
 
 	return string(content), true, nil
 }
 
+// getRemote reads the entry of cacheKey from the remote, keeping a local
+// copy at cachePath that expires when the remote one does
+func (c *Cache) getRemote(url, cacheKey, cachePath string) (string, bool, error) {
+	content, modified, found, err := c.remote.Get(cacheKey)
+	if err != nil {
+		return "", false, fmt.Errorf("remote cache: %w", err)
+	}
+	if !found || (!modified.IsZero() && time.Since(modified) > cacheExpiry) {
+		return "", false, nil
+	}
+	stored, _, found, err := c.remote.Get(cacheKey + hashSuffix)
+	if err != nil {
+		return "", false, fmt.Errorf("remote cache: %w", err)
+	}
+	if !found {
+		return "", false, nil
+	}
+	if strings.TrimSpace(string(stored)) != hash(content) {
+		return "", false, fmt.Errorf("%s (remote): %w", url, ErrCorrupt)
+	}
+
+	if err := c.writeLocal(cachePath, content); err == nil && !modified.IsZero() {
+		os.Chtimes(cachePath, modified, modified)
+	}
+	return string(content), true, nil
+}
+
 // Set stores content in cache
 func (c *Cache) Set(url string, content string) error {
 	if !c.enabled {
@@ -92,16 +172,71 @@ func (c *Cache) Set(url string, content string) error {
 
 	cacheKey := c.generateCacheKey(url)
 	cachePath := filepath.Join(c.cacheDir, cacheKey)
+	if err := c.writeLocal(cachePath, []byte(content)); err != nil {
+		return err
+	}
 
-	// Write content to cache file
-	if err := os.WriteFile(cachePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write cache: %w", err)
+	if c.remote != nil && c.mode != ModeRead {
+		if err := c.remote.Put(cacheKey, []byte(content)); err != nil {
+			return fmt.Errorf("failed to write remote cache: %w", err)
+		}
+		if err := c.remote.Put(cacheKey+hashSuffix, []byte(hash([]byte(content))+"\n")); err != nil {
+			return fmt.Errorf("failed to write remote cache: %w", err)
+		}
 	}
+	return nil
+}
 
+// writeLocal writes content to cachePath, then the hash Get verifies it
+// against
+func (c *Cache) writeLocal(cachePath string, content []byte) error {
+	if err := writeFile(cachePath, content); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := writeFile(cachePath+hashSuffix, []byte(hash(content)+"\n")); err != nil {
+		c.remove(cachePath)
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
 	return nil
 }
 
-// Clear removes all cached files
+// writeFile replaces the file at path with data through a temporary file,
+// so builds and processes reading the cache meanwhile never see it half
+// written
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// remove deletes an entry and its hash
+func (c *Cache) remove(cachePath string) {
+	os.Remove(cachePath)
+	os.Remove(cachePath + hashSuffix)
+}
+
+// hash returns the hex SHA-256 of content
+func hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Clear removes all cached files; a remote keeps its entries
 func (c *Cache) Clear() error {
 	if !c.enabled {
 		return nil
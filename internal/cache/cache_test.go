@@ -1,8 +1,11 @@
 package cache
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -109,6 +112,46 @@ func TestCacheExpiry(t *testing.T) {
 	c.Clear()
 }
 
+func TestCacheOffline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	c, err := NewCache(true)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	if err := c.SetRemote(unreachableBackend{t}, ModeReadWrite); err != nil {
+		t.Fatalf("SetRemote failed: %v", err)
+	}
+	c.SetOffline(true)
+
+	testURL := "https://example.com/offline-test.lua"
+	if err := c.writeLocal(filepath.Join(c.GetCacheDir(), c.generateCacheKey(testURL)), []byte("return 1")); err != nil {
+		t.Fatalf("writeLocal failed: %v", err)
+	}
+	oldTime := time.Now().Add(-30 * 24 * time.Hour)
+	os.Chtimes(filepath.Join(c.GetCacheDir(), c.generateCacheKey(testURL)), oldTime, oldTime)
+
+	content, found, err := c.Get(testURL)
+	if err != nil || !found || content != "return 1" {
+		t.Errorf("Offline caches should serve expired entries, got %q found=%v err=%v", content, found, err)
+	}
+	if _, found, err := c.Get("https://example.com/missing.lua"); err != nil || found {
+		t.Errorf("Expected a plain miss, got found=%v err=%v", found, err)
+	}
+}
+
+// unreachableBackend fails tests that use it
+type unreachableBackend struct{ t *testing.T }
+
+func (u unreachableBackend) Get(key string) ([]byte, time.Time, bool, error) {
+	u.t.Errorf("Unexpected remote read of %s", key)
+	return nil, time.Time{}, false, errors.New("unreachable")
+}
+
+func (u unreachableBackend) Put(key string, data []byte) error {
+	u.t.Errorf("Unexpected remote write of %s", key)
+	return errors.New("unreachable")
+}
+
 func TestCacheDisabled(t *testing.T) {
 	c, err := NewCache(false)
 	if err != nil {
@@ -199,3 +242,90 @@ func TestGenerateCacheKey(t *testing.T) {
 	// Clean up
 	c.Clear()
 }
+
+func TestCacheIntegrity(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	c, err := NewCache(true)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+
+	testURL := "https://example.com/tampered.lua"
+	if err := c.Set(testURL, "return 1"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	cachePath := filepath.Join(c.GetCacheDir(), c.generateCacheKey(testURL))
+	if _, err := os.Stat(cachePath + hashSuffix); err != nil {
+		t.Fatalf("Set should store the content's hash: %v", err)
+	}
+
+	// Modify the entry behind the cache's back
+	if err := os.WriteFile(cachePath, []byte("return evil()"), 0644); err != nil {
+		t.Fatalf("Failed to modify cache file: %v", err)
+	}
+	_, found, err := c.Get(testURL)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt, got %v", err)
+	}
+	if found {
+		t.Error("Tampered entry should not be found")
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Error("Tampered entry should be removed")
+	}
+
+	// Entries without a hash are misses
+	if err := os.WriteFile(cachePath, []byte("return 1"), 0644); err != nil {
+		t.Fatalf("Failed to write cache file: %v", err)
+	}
+	_, found, err = c.Get(testURL)
+	if err != nil || found {
+		t.Errorf("Entry without a hash should be a plain miss, got found=%v err=%v", found, err)
+	}
+}
+
+func TestCacheConcurrentSetAndGet(t *testing.T) {
+	c := &Cache{cacheDir: t.TempDir(), enabled: true}
+	testURL := "https://example.com/shared.lua"
+	testContent := strings.Repeat("print('shared')\n", 4096)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.Set(testURL, testContent); err != nil {
+				t.Errorf("Set failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// Entries are replaced whole, so a reader never sees one half written
+			content, found, err := c.Get(testURL)
+			if err != nil {
+				t.Errorf("Get failed: %v", err)
+			}
+			if found && content != testContent {
+				t.Errorf("Get returned a partial entry of %d bytes", len(content))
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(c.cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+	info, err := os.Stat(filepath.Join(c.cacheDir, c.generateCacheKey(testURL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Modes of a remote cache
+const (
+	ModeRead      = "read"      // read-through: local misses are looked up remotely
+	ModeWrite     = "write"     // write-through: new entries are also stored remotely
+	ModeReadWrite = "readwrite" // both
+)
+
+// TokenEnv names the environment variable holding the bearer token sent to
+// HTTP remote caches
+const TokenEnv = "LUA_BUNDLER_CACHE_TOKEN"
+
+// Backend is a store shared by several machines that cache entries are
+// read through and written through
+type Backend interface {
+	// Get returns the object stored under key and when it was stored (zero
+	// when unknown), or false when there is none
+	Get(key string) ([]byte, time.Time, bool, error)
+	// Put stores data under key
+	Put(key string, data []byte) error
+}
+
+// NewBackend returns the backend of a remote cache URL: http(s)://host/path
+// for a server answering GET and PUT under that path, or s3://bucket/prefix
+// for an S3-compatible bucket. S3 credentials, region and endpoint come
+// from the usual AWS_* environment variables.
+func NewBackend(rawURL string, client *http.Client) (Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote cache URL %q: %w", rawURL, err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPBackend{BaseURL: strings.TrimSuffix(rawURL, "/"), Token: os.Getenv(TokenEnv), Client: client}, nil
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid remote cache URL %q: missing bucket", rawURL)
+		}
+		s3 := &S3Backend{
+			Bucket:       u.Host,
+			Prefix:       strings.Trim(u.Path, "/"),
+			Region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+			Endpoint:     strings.TrimSuffix(firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"), "/"),
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			Client:       client,
+		}
+		if s3.AccessKey == "" || s3.SecretKey == "" {
+			return nil, fmt.Errorf("remote cache %s needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", rawURL)
+		}
+		if s3.Region == "" {
+			s3.Region = "us-east-1"
+		}
+		if s3.Endpoint == "" {
+			s3.Endpoint = "https://s3." + s3.Region + ".amazonaws.com"
+		}
+		return s3, nil
+	}
+	return nil, fmt.Errorf("unsupported remote cache URL %q (want http://, https:// or s3://)", rawURL)
+}
+
+// HTTPBackend stores objects on a server under BaseURL/key, with GET
+// answering 404 for missing objects
+type HTTPBackend struct {
+	BaseURL string
+	Token   string // sent as a bearer token when set
+	Client  *http.Client
+}
+
+// Get fetches BaseURL/key
+func (h *HTTPBackend) Get(key string) ([]byte, time.Time, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, h.BaseURL+"/"+key, nil)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	h.authorize(req)
+	return fetchObject(h.Client, req)
+}
+
+// Put uploads data to BaseURL/key
+func (h *HTTPBackend) Put(key string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, h.BaseURL+"/"+key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	h.authorize(req)
+	return storeObject(h.Client, req)
+}
+
+func (h *HTTPBackend) authorize(req *http.Request) {
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+}
+
+// S3Backend stores objects in an S3-compatible bucket, addressed path-style
+// so providers other than AWS work too. Requests are signed with AWS
+// Signature Version 4.
+type S3Backend struct {
+	Bucket       string
+	Prefix       string // key prefix without slashes at either end, "" for none
+	Region       string
+	Endpoint     string // scheme and host, such as https://s3.us-east-1.amazonaws.com
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // for temporary credentials, "" otherwise
+	Client       *http.Client
+}
+
+// Get fetches the object of key
+func (s *S3Backend) Get(key string) ([]byte, time.Time, bool, error) {
+	req, err := s.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	return fetchObject(s.Client, req)
+}
+
+// Put uploads data as the object of key
+func (s *S3Backend) Put(key string, data []byte) error {
+	req, err := s.request(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	return storeObject(s.Client, req)
+}
+
+// request returns a signed request for the object of key
+func (s *S3Backend) request(method, key string, body []byte) (*http.Request, error) {
+	object := key
+	if s.Prefix != "" {
+		object = s.Prefix + "/" + key
+	}
+	req, err := http.NewRequest(method, s.Endpoint+"/"+s.Bucket+"/"+object, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body, req.ContentLength = nil, 0
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	signV4(req, body, s.AccessKey, s.SecretKey, s.Region, time.Now())
+	return req, nil
+}
+
+// signV4 signs req for S3 with AWS Signature Version 4, covering the host,
+// the payload hash and every header already set
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region string, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, uriEncodePath(req.URL.Path), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), amzDate[:8])
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncodePath percent-encodes every byte of path but unreserved
+// characters and slashes, as Signature Version 4 expects
+func uriEncodePath(path string) string {
+	var out strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '.' || c == '_' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			out.WriteByte(c)
+		} else {
+			fmt.Fprintf(&out, "%%%02X", c)
+		}
+	}
+	return out.String()
+}
+
+// fetchObject sends a GET request, treating 404 as a missing object
+func fetchObject(client *http.Client, req *http.Request) ([]byte, time.Time, bool, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, time.Time{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, false, fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return data, modified, true, nil
+}
+
+// storeObject sends a PUT request
+func storeObject(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("PUT %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// statsFile in the cache directory accumulates the stats of every build
+const statsFile = "stats.json"
+
+// Stats counts how downloads of remote scripts were served
+type Stats struct {
+	Builds        int           `json:"builds"`
+	Hits          int           `json:"hits"`          // served from the cache
+	Misses        int           `json:"misses"`        // not cached, or expired
+	Revalidations int           `json:"revalidations"` // cached copies failing their hash or the lockfile, downloaded again
+	Downloads     int           `json:"downloads"`     // successful network downloads
+	CacheBytes    int64         `json:"cacheBytes"`    // bytes served from the cache
+	NetworkBytes  int64         `json:"networkBytes"`  // bytes downloaded
+	NetworkTime   time.Duration `json:"networkTime"`   // time spent downloading, in nanoseconds
+	Since         time.Time     `json:"since,omitempty"`
+}
+
+// Lookups returns how many downloads consulted the cache
+func (s Stats) Lookups() int {
+	return s.Hits + s.Misses + s.Revalidations
+}
+
+// HitRate returns the share of lookups served from the cache, from 0 to 1
+func (s Stats) HitRate() float64 {
+	if s.Lookups() == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Lookups())
+}
+
+// AverageDownload returns the mean time of a network download, or 0 when
+// there was none
+func (s Stats) AverageDownload() time.Duration {
+	if s.Downloads == 0 {
+		return 0
+	}
+	return s.NetworkTime / time.Duration(s.Downloads)
+}
+
+// TimeSaved estimates the time hits saved, each worth a download taking
+// average
+func (s Stats) TimeSaved(average time.Duration) time.Duration {
+	return average * time.Duration(s.Hits)
+}
+
+// Add adds the counts of other to s
+func (s *Stats) Add(other Stats) {
+	s.Builds += other.Builds
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Revalidations += other.Revalidations
+	s.Downloads += other.Downloads
+	s.CacheBytes += other.CacheBytes
+	s.NetworkBytes += other.NetworkBytes
+	s.NetworkTime += other.NetworkTime
+	if s.Since.IsZero() || (!other.Since.IsZero() && other.Since.Before(s.Since)) {
+		s.Since = other.Since
+	}
+}
+
+// Stats returns the stats recorded so far, zero when there are none
+func (c *Cache) Stats() (Stats, error) {
+	var stats Stats
+	if !c.enabled {
+		return stats, nil
+	}
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, statsFile))
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("failed to read cache stats: %w", err)
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("failed to read cache stats: %w", err)
+	}
+	return stats, nil
+}
+
+// RecordStats adds the stats of a build to those recorded and returns the
+// new totals
+func (c *Cache) RecordStats(build Stats) (Stats, error) {
+	total, err := c.Stats()
+	if err != nil || !c.enabled {
+		return total, err
+	}
+	build.Builds = 1
+	if build.Since.IsZero() {
+		build.Since = time.Now().UTC()
+	}
+	total.Add(build)
+	data, err := json.MarshalIndent(total, "", "  ")
+	if err != nil {
+		return total, err
+	}
+	if err := writeFile(filepath.Join(c.cacheDir, statsFile), append(data, '\n')); err != nil {
+		return total, fmt.Errorf("failed to write cache stats: %w", err)
+	}
+	return total, nil
+}
+
+// ResetStats forgets the recorded stats
+func (c *Cache) ResetStats() error {
+	if !c.enabled {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(c.cacheDir, statsFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to reset cache stats: %w", err)
+	}
+	return nil
+}
+
+// Usage returns the number of cached entries and their size on disk
+func (c *Cache) Usage() (int, int64, error) {
+	if !c.enabled {
+		return 0, 0, nil
+	}
+	entries, err := os.ReadDir(c.cacheDir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	count, size := 0, int64(0)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == statsFile {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size += info.Size()
+		if !strings.HasSuffix(entry.Name(), hashSuffix) {
+			count++
+		}
+	}
+	return count, size, nil
+}
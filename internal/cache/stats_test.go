@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordStats(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	c, err := NewCache(true)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+
+	stats, err := c.Stats()
+	if err != nil || stats.Builds != 0 {
+		t.Fatalf("Expected no stats yet, got %+v err=%v", stats, err)
+	}
+
+	first := Stats{Hits: 1, Misses: 1, Downloads: 1, CacheBytes: 100, NetworkBytes: 200, NetworkTime: 400 * time.Millisecond}
+	if _, err := c.RecordStats(first); err != nil {
+		t.Fatalf("RecordStats failed: %v", err)
+	}
+	second := Stats{Hits: 2, Revalidations: 1, Downloads: 1, NetworkBytes: 50, NetworkTime: 200 * time.Millisecond}
+	total, err := c.RecordStats(second)
+	if err != nil {
+		t.Fatalf("RecordStats failed: %v", err)
+	}
+
+	stats, err = c.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats != total {
+		t.Errorf("Stored stats %+v differ from the returned totals %+v", stats, total)
+	}
+	if stats.Builds != 2 || stats.Hits != 3 || stats.Misses != 1 || stats.Revalidations != 1 || stats.NetworkBytes != 250 {
+		t.Errorf("Unexpected totals %+v", stats)
+	}
+	if stats.Since.IsZero() {
+		t.Error("Since should be set")
+	}
+	if got := stats.HitRate(); got != 0.6 {
+		t.Errorf("HitRate = %v, want 0.6", got)
+	}
+	if got := stats.AverageDownload(); got != 300*time.Millisecond {
+		t.Errorf("AverageDownload = %v, want 300ms", got)
+	}
+	if got := stats.TimeSaved(stats.AverageDownload()); got != 900*time.Millisecond {
+		t.Errorf("TimeSaved = %v, want 900ms", got)
+	}
+
+	if err := c.ResetStats(); err != nil {
+		t.Fatalf("ResetStats failed: %v", err)
+	}
+	if stats, _ := c.Stats(); stats.Builds != 0 {
+		t.Errorf("Expected no stats after a reset, got %+v", stats)
+	}
+	if err := c.ResetStats(); err != nil {
+		t.Errorf("Resetting twice should not fail: %v", err)
+	}
+}
+
+func TestStatsEmpty(t *testing.T) {
+	var stats Stats
+	if stats.HitRate() != 0 || stats.AverageDownload() != 0 {
+		t.Errorf("Empty stats should have no hit rate nor average, got %v and %v", stats.HitRate(), stats.AverageDownload())
+	}
+}
+
+func TestUsage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	c, err := NewCache(true)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	c.Set("https://example.com/a.lua", "return 1")
+	c.Set("https://example.com/b.lua", "return 22")
+	c.RecordStats(Stats{Hits: 1})
+
+	entries, size, err := c.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if entries != 2 {
+		t.Errorf("Expected 2 entries, got %d", entries)
+	}
+	// Both contents and their hash lines, but not the stats
+	if want := int64(len("return 1") + len("return 22") + 2*len(hash(nil)+"\n")); size != want {
+		t.Errorf("Expected %d bytes, got %d", want, size)
+	}
+}
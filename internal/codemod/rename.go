@@ -0,0 +1,180 @@
+package codemod
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Renamer performs scope-aware renames of a variable. Every binding named
+// From is renamed, locals and globals alike, unless the new name would be
+// captured by or shadow another variable. With Fields, field and method names
+// (t.From, t:From(), {From = ...}) are renamed too; those cannot be checked
+// for conflicts.
+type Renamer struct {
+	From   string
+	To     string
+	Fields bool
+}
+
+// NewRenamer validates the names and returns a renamer
+func NewRenamer(from, to string, fields bool) (*Renamer, error) {
+	for _, name := range []string{from, to} {
+		if !identifierRegex.MatchString(name) || parser.IsKeyword(name) {
+			return nil, fmt.Errorf("%q is not a valid Lua identifier", name)
+		}
+	}
+	if from == to {
+		return nil, fmt.Errorf("--symbol and --to are the same (%s)", from)
+	}
+	return &Renamer{From: from, To: to, Fields: fields}, nil
+}
+
+// RenameFiles renames the symbol across sources (file -> content). Globals are
+// shared between files, so a global From cannot be renamed when any file
+// already uses a global To. Nothing is returned for files without changes.
+func (r *Renamer) RenameFiles(sources map[string]string) (map[string]string, map[string][]Change, error) {
+	files := make([]string, 0, len(sources))
+	for file := range sources {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	chunks := make(map[string]*parser.Chunk, len(files))
+	var fromGlobal, toGlobal string
+	for _, file := range files {
+		chunk, err := parser.Parse(sources[file])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+		chunks[file] = chunk
+		for _, b := range chunk.Bindings {
+			if b.Global() && b.Name == r.From && fromGlobal == "" {
+				fromGlobal = file
+			}
+			if b.Global() && b.Name == r.To && toGlobal == "" {
+				toGlobal = file
+			}
+		}
+	}
+	if fromGlobal != "" && toGlobal != "" {
+		return nil, nil, fmt.Errorf("cannot rename global %s (used in %s): global %s already exists (used in %s)", r.From, fromGlobal, r.To, toGlobal)
+	}
+
+	outputs := make(map[string]string)
+	changes := make(map[string][]Change)
+	for _, file := range files {
+		out, fileChanges, err := r.rename(file, sources[file], chunks[file])
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(fileChanges) > 0 {
+			outputs[file] = out
+			changes[file] = fileChanges
+		}
+	}
+	return outputs, changes, nil
+}
+
+func (r *Renamer) rename(file, src string, chunk *parser.Chunk) (string, []Change, error) {
+	var edits []parser.Span
+	for _, b := range chunk.Bindings {
+		if b.Name != r.From {
+			continue
+		}
+		if err := r.checkConflicts(file, src, chunk, b); err != nil {
+			return src, nil, err
+		}
+		for _, id := range b.Idents {
+			edits = append(edits, id.Span)
+		}
+	}
+
+	if r.Fields {
+		parser.Walk(chunk, func(n parser.Node) bool {
+			switch n := n.(type) {
+			case *parser.FieldExpr:
+				edits = r.appendName(edits, n.Name)
+			case *parser.MethodCallExpr:
+				edits = r.appendName(edits, n.Name)
+			case *parser.FunctionStmt:
+				if n.Method != nil {
+					edits = r.appendName(edits, *n.Method)
+				}
+			case *parser.TableField:
+				if n.Kind == parser.NamedField {
+					edits = r.appendName(edits, n.Name)
+				}
+			}
+			return true
+		})
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+
+	var out strings.Builder
+	var changes []Change
+	last := 0
+	for _, span := range edits {
+		out.WriteString(src[last:span.Start])
+		out.WriteString(r.To)
+		last = span.End
+		changes = append(changes, Change{Line: lineAt(src, span.Start), Old: r.From, New: r.To})
+	}
+	out.WriteString(src[last:])
+	return out.String(), changes, nil
+}
+
+func (r *Renamer) appendName(edits []parser.Span, name parser.Token) []parser.Span {
+	if name.Value == r.From {
+		edits = append(edits, parser.Span{Start: name.Start, End: name.End})
+	}
+	return edits
+}
+
+// checkConflicts makes sure that after renaming b every use of b still refers
+// to b, and no use of another variable named To starts referring to b
+func (r *Renamer) checkConflicts(file, src string, chunk *parser.Chunk, b *parser.Binding) error {
+	for _, id := range b.Idents {
+		other := id.Scope.Lookup(r.To, id.Seq)
+		if other != nil && (b.Global() || other.Seq > b.Seq) {
+			return fmt.Errorf("%s:%d: %s would be captured by local %s declared on line %d",
+				file, lineAt(src, id.Start), r.From, r.To, declLine(src, other))
+		}
+	}
+
+	for _, other := range chunk.Bindings {
+		if other.Name != r.To {
+			continue
+		}
+		if b.Global() && other.Global() {
+			return fmt.Errorf("%s: cannot rename global %s: global %s already exists", file, r.From, r.To)
+		}
+		for _, id := range other.Idents {
+			if id == other.Decl {
+				continue
+			}
+			if b.VisibleAt(id.Scope, id.Seq) && (other.Global() || other.Seq < b.Seq) {
+				return fmt.Errorf("%s:%d: renamed %s would shadow %s used here",
+					file, lineAt(src, id.Start), r.From, r.To)
+			}
+		}
+	}
+	return nil
+}
+
+func declLine(src string, b *parser.Binding) int {
+	if b.Decl == nil {
+		return lineAt(src, b.Scope.Start)
+	}
+	return lineAt(src, b.Decl.Start)
+}
+
+func lineAt(src string, offset int) int {
+	return strings.Count(src[:offset], "\n") + 1
+}
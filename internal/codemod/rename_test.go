@@ -0,0 +1,126 @@
+package codemod
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRenamer_Invalid(t *testing.T) {
+	for _, pair := range [][2]string{{"", "b"}, {"a", "end"}, {"a", "1x"}, {"a", "a"}} {
+		_, err := NewRenamer(pair[0], pair[1], false)
+		assert.Error(t, err, "rename %q -> %q should be rejected", pair[0], pair[1])
+	}
+}
+
+func TestRenameFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields bool
+		src    string
+		want   string
+	}{
+		{
+			name: "locals and uses",
+			src: `local Old = require("old")
+local function f(Old) return Old end
+print(Old, t.Old, "Old") -- Old`,
+			want: `local New = require("old")
+local function f(New) return New end
+print(New, t.Old, "Old") -- Old`,
+		},
+		{
+			name: "globals",
+			src:  "function Old() end\nOld()",
+			want: "function New() end\nNew()",
+		},
+		{
+			name:   "fields",
+			fields: true,
+			src: `local M = {Old = 1}
+function M.Old() end
+function M:Old() end
+M:Old() print(M.Old, M["Old"])`,
+			want: `local M = {New = 1}
+function M.New() end
+function M:New() end
+M:New() print(M.New, M["Old"])`,
+		},
+		{
+			name: "outer New shadowed before use is fine",
+			src:  "local New = 1\nprint(New)\nlocal Old = 2\nprint(Old)",
+			want: "local New = 1\nprint(New)\nlocal New = 2\nprint(New)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRenamer("Old", "New", tt.fields)
+			require.NoError(t, err)
+
+			outputs, changes, err := r.RenameFiles(map[string]string{"main.lua": tt.src})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, outputs["main.lua"])
+			assert.NotEmpty(t, changes["main.lua"])
+		})
+	}
+}
+
+func TestRenameFiles_Conflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources map[string]string
+		want    string
+	}{
+		{
+			name:    "captured by inner local",
+			sources: map[string]string{"main.lua": "local Old = 1\ndo\n\tlocal New = 2\n\tprint(Old)\nend"},
+			want:    "main.lua:4: Old would be captured by local New declared on line 3",
+		},
+		{
+			name:    "shadows outer local",
+			sources: map[string]string{"main.lua": "local New = 1\nlocal Old = 2\nprint(New)"},
+			want:    "main.lua:3: renamed Old would shadow New used here",
+		},
+		{
+			name:    "shadows global",
+			sources: map[string]string{"main.lua": "local Old = 1\nprint(New)"},
+			want:    "main.lua:2: renamed Old would shadow New used here",
+		},
+		{
+			name: "global exists in another module",
+			sources: map[string]string{
+				"a.lua": "Old = 1",
+				"b.lua": "print(New)",
+			},
+			want: "cannot rename global Old (used in a.lua): global New already exists (used in b.lua)",
+		},
+		{
+			name:    "parse error",
+			sources: map[string]string{"main.lua": "local Old = "},
+			want:    "main.lua: line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRenamer("Old", "New", false)
+			require.NoError(t, err)
+
+			_, _, err = r.RenameFiles(tt.sources)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestRenameFiles_Unchanged(t *testing.T) {
+	r, err := NewRenamer("Old", "New", false)
+	require.NoError(t, err)
+
+	outputs, changes, err := r.RenameFiles(map[string]string{"main.lua": "print('Old')"})
+	require.NoError(t, err)
+	assert.Empty(t, outputs)
+	assert.Empty(t, changes)
+}
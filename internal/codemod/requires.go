@@ -0,0 +1,177 @@
+package codemod
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Require path conventions understood by RequireRewriter
+const (
+	StyleDot   = "dot"   // modules.tasks.cook, resolved from the project root
+	StyleSlash = "slash" // /modules/tasks/cook, ./cook.lua, ../util
+)
+
+// RequireRewriter rewrites require paths from one convention to another.
+// When From and To are not style names, paths starting with From (followed
+// by "/", "." or nothing) have that prefix replaced by To.
+type RequireRewriter struct {
+	From string
+	To   string
+	Root string // project root that dot paths and /paths resolve from
+}
+
+// Change is a single rewritten require path
+type Change struct {
+	Line int
+	Old  string
+	New  string
+}
+
+// NewRequireRewriter validates from/to and returns a rewriter for root
+func NewRequireRewriter(from, to, root string) (*RequireRewriter, error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("both --from and --to are required")
+	}
+	if from == to {
+		return nil, fmt.Errorf("--from and --to are the same (%s)", from)
+	}
+	if isStyle(from) != isStyle(to) {
+		return nil, fmt.Errorf("cannot rewrite between a style and a prefix (%s -> %s); use %q or %q for both, or two path prefixes", from, to, StyleDot, StyleSlash)
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve project root: %w", err)
+	}
+	return &RequireRewriter{From: from, To: to, Root: absRoot}, nil
+}
+
+func isStyle(s string) bool {
+	return s == StyleDot || s == StyleSlash
+}
+
+// RewritePath returns the new require path for modulePath as written in file,
+// or false if it does not apply or cannot be expressed in the target style
+func (r *RequireRewriter) RewritePath(file, modulePath string) (string, bool) {
+	if strings.Contains(modulePath, "://") || strings.Contains(modulePath, "::") {
+		return "", false
+	}
+
+	switch {
+	case r.From == StyleDot && r.To == StyleSlash:
+		if !isDotPath(modulePath) {
+			return "", false
+		}
+		return "/" + strings.ReplaceAll(modulePath, ".", "/"), true
+	case r.From == StyleSlash && r.To == StyleDot:
+		return r.slashToDot(file, modulePath)
+	}
+
+	if modulePath == r.From {
+		return r.To, true
+	}
+	if strings.HasPrefix(modulePath, r.From) {
+		rest := modulePath[len(r.From):]
+		if strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, ".") {
+			return r.To + rest, true
+		}
+	}
+	return "", false
+}
+
+// isDotPath mirrors the bundler's rule for dot-separated paths from the root
+func isDotPath(modulePath string) bool {
+	return strings.Contains(modulePath, ".") &&
+		!strings.Contains(modulePath, "/") &&
+		!strings.HasSuffix(modulePath, ".lua")
+}
+
+func (r *RequireRewriter) slashToDot(file, modulePath string) (string, bool) {
+	if !strings.Contains(modulePath, "/") && !strings.HasSuffix(modulePath, ".lua") {
+		return "", false
+	}
+
+	var target string
+	if strings.HasPrefix(modulePath, "/") {
+		target = filepath.Join(r.Root, modulePath[1:])
+	} else {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return "", false
+		}
+		target = filepath.Join(filepath.Dir(absFile), modulePath)
+	}
+
+	rel, err := filepath.Rel(r.Root, strings.TrimSuffix(target, ".lua"))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for _, seg := range segments {
+		if seg == "" || strings.Contains(seg, ".") {
+			return "", false
+		}
+	}
+
+	// A single name resolves next to the requiring file, so it only means
+	// the same module for files directly in the root
+	if len(segments) == 1 {
+		absFile, err := filepath.Abs(file)
+		if err != nil || filepath.Dir(absFile) != r.Root {
+			return "", false
+		}
+	}
+	return strings.Join(segments, "."), true
+}
+
+// RewriteSource rewrites the require paths in src, which was read from file.
+// Quote style and everything outside the rewritten strings is preserved.
+func (r *RequireRewriter) RewriteSource(file, src string) (string, []Change, error) {
+	tokens, err := parser.Tokenize(src)
+	if err != nil {
+		return src, nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	var out strings.Builder
+	var changes []Change
+	last := 0
+	for _, call := range parser.FindRequires(tokens) {
+		newPath, ok := r.RewritePath(file, call.Path)
+		if !ok || newPath == call.Path {
+			continue
+		}
+		quote := call.Token.Value[:1]
+		out.WriteString(src[last:call.Token.Start])
+		out.WriteString(quote + newPath + quote)
+		last = call.Token.End
+		changes = append(changes, Change{Line: call.Token.Line, Old: call.Path, New: newPath})
+	}
+	out.WriteString(src[last:])
+	return out.String(), changes, nil
+}
+
+// Diff renders a unified-style diff of the changed lines between old and
+// new, which must have the same number of lines
+func Diff(name, old, new string) string {
+	oldLines := strings.Split(old, "\n")
+	newLines := strings.Split(new, "\n")
+	if len(oldLines) != len(newLines) {
+		return ""
+	}
+
+	var out strings.Builder
+	for i := range oldLines {
+		if oldLines[i] == newLines[i] {
+			continue
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		fmt.Fprintf(&out, "@@ -%d +%d @@\n-%s\n+%s\n", i+1, i+1, oldLines[i], newLines[i])
+	}
+	return out.String()
+}
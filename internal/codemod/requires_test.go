@@ -0,0 +1,90 @@
+package codemod
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequireRewriter_Invalid(t *testing.T) {
+	_, err := NewRequireRewriter("", "slash", ".")
+	assert.Error(t, err, "missing --from should fail")
+
+	_, err = NewRequireRewriter("dot", "dot", ".")
+	assert.Error(t, err, "identical conventions should fail")
+
+	_, err = NewRequireRewriter("dot", "lib/new", ".")
+	assert.Error(t, err, "mixing a style and a prefix should fail")
+}
+
+func TestRewritePath(t *testing.T) {
+	root := t.TempDir()
+	rootFile := filepath.Join(root, "main.lua")
+	subFile := filepath.Join(root, "tasks", "main.lua")
+
+	tests := []struct {
+		name     string
+		from, to string
+		file     string
+		path     string
+		want     string
+		ok       bool
+	}{
+		{"dot to slash", "dot", "slash", subFile, "modules.tasks.cook", "/modules/tasks/cook", true},
+		{"dot to slash skips plain names", "dot", "slash", subFile, "helper", "", false},
+		{"dot to slash skips slash paths", "dot", "slash", subFile, "./helper.lua", "", false},
+		{"slash to dot from root", "slash", "dot", subFile, "/modules/cook.lua", "modules.cook", true},
+		{"slash to dot relative", "slash", "dot", subFile, "./cook", "tasks.cook", true},
+		{"slash to dot parent", "slash", "dot", subFile, "../util/log.lua", "util.log", true},
+		{"slash to dot single name in root", "slash", "dot", rootFile, "./helper.lua", "helper", true},
+		{"slash to dot single name outside root", "slash", "dot", subFile, "../helper.lua", "", false},
+		{"slash to dot escaping root", "slash", "dot", rootFile, "../outside.lua", "", false},
+		{"slash to dot dotted segment", "slash", "dot", rootFile, "./lib/v1.2/x", "", false},
+		{"prefix exact", "old", "new", rootFile, "old", "new", true},
+		{"prefix folder", "old/ui", "new/ui", rootFile, "old/ui/button", "new/ui/button", true},
+		{"prefix dotted", "legacy", "core", rootFile, "legacy.net", "core.net", true},
+		{"prefix needs boundary", "old", "new", rootFile, "older/x", "", false},
+		{"urls untouched", "dot", "slash", rootFile, "https://example.com/a.b", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRequireRewriter(tt.from, tt.to, root)
+			require.NoError(t, err)
+
+			got, ok := r.RewritePath(tt.file, tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRewriteSource(t *testing.T) {
+	root := t.TempDir()
+	r, err := NewRequireRewriter("dot", "slash", root)
+	require.NoError(t, err)
+
+	src := `local cook = require('tasks.cook')
+-- require("tasks.comment")
+local msg = "require('tasks.string')"
+local util = require "lib.util"`
+
+	out, changes, err := r.RewriteSource(filepath.Join(root, "main.lua"), src)
+	require.NoError(t, err)
+
+	assert.Equal(t, `local cook = require('/tasks/cook')
+-- require("tasks.comment")
+local msg = "require('tasks.string')"
+local util = require "/lib/util"`, out)
+	assert.Equal(t, []Change{
+		{Line: 1, Old: "tasks.cook", New: "/tasks/cook"},
+		{Line: 4, Old: "lib.util", New: "/lib/util"},
+	}, changes)
+
+	diff := Diff("main.lua", src, out)
+	assert.Contains(t, diff, "--- a/main.lua\n+++ b/main.lua\n")
+	assert.Contains(t, diff, "@@ -1 +1 @@\n-local cook = require('tasks.cook')\n+local cook = require('/tasks/cook')\n")
+	assert.NotContains(t, diff, "tasks.comment")
+}
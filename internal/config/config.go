@@ -0,0 +1,458 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+)
+
+// FileName is the project config file looked up next to the entry file
+const FileName = "lua-bundler.json"
+
+// Config holds project-level bundler settings
+type Config struct {
+	// Target is the default runtime target when --target is not given
+	Target string `json:"target,omitempty"`
+
+	// Variants maps a module path to per-target file paths (relative to the
+	// project directory), e.g. {"net": {"roblox": "net/rbx.lua"}}
+	Variants map[string]map[string]string `json:"variants,omitempty"`
+
+	// Strict fails builds with requires that are neither embedded nor
+	// explicitly external, as --strict does
+	Strict bool `json:"strict,omitempty"`
+
+	// SafeWrap runs the bundle in pcall and reports errors, as --safe-wrap
+	// does
+	SafeWrap bool `json:"safeWrap,omitempty"`
+
+	// ErrorHandler is the module, by require path, whose returned function
+	// a safe-wrapped bundle calls with the error message and build id,
+	// instead of the default console message and notification
+	ErrorHandler string `json:"errorHandler,omitempty"`
+
+	// Requires settles ambiguous requires: module path -> "external" or the
+	// file to embed (relative to the project directory). Interactive builds
+	// record the choices they prompt for here.
+	Requires map[string]string `json:"requires,omitempty"`
+
+	// Mirrors maps a remote dependency URL to fallback URLs tried in order
+	Mirrors map[string][]string `json:"mirrors,omitempty"`
+
+	// Shortener is the default URL shortener API for --shorten, with a {url}
+	// placeholder, e.g. "https://is.gd/create.php?format=simple&url={url}"
+	Shortener string `json:"shortener,omitempty"`
+
+	// Version is the project version, written to the bundle header and
+	// defined as VERSION; `lua-bundler bump` updates it
+	Version string `json:"version,omitempty"`
+
+	// Defines are constants declared at the top of the bundle; --define
+	// values take precedence
+	Defines map[string]string `json:"defines,omitempty"`
+
+	// SuppressWarnings lists warning rules to disable, e.g. ["global-shadow"];
+	// --no-warn adds to them
+	SuppressWarnings []string `json:"suppressWarnings,omitempty"`
+
+	// KeepPatterns are regular expressions; print and warn statements with
+	// a matching string argument survive release mode. --keep-pattern adds
+	// to them
+	KeepPatterns []string `json:"keepPatterns,omitempty"`
+
+	// LogShim is the default --log-shim level: release mode then routes print
+	// and warn through an embedded logger instead of removing them
+	LogShim string `json:"logShim,omitempty"`
+
+	// DebugCalls is the default --debug-calls mode: whether release mode
+	// keeps the function calls in the arguments of the statements it removes
+	DebugCalls string `json:"debugCalls,omitempty"`
+
+	// Loader is the default --loader strategy for embedding modules
+	Loader string `json:"loader,omitempty"`
+
+	// InlineSmall is the default --inline-small token limit of constant
+	// modules inlined at their require sites (0 = off)
+	InlineSmall int `json:"inlineSmall,omitempty"`
+
+	// Resolution is the default --resolution mode mapping require strings
+	// such as "a.b.c" to files
+	Resolution string `json:"resolution,omitempty"`
+
+	// NoMemoize lists modules that run again on every require instead of
+	// returning the cached result. --no-memoize adds to them
+	NoMemoize []string `json:"noMemoize,omitempty"`
+
+	// Stubs maps a module key to a file embedded in its place, relative to
+	// the config file, e.g. {"analytics": "stubs/analytics.lua"}; --stub
+	// values take precedence
+	Stubs map[string]string `json:"stubs,omitempty"`
+
+	// Omit lists modules replaced by an empty table; --omit adds to them
+	Omit []string `json:"omit,omitempty"`
+
+	// Prelude lists modules run before the entry file, by require path,
+	// e.g. ["checks.env"]; the entry file does not need to require them
+	Prelude []string `json:"prelude,omitempty"`
+
+	// Epilogue lists modules run after the entry file returns, by require
+	// path
+	Epilogue []string `json:"epilogue,omitempty"`
+
+	// Features maps an optional feature to the modules only it uses, by
+	// require path or pattern, e.g. {"esp": ["esp.*"], "autofarm": ["farm"]};
+	// --features selects the ones to bundle
+	Features map[string][]string `json:"features,omitempty"`
+
+	// GitInfo records the commit, tag and dirty state of the git checkout
+	// in the bundle header and manifest, and defines GIT_COMMIT, GIT_TAG and
+	// GIT_DIRTY, as --git-info does
+	GitInfo bool `json:"gitInfo,omitempty"`
+
+	// Locales are translation files merged into the bundle, relative to the
+	// config file, e.g. ["locales/de.json"]; the file name is the locale.
+	// --locale adds to them
+	Locales []string `json:"locales,omitempty"`
+
+	// Locale is the locale selected when the script starts, the first
+	// locale when empty; --default-locale overrides it
+	Locale string `json:"locale,omitempty"`
+
+	// LocaleFunction is the global marking translatable strings, "L" when
+	// empty. Setting it enables translation without locale files
+	LocaleFunction string `json:"localeFunction,omitempty"`
+
+	// StateKey isolates the state the bundle keeps in getgenv(), shared and
+	// _G under this key, as --state-key does
+	StateKey string `json:"stateKey,omitempty"`
+
+	// RequestShim routes syn.request, http.request, http_request and
+	// request calls through one cross-executor shim, as --request-shim does
+	RequestShim bool `json:"requestShim,omitempty"`
+
+	// Lualib lists the directories the openresty target reads resty.*
+	// modules from, relative to the config file; --lualib replaces them
+	Lualib []string `json:"lualib,omitempty"`
+
+	// AddonLibs lists the directories searched for the files a WoW .toc
+	// entry lists that the addon folder lacks, such as an Ace3 checkout,
+	// relative to the config file; --addon-libs replaces them
+	AddonLibs []string `json:"addonLibs,omitempty"`
+
+	// SourceEncoding is how sources that are not UTF-8 are read, as
+	// --source-encoding sets
+	SourceEncoding string `json:"sourceEncoding,omitempty"`
+
+	// LineEndings are the line endings of the bundle, lf or crlf, as
+	// --line-endings sets
+	LineEndings string `json:"lineEndings,omitempty"`
+
+	// SizeLimit is the largest bundle allowed, in bytes or a preset such as
+	// cc-floppy, as --size-limit sets
+	SizeLimit string `json:"sizeLimit,omitempty"`
+
+	// Obfuscation is the obfuscation used when --obfuscate is not given: a
+	// preset, e.g. {"preset": "heavy"}, or passes run in order with their
+	// options, e.g. {"passes": [{"pass": "rename"}, {"pass": "strings",
+	// "method": "escape"}, {"pass": "minify", "singleLine": true}]}. Its
+	// modules override that per module, first match first, e.g.
+	// [{"match": "crypto/", "preset": "heavy"}, {"match": "vendor/", "preset": "none"}]
+	Obfuscation Obfuscation `json:"obfuscation,omitempty"`
+
+	// Plugin sets up the toolbar button of the Studio plugin written with
+	// --plugin
+	Plugin Plugin `json:"plugin,omitempty"`
+
+	// Sync delivers the bundle where it is used after every successful
+	// build, in order, e.g. [{"copyTo": "~/AppData/Local/Executor/autoexec"},
+	// {"run": ["adb", "push", "{bundle}", "/sdcard/scripts/"]}]; --no-sync
+	// skips them
+	Sync []SyncAction `json:"sync,omitempty"`
+
+	// Executors are the local executor installs --inject writes the bundle
+	// to, by name, e.g. {"krnl": {"dir": "~/AppData/Local/krnl",
+	// "execute": "ws://127.0.0.1:6969"}}
+	Executors map[string]Executor `json:"executors,omitempty"`
+
+	path string
+}
+
+// Plugin is the toolbar button of a Studio plugin; empty fields take the
+// defaults
+type Plugin struct {
+	Toolbar string `json:"toolbar,omitempty"`
+	Button  string `json:"button,omitempty"`
+	Tooltip string `json:"tooltip,omitempty"`
+	Icon    string `json:"icon,omitempty"`
+}
+
+// SyncAction copies the bundle to a directory or runs a command with it
+type SyncAction struct {
+	Name string `json:"name,omitempty"` // shown in the build output
+
+	// CopyTo is the directory the bundle is copied into, or the file it is
+	// copied to when the path has an extension; ~ and $VARIABLES expand,
+	// and relative paths start at the config file
+	CopyTo string `json:"copyTo,omitempty"`
+
+	// Run is a command and its arguments, run in the config file's
+	// directory; {bundle} stands for the bundle's absolute path and {name}
+	// for its file name
+	Run []string `json:"run,omitempty"`
+
+	// Optional actions only warn when they fail instead of failing the build
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Label returns what the action is called in the build output
+func (a SyncAction) Label() string {
+	switch {
+	case a.Name != "":
+		return a.Name
+	case a.CopyTo != "":
+		return a.CopyTo
+	case len(a.Run) > 0:
+		return a.Run[0]
+	}
+	return "sync"
+}
+
+// Validate checks that the action does one thing
+func (a SyncAction) Validate() error {
+	if (a.CopyTo == "") == (len(a.Run) == 0) {
+		return fmt.Errorf("sync %s: set copyTo or run", a.Label())
+	}
+	return nil
+}
+
+// Executor is where an executor install reads scripts from, and the local
+// API running one if it has any. ~ and $VARIABLES expand in the folders,
+// and relative ones start at the config file.
+type Executor struct {
+	Dir       string `json:"dir,omitempty"`       // install folder holding workspace and autoexec
+	Workspace string `json:"workspace,omitempty"` // folder readfile and the script hub see, <dir>/workspace when empty
+	Autoexec  string `json:"autoexec,omitempty"`  // folder run when the executor attaches, <dir>/autoexec when empty
+	Execute   string `json:"execute,omitempty"`   // http(s):// or ws:// URL running a script sent to it
+}
+
+// Folders returns the workspace and autoexec folders, "" when unknown
+func (e Executor) Folders() (workspace, autoexec string) {
+	workspace, autoexec = e.Workspace, e.Autoexec
+	if e.Dir != "" {
+		if workspace == "" {
+			workspace = filepath.Join(e.Dir, "workspace")
+		}
+		if autoexec == "" {
+			autoexec = filepath.Join(e.Dir, "autoexec")
+		}
+	}
+	return workspace, autoexec
+}
+
+// Obfuscation selects an obfuscation preset or a pipeline of passes, and
+// the modules obfuscated otherwise
+type Obfuscation struct {
+	Preset  string              `json:"preset,omitempty"` // a preset name or level 0-3
+	Passes  []obfuscator.Pass   `json:"passes,omitempty"`
+	Modules []ObfuscationModule `json:"modules,omitempty"`
+}
+
+// ObfuscationModule selects the preset or passes of the modules matching a
+// glob against their require key or file path; a glob ending in / matches
+// a directory
+type ObfuscationModule struct {
+	Match  string            `json:"match"`
+	Preset string            `json:"preset,omitempty"`
+	Passes []obfuscator.Pass `json:"passes,omitempty"`
+}
+
+// Pipeline returns the passes the obfuscation block selects, none when it
+// is empty
+func (o Obfuscation) Pipeline() ([]obfuscator.Pass, error) {
+	passes, err := pipeline(o.Preset, o.Passes)
+	if err != nil {
+		return nil, fmt.Errorf("obfuscation: %w", err)
+	}
+	return passes, nil
+}
+
+// Pipeline returns the passes the module rule selects
+func (m ObfuscationModule) Pipeline() ([]obfuscator.Pass, error) {
+	if m.Preset == "" && len(m.Passes) == 0 {
+		return nil, fmt.Errorf("obfuscation of %s: set a preset or passes", m.Match)
+	}
+	passes, err := pipeline(m.Preset, m.Passes)
+	if err != nil {
+		return nil, fmt.Errorf("obfuscation of %s: %w", m.Match, err)
+	}
+	return passes, nil
+}
+
+// pipeline returns the passes of a preset or the validated passes
+func pipeline(preset string, passes []obfuscator.Pass) ([]obfuscator.Pass, error) {
+	switch {
+	case preset != "" && len(passes) > 0:
+		return nil, fmt.Errorf("set a preset or passes, not both")
+	case preset != "":
+		return obfuscator.ParsePreset(preset)
+	}
+	if err := obfuscator.Validate(passes); err != nil {
+		return nil, err
+	}
+	return passes, nil
+}
+
+// Load reads a config file from the given path
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	cfg.path = path
+
+	return cfg, nil
+}
+
+// LoadFromDir loads lua-bundler.json from dir, returning an empty config if none exists
+func LoadFromDir(dir string) (*Config, error) {
+	path := filepath.Join(dir, FileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	return Load(path)
+}
+
+// Path returns the file the config was loaded from (empty if none)
+func (c *Config) Path() string {
+	return c.path
+}
+
+var versionFieldRegex = regexp.MustCompile(`("version"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// WriteVersion sets the version in the config file at path, leaving the rest
+// of the file as written. The file is created if it does not exist.
+func WriteVersion(path, version string) error {
+	quoted, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data = []byte("{}\n")
+	} else if err != nil {
+		return fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	content := string(data)
+	if loc := versionFieldRegex.FindStringSubmatchIndex(content); loc != nil {
+		content = content[:loc[3]] + string(quoted) + content[loc[1]:]
+	} else if content, err = insertField(content, "version", string(quoted)); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	// Refuse to write a file that no longer parses
+	var check Config
+	if err := json.Unmarshal([]byte(content), &check); err != nil || check.Version != version {
+		return fmt.Errorf("failed to update version in %s", path)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write config %s: %w", path, err)
+	}
+	return nil
+}
+
+var requiresFieldRegex = regexp.MustCompile(`"requires"\s*:\s*\{`)
+
+// WriteRequire records how a require of module resolves in the config file
+// at path, "external" or a file, leaving the rest of the file as written.
+// The file is created if it does not exist.
+func WriteRequire(path, module, choice string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data = []byte("{}\n")
+	} else if err != nil {
+		return fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	content := string(data)
+	requires := map[string]string{}
+	start, end := -1, -1
+	if loc := requiresFieldRegex.FindStringIndex(content); loc != nil {
+		start = loc[1] - 1
+		end = objectEnd(content, start)
+		if end < 0 || json.Unmarshal([]byte(content[start:end]), &requires) != nil {
+			return fmt.Errorf("failed to parse requires in config %s", path)
+		}
+	}
+	requires[module] = choice
+	object, err := json.MarshalIndent(requires, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	if start >= 0 {
+		content = content[:start] + string(object) + content[end:]
+	} else if content, err = insertField(content, "requires", string(object)); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	// Refuse to write a file that no longer parses
+	var check Config
+	if err := json.Unmarshal([]byte(content), &check); err != nil || check.Requires[module] != choice {
+		return fmt.Errorf("failed to record %s in %s", module, path)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write config %s: %w", path, err)
+	}
+	return nil
+}
+
+// insertField adds a field holding value, already encoded, at the start of
+// the top-level object in content
+func insertField(content, name, value string) (string, error) {
+	open := strings.Index(content, "{")
+	if open < 0 {
+		return "", fmt.Errorf("no JSON object")
+	}
+	field := "\n  \"" + name + "\": " + value
+	if rest := strings.TrimSpace(content[open+1:]); !strings.HasPrefix(rest, "}") {
+		field += ","
+	} else {
+		field += "\n"
+	}
+	return content[:open+1] + field + content[open+1:], nil
+}
+
+// objectEnd returns the index just past the JSON object opening at start,
+// or -1 when it is not closed
+func objectEnd(content string, start int) int {
+	depth, inString := 0, false
+	for i := start; i < len(content); i++ {
+		switch c := content[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/obfuscator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	content := `{
+	"target": "lua51",
+	"variants": {
+		"net": {"roblox": "net/rbx.lua"}
+	},
+	"shortener": "https://is.gd/create.php?format=simple&url={url}"
+}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	cfg, err := Load(path)
+	require.NoError(t, err, "Load() should not fail")
+
+	assert.Equal(t, "lua51", cfg.Target)
+	assert.Equal(t, "net/rbx.lua", cfg.Variants["net"]["roblox"])
+	assert.Equal(t, "https://is.gd/create.php?format=simple&url={url}", cfg.Shortener)
+	assert.Equal(t, path, cfg.Path())
+}
+
+func TestLoad_InvalidJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0644))
+
+	_, err := Load(path)
+	assert.Error(t, err, "Load() should fail on invalid JSON")
+	assert.Contains(t, err.Error(), "failed to parse config")
+}
+
+func TestLoadFromDir(t *testing.T) {
+	t.Run("missing config", func(t *testing.T) {
+		cfg, err := LoadFromDir(t.TempDir())
+		require.NoError(t, err, "LoadFromDir() should not fail without config")
+		assert.Empty(t, cfg.Target)
+		assert.Empty(t, cfg.Path())
+	})
+
+	t.Run("existing config", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`{"target":"roblox"}`), 0644))
+
+		cfg, err := LoadFromDir(dir)
+		require.NoError(t, err, "LoadFromDir() should not fail")
+		assert.Equal(t, "roblox", cfg.Target)
+	})
+}
+
+func TestWriteVersion(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	require.NoError(t, WriteVersion(path, "0.1.0"), "a missing config should be created")
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "0.1.0", cfg.Version)
+
+	content := "{\n\t\"target\": \"lua51\",\n\t\"version\": \"1.4.0\"\n}\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, WriteVersion(path, "1.5.0"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n\t\"target\": \"lua51\",\n\t\"version\": \"1.5.0\"\n}\n", string(data), "formatting should be kept")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"target": "lua51"}`), 0644))
+	require.NoError(t, WriteVersion(path, "2.0.0"))
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", cfg.Version)
+	assert.Equal(t, "lua51", cfg.Target)
+}
+
+func TestWriteRequire(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	require.NoError(t, WriteRequire(path, "Players.Local", "external"), "a missing config should be created")
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Players.Local": "external"}, cfg.Requires)
+
+	content := "{\n  \"target\": \"lua51\",\n  \"requires\": { \"a\": \"a/init.lua\" },\n  \"defines\": { \"X\": \"}\" }\n}\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, WriteRequire(path, "lib.json", "lib/json.lua"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"target\": \"lua51\",\n  \"requires\": {\n    \"a\": \"a/init.lua\",\n    \"lib.json\": \"lib/json.lua\"\n  },\n  \"defines\": { \"X\": \"}\" }\n}\n", string(data), "the rest of the file should be kept")
+
+	require.NoError(t, WriteRequire(path, "a", "external"))
+	cfg, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "external", "lib.json": "lib/json.lua"}, cfg.Requires)
+	assert.Equal(t, "}", cfg.Defines["X"])
+}
+
+func TestObfuscationPipeline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{"obfuscation": {"passes": [{"pass": "strings", "method": "escape"}, {"pass": "minify", "singleLine": true}]}}`), 0644))
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	passes, err := cfg.Obfuscation.Pipeline()
+	require.NoError(t, err)
+	assert.Equal(t, []obfuscator.Pass{{Name: "strings", Method: "escape"}, {Name: "minify", SingleLine: true}}, passes)
+
+	passes, err = Obfuscation{Preset: "2"}.Pipeline()
+	require.NoError(t, err)
+	assert.Equal(t, obfuscator.Presets["medium"], passes)
+
+	passes, err = Obfuscation{}.Pipeline()
+	require.NoError(t, err)
+	assert.Empty(t, passes)
+
+	_, err = Obfuscation{Preset: "heavy", Passes: []obfuscator.Pass{{Name: "rename"}}}.Pipeline()
+	assert.EqualError(t, err, "obfuscation: set a preset or passes, not both")
+	_, err = Obfuscation{Passes: []obfuscator.Pass{{Name: "rename", Depth: 2}}}.Pipeline()
+	assert.EqualError(t, err, "obfuscation: pass 1: option depth does not apply to the rename pass")
+}
+
+func TestObfuscationModulePipeline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{"obfuscation": {"preset": "medium", "modules": [{"match": "crypto/", "preset": "3"}, {"match": "vendor/", "preset": "none"}]}}`), 0644))
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Obfuscation.Modules, 2)
+
+	passes, err := cfg.Obfuscation.Modules[0].Pipeline()
+	require.NoError(t, err)
+	assert.Equal(t, obfuscator.Presets["heavy"], passes)
+	passes, err = cfg.Obfuscation.Modules[1].Pipeline()
+	require.NoError(t, err)
+	assert.Empty(t, passes)
+
+	_, err = ObfuscationModule{Match: "ui/"}.Pipeline()
+	assert.EqualError(t, err, "obfuscation of ui/: set a preset or passes")
+	_, err = ObfuscationModule{Match: "ui/", Preset: "ultra"}.Pipeline()
+	assert.ErrorContains(t, err, `obfuscation of ui/: unknown obfuscation preset "ultra"`)
+}
+
+func TestSyncAction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	content := `{"sync": [
+	{"copyTo": "~/autoexec"},
+	{"name": "phone", "run": ["adb", "push", "{bundle}", "/sdcard/"], "optional": true}
+]}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Sync, 2)
+	assert.Equal(t, "~/autoexec", cfg.Sync[0].Label())
+	assert.Equal(t, "phone", cfg.Sync[1].Label())
+	assert.True(t, cfg.Sync[1].Optional)
+	for _, action := range cfg.Sync {
+		assert.NoError(t, action.Validate())
+	}
+
+	assert.EqualError(t, SyncAction{}.Validate(), "sync sync: set copyTo or run")
+	assert.EqualError(t, SyncAction{CopyTo: "out", Run: []string{"scp"}}.Validate(), "sync out: set copyTo or run")
+}
+
+func TestExecutorFolders(t *testing.T) {
+	workspace, autoexec := Executor{Dir: "krnl"}.Folders()
+	assert.Equal(t, filepath.Join("krnl", "workspace"), workspace)
+	assert.Equal(t, filepath.Join("krnl", "autoexec"), autoexec)
+
+	workspace, autoexec = Executor{Dir: "krnl", Autoexec: "startup"}.Folders()
+	assert.Equal(t, filepath.Join("krnl", "workspace"), workspace)
+	assert.Equal(t, "startup", autoexec)
+
+	workspace, autoexec = Executor{Workspace: "scripts"}.Folders()
+	assert.Equal(t, "scripts", workspace)
+	assert.Empty(t, autoexec)
+}
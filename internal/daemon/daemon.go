@@ -0,0 +1,464 @@
+// Package daemon serves the bundler over a local JSON-RPC 2.0 API, keeping
+// resolved projects in memory so editor integrations do not pay process
+// startup and a full re-resolution on every request.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+)
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeBundlerError   = -32000
+)
+
+// Factory creates a bundler for an entry file, configured from the project
+// the way the CLI would configure it
+type Factory func(entry string) (*bundler.Bundler, error)
+
+// Server answers JSON-RPC requests on /rpc and streams watch events on /events.
+// Requests are served in parallel: every resolution and build has a bundler
+// of its own and runs in the worker pool, while builds of the same project
+// wait for each other so it is only built once.
+type Server struct {
+	newBundler Factory
+	pool       *bundler.Pool
+
+	mu        sync.Mutex             // guards the maps below, never held while bundling
+	projects  map[string]*project    // entry -> last resolution
+	resolving map[string]*resolution // entry -> resolution in progress
+	subs      map[string]*subscription
+	nextSub   int
+}
+
+// project is an entry file resolved once and reused until one of the local
+// files it read changes
+type project struct {
+	entry       string
+	bundler     *bundler.Bundler
+	err         error                // resolution failure, reported as a diagnostic
+	stamps      map[string]fileStamp // local file -> state when it was read
+	diagnostics []Diagnostic
+
+	buildMu sync.Mutex            // held while building, so a build is made once
+	builds  map[bool]*BuildResult // release mode -> last build
+}
+
+// resolution is an entry being resolved, which requests for the same entry
+// wait for instead of resolving it again
+type resolution struct {
+	done    chan struct{} // closed when project and err are set
+	project *project
+	err     error
+}
+
+// fileStamp identifies a version of a file without reading it
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewServer returns a server creating bundlers with newBundler
+func NewServer(newBundler Factory) *Server {
+	return &Server{
+		newBundler: newBundler,
+		pool:       bundler.NewPool(0),
+		projects:   make(map[string]*project),
+		resolving:  make(map[string]*resolution),
+		subs:       make(map[string]*subscription),
+	}
+}
+
+// SetWorkers sets how many resolutions and builds run at once, one per CPU
+// when workers is 0 or less
+func (s *Server) SetWorkers(workers int) {
+	s.pool = bundler.NewPool(workers)
+}
+
+// Handler returns the HTTP handler serving the API. Requests must come
+// through a loopback host name, so web pages cannot reach the daemon by
+// rebinding their own domain to 127.0.0.1.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", s.serveRPC)
+	mux.HandleFunc("/events", s.serveEvents)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost reports whether a Host header names this machine
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// serveRPC handles one JSON-RPC request. Only JSON bodies are accepted,
+// which browsers cannot send cross-origin without a preflight the daemon
+// never approves.
+func (s *Server) serveRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType := r.Header.Get("Content-Type"); mediaType != "application/json" {
+		http.Error(w, "expected Content-Type: application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req rpcRequest
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error = &rpcError{Code: codeParseError, Message: fmt.Sprintf("parse error: %v", err)}
+	} else if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: codeInvalidRequest, Message: `invalid request: expected "jsonrpc": "2.0" and a method`}
+	} else {
+		if req.ID != nil {
+			resp.ID = req.ID
+		}
+		result, err := s.call(req.Method, req.Params)
+		if err != nil {
+			rerr, ok := err.(*rpcError)
+			if !ok {
+				rerr = &rpcError{Code: codeBundlerError, Message: err.Error()}
+			}
+			resp.Error = rerr
+		} else {
+			resp.Result = result
+		}
+		// Notifications get no response
+		if req.ID == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// entryParams are the parameters shared by methods working on a project
+type entryParams struct {
+	Entry   string `json:"entry"`
+	Release bool   `json:"release"`
+}
+
+// call dispatches a method to its handler
+func (s *Server) call(method string, raw json.RawMessage) (interface{}, error) {
+	switch method {
+	case "resolve", "build", "diagnostics", "watch":
+		var params entryParams
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		if params.Entry == "" {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "invalid params: entry is required"}
+		}
+		entry, err := normalizeEntry(params.Entry)
+		if err != nil {
+			return nil, err
+		}
+		switch method {
+		case "resolve":
+			return s.Resolve(entry)
+		case "build":
+			return s.Build(entry, params.Release)
+		case "diagnostics":
+			return s.Diagnostics(entry)
+		default:
+			return s.Watch(entry)
+		}
+	case "unwatch":
+		var params struct {
+			Subscription string `json:"subscription"`
+		}
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		if !s.Unwatch(params.Subscription) {
+			return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown subscription %q", params.Subscription)}
+		}
+		return true, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+}
+
+// decodeParams decodes named params, rejecting positional ones
+func decodeParams(raw json.RawMessage, params interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, params); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
+
+// normalizeEntry makes local entries absolute, so a project is shared by
+// clients running in different directories
+func normalizeEntry(entry string) (string, error) {
+	if bundler.IsURL(entry) {
+		return entry, nil
+	}
+	return filepath.Abs(entry)
+}
+
+// Module is a bundled module in a resolve result
+type Module struct {
+	Key    string `json:"key"`
+	Source string `json:"source"` // file or URL it was loaded from
+	Remote bool   `json:"remote"`
+}
+
+// Edge is a dependency in a resolve result
+type Edge struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Remote  bool   `json:"remote,omitempty"`
+	Runtime bool   `json:"runtime,omitempty"`
+}
+
+// ResolveResult is the dependency graph of an entry
+type ResolveResult struct {
+	Entry       string       `json:"entry"`
+	Modules     []Module     `json:"modules"`
+	Edges       []Edge       `json:"edges"`
+	Externals   []string     `json:"externals,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Files       []string     `json:"files"` // local files the graph was read from
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Diagnostic is a problem in a source file
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"` // error or warning
+	Rule     string `json:"rule,omitempty"`
+	Message  string `json:"message"`
+}
+
+// DiagnosticsResult lists the problems found resolving an entry
+type DiagnosticsResult struct {
+	Entry       string       `json:"entry"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// BuildResult is a bundle built by the daemon
+type BuildResult struct {
+	Bundle   string                   `json:"bundle"`
+	BuildID  string                   `json:"buildId"`
+	Warnings []string                 `json:"warnings"`
+	Modules  []bundler.ManifestModule `json:"modules"`
+	Timings  bundler.Timings          `json:"timings"` // of the build that made the bundle
+	Cached   bool                     `json:"cached"`
+}
+
+// Resolve returns the dependency graph of entry
+func (s *Server) Resolve(entry string) (*ResolveResult, error) {
+	p, err := s.project(entry)
+	if err != nil {
+		return nil, err
+	}
+	b := p.bundler
+	result := &ResolveResult{Entry: entry, Modules: []Module{}, Edges: []Edge{}, Files: b.GetLocalFiles(), Diagnostics: p.diagnostics}
+	if p.err != nil {
+		result.Error = p.err.Error()
+	}
+	for key := range b.GetModules() {
+		source := b.GetModuleSource(key)
+		result.Modules = append(result.Modules, Module{Key: key, Source: source, Remote: bundler.IsURL(source)})
+	}
+	sort.Slice(result.Modules, func(i, j int) bool { return result.Modules[i].Key < result.Modules[j].Key })
+	for from, deps := range b.GetGraph() {
+		for _, dep := range deps {
+			result.Edges = append(result.Edges, Edge{From: from, To: dep.Key, Remote: dep.Remote, Runtime: dep.Runtime})
+		}
+	}
+	sort.SliceStable(result.Edges, func(i, j int) bool { return result.Edges[i].From < result.Edges[j].From })
+	for _, ext := range b.ExternalRequires() {
+		result.Externals = append(result.Externals, ext.Path)
+	}
+	return result, nil
+}
+
+// Diagnostics returns the problems found resolving entry
+func (s *Server) Diagnostics(entry string) (*DiagnosticsResult, error) {
+	p, err := s.project(entry)
+	if err != nil {
+		return nil, err
+	}
+	return &DiagnosticsResult{Entry: entry, Diagnostics: p.diagnostics}, nil
+}
+
+// Build bundles entry, reusing the last bundle while no local file changed
+func (s *Server) Build(entry string, release bool) (*BuildResult, error) {
+	p, err := s.project(entry)
+	if err != nil {
+		return nil, err
+	}
+	p.buildMu.Lock()
+	defer p.buildMu.Unlock()
+	if cached := p.builds[release]; cached != nil {
+		result := *cached
+		result.Cached = true
+		return &result, nil
+	}
+
+	var result *BuildResult
+	err = s.pool.Do(context.Background(), func() error {
+		// Bundling consumes the bundler, so builds start from a fresh one
+		b, err := s.newBundler(entry)
+		if err != nil {
+			return err
+		}
+		built, err := b.BundleWithResult(release)
+		if err != nil {
+			return fmt.Errorf("bundling failed: %w", err)
+		}
+		result = &BuildResult{Bundle: built.Output, BuildID: built.BuildID, Warnings: built.Warnings, Modules: built.Modules, Timings: built.Timings}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.builds[release] = result
+	return result, nil
+}
+
+// project returns the resolution of entry, resolving it again in the pool
+// when it failed or a file it read has changed. Concurrent requests for an
+// entry share one resolution. Callers must not hold s.mu.
+func (s *Server) project(entry string) (*project, error) {
+	s.mu.Lock()
+	if p := s.projects[entry]; p != nil && p.err == nil && !p.changed() {
+		s.mu.Unlock()
+		return p, nil
+	}
+	r := s.resolving[entry]
+	if r != nil {
+		s.mu.Unlock()
+		<-r.done
+		return r.project, r.err
+	}
+	r = &resolution{done: make(chan struct{})}
+	s.resolving[entry] = r
+	s.mu.Unlock()
+
+	r.err = s.pool.Do(context.Background(), func() error {
+		var err error
+		r.project, err = s.resolve(entry)
+		return err
+	})
+
+	s.mu.Lock()
+	if r.err == nil {
+		s.projects[entry] = r.project
+	}
+	delete(s.resolving, entry)
+	s.mu.Unlock()
+	close(r.done)
+	return r.project, r.err
+}
+
+// resolve resolves entry with a new bundler. Resolution failures are kept
+// on the project as an error diagnostic; only a bundler that cannot be
+// created is an error.
+func (s *Server) resolve(entry string) (*project, error) {
+	b, err := s.newBundler(entry)
+	if err != nil {
+		return nil, err
+	}
+	p := &project{entry: entry, bundler: b, builds: make(map[bool]*BuildResult)}
+	_, p.err = b.Resolve()
+
+	p.stamps = make(map[string]fileStamp)
+	for _, file := range b.GetLocalFiles() {
+		p.stamps[file] = stampOf(file)
+	}
+
+	p.diagnostics = []Diagnostic{}
+	for _, d := range b.GetDiagnostics() {
+		p.diagnostics = append(p.diagnostics, Diagnostic{File: d.File, Line: d.Line, Severity: d.Severity, Rule: d.Rule, Message: d.Message})
+	}
+	if p.err != nil {
+		p.diagnostics = append(p.diagnostics, Diagnostic{File: entry, Severity: bundler.SeverityError, Message: p.err.Error()})
+	}
+	return p, nil
+}
+
+// changed reports whether a file the project was resolved from has been
+// modified or removed since
+func (p *project) changed() bool {
+	return len(p.changedFiles()) > 0
+}
+
+// changedFiles returns the sorted files modified or removed since the
+// project was resolved
+func (p *project) changedFiles() []string {
+	var files []string
+	for file, stamp := range p.stamps {
+		if stampOf(file) != stamp {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// stampOf returns the current stamp of a file, the zero stamp if it is gone
+func stampOf(file string) fileStamp {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
@@ -0,0 +1,207 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProject writes a main.lua requiring utils.helper and returns its path
+func writeProject(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "utils"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.lua"), []byte("local helper = require(\"utils.helper\")\nprint = nil\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "utils", "helper.lua"), []byte("return {}\n"), 0644))
+	return filepath.Join(dir, "main.lua")
+}
+
+// touch rewrites a file with a later modification time, so the change is
+// seen even on file systems with coarse timestamps
+func touch(t *testing.T, file, content string) {
+	require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(file, later, later))
+}
+
+func newTestServer() *Server {
+	return NewServer(func(entry string) (*bundler.Bundler, error) {
+		return bundler.NewBundler(entry, false, false)
+	})
+}
+
+// rpc posts a JSON-RPC request and decodes the response
+func rpc(t *testing.T, url, method string, params interface{}) rpcResponse {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+	resp, err := http.Post(url+"/rpc", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out rpcResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	return out
+}
+
+func TestRPCResolve(t *testing.T) {
+	entry := writeProject(t)
+	srv := httptest.NewServer(newTestServer().Handler())
+	defer srv.Close()
+
+	resp := rpc(t, srv.URL, "resolve", map[string]string{"entry": entry})
+	require.Nil(t, resp.Error)
+	assert.Equal(t, json.RawMessage("1"), resp.ID)
+
+	data, _ := json.Marshal(resp.Result)
+	var result ResolveResult
+	require.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, entry, result.Entry)
+	assert.Equal(t, []Module{{Key: "utils.helper", Source: filepath.Join(filepath.Dir(entry), "utils", "helper.lua")}}, result.Modules)
+	assert.Equal(t, []Edge{{From: entry, To: "utils.helper"}}, result.Edges)
+	assert.Equal(t, []Diagnostic{{File: entry, Line: 2, Severity: "warning", Rule: bundler.RuleGlobalOverride, Message: "overrides standard global print"}}, result.Diagnostics)
+}
+
+func TestRPCErrors(t *testing.T) {
+	srv := httptest.NewServer(newTestServer().Handler())
+	defer srv.Close()
+
+	resp := rpc(t, srv.URL, "bundle", nil)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, codeMethodNotFound, resp.Error.Code)
+
+	resp = rpc(t, srv.URL, "resolve", map[string]string{})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, codeInvalidParams, resp.Error.Code)
+
+	resp = rpc(t, srv.URL, "resolve", []string{"main.lua"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, codeInvalidParams, resp.Error.Code)
+
+	post, err := http.Post(srv.URL+"/rpc", "text/plain", bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":1,"method":"resolve"}`)))
+	require.NoError(t, err)
+	post.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, post.StatusCode)
+
+	post, err = http.Post(srv.URL+"/rpc", "application/json", bytes.NewReader([]byte(`{"jsonrpc":"2.0","method":"unwatch","params":{"subscription":"9"}}`)))
+	require.NoError(t, err)
+	post.Body.Close()
+	assert.Equal(t, http.StatusNoContent, post.StatusCode, "notifications get no response")
+}
+
+func TestHandlerRejectsForeignHosts(t *testing.T) {
+	handler := newTestServer().Handler()
+	for host, status := range map[string]int{
+		"localhost:7420":      http.StatusMethodNotAllowed,
+		"127.0.0.1:7420":      http.StatusMethodNotAllowed,
+		"[::1]:7420":          http.StatusMethodNotAllowed,
+		"attacker.example":    http.StatusForbidden,
+		"192.168.1.10:7420":   http.StatusForbidden,
+		"localhost.evil.test": http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/rpc", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, host)
+	}
+}
+
+func TestBuildIsCachedUntilAFileChanges(t *testing.T) {
+	entry := writeProject(t)
+	s := newTestServer()
+
+	first, err := s.Build(entry, false)
+	require.NoError(t, err)
+	assert.False(t, first.Cached)
+	assert.Contains(t, first.Bundle, `EmbeddedModules["utils.helper"]`)
+	assert.NotEmpty(t, first.BuildID)
+	require.Len(t, first.Modules, 1)
+	assert.Equal(t, "utils.helper", first.Modules[0].Key)
+	assert.Positive(t, first.Timings.Total)
+
+	again, err := s.Build(entry, false)
+	require.NoError(t, err)
+	assert.True(t, again.Cached)
+	assert.Equal(t, first.Bundle, again.Bundle)
+
+	release, err := s.Build(entry, true)
+	require.NoError(t, err)
+	assert.False(t, release.Cached, "release builds are cached separately")
+
+	touch(t, filepath.Join(filepath.Dir(entry), "utils", "helper.lua"), "return { changed = true }\n")
+	rebuilt, err := s.Build(entry, false)
+	require.NoError(t, err)
+	assert.False(t, rebuilt.Cached)
+	assert.Contains(t, rebuilt.Bundle, "changed = true")
+}
+
+func TestDiagnosticsReportResolveErrors(t *testing.T) {
+	entry := writeProject(t)
+	touch(t, entry, "local missing = require(\"utils.missing\")\n")
+	s := newTestServer()
+
+	result, err := s.Diagnostics(entry)
+	require.NoError(t, err)
+	require.Len(t, result.Diagnostics, 1)
+	assert.Equal(t, "error", result.Diagnostics[0].Severity)
+	assert.Equal(t, entry, result.Diagnostics[0].File)
+	assert.Contains(t, result.Diagnostics[0].Message, "missing.lua")
+
+	_, err = s.Build(entry, false)
+	assert.Error(t, err)
+
+	// Failed resolutions are retried, so fixing the project clears them
+	touch(t, entry, "local helper = require(\"utils.helper\")\n")
+	result, err = s.Diagnostics(entry)
+	require.NoError(t, err)
+	assert.Empty(t, result.Diagnostics)
+}
+
+func TestBuildParallel(t *testing.T) {
+	entries := []string{writeProject(t), writeProject(t), writeProject(t)}
+	s := newTestServer()
+	s.SetWorkers(2)
+
+	var wg sync.WaitGroup
+	results := make([]*BuildResult, 12)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := entries[i%len(entries)]
+			if i%4 == 3 {
+				_, errs[i] = s.Resolve(entry)
+				return
+			}
+			results[i], errs[i] = s.Build(entry, i%2 == 0)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		require.NoError(t, err)
+		if results[i] != nil {
+			assert.Contains(t, results[i].Bundle, `EmbeddedModules["utils.helper"]`)
+		}
+	}
+
+	// Each project and mode was built once; the other requests were cached
+	built := 0
+	for _, r := range results {
+		if r != nil && !r.Cached {
+			built++
+		}
+	}
+	assert.Equal(t, 6, built)
+}
@@ -0,0 +1,179 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+)
+
+// Event tells a subscriber that an entry's files changed, with the
+// diagnostics of the new resolution
+type Event struct {
+	Subscription string       `json:"subscription"`
+	Entry        string       `json:"entry"`
+	Changed      []string     `json:"changed"`
+	Diagnostics  []Diagnostic `json:"diagnostics"`
+}
+
+// subscription is a client watching one entry
+type subscription struct {
+	id     string
+	entry  string
+	events chan Event
+}
+
+// WatchResult identifies a new subscription
+type WatchResult struct {
+	Subscription string `json:"subscription"`
+}
+
+// Watch subscribes to changes of the files entry is built from. Events are
+// read from GET /events?subscription=<id> as server-sent events.
+func (s *Server) Watch(entry string) (*WatchResult, error) {
+	if _, err := s.project(entry); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextSub++
+	sub := &subscription{id: fmt.Sprintf("%d", s.nextSub), entry: entry, events: make(chan Event, 16)}
+	s.subs[sub.id] = sub
+	return &WatchResult{Subscription: sub.id}, nil
+}
+
+// Unwatch ends a subscription, reporting whether it existed
+func (s *Server) Unwatch(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if ok {
+		delete(s.subs, id)
+		close(sub.events)
+	}
+	return ok
+}
+
+// Poll checks the files of every watched entry and, for each entry that
+// changed, resolves it again and notifies its subscribers. Run every
+// interval, it stands in for file system notifications.
+func (s *Server) Poll() {
+	type change struct {
+		entry    string
+		previous *project
+		files    []string
+	}
+	var changes []change
+	s.mu.Lock()
+	checked := make(map[string]bool)
+	for _, sub := range s.subs {
+		if checked[sub.entry] {
+			continue
+		}
+		checked[sub.entry] = true
+
+		p := s.projects[sub.entry]
+		if p == nil {
+			continue
+		}
+		if files := p.changedFiles(); len(files) > 0 {
+			changes = append(changes, change{entry: sub.entry, previous: p, files: files})
+		}
+	}
+	s.mu.Unlock()
+
+	// Entries are resolved without holding s.mu, so requests go on meanwhile
+	for _, c := range changes {
+		var next *project
+		err := s.pool.Do(context.Background(), func() error {
+			var err error
+			next, err = s.resolve(c.entry)
+			return err
+		})
+		if err != nil {
+			next = &project{entry: c.entry, stamps: make(map[string]fileStamp), builds: make(map[bool]*BuildResult), err: err,
+				diagnostics: []Diagnostic{{File: c.entry, Severity: bundler.SeverityError, Message: err.Error()}}}
+			// Keep the new stamps so the same change is not reported again
+			for file, stamp := range c.previous.stamps {
+				next.stamps[file] = stamp
+			}
+			for _, file := range c.files {
+				next.stamps[file] = stampOf(file)
+			}
+		}
+		s.mu.Lock()
+		s.projects[c.entry] = next
+		s.notify(Event{Entry: c.entry, Changed: c.files, Diagnostics: next.diagnostics})
+		s.mu.Unlock()
+	}
+}
+
+// notify sends an event to every subscriber of its entry, dropping it for
+// subscribers too slow to keep up. Callers hold s.mu.
+func (s *Server) notify(event Event) {
+	for _, sub := range s.subs {
+		if sub.entry != event.Entry {
+			continue
+		}
+		event.Subscription = sub.id
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
+
+// Run polls watched entries every interval until stop is closed
+func (s *Server) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Poll()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// serveEvents streams a subscription's events until the client disconnects
+// or the subscription ends
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sub := s.subs[r.URL.Query().Get("subscription")]
+	s.mu.Unlock()
+	if sub == nil {
+		http.Error(w, "unknown subscription", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: changed\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
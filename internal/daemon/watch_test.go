@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollNotifiesSubscribers(t *testing.T) {
+	entry := writeProject(t)
+	s := newTestServer()
+
+	sub, err := s.Watch(entry)
+	require.NoError(t, err)
+	s.Poll()
+	assert.Empty(t, s.subs[sub.Subscription].events, "nothing changed yet")
+
+	helper := filepath.Join(filepath.Dir(entry), "utils", "helper.lua")
+	touch(t, helper, "string.shout = nil\nreturn {}\n")
+	s.Poll()
+
+	event := <-s.subs[sub.Subscription].events
+	assert.Equal(t, sub.Subscription, event.Subscription)
+	assert.Equal(t, entry, event.Entry)
+	assert.Equal(t, []string{helper}, event.Changed)
+	require.Len(t, event.Diagnostics, 2)
+	assert.Equal(t, helper, event.Diagnostics[1].File)
+
+	s.Poll()
+	assert.Empty(t, s.subs[sub.Subscription].events, "a change is reported once")
+
+	assert.True(t, s.Unwatch(sub.Subscription))
+	assert.False(t, s.Unwatch(sub.Subscription))
+}
+
+func TestServeEventsStreamsChanges(t *testing.T) {
+	entry := writeProject(t)
+	s := newTestServer()
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp := rpc(t, srv.URL, "watch", map[string]string{"entry": entry})
+	require.Nil(t, resp.Error)
+	id := resp.Result.(map[string]interface{})["subscription"].(string)
+
+	events, err := http.Get(srv.URL + "/events?subscription=" + id)
+	require.NoError(t, err)
+	defer events.Body.Close()
+	assert.Equal(t, "text/event-stream", events.Header.Get("Content-Type"))
+
+	touch(t, entry, "return nil\n")
+	s.Poll()
+
+	reader := bufio.NewReader(events.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: changed\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+	assert.Equal(t, []string{entry}, event.Changed)
+	assert.Empty(t, event.Diagnostics)
+
+	missing, err := http.Get(srv.URL + "/events?subscription=nope")
+	require.NoError(t, err)
+	missing.Body.Close()
+	assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+}
@@ -0,0 +1,296 @@
+package doctor
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/constt/lua-bundler/internal/lockfile"
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/constt/lua-bundler/internal/semver"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+// Result is the outcome of a single check, with a suggested fix when it did not pass
+type Result struct {
+	Check   string
+	Status  Status
+	Message string
+	Fix     string
+}
+
+func pass(check, format string, args ...interface{}) Result {
+	return Result{Check: check, Status: Pass, Message: fmt.Sprintf(format, args...)}
+}
+
+// CacheDir checks that the HTTP cache directory can be written
+func CacheDir(dir string) Result {
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return Result{
+			Check:   "cache",
+			Status:  Fail,
+			Message: fmt.Sprintf("cache directory %s is not writable: %v", dir, err),
+			Fix:     fmt.Sprintf("fix the permissions of %s, or build with --no-cache", dir),
+		}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return pass("cache", "%s is writable", dir)
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Config checks the settings of a loaded config file; paths are relative to projectDir
+func Config(cfg *config.Config, projectDir string) []Result {
+	var results []Result
+	name := cfg.Path()
+	if name == "" {
+		return []Result{pass("config", "no %s (defaults are used)", config.FileName)}
+	}
+
+	if cfg.Target != "" && !bundler.IsValidTarget(cfg.Target) {
+		results = append(results, Result{
+			Check:   "config",
+			Status:  Fail,
+			Message: fmt.Sprintf("unknown target %q", cfg.Target),
+			Fix:     fmt.Sprintf("set \"target\" in %s to one of: %s", name, strings.Join(bundler.Targets, ", ")),
+		})
+	}
+
+	for _, module := range sortedKeys(cfg.Variants) {
+		for _, target := range sortedKeys(cfg.Variants[module]) {
+			path := cfg.Variants[module][target]
+			if !bundler.IsValidTarget(target) {
+				results = append(results, Result{
+					Check:   "config",
+					Status:  Fail,
+					Message: fmt.Sprintf("variant of %q uses unknown target %q", module, target),
+					Fix:     fmt.Sprintf("use one of: %s", strings.Join(bundler.Targets, ", ")),
+				})
+			}
+			if _, err := os.Stat(filepath.Join(projectDir, path)); err != nil {
+				results = append(results, Result{
+					Check:   "config",
+					Status:  Fail,
+					Message: fmt.Sprintf("%s variant of %q points to missing file %s", target, module, path),
+					Fix:     fmt.Sprintf("create %s or fix the path in %s (paths are relative to %s)", path, name, projectDir),
+				})
+			}
+		}
+	}
+
+	for _, source := range sortedKeys(cfg.Mirrors) {
+		for _, mirror := range append([]string{source}, cfg.Mirrors[source]...) {
+			if !bundler.IsURL(mirror) {
+				results = append(results, Result{
+					Check:   "config",
+					Status:  Fail,
+					Message: fmt.Sprintf("mirror entry %q is not an http(s) URL", mirror),
+					Fix:     fmt.Sprintf("fix the \"mirrors\" entry in %s", name),
+				})
+			}
+		}
+	}
+
+	if cfg.Shortener != "" && !strings.Contains(cfg.Shortener, "{url}") {
+		results = append(results, Result{
+			Check:   "config",
+			Status:  Fail,
+			Message: "shortener has no {url} placeholder",
+			Fix:     "add {url} where the long URL goes, e.g. https://is.gd/create.php?format=simple&url={url}",
+		})
+	}
+
+	if cfg.Version != "" {
+		if _, err := semver.Parse(cfg.Version); err != nil {
+			results = append(results, Result{
+				Check:   "config",
+				Status:  Warn,
+				Message: fmt.Sprintf("version %q is not a semantic version", cfg.Version),
+				Fix:     "`lua-bundler bump` needs MAJOR.MINOR.PATCH; set it with `lua-bundler bump 1.0.0`",
+			})
+		}
+	}
+
+	for _, define := range sortedKeys(cfg.Defines) {
+		if !identifierRegex.MatchString(define) || parser.IsKeyword(define) {
+			results = append(results, Result{
+				Check:   "config",
+				Status:  Fail,
+				Message: fmt.Sprintf("define %q is not a Lua identifier", define),
+				Fix:     fmt.Sprintf("rename it in the \"defines\" of %s", name),
+			})
+		}
+	}
+
+	for _, rule := range cfg.SuppressWarnings {
+		if !bundler.IsWarningRule(rule) {
+			results = append(results, Result{
+				Check:   "config",
+				Status:  Fail,
+				Message: fmt.Sprintf("unknown warning rule %q in suppressWarnings", rule),
+				Fix:     fmt.Sprintf("use one of: %s", strings.Join(bundler.WarningRules, ", ")),
+			})
+		}
+	}
+
+	for _, pattern := range cfg.KeepPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			results = append(results, Result{
+				Check:   "config",
+				Status:  Fail,
+				Message: fmt.Sprintf("keep pattern %q is not a valid regular expression", pattern),
+				Fix:     fmt.Sprintf("fix the \"keepPatterns\" entry in %s (Go regexp syntax)", name),
+			})
+		}
+	}
+
+	if cfg.LogShim != "" && !bundler.IsLogLevel(cfg.LogShim) {
+		results = append(results, Result{
+			Check:   "config",
+			Status:  Fail,
+			Message: fmt.Sprintf("unknown logShim level %q", cfg.LogShim),
+			Fix:     fmt.Sprintf("use one of: %s", strings.Join(bundler.LogLevels, ", ")),
+		})
+	}
+
+	if cfg.Loader != "" && !bundler.IsLoader(cfg.Loader) {
+		results = append(results, Result{
+			Check:   "config",
+			Status:  Fail,
+			Message: fmt.Sprintf("unknown loader %q", cfg.Loader),
+			Fix:     fmt.Sprintf("use one of: %s", strings.Join(bundler.Loaders, ", ")),
+		})
+	}
+
+	if len(results) == 0 {
+		results = append(results, pass("config", "%s is valid", name))
+	}
+	return results
+}
+
+var hashRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Lockfile checks that every pinned hash is well formed and that cached
+// copies still match them. cached returns the cached content of a URL.
+func Lockfile(lock *lockfile.Lockfile, cached func(url string) (string, bool)) []Result {
+	if lock == nil {
+		return []Result{pass("lockfile", "no lockfile (remote dependencies are not pinned)")}
+	}
+
+	var results []Result
+	for _, u := range lock.URLs() {
+		entry, _ := lock.Get(u)
+		if !hashRegex.MatchString(entry.SHA256) {
+			results = append(results, Result{
+				Check:   "lockfile",
+				Status:  Fail,
+				Message: fmt.Sprintf("%s has a malformed hash %q", u, entry.SHA256),
+				Fix:     fmt.Sprintf("remove the entry from %s and rebuild to pin it again", lock.Path()),
+			})
+			continue
+		}
+		if content, ok := cached(u); ok && !lock.Verify(u, content) {
+			results = append(results, Result{
+				Check:   "lockfile",
+				Status:  Warn,
+				Message: fmt.Sprintf("cached copy of %s does not match the lockfile", u),
+				Fix:     "build with --no-cache to download it again; if the remote changed on purpose, re-pin it",
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		results = append(results, pass("lockfile", "%s: %d pinned dependencies", lock.Path(), len(lock.URLs())))
+	}
+	return results
+}
+
+// Hosts checks that the hosts of urls can be reached. ping returns an error
+// when a request to a URL fails at the network level.
+func Hosts(urls []string, ping func(url string) error) []Result {
+	seen := make(map[string]bool)
+	var roots []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		root := u.Scheme + "://" + u.Host + "/"
+		if !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+	sort.Strings(roots)
+
+	if len(roots) == 0 {
+		return []Result{pass("network", "no remote hosts configured")}
+	}
+
+	var results []Result
+	for _, root := range roots {
+		if err := ping(root); err != nil {
+			results = append(results, Result{
+				Check:   "network",
+				Status:  Fail,
+				Message: fmt.Sprintf("%s is unreachable: %v", root, err),
+				Fix:     "check your connection, or set --proxy / HTTPS_PROXY if you are behind a proxy",
+			})
+		} else {
+			results = append(results, pass("network", "%s is reachable", root))
+		}
+	}
+	return results
+}
+
+// optionalTools are external programs some features use
+var optionalTools = []struct {
+	name, purpose string
+}{
+	{"git", "needed for `release` to find the GitHub repository"},
+	{"luau-compile", "lets you syntax-check Luau bundles"},
+	{"luau", "lets you run Luau bundles locally and run `verify-obfuscation`"},
+}
+
+// Tools reports which optional tools are on the PATH; missing ones are warnings
+func Tools(lookPath func(string) (string, error)) []Result {
+	var results []Result
+	for _, tool := range optionalTools {
+		if path, err := lookPath(tool.name); err == nil {
+			results = append(results, pass("tools", "%s: %s", tool.name, path))
+		} else {
+			results = append(results, Result{
+				Check:   "tools",
+				Status:  Warn,
+				Message: fmt.Sprintf("%s not found (%s)", tool.name, tool.purpose),
+				Fix:     fmt.Sprintf("install %s and add it to your PATH", tool.name),
+			})
+		}
+	}
+	return results
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
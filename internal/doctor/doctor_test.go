@@ -0,0 +1,125 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/config"
+	"github.com/constt/lua-bundler/internal/lockfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func statuses(results []Result) []Status {
+	var s []Status
+	for _, r := range results {
+		s = append(s, r.Status)
+	}
+	return s
+}
+
+func TestCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, Pass, CacheDir(dir).Status)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the probe file should be removed")
+
+	r := CacheDir(filepath.Join(dir, "missing"))
+	assert.Equal(t, Fail, r.Status)
+	assert.NotEmpty(t, r.Fix)
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "net.lua"), []byte(""), 0644))
+	path := filepath.Join(dir, config.FileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{
+	"target": "lua51",
+	"variants": {"net": {"roblox": "net.lua"}},
+	"version": "1.0.0",
+	"suppressWarnings": ["global-shadow"]
+}`), 0644))
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Status{Pass}, statuses(Config(cfg, dir)))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{
+	"target": "lua99",
+	"variants": {"net": {"roblox": "missing.lua"}},
+	"mirrors": {"https://a.example/x.lua": ["ftp://b.example/x.lua"]},
+	"shortener": "https://is.gd/create.php",
+	"version": "latest",
+	"defines": {"end": "1"},
+	"suppressWarnings": ["shadowing"],
+	"keepPatterns": ["[ERROR"],
+	"logShim": "verbose",
+	"loader": "eager"
+}`), 0644))
+	cfg, err = config.Load(path)
+	require.NoError(t, err)
+	results := Config(cfg, dir)
+	assert.Equal(t, []Status{Fail, Fail, Fail, Fail, Warn, Fail, Fail, Fail, Fail, Fail}, statuses(results))
+	for _, r := range results {
+		assert.NotEmpty(t, r.Fix, r.Message)
+	}
+
+	assert.Equal(t, []Status{Pass}, statuses(Config(&config.Config{}, dir)), "no config is fine")
+}
+
+func TestLockfile(t *testing.T) {
+	assert.Equal(t, []Status{Pass}, statuses(Lockfile(nil, nil)))
+
+	lock, err := lockfile.Load(filepath.Join(t.TempDir(), lockfile.FileName))
+	require.NoError(t, err)
+	lock.Set("https://example.com/a.lua", lockfile.Entry{SHA256: lockfile.Hash("a")})
+	lock.Set("https://example.com/b.lua", lockfile.Entry{SHA256: lockfile.Hash("b")})
+	lock.Set("https://example.com/c.lua", lockfile.Entry{SHA256: "xyz"})
+
+	cached := map[string]string{
+		"https://example.com/a.lua": "a",
+		"https://example.com/b.lua": "tampered",
+	}
+	results := Lockfile(lock, func(url string) (string, bool) {
+		content, ok := cached[url]
+		return content, ok
+	})
+	assert.Equal(t, []Status{Warn, Fail}, statuses(results))
+	assert.Contains(t, results[0].Message, "b.lua")
+	assert.Contains(t, results[1].Message, "malformed")
+}
+
+func TestHosts(t *testing.T) {
+	var pinged []string
+	results := Hosts([]string{
+		"https://good.example/a.lua",
+		"https://good.example/b.lua",
+		"https://bad.example/c.lua",
+		"https://is.gd/create.php?url={url}",
+	}, func(url string) error {
+		pinged = append(pinged, url)
+		if url == "https://bad.example/" {
+			return errors.New("no such host")
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"https://bad.example/", "https://good.example/", "https://is.gd/"}, pinged, "each host should be pinged once")
+	assert.Equal(t, []Status{Fail, Pass, Pass}, statuses(results))
+
+	assert.Equal(t, []Status{Pass}, statuses(Hosts(nil, nil)))
+}
+
+func TestTools(t *testing.T) {
+	results := Tools(func(name string) (string, error) {
+		if name == "git" {
+			return "/usr/bin/git", nil
+		}
+		return "", errors.New("not found")
+	})
+	require.Len(t, results, len(optionalTools))
+	assert.Equal(t, Pass, results[0].Status)
+	assert.Equal(t, Warn, results[1].Status)
+}
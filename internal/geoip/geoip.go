@@ -0,0 +1,343 @@
+// Package geoip looks up the country and autonomous system of IP addresses
+// in MaxMind DB files, such as GeoLite2-Country.mmdb and GeoLite2-ASN.mmdb
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata at the end of a database
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Record is what a database knows about an address. Fields the database
+// does not have are zero.
+type Record struct {
+	Country string // ISO 3166-1 alpha-2 code, such as "DE"
+	ASN     uint   // autonomous system number
+	Org     string // organization of the autonomous system
+}
+
+// Reader reads a MaxMind DB held in memory
+type Reader struct {
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint // offset of the data section
+	ipv4Start  uint // node reached after the 96 zero bits IPv4 addresses sit under
+	dbType     string
+}
+
+// Open reads the database at path
+func Open(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	r, err := New(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// New reads a database from its bytes
+func New(data []byte) (*Reader, error) {
+	at := bytes.LastIndex(data, metadataMarker)
+	if at < 0 {
+		return nil, errors.New("not a MaxMind DB: metadata not found")
+	}
+	d := decoder{data: data[at+len(metadataMarker):]}
+	value, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	meta, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid metadata: not a map")
+	}
+
+	r := &Reader{data: data}
+	r.nodeCount, _ = toUint(meta["node_count"])
+	r.recordSize, _ = toUint(meta["record_size"])
+	r.ipVersion, _ = toUint(meta["ip_version"])
+	r.dbType, _ = meta["database_type"].(string)
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	r.dataStart = treeSize + 16
+	if r.dataStart > uint(at) {
+		return nil, errors.New("invalid database: search tree overlaps metadata")
+	}
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// Type returns the database type, such as "GeoLite2-Country"
+func (r *Reader) Type() string {
+	return r.dbType
+}
+
+// Lookup returns what the database knows about ip, and false when it
+// has no entry for it
+func (r *Reader) Lookup(ip net.IP) (Record, bool, error) {
+	value, found, err := r.lookup(ip)
+	if err != nil || !found {
+		return Record{}, found, err
+	}
+	entry, _ := value.(map[string]any)
+
+	var rec Record
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := entry[key].(map[string]any); ok {
+			if code, ok := country["iso_code"].(string); ok && code != "" {
+				rec.Country = code
+				break
+			}
+		}
+	}
+	rec.ASN, _ = toUint(entry["autonomous_system_number"])
+	rec.Org, _ = entry["autonomous_system_organization"].(string)
+	return rec, true, nil
+}
+
+// lookup walks the search tree to the data of ip
+func (r *Reader) lookup(ip net.IP) (any, bool, error) {
+	node := uint(0)
+	bits := ip.To16()
+	if bits == nil {
+		return nil, false, fmt.Errorf("invalid IP address %v", ip)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		bits = v4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, false, nil
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, false, nil
+	}
+	if node < r.nodeCount {
+		return nil, false, errors.New("invalid database: search tree deeper than the address")
+	}
+
+	offset := node - r.nodeCount - 16
+	d := decoder{data: r.data[r.dataStart:]}
+	value, _, err := d.decode(offset)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid data record: %w", err)
+	}
+	return value, true, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node
+func (r *Reader) record(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.data[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.data[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.data[node*8+bit*4:]))
+	}
+}
+
+// Data section types
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes values of a data section
+type decoder struct {
+	data []byte
+}
+
+// decode returns the value at offset and the offset after it
+func (d decoder) decode(offset uint) (any, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		value, _, err := d.decode(size)
+		return value, offset, err
+	}
+	return d.value(typ, size, offset)
+}
+
+// control reads the control byte at offset, returning the type and the
+// size, or the target of a pointer
+func (d decoder) control(offset uint) (int, uint, uint, error) {
+	b, offset, err := d.bytes(offset, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctrl := b[0]
+	typ := int(ctrl >> 5)
+	if typ == typePointer {
+		n := uint(ctrl>>3&0x3) + 1
+		p, next, err := d.bytes(offset, n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		target := uint(0)
+		if n < 4 {
+			target = uint(ctrl & 0x7)
+		}
+		for _, c := range p {
+			target = target<<8 | uint(c)
+		}
+		target += [...]uint{0, 2048, 526336, 0}[n-1]
+		return typ, target, next, nil
+	}
+	if typ == typeExtended {
+		ext, next, err := d.bytes(offset, 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		typ, offset = 7+int(ext[0]), next
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		ext, next, err := d.bytes(offset, n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		extra := uint(0)
+		for _, c := range ext {
+			extra = extra<<8 | uint(c)
+		}
+		size = [...]uint{29, 285, 65821}[n-1] + extra
+		offset = next
+	}
+	return typ, size, offset, nil
+}
+
+// value decodes a value of typ and size starting at offset
+func (d decoder) value(typ int, size, offset uint) (any, uint, error) {
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			if m[name], offset, err = d.decode(next); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a, offset = append(a, v), next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	b, next, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64, typeInt32:
+		if size > 8 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		n := uint64(0)
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		if typ == typeInt32 {
+			return int32(uint32(n)), next, nil
+		}
+		return n, next, nil
+	case typeBytes, typeUint128:
+		return b, next, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// bytes returns n bytes at offset and the offset after them
+func (d decoder) bytes(offset, n uint) ([]byte, uint, error) {
+	if offset+n > uint(len(d.data)) || offset+n < offset {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	return d.data[offset : offset+n], offset + n, nil
+}
+
+// toUint converts a decoded unsigned integer
+func toUint(v any) (uint, bool) {
+	n, ok := v.(uint64)
+	return uint(n), ok
+}
@@ -0,0 +1,248 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// encoder writes values of a data section
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) control(typ int, size int) {
+	// Extended types have type 0 in the control byte and 7 less in the next
+	ctrl := byte(0)
+	if typ <= 7 {
+		ctrl = byte(typ) << 5
+	}
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+	default:
+		panic("size not supported by the test encoder")
+	}
+	e.WriteByte(ctrl)
+	if typ > 7 {
+		e.WriteByte(byte(typ - 7))
+	}
+	if size >= 29 {
+		e.WriteByte(byte(size - 29))
+	}
+}
+
+func (e *encoder) value(v any) {
+	switch v := v.(type) {
+	case string:
+		e.control(typeString, len(v))
+		e.WriteString(v)
+	case uint32:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], v)
+		trimmed := bytes.TrimLeft(b[:], "\x00")
+		e.control(typeUint32, len(trimmed))
+		e.Write(trimmed)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		e.control(typeBool, size)
+	case []any:
+		e.control(typeArray, len(v))
+		for _, item := range v {
+			e.value(item)
+		}
+	case pointer:
+		e.WriteByte(0x20 | byte(v>>8&0x7))
+		e.WriteByte(byte(v))
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.control(typeMap, len(v))
+		for _, key := range keys {
+			e.value(key)
+			e.value(v[key])
+		}
+	default:
+		panic("type not supported by the test encoder")
+	}
+}
+
+// pointer is an 11 bit pointer into the data section
+type pointer uint16
+
+// trieNode is a node of the search tree being written
+type trieNode struct {
+	children [2]*trieNode
+	data     int // offset in the data section + 1 for leaves, 0 otherwise
+}
+
+// writeDB returns a database mapping networks to records
+func writeDB(t *testing.T, ipVersion, recordSize int, networks map[string]any) []byte {
+	t.Helper()
+	var data encoder
+	root := &trieNode{}
+	cidrs := make([]string, 0, len(networks))
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := network.Mask.Size()
+		ip := []byte(network.IP)
+		if ipVersion == 6 && len(ip) == 4 {
+			ip = append(make([]byte, 12), ip...)
+			ones += 96
+		}
+		offset := data.Len()
+		data.value(networks[cidr])
+
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{}
+			}
+			node = node.children[bit]
+		}
+		node.data = offset + 1
+	}
+
+	// Number the inner nodes breadth first
+	var nodes []*trieNode
+	number := map[*trieNode]int{}
+	for queue := []*trieNode{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		number[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, child := range n.children {
+			if child != nil && child.data == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+	count := len(nodes)
+	recordValue := func(child *trieNode) uint32 {
+		switch {
+		case child == nil:
+			return uint32(count)
+		case child.data > 0:
+			return uint32(count + 16 + child.data - 1)
+		}
+		return uint32(number[child])
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		left, right := recordValue(n.children[0]), recordValue(n.children[1])
+		switch recordSize {
+		case 24:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>20&0xF0 | right>>24&0x0F), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			binary.Write(&out, binary.BigEndian, [2]uint32{left, right})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.Write(metadataMarker)
+	var meta encoder
+	meta.value(map[string]any{
+		"node_count":                  uint32(count),
+		"record_size":                 uint32(recordSize),
+		"ip_version":                  uint32(ipVersion),
+		"database_type":               "Test-DB",
+		"binary_format_major_version": uint32(2),
+	})
+	out.Write(meta.Bytes())
+	return out.Bytes()
+}
+
+var testNetworks = map[string]any{
+	"203.0.113.0/24": map[string]any{
+		"country":            map[string]any{"iso_code": "DE", "names": map[string]any{"en": "Germany"}},
+		"registered_country": map[string]any{"iso_code": "AT"},
+		"location":           map[string]any{"accuracy_radius": uint32(100)},
+	},
+	"198.51.100.0/25": map[string]any{
+		"registered_country":             map[string]any{"iso_code": "US"},
+		"autonomous_system_number":       uint32(64500),
+		"autonomous_system_organization": strings.Repeat("Example Hosting ", 3),
+		"is_anycast":                     true,
+		"subdivisions":                   []any{map[string]any{"iso_code": "CA"}},
+	},
+}
+
+func TestLookup(t *testing.T) {
+	for _, tc := range []struct{ ipVersion, recordSize int }{{4, 24}, {6, 24}, {6, 28}, {4, 32}} {
+		r, err := New(writeDB(t, tc.ipVersion, tc.recordSize, testNetworks))
+		if err != nil {
+			t.Fatalf("v%d/%d: New failed: %v", tc.ipVersion, tc.recordSize, err)
+		}
+		if r.Type() != "Test-DB" {
+			t.Errorf("Type = %q", r.Type())
+		}
+
+		rec, found, err := r.Lookup(net.ParseIP("203.0.113.7"))
+		if err != nil || !found || rec.Country != "DE" || rec.ASN != 0 {
+			t.Errorf("v%d/%d: 203.0.113.7 = %+v found=%v err=%v", tc.ipVersion, tc.recordSize, rec, found, err)
+		}
+		rec, found, err = r.Lookup(net.ParseIP("198.51.100.99"))
+		want := Record{Country: "US", ASN: 64500, Org: strings.Repeat("Example Hosting ", 3)}
+		if err != nil || !found || rec != want {
+			t.Errorf("v%d/%d: 198.51.100.99 = %+v found=%v err=%v, want the registered country", tc.ipVersion, tc.recordSize, rec, found, err)
+		}
+		for _, ip := range []string{"198.51.100.200", "192.0.2.1", "2001:db8::1"} {
+			if rec, found, err := r.Lookup(net.ParseIP(ip)); err != nil || found {
+				t.Errorf("v%d/%d: %s should not be found, got %+v err=%v", tc.ipVersion, tc.recordSize, ip, rec, err)
+			}
+		}
+	}
+}
+
+func TestDecodePointer(t *testing.T) {
+	var data encoder
+	data.value("JP")
+	start := data.Len()
+	data.value(map[string]any{"country": map[string]any{"iso_code": pointer(0)}})
+
+	value, _, err := decoder{data: data.Bytes()}.decode(uint(start))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	country := value.(map[string]any)["country"].(map[string]any)
+	if country["iso_code"] != "JP" {
+		t.Errorf("Pointer resolved to %v", country["iso_code"])
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.mmdb")
+	os.WriteFile(path, []byte("not a database"), 0644)
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "metadata not found") {
+		t.Errorf("Expected a metadata error, got %v", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Missing databases should fail")
+	}
+	if _, err := New(append([]byte{1, 2, 3}, metadataMarker...)); err == nil {
+		t.Error("Truncated metadata should fail")
+	}
+}
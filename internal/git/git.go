@@ -0,0 +1,109 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// commitPattern matches an abbreviated or full commit hash
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// ParseSpec splits "repo@ref" into the repository and the ref, a branch,
+// tag or commit. The ref is empty when spec names none. An @ before the
+// repository path, as in git@github.com:user/proj, belongs to the URL.
+func ParseSpec(spec string) (repo, ref string) {
+	pathStart := 0
+	if i := strings.Index(spec, "://"); i >= 0 {
+		if j := strings.Index(spec[i+3:], "/"); j >= 0 {
+			pathStart = i + 3 + j
+		} else {
+			pathStart = len(spec)
+		}
+	} else if i := strings.Index(spec, ":"); i >= 0 {
+		pathStart = i
+	}
+
+	at := strings.LastIndex(spec, "@")
+	if at <= pathStart {
+		return spec, ""
+	}
+	return spec[:at], spec[at+1:]
+}
+
+// Clone makes a shallow clone of repo at ref into dir, an empty or missing
+// directory. An empty ref clones the default branch. Commit hashes are
+// fetched directly, which needs a server allowing it, as GitHub does.
+func Clone(ctx context.Context, repo, ref, dir string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid git ref %q", ref)
+	}
+
+	if commitPattern.MatchString(ref) {
+		if err := run(ctx, "", "init", "--quiet", dir); err != nil {
+			return err
+		}
+		if err := run(ctx, dir, "fetch", "--quiet", "--depth", "1", "--", repo, ref); err != nil {
+			return err
+		}
+		return run(ctx, dir, "checkout", "--quiet", "FETCH_HEAD")
+	}
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	return run(ctx, "", append(args, "--", repo, dir)...)
+}
+
+// Info identifies the revision checked out in a working tree
+type Info struct {
+	Commit string // full hash of HEAD
+	Tag    string // tag pointing at HEAD, "" when none does
+	Dirty  bool   // tracked files differ from HEAD
+}
+
+// Describe returns the revision of the working tree containing dir. It
+// fails outside a git repository, in one without commits, or without git.
+func Describe(ctx context.Context, dir string) (Info, error) {
+	commit, err := output(ctx, dir, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Commit: commit}
+	// A commit without a tag makes describe fail; that is not an error here
+	info.Tag, _ = output(ctx, dir, "describe", "--tags", "--exact-match", "HEAD")
+	status, err := output(ctx, dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return Info{}, err
+	}
+	info.Dirty = status != ""
+	return info, nil
+}
+
+// run runs git in dir, without prompting for credentials
+func run(ctx context.Context, dir string, args ...string) error {
+	_, err := output(ctx, dir, args...)
+	return err
+}
+
+// output runs git in dir and returns its trimmed standard output
+func output(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
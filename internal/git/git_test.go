@@ -0,0 +1,113 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec, repo, ref string
+	}{
+		{"https://github.com/user/proj@v1.4.0", "https://github.com/user/proj", "v1.4.0"},
+		{"https://github.com/user/proj", "https://github.com/user/proj", ""},
+		{"https://github.com/user/proj.git@feature/menu", "https://github.com/user/proj.git", "feature/menu"},
+		{"https://token@github.com/user/proj", "https://token@github.com/user/proj", ""},
+		{"https://token@github.com/user/proj@abc1234", "https://token@github.com/user/proj", "abc1234"},
+		{"git@github.com:user/proj", "git@github.com:user/proj", ""},
+		{"git@github.com:user/proj@main", "git@github.com:user/proj", "main"},
+	}
+	for _, tt := range tests {
+		repo, ref := ParseSpec(tt.spec)
+		assert.Equal(t, tt.repo, repo, tt.spec)
+		assert.Equal(t, tt.ref, ref, tt.spec)
+	}
+}
+
+// gitRepo creates a repository with a commit on main tagged v1.0.0 and a
+// later commit, returning its file:// URL and the first commit's hash
+func gitRepo(t *testing.T) (string, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	gitCmd := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	gitCmd("init", "--quiet", "--initial-branch=main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.lua"), []byte("return 1"), 0644))
+	gitCmd("add", ".")
+	gitCmd("commit", "--quiet", "-m", "first")
+	gitCmd("tag", "v1.0.0")
+	first := gitCmd("rev-parse", "HEAD")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.lua"), []byte("return 2"), 0644))
+	gitCmd("commit", "--quiet", "-am", "second")
+	return "file://" + filepath.ToSlash(dir), first
+}
+
+func TestClone(t *testing.T) {
+	repo, first := gitRepo(t)
+
+	tests := []struct {
+		name, ref, want string
+	}{
+		{"default branch", "", "return 2"},
+		{"branch", "main", "return 2"},
+		{"tag", "v1.0.0", "return 1"},
+		{"commit", first, "return 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "checkout")
+			require.NoError(t, Clone(context.Background(), repo, tt.ref, dir))
+			data, err := os.ReadFile(filepath.Join(dir, "main.lua"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+
+	t.Run("missing ref", func(t *testing.T) {
+		err := Clone(context.Background(), repo, "v9.9.9", filepath.Join(t.TempDir(), "checkout"))
+		assert.ErrorContains(t, err, "git clone failed")
+	})
+
+	t.Run("option-like ref", func(t *testing.T) {
+		err := Clone(context.Background(), repo, "--upload-pack=evil", filepath.Join(t.TempDir(), "checkout"))
+		assert.ErrorContains(t, err, "invalid git ref")
+	})
+}
+
+func TestDescribe(t *testing.T) {
+	repo, first := gitRepo(t)
+	dir := strings.TrimPrefix(repo, "file://")
+
+	info, err := Describe(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Len(t, info.Commit, 40)
+	assert.NotEqual(t, first, info.Commit)
+	assert.Empty(t, info.Tag, "HEAD is not tagged")
+	assert.False(t, info.Dirty)
+
+	checkout := filepath.Join(t.TempDir(), "checkout")
+	require.NoError(t, Clone(context.Background(), repo, "v1.0.0", checkout))
+	require.NoError(t, os.WriteFile(filepath.Join(checkout, "main.lua"), []byte("return 3"), 0644))
+	info, err = Describe(context.Background(), checkout)
+	require.NoError(t, err)
+	assert.Equal(t, first, info.Commit)
+	assert.Equal(t, "v1.0.0", info.Tag)
+	assert.True(t, info.Dirty)
+
+	_, err = Describe(context.Background(), t.TempDir())
+	assert.Error(t, err, "a directory outside a repository has no revision")
+}
@@ -0,0 +1,191 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the GitHub REST API endpoint
+const DefaultAPIURL = "https://api.github.com"
+
+// Client talks to the GitHub releases API
+type Client struct {
+	apiURL     string
+	token      string
+	httpClient *http.Client
+}
+
+// Release is a GitHub release
+type Release struct {
+	ID         int64   `json:"id"`
+	TagName    string  `json:"tag_name"`
+	Name       string  `json:"name"`
+	Body       string  `json:"body"`
+	Draft      bool    `json:"draft"`
+	Prerelease bool    `json:"prerelease"`
+	HTMLURL    string  `json:"html_url"`
+	UploadURL  string  `json:"upload_url"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// ReleaseOptions are the fields set when creating or updating a release;
+// empty Name and Body leave an existing release's values unchanged
+type ReleaseOptions struct {
+	Tag        string
+	Target     string // commitish the tag is created from if it does not exist
+	Name       string
+	Body       string
+	Draft      bool
+	Prerelease bool
+}
+
+// NewClient creates a client authenticating with token against apiURL
+// (DefaultAPIURL when empty)
+func NewClient(token, apiURL string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Client{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// EnsureRelease returns the release for opts.Tag in repo ("owner/name"),
+// creating it if needed or updating it to match opts. The bool reports
+// whether the release was created.
+func (c *Client) EnsureRelease(repo string, opts ReleaseOptions) (*Release, bool, error) {
+	var existing Release
+	status, err := c.do(http.MethodGet, c.apiURL+"/repos/"+repo+"/releases/tags/"+url.PathEscape(opts.Tag), nil, "", &existing)
+	if err != nil && status != http.StatusNotFound {
+		return nil, false, err
+	}
+
+	payload := map[string]interface{}{
+		"tag_name":   opts.Tag,
+		"draft":      opts.Draft,
+		"prerelease": opts.Prerelease,
+	}
+	if opts.Name != "" {
+		payload["name"] = opts.Name
+	}
+	if opts.Body != "" {
+		payload["body"] = opts.Body
+	}
+
+	var release Release
+	if status == http.StatusNotFound {
+		if opts.Target != "" {
+			payload["target_commitish"] = opts.Target
+		}
+		if _, err := c.doJSON(http.MethodPost, c.apiURL+"/repos/"+repo+"/releases", payload, &release); err != nil {
+			return nil, false, fmt.Errorf("failed to create release %s: %w", opts.Tag, err)
+		}
+		return &release, true, nil
+	}
+
+	endpoint := fmt.Sprintf("%s/repos/%s/releases/%d", c.apiURL, repo, existing.ID)
+	if _, err := c.doJSON(http.MethodPatch, endpoint, payload, &release); err != nil {
+		return nil, false, fmt.Errorf("failed to update release %s: %w", opts.Tag, err)
+	}
+	return &release, false, nil
+}
+
+// UploadAsset attaches data to release as name, replacing an existing asset
+// with the same name
+func (c *Client) UploadAsset(repo string, release *Release, name string, data []byte) (*Asset, error) {
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			endpoint := fmt.Sprintf("%s/repos/%s/releases/assets/%d", c.apiURL, repo, asset.ID)
+			if _, err := c.do(http.MethodDelete, endpoint, nil, "", nil); err != nil {
+				return nil, fmt.Errorf("failed to replace asset %s: %w", name, err)
+			}
+		}
+	}
+
+	// upload_url is a URI template such as .../assets{?name,label}
+	uploadURL := release.UploadURL
+	if i := strings.Index(uploadURL, "{"); i >= 0 {
+		uploadURL = uploadURL[:i]
+	}
+	var asset Asset
+	if _, err := c.do(http.MethodPost, uploadURL+"?name="+url.QueryEscape(name), bytes.NewReader(data), "application/octet-stream", &asset); err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return &asset, nil
+}
+
+func (c *Client) doJSON(method, endpoint string, payload interface{}, out interface{}) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	return c.do(method, endpoint, bytes.NewReader(body), "application/json", out)
+}
+
+// do sends an API request, decoding a successful JSON response into out.
+// The status code is returned alongside errors for API failures.
+func (c *Client) do(method, endpoint string, body io.Reader, contentType string, out interface{}) (int, error) {
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return resp.StatusCode, fmt.Errorf("GitHub API %s: HTTP %d: %s", method, resp.StatusCode, apiErr.Message)
+		}
+		return resp.StatusCode, fmt.Errorf("GitHub API %s: HTTP %d", method, resp.StatusCode)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode GitHub response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+var remoteRegex = regexp.MustCompile(`^(?:https?://(?:[^@/]+@)?github\.com/|git@github\.com:|ssh://git@github\.com/)([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// RepoFromRemote returns "owner/name" for a GitHub git remote URL
+func RepoFromRemote(remote string) (string, error) {
+	m := remoteRegex.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil {
+		return "", fmt.Errorf("%q is not a GitHub remote", remote)
+	}
+	return m[1] + "/" + m[2], nil
+}
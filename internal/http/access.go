@@ -0,0 +1,231 @@
+package httpserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/geoip"
+)
+
+// AccessOptions restricts who may use the server. An address must pass
+// every rule set; empty lists do not restrict.
+type AccessOptions struct {
+	Allow          []string    // IPs and CIDRs allowed; every other address is refused when set
+	Deny           []string    // IPs and CIDRs refused
+	TrustedProxies []string    // IPs and CIDRs of proxies whose X-Forwarded-For header is believed
+	GeoIP          []GeoLookup // where countries and autonomous systems are looked up
+	AllowCountries []string    // ISO country codes allowed; every other country is refused when set
+	DenyCountries  []string    // ISO country codes refused
+	DenyASNs       []uint      // autonomous systems refused, such as those of hosting providers
+}
+
+// GeoLookup finds the country and autonomous system of an address.
+// *geoip.Reader looks them up in a MaxMind database.
+type GeoLookup interface {
+	Lookup(ip net.IP) (geoip.Record, bool, error)
+}
+
+// AccessControl decides which clients may use the server
+type AccessControl struct {
+	allow, deny, proxies []*net.IPNet
+	geo                  []GeoLookup
+	allowCountries       map[string]bool
+	denyCountries        map[string]bool
+	denyASNs             map[uint]bool
+}
+
+// NewAccessControl returns the access control of opts
+func NewAccessControl(opts AccessOptions) (*AccessControl, error) {
+	a := &AccessControl{geo: opts.GeoIP}
+	var err error
+	if a.allow, err = parseNetworks(opts.Allow); err != nil {
+		return nil, err
+	}
+	if a.deny, err = parseNetworks(opts.Deny); err != nil {
+		return nil, err
+	}
+	if a.proxies, err = parseNetworks(opts.TrustedProxies); err != nil {
+		return nil, err
+	}
+	a.allowCountries = countrySet(opts.AllowCountries)
+	a.denyCountries = countrySet(opts.DenyCountries)
+	if len(opts.DenyASNs) > 0 {
+		a.denyASNs = make(map[uint]bool)
+		for _, asn := range opts.DenyASNs {
+			a.denyASNs[asn] = true
+		}
+	}
+	if a.geoRules() && len(a.geo) == 0 {
+		return nil, fmt.Errorf("country and ASN rules need a GeoIP database to look addresses up in")
+	}
+	return a, nil
+}
+
+// Restricts reports whether any rule is set
+func (a *AccessControl) Restricts() bool {
+	return len(a.allow) > 0 || len(a.deny) > 0 || a.geoRules()
+}
+
+func (a *AccessControl) geoRules() bool {
+	return len(a.allowCountries) > 0 || len(a.denyCountries) > 0 || len(a.denyASNs) > 0
+}
+
+// ClientIP returns the address of the client making r. Behind trusted
+// proxies, it is the last address of X-Forwarded-For that is not one.
+// Peers on a UNIX socket are the local proxy forwarding to it, and are
+// always trusted.
+func (a *AccessControl) ClientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && !contains(a.proxies, ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !contains(a.proxies, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// Check returns why ip is refused, or nil when it may use the server
+func (a *AccessControl) Check(ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("unknown client address")
+	}
+	if contains(a.deny, ip) {
+		return fmt.Errorf("%s is denied", ip)
+	}
+	if len(a.allow) > 0 && !contains(a.allow, ip) {
+		return fmt.Errorf("%s is not in the allowlist", ip)
+	}
+	if !a.geoRules() {
+		return nil
+	}
+
+	rec, err := a.lookup(ip)
+	if err != nil {
+		return fmt.Errorf("looking up %s failed: %w", ip, err)
+	}
+	country := rec.Country
+	if country == "" {
+		country = "unknown"
+	}
+	if a.denyCountries[rec.Country] || (len(a.allowCountries) > 0 && !a.allowCountries[rec.Country]) {
+		return fmt.Errorf("%s is in country %s, which is not allowed", ip, country)
+	}
+	if a.denyASNs[rec.ASN] {
+		return fmt.Errorf("%s is in denied autonomous system AS%d (%s)", ip, rec.ASN, rec.Org)
+	}
+	return nil
+}
+
+// lookup merges what every GeoIP source knows about ip, the first source
+// knowing a field winning
+func (a *AccessControl) lookup(ip net.IP) (geoip.Record, error) {
+	var merged geoip.Record
+	for _, geo := range a.geo {
+		rec, found, err := geo.Lookup(ip)
+		if err != nil {
+			return merged, err
+		}
+		if !found {
+			continue
+		}
+		if merged.Country == "" {
+			merged.Country = rec.Country
+		}
+		if merged.ASN == 0 {
+			merged.ASN, merged.Org = rec.ASN, rec.Org
+		}
+	}
+	return merged, nil
+}
+
+// Wrap refuses the requests of clients failing the rules with 403 before
+// they reach h. Requests forwarded by trusted proxies reach h with the
+// client's address as their RemoteAddr, so logs show who made them.
+func (a *AccessControl) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := a.ClientIP(r)
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); ip != nil && (err != nil || host != ip.String()) {
+			r = r.WithContext(r.Context())
+			r.RemoteAddr = ip.String()
+		}
+		if !a.Restricts() {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if err := a.Check(ip); err != nil {
+			fmt.Printf("[%s] %s %s %s: %v\n",
+				time.Now().Format("15:04:05"),
+				warningStyle.Render("⛔"),
+				r.Method,
+				r.URL.Path,
+				err)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// parseNetworks parses IPs and CIDRs, an IP being a network of one address
+func parseNetworks(specs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if !strings.Contains(spec, "/") {
+			ip := net.ParseIP(spec)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address or CIDR %q", spec)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR %q", spec)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// contains reports whether ip is in any of networks
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// countrySet returns upper-cased country codes as a set, nil for none
+func countrySet(codes []string) map[string]bool {
+	if len(codes) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(strings.TrimSpace(code))] = true
+	}
+	return set
+}
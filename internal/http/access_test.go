@@ -0,0 +1,139 @@
+package httpserver
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/geoip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGeo looks addresses up in a map
+type fakeGeo map[string]geoip.Record
+
+func (f fakeGeo) Lookup(ip net.IP) (geoip.Record, bool, error) {
+	if ip.String() == "203.0.113.99" {
+		return geoip.Record{}, false, errors.New("corrupt database")
+	}
+	rec, found := f[ip.String()]
+	return rec, found, nil
+}
+
+func TestAccessControl_Networks(t *testing.T) {
+	a, err := NewAccessControl(AccessOptions{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.7"},
+		Deny:  []string{"10.6.0.0/16"},
+	})
+	require.NoError(t, err)
+	assert.True(t, a.Restricts())
+
+	assert.NoError(t, a.Check(net.ParseIP("10.1.2.3")))
+	assert.NoError(t, a.Check(net.ParseIP("192.0.2.7")))
+	assert.NoError(t, a.Check(net.ParseIP("2001:db8::1")))
+	assert.EqualError(t, a.Check(net.ParseIP("10.6.0.1")), "10.6.0.1 is denied", "deny wins over allow")
+	assert.EqualError(t, a.Check(net.ParseIP("192.0.2.8")), "192.0.2.8 is not in the allowlist")
+	assert.Error(t, a.Check(nil))
+}
+
+func TestAccessControl_Geo(t *testing.T) {
+	geo := fakeGeo{
+		"198.51.100.1": {Country: "DE", ASN: 3320, Org: "Deutsche Telekom"},
+		"198.51.100.2": {Country: "RU"},
+		"198.51.100.3": {Country: "NL", ASN: 14061, Org: "DigitalOcean"},
+	}
+	a, err := NewAccessControl(AccessOptions{
+		GeoIP:          []GeoLookup{geo},
+		AllowCountries: []string{"de", "NL"},
+		DenyASNs:       []uint{14061},
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, a.Check(net.ParseIP("198.51.100.1")))
+	assert.EqualError(t, a.Check(net.ParseIP("198.51.100.2")), "198.51.100.2 is in country RU, which is not allowed")
+	assert.EqualError(t, a.Check(net.ParseIP("198.51.100.3")), "198.51.100.3 is in denied autonomous system AS14061 (DigitalOcean)")
+	assert.EqualError(t, a.Check(net.ParseIP("198.51.100.4")), "198.51.100.4 is in country unknown, which is not allowed")
+	assert.ErrorContains(t, a.Check(net.ParseIP("203.0.113.99")), "corrupt database", "lookup errors refuse")
+}
+
+func TestAccessControl_MergesLookups(t *testing.T) {
+	countries := fakeGeo{"198.51.100.3": {Country: "NL"}}
+	asns := fakeGeo{"198.51.100.3": {ASN: 14061, Org: "DigitalOcean"}}
+	a, err := NewAccessControl(AccessOptions{GeoIP: []GeoLookup{countries, asns}, DenyCountries: []string{"RU"}, DenyASNs: []uint{14061}})
+	require.NoError(t, err)
+	assert.ErrorContains(t, a.Check(net.ParseIP("198.51.100.3")), "AS14061")
+}
+
+func TestNewAccessControl_Invalid(t *testing.T) {
+	_, err := NewAccessControl(AccessOptions{Deny: []string{"10.0.0.0/33"}})
+	assert.EqualError(t, err, `invalid IP address or CIDR "10.0.0.0/33"`)
+	_, err = NewAccessControl(AccessOptions{Allow: []string{"example.com"}})
+	assert.EqualError(t, err, `invalid IP address or CIDR "example.com"`)
+	_, err = NewAccessControl(AccessOptions{DenyCountries: []string{"RU"}})
+	assert.ErrorContains(t, err, "GeoIP database")
+
+	a, err := NewAccessControl(AccessOptions{TrustedProxies: []string{"127.0.0.1"}})
+	require.NoError(t, err)
+	assert.False(t, a.Restricts(), "trusted proxies alone refuse nobody")
+}
+
+func TestAccessControl_ClientIP(t *testing.T) {
+	a, err := NewAccessControl(AccessOptions{TrustedProxies: []string{"127.0.0.1", "172.16.0.0/12"}})
+	require.NoError(t, err)
+	request := func(remote string, forwarded ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/bundle.lua", nil)
+		req.RemoteAddr = remote
+		for _, f := range forwarded {
+			req.Header.Add("X-Forwarded-For", f)
+		}
+		return req
+	}
+
+	assert.Equal(t, "192.0.2.1", a.ClientIP(request("192.0.2.1:5000", "10.9.9.9")).String(), "untrusted peers cannot spoof")
+	assert.Equal(t, "192.0.2.1", a.ClientIP(request("127.0.0.1:5000", "10.9.9.9, 192.0.2.1")).String(), "the rightmost untrusted hop")
+	assert.Equal(t, "192.0.2.1", a.ClientIP(request("127.0.0.1:5000", "10.9.9.9", "192.0.2.1, 172.16.0.5")).String())
+	assert.Equal(t, "127.0.0.1", a.ClientIP(request("127.0.0.1:5000")).String())
+	assert.Equal(t, "172.16.0.5", a.ClientIP(request("127.0.0.1:5000", "garbage, 172.16.0.5")).String())
+}
+
+func TestAccessControl_Wrap(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("print(1)")) })
+
+	open, err := NewAccessControl(AccessOptions{})
+	require.NoError(t, err)
+	assert.NotNil(t, open.Wrap(next))
+
+	a, err := NewAccessControl(AccessOptions{Deny: []string{"192.0.2.0/24"}})
+	require.NoError(t, err)
+	h := a.Wrap(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/bundle.lua", nil)
+	req.RemoteAddr = "192.0.2.1:4000"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "print")
+
+	req.RemoteAddr = "198.51.100.1:4000"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "print(1)", rec.Body.String())
+}
+
+func TestAccessControl_ClientIP_Socket(t *testing.T) {
+	a, err := NewAccessControl(AccessOptions{})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/bundle.lua", nil)
+	req.RemoteAddr = "@"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	assert.Equal(t, "192.0.2.1", a.ClientIP(req).String(), "the proxy on the socket is trusted")
+
+	var remote string
+	a.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { remote = r.RemoteAddr })).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "192.0.2.1", remote, "handlers see the client")
+	assert.Equal(t, "@", req.RemoteAddr, "the request is not changed")
+}
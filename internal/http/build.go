@@ -0,0 +1,285 @@
+package httpserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/git"
+	"github.com/constt/lua-bundler/internal/playground"
+)
+
+// DefaultBuildMaxSize is the default limit of a build request and of the
+// project it unpacks to
+const DefaultBuildMaxSize = 10 << 20
+
+// cloneTimeout bounds how long fetching a git project may take
+const cloneTimeout = 2 * time.Minute
+
+// BuildOptions configures the POST /build endpoint
+type BuildOptions struct {
+	Token   string // bearer token clients must send; the endpoint is off when empty
+	MaxSize int64  // largest request body and unpacked project in bytes, DefaultBuildMaxSize when 0
+	Workers int    // builds run at once, one per CPU when 0; later requests wait
+}
+
+// buildRequest is the JSON body of a build from a git repository. Build
+// options sit next to the repository fields.
+type buildRequest struct {
+	Git string `json:"git"`
+	Ref string `json:"ref"`
+	playground.Options
+}
+
+// errTooLarge reports a request or project over the size limit
+var errTooLarge = errors.New("project exceeds the size limit")
+
+// buildHandler serves POST /build. A request either uploads a zipped
+// project (Content-Type application/zip, options in the query string) or
+// names a git repository in a JSON body. The response is the bundle, or
+// the bundle with its warnings and modules as JSON when the client accepts
+// application/json.
+type buildHandler struct {
+	opts  BuildOptions
+	pool  *bundler.Pool
+	clone func(ctx context.Context, repo, ref, dir string) error
+}
+
+// NewBuildHandler returns the handler of the build endpoint
+func NewBuildHandler(opts BuildOptions) http.Handler {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultBuildMaxSize
+	}
+	return &buildHandler{opts: opts, pool: bundler.NewPool(opts.Workers), clone: git.Clone}
+}
+
+func (h *buildHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lua-bundler"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, h.opts.MaxSize+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > h.opts.MaxSize {
+		http.Error(w, errTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var files map[string]string
+	var opts playground.Options
+	switch mediaType(r.Header.Get("Content-Type")) {
+	case "application/zip":
+		if opts, err = queryOptions(r.URL.Query()); err == nil {
+			files, err = h.unzip(body)
+		}
+	case "application/json":
+		var req buildRequest
+		if err = json.Unmarshal(body, &req); err != nil {
+			err = fmt.Errorf("invalid request: %w", err)
+		} else {
+			opts = req.Options
+			files, err = h.fetchGit(r.Context(), req.Git, req.Ref)
+		}
+	default:
+		http.Error(w, "Content-Type must be application/zip or application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	if errors.Is(err, errTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Waiting for a worker ends when the client gives up
+	var result *playground.Result
+	err = h.pool.Do(r.Context(), func() error {
+		var err error
+		result, err = playground.Bundle(files, opts)
+		return err
+	})
+	if r.Context().Err() != nil {
+		http.Error(w, "request canceled", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Lua-Bundler-Warnings", strconv.Itoa(len(result.Warnings)))
+	io.WriteString(w, result.Bundle)
+}
+
+// authorized reports whether the request carries the configured token
+func (h *buildHandler) authorized(r *http.Request) bool {
+	return bearerAuthorized(r, h.opts.Token)
+}
+
+// mediaType returns a Content-Type without its parameters
+func mediaType(contentType string) string {
+	media, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(media))
+}
+
+// queryOptions reads build options from query parameters: entry, release,
+// target, minify, loader, namespace and repeatable define=NAME=VALUE
+func queryOptions(query url.Values) (playground.Options, error) {
+	opts := playground.Options{
+		Entry:     query.Get("entry"),
+		Target:    query.Get("target"),
+		Loader:    query.Get("loader"),
+		Namespace: query.Get("namespace"),
+	}
+	if v := query.Get("release"); v != "" {
+		release, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid release %q", v)
+		}
+		opts.Release = release
+	}
+	if v := query.Get("minify"); v != "" {
+		level, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid minify level %q", v)
+		}
+		opts.Minify = &level
+	}
+	for _, define := range query["define"] {
+		name, value, ok := strings.Cut(define, "=")
+		if !ok {
+			return opts, fmt.Errorf("invalid define %q, expected NAME=VALUE", define)
+		}
+		if opts.Defines == nil {
+			opts.Defines = make(map[string]string)
+		}
+		opts.Defines[name] = value
+	}
+	return opts, nil
+}
+
+// unzip returns the files of a zip archive, refusing archives that unpack
+// past the size limit
+func (h *buildHandler) unzip(data []byte) (map[string]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	files := make(map[string]string)
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		// The declared size can lie, so the read itself is limited
+		content, err := io.ReadAll(io.LimitReader(rc, h.opts.MaxSize-total+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		if total += int64(len(content)); total > h.opts.MaxSize {
+			return nil, errTooLarge
+		}
+		files[f.Name] = string(content)
+	}
+	return files, nil
+}
+
+// fetchGit clones a repository at ref, the default branch when empty, and
+// returns its files
+func (h *buildHandler) fetchGit(ctx context.Context, repo, ref string) (map[string]string, error) {
+	if repo == "" {
+		return nil, fmt.Errorf("request needs a git repository URL")
+	}
+	u, err := url.Parse(repo)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("git repository must be an https URL, got %q", repo)
+	}
+	dir, err := os.MkdirTemp("", "lua-bundler-build-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(ctx, cloneTimeout)
+	defer cancel()
+	if err := h.clone(ctx, repo, ref, dir); err != nil {
+		return nil, err
+	}
+	return readProject(dir, h.opts.MaxSize)
+}
+
+// readProject returns the files under dir by slash-separated relative path,
+// leaving out the .git directory
+func readProject(dir string, maxSize int64) (map[string]string, error) {
+	files := make(map[string]string)
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if total += info.Size(); total > maxSize {
+			return errTooLarge
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,232 @@
+package httpserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var buildProject = map[string]string{
+	"main.lua":         `local helper = require("utils.helper")` + "\nprint(helper)\n",
+	"utils/helper.lua": "return {}\n",
+}
+
+func zipProject(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func postBuild(h http.Handler, target, contentType, token string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBuildHandler_Zip(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret"})
+
+	rec := postBuild(h, "/build?release=true", "application/zip", "secret", zipProject(t, buildProject))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `EmbeddedModules["utils.helper"]`)
+	assert.NotContains(t, rec.Body.String(), "print(helper)", "release mode should strip print")
+	assert.Equal(t, "0", rec.Header().Get("X-Lua-Bundler-Warnings"))
+}
+
+func TestBuildHandler_JSONResponse(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret"})
+
+	req := httptest.NewRequest(http.MethodPost, "/build", bytes.NewReader(zipProject(t, buildProject)))
+	req.Header.Set("Content-Type", "application/zip")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var result struct {
+		Bundle  string   `json:"bundle"`
+		Modules []string `json:"modules"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, []string{"utils.helper"}, result.Modules)
+	assert.Contains(t, result.Bundle, "print(helper)")
+}
+
+func TestBuildHandler_Git(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret"}).(*buildHandler)
+	var gotRepo, gotRef string
+	h.clone = func(ctx context.Context, repo, ref, dir string) error {
+		gotRepo, gotRef = repo, ref
+		for name, content := range buildProject {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	body := `{"git": "https://example.com/team/game.git", "ref": "v1.2.0", "release": true}`
+	rec := postBuild(h, "/build", "application/json", "secret", []byte(body))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "https://example.com/team/game.git", gotRepo)
+	assert.Equal(t, "v1.2.0", gotRef)
+	assert.Contains(t, rec.Body.String(), `EmbeddedModules["utils.helper"]`)
+
+	for _, body := range []string{
+		`{"git": "file:///etc"}`,
+		`{"git": "git@example.com:team/game.git"}`,
+		`{}`,
+	} {
+		rec := postBuild(h, "/build", "application/json", "secret", []byte(body))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestBuildHandler_Auth(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret"})
+	body := zipProject(t, buildProject)
+
+	assert.Equal(t, http.StatusUnauthorized, postBuild(h, "/build", "application/zip", "", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, postBuild(h, "/build", "application/zip", "wrong", body).Code)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/build", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestBuildHandler_Limits(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret", MaxSize: 4096})
+
+	t.Run("request body", func(t *testing.T) {
+		rec := postBuild(h, "/build", "application/zip", "secret", bytes.Repeat([]byte("x"), 5000))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("unpacked project", func(t *testing.T) {
+		// Compresses far below the limit but unpacks past it
+		files := map[string]string{"main.lua": "return 1\n", "big.lua": "--" + strings.Repeat("a", 8000)}
+		body := zipProject(t, files)
+		require.Less(t, len(body), 4096)
+		rec := postBuild(h, "/build", "application/zip", "secret", body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestBuildHandler_BadRequests(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret"})
+	project := zipProject(t, buildProject)
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        []byte
+		want        int
+	}{
+		{"unsupported type", "/build", "text/plain", project, http.StatusUnsupportedMediaType},
+		{"not a zip", "/build", "application/zip", []byte("nope"), http.StatusBadRequest},
+		{"bad release", "/build?release=maybe", "application/zip", project, http.StatusBadRequest},
+		{"bad define", "/build?define=DEBUG", "application/zip", project, http.StatusBadRequest},
+		{"bad json", "/build", "application/json", []byte("{"), http.StatusBadRequest},
+		{"missing entry", "/build?entry=src/main.lua", "application/zip", project, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postBuild(h, tt.target, tt.contentType, "secret", tt.body)
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestQueryOptions(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/build?entry=src/main.lua&release=1&target=luajit&minify=2&define=DEBUG=false&define=NAME=x=y", nil)
+	opts, err := queryOptions(req.URL.Query())
+	require.NoError(t, err)
+	assert.Equal(t, "src/main.lua", opts.Entry)
+	assert.True(t, opts.Release)
+	assert.Equal(t, "luajit", opts.Target)
+	require.NotNil(t, opts.Minify)
+	assert.Equal(t, 2, *opts.Minify)
+	assert.Equal(t, map[string]string{"DEBUG": "false", "NAME": "x=y"}, opts.Defines)
+}
+
+func TestReadProject(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "utils"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.lua"), []byte("return 1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "utils", "helper.lua"), []byte("return 2"), 0644))
+
+	files, err := readProject(dir, 100)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"main.lua": "return 1", "utils/helper.lua": "return 2"}, files)
+
+	_, err = readProject(dir, 10)
+	assert.ErrorIs(t, err, errTooLarge)
+}
+
+func TestBuildHandler_Parallel(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret", Workers: 2})
+	project := zipProject(t, buildProject)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 6)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = postBuild(h, "/build", "application/zip", "secret", project).Code
+		}(i)
+	}
+	wg.Wait()
+	for _, code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+}
+
+func TestBuildHandler_CanceledWhileWaiting(t *testing.T) {
+	h := NewBuildHandler(BuildOptions{Token: "secret", Workers: 1}).(*buildHandler)
+	release := make(chan struct{})
+	defer close(release)
+	go h.pool.Do(context.Background(), func() error { <-release; return nil })
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/build", bytes.NewReader(zipProject(t, buildProject))).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/zip")
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
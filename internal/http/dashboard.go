@@ -0,0 +1,235 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//go:embed dashboard
+var dashboardAssets embed.FS
+
+// recentRequestsKept is how many requests the dashboard lists
+const recentRequestsKept = 50
+
+// DashboardOptions configures the /dashboard page
+type DashboardOptions struct {
+	Token     string                 // password of the dashboard, sent with HTTP basic auth; the dashboard is off when empty
+	Rebuild   func() (string, error) // rebuilds the bundle, returning what the build printed; nil hides the button
+	BuildTime time.Duration          // how long the build before serving took
+}
+
+// RequestLog is a request the server answered
+type RequestLog struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Remote string    `json:"remote"`
+	Status int       `json:"status"`
+}
+
+// RebuildLog is the outcome of a rebuild started from the dashboard
+type RebuildLog struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Output   string        `json:"output"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// DashboardStatus is what the dashboard shows, as served by
+// /dashboard/status
+type DashboardStatus struct {
+	Bundle      string        `json:"bundle"`
+	Hash        string        `json:"hash"` // SHA-256 of the bundle
+	Size        int64         `json:"size"`
+	Modified    time.Time     `json:"modified"`
+	BuildTime   time.Duration `json:"buildTime"`
+	CanRebuild  bool          `json:"canRebuild"`
+	Rebuilding  bool          `json:"rebuilding"`
+	LastRebuild *RebuildLog   `json:"lastRebuild,omitempty"`
+	Requests    []RequestLog  `json:"requests"` // newest first
+}
+
+// Dashboard serves /dashboard: a page showing the served bundle and the
+// recent requests, with a button rebuilding the bundle
+type Dashboard struct {
+	bundlePath string
+	opts       DashboardOptions
+	static     http.Handler
+
+	mu          sync.Mutex
+	requests    []RequestLog
+	buildTime   time.Duration
+	rebuilding  bool
+	lastRebuild *RebuildLog
+}
+
+// NewDashboard returns the dashboard of the bundle at bundlePath
+func NewDashboard(bundlePath string, opts DashboardOptions) *Dashboard {
+	assets, _ := fs.Sub(dashboardAssets, "dashboard")
+	return &Dashboard{
+		bundlePath: bundlePath,
+		opts:       opts,
+		static:     http.StripPrefix("/dashboard/", http.FileServer(http.FS(assets))),
+		buildTime:  opts.BuildTime,
+	}
+}
+
+// Record adds a request to the recent ones
+func (d *Dashboard) Record(r *http.Request, status int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, RequestLog{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Remote: r.RemoteAddr,
+		Status: status,
+	})
+	if len(d.requests) > recentRequestsKept {
+		d.requests = d.requests[len(d.requests)-recentRequestsKept:]
+	}
+}
+
+// Status returns what the dashboard shows
+func (d *Dashboard) Status() (DashboardStatus, error) {
+	data, err := os.ReadFile(d.bundlePath)
+	if err != nil {
+		return DashboardStatus{}, err
+	}
+	info, err := os.Stat(d.bundlePath)
+	if err != nil {
+		return DashboardStatus{}, err
+	}
+	sum := sha256.Sum256(data)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	requests := make([]RequestLog, len(d.requests))
+	for i, req := range d.requests {
+		requests[len(requests)-1-i] = req
+	}
+	return DashboardStatus{
+		Bundle:      filepath.Base(d.bundlePath),
+		Hash:        hex.EncodeToString(sum[:]),
+		Size:        int64(len(data)),
+		Modified:    info.ModTime(),
+		BuildTime:   d.buildTime,
+		CanRebuild:  d.opts.Rebuild != nil,
+		Rebuilding:  d.rebuilding,
+		LastRebuild: d.lastRebuild,
+		Requests:    requests,
+	}, nil
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="lua-bundler dashboard"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.URL.Path {
+	case "/dashboard":
+		http.Redirect(w, r, "/dashboard/", http.StatusMovedPermanently)
+	case "/dashboard/status":
+		status, err := d.Status()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	case "/dashboard/rebuild":
+		d.serveRebuild(w, r)
+	default:
+		d.static.ServeHTTP(w, r)
+	}
+}
+
+// serveRebuild runs a rebuild and answers with its log. The page sends a
+// custom header, which cross-site forms cannot, so a logged-in browser
+// cannot be tricked into rebuilding.
+func (d *Dashboard) serveRebuild(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-Lua-Bundler-Dashboard") == "" {
+		http.Error(w, "missing X-Lua-Bundler-Dashboard header", http.StatusForbidden)
+		return
+	}
+	if d.opts.Rebuild == nil {
+		http.Error(w, "rebuilding is not available", http.StatusNotFound)
+		return
+	}
+
+	d.mu.Lock()
+	if d.rebuilding {
+		d.mu.Unlock()
+		http.Error(w, "a rebuild is already running", http.StatusConflict)
+		return
+	}
+	d.rebuilding = true
+	d.mu.Unlock()
+
+	started := time.Now()
+	output, err := d.opts.Rebuild()
+	log := &RebuildLog{Time: started, Duration: time.Since(started), Output: output}
+	if err != nil {
+		log.Error = err.Error()
+	}
+
+	d.mu.Lock()
+	d.rebuilding = false
+	d.lastRebuild = log
+	if err == nil {
+		d.buildTime = log.Duration
+	}
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(log)
+}
+
+// authorized reports whether the request carries the token as its basic
+// auth password; any user name is accepted
+func (d *Dashboard) authorized(r *http.Request) bool {
+	_, password, ok := r.BasicAuth()
+	return ok && tokenMatches(password, d.opts.Token)
+}
+
+// recorded lists the requests h answers on board, when there is one
+func recorded(board *Dashboard, h http.Handler) http.Handler {
+	if board == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		board.Record(r, rec.status)
+	})
+}
+
+// statusRecorder remembers the status code written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
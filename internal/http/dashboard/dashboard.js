@@ -0,0 +1,70 @@
+// Polls /dashboard/status and renders it; the rebuild button posts to
+// /dashboard/rebuild with the header the server requires.
+const $ = (id) => document.getElementById(id);
+
+function formatBytes(n) {
+  if (n >= 1 << 20) return (n / (1 << 20)).toFixed(1) + " MB";
+  if (n >= 1 << 10) return (n / (1 << 10)).toFixed(1) + " KB";
+  return n + " B";
+}
+
+function formatDuration(ns) {
+  const ms = ns / 1e6;
+  return ms < 1000 ? Math.round(ms) + "ms" : (ms / 1000).toFixed(1) + "s";
+}
+
+function cell(row, text, className) {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (className) td.className = className;
+}
+
+function render(status) {
+  $("bundle").textContent = status.bundle;
+  $("bundle").href = "/" + encodeURIComponent(status.bundle);
+  $("hash").textContent = status.hash.slice(0, 16);
+  $("hash").title = status.hash;
+  $("size").textContent = formatBytes(status.size);
+  $("modified").textContent = new Date(status.modified).toLocaleString();
+  $("build-time").textContent = status.buildTime ? formatDuration(status.buildTime) : "-";
+
+  $("rebuild-section").hidden = !status.canRebuild;
+  $("rebuild").disabled = status.rebuilding;
+  if (status.rebuilding) {
+    $("rebuild-state").textContent = "Rebuilding...";
+    $("rebuild-state").className = "";
+  } else if (status.lastRebuild) {
+    const last = status.lastRebuild;
+    $("rebuild-state").textContent = (last.error ? "Failed: " + last.error : "Rebuilt") +
+      " at " + new Date(last.time).toLocaleTimeString() + " in " + formatDuration(last.duration);
+    $("rebuild-state").className = last.error ? "error" : "ok";
+    $("rebuild-output").textContent = last.output;
+    $("rebuild-output").hidden = !last.output;
+  }
+
+  const body = $("requests");
+  body.replaceChildren();
+  for (const req of status.requests) {
+    const row = body.insertRow();
+    cell(row, new Date(req.time).toLocaleTimeString());
+    cell(row, req.method);
+    cell(row, req.path);
+    cell(row, req.status, req.status >= 400 ? "error" : "");
+    cell(row, req.remote);
+  }
+}
+
+async function refresh() {
+  const resp = await fetch("status", { cache: "no-store" });
+  if (resp.ok) render(await resp.json());
+}
+
+$("rebuild").addEventListener("click", async () => {
+  $("rebuild").disabled = true;
+  $("rebuild-state").textContent = "Rebuilding...";
+  await fetch("rebuild", { method: "POST", headers: { "X-Lua-Bundler-Dashboard": "1" } });
+  refresh();
+});
+
+refresh();
+setInterval(refresh, 3000);
@@ -0,0 +1,118 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dashboardRequest(d http.Handler, method, target, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if password != "" {
+		req.SetBasicAuth("admin", password)
+	}
+	if method == http.MethodPost {
+		req.Header.Set("X-Lua-Bundler-Dashboard", "1")
+	}
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDashboard_Auth(t *testing.T) {
+	d := NewDashboard(filepath.Join(t.TempDir(), "bundle.lua"), DashboardOptions{Token: "secret"})
+
+	rec := dashboardRequest(d, http.MethodGet, "/dashboard/", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Basic")
+	assert.Equal(t, http.StatusUnauthorized, dashboardRequest(d, http.MethodGet, "/dashboard/", "wrong").Code)
+
+	rec = dashboardRequest(d, http.MethodGet, "/dashboard/", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Lua Bundler Dashboard", "assets are embedded")
+	assert.Equal(t, http.StatusOK, dashboardRequest(d, http.MethodGet, "/dashboard/dashboard.js", "secret").Code)
+}
+
+func TestDashboard_Status(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.lua")
+	require.NoError(t, os.WriteFile(bundle, []byte("print('hi')\n"), 0644))
+	d := NewDashboard(bundle, DashboardOptions{Token: "secret", BuildTime: 250 * time.Millisecond})
+	d.Record(httptest.NewRequest(http.MethodGet, "/bundle.lua", nil), http.StatusOK)
+	d.Record(httptest.NewRequest(http.MethodGet, "/missing.lua", nil), http.StatusNotFound)
+
+	rec := dashboardRequest(d, http.MethodGet, "/dashboard/status", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status DashboardStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+	assert.Equal(t, "bundle.lua", status.Bundle)
+	assert.Equal(t, int64(12), status.Size)
+	assert.Len(t, status.Hash, 64)
+	assert.Equal(t, 250*time.Millisecond, status.BuildTime)
+	assert.False(t, status.CanRebuild)
+	require.Len(t, status.Requests, 2)
+	assert.Equal(t, "/missing.lua", status.Requests[0].Path, "newest first")
+	assert.Equal(t, http.StatusNotFound, status.Requests[0].Status)
+}
+
+func TestDashboard_RecentRequestsBounded(t *testing.T) {
+	d := NewDashboard("bundle.lua", DashboardOptions{Token: "secret"})
+	for i := 0; i < recentRequestsKept+10; i++ {
+		d.Record(httptest.NewRequest(http.MethodGet, "/bundle.lua", nil), http.StatusOK)
+	}
+	assert.Len(t, d.requests, recentRequestsKept)
+}
+
+func TestDashboard_Rebuild(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.lua")
+	require.NoError(t, os.WriteFile(bundle, []byte("return 1\n"), 0644))
+	fail := false
+	d := NewDashboard(bundle, DashboardOptions{Token: "secret", Rebuild: func() (string, error) {
+		if fail {
+			return "❌ Bundling failed", errors.New("exit status 1")
+		}
+		return "✅ Bundle created", os.WriteFile(bundle, []byte("return 2\n"), 0644)
+	}})
+
+	assert.Equal(t, http.StatusMethodNotAllowed, dashboardRequest(d, http.MethodGet, "/dashboard/rebuild", "secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, dashboardRequest(d, http.MethodPost, "/dashboard/rebuild", "").Code)
+	req := httptest.NewRequest(http.MethodPost, "/dashboard/rebuild", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "requests without the page's header are refused")
+
+	rec = dashboardRequest(d, http.MethodPost, "/dashboard/rebuild", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	status, err := d.Status()
+	require.NoError(t, err)
+	assert.True(t, status.CanRebuild)
+	require.NotNil(t, status.LastRebuild)
+	assert.Equal(t, "✅ Bundle created", status.LastRebuild.Output)
+	assert.Empty(t, status.LastRebuild.Error)
+	assert.Equal(t, int64(len("return 2\n")), status.Size)
+
+	fail = true
+	rec = dashboardRequest(d, http.MethodPost, "/dashboard/rebuild", "secret")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	status, _ = d.Status()
+	assert.Equal(t, "exit status 1", status.LastRebuild.Error)
+}
+
+func TestRecorded(t *testing.T) {
+	d := NewDashboard("bundle.lua", DashboardOptions{Token: "secret"})
+	h := recorded(d, http.HandlerFunc(http.NotFound))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope.lua", nil))
+
+	require.Len(t, d.requests, 1)
+	assert.Equal(t, http.StatusNotFound, d.requests[0].Status)
+	assert.NotNil(t, recorded(nil, h), "without a dashboard handlers are left as they are")
+}
@@ -0,0 +1,121 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DebugOptions configures serving the source map and manifest of the
+// bundle, which let developers symbolicate errors from live clients
+// without shipping the map inside the script
+type DebugOptions struct {
+	Token string      // bearer token clients must send; the files are not served when empty
+	Files *DebugFiles // the files served, replaced as the bundle is rebuilt
+}
+
+// DebugFiles holds the source map and manifest of the last build of the
+// bundle. Watched and dashboard rebuilds update them, so they describe the
+// bundle being served rather than the one the server started with.
+type DebugFiles struct {
+	mu           sync.RWMutex
+	sourceMap    []byte // nil when the build has none
+	manifest     []byte
+	bundleSHA256 string // hash of the bundle the files describe
+}
+
+// NewDebugFiles returns the debug files of the bundle hashing to
+// bundleSHA256
+func NewDebugFiles(sourceMap, manifest []byte, bundleSHA256 string) *DebugFiles {
+	return &DebugFiles{sourceMap: sourceMap, manifest: manifest, bundleSHA256: bundleSHA256}
+}
+
+// Update replaces the files with those of a rebuilt bundle hashing to
+// bundleSHA256
+func (f *DebugFiles) Update(sourceMap, manifest []byte, bundleSHA256 string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sourceMap, f.manifest, f.bundleSHA256 = sourceMap, manifest, bundleSHA256
+}
+
+// get returns the source map or the manifest, with the hash of the bundle
+// it describes
+func (f *DebugFiles) get(manifest bool) ([]byte, string) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if manifest {
+		return f.manifest, f.bundleSHA256
+	}
+	return f.sourceMap, f.bundleSHA256
+}
+
+// SourceMapName returns the name the source map of a bundle is served
+// under: bundle.lua has bundle.map.json
+func SourceMapName(bundleFile string) string {
+	base := filepath.Base(bundleFile)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".map.json"
+}
+
+// debugFile serves one of the debug files of the bundle at bundlePath
+type debugFile struct {
+	opts       DebugOptions
+	bundlePath string
+	manifest   bool // whether this serves the manifest rather than the source map
+}
+
+// NewDebugHandlers returns the handlers of the source map and manifest,
+// keyed by the path they are served at
+func NewDebugHandlers(bundlePath string, opts DebugOptions) map[string]http.Handler {
+	return map[string]http.Handler{
+		"/" + SourceMapName(bundlePath): &debugFile{opts: opts, bundlePath: bundlePath},
+		"/manifest.json":                &debugFile{opts: opts, bundlePath: bundlePath, manifest: true},
+	}
+}
+
+func (d *debugFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !bearerAuthorized(r, d.opts.Token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lua-bundler"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	data, bundleSHA256 := d.opts.Files.get(d.manifest)
+	if data == nil {
+		http.Error(w, "this build has no source map: minifying or release mode moved its lines", http.StatusNotFound)
+		return
+	}
+	// Between a rebuild replacing the bundle and updating the files, or
+	// after a build outside the server, they describe another bundle
+	if bundle, err := os.ReadFile(d.bundlePath); err == nil {
+		sum := sha256.Sum256(bundle)
+		if hex.EncodeToString(sum[:]) != bundleSHA256 {
+			http.Error(w, "the debug files describe another bundle than the one on disk; retry once its rebuild finishes", http.StatusConflict)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// bearerAuthorized reports whether the request carries token as a bearer
+// token
+func bearerAuthorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && tokenMatches(got, token)
+}
+
+// tokenMatches compares a token sent by a client in constant time
+func tokenMatches(got, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
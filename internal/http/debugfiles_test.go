@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getDebugFile(h http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/bundle.map.json", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSourceMapName(t *testing.T) {
+	assert.Equal(t, "bundle.map.json", SourceMapName("dist/bundle.lua"))
+	assert.Equal(t, "hub.map.json", SourceMapName("hub"))
+}
+
+func TestDebugHandlers(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.lua")
+	require.NoError(t, os.WriteFile(bundle, []byte("print(1)\n"), 0644))
+	sum := sha256.Sum256([]byte("print(1)\n"))
+	files := NewDebugFiles([]byte(`{"version": 1}`), []byte(`{"name": "bundle"}`), hex.EncodeToString(sum[:]))
+	handlers := NewDebugHandlers(bundle, DebugOptions{Token: "secret", Files: files})
+	require.Contains(t, handlers, "/bundle.map.json")
+	require.Contains(t, handlers, "/manifest.json")
+	sourceMap := handlers["/bundle.map.json"]
+
+	assert.Equal(t, http.StatusUnauthorized, getDebugFile(sourceMap, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, getDebugFile(sourceMap, "wrong").Code)
+
+	rec := getDebugFile(sourceMap, "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"version": 1}`, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `{"name": "bundle"}`, getDebugFile(handlers["/manifest.json"], "secret").Body.String())
+
+	require.NoError(t, os.WriteFile(bundle, []byte("print(2)\n"), 0644))
+	assert.Equal(t, http.StatusConflict, getDebugFile(sourceMap, "secret").Code, "files of an older bundle are not served")
+
+	sum = sha256.Sum256([]byte("print(2)\n"))
+	files.Update([]byte(`{"version": 2}`), []byte(`{"name": "rebuilt"}`), hex.EncodeToString(sum[:]))
+	rec = getDebugFile(sourceMap, "secret")
+	assert.Equal(t, http.StatusOK, rec.Code, "a rebuild updates the files")
+	assert.Equal(t, `{"version": 2}`, rec.Body.String())
+	assert.Equal(t, `{"name": "rebuilt"}`, getDebugFile(handlers["/manifest.json"], "secret").Body.String())
+}
+
+func TestDebugHandlers_NoSourceMap(t *testing.T) {
+	handlers := NewDebugHandlers("bundle.lua", DebugOptions{Token: "secret", Files: NewDebugFiles(nil, []byte("{}"), "")})
+	rec := getDebugFile(handlers["/bundle.map.json"], "secret")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no source map")
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
@@ -29,44 +31,142 @@ var (
 			Bold(true)
 )
 
-// StartServer starts an HTTP server to serve the bundled output file
-func StartServer(outputFile string, port int) {
+// ServerOptions configures the optional endpoints of the server, each off
+// unless its options have a token
+type ServerOptions struct {
+	Build     BuildOptions     // POST /build
+	Dashboard DashboardOptions // /dashboard/
+	Debug     DebugOptions     // the source map and manifest
+	Variants  VariantOptions   // query parameters building variants of the bundle
+	Watch     WatchOptions     // /status of a bundle rebuilt as its files change
+	Access    AccessOptions    // who may use any of it
+	Signing   SigningOptions   // signed, expiring URLs for the bundle and its directory
+	Bind      string           // address to listen on, DefaultBind when empty
+	Socket    string           // UNIX socket to listen on instead of Bind and the port, for a reverse proxy
+	Tunnel    string           // provider giving the server a public URL, "" for none
+	Exit      func(code int)   // ends the process when the server cannot run, os.Exit when nil
+}
+
+// DefaultBind keeps the server to this machine; binding 0.0.0.0 exposes
+// it on every interface
+const DefaultBind = "127.0.0.1"
+
+// exit ends the process with code through Exit
+func (o ServerOptions) exit(code int) {
+	if o.Exit != nil {
+		o.Exit(code)
+	}
+	os.Exit(code)
+}
+
+// StartServer starts an HTTP server to serve the bundled output file and
+// the endpoints opts turns on
+func StartServer(outputFile string, port int, opts ServerOptions) {
 	absPath, err := filepath.Abs(outputFile)
 	if err != nil {
 		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to get absolute path: %v", err)))
-		os.Exit(1)
+		opts.exit(1)
+	}
+	if opts.Tunnel != "" {
+		// Tunneled requests come from the local tunnel process
+		opts.Access.TrustedProxies = append(opts.Access.TrustedProxies, "127.0.0.1", "::1")
+	}
+	access, err := NewAccessControl(opts.Access)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		opts.exit(1)
+	}
+
+	if opts.Bind == "" {
+		opts.Bind = DefaultBind
+	}
+	listener, err := listen(port, opts)
+	if err != nil {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to start server: %v", err)))
+		opts.exit(1)
 	}
 
 	fmt.Println()
 	fmt.Println(infoStyle.Render("🌐 Starting HTTP server..."))
 	fmt.Println()
 
-	// Get local IP addresses
-	localIPs := getLocalIPs()
-
-	// Print all access URLs
-	fmt.Printf("%s http://localhost:%d/%s\n",
+	// Print all access URLs; behind a socket, the paths the proxy forwards
+	base := ""
+	if opts.Socket != "" {
+		fmt.Printf("%s %s\n",
+			successStyle.Render("🔌 Socket:"),
+			opts.Socket)
+	} else {
+		base = "http://" + net.JoinHostPort(localHost(opts.Bind), strconv.Itoa(port))
+	}
+	fmt.Printf("%s %s/%s\n",
 		successStyle.Render("🔗 Local:"),
-		port,
+		base,
 		filepath.Base(outputFile))
 
-	for _, ip := range localIPs {
-		fmt.Printf("%s http://%s:%d/%s\n",
-			successStyle.Render("🌍 Network:"),
-			ip,
-			port,
-			filepath.Base(outputFile))
+	if ip := net.ParseIP(opts.Bind); opts.Socket == "" && ip != nil && ip.IsUnspecified() {
+		for _, ip := range getLocalIPs() {
+			fmt.Printf("%s http://%s:%d/%s\n",
+				successStyle.Render("🌍 Network:"),
+				ip,
+				port,
+				filepath.Base(outputFile))
+		}
 	}
 
-	fmt.Printf("%s http://localhost:%d\n",
+	fmt.Printf("%s %s/\n",
 		infoStyle.Render("📋 Directory listing:"),
-		port)
+		base)
+	if opts.Build.Token != "" {
+		fmt.Printf("%s %s/build\n",
+			infoStyle.Render("🏗️  Build API:"),
+			base)
+	}
+	if opts.Variants.Token != "" {
+		fmt.Printf("%s %s/%s?release=true&obfuscate=2&token=...\n",
+			infoStyle.Render("🧪 Variants:"),
+			base,
+			filepath.Base(outputFile))
+	}
+	if opts.Debug.Token != "" {
+		fmt.Printf("%s %s/%s, %s/manifest.json\n",
+			infoStyle.Render("🗺️  Debug files:"),
+			base, SourceMapName(outputFile), base)
+	}
+	if opts.Watch.Status != nil {
+		fmt.Printf("%s %s/status\n",
+			infoStyle.Render("🔄 Build status:"),
+			base)
+	}
+	if opts.Signing.Secret != "" {
+		fmt.Println(infoStyle.Render("🔏 Files need signed URLs (lua-bundler sign)"))
+	}
+	if opts.Dashboard.Token != "" {
+		fmt.Printf("%s %s/dashboard/\n",
+			infoStyle.Render("📊 Dashboard:"),
+			base)
+	}
+	var tunnel *Tunnel
+	if opts.Tunnel != "" && opts.Socket == "" {
+		tunnel = openTunnel(opts.Tunnel, base, filepath.Base(outputFile), opts.Signing.Secret != "")
+	}
 	fmt.Println()
 	fmt.Println(warningStyle.Render("Press Ctrl+C to stop the server"))
 	fmt.Println()
 
+	var board *Dashboard
+	if opts.Dashboard.Token != "" {
+		board = NewDashboard(absPath, opts.Dashboard)
+		http.Handle("/dashboard", board)
+		http.Handle("/dashboard/", board)
+	}
+	var variants *variantServer
+	if opts.Variants.Token != "" {
+		variants = newVariantServer(absPath, opts.Variants)
+	}
+
 	// Create HTTP handler
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	http.Handle("/", recorded(board, requireSignature(opts.Signing.Secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Log request
 		timestamp := time.Now().Format("15:04:05")
 		fmt.Printf("[%s] %s %s %s from %s\n",
@@ -78,6 +178,27 @@ func StartServer(outputFile string, port int) {
 
 		// If requesting the specific file directly
 		if r.URL.Path == "/"+filepath.Base(outputFile) {
+			if opts.Watch.ErrorSnippet && opts.Watch.Status != nil {
+				if message, failed := opts.Watch.Status.Failed(); failed {
+					w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+					w.Header().Set("Cache-Control", "no-store")
+					w.Header().Set("X-Lua-Bundler-Build", StateError)
+					fmt.Fprint(w, ErrorSnippet(message))
+					return
+				}
+			}
+			if variants != nil {
+				v, ok, err := ParseVariant(r.URL.Query())
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if ok {
+					variants.serve(w, r, v)
+					return
+				}
+			}
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			http.ServeFile(w, r, absPath)
@@ -120,14 +241,76 @@ func StartServer(outputFile string, port int) {
 		}
 
 		http.NotFound(w, r)
-	})
+	}))))
+
+	if opts.Watch.Status != nil {
+		http.Handle("/status", recorded(board, opts.Watch.Status))
+	}
+	if opts.Build.Token != "" {
+		http.Handle("/build", recorded(board, NewBuildHandler(opts.Build)))
+	}
+	if opts.Debug.Token != "" {
+		for path, h := range NewDebugHandlers(absPath, opts.Debug) {
+			http.Handle(path, recorded(board, h))
+		}
+	}
 
-	// Start server on 0.0.0.0 to accept connections from any network interface
-	addr := fmt.Sprintf("0.0.0.0:%d", port)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := http.Serve(listener, access.Wrap(http.DefaultServeMux)); err != nil {
+		if tunnel != nil {
+			tunnel.Close()
+		}
 		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Failed to start server: %v", err)))
-		os.Exit(1)
+		opts.exit(1)
+	}
+}
+
+// openTunnel opens a tunnel to the server at base and prints the public
+// URL and loader of the bundle, only warning when that fails so the
+// server keeps running locally
+func openTunnel(provider, base, bundle string, signed bool) *Tunnel {
+	fmt.Println(infoStyle.Render(fmt.Sprintf("🚇 Opening %s tunnel...", provider)))
+	tunnel, err := StartTunnel(provider, base)
+	if err != nil {
+		fmt.Println(warningStyle.Render(fmt.Sprintf("⚠️  %v", err)))
+		return nil
+	}
+	public := tunnel.URL + "/" + url.PathEscape(bundle)
+	fmt.Printf("%s %s\n", successStyle.Render("🚇 Public:"), public)
+	if signed {
+		fmt.Printf("%s lua-bundler sign %s\n", infoStyle.Render("🔏 Sign links with:"), public)
+	} else {
+		fmt.Printf("%s %s\n", infoStyle.Render("📋 Loader:"), LoaderSnippet(public))
+	}
+	return tunnel
+}
+
+// listen opens the UNIX socket of opts, replacing a stale one left by an
+// earlier server, or else the port on its bind address
+func listen(port int, opts ServerOptions) (net.Listener, error) {
+	if opts.Socket == "" {
+		return net.Listen("tcp", net.JoinHostPort(opts.Bind, strconv.Itoa(port)))
+	}
+	if info, err := os.Lstat(opts.Socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", opts.Socket)
+		}
+		if conn, err := net.Dial("unix", opts.Socket); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", opts.Socket)
+		}
+		if err := os.Remove(opts.Socket); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", opts.Socket)
+}
+
+// localHost returns the host this machine reaches a server bound to bind at
+func localHost(bind string) string {
+	if ip := net.ParseIP(bind); bind == "" || (ip != nil && (ip.IsUnspecified() || ip.IsLoopback())) {
+		return "localhost"
 	}
+	return bind
 }
 
 // getLocalIPs returns a list of local IP addresses (excluding loopback)
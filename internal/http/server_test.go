@@ -1,6 +1,10 @@
 package httpserver
 
 import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +27,62 @@ func TestGetLocalIPs(t *testing.T) {
 		}
 	}
 }
+
+func TestLocalHost(t *testing.T) {
+	for bind, want := range map[string]string{
+		"":           "localhost",
+		"127.0.0.1":  "localhost",
+		"0.0.0.0":    "localhost",
+		"::":         "localhost",
+		"10.0.0.5":   "10.0.0.5",
+		"build-host": "build-host",
+	} {
+		if got := localHost(bind); got != want {
+			t.Errorf("localHost(%q) = %q, want %q", bind, got, want)
+		}
+	}
+}
+
+func TestListen_Socket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "lua-bundler.sock")
+	opts := ServerOptions{Socket: socket}
+
+	l, err := listen(0, opts)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	if _, err := listen(0, opts); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Expected a socket in use to be refused, got %v", err)
+	}
+	l.Close()
+
+	// A socket left behind by a server that died is replaced
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("net.Listen failed: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	l, err = listen(0, opts)
+	if err != nil {
+		t.Fatalf("Expected the stale socket to be replaced, got %v", err)
+	}
+	l.Close()
+
+	file := filepath.Join(t.TempDir(), "bundle.lua")
+	os.WriteFile(file, []byte("print(1)"), 0644)
+	if _, err := listen(0, ServerOptions{Socket: file}); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Expected other files to be kept, got %v", err)
+	}
+}
+
+func TestListen_Bind(t *testing.T) {
+	l, err := listen(0, ServerOptions{Bind: DefaultBind})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+	if host, _, _ := net.SplitHostPort(l.Addr().String()); host != "127.0.0.1" {
+		t.Errorf("Expected to listen on loopback only, got %s", l.Addr())
+	}
+}
@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignURL(t *testing.T) {
+	expires := time.Unix(1900000000, 0)
+	signed, err := SignURL("https://cdn.example.com/hub.lua?v=2", "secret", expires, "alice")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "https://cdn.example.com/hub.lua?customer=alice&expires=1900000000&signature="), signed)
+	assert.Contains(t, signed, "v=2")
+
+	u, _ := url.Parse(signed)
+	customer, err := VerifySignedURL(u, "secret", expires.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", customer)
+
+	_, err = VerifySignedURL(u, "secret", expires)
+	assert.ErrorIs(t, err, ErrLinkExpired)
+	_, err = VerifySignedURL(u, "other", expires.Add(-time.Second))
+	assert.EqualError(t, err, "invalid signature")
+
+	resigned, err := SignURL(signed, "secret", expires.Add(time.Hour), "")
+	require.NoError(t, err)
+	assert.NotContains(t, resigned, "customer=", "signing again replaces the fields")
+	assert.Equal(t, 1, strings.Count(resigned, "signature="))
+
+	_, err = SignURL("https://cdn.example.com/hub.lua", "", expires, "")
+	assert.Error(t, err)
+	_, err = SignURL("hub.lua", "secret", expires, "")
+	assert.EqualError(t, err, `invalid URL "hub.lua"`)
+}
+
+func TestVerifySignedURL_Tampered(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	signed, err := SignURL("http://localhost:8080/hub.lua", "secret", expires, "alice")
+	require.NoError(t, err)
+	now := time.Now()
+
+	for name, tamper := range map[string]func(url.Values){
+		"customer": func(q url.Values) { q.Set("customer", "bob") },
+		"expiry":   func(q url.Values) { q.Set("expires", "9999999999") },
+		"missing":  func(q url.Values) { q.Del("signature") },
+	} {
+		u, _ := url.Parse(signed)
+		q := u.Query()
+		tamper(q)
+		u.RawQuery = q.Encode()
+		_, err := VerifySignedURL(u, "secret", now)
+		assert.Error(t, err, name)
+	}
+
+	u, _ := url.Parse(signed)
+	u.Path = "/other.lua"
+	_, err = VerifySignedURL(u, "secret", now)
+	assert.EqualError(t, err, "invalid signature", "links only open their own path")
+}
+
+func TestRequireSignature(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("print(1)")) })
+	assert.NotNil(t, requireSignature("", next))
+	h := requireSignature("secret", next)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, get("/hub.lua").Code)
+
+	valid, err := SignURL("http://localhost/hub.lua", "secret", time.Now().Add(time.Minute), "alice")
+	require.NoError(t, err)
+	rec := get(valid)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "print(1)", rec.Body.String())
+
+	expired, err := SignURL("http://localhost/hub.lua", "secret", time.Now().Add(-time.Minute), "alice")
+	require.NoError(t, err)
+	rec = get(expired)
+	assert.Equal(t, http.StatusGone, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "print")
+}
@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+// LoaderSnippet returns the one-liner that loads the bundle hosted at url
+func LoaderSnippet(bundleURL string) string {
+	return "loadstring(game:HttpGet(" + strconv.Quote(bundleURL) + "))()"
+}
+
+// LocalURL returns the URL StartServer serves outputFile at on this machine
+func LocalURL(outputFile string, port int) string {
+	return fmt.Sprintf("http://localhost:%d/%s", port, url.PathEscape(filepath.Base(outputFile)))
+}
+
+// Shorten asks a URL shortener for a short link to longURL. apiTemplate is
+// the API endpoint with a {url} placeholder, e.g.
+// https://is.gd/create.php?format=simple&url={url}; the response body must
+// be the short URL as plain text.
+func Shorten(apiTemplate, longURL string) (string, error) {
+	if !strings.Contains(apiTemplate, "{url}") {
+		return "", fmt.Errorf("shortener %q has no {url} placeholder", apiTemplate)
+	}
+	endpoint := strings.ReplaceAll(apiTemplate, "{url}", url.QueryEscape(longURL))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to shorten URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read shortener response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("shortener returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	short := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(short, "http://") && !strings.HasPrefix(short, "https://") {
+		return "", fmt.Errorf("shortener returned %q, expected a URL", short)
+	}
+	return short, nil
+}
+
+// CopyToClipboard copies text to the terminal's clipboard with an OSC 52
+// escape sequence, which also works over SSH in terminals that support it
+func CopyToClipboard(text string) error {
+	_, err := osc52.New(text).WriteTo(os.Stderr)
+	return err
+}
@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoaderSnippet(t *testing.T) {
+	assert.Equal(t, `loadstring(game:HttpGet("http://localhost:8080/bundle.lua"))()`, LoaderSnippet("http://localhost:8080/bundle.lua"))
+	assert.Equal(t, "http://localhost:3000/my%20bundle.lua", LocalURL("out/my bundle.lua", 3000))
+}
+
+func TestShorten(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("url")
+		_, _ = w.Write([]byte("https://sho.rt/abc\n"))
+	}))
+	defer server.Close()
+
+	short, err := Shorten(server.URL+"/create?format=simple&url={url}", "https://example.com/bundle.lua?v=1&x=2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sho.rt/abc", short)
+	assert.Equal(t, "https://example.com/bundle.lua?v=1&x=2", got, "the long URL should be query-escaped")
+}
+
+func TestShorten_Errors(t *testing.T) {
+	_, err := Shorten("https://is.gd/create.php", "https://example.com")
+	assert.ErrorContains(t, err, "{url}")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("Error: invalid URL"))
+	}))
+	defer server.Close()
+
+	_, err = Shorten(server.URL+"/fail?url={url}", "https://example.com")
+	assert.ErrorContains(t, err, "429")
+	_, err = Shorten(server.URL+"/ok?url={url}", "https://example.com")
+	assert.ErrorContains(t, err, "expected a URL")
+}
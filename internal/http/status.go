@@ -0,0 +1,162 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// States of a watched build
+const (
+	StateBuilding = "building"
+	StateOK       = "ok"
+	StateError    = "error"
+)
+
+// WatchOptions configures serving a bundle that is rebuilt as its files
+// change
+type WatchOptions struct {
+	Status       *BuildStatus // served at /status; nil when not watching
+	ErrorSnippet bool         // serve a script printing the build error instead of the bundle while the last build failed
+}
+
+// StatusReport is what /status answers
+type StatusReport struct {
+	State       string    `json:"state"`             // building, ok or error
+	Changed     []string  `json:"changed,omitempty"` // files that started the current or last build
+	Started     time.Time `json:"started"`
+	DurationMs  int64     `json:"durationMs"`            // of the last finished build
+	Error       string    `json:"error,omitempty"`       // why the last build failed
+	Diagnostics []string  `json:"diagnostics,omitempty"` // its output
+	LastSuccess time.Time `json:"lastSuccess"`           // when the bundle being served was built
+}
+
+// BuildStatus tracks the builds of a watched bundle. The served bundle is
+// only replaced by builds that succeed, so it stays the last good one
+// while a build runs or after one fails.
+type BuildStatus struct {
+	mu     sync.Mutex
+	report StatusReport
+	failed bool // whether the last finished build failed
+}
+
+// NewBuildStatus returns the status of a bundle that was just built
+func NewBuildStatus() *BuildStatus {
+	now := time.Now()
+	return &BuildStatus{report: StatusReport{State: StateOK, Started: now, LastSuccess: now}}
+}
+
+// Start records that changed files started a build
+func (s *BuildStatus) Start(changed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.State = StateBuilding
+	s.report.Changed = changed
+	s.report.Started = time.Now()
+}
+
+// Finish records the end of the build started last, with its output
+func (s *BuildStatus) Finish(output string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.DurationMs = time.Since(s.report.Started).Milliseconds()
+	s.failed = err != nil
+	if err == nil {
+		s.report.State = StateOK
+		s.report.Error, s.report.Diagnostics = "", nil
+		s.report.LastSuccess = time.Now()
+		return
+	}
+	s.report.State = StateError
+	s.report.Diagnostics = outputLines(output)
+	s.report.Error = buildError(s.report.Diagnostics, err)
+}
+
+// Report returns the current status
+func (s *BuildStatus) Report() StatusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := s.report
+	report.Changed = append([]string(nil), report.Changed...)
+	report.Diagnostics = append([]string(nil), report.Diagnostics...)
+	return report
+}
+
+// Failed returns why the last finished build failed, or false when it
+// succeeded
+func (s *BuildStatus) Failed() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report.Error, s.failed
+}
+
+// ServeHTTP answers /status with the report as JSON
+func (s *BuildStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(s.Report())
+}
+
+// ErrorSnippet returns a script printing that the build failed with
+// message, served in place of the bundle so the failure shows in-game
+func ErrorSnippet(message string) string {
+	return "-- lua-bundler: the last build failed, so this script reports it instead of running the bundle\n" +
+		"local message = " + luaString("[lua-bundler] build failed: "+message) + "\n" +
+		"if warn then warn(message) else print(message) end\n"
+}
+
+// outputLines returns the non-empty lines of a build's output
+func outputLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, " \r\t"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// buildError returns the first error line of a build's output, falling
+// back to its last line and then to err
+func buildError(lines []string, err error) string {
+	for _, line := range lines {
+		if strings.Contains(line, "❌") {
+			return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "❌"))
+		}
+	}
+	if len(lines) > 0 {
+		return strings.TrimSpace(lines[len(lines)-1])
+	}
+	return err.Error()
+}
+
+// luaString returns s as a double-quoted Lua string, escaping what Lua 5.1
+// cannot hold in one
+func luaString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c == '\n':
+			out.WriteString("\\n")
+		case c < ' ' || c == 0x7f:
+			fmt.Fprintf(&out, "\\%03d", c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
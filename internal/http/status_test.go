@@ -0,0 +1,75 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getStatus(t *testing.T, s *BuildStatus) StatusReport {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var report StatusReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return report
+}
+
+func TestBuildStatus(t *testing.T) {
+	s := NewBuildStatus()
+	assert.Equal(t, StateOK, getStatus(t, s).State)
+	built := getStatus(t, s).LastSuccess
+
+	s.Start([]string{"src/mod.lua"})
+	report := getStatus(t, s)
+	assert.Equal(t, StateBuilding, report.State)
+	assert.Equal(t, []string{"src/mod.lua"}, report.Changed)
+
+	s.Finish("Building...\n\n❌ Bundling failed: module \"missing\" not found\n", errors.New("exit status 1"))
+	report = getStatus(t, s)
+	assert.Equal(t, StateError, report.State)
+	assert.Equal(t, `Bundling failed: module "missing" not found`, report.Error)
+	assert.Equal(t, []string{"Building...", `❌ Bundling failed: module "missing" not found`}, report.Diagnostics)
+	assert.True(t, report.LastSuccess.Equal(built), "the served bundle is still the last good one")
+	message, failed := s.Failed()
+	assert.True(t, failed)
+	assert.Equal(t, report.Error, message)
+
+	s.Start([]string{"src/mod.lua"})
+	_, failed = s.Failed()
+	assert.True(t, failed, "the error holds until a build succeeds")
+
+	s.Finish("", nil)
+	report = getStatus(t, s)
+	assert.Equal(t, StateOK, report.State)
+	assert.Empty(t, report.Error)
+	assert.Empty(t, report.Diagnostics)
+	assert.True(t, report.LastSuccess.After(built))
+	_, failed = s.Failed()
+	assert.False(t, failed)
+}
+
+func TestBuildStatus_ErrorWithoutOutput(t *testing.T) {
+	s := NewBuildStatus()
+	s.Finish("", errors.New("signal: killed"))
+	message, failed := s.Failed()
+	assert.True(t, failed)
+	assert.Equal(t, "signal: killed", message)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestErrorSnippet(t *testing.T) {
+	snippet := ErrorSnippet("bad \"quote\" \\ path\nnext\tline")
+	assert.Contains(t, snippet, `local message = "[lua-bundler] build failed: bad \"quote\" \\ path\nnext\009line"`)
+	assert.Contains(t, snippet, "if warn then warn(message) else print(message) end")
+}
@@ -0,0 +1,141 @@
+package httpserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Tunnel providers
+const (
+	TunnelCloudflare = "cloudflared" // a Cloudflare quick tunnel, no account needed
+	TunnelNgrok      = "ngrok"       // an ngrok tunnel, with the authtoken ngrok is configured with
+)
+
+// TunnelProviders lists the providers --tunnel accepts
+var TunnelProviders = []string{TunnelCloudflare, TunnelNgrok}
+
+// tunnelTimeout bounds how long a tunnel may take to report its URL
+var tunnelTimeout = 30 * time.Second
+
+// tunnelCommand returns the command opening a tunnel of provider to
+// target, a local URL
+var tunnelCommand = func(provider, target string) *exec.Cmd {
+	if provider == TunnelNgrok {
+		return exec.Command("ngrok", "http", target, "--log", "stdout", "--log-format", "json")
+	}
+	return exec.Command("cloudflared", "tunnel", "--no-autoupdate", "--url", target)
+}
+
+var quickTunnelURL = regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`)
+
+// Tunnel is a tunnel process giving the local server a public HTTPS URL
+type Tunnel struct {
+	URL string
+	cmd *exec.Cmd
+}
+
+// StartTunnel runs the tool of provider to expose target, waiting until
+// it reports the public URL
+func StartTunnel(provider, target string) (*Tunnel, error) {
+	if err := CheckTunnel(provider); err != nil {
+		return nil, err
+	}
+	cmd := tunnelCommand(provider, target)
+	if cmd.Err != nil {
+		return nil, fmt.Errorf("%s not found in PATH; install it to use --tunnel %s", cmd.Args[0], provider)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cmd.Args[0], err)
+	}
+
+	found := make(chan string, 1)
+	lines := make(chan []string, 1)
+	go func() {
+		var last []string
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if u := parseTunnelURL(provider, line); u != "" {
+				found <- u
+				io.Copy(io.Discard, out)
+				return
+			}
+			if last = append(last, line); len(last) > 5 {
+				last = last[1:]
+			}
+		}
+		lines <- last
+	}()
+
+	t := &Tunnel{cmd: cmd}
+	select {
+	case t.URL = <-found:
+		return t, nil
+	case last := <-lines:
+		cmd.Wait()
+		return nil, tunnelError(cmd.Args[0], "exited before reporting its URL", last)
+	case <-time.After(tunnelTimeout):
+		t.Close()
+		return nil, tunnelError(cmd.Args[0], fmt.Sprintf("reported no URL within %s", tunnelTimeout), nil)
+	}
+}
+
+// CheckTunnel returns an error when provider is not a tunnel provider
+func CheckTunnel(provider string) error {
+	switch provider {
+	case TunnelCloudflare, TunnelNgrok:
+		return nil
+	}
+	return fmt.Errorf("unknown tunnel %q (want %s)", provider, strings.Join(TunnelProviders, " or "))
+}
+
+// Close stops the tunnel
+func (t *Tunnel) Close() error {
+	if t.cmd.Process == nil {
+		return nil
+	}
+	if err := t.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	t.cmd.Wait()
+	return nil
+}
+
+// parseTunnelURL returns the public URL a line of the tool's output
+// reports, or "" when it reports none
+func parseTunnelURL(provider, line string) string {
+	if provider == TunnelCloudflare {
+		return quickTunnelURL.FindString(line)
+	}
+	var entry struct {
+		Msg string `json:"msg"`
+		URL string `json:"url"`
+	}
+	if json.Unmarshal([]byte(line), &entry) != nil || entry.Msg != "started tunnel" {
+		return ""
+	}
+	if strings.HasPrefix(entry.URL, "https://") {
+		return entry.URL
+	}
+	return ""
+}
+
+func tunnelError(name, what string, last []string) error {
+	if len(last) == 0 {
+		return fmt.Errorf("%s %s", name, what)
+	}
+	return fmt.Errorf("%s %s:\n%s", name, what, strings.Join(last, "\n"))
+}
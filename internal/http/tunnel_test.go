@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTunnel makes tunnels run script in sh instead of the real tools
+func fakeTunnel(t *testing.T, script string) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	previous := tunnelCommand
+	tunnelCommand = func(provider, target string) *exec.Cmd {
+		return exec.Command("sh", "-c", script, provider, target)
+	}
+	t.Cleanup(func() { tunnelCommand = previous })
+}
+
+func TestParseTunnelURL(t *testing.T) {
+	assert.Equal(t, "https://quiet-river-42.trycloudflare.com",
+		parseTunnelURL(TunnelCloudflare, "2026-10-15T10:00:00Z INF |  https://quiet-river-42.trycloudflare.com                     |"))
+	assert.Empty(t, parseTunnelURL(TunnelCloudflare, "INF Requesting new quick Tunnel on trycloudflare.com..."))
+
+	assert.Equal(t, "https://ab12.ngrok-free.app",
+		parseTunnelURL(TunnelNgrok, `{"lvl":"info","msg":"started tunnel","name":"command_line","addr":"http://localhost:8080","url":"https://ab12.ngrok-free.app"}`))
+	assert.Empty(t, parseTunnelURL(TunnelNgrok, `{"lvl":"info","msg":"client session established"}`))
+	assert.Empty(t, parseTunnelURL(TunnelNgrok, "not json"))
+}
+
+func TestStartTunnel(t *testing.T) {
+	fakeTunnel(t, `echo "INF Requesting new quick Tunnel" >&2; echo "INF |  https://quiet-river-42.trycloudflare.com  |" >&2; sleep 30`)
+
+	tunnel, err := StartTunnel(TunnelCloudflare, "http://localhost:8080")
+	require.NoError(t, err)
+	assert.Equal(t, "https://quiet-river-42.trycloudflare.com", tunnel.URL)
+	assert.NoError(t, tunnel.Close())
+}
+
+func TestStartTunnel_Exits(t *testing.T) {
+	fakeTunnel(t, `echo 'ERR authentication failed: missing authtoken'; exit 1`)
+
+	_, err := StartTunnel(TunnelNgrok, "http://localhost:8080")
+	assert.EqualError(t, err, "sh exited before reporting its URL:\nERR authentication failed: missing authtoken")
+}
+
+func TestStartTunnel_Timeout(t *testing.T) {
+	fakeTunnel(t, `sleep 30`)
+	previous := tunnelTimeout
+	tunnelTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { tunnelTimeout = previous })
+
+	_, err := StartTunnel(TunnelCloudflare, "http://localhost:8080")
+	assert.EqualError(t, err, "sh reported no URL within 50ms")
+}
+
+func TestStartTunnel_Unknown(t *testing.T) {
+	_, err := StartTunnel("localtunnel", "http://localhost:8080")
+	assert.EqualError(t, err, `unknown tunnel "localtunnel" (want cloudflared or ngrok)`)
+	assert.NoError(t, CheckTunnel(TunnelNgrok))
+}
+
+func TestStartTunnel_NotInstalled(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := StartTunnel(TunnelCloudflare, "http://localhost:8080")
+	assert.EqualError(t, err, "cloudflared not found in PATH; install it to use --tunnel cloudflared")
+}
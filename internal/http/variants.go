@@ -0,0 +1,207 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultVariantsCached is how many variant builds are kept when
+// VariantOptions.MaxCached is 0
+const defaultVariantsCached = 16
+
+// VariantOptions configures on-demand builds of variants of the served
+// bundle, chosen with query parameters
+type VariantOptions struct {
+	Token     string                                         // clients send it as a bearer token or a token query parameter; variants are off when empty
+	Build     func(v Variant, output string) (string, error) // builds v to the output file, returning what the build printed
+	MaxCached int                                            // variant builds kept, defaultVariantsCached when 0
+}
+
+// Variant is a configuration of the bundle asked for with query
+// parameters. Empty fields keep the setting of the served build.
+type Variant struct {
+	Release   string // "true" or "false"
+	Obfuscate string // preset or level
+	Minify    string // level
+	Target    string
+	Features  []string // nil keeps the build's features; empty bundles none
+	Defines   []string // NAME=VALUE, declared on top of the build's
+}
+
+// ParseVariant reads a variant from the release, obfuscate, minify,
+// target, features and repeatable define query parameters, reporting
+// false when none is set
+func ParseVariant(query url.Values) (Variant, bool, error) {
+	var v Variant
+	set := false
+	for _, name := range []string{"release", "obfuscate", "minify", "target", "features", "define"} {
+		if query.Has(name) {
+			set = true
+		}
+	}
+	if !set {
+		return v, false, nil
+	}
+
+	if s := query.Get("release"); s != "" {
+		release, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, true, fmt.Errorf("invalid release %q", s)
+		}
+		v.Release = strconv.FormatBool(release)
+	}
+	if s := query.Get("minify"); s != "" {
+		if _, err := strconv.Atoi(s); err != nil {
+			return v, true, fmt.Errorf("invalid minify level %q", s)
+		}
+		v.Minify = s
+	}
+	v.Obfuscate = query.Get("obfuscate")
+	v.Target = query.Get("target")
+	if query.Has("features") {
+		v.Features = []string{}
+		for _, list := range query["features"] {
+			for _, feature := range strings.Split(list, ",") {
+				if feature = strings.TrimSpace(feature); feature != "" {
+					v.Features = append(v.Features, feature)
+				}
+			}
+		}
+		sort.Strings(v.Features)
+	}
+	for _, define := range query["define"] {
+		if !strings.Contains(define, "=") {
+			return v, true, fmt.Errorf("invalid define %q, expected NAME=VALUE", define)
+		}
+		v.Defines = append(v.Defines, define)
+	}
+	sort.Strings(v.Defines)
+	return v, true, nil
+}
+
+// Key identifies the variant; variants asking for the same settings in
+// another order share it
+func (v Variant) Key() string {
+	parts := []string{
+		"release=" + v.Release,
+		"obfuscate=" + v.Obfuscate,
+		"minify=" + v.Minify,
+		"target=" + v.Target,
+	}
+	if v.Features != nil {
+		parts = append(parts, "features="+strings.Join(v.Features, ","))
+	}
+	for _, define := range v.Defines {
+		parts = append(parts, "define="+define)
+	}
+	return strings.Join(parts, "&")
+}
+
+// variantBuild is a built variant and the served bundle it was built
+// next to
+type variantBuild struct {
+	data    []byte
+	bundled time.Time // modification time of the served bundle
+}
+
+// variantServer builds variants of the bundle at bundlePath on demand and
+// keeps the latest builds until the bundle changes
+type variantServer struct {
+	opts       VariantOptions
+	bundlePath string
+
+	mu     sync.Mutex // held while building, so a variant is built once
+	builds map[string]variantBuild
+	order  []string // keys, oldest first
+}
+
+func newVariantServer(bundlePath string, opts VariantOptions) *variantServer {
+	if opts.MaxCached <= 0 {
+		opts.MaxCached = defaultVariantsCached
+	}
+	return &variantServer{opts: opts, bundlePath: bundlePath, builds: make(map[string]variantBuild)}
+}
+
+// serve answers with the variant v, building it unless it is cached
+func (s *variantServer) serve(w http.ResponseWriter, r *http.Request, v Variant) {
+	if !bearerAuthorized(r, s.opts.Token) && !queryAuthorized(r, s.opts.Token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="lua-bundler"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	data, cached, err := s.variant(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("X-Lua-Bundler-Variant", v.Key())
+	if cached {
+		w.Header().Set("X-Lua-Bundler-Cache", "hit")
+	} else {
+		w.Header().Set("X-Lua-Bundler-Cache", "miss")
+	}
+	w.Write(data)
+}
+
+// variant returns the build of v and whether it was cached
+func (s *variantServer) variant(v Variant) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bundled time.Time
+	if info, err := os.Stat(s.bundlePath); err == nil {
+		bundled = info.ModTime()
+	}
+	key := v.Key()
+	if build, ok := s.builds[key]; ok && build.bundled.Equal(bundled) {
+		return build.data, true, nil
+	}
+
+	data, err := s.build(v)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := s.builds[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.builds[key] = variantBuild{data: data, bundled: bundled}
+	for len(s.order) > s.opts.MaxCached {
+		delete(s.builds, s.order[0])
+		s.order = s.order[1:]
+	}
+	return data, false, nil
+}
+
+// build runs the build of v into a temporary file
+func (s *variantServer) build(v Variant) ([]byte, error) {
+	f, err := os.CreateTemp("", "lua-bundler-variant-*.lua")
+	if err != nil {
+		return nil, err
+	}
+	output := f.Name()
+	f.Close()
+	defer os.Remove(output)
+
+	printed, err := s.opts.Build(v, output)
+	if err != nil {
+		return nil, fmt.Errorf("building variant %s failed: %v\n\n%s", v.Key(), err, printed)
+	}
+	return os.ReadFile(output)
+}
+
+// queryAuthorized reports whether the request carries token as its token
+// query parameter, for clients such as game:HttpGet that cannot set headers
+func queryAuthorized(r *http.Request, token string) bool {
+	got := r.URL.Query().Get("token")
+	return got != "" && tokenMatches(got, token)
+}
@@ -0,0 +1,109 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariant(t *testing.T) {
+	_, ok, err := ParseVariant(url.Values{"token": {"secret"}, "v": {"3"}})
+	require.NoError(t, err)
+	assert.False(t, ok, "other parameters do not ask for a variant")
+
+	query, _ := url.ParseQuery("release=1&obfuscate=heavy&features=esp,aimbot&define=DEBUG=false&define=API=v2")
+	v, ok, err := ParseVariant(query)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, Variant{
+		Release:   "true",
+		Obfuscate: "heavy",
+		Features:  []string{"aimbot", "esp"},
+		Defines:   []string{"API=v2", "DEBUG=false"},
+	}, v)
+
+	reordered, _ := url.ParseQuery("define=API=v2&features=aimbot&features=esp&obfuscate=heavy&define=DEBUG=false&release=true")
+	other, _, err := ParseVariant(reordered)
+	require.NoError(t, err)
+	assert.Equal(t, v.Key(), other.Key(), "the order of parameters does not matter")
+
+	none, _, err := ParseVariant(url.Values{"features": {""}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, none.Features, "an empty features list bundles none")
+	assert.NotEqual(t, Variant{}.Key(), none.Key())
+
+	for _, bad := range []string{"release=maybe", "minify=high", "define=DEBUG"} {
+		query, _ := url.ParseQuery(bad)
+		_, ok, err := ParseVariant(query)
+		assert.True(t, ok)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestVariantServer(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "bundle.lua")
+	require.NoError(t, os.WriteFile(bundle, []byte("print('default')\n"), 0644))
+	builds := 0
+	s := newVariantServer(bundle, VariantOptions{Token: "secret", MaxCached: 2, Build: func(v Variant, output string) (string, error) {
+		builds++
+		if v.Obfuscate == "broken" {
+			return "❌ Unknown obfuscation preset", errors.New("exit status 1")
+		}
+		return "", os.WriteFile(output, []byte("-- "+v.Key()+"\n"), 0644)
+	}})
+	get := func(target string) *httptest.ResponseRecorder {
+		query, _ := url.ParseQuery(target)
+		v, _, _ := ParseVariant(query)
+		rec := httptest.NewRecorder()
+		s.serve(rec, httptest.NewRequest(http.MethodGet, "/bundle.lua?"+target, nil), v)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("release=true").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("release=true&token=wrong").Code)
+
+	rec := get("release=true&token=secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "release=true")
+	assert.Equal(t, "miss", rec.Header().Get("X-Lua-Bundler-Cache"))
+	rec = get("release=true&token=secret")
+	assert.Equal(t, "hit", rec.Header().Get("X-Lua-Bundler-Cache"))
+	assert.Equal(t, 1, builds)
+
+	req := httptest.NewRequest(http.MethodGet, "/bundle.lua?minify=2", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	s.serve(rec, req, Variant{Minify: "2"})
+	assert.Equal(t, http.StatusOK, rec.Code, "the token can be a bearer token")
+
+	rec = get("obfuscate=broken&token=secret")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Unknown obfuscation preset")
+
+	// A new build of the served bundle invalidates the variants
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(bundle, later, later))
+	builds = 0
+	assert.Equal(t, "miss", get("release=true&token=secret").Header().Get("X-Lua-Bundler-Cache"))
+	assert.Equal(t, 1, builds)
+}
+
+func TestVariantServer_Evicts(t *testing.T) {
+	s := newVariantServer("bundle.lua", VariantOptions{Token: "secret", MaxCached: 2, Build: func(v Variant, output string) (string, error) {
+		return "", os.WriteFile(output, []byte(v.Key()), 0644)
+	}})
+	for _, target := range []string{"1", "2", "3"} {
+		_, _, err := s.variant(Variant{Target: target})
+		require.NoError(t, err)
+	}
+	assert.Len(t, s.builds, 2)
+	assert.NotContains(t, s.builds, Variant{Target: "1"}.Key(), "the oldest build goes first")
+}
@@ -0,0 +1,140 @@
+// Package inject runs scripts in executors through the local execute APIs
+// some of them expose: an HTTP endpoint taking the script as the body of a
+// POST, or a WebSocket taking it as a text message.
+package inject
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Timeout bounds connecting to an execute API and handing it a script
+var Timeout = 5 * time.Second
+
+// Execute sends script to the execute API at rawURL
+func Execute(rawURL, script string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid execute URL %q: %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return executeHTTP(u, script)
+	case "ws":
+		return executeWebSocket(u, script)
+	}
+	return fmt.Errorf("unsupported execute URL %q (want http://, https:// or ws://)", rawURL)
+}
+
+// executeHTTP posts the script as plain text
+func executeHTTP(u *url.URL, script string) error {
+	client := &http.Client{Timeout: Timeout}
+	resp, err := client.Post(u.String(), "text/plain; charset=utf-8", strings.NewReader(script))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return fmt.Errorf("POST %s: %s: %s", u.Redacted(), resp.Status, msg)
+		}
+		return fmt.Errorf("POST %s: %s", u.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// webSocketGUID is mixed into the handshake key, per RFC 6455
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	opText  = 0x1
+	opClose = 0x8
+)
+
+// executeWebSocket opens a WebSocket, sends the script as one text message
+// and closes it again
+func executeWebSocket(u *url.URL, script string) error {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	conn, err := net.DialTimeout("tcp", host, Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(Timeout))
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	path := u.RequestURI()
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", path, u.Host, key)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		return fmt.Errorf("WebSocket handshake with %s failed: %w", u.Redacted(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("WebSocket handshake with %s failed: %s", u.Redacted(), resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return fmt.Errorf("WebSocket handshake with %s failed: bad Sec-WebSocket-Accept", u.Redacted())
+	}
+
+	if err := writeFrame(conn, opText, []byte(script)); err != nil {
+		return err
+	}
+	// 1000 is a normal closure
+	return writeFrame(conn, opClose, []byte{0x03, 0xe8})
+}
+
+// acceptKey returns the Sec-WebSocket-Accept a server answers key with
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeFrame writes one final, masked frame, as clients must
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	var frame bytes.Buffer
+	frame.WriteByte(0x80 | opcode)
+	switch n := len(payload); {
+	case n < 126:
+		frame.WriteByte(0x80 | byte(n))
+	case n <= 0xffff:
+		frame.WriteByte(0x80 | 126)
+		binary.Write(&frame, binary.BigEndian, uint16(n))
+	default:
+		frame.WriteByte(0x80 | 127)
+		binary.Write(&frame, binary.BigEndian, uint64(n))
+	}
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame.Write(mask)
+	for i, b := range payload {
+		frame.WriteByte(b ^ mask[i%4])
+	}
+	_, err := w.Write(frame.Bytes())
+	return err
+}
@@ -0,0 +1,121 @@
+package inject
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readFrame reads one masked client frame
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var size uint16
+		binary.Read(r, binary.BigEndian, &size)
+		n = uint64(size)
+	case 127:
+		binary.Read(r, binary.BigEndian, &n)
+	}
+	var mask [4]byte
+	io.ReadFull(r, mask[:])
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return head[0] & 0x0f, payload, nil
+}
+
+// webSocketServer records the text messages sent to it
+func webSocketServer(t *testing.T, received chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			http.Error(w, "not a WebSocket", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+		for {
+			op, payload, err := readFrame(rw.Reader)
+			if err != nil || op == opClose {
+				return
+			}
+			received <- string(payload)
+		}
+	}))
+}
+
+func TestExecute_WebSocket(t *testing.T) {
+	received := make(chan string, 2)
+	server := webSocketServer(t, received)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/execute"
+
+	for _, script := range []string{"print('hi')", strings.Repeat("x", 70000)} {
+		if err := Execute(wsURL, script); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if got := <-received; got != script {
+			t.Errorf("Received %d bytes, want %d", len(got), len(script))
+		}
+	}
+}
+
+func TestExecute_HTTP(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) == "error()" {
+			http.Error(w, "script rejected", http.StatusBadRequest)
+			return
+		}
+		got = string(body)
+	}))
+	defer server.Close()
+
+	if err := Execute(server.URL, "print(1)"); err != nil || got != "print(1)" {
+		t.Errorf("Expected the script to be posted, got %q err=%v", got, err)
+	}
+	err := Execute(server.URL, "error()")
+	if err == nil || !strings.Contains(err.Error(), "400 Bad Request: script rejected") {
+		t.Errorf("Expected the rejection, got %v", err)
+	}
+}
+
+func TestExecute_Invalid(t *testing.T) {
+	if err := Execute("ftp://127.0.0.1/", "print(1)"); err == nil {
+		t.Error("Expected unsupported schemes to fail")
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	err := Execute("ws"+strings.TrimPrefix(server.URL, "http"), "print(1)")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a failed handshake, got %v", err)
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %s", got)
+	}
+}
@@ -0,0 +1,158 @@
+package license
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Unknown is reported when no license could be identified
+const Unknown = "UNKNOWN"
+
+// Info is the license information found in a source file
+type Info struct {
+	SPDX       string   // SPDX identifier, or Unknown
+	Copyrights []string // copyright lines
+	Notice     string   // the comment block holding the license header, without comment markers
+}
+
+// Found reports whether any license information was found
+func (i Info) Found() bool {
+	return i.SPDX != Unknown || len(i.Copyrights) > 0
+}
+
+var (
+	spdxRegex      = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+\-]+(?:\s+(?:OR|AND|WITH)\s+[A-Za-z0-9.+\-]+)*)`)
+	copyrightRegex = regexp.MustCompile(`(?i)^\s*(?:copyright\b|\(c\)\s*\d{4}|©)`)
+)
+
+// phrases identifying common license texts; all phrases of an entry must
+// appear, and entries are checked in order
+var phrases = []struct {
+	spdx    string
+	phrases []string
+}{
+	{"Apache-2.0", []string{"apache license, version 2.0"}},
+	{"Apache-2.0", []string{"apache license 2.0"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license"}},
+	{"MPL-2.0", []string{"mozilla public license"}},
+	{"Unlicense", []string{"free and unencumbered software released into the public domain"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"Zlib", []string{"altered source versions must be plainly marked"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"MIT", []string{"mit license"}},
+}
+
+// Detect finds license information in the comments of a Lua source file
+func Detect(content string) Info {
+	info := Info{SPDX: Unknown}
+
+	for _, block := range commentBlocks(content) {
+		spdx := ""
+		if m := spdxRegex.FindStringSubmatch(block); m != nil {
+			spdx = m[1]
+		} else {
+			spdx = identify(block)
+		}
+
+		var copyrights []string
+		for _, line := range strings.Split(block, "\n") {
+			if copyrightRegex.MatchString(line) {
+				copyrights = append(copyrights, strings.TrimSpace(line))
+			}
+		}
+
+		if spdx == "" && len(copyrights) == 0 {
+			continue
+		}
+		if info.SPDX == Unknown && spdx != "" {
+			info.SPDX = spdx
+		}
+		info.Copyrights = append(info.Copyrights, copyrights...)
+		if info.Notice == "" {
+			info.Notice = block
+		}
+	}
+	return info
+}
+
+// identify matches a license text against known phrases
+func identify(text string) string {
+	lower := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	for _, p := range phrases {
+		matched := true
+		for _, phrase := range p.phrases {
+			if !strings.Contains(lower, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return p.spdx
+		}
+	}
+	return ""
+}
+
+// commentBlocks returns the text of each comment, with runs of line comments
+// on consecutive lines joined into one block
+func commentBlocks(content string) []string {
+	tokens, err := parser.Tokenize(content)
+	if err != nil {
+		// Fall back to the whole file so headers are still found in sources
+		// the lexer rejects
+		return []string{content}
+	}
+
+	var blocks []string
+	var current []string
+	lastLine := -1
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+
+	for _, tok := range tokens {
+		if tok.Kind != parser.Comment {
+			flush()
+			continue
+		}
+		text, long := stripComment(tok.Value)
+		if long {
+			flush()
+			blocks = append(blocks, text)
+			continue
+		}
+		if tok.Line != lastLine+1 {
+			flush()
+		}
+		current = append(current, text)
+		lastLine = tok.Line
+	}
+	flush()
+	return blocks
+}
+
+// stripComment removes the comment markers, reporting whether it was a long comment
+func stripComment(comment string) (string, bool) {
+	body := strings.TrimPrefix(comment, "--")
+	if strings.HasPrefix(body, "[") {
+		level := strings.Index(body[1:], "[")
+		if level >= 0 && strings.Trim(body[1:1+level], "=") == "" {
+			closing := "]" + strings.Repeat("=", level) + "]"
+			return strings.Trim(strings.TrimSuffix(body[level+2:], closing), "\n"), true
+		}
+	}
+	if strings.HasPrefix(comment, "#") {
+		return strings.TrimPrefix(comment, "#"), false
+	}
+	return strings.TrimPrefix(body, " "), false
+}
@@ -0,0 +1,78 @@
+package license
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		spdx       string
+		copyrights []string
+	}{
+		{
+			name:    "spdx identifier",
+			content: "-- SPDX-License-Identifier: Apache-2.0 OR MIT\nreturn {}",
+			spdx:    "Apache-2.0 OR MIT",
+		},
+		{
+			name: "mit long comment header",
+			content: `--[[
+  Copyright (c) 2020 rxi
+
+  Permission is hereby granted, free of charge, to any person obtaining a copy
+  of this software and associated documentation files (the "Software")...
+]]
+local json = {}`,
+			spdx:       "MIT",
+			copyrights: []string{"Copyright (c) 2020 rxi"},
+		},
+		{
+			name: "line comment block",
+			content: `-- lib.lua
+-- Copyright 2019 Jane Doe
+-- Licensed under the Apache License, Version 2.0
+local x = 1`,
+			spdx:       "Apache-2.0",
+			copyrights: []string{"Copyright 2019 Jane Doe"},
+		},
+		{
+			name:    "bsd 3 clause",
+			content: "--[==[ Redistribution and use in source and binary forms ... Neither the name of the copyright holder ]==]",
+			spdx:    "BSD-3-Clause",
+		},
+		{
+			name:       "copyright without license",
+			content:    "-- (c) 2021 Someone\nreturn 1",
+			spdx:       Unknown,
+			copyrights: []string{"(c) 2021 Someone"},
+		},
+		{
+			name:    "license text in a string is ignored",
+			content: `local s = "MIT License" -- just a comment`,
+			spdx:    Unknown,
+		},
+		{
+			name:    "nothing",
+			content: "return 1",
+			spdx:    Unknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Detect(tt.content)
+			assert.Equal(t, tt.spdx, info.SPDX)
+			assert.Equal(t, tt.copyrights, info.Copyrights)
+			assert.Equal(t, tt.spdx != Unknown || len(tt.copyrights) > 0, info.Found())
+		})
+	}
+}
+
+func TestDetect_Notice(t *testing.T) {
+	info := Detect("-- helper\nlocal x = 1\n-- MIT License\n-- Copyright (c) 2024 Acme\nreturn x")
+	assert.Equal(t, "MIT License\nCopyright (c) 2024 Acme", info.Notice, "notice should be the license comment block")
+}
@@ -0,0 +1,107 @@
+package lockfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// FileName is the lockfile looked up next to the entry file
+const FileName = "lua-bundler.lock"
+
+// currentVersion is the lockfile format version
+const currentVersion = 1
+
+// Entry records the pinned state of a remote dependency
+type Entry struct {
+	SHA256   string `json:"sha256"`
+	Resolved string `json:"resolved,omitempty"` // final URL after redirects, if different
+}
+
+// Lockfile pins the content hashes of remote dependencies
+type Lockfile struct {
+	Version int              `json:"version"`
+	Remotes map[string]Entry `json:"remotes"`
+
+	path string
+}
+
+// Load reads a lockfile, returning an empty lockfile bound to path if it does not exist
+func Load(path string) (*Lockfile, error) {
+	l := &Lockfile{
+		Version: currentVersion,
+		Remotes: make(map[string]Entry),
+		path:    path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	if l.Remotes == nil {
+		l.Remotes = make(map[string]Entry)
+	}
+
+	return l, nil
+}
+
+// Get returns the entry pinned for url
+func (l *Lockfile) Get(url string) (Entry, bool) {
+	e, ok := l.Remotes[url]
+	return e, ok
+}
+
+// Set pins an entry for url
+func (l *Lockfile) Set(url string, e Entry) {
+	l.Remotes[url] = e
+}
+
+// URLs returns the sorted URLs pinned in the lockfile
+func (l *Lockfile) URLs() []string {
+	urls := make([]string, 0, len(l.Remotes))
+	for url := range l.Remotes {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Verify reports whether content matches the hash pinned for url.
+// URLs without an entry always verify.
+func (l *Lockfile) Verify(url, content string) bool {
+	e, ok := l.Remotes[url]
+	return !ok || e.SHA256 == Hash(content)
+}
+
+// Save writes the lockfile back to its path
+func (l *Lockfile) Save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+	if err := os.WriteFile(l.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// Path returns the lockfile path
+func (l *Lockfile) Path() string {
+	return l.path
+}
+
+// Hash returns the hex-encoded SHA-256 of content
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
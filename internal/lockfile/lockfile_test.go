@@ -0,0 +1,66 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	l, err := Load(path)
+	require.NoError(t, err, "Load() should not fail for a missing lockfile")
+	assert.Empty(t, l.Remotes)
+	assert.Equal(t, path, l.Path())
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	l, err := Load(path)
+	require.NoError(t, err)
+	l.Set("https://example.com/lib.lua", Entry{SHA256: Hash("return 1")})
+	require.NoError(t, l.Save(), "Save() should not fail")
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	e, ok := loaded.Get("https://example.com/lib.lua")
+	require.True(t, ok, "entry should round-trip")
+	assert.Equal(t, Hash("return 1"), e.SHA256)
+	assert.Equal(t, currentVersion, loaded.Version)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0644))
+
+	_, err := Load(path)
+	assert.Error(t, err, "Load() should fail on invalid JSON")
+}
+
+func TestVerify(t *testing.T) {
+	l, err := Load(filepath.Join(t.TempDir(), FileName))
+	require.NoError(t, err)
+	l.Set("https://example.com/lib.lua", Entry{SHA256: Hash("return 1")})
+
+	assert.True(t, l.Verify("https://example.com/lib.lua", "return 1"))
+	assert.False(t, l.Verify("https://example.com/lib.lua", "return 2"))
+	assert.True(t, l.Verify("https://example.com/other.lua", "anything"), "unpinned URLs should verify")
+}
+
+func TestHash(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Hash(""))
+}
+
+func TestURLs(t *testing.T) {
+	l, err := Load(filepath.Join(t.TempDir(), FileName))
+	require.NoError(t, err)
+	l.Set("https://b.example.com/lib.lua", Entry{SHA256: Hash("b")})
+	l.Set("https://a.example.com/lib.lua", Entry{SHA256: Hash("a")})
+
+	assert.Equal(t, []string{"https://a.example.com/lib.lua", "https://b.example.com/lib.lua"}, l.URLs())
+}
@@ -0,0 +1,210 @@
+package lsp
+
+import (
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// definition returns where the name at offset in text is defined, following
+// require the way the bundler resolves it: a require path leads to the
+// module file, a local holding a require result to the module, and a field
+// of such a local (helper.greet, helper:greet()) to the field's definition
+// in the module. Returns nil for anything else.
+func definition(b *bundler.Bundler, path, text string, offset int, read func(string) (string, error)) *Location {
+	tokens, err := parser.Tokenize(text)
+	if err != nil {
+		return nil
+	}
+	for _, call := range parser.FindRequires(tokens) {
+		if offset >= call.Token.Start && offset <= call.Token.End {
+			return moduleLocation(b, path, call.Path, "", read)
+		}
+	}
+
+	chunk, err := parser.Parse(text)
+	if err != nil {
+		return nil
+	}
+	required := requiredLocals(chunk)
+
+	var loc *Location
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if loc != nil {
+			return false
+		}
+		span := n.Range()
+		if offset < span.Start || offset > span.End {
+			return false
+		}
+		switch n := n.(type) {
+		case *parser.FieldExpr:
+			if within(offset, n.Name) {
+				if modulePath := requiredBy(n.X, required); modulePath != "" {
+					loc = moduleLocation(b, path, modulePath, n.Name.Value, read)
+				}
+			}
+		case *parser.MethodCallExpr:
+			if within(offset, n.Name) {
+				if modulePath := requiredBy(n.Recv, required); modulePath != "" {
+					loc = moduleLocation(b, path, modulePath, n.Name.Value, read)
+				}
+			}
+		case *parser.Ident:
+			if modulePath := required[n.Binding]; modulePath != "" {
+				loc = moduleLocation(b, path, modulePath, "", read)
+			}
+		}
+		return true
+	})
+	return loc
+}
+
+// within reports whether offset falls on tok
+func within(offset int, tok parser.Token) bool {
+	return offset >= tok.Start && offset <= tok.End
+}
+
+// requiredLocals maps locals initialized with local x = require("path") to
+// the require path
+func requiredLocals(chunk *parser.Chunk) map[*parser.Binding]string {
+	required := make(map[*parser.Binding]string)
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if s, ok := n.(*parser.LocalStmt); ok {
+			for i, name := range s.Names {
+				if i < len(s.Values) {
+					if modulePath := requirePath(s.Values[i]); modulePath != "" {
+						required[name.Binding] = modulePath
+					}
+				}
+			}
+		}
+		return true
+	})
+	return required
+}
+
+// requiredBy returns the require path whose result e holds: a require call
+// or a local initialized with one
+func requiredBy(e parser.Expr, required map[*parser.Binding]string) string {
+	if id, ok := e.(*parser.Ident); ok {
+		return required[id.Binding]
+	}
+	return requirePath(e)
+}
+
+// requirePath returns the path of a require("path") call expression
+func requirePath(e parser.Expr) string {
+	call, ok := e.(*parser.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return ""
+	}
+	fn, ok := call.Fn.(*parser.Ident)
+	if !ok || fn.Name != "require" || fn.Binding == nil || !fn.Binding.Global() {
+		return ""
+	}
+	str, ok := call.Args[0].(*parser.StringExpr)
+	if !ok {
+		return ""
+	}
+	path, _ := str.Token.Unquote()
+	return path
+}
+
+// moduleLocation returns the location of a required module, at the
+// definition of member when it is given and found, else at the top
+func moduleLocation(b *bundler.Bundler, path, modulePath, member string, read func(string) (string, error)) *Location {
+	target, external := b.ResolveRequire(path, modulePath)
+	if external {
+		return nil
+	}
+	text, err := read(target)
+	if err != nil {
+		return nil
+	}
+
+	pos := Position{}
+	if member != "" {
+		if offset := memberDefinition(text, member); offset >= 0 {
+			pos = offsetToPosition(text, offset)
+		}
+	}
+	return &Location{URI: pathToURI(target), Range: Range{Start: pos, End: pos}}
+}
+
+// memberDefinition returns the offset where a module defines a field of the
+// table it returns: a field of a returned table constructor, or for a
+// returned local M, function M.name(), function M:name(), M.name = ... or
+// a field of M's table constructor. Returns -1 when there is none.
+func memberDefinition(text, member string) int {
+	chunk, err := parser.Parse(text)
+	if err != nil || len(chunk.Block.Stmts) == 0 {
+		return -1
+	}
+	ret, ok := chunk.Block.Stmts[len(chunk.Block.Stmts)-1].(*parser.ReturnStmt)
+	if !ok || len(ret.Values) != 1 {
+		return -1
+	}
+
+	switch v := ret.Values[0].(type) {
+	case *parser.TableExpr:
+		return tableField(v, member)
+	case *parser.Ident:
+		if v.Binding == nil || v.Binding.Global() {
+			return -1
+		}
+		return localMember(chunk, v.Binding, member)
+	}
+	return -1
+}
+
+// localMember returns the offset of the first definition of a field of the
+// local module table
+func localMember(chunk *parser.Chunk, module *parser.Binding, member string) int {
+	isModule := func(e parser.Expr) bool {
+		id, ok := e.(*parser.Ident)
+		return ok && id.Binding == module
+	}
+
+	found := -1
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if found >= 0 {
+			return false
+		}
+		switch s := n.(type) {
+		case *parser.LocalStmt:
+			for i, name := range s.Names {
+				if name.Binding == module && i < len(s.Values) {
+					if t, ok := s.Values[i].(*parser.TableExpr); ok {
+						found = tableField(t, member)
+					}
+				}
+			}
+		case *parser.FunctionStmt:
+			if s.Method != nil && isModule(s.Target) && s.Method.Value == member {
+				found = s.Method.Start
+			} else if f, ok := s.Target.(*parser.FieldExpr); ok && s.Method == nil && isModule(f.X) && f.Name.Value == member {
+				found = f.Name.Start
+			}
+		case *parser.AssignStmt:
+			for _, t := range s.Targets {
+				if f, ok := t.(*parser.FieldExpr); ok && isModule(f.X) && f.Name.Value == member {
+					found = f.Name.Start
+					break
+				}
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// tableField returns the offset of a name = value field of a table
+// constructor, or -1
+func tableField(t *parser.TableExpr, name string) int {
+	for _, f := range t.Fields {
+		if f.Kind == parser.NamedField && f.Name.Value == name {
+			return f.Name.Start
+		}
+	}
+	return -1
+}
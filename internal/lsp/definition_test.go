@@ -0,0 +1,78 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinition(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "utils"), 0755))
+	modules := map[string]string{
+		"utils/helper.lua": "local helper = { name = \"h\" }\n\nfunction helper.greet() end\nfunction helper:shout() end\nhelper.version = 2\nreturn helper\n",
+		"utils/table.lua":  "return {\n  answer = 42,\n}\n",
+	}
+	for name, content := range modules {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	b, err := bundler.NewBundler(filepath.Join(dir, "main.lua"), false, false)
+	require.NoError(t, err)
+
+	main := filepath.Join(dir, "main.lua")
+	text := `local helper = require("utils.helper")
+local t = require("utils.table")
+helper.greet()
+helper:shout()
+print(helper.version, helper.name, helper.missing, t.answer)
+local gui = require("Players.LocalPlayer")
+print(require("utils.table").answer)
+`
+	read := func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+	helperURI := pathToURI(filepath.Join(dir, "utils", "helper.lua"))
+	tableURI := pathToURI(filepath.Join(dir, "utils", "table.lua"))
+	at := func(line, char int) *Location {
+		return &Location{Range: Range{Start: Position{Line: line, Character: char}, End: Position{Line: line, Character: char}}}
+	}
+
+	tests := []struct {
+		name   string
+		near   string // definition is requested at the first occurrence of near
+		skip   int    // bytes into near
+		uri    string
+		expect *Location
+	}{
+		{"require path", `"utils.helper"`, 3, helperURI, at(0, 0)},
+		{"local holding a require", "helper.greet", 2, helperURI, at(0, 0)},
+		{"function field", "helper.greet", 8, helperURI, at(2, 16)},
+		{"method", "helper:shout", 8, helperURI, at(3, 16)},
+		{"assigned field", "helper.version", 9, helperURI, at(4, 7)},
+		{"table constructor field", "helper.name", 8, helperURI, at(0, 17)},
+		{"unknown field falls back to the module", "helper.missing", 8, helperURI, at(0, 0)},
+		{"returned table", "t.answer", 3, tableURI, at(1, 2)},
+		{"field of a require call", `).answer`, 3, tableURI, at(1, 2)},
+		{"external require", "Players.LocalPlayer", 2, "", nil},
+		{"unrelated name", "print", 1, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := strings.Index(text, tt.near)
+			require.GreaterOrEqual(t, offset, 0)
+			loc := definition(b, main, text, offset+tt.skip, read)
+			if tt.expect == nil {
+				assert.Nil(t, loc)
+				return
+			}
+			tt.expect.URI = tt.uri
+			assert.Equal(t, tt.expect, loc)
+		})
+	}
+}
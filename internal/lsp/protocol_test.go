@@ -0,0 +1,61 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageFraming(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeMessage(&buf, notification{JSONRPC: "2.0", Method: "initialized", Params: map[string]int{}}))
+	assert.True(t, strings.HasPrefix(buf.String(), "Content-Length: 52\r\n\r\n{"), buf.String())
+
+	buf.WriteString("Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 40\r\n\r\n")
+	buf.WriteString(`{"jsonrpc":"2.0","id":7,"method":"foo"}` + "\n")
+
+	r := bufio.NewReader(&buf)
+	msg, err := readMessage(r)
+	require.NoError(t, err)
+	assert.Equal(t, "initialized", msg.Method)
+	assert.Nil(t, msg.ID)
+
+	msg, err = readMessage(r)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", msg.Method)
+	require.NotNil(t, msg.ID)
+	assert.Equal(t, "7", string(*msg.ID))
+
+	_, err = readMessage(bufio.NewReader(strings.NewReader("X-Other: 1\r\n\r\n{}")))
+	assert.Error(t, err, "messages need a Content-Length")
+}
+
+func TestPositions(t *testing.T) {
+	text := "local a = 1\nlocal s = \"😀\" .. b\n"
+
+	b := strings.Index(text, "b")
+	assert.Equal(t, Position{Line: 1, Character: 18}, offsetToPosition(text, b), "the emoji is two UTF-16 units")
+	assert.Equal(t, b, positionToOffset(text, Position{Line: 1, Character: 18}))
+
+	assert.Equal(t, Position{Line: 0, Character: 0}, offsetToPosition(text, 0))
+	assert.Equal(t, 11, positionToOffset(text, Position{Line: 0, Character: 99}), "columns clamp to the line end")
+	assert.Equal(t, len(text), positionToOffset(text, Position{Line: 9, Character: 0}))
+}
+
+func TestURIs(t *testing.T) {
+	path := filepath.FromSlash("/home/me/my game/main.lua")
+	uri := pathToURI(path)
+	assert.Equal(t, "file:///home/me/my%20game/main.lua", uri)
+
+	back, err := uriToPath(uri)
+	require.NoError(t, err)
+	assert.Equal(t, path, back)
+
+	_, err = uriToPath("untitled:Untitled-1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,328 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+)
+
+// Factory creates a bundler for the project in dir, configured from the
+// project the way the CLI would configure it
+type Factory func(dir string) (*bundler.Bundler, error)
+
+// Server is a language server for one client connection. It keeps the
+// text of open documents, so diagnostics follow unsaved edits.
+type Server struct {
+	newBundler Factory
+	out        io.Writer
+
+	root     string                      // workspace folder, "" when none was given
+	docs     map[string]string           // open document path -> text
+	bundlers map[string]*bundler.Bundler // project dir -> bundler
+	shutdown bool
+}
+
+// NewServer returns a server creating bundlers with newBundler
+func NewServer(newBundler Factory) *Server {
+	return &Server{
+		newBundler: newBundler,
+		docs:       make(map[string]string),
+		bundlers:   make(map[string]*bundler.Bundler),
+	}
+}
+
+// Run serves requests from in, writing responses and notifications to out,
+// until the client sends exit or closes in
+func (s *Server) Run(in io.Reader, out io.Writer) error {
+	s.out = out
+	r := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request or applies a notification
+func (s *Server) handle(msg *message) error {
+	result, err := s.dispatch(msg)
+	if msg.ID == nil {
+		// Notifications have no response, even when they fail
+		return nil
+	}
+	if err != nil {
+		code := -32603
+		var rerr *responseError
+		if errors.As(err, &rerr) {
+			code = rerr.Code
+		}
+		return writeMessage(s.out, errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: responseError{Code: code, Message: err.Error()}})
+	}
+	return writeMessage(s.out, response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// dispatch runs the handler of a method
+func (s *Server) dispatch(msg *message) (interface{}, error) {
+	if s.shutdown && msg.Method != "exit" {
+		return nil, &responseError{Code: -32600, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			RootURI          string `json:"rootUri"`
+			RootPath         string `json:"rootPath"`
+			WorkspaceFolders []struct {
+				URI string `json:"uri"`
+			} `json:"workspaceFolders"`
+		}
+		if len(msg.Params) > 0 {
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				return nil, &responseError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)}
+			}
+		}
+		s.root = params.RootPath
+		if params.RootURI != "" {
+			s.root, _ = uriToPath(params.RootURI)
+		} else if len(params.WorkspaceFolders) > 0 {
+			s.root, _ = uriToPath(params.WorkspaceFolders[0].URI)
+		}
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    1, // full document text on every change
+					"save":      true,
+				},
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "lua-bundler"},
+		}, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		path, err := uriToPath(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		s.docs[path] = params.TextDocument.Text
+		return nil, s.publishAll()
+
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   textDocumentIdentifier `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		path, err := uriToPath(params.TextDocument.URI)
+		if err != nil || len(params.ContentChanges) == 0 {
+			return nil, err
+		}
+		s.docs[path] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.publishAll()
+
+	case "textDocument/didSave":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if path, err := uriToPath(params.TextDocument.URI); err == nil && filepath.Base(path) == config.FileName {
+			// Project settings changed; configure bundlers again
+			s.bundlers = make(map[string]*bundler.Bundler)
+		}
+		return nil, s.publishAll()
+
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		path, err := uriToPath(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.docs, path)
+		if err := s.publish(path, []Diagnostic{}); err != nil {
+			return nil, err
+		}
+		return nil, s.publishAll()
+
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)}
+		}
+		path, err := uriToPath(params.TextDocument.URI)
+		if err != nil {
+			return nil, &responseError{Code: -32602, Message: err.Error()}
+		}
+		text, err := s.read(path)
+		if err != nil {
+			return nil, nil
+		}
+		b, err := s.bundler(path)
+		if err != nil {
+			return nil, err
+		}
+		loc := definition(b, path, text, positionToOffset(text, params.Position), s.read)
+		if loc == nil {
+			return nil, nil
+		}
+		return loc, nil
+	}
+
+	if msg.ID != nil && !strings.HasPrefix(msg.Method, "$/") {
+		return nil, &responseError{Code: -32601, Message: fmt.Sprintf("method not found: %s", msg.Method)}
+	}
+	return nil, nil
+}
+
+// publishAll lints every open document again. An edit can fix or break
+// requires in other files, so all of them are refreshed.
+func (s *Server) publishAll() error {
+	paths := make([]string, 0, len(s.docs))
+	for path := range s.docs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := s.publish(path, s.diagnostics(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diagnostics lints an open document
+func (s *Server) diagnostics(path string) []Diagnostic {
+	text := s.docs[path]
+	b, err := s.bundler(path)
+	if err != nil {
+		return []Diagnostic{{Severity: severityError, Source: "lua-bundler", Message: err.Error()}}
+	}
+
+	diags := []Diagnostic{}
+	for _, d := range b.Lint(path, text, s.read) {
+		severity := severityWarning
+		if d.Severity == bundler.SeverityError {
+			severity = severityError
+		}
+		diags = append(diags, Diagnostic{
+			Range:    Range{Start: offsetToPosition(text, d.Start), End: offsetToPosition(text, d.End)},
+			Severity: severity,
+			Code:     d.Rule,
+			Source:   "lua-bundler",
+			Message:  d.Message,
+		})
+	}
+	return diags
+}
+
+// publish sends the diagnostics of a document to the client
+func (s *Server) publish(path string, diags []Diagnostic) error {
+	return writeMessage(s.out, notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: map[string]interface{}{
+			"uri":         pathToURI(path),
+			"diagnostics": diags,
+		},
+	})
+}
+
+// read returns the text of a file, preferring the open document's buffer
+func (s *Server) read(path string) (string, error) {
+	if text, ok := s.docs[path]; ok {
+		return text, nil
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// bundler returns the bundler of the project containing path
+func (s *Server) bundler(path string) (*bundler.Bundler, error) {
+	dir := s.projectDir(path)
+	if b, ok := s.bundlers[dir]; ok {
+		return b, nil
+	}
+	b, err := s.newBundler(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.bundlers[dir] = b
+	return b, nil
+}
+
+// projectDir returns the directory require paths in path resolve from: the
+// nearest directory with a config file, else the workspace folder holding
+// path, else the file's own directory
+func (s *Server) projectDir(path string) string {
+	dir := filepath.Dir(path)
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, config.FileName)); err == nil {
+			return d
+		}
+		if d == s.root || filepath.Dir(d) == d {
+			break
+		}
+	}
+	if s.root != "" {
+		if rel, err := filepath.Rel(s.root, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			return s.root
+		}
+	}
+	return dir
+}
@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer() *Server {
+	return NewServer(func(dir string) (*bundler.Bundler, error) {
+		return bundler.NewBundler(filepath.Join(dir, "main.lua"), false, false)
+	})
+}
+
+func request(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+// output is a message written by the server
+type output struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// run feeds client messages to the server and returns what it wrote
+func run(t *testing.T, s *Server, msgs ...interface{}) []output {
+	var in, out bytes.Buffer
+	for _, m := range msgs {
+		require.NoError(t, writeMessage(&in, m))
+	}
+	require.NoError(t, s.Run(&in, &out))
+
+	var written []output
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readBody(r)
+		if err != nil {
+			return written
+		}
+		var o output
+		require.NoError(t, json.Unmarshal(body, &o))
+		written = append(written, o)
+	}
+}
+
+type published struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func TestServerSession(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "utils"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "utils", "log.lua"), []byte("local M = {}\nfunction M.info() end\nreturn M\n"), 0644))
+	main := filepath.Join(dir, "src", "main.lua")
+	uri := pathToURI(main)
+
+	s := newTestServer()
+	out := run(t, s,
+		request(1, "initialize", map[string]interface{}{"rootUri": pathToURI(dir)}),
+		notify("initialized", map[string]interface{}{}),
+		notify("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{
+			"uri": uri, "languageId": "lua", "version": 1,
+			"text": "local log = require(\"utils.log\")\nlocal x = require(\"utils.missing\")\n",
+		}}),
+		notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]string{{"text": "local log = require(\"utils.log\")\nlog.info()\n"}},
+		}),
+		request(2, "textDocument/definition", map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri},
+			"position":     Position{Line: 1, Character: 5},
+		}),
+		request(3, "workspace/symbol", map[string]string{"query": "x"}),
+		request(4, "shutdown", nil),
+		notify("exit", nil),
+	)
+	require.Len(t, out, 6)
+
+	require.NotNil(t, out[0].ID)
+	assert.Equal(t, 1, *out[0].ID)
+	assert.Contains(t, string(out[0].Result), `"definitionProvider":true`)
+
+	assert.Equal(t, "textDocument/publishDiagnostics", out[1].Method)
+	var diags published
+	require.NoError(t, json.Unmarshal(out[1].Params, &diags))
+	assert.Equal(t, uri, diags.URI)
+	require.Len(t, diags.Diagnostics, 1, "the missing module is reported, resolved from the workspace root")
+	assert.Equal(t, Diagnostic{
+		Range:    Range{Start: Position{Line: 1, Character: 18}, End: Position{Line: 1, Character: 33}},
+		Severity: severityError,
+		Code:     bundler.RuleUnresolvedRequire,
+		Source:   "lua-bundler",
+		Message:  "cannot resolve utils.missing: utils/missing.lua not found",
+	}, diags.Diagnostics[0])
+
+	require.NoError(t, json.Unmarshal(out[2].Params, &diags))
+	assert.Empty(t, diags.Diagnostics, "diagnostics follow the edited buffer")
+
+	var loc Location
+	require.NoError(t, json.Unmarshal(out[3].Result, &loc))
+	assert.Equal(t, Location{URI: pathToURI(filepath.Join(dir, "utils", "log.lua")), Range: Range{Start: Position{Line: 1, Character: 11}, End: Position{Line: 1, Character: 11}}}, loc)
+
+	require.NotNil(t, out[4].Error)
+	assert.Equal(t, -32601, out[4].Error.Code)
+	assert.Equal(t, "null", string(out[5].Result))
+}
+
+func TestServerProjectDir(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "game")
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "src", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "lua-bundler.json"), []byte("{}"), 0644))
+
+	s := newTestServer()
+	s.root = root
+	assert.Equal(t, project, s.projectDir(filepath.Join(project, "src", "ui", "hud.lua")), "nearest config wins")
+	assert.Equal(t, root, s.projectDir(filepath.Join(root, "tools", "gen.lua")), "workspace root without a config")
+
+	outside := t.TempDir()
+	assert.Equal(t, outside, s.projectDir(filepath.Join(outside, "x.lua")))
+}
+
+func TestServerReportsConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.lua")
+	s := NewServer(func(string) (*bundler.Bundler, error) {
+		return nil, os.ErrPermission
+	})
+	out := run(t, s, notify("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{"uri": pathToURI(main), "text": ""}}))
+	require.Len(t, out, 1)
+
+	var diags published
+	require.NoError(t, json.Unmarshal(out[0].Params, &diags))
+	require.Len(t, diags.Diagnostics, 1)
+	assert.Equal(t, severityError, diags.Diagnostics[0].Severity)
+}
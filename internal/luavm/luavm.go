@@ -0,0 +1,101 @@
+// Package luavm runs Lua programs in a Lua 5.1 VM embedded in the binary,
+// capturing what they print, so checks need no interpreter on the PATH.
+package luavm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// chunkName is what errors call the program
+const chunkName = "check.lua"
+
+// Run runs program and returns what it printed with print and io.write,
+// with an error carrying the Lua error when it fails or times out. Each
+// run gets a fresh VM with the standard libraries.
+func Run(program string, timeout time.Duration) (string, error) {
+	L := lua.NewState()
+	defer L.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	var out strings.Builder
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		for i := 1; i <= L.GetTop(); i++ {
+			if i > 1 {
+				out.WriteByte('\t')
+			}
+			out.WriteString(L.ToStringMeta(L.Get(i)).String())
+		}
+		out.WriteByte('\n')
+		return 0
+	}))
+	if io, ok := L.GetGlobal("io").(*lua.LTable); ok {
+		io.RawSetString("write", L.NewFunction(func(L *lua.LState) int {
+			for i := 1; i <= L.GetTop(); i++ {
+				out.WriteString(L.CheckString(i))
+			}
+			return 0
+		}))
+	}
+
+	nativeAsC(L)
+
+	fn, err := L.Load(strings.NewReader(program), chunkName)
+	if err == nil {
+		L.Push(fn)
+		err = L.PCall(0, lua.MultRet, nil)
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return out.String(), fmt.Errorf("timed out after %s", timeout)
+		}
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) && apiErr.Object != nil {
+			return out.String(), errors.New(apiErr.Object.String())
+		}
+		return out.String(), err
+	}
+	return out.String(), nil
+}
+
+// nativeAsC wraps debug.getinfo to report Go builtins, which gopher-lua
+// calls "G", as the "C" functions they are in reference Lua, so programs
+// telling native functions from Lua ones, such as anti-tamper checks, run
+// as they would outside the embedded VM
+func nativeAsC(L *lua.LState) {
+	debug, ok := L.GetGlobal("debug").(*lua.LTable)
+	if !ok {
+		return
+	}
+	getinfo := debug.RawGetString("getinfo")
+	debug.RawSetString("getinfo", L.NewFunction(func(L *lua.LState) int {
+		args := make([]lua.LValue, L.GetTop())
+		for i := range args {
+			args[i] = L.Get(i + 1)
+		}
+		// Stack levels count from the caller, not from this wrapper
+		if len(args) > 0 {
+			if level, ok := args[0].(lua.LNumber); ok {
+				args[0] = level + 1
+			}
+		}
+		L.Push(getinfo)
+		for _, arg := range args {
+			L.Push(arg)
+		}
+		L.Call(len(args), 1)
+		if info, ok := L.Get(-1).(*lua.LTable); ok && info.RawGetString("what") == lua.LString("G") {
+			info.RawSetString("what", lua.LString("C"))
+			info.RawSetString("source", lua.LString("=[C]"))
+			info.RawSetString("short_src", lua.LString("[C]"))
+		}
+		return 1
+	}))
+}
@@ -0,0 +1,64 @@
+package luavm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	out, err := Run("print(1, 'two', nil)\nio.write('a', 2, '\\n')\nprint(setmetatable({}, {__tostring = function() return 'stub' end}))\n", time.Second)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "1\ttwo\tnil\na2\nstub\n"; out != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	out, err := Run("print('before')\nlocal x = nil\nx()\n", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "check.lua:3:") {
+		t.Errorf("Expected the runtime error with its line, got %v", err)
+	}
+	if out != "before\n" {
+		t.Errorf("Expected the output before the error, got %q", out)
+	}
+
+	if _, err := Run("local x: number = 1\n", time.Second); err == nil {
+		t.Error("Expected a syntax error for Luau type annotations")
+	}
+
+	if _, err := Run("while true do end\n", 50*time.Millisecond); err == nil || err.Error() != "timed out after 50ms" {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+func TestRun_FreshState(t *testing.T) {
+	if _, err := Run("leaked = true\n", time.Second); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	out, err := Run("print(leaked)\n", time.Second)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if out != "nil\n" {
+		t.Errorf("Expected globals not to leak between runs, got %q", out)
+	}
+}
+
+func TestRun_NativeFunctionsAsC(t *testing.T) {
+	program := `local info = debug.getinfo(print, "S")
+print(info.what, info.short_src)
+print(debug.getinfo(function() end, "S").what)
+local function where() return debug.getinfo(1, "l").currentline, debug.getinfo(2, "l").currentline end
+print(where())
+`
+	out, err := Run(program, time.Second)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := "C\t[C]\nLua\n4\t5\n"; out != want {
+		t.Errorf("Expected Go builtins reported as C and stack levels kept, got %q", out)
+	}
+}
@@ -0,0 +1,88 @@
+// Package notify shows desktop notifications with the tools each OS ships:
+// osascript on macOS, notify-send on Linux and the BSDs, and a PowerShell
+// toast on Windows.
+package notify
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// maxMessage bounds the message shown, as notifications cut long ones
+const maxMessage = 200
+
+// appName is who notifications come from
+const appName = "lua-bundler"
+
+// Notification is one desktop notification
+type Notification struct {
+	Title   string
+	Message string
+	Failure bool // shown as urgent where the OS supports it
+}
+
+// Send shows n on this machine's desktop
+func Send(n Notification) error {
+	cmd, err := Command(runtime.GOOS, n)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s failed: %s", cmd.Args[0], msg)
+		}
+		return fmt.Errorf("%s failed: %w", cmd.Args[0], err)
+	}
+	return nil
+}
+
+// Command returns the command showing n on goos
+func Command(goos string, n Notification) (*exec.Cmd, error) {
+	message := truncate(n.Message)
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(n.Title))
+		if n.Failure {
+			script += ` sound name "Basso"`
+		}
+		return exec.Command("osascript", "-e", script), nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		urgency := "normal"
+		if n.Failure {
+			urgency = "critical"
+		}
+		return exec.Command("notify-send", "--app-name="+appName, "--urgency="+urgency, n.Title, message), nil
+	case "windows":
+		// The text travels in the environment, so nothing needs escaping
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToast)
+		cmd.Env = append(os.Environ(), "LUA_BUNDLER_NOTIFY_TITLE="+n.Title, "LUA_BUNDLER_NOTIFY_MESSAGE="+message)
+		return cmd, nil
+	}
+	return nil, fmt.Errorf("desktop notifications are not supported on %s", goos)
+}
+
+// windowsToast shows a toast with the title and message from the
+// environment
+const windowsToast = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:LUA_BUNDLER_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:LUA_BUNDLER_NOTIFY_MESSAGE)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('` + appName + `').Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+// appleScriptString returns s as a double-quoted AppleScript string
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// truncate shortens a message to maxMessage characters on one line
+func truncate(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > maxMessage {
+		return string(runes[:maxMessage-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	n := Notification{Title: "Build failed", Message: `module "net" not found`, Failure: true}
+
+	cmd, err := Command("darwin", n)
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	want := `display notification "module \"net\" not found" with title "Build failed" sound name "Basso"`
+	if cmd.Args[0] != "osascript" || cmd.Args[2] != want {
+		t.Errorf("Unexpected macOS command %q", cmd.Args)
+	}
+
+	cmd, err = Command("linux", n)
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if got := strings.Join(cmd.Args, "|"); got != `notify-send|--app-name=lua-bundler|--urgency=critical|Build failed|module "net" not found` {
+		t.Errorf("Unexpected Linux command %s", got)
+	}
+
+	cmd, err = Command("windows", n)
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if cmd.Args[0] != "powershell" || !strings.Contains(strings.Join(cmd.Env, "\n"), "LUA_BUNDLER_NOTIFY_MESSAGE="+n.Message) {
+		t.Errorf("Unexpected Windows command %q", cmd.Args)
+	}
+
+	if _, err := Command("plan9", n); err == nil {
+		t.Error("Expected unsupported systems to fail")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("line one\n\tline  two"); got != "line one line two" {
+		t.Errorf("truncate = %q", got)
+	}
+	long := truncate(strings.Repeat("é", 300))
+	if n := len([]rune(long)); n != maxMessage || !strings.HasSuffix(long, "…") {
+		t.Errorf("Expected %d characters ending in an ellipsis, got %d", maxMessage, n)
+	}
+}
@@ -0,0 +1,193 @@
+package obfuscator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// localPrefix matches the keywords opening a local declaration
+var localPrefix = regexp.MustCompile(`^local\s+(function\s+)?`)
+
+// flattenFlow turns the chunk and function bodies into dispatchers: a loop
+// running one statement per state, in shuffled branches. Locals are
+// declared before the loop. Bodies where that would change behaviour are
+// left in order: those with goto, labels, a break or continue reaching the
+// dispatcher, attributed or annotated locals, type aliases, a local
+// declared twice, or a local whose name is used before its declaration.
+// Hot functions and code the parser cannot read are left as they are.
+func (o *Obfuscator) flattenFlow(code string, p Pass) string {
+	chunk, err := parser.Parse(code)
+	if err != nil {
+		return code
+	}
+	minStatements := p.MinStatements
+	if minStatements == 0 {
+		minStatements = 3
+	}
+
+	// Outer bodies come first, so flattening in reverse sees inner bodies
+	// flattened already
+	blocks := []*parser.Block{chunk.Block}
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if skipHot(code, n) {
+			return false
+		}
+		if fn, ok := n.(*parser.FunctionExpr); ok {
+			blocks = append(blocks, fn.Body)
+		}
+		return true
+	})
+	var edits []edit
+	for i := len(blocks) - 1; i >= 0; i-- {
+		stmts := blocks[i].Stmts
+		if len(stmts) < minStatements {
+			continue
+		}
+		if text, ok := o.dispatcher(code, stmts, edits); ok {
+			edits = append(edits, edit{stmts[0].Range().Start, stmts[len(stmts)-1].Range().End, text})
+		}
+	}
+	return applyEdits(code, 0, len(code), edits)
+}
+
+// dispatcher returns the flattened form of stmts, or false when they
+// cannot be flattened
+func (o *Obfuscator) dispatcher(code string, stmts []parser.Stmt, edits []edit) (string, bool) {
+	var locals []string
+	declared := make(map[string]bool)
+	declare := func(name string, before []parser.Node) bool {
+		if declared[name] {
+			return false
+		}
+		for _, n := range before {
+			if usesName(n, name) {
+				return false
+			}
+		}
+		declared[name] = true
+		locals = append(locals, name)
+		return true
+	}
+
+	var bodies []string
+	for i, stmt := range stmts {
+		if escapesDispatcher(stmt) {
+			return "", false
+		}
+		earlier := make([]parser.Node, 0, i+1)
+		for _, s := range stmts[:i] {
+			earlier = append(earlier, s)
+		}
+
+		text := applyEdits(code, stmt.Range().Start, stmt.Range().End, edits)
+		switch s := stmt.(type) {
+		case *parser.TypeStmt:
+			return "", false
+		case *parser.LocalStmt:
+			// A value naming the local means an outer variable of that name
+			for _, v := range s.Values {
+				earlier = append(earlier, v)
+			}
+			for j, name := range s.Names {
+				if s.Attribs[j] != "" || !declare(name.Name, earlier) {
+					return "", false
+				}
+			}
+			names := code[s.Start:s.Names[len(s.Names)-1].End]
+			if len(s.Values) > 0 {
+				names = code[s.Start:s.Values[0].Range().Start]
+			}
+			if strings.Contains(names, ":") {
+				return "", false
+			}
+			if len(s.Values) == 0 {
+				continue
+			}
+			text = localPrefix.ReplaceAllString(text, "")
+		case *parser.LocalFunctionStmt:
+			if !declare(s.Name.Name, earlier) {
+				return "", false
+			}
+			text = s.Name.Name + " = function" + strings.TrimPrefix(localPrefix.ReplaceAllString(text, ""), s.Name.Name)
+		}
+		bodies = append(bodies, text)
+	}
+	if len(bodies) == 0 {
+		return "", false
+	}
+
+	// Distinct states, 0 ending the loop
+	states := make([]int, len(bodies))
+	used := map[int]bool{0: true}
+	for i := range states {
+		for used[states[i]] {
+			states[i] = randomInt(1<<16) + 1
+		}
+		used[states[i]] = true
+	}
+	state := o.generateObfuscatedName()
+	branches := make([]string, len(bodies))
+	for i, body := range bodies {
+		next := 0
+		if i+1 < len(states) {
+			next = states[i+1]
+		}
+		branch := fmt.Sprintf("%s == %d then\n%s\n%s = %d", state, states[i], body, state, next)
+		if _, ok := stmts[len(stmts)-1].(*parser.ReturnStmt); ok && i == len(bodies)-1 {
+			branch = fmt.Sprintf("%s == %d then\n%s", state, states[i], body)
+		}
+		branches[i] = branch
+	}
+	for i := len(branches) - 1; i > 0; i-- {
+		j := randomInt(i + 1)
+		branches[i], branches[j] = branches[j], branches[i]
+	}
+
+	var out strings.Builder
+	for _, name := range locals {
+		fmt.Fprintf(&out, "local %s\n", name)
+	}
+	fmt.Fprintf(&out, "local %s = %d\nwhile %s ~= 0 do\nif %s\nend\nend", state, states[0], state, strings.Join(branches, "\nelseif "))
+	return out.String(), true
+}
+
+// escapesDispatcher reports whether stmt would jump out of a dispatcher
+// loop wrapped around it: a goto or label anywhere outside nested
+// functions, or a break or continue outside nested loops
+func escapesDispatcher(stmt parser.Stmt) bool {
+	escapes := false
+	parser.Walk(stmt, func(n parser.Node) bool {
+		switch n.(type) {
+		case *parser.FunctionExpr:
+			return false
+		case *parser.GotoStmt, *parser.LabelStmt:
+			escapes = true
+		}
+		return !escapes
+	})
+	parser.Walk(stmt, func(n parser.Node) bool {
+		switch n.(type) {
+		case *parser.FunctionExpr, *parser.WhileStmt, *parser.RepeatStmt, *parser.NumericForStmt, *parser.GenericForStmt:
+			return false
+		case *parser.BreakStmt, *parser.ContinueStmt:
+			escapes = true
+		}
+		return !escapes
+	})
+	return escapes
+}
+
+// usesName reports whether name occurs as an identifier in n
+func usesName(n parser.Node, name string) bool {
+	found := false
+	parser.Walk(n, func(node parser.Node) bool {
+		if id, ok := node.(*parser.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,62 @@
+package obfuscator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// stringLiterals returns the string literals of src in order
+func stringLiterals(t *testing.T, src string) []string {
+	tokens, err := parser.Tokenize(src)
+	if err != nil {
+		t.Fatalf("tokenize: %v\n%s", err, src)
+	}
+	var literals []string
+	for _, tok := range tokens {
+		// Renaming reaches into the expressions of interpolated strings
+		if tok.Kind == parser.String && !(strings.HasPrefix(tok.Value, "`") && strings.Contains(tok.Value, "{")) {
+			literals = append(literals, tok.Value)
+		}
+	}
+	return literals
+}
+
+func FuzzObfuscate(f *testing.F) {
+	seeds := []string{
+		"local name = \"héllo  wörld\" -- ünïcode\nprint(name)\n",
+		"local msg = [[\nDear name,\n\n  l'été  ]]\nlocal name = 1\nprint(msg, name)\n",
+		"local s = \"it's\" -- don't\nlocal v = 'a -- b'\nreturn s .. v\n",
+		"local t = { label = `{1}  €`, count = 2 }\nlocal function go(label) return t.label .. label end\nreturn go('x')\n",
+		"--[==[ 多行 ]==]\nlocal x = 10 local y = x * 2 return y\n",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		if _, err := parser.Parse(src); err != nil {
+			return
+		}
+		for _, preset := range []string{"light", "medium", "heavy"} {
+			o, err := NewPipeline(Presets[preset])
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := o.Obfuscate(src)
+			if _, err := parser.Parse(out); err != nil {
+				t.Fatalf("%s: output does not parse (%v):\n%s\n--- from:\n%s", preset, err, out, src)
+			}
+			// These presets encode no strings, so every literal survives
+			want, got := stringLiterals(t, src), stringLiterals(t, out)
+			if len(want) != len(got) {
+				t.Fatalf("%s: %d string literals became %d:\n%s\n--- from:\n%s", preset, len(want), len(got), out, src)
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Fatalf("%s: string %s became %s", preset, want[i], got[i])
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package obfuscator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// HotTag in a comment marks a frame-time-sensitive function: on a line of
+// its own it tags the function starting on the next line, at the end of a
+// line the function starting on that line
+const HotTag = "@hot"
+
+// hotMarker marks the tagged functions while the passes run: a comment
+// numbering the function, in front of its body
+var hotMarker = regexp.MustCompile(`--\[\[@@(\d+)\]\] ?`)
+
+// hiddenHotMarker is a marker comment removal leaves in place
+var hiddenHotMarker = regexp.MustCompile("\x00(\\d+)\x00")
+
+// hotExempt are the passes that leave the bodies of hot functions as they
+// are; renaming and minifying keep their structure
+var hotExempt = map[string]bool{
+	PassStrings: true, PassNumbers: true, PassFlow: true, PassJunk: true, PassVirtualize: true,
+}
+
+// HotFunction reports what obfuscation did to a function tagged --@hot
+type HotFunction struct {
+	Name    string   // as declared, "function" when anonymous
+	Line    int      // line of the function in the source
+	Skipped []string // passes of the pipeline that left its body out
+	Intact  bool     // its body came out with the same structure
+}
+
+// hotFunction is a tagged function with the tokens of its body
+type hotFunction struct {
+	HotFunction
+	body []parser.Token
+}
+
+// markHot puts a marker in front of the body of every function tagged
+// --@hot. Code the parser cannot read has no hot functions.
+func markHot(code string) (string, []hotFunction) {
+	if !strings.Contains(code, HotTag) {
+		return code, nil
+	}
+	chunk, err := parser.Parse(code)
+	if err != nil {
+		return code, nil
+	}
+
+	tagged := make(map[int]bool)
+	for _, tok := range chunk.Tokens {
+		if tok.Kind != parser.Comment || strings.TrimSpace(strings.TrimPrefix(tok.Value, "--")) != HotTag {
+			continue
+		}
+		lineStart := strings.LastIndex(code[:tok.Start], "\n") + 1
+		if strings.TrimSpace(code[lineStart:tok.Start]) == "" {
+			tagged[tok.Line+1] = true
+		} else {
+			tagged[tok.Line] = true
+		}
+	}
+
+	// Functions are named and placed by the statements declaring them
+	names := make(map[*parser.FunctionExpr]string)
+	starts := make(map[*parser.FunctionExpr]int)
+	declare := func(fn parser.Expr, name string, start int) {
+		if fn, ok := fn.(*parser.FunctionExpr); ok {
+			names[fn], starts[fn] = name, start
+		}
+	}
+	var hot []hotFunction
+	var edits []edit
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case *parser.FunctionStmt:
+			name := code[n.Target.Range().Start:n.Target.Range().End]
+			if n.Method != nil {
+				name += ":" + n.Method.Value
+			}
+			declare(n.Func, name, n.Start)
+		case *parser.LocalFunctionStmt:
+			declare(n.Func, n.Name.Name, n.Start)
+		case *parser.LocalStmt:
+			for i, v := range n.Values {
+				if i < len(n.Names) {
+					declare(v, n.Names[i].Name, n.Start)
+				}
+			}
+		case *parser.AssignStmt:
+			for i, v := range n.Values {
+				if i < len(n.Targets) {
+					declare(v, code[n.Targets[i].Range().Start:n.Targets[i].Range().End], n.Start)
+				}
+			}
+		case *parser.FunctionExpr:
+			start, ok := starts[n]
+			if !ok {
+				start = n.Start
+			}
+			line := lineAt(code, start)
+			if !tagged[line] && !tagged[lineAt(code, n.Start)] {
+				return true
+			}
+			name := names[n]
+			if name == "" {
+				name = "function"
+			}
+			hot = append(hot, hotFunction{HotFunction{Name: name, Line: line}, bodyTokens(chunk, n)})
+			edits = append(edits, edit{n.Body.Start, n.Body.Start, fmt.Sprintf("--[[@@%d]] ", len(hot))})
+			// Functions nested in a hot one are part of it
+			return false
+		}
+		return true
+	})
+	return applyEdits(code, 0, len(code), edits), hot
+}
+
+// checkHot reports whether the bodies of the hot functions kept their
+// structure in the obfuscated code, which still holds their markers
+func (o *Obfuscator) checkHot(code string, hot []hotFunction) []HotFunction {
+	var skipped []string
+	for _, p := range o.passes {
+		if hotExempt[p.Name] && !containsPass(skipped, p.Name) {
+			skipped = append(skipped, p.Name)
+		}
+	}
+
+	found := make(map[int][]parser.Token)
+	if chunk, err := parser.Parse(code); err == nil {
+		parser.Walk(chunk, func(n parser.Node) bool {
+			if fn, ok := n.(*parser.FunctionExpr); ok {
+				if id := hotID(code, fn); id > 0 {
+					found[id] = bodyTokens(chunk, fn)
+				}
+			}
+			return true
+		})
+	}
+
+	report := make([]HotFunction, len(hot))
+	for i, h := range hot {
+		report[i] = h.HotFunction
+		report[i].Skipped = skipped
+		report[i].Intact = sameStructure(h.body, found[i+1])
+	}
+	return report
+}
+
+// hotID returns the number of the marker in front of the body of fn, or 0
+// when it is not hot
+func hotID(code string, fn *parser.FunctionExpr) int {
+	m := hotMarker.FindStringSubmatch(code[fn.Start:fn.Body.Start])
+	if m == nil {
+		return 0
+	}
+	id, _ := strconv.Atoi(m[1])
+	return id
+}
+
+// skipHot reports whether n is a hot function, whose body a pass must
+// leave out
+func skipHot(code string, n parser.Node) bool {
+	fn, ok := n.(*parser.FunctionExpr)
+	return ok && hotID(code, fn) > 0
+}
+
+// bodyTokens returns the tokens of fn's body and its end, without comments
+func bodyTokens(chunk *parser.Chunk, fn *parser.FunctionExpr) []parser.Token {
+	var tokens []parser.Token
+	for _, tok := range chunk.Tokens {
+		if tok.Start >= fn.Body.Start && tok.End <= fn.End && tok.Kind != parser.Comment && tok.Kind != parser.EOF {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+// sameStructure reports whether two bodies match token for token, names
+// aside, since renaming keeps the structure
+func sameStructure(a, b []parser.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Kind != b[i].Kind || (a[i].Kind != parser.Name && a[i].Value != b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPass reports whether names holds name
+func containsPass(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lineAt returns the 1-based line of the byte offset pos in code
+func lineAt(code string, pos int) int {
+	return strings.Count(code[:pos], "\n") + 1
+}
@@ -0,0 +1,97 @@
+package obfuscator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hotSource = `local scale = 2
+local label = "speed"
+
+--@hot
+local function step(bodies, dt)
+	for i = 1, #bodies do
+		local body = bodies[i]
+		body.x = body.x + body.vx * dt * 60
+		if body.x > 1000 then body.tag = "edge" end
+	end
+end
+
+local physics = {}
+physics.update = function(dt) --@hot
+	return step(physics, dt * scale)
+end
+
+local function report()
+	print(label, scale, 42)
+	return "done"
+end
+
+return report()
+`
+
+func TestObfuscateReport_Hot(t *testing.T) {
+	obf, err := NewPipeline(Virtualized(Presets["max"]))
+	require.NoError(t, err)
+
+	result, report := obf.ObfuscateReport(hotSource)
+	_, err = parser.Parse(result)
+	require.NoError(t, err, result)
+
+	skipped := []string{PassFlow, PassJunk, PassStrings, PassNumbers, PassVirtualize}
+	assert.Equal(t, []HotFunction{
+		{Name: "step", Line: 5, Skipped: skipped, Intact: true},
+		{Name: "physics.update", Line: 14, Skipped: skipped, Intact: true},
+	}, report)
+
+	assert.NotContains(t, result, "--[[@@", "markers are removed")
+	assert.Contains(t, result, `"edge"`, "strings in hot functions stay")
+	assert.Contains(t, result, "* 60", "numbers in hot functions stay")
+	assert.Contains(t, result, "> 1000")
+	assert.NotContains(t, result, `"speed"`, "strings outside them are encoded")
+}
+
+func TestObfuscateReport_Untagged(t *testing.T) {
+	obf := NewObfuscator(3)
+	code := "local function f(x)\n\treturn x * 2 -- not @hot\nend\nreturn f(1)\n"
+
+	result, report := obf.ObfuscateReport(code)
+	assert.Nil(t, report)
+	assert.NotContains(t, result, "--[[@@")
+}
+
+func TestMarkHot(t *testing.T) {
+	code := "--@hot\nlocal f = function() end\nlocal g = { h = function() return 1 end } --@hot\nlocal function i() --@hot\n\tlocal j = function() end\nend\n"
+	marked, hot := markHot(code)
+
+	require.Len(t, hot, 3)
+	assert.Equal(t, HotFunction{Name: "f", Line: 2}, hot[0].HotFunction)
+	assert.Equal(t, HotFunction{Name: "function", Line: 3}, hot[1].HotFunction)
+	assert.Equal(t, HotFunction{Name: "i", Line: 4}, hot[2].HotFunction, "functions nested in hot ones are part of them")
+	assert.Contains(t, marked, "local f = function() --[[@@1]] end")
+	assert.Contains(t, marked, "function() --[[@@2]] return 1 end")
+	assert.Equal(t, strings.Count(code, "\n"), strings.Count(marked, "\n"), "lines stay")
+}
+
+func TestRemoveComments_KeepsHotMarkers(t *testing.T) {
+	obf := NewObfuscator(1)
+	marked, _ := markHot("local function f() --@hot\n\treturn 1 -- one\nend\n")
+	assert.Equal(t, "local function f() \n\t--[[@@1]] return 1 \nend", obf.removeComments(marked))
+}
+
+func TestCheckHot_Changed(t *testing.T) {
+	obf, err := NewPipeline([]Pass{{Name: PassRename}})
+	require.NoError(t, err)
+	marked, hot := markHot("local function f(a) --@hot\n\treturn a + 1\nend\n")
+
+	renamed := strings.Replace(marked, "a + 1", "b + 1", 1)
+	assert.True(t, obf.checkHot(renamed, hot)[0].Intact, "renames keep the structure")
+	changed := strings.Replace(marked, "a + 1", "a + (2 - 1)", 1)
+	assert.False(t, obf.checkHot(changed, hot)[0].Intact)
+	assert.False(t, obf.checkHot("local x =", hot)[0].Intact, "unreadable output is not intact")
+	assert.Empty(t, obf.checkHot(marked, hot)[0].Skipped)
+}
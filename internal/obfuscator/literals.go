@@ -0,0 +1,143 @@
+package obfuscator
+
+import (
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// The text passes work on code with regular expressions and byte scans.
+// String literals and comments hold arbitrary text, quotes and non-ASCII
+// characters included, so these helpers find them with the lexer and keep
+// the passes out of them. Code the lexer cannot read is handled as before.
+
+// outsideLiterals applies f to the stretches of code between string
+// literals, leaving the literals as written
+func outsideLiterals(code string, f func(string) string) string {
+	tokens, err := parser.Tokenize(code)
+	if err != nil {
+		return f(code)
+	}
+	var out strings.Builder
+	out.Grow(len(code))
+	pos := 0
+	for _, tok := range tokens {
+		if tok.Kind != parser.String {
+			continue
+		}
+		out.WriteString(f(code[pos:tok.Start]))
+		out.WriteString(tok.Value)
+		pos = tok.End
+	}
+	out.WriteString(f(code[pos:]))
+	return out.String()
+}
+
+// maskLiterals returns code with the contents of string literals and
+// comments blanked out, line breaks kept, so patterns matched against it
+// find only code, at the offsets it has in code
+func maskLiterals(code string) string {
+	tokens, err := parser.Tokenize(code)
+	if err != nil {
+		return code
+	}
+	masked := []byte(code)
+	for _, tok := range tokens {
+		if tok.Kind != parser.String && tok.Kind != parser.Comment {
+			continue
+		}
+		for i := tok.Start; i < tok.End; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+	return string(masked)
+}
+
+// stripComments removes the comments of code except hot function markers
+// and a leading #! line, then the lines left blank outside string literals.
+// ok is false when the lexer cannot read code.
+func stripComments(code string) (string, bool) {
+	tokens, err := parser.Tokenize(code)
+	if err != nil {
+		return "", false
+	}
+	var out strings.Builder
+	out.Grow(len(code))
+	pos := 0
+	for _, tok := range tokens {
+		if tok.Kind != parser.Comment || strings.HasPrefix(tok.Value, "#") || hotMarker.MatchString(tok.Value) {
+			continue
+		}
+		out.WriteString(code[pos:tok.Start])
+		// A comment between two tokens on a line still separates them
+		if tok.End < len(code) && code[tok.End] != '\n' {
+			out.WriteByte(' ')
+		}
+		pos = tok.End
+	}
+	out.WriteString(code[pos:])
+
+	stripped := outsideLiterals(out.String(), func(s string) string {
+		return blankLines.ReplaceAllString(s, "\n")
+	})
+	return strings.Trim(stripped, " \t\n"), true
+}
+
+// longBracketEnd returns the end of the long string or comment body opening
+// with a long bracket at code[i], or -1 when none opens there
+func longBracketEnd(code string, i int) int {
+	if i >= len(code) || code[i] != '[' {
+		return -1
+	}
+	j := i + 1
+	for j < len(code) && code[j] == '=' {
+		j++
+	}
+	if j >= len(code) || code[j] != '[' {
+		return -1
+	}
+	closing := "]" + strings.Repeat("=", j-i-1) + "]"
+	end := strings.Index(code[j+1:], closing)
+	if end < 0 {
+		return len(code)
+	}
+	return j + 1 + end + len(closing)
+}
+
+// interpolationEnd returns the end of the Luau interpolated string opening
+// with a backtick at code[i], calling expr with the code of each {...} in it
+func interpolationEnd(code string, i int, expr func(start, end int)) int {
+	i++
+	for i < len(code) {
+		switch code[i] {
+		case '\\':
+			i += 2
+			if i > len(code) {
+				return len(code)
+			}
+			continue
+		case '`':
+			return i + 1
+		case '{':
+			start, depth := i+1, 1
+			for i++; i < len(code) && depth > 0; i++ {
+				switch code[i] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				}
+			}
+			if depth > 0 {
+				expr(start, len(code))
+				return len(code)
+			}
+			expr(start, i-1)
+			continue
+		}
+		i++
+	}
+	return len(code)
+}
@@ -0,0 +1,63 @@
+package obfuscator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutsideLiterals(t *testing.T) {
+	upper := func(s string) string { return strings.ToUpper(s) }
+	assert.Equal(t, `LOCAL S = "héllo" .. [[wörld]] .. 'x'`, outsideLiterals(`local s = "héllo" .. [[wörld]] .. 'x'`, upper))
+	assert.Equal(t, `LOCAL S = "UNFINISHED`, outsideLiterals(`local s = "unfinished`, upper), "code the lexer cannot read goes to f whole")
+}
+
+func TestMaskLiterals(t *testing.T) {
+	code := "local a = \"local b\" -- local c\nlocal d = [[\nlocal é]]"
+	masked := maskLiterals(code)
+	require.Len(t, masked, len(code))
+	assert.Equal(t, "local a ="+strings.Repeat(" ", 21)+"\nlocal d =   \n"+strings.Repeat(" ", len("local é]]")), masked)
+}
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name, code, want string
+	}{
+		{"apostrophe before comment", "local s = \"l'été\" -- don't\nprint(s)", "local s = \"l'été\" \nprint(s)"},
+		{"dashes in strings", "local v = 'a -- b' -- c", "local v = 'a -- b'"},
+		{"comment between tokens", "local x--[[ é ]]=1", "local x =1"},
+		{"blank lines in long strings", "local m = [[\n\n  é  ]]\n\n\nprint(m)", "local m = [[\n\n  é  ]]\nprint(m)"},
+		{"shebang and hot markers", "#!/usr/bin/lua\n-- about\nlocal function f() --[[@@1]] return 1 end", "#!/usr/bin/lua\nlocal function f() --[[@@1]] return 1 end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := stripComments(tt.code)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, ok := stripComments("local s = 'unfinished")
+	assert.False(t, ok)
+}
+
+func TestObfuscate_KeepsLiterals(t *testing.T) {
+	code := "local name = \"Ana\"\n" +
+		"local note = [[\nDear name,\n\n  see  you]]\n" +
+		"local tag = `{name}  and name`\n" +
+		"print(\"your local name\", note, tag)"
+	for _, preset := range []string{"medium", "heavy"} {
+		o, err := NewPipeline(Presets[preset])
+		require.NoError(t, err)
+		out := o.Obfuscate(code)
+		renamed := o.NameMap()["name"]
+		require.NotEmpty(t, renamed)
+
+		assert.Contains(t, out, "[[\nDear name,\n\n  see  you]]", preset)
+		assert.Contains(t, out, "`{"+renamed+"}  and name`", preset)
+		assert.Contains(t, out, "\"your local name\"", preset)
+		assert.NotContains(t, o.NameMap(), "your", "names in strings are not declarations")
+	}
+}
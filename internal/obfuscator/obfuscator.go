@@ -10,10 +10,12 @@ import (
 // Obfuscator handles Lua code obfuscation
 type Obfuscator struct {
 	identifierMap map[string]string
-	level         int // 1 = basic, 2 = medium, 3 = heavy
+	level         int // 1 = basic, 2 = medium, 3 = heavy, -1 = another pipeline
+	passes        []Pass
 }
 
-// NewObfuscator creates a new obfuscator instance
+// NewObfuscator creates a new obfuscator instance running the preset of
+// level
 func NewObfuscator(level int) *Obfuscator {
 	if level < 1 {
 		level = 1
@@ -24,36 +26,128 @@ func NewObfuscator(level int) *Obfuscator {
 	return &Obfuscator{
 		identifierMap: make(map[string]string),
 		level:         level,
+		passes:        Presets[PresetNames[level]],
 	}
 }
 
+// NewPipeline creates an obfuscator running passes in order
+func NewPipeline(passes []Pass) (*Obfuscator, error) {
+	if err := Validate(passes); err != nil {
+		return nil, err
+	}
+	return &Obfuscator{
+		identifierMap: make(map[string]string),
+		level:         Level(passes),
+		passes:        append([]Pass{}, passes...),
+	}, nil
+}
+
+// WithPasses creates an obfuscator running passes that shares o's renames,
+// so an identifier gets the same name whichever of them renames it
+func (o *Obfuscator) WithPasses(passes []Pass) (*Obfuscator, error) {
+	derived, err := NewPipeline(passes)
+	if err != nil {
+		return nil, err
+	}
+	derived.identifierMap = o.identifierMap
+	return derived, nil
+}
+
+// Passes returns the passes the obfuscator runs, in order
+func (o *Obfuscator) Passes() []Pass {
+	return append([]Pass{}, o.passes...)
+}
+
+// Renames reports whether the pipeline renames identifiers
+func (o *Obfuscator) Renames() bool {
+	for _, p := range o.passes {
+		if p.Name == PassRename {
+			return true
+		}
+	}
+	return false
+}
+
 // Obfuscate applies obfuscation to Lua code
 func (o *Obfuscator) Obfuscate(code string) string {
-	result := code
-
-	switch o.level {
-	case 1:
-		// Basic obfuscation: minify and remove comments
-		result = o.removeComments(result)
-		result = o.minifyWhitespace(result)
-	case 2:
-		// Medium obfuscation: basic + rename variables
-		result = o.removeComments(result)
-		result = o.renameIdentifiers(result)
-		result = o.minifyWhitespace(result)
-	case 3:
-		// Heavy obfuscation: medium + extra aggressive minification
-		result = o.removeComments(result)
-		result = o.renameIdentifiers(result)
-		result = o.minifyWhitespace(result)
-		result = o.aggressiveMinify(result)
+	result, _ := o.ObfuscateReport(code)
+	return result
+}
+
+// ObfuscateReport applies obfuscation to Lua code and reports what it did
+// to the functions tagged --@hot. Passes that restructure code or encode
+// literals leave their bodies out, so hot loops keep their speed.
+func (o *Obfuscator) ObfuscateReport(code string) (string, []HotFunction) {
+	result, hot := markHot(code)
+
+	for _, p := range o.passes {
+		switch p.Name {
+		case PassRename:
+			// Names in comments would leak the originals
+			result = o.removeComments(result)
+			result = o.renameIdentifiers(result)
+		case PassStrings:
+			result = o.encodeStrings(result, p)
+		case PassNumbers:
+			result = o.encodeNumbers(result, p)
+		case PassFlow:
+			result = o.flattenFlow(result, p)
+		case PassJunk:
+			result = o.injectJunk(result, p)
+		case PassAntiTamper:
+			result = o.injectAntiTamper(result, p)
+		case PassVirtualize:
+			result = o.virtualize(result, p)
+		case PassMinify:
+			result = o.removeComments(result)
+			result = o.minifyWhitespace(result)
+			if p.SingleLine {
+				result = o.aggressiveMinify(result)
+			}
+		}
 	}
 
-	return result
+	if len(hot) == 0 {
+		return result, nil
+	}
+	report := o.checkHot(result, hot)
+	return hotMarker.ReplaceAllString(result, ""), report
+}
+
+// NameMap returns a copy of the original -> obfuscated identifier mapping
+// built up by every Obfuscate call so far
+func (o *Obfuscator) NameMap() map[string]string {
+	names := make(map[string]string, len(o.identifierMap))
+	for original, renamed := range o.identifierMap {
+		names[original] = renamed
+	}
+	return names
 }
 
+// Whitespace patterns of the minify pass, applied outside string literals
+var (
+	multiSpace   = regexp.MustCompile(`[ \t]+`)
+	blankLines   = regexp.MustCompile(`[ \t]*\n(?:[ \t]*\n)+`)
+	emptyLines   = regexp.MustCompile(`\n\s*\n`)
+	blockOpeners = regexp.MustCompile(`\b(then|do|repeat)\s+`)
+	blockClosers = regexp.MustCompile(`\s+(end|until|else)\b`)
+	spaceRuns    = regexp.MustCompile(`\s{2,}`)
+)
+
 // removeComments removes Lua comments from code
 func (o *Obfuscator) removeComments(code string) string {
+	if stripped, ok := stripComments(code); ok {
+		return stripped
+	}
+	return o.removeCommentsByLine(code)
+}
+
+// removeCommentsByLine removes comments line by line, guessing from quotes
+// whether -- starts one, for code the lexer cannot read
+func (o *Obfuscator) removeCommentsByLine(code string) string {
+	// Markers of hot functions stay
+	code = hotMarker.ReplaceAllString(code, "\x00$1\x00")
+
 	// Remove multi-line comments --[[ ... ]]
 	multiLineComment := regexp.MustCompile(`--\[\[[\s\S]*?\]\]`)
 	code = multiLineComment.ReplaceAllString(code, "")
@@ -74,38 +168,47 @@ func (o *Obfuscator) removeComments(code string) string {
 			result = append(result, line)
 		}
 	}
-	return strings.Join(result, "\n")
+	return hiddenHotMarker.ReplaceAllString(strings.Join(result, "\n"), "--[[@@$1]] ")
 }
 
 // minifyWhitespace removes unnecessary whitespace
 func (o *Obfuscator) minifyWhitespace(code string) string {
-	// Replace multiple spaces with single space
-	multiSpace := regexp.MustCompile(`[ \t]+`)
-	code = multiSpace.ReplaceAllString(code, " ")
-
-	// Skip operator minification to avoid breaking syntax
-	// It's safer to preserve spaces around operators
+	code = outsideLiterals(code, func(code string) string {
+		// Replace multiple spaces with single space
+		code = multiSpace.ReplaceAllString(code, " ")
 
-	// Remove empty lines
-	code = regexp.MustCompile(`\n\s*\n`).ReplaceAllString(code, "\n")
+		// Skip operator minification to avoid breaking syntax
+		// It's safer to preserve spaces around operators
 
+		// Remove empty lines
+		return emptyLines.ReplaceAllString(code, "\n")
+	})
 	return strings.TrimSpace(code)
 }
 
 // aggressiveMinify applies aggressive minification
 func (o *Obfuscator) aggressiveMinify(code string) string {
-	// Remove all newlines and replace with spaces (single line output)
-	code = strings.ReplaceAll(code, "\n", " ")
+	// A leading #! line ends at its line break, so it keeps it
+	if strings.HasPrefix(code, "#") {
+		if end := strings.IndexByte(code, '\n'); end >= 0 {
+			return code[:end+1] + o.aggressiveMinify(code[end+1:])
+		}
+		return code
+	}
 
-	// Remove spaces after specific keywords that don't need them
-	code = regexp.MustCompile(`\b(then|do|repeat)\s+`).ReplaceAllString(code, "$1 ")
+	code = outsideLiterals(code, func(code string) string {
+		// Remove all newlines and replace with spaces (single line output)
+		code = strings.ReplaceAll(code, "\n", " ")
 
-	// Remove spaces before specific keywords
-	code = regexp.MustCompile(`\s+(end|until|else)\b`).ReplaceAllString(code, " $1")
+		// Remove spaces after specific keywords that don't need them
+		code = blockOpeners.ReplaceAllString(code, "$1 ")
 
-	// Collapse multiple spaces
-	code = regexp.MustCompile(`\s{2,}`).ReplaceAllString(code, " ")
+		// Remove spaces before specific keywords
+		code = blockClosers.ReplaceAllString(code, " $1")
 
+		// Collapse multiple spaces
+		return spaceRuns.ReplaceAllString(code, " ")
+	})
 	return strings.TrimSpace(code)
 }
 
@@ -115,13 +218,16 @@ func (o *Obfuscator) renameIdentifiers(code string) string {
 	localVarRegex := regexp.MustCompile(`\blocal\s+([a-zA-Z_][a-zA-Z0-9_]*)\b`)
 	localFuncRegex := regexp.MustCompile(`\blocal\s+function\s+([a-zA-Z_][a-zA-Z0-9_]*)\b`)
 
-	matches := localVarRegex.FindAllStringSubmatch(code, -1)
-	funcMatches := localFuncRegex.FindAllStringSubmatch(code, -1)
+	// Declarations are looked for in code only, not in the text of strings
+	// and comments
+	masked := maskLiterals(code)
+	matches := localVarRegex.FindAllStringSubmatch(masked, -1)
+	funcMatches := localFuncRegex.FindAllStringSubmatch(masked, -1)
 
 	// Also capture identifiers from multi-line local declarations without assignment
 	// Pattern: lines starting with "local" followed by just identifier (no =, no function)
 	multiLineLocalRegex := regexp.MustCompile(`(?m)^\s*local\s+([a-zA-Z_][a-zA-Z0-9_]*)\s*$`)
-	multiLineMatches := multiLineLocalRegex.FindAllStringSubmatch(code, -1)
+	multiLineMatches := multiLineLocalRegex.FindAllStringSubmatch(masked, -1)
 
 	// Reserved Lua keywords and Roblox globals that should not be renamed
 	reserved := map[string]bool{
@@ -156,10 +262,11 @@ func (o *Obfuscator) renameIdentifiers(code string) string {
 		"isfolder": true, "isfile": true, "listfiles": true, "delfile": true, "delfolder": true,
 	}
 
-	// Create mapping for identifiers
+	// Create mapping for identifiers. Names generated by earlier passes,
+	// such as a string decoder's, are obscure already.
 	for _, match := range matches {
 		identifier := match[1]
-		if !reserved[identifier] && identifier != "function" && o.identifierMap[identifier] == "" {
+		if !reserved[identifier] && identifier != "function" && !generatedName.MatchString(identifier) && o.identifierMap[identifier] == "" {
 			o.identifierMap[identifier] = o.generateObfuscatedName()
 		}
 	}
@@ -167,7 +274,7 @@ func (o *Obfuscator) renameIdentifiers(code string) string {
 	// Add function names to mapping
 	for _, match := range funcMatches {
 		identifier := match[1]
-		if !reserved[identifier] && o.identifierMap[identifier] == "" {
+		if !reserved[identifier] && !generatedName.MatchString(identifier) && o.identifierMap[identifier] == "" {
 			o.identifierMap[identifier] = o.generateObfuscatedName()
 		}
 	}
@@ -175,7 +282,7 @@ func (o *Obfuscator) renameIdentifiers(code string) string {
 	// Add multi-line local declarations (e.g., "local Core" on its own line)
 	for _, match := range multiLineMatches {
 		identifier := match[1]
-		if !reserved[identifier] && o.identifierMap[identifier] == "" {
+		if !reserved[identifier] && !generatedName.MatchString(identifier) && o.identifierMap[identifier] == "" {
 			o.identifierMap[identifier] = o.generateObfuscatedName()
 		}
 	}
@@ -193,6 +300,25 @@ func (o *Obfuscator) replaceOutsideStrings(code string, replacements map[string]
 	i := 0
 
 	for i < len(code) {
+		// Long strings are copied as they are, like quoted ones
+		if end := longBracketEnd(code, i); end >= 0 {
+			result.WriteString(code[i:end])
+			i = end
+			continue
+		}
+		// Interpolated strings are too, but for the expressions in braces
+		if code[i] == '`' {
+			pos := i
+			end := interpolationEnd(code, i, func(start, end int) {
+				result.WriteString(code[pos:start])
+				result.WriteString(o.replaceOutsideStrings(code[start:end], replacements))
+				pos = end
+			})
+			result.WriteString(code[pos:end])
+			i = end
+			continue
+		}
+
 		// Check if we're at the start of a string
 		if code[i] == '"' || code[i] == '\'' {
 			quote := code[i]
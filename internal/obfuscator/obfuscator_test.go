@@ -162,6 +162,11 @@ return myVariable + anotherVar
 	assert.NotContains(t, result, "myVariable")
 	assert.NotContains(t, result, "anotherVar")
 	assert.Contains(t, result, "_0x") // Obfuscated names start with _0x
+
+	names := obf.NameMap()
+	assert.Contains(t, result, names["myVariable"], "the name map should match the output")
+	names["myVariable"] = "changed"
+	assert.NotEqual(t, "changed", obf.NameMap()["myVariable"], "NameMap should return a copy")
 }
 
 func TestObfuscateHeavy(t *testing.T) {
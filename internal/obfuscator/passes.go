@@ -0,0 +1,364 @@
+package obfuscator
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// keptCallees take string arguments the bundler rewrites after obfuscation,
+// such as require paths and HttpGet URLs, so the strings pass leaves them
+var keptCallees = map[string]bool{
+	"require": true, "HttpGet": true, "HttpGetAsync": true, "get": true,
+	"include": true, "AddCSLuaFile": true, "loadAPI": true,
+}
+
+// decimalInteger matches the number literals the numbers pass rewrites
+var decimalInteger = regexp.MustCompile(`^[0-9]+$`)
+
+// maxEncodedNumber bounds the literals the numbers pass rewrites, keeping
+// the arithmetic exact in every number representation
+const maxEncodedNumber = 1 << 24
+
+// tamperChecked are the builtins the antitamper pass expects to be native
+const tamperChecked = "tostring, type, pcall, error, setmetatable, rawget, string.char, string.byte, table.concat"
+
+// decoyTemplates are the decoy functions of the junk pass: %[1]s is the
+// function's name, %[2]s to %[4]s are its parameters and locals, %[5]d and
+// %[6]d constants
+var decoyTemplates = []string{
+	"local %[1]s = function(%[2]s, %[3]s) local %[4]s = (%[2]s or 0) * %[5]d + %[6]d if %[4]s > %[3]s then return %[4]s %% %[6]d end return %[3]s end",
+	"local %[1]s = function(%[2]s) local %[4]s = {} for %[3]s = 1, %[5]d do %[4]s[%[3]s] = %[2]s .. %[3]s end return %[4]s[%[6]d %% %[5]d + 1] end",
+	"local %[1]s = function(%[2]s, %[3]s) if type(%[2]s) ~= \"table\" then return nil end local %[4]s = %[2]s[%[3]s] or %[5]d return %[4]s + %[6]d end",
+}
+
+// edit replaces the code between start and end with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits returns code[start:end] with the edits inside it applied. An
+// edit inside a wider one is already part of the wider one's text.
+func applyEdits(code string, start, end int, edits []edit) string {
+	var inside []edit
+	for _, e := range edits {
+		if e.start >= start && e.end <= end {
+			inside = append(inside, e)
+		}
+	}
+	sort.Slice(inside, func(i, j int) bool {
+		if inside[i].start != inside[j].start {
+			return inside[i].start < inside[j].start
+		}
+		return inside[i].end > inside[j].end
+	})
+
+	var out strings.Builder
+	pos := start
+	for _, e := range inside {
+		if e.start < pos {
+			continue
+		}
+		out.WriteString(code[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.WriteString(code[pos:end])
+	return out.String()
+}
+
+// encodeStrings replaces string literals with encoded ones: escape
+// sequences, or calls of a decoder prepended to the chunk. Strings in hot
+// functions stay, and code the parser cannot read is left as it is.
+func (o *Obfuscator) encodeStrings(code string, p Pass) string {
+	chunk, err := parser.Parse(code)
+	if err != nil {
+		return code
+	}
+
+	kept := make(map[parser.Span]bool)
+	// f"x" passes the literal as the argument list, so a call replacing it
+	// needs parentheses
+	bare := make(map[parser.Span]bool)
+	parser.Walk(chunk, func(n parser.Node) bool {
+		var name string
+		var args []parser.Expr
+		var fnEnd int
+		switch n := n.(type) {
+		case *parser.CallExpr:
+			args, fnEnd = n.Args, n.Fn.Range().End
+			switch fn := n.Fn.(type) {
+			case *parser.Ident:
+				name = fn.Name
+			case *parser.FieldExpr:
+				name = fn.Name.Value
+			}
+		case *parser.MethodCallExpr:
+			name, args, fnEnd = n.Name.Value, n.Args, n.Name.End
+		default:
+			return true
+		}
+		for _, arg := range args {
+			if keptCallees[name] {
+				kept[arg.Range()] = true
+			}
+		}
+		if len(args) == 1 && !strings.HasPrefix(strings.TrimSpace(code[fnEnd:args[0].Range().Start]), "(") {
+			bare[args[0].Range()] = true
+		}
+		return true
+	})
+
+	decoder := ""
+	if p.Method != StringsEscape {
+		decoder = o.generateObfuscatedName()
+	}
+	var edits []edit
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if skipHot(code, n) {
+			return false
+		}
+		str, ok := n.(*parser.StringExpr)
+		// Interpolated strings hold code
+		if !ok || kept[str.Span] || strings.HasPrefix(str.Token.Value, "`") {
+			return true
+		}
+		value, ok := parser.StringValue(str.Token)
+		if !ok || value == "" {
+			return true
+		}
+		text := escapeBytes(value)
+		if decoder != "" {
+			// Encoded text that reads as words invites guessing
+			key, encoded := 0, ""
+			for attempt := 0; attempt < 16 && (attempt == 0 || readable(encoded)); attempt++ {
+				key = randomInt(255) + 1
+				encoded = shiftBytes(value, key)
+			}
+			text = fmt.Sprintf("%s(%s, %d)", decoder, escapeBytes(encoded), key)
+			if bare[str.Span] {
+				text = "(" + text + ")"
+			}
+		}
+		edits = append(edits, edit{str.Start, str.End, text})
+		return true
+	})
+	if len(edits) == 0 {
+		return code
+	}
+
+	result := applyEdits(code, 0, len(code), edits)
+	if decoder != "" {
+		// On the first line, so line numbers stay the same
+		s, k, t, i := o.generateObfuscatedName(), o.generateObfuscatedName(), o.generateObfuscatedName(), o.generateObfuscatedName()
+		result = fmt.Sprintf("local %[1]s = function(%[2]s, %[3]s) local %[4]s = {} for %[5]s = 1, #%[2]s do %[4]s[%[5]s] = string.char((string.byte(%[2]s, %[5]s) - %[3]s * %[5]s) %% 256) end return table.concat(%[4]s) end ",
+			decoder, s, k, t, i) + result
+	}
+	return result
+}
+
+// encodeNumbers replaces decimal integer literals with arithmetic computing
+// them, outside hot functions. Code the parser cannot read is left as it is.
+func (o *Obfuscator) encodeNumbers(code string, p Pass) string {
+	chunk, err := parser.Parse(code)
+	if err != nil {
+		return code
+	}
+	depth := p.Depth
+	if depth == 0 {
+		depth = 1
+	}
+
+	var edits []edit
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if skipHot(code, n) {
+			return false
+		}
+		num, ok := n.(*parser.NumberExpr)
+		if !ok || !decimalInteger.MatchString(num.Value) {
+			return true
+		}
+		if value, err := strconv.Atoi(num.Value); err == nil && value <= maxEncodedNumber {
+			edits = append(edits, edit{num.Start, num.End, arithmetic(value, depth)})
+		}
+		return true
+	})
+	return applyEdits(code, 0, len(code), edits)
+}
+
+// arithmetic returns a parenthesized expression computing n, nested depth
+// times
+func arithmetic(n, depth int) string {
+	if depth == 0 {
+		return strconv.Itoa(n)
+	}
+	a := randomInt(1000) + 1
+	switch randomInt(3) {
+	case 0:
+		if n >= a {
+			return fmt.Sprintf("(%s + %d)", arithmetic(n-a, depth-1), a)
+		}
+		return fmt.Sprintf("(%d - %s)", a, arithmetic(a-n, depth-1))
+	case 1:
+		return fmt.Sprintf("(%s - %d)", arithmetic(n+a, depth-1), a)
+	default:
+		m := randomInt(15) + 2
+		return fmt.Sprintf("(%s * %d + %d)", arithmetic(n/m, depth-1), m, n%m)
+	}
+}
+
+// injectJunk adds inert decoys: functions that are never called, pools of
+// strings nothing reads, and branches that never run. Branches go at the
+// start of function bodies, the rest on the first line, so line numbers
+// stay the same. With a budget, decoys are added while they fit within that
+// percentage of the code's size; without one, count decoys are. Hot
+// functions get no branches. Where the parser cannot read the code,
+// branches go on the first line too.
+func (o *Obfuscator) injectJunk(code string, p Pass) string {
+	count := p.Count
+	if count == 0 {
+		count = 2
+	}
+	budget := -1
+	if p.Budget > 0 {
+		count, budget = -1, len(code)*p.Budget/100
+	}
+
+	var bodies []int
+	if chunk, err := parser.Parse(code); err == nil {
+		parser.Walk(chunk, func(n parser.Node) bool {
+			if skipHot(code, n) {
+				return false
+			}
+			if fn, ok := n.(*parser.FunctionExpr); ok && len(fn.Body.Stmts) > 0 {
+				bodies = append(bodies, fn.Body.Stmts[0].Range().Start)
+			}
+			return true
+		})
+	}
+
+	var first strings.Builder
+	var edits []edit
+	// Under a budget, a decoy that does not fit is skipped, and several
+	// skipped in a row end the pass
+	for added, misses := 0, 0; count != 0 && misses < 8; {
+		kind := randomInt(3)
+		var decoy string
+		switch kind {
+		case 0:
+			decoy = o.decoyFunction()
+		case 1:
+			decoy = o.stringPool()
+		default:
+			decoy = o.fakeBranch()
+		}
+		// A statement starting with ( would otherwise call the decoy
+		decoy += "; "
+		if budget < 0 {
+			count--
+		} else if added+len(decoy) > budget {
+			misses++
+			continue
+		} else {
+			added, misses = added+len(decoy), 0
+		}
+		if kind == 2 && len(bodies) > 0 {
+			at := bodies[randomInt(len(bodies))]
+			edits = append(edits, edit{at, at, decoy})
+			continue
+		}
+		first.WriteString(decoy)
+	}
+	return first.String() + applyEdits(code, 0, len(code), edits)
+}
+
+// decoyFunction returns a local function that is never called
+func (o *Obfuscator) decoyFunction() string {
+	template := decoyTemplates[randomInt(len(decoyTemplates))]
+	return fmt.Sprintf(template, o.generateObfuscatedName(), o.generateObfuscatedName(), o.generateObfuscatedName(),
+		o.generateObfuscatedName(), randomInt(97)+3, randomInt(997)+3)
+}
+
+// stringPool returns a local table of random strings, spelled like the
+// strings pass spells its encoded ones
+func (o *Obfuscator) stringPool() string {
+	entries := make([]string, randomInt(6)+3)
+	for i := range entries {
+		value := make([]byte, randomInt(19)+6)
+		for j := range value {
+			value[j] = byte(randomInt(256))
+		}
+		entries[i] = escapeBytes(string(value))
+	}
+	return fmt.Sprintf("local %s = {%s}", o.generateObfuscatedName(), strings.Join(entries, ", "))
+}
+
+// fakeBranch returns an if statement whose condition is always false,
+// though not literally: no square is 2 or 3 modulo 4, and the product of
+// consecutive integers is even
+func (o *Obfuscator) fakeBranch() string {
+	a := randomInt(9000) + 1000
+	condition := fmt.Sprintf("(%d * %d) %% 4 == %d", a, a, randomInt(2)+2)
+	if randomInt(2) == 0 {
+		condition = fmt.Sprintf("(%d * %d) %% 2 == 1", a, a+1)
+	}
+	v := o.generateObfuscatedName()
+	body := fmt.Sprintf("local %[1]s = {%[2]s, %[3]d} %[1]s[#%[1]s + 1] = tostring(%[3]d)", v, escapeBytes(o.generateObfuscatedName()), randomInt(997)+3)
+	if randomInt(2) == 0 {
+		body = fmt.Sprintf("local %[1]s = string.rep(%[2]s, %[3]d) %[1]s = %[1]s .. %[2]s", v, escapeBytes(o.generateObfuscatedName()), randomInt(7)+2)
+	}
+	return fmt.Sprintf("if %s then %s end", condition, body)
+}
+
+// injectAntiTamper prepends a check raising an error when builtins the
+// code relies on have been replaced by Lua functions, as hooks do. Where
+// the runtime cannot tell native functions apart, the check passes.
+func (o *Obfuscator) injectAntiTamper(code string, p Pass) string {
+	message := p.Message
+	if message == "" {
+		message = "integrity check failed"
+	}
+	native, f := o.generateObfuscatedName(), o.generateObfuscatedName()
+	check := fmt.Sprintf("do local %[1]s = function(%[2]s) if type(%[2]s) ~= \"function\" then return false end "+
+		"if iscclosure then return iscclosure(%[2]s) end "+
+		"if debug and debug.info then return debug.info(%[2]s, \"s\") == \"[C]\" end "+
+		"if debug and debug.getinfo then return debug.getinfo(%[2]s, \"S\").what == \"C\" end return true end "+
+		"for _, %[2]s in ipairs({%[3]s}) do if not %[1]s(%[2]s) then error(%[4]s, 0) end end end ",
+		native, f, tamperChecked, escapeBytes(message))
+	return check + code
+}
+
+// escapeBytes returns a Lua string literal spelling every byte of s as a
+// decimal escape
+func escapeBytes(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		fmt.Fprintf(&out, "\\%03d", s[i])
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// shiftBytes encodes s for the strings pass decoder: byte i (from 1) is
+// shifted by key * i
+func shiftBytes(s string, key int) string {
+	out := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		out[i] = byte((int(s[i]) + key*(i+1)) % 256)
+	}
+	return string(out)
+}
+
+// randomInt returns a random number from 0 to n-1
+func randomInt(n int) int {
+	v, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	return int(v.Int64())
+}
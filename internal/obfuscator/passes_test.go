@@ -0,0 +1,265 @@
+package obfuscator
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decoderCall matches a call of the strings pass decoder
+var decoderCall = regexp.MustCompile(`_0x[0-9a-f]{6}\("((?:\\[0-9]{3})*)", ([0-9]+)\)`)
+
+// decodeCall undoes the strings pass cipher for a decoderCall match
+func decodeCall(t *testing.T, m []string) string {
+	key, err := strconv.Atoi(m[2])
+	require.NoError(t, err)
+	var out []byte
+	for i, esc := range strings.Split(m[1], `\`)[1:] {
+		b, err := strconv.Atoi(esc)
+		require.NoError(t, err)
+		out = append(out, byte(((b-key*(i+1))%256+256)%256))
+	}
+	return string(out)
+}
+
+// evaluate computes a constant arithmetic expression
+func evaluate(t *testing.T, e parser.Expr) int {
+	switch e := e.(type) {
+	case *parser.NumberExpr:
+		n, err := strconv.Atoi(e.Value)
+		require.NoError(t, err)
+		return n
+	case *parser.ParenExpr:
+		return evaluate(t, e.X)
+	case *parser.BinaryExpr:
+		x, y := evaluate(t, e.X), evaluate(t, e.Y)
+		switch e.Op {
+		case "+":
+			return x + y
+		case "-":
+			return x - y
+		case "*":
+			return x * y
+		}
+	}
+	t.Fatalf("not an arithmetic expression: %T", e)
+	return 0
+}
+
+func TestEncodeStrings_Escape(t *testing.T) {
+	obf := NewObfuscator(1)
+	code := "local util = require(\"util\")\n" +
+		"local lib = loadstring(game:HttpGet(\"https://example.com/lib.lua\"))()\n" +
+		"print(\"hi\", 'a\\n', [[long]], `{util}`, \"\")\n"
+
+	out := obf.encodeStrings(code, Pass{Name: PassStrings, Method: StringsEscape})
+	assert.Contains(t, out, `require("util")`, "require paths are rewritten by the bundler")
+	assert.Contains(t, out, `HttpGet("https://example.com/lib.lua")`)
+	assert.Contains(t, out, `print("\104\105", "\097\010", "\108\111\110\103", `+"`{util}`"+`, "")`)
+	assert.Equal(t, strings.Count(code, "\n"), strings.Count(out, "\n"), "line numbers stay the same")
+}
+
+func TestEncodeStrings_Cipher(t *testing.T) {
+	obf := NewObfuscator(1)
+	out := obf.encodeStrings("local name = \"Players\"\nwarn\"careful\"\n", Pass{Name: PassStrings})
+
+	_, err := parser.Parse(out)
+	require.NoError(t, err, out)
+	assert.NotContains(t, out, "Players")
+	assert.Regexp(t, `^local _0x[0-9a-f]{6} = function\(`, out, "the decoder comes first")
+	assert.Equal(t, 2, strings.Count(out, "\n"), "the decoder shares the first line")
+	assert.Regexp(t, `warn\(_0x[0-9a-f]{6}\("[^"]*", [0-9]+\)\)`, out, "a call replacing f\"x\" needs parentheses")
+
+	var decoded []string
+	for _, m := range decoderCall.FindAllStringSubmatch(out, -1) {
+		decoded = append(decoded, decodeCall(t, m))
+	}
+	assert.Equal(t, []string{"Players", "careful"}, decoded)
+
+	assert.Equal(t, "return 1", obf.encodeStrings("return 1", Pass{Name: PassStrings}), "no strings, no decoder")
+	assert.Equal(t, "print('", obf.encodeStrings("print('", Pass{Name: PassStrings}), "unparsable code is left alone")
+}
+
+func TestEncodeNumbers(t *testing.T) {
+	obf := NewObfuscator(1)
+	code := "local t = {0, 7, 42, 65535}\nreturn t[2] + 0x10 + 1.5 + 99999999\n"
+
+	for depth := 1; depth <= 3; depth++ {
+		out := obf.encodeNumbers(code, Pass{Name: PassNumbers, Depth: depth})
+		chunk, err := parser.Parse(out)
+		require.NoError(t, err, out)
+		assert.Contains(t, out, "0x10")
+		assert.Contains(t, out, "1.5")
+		assert.Contains(t, out, "99999999", "numbers beyond exact arithmetic stay")
+
+		table := chunk.Block.Stmts[0].(*parser.LocalStmt).Values[0].(*parser.TableExpr)
+		var values []int
+		for _, field := range table.Fields {
+			values = append(values, evaluate(t, field.Value))
+		}
+		assert.Equal(t, []int{0, 7, 42, 65535}, values)
+	}
+}
+
+func TestInjectJunk(t *testing.T) {
+	obf := NewObfuscator(1)
+	out := obf.injectJunk("return 1\n", Pass{Name: PassJunk, Count: 3})
+
+	chunk, err := parser.Parse(out)
+	require.NoError(t, err, out)
+	assert.Len(t, chunk.Block.Stmts, 4)
+	assert.True(t, strings.HasSuffix(out, " return 1\n"))
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+}
+
+func TestInjectJunk_Budget(t *testing.T) {
+	obf := NewObfuscator(1)
+	code := "local function area(w, h)\n    return w * h\nend\n" + strings.Repeat("print(area(2, 3))\n", 40)
+
+	out := obf.injectJunk(code, Pass{Name: PassJunk, Budget: 50})
+	_, err := parser.Parse(out)
+	require.NoError(t, err, out)
+	added := len(out) - len(code)
+	assert.Greater(t, added, 0)
+	assert.LessOrEqual(t, added, len(code)/2, "decoys stay within the budget")
+	assert.Equal(t, strings.Count(code, "\n"), strings.Count(out, "\n"), "line numbers stay the same")
+	assert.Contains(t, out, "return w * h\nend\n")
+
+	assert.Equal(t, code, obf.injectJunk(code, Pass{Name: PassJunk, Budget: 1}), "no decoy fits in 1%")
+}
+
+func TestFakeBranch(t *testing.T) {
+	obf := NewObfuscator(1)
+	for i := 0; i < 50; i++ {
+		branch := obf.fakeBranch()
+		chunk, err := parser.Parse(branch)
+		require.NoError(t, err, branch)
+
+		cond := chunk.Block.Stmts[0].(*parser.IfStmt).Conds[0].(*parser.BinaryExpr)
+		product := cond.X.(*parser.BinaryExpr)
+		assert.NotEqual(t, evaluate(t, product.X)%evaluate(t, product.Y), evaluate(t, cond.Y), branch)
+	}
+}
+
+func TestInjectJunk_Kinds(t *testing.T) {
+	obf := NewObfuscator(1)
+	code := "local function f(x)\n    return x\nend\nreturn (f)(1)\n"
+
+	kinds := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		out := obf.injectJunk(code, Pass{Name: PassJunk, Count: 3})
+		chunk, err := parser.Parse(out)
+		require.NoError(t, err, out)
+		assert.True(t, strings.HasSuffix(out, "return (f)(1)\n"), "a statement starting with ( does not call a decoy")
+
+		fn := chunk.Block.Stmts[len(chunk.Block.Stmts)-2].(*parser.LocalFunctionStmt).Func
+		for _, stmt := range append(chunk.Block.Stmts, fn.Body.Stmts...) {
+			switch stmt := stmt.(type) {
+			case *parser.IfStmt:
+				kinds["branch"] = true
+			case *parser.LocalStmt:
+				switch stmt.Values[0].(type) {
+				case *parser.TableExpr:
+					kinds["pool"] = true
+				case *parser.FunctionExpr:
+					kinds["function"] = true
+				}
+			}
+		}
+	}
+	assert.Len(t, kinds, 3, "decoy functions, string pools and fake branches")
+}
+
+func TestInjectAntiTamper(t *testing.T) {
+	obf := NewObfuscator(1)
+	out := obf.injectAntiTamper("return 1\n", Pass{Name: PassAntiTamper, Message: "no"})
+
+	_, err := parser.Parse(out)
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "iscclosure")
+	assert.Contains(t, out, `error("\110\111", 0)`)
+	assert.Contains(t, out, "string.char, string.byte")
+	assert.True(t, strings.HasSuffix(out, " return 1\n"))
+}
+
+func TestFlattenFlow(t *testing.T) {
+	obf := NewObfuscator(1)
+	code := `local function sum(list)
+    local total = 0
+    for _, v in ipairs(list) do
+        if v < 0 then break end
+        total = total + v
+    end
+    local function double(x) return x * 2 end
+    return double(total)
+end
+return sum
+`
+	out := obf.flattenFlow(code, Pass{Name: PassFlow})
+	chunk, err := parser.Parse(out)
+	require.NoError(t, err, out)
+
+	var branches []int
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if loop, ok := n.(*parser.WhileStmt); ok {
+			branches = append(branches, dispatcherBranches(loop))
+		}
+		return true
+	})
+	assert.Equal(t, []int{4}, branches, "only sum's body is long enough; a break in a nested loop stays in it")
+	assert.Contains(t, out, "local total\n")
+	assert.Contains(t, out, "local double\n")
+	assert.Contains(t, out, "double = function(x) return x * 2 end")
+	assert.Contains(t, out, "total = 0\n")
+	assert.Contains(t, out, "\nreturn double(total)\n", "the return ends its branch")
+
+	report, err := Analyze(out)
+	require.NoError(t, err)
+	assert.True(t, report.Protections[4].Active, "the report sees the dispatcher")
+}
+
+func TestFlattenFlow_KeepsUnsafeBodies(t *testing.T) {
+	obf := NewObfuscator(1)
+	for name, code := range map[string]string{
+		"name used before its local": "print(x)\nlocal x = 1\nprint(x)\n",
+		"value naming the local":     "local print = print\nprint(1)\nprint(2)\n",
+		"local declared twice":       "local a = 1\nlocal a = a + 1\nprint(a)\n",
+		"attributed local":           "local a <const> = 1\nprint(a)\nprint(a)\n",
+		"annotated local":            "local a: number = 1\nprint(a)\nprint(a)\n",
+		"goto":                       "goto skip\nprint(1)\n::skip::\nprint(2)\n",
+		"too short":                  "print(1)\nprint(2)\n",
+	} {
+		assert.Equal(t, code, obf.flattenFlow(code, Pass{Name: PassFlow}), name)
+	}
+}
+
+func TestMaxPreset(t *testing.T) {
+	obf, err := NewPipeline(Presets["max"])
+	require.NoError(t, err)
+	code := `local Settings = {titles = {"Novice adventurer", "Seasoned explorer", "Legendary champion"}}
+function Settings.describe(name, level)
+    local label = "Player " .. name
+    local bonus = level * 10 + 5
+    print(label, bonus)
+    return label
+end
+return Settings
+`
+	out := obf.Obfuscate(code)
+	_, err = parser.Parse(out)
+	require.NoError(t, err, out)
+	assert.NotContains(t, out, "Player")
+	assert.NotContains(t, out, "explorer")
+	assert.NotContains(t, out, "Settings")
+
+	report, err := Analyze(out)
+	require.NoError(t, err)
+	for _, p := range report.Protections {
+		assert.True(t, p.Active, p.Name)
+	}
+}
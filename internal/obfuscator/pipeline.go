@@ -0,0 +1,190 @@
+package obfuscator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Obfuscation passes, run in the order a pipeline lists them
+const (
+	PassRename     = "rename"     // renames locals and local functions
+	PassStrings    = "strings"    // encodes string literals
+	PassNumbers    = "numbers"    // replaces integer literals with arithmetic
+	PassFlow       = "flow"       // flattens function bodies into state machine dispatchers
+	PassJunk       = "junk"       // adds decoy code that never runs
+	PassAntiTamper = "antitamper" // fails when builtins are hooked
+	PassMinify     = "minify"     // removes comments and collapses whitespace
+	PassVirtualize = "virtualize" // compiles to instructions run by a generated interpreter
+)
+
+// PassNames lists the passes a pipeline can use
+var PassNames = []string{PassRename, PassStrings, PassNumbers, PassFlow, PassJunk, PassAntiTamper, PassMinify, PassVirtualize}
+
+// String encoding methods of the strings pass
+const (
+	StringsCipher = "cipher" // bytes shifted by a key, decoded by a helper at runtime
+	StringsEscape = "escape" // decimal escape sequences, decoded by the Lua lexer
+)
+
+// Pass is a step of an obfuscation pipeline with its options. Options of
+// other passes must be left unset.
+type Pass struct {
+	Name          string `json:"pass"`
+	SingleLine    bool   `json:"singleLine,omitempty"`    // minify: put the whole chunk on one line
+	Method        string `json:"method,omitempty"`        // strings: StringsCipher (default) or StringsEscape
+	Depth         int    `json:"depth,omitempty"`         // numbers: arithmetic nesting per literal, 1 (default) to 3
+	MinStatements int    `json:"minStatements,omitempty"` // flow: fewest statements of a flattened body, 3 by default
+	Count         int    `json:"count,omitempty"`         // junk: decoys per chunk, 2 by default
+	Budget        int    `json:"budget,omitempty"`        // junk: decoys up to this percentage of the chunk's size, instead of a count
+	Message       string `json:"message,omitempty"`       // antitamper: error raised on tampering
+}
+
+// Presets are the named pipelines. The integer levels 0 to 3 are aliases
+// for none, light, medium and heavy.
+var Presets = map[string][]Pass{
+	"none":   {},
+	"light":  {{Name: PassMinify}},
+	"medium": {{Name: PassRename}, {Name: PassMinify}},
+	"heavy":  {{Name: PassRename}, {Name: PassMinify, SingleLine: true}},
+	"max": {
+		{Name: PassFlow}, {Name: PassAntiTamper}, {Name: PassJunk}, {Name: PassStrings},
+		{Name: PassNumbers}, {Name: PassRename}, {Name: PassMinify, SingleLine: true},
+	},
+}
+
+// PresetNames lists the presets from weakest to strongest; the first four
+// are the integer levels
+var PresetNames = []string{"none", "light", "medium", "heavy", "max"}
+
+// ParsePreset returns the passes of a preset, given by name or integer level
+func ParsePreset(name string) ([]Pass, error) {
+	if level, err := strconv.Atoi(name); err == nil {
+		if level < 0 || level > 3 {
+			return nil, fmt.Errorf("obfuscation level %d out of range (0-3)", level)
+		}
+		name = PresetNames[level]
+	}
+	passes, ok := Presets[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown obfuscation preset %q (want 0-3 or one of %s)", name, strings.Join(PresetNames, ", "))
+	}
+	return append([]Pass{}, passes...), nil
+}
+
+// Level returns the integer level whose preset passes is, or -1 when it is
+// another preset or a custom pipeline
+func Level(passes []Pass) int {
+	for level, name := range PresetNames[:4] {
+		if equalPasses(passes, Presets[name]) {
+			return level
+		}
+	}
+	return -1
+}
+
+// Describe names a pipeline: its preset, or its passes in order
+func Describe(passes []Pass) string {
+	for _, name := range PresetNames {
+		if equalPasses(passes, Presets[name]) {
+			return name
+		}
+	}
+	names := make([]string, len(passes))
+	for i, p := range passes {
+		names[i] = p.Name
+	}
+	return strings.Join(names, " → ")
+}
+
+// Virtualized returns passes with the virtualize pass added before the
+// trailing rename and minify passes, so the interpreter's own names and
+// layout are still obfuscated. Pipelines that virtualize are returned as is.
+func Virtualized(passes []Pass) []Pass {
+	at := len(passes)
+	for i, p := range passes {
+		if p.Name == PassVirtualize {
+			return passes
+		}
+		if p.Name != PassRename && p.Name != PassMinify {
+			at = len(passes)
+		} else if at == len(passes) {
+			at = i
+		}
+	}
+	out := make([]Pass, 0, len(passes)+1)
+	out = append(out, passes[:at]...)
+	out = append(out, Pass{Name: PassVirtualize})
+	return append(out, passes[at:]...)
+}
+
+// Validate checks that every pass exists and only sets its own options
+func Validate(passes []Pass) error {
+	for i, p := range passes {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("pass %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (p Pass) validate() error {
+	known := false
+	for _, name := range PassNames {
+		known = known || p.Name == name
+	}
+	if !known {
+		return fmt.Errorf("unknown pass %q (want one of %s)", p.Name, strings.Join(PassNames, ", "))
+	}
+
+	var foreign []string
+	for _, opt := range []struct {
+		name string
+		set  bool
+		pass string
+	}{
+		{"singleLine", p.SingleLine, PassMinify},
+		{"method", p.Method != "", PassStrings},
+		{"depth", p.Depth != 0, PassNumbers},
+		{"minStatements", p.MinStatements != 0, PassFlow},
+		{"count", p.Count != 0, PassJunk},
+		{"budget", p.Budget != 0, PassJunk},
+		{"message", p.Message != "", PassAntiTamper},
+	} {
+		if opt.set && opt.pass != p.Name {
+			foreign = append(foreign, opt.name)
+		}
+	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("option %s does not apply to the %s pass", strings.Join(foreign, ", "), p.Name)
+	}
+
+	switch {
+	case p.Method != "" && p.Method != StringsCipher && p.Method != StringsEscape:
+		return fmt.Errorf("unknown strings method %q (want %s or %s)", p.Method, StringsCipher, StringsEscape)
+	case p.Depth < 0 || p.Depth > 3:
+		return fmt.Errorf("numbers depth %d out of range (1-3)", p.Depth)
+	case p.MinStatements < 0:
+		return fmt.Errorf("flow minStatements must not be negative")
+	case p.Count < 0:
+		return fmt.Errorf("junk count must not be negative")
+	case p.Budget < 0:
+		return fmt.Errorf("junk budget must not be negative")
+	case p.Count != 0 && p.Budget != 0:
+		return fmt.Errorf("junk count and budget are exclusive")
+	}
+	return nil
+}
+
+// equalPasses reports whether a and b are the same pipeline
+func equalPasses(a, b []Pass) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package obfuscator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePreset(t *testing.T) {
+	for input, preset := range map[string]string{"0": "none", "1": "light", "2": "medium", "3": "heavy", "Heavy": "heavy", "max": "max"} {
+		passes, err := ParsePreset(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, Presets[preset], passes, input)
+	}
+
+	_, err := ParsePreset("4")
+	assert.EqualError(t, err, "obfuscation level 4 out of range (0-3)")
+	_, err = ParsePreset("ultra")
+	assert.EqualError(t, err, `unknown obfuscation preset "ultra" (want 0-3 or one of none, light, medium, heavy, max)`)
+}
+
+func TestLevelAndDescribe(t *testing.T) {
+	assert.Equal(t, 2, Level(Presets["medium"]))
+	assert.Equal(t, 0, Level(nil))
+	assert.Equal(t, -1, Level(Presets["max"]))
+	assert.Equal(t, -1, Level([]Pass{{Name: PassMinify, SingleLine: true}}))
+
+	assert.Equal(t, "heavy", Describe(Presets["heavy"]))
+	assert.Equal(t, "flow → strings → minify", Describe([]Pass{{Name: PassFlow}, {Name: PassStrings}, {Name: PassMinify}}))
+}
+
+func TestVirtualized(t *testing.T) {
+	assert.Equal(t, "flow → antitamper → junk → strings → numbers → virtualize → rename → minify", Describe(Virtualized(Presets["max"])))
+	assert.Equal(t, "rename → strings → virtualize → minify", Describe(Virtualized([]Pass{{Name: PassRename}, {Name: PassStrings}, {Name: PassMinify}})))
+	assert.Equal(t, "strings → virtualize", Describe(Virtualized([]Pass{{Name: PassStrings}})))
+	assert.Equal(t, "virtualize", Describe(Virtualized(nil)))
+
+	passes := []Pass{{Name: PassVirtualize}, {Name: PassMinify}}
+	assert.Equal(t, passes, Virtualized(passes), "pipelines that virtualize are kept")
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(Presets["max"]))
+	assert.NoError(t, Validate([]Pass{{Name: PassStrings, Method: StringsEscape}, {Name: PassNumbers, Depth: 3}, {Name: PassAntiTamper, Message: "no"}}))
+
+	tests := []struct {
+		passes []Pass
+		want   string
+	}{
+		{[]Pass{{Name: "shuffle"}}, `pass 1: unknown pass "shuffle" (want one of rename, strings, numbers, flow, junk, antitamper, minify, virtualize)`},
+		{[]Pass{{Name: PassRename}, {Name: PassFlow, Count: 2, SingleLine: true}}, "pass 2: option singleLine, count does not apply to the flow pass"},
+		{[]Pass{{Name: PassStrings, Method: "base64"}}, `pass 1: unknown strings method "base64" (want cipher or escape)`},
+		{[]Pass{{Name: PassNumbers, Depth: 4}}, "pass 1: numbers depth 4 out of range (1-3)"},
+		{[]Pass{{Name: PassJunk, Count: -1}}, "pass 1: junk count must not be negative"},
+		{[]Pass{{Name: PassJunk, Count: 2, Budget: 10}}, "pass 1: junk count and budget are exclusive"},
+		{[]Pass{{Name: PassMinify, Budget: 10}}, "pass 1: option budget does not apply to the minify pass"},
+	}
+	for _, tt := range tests {
+		assert.EqualError(t, Validate(tt.passes), tt.want)
+	}
+}
+
+func TestNewPipeline(t *testing.T) {
+	obf, err := NewPipeline([]Pass{{Name: PassMinify, SingleLine: true}})
+	require.NoError(t, err)
+	assert.Equal(t, -1, obf.level)
+	assert.False(t, obf.Renames())
+	assert.Equal(t, "local a = 1 return a", obf.Obfuscate("-- comment\nlocal a = 1\n\nreturn a\n"))
+
+	_, err = NewPipeline([]Pass{{Name: "shuffle"}})
+	assert.Error(t, err)
+}
+
+func TestNewObfuscator_LevelsArePresets(t *testing.T) {
+	assert.Equal(t, Presets["light"], NewObfuscator(1).Passes())
+	assert.Equal(t, Presets["heavy"], NewObfuscator(3).Passes())
+	assert.False(t, NewObfuscator(1).Renames())
+	assert.True(t, NewObfuscator(2).Renames())
+}
+
+func TestObfuscate_PassesRunInOrder(t *testing.T) {
+	code := "local greeting = \"hi\"\nprint(greeting)\n"
+
+	// Escaping first leaves nothing readable for a later pass to see
+	obf, err := NewPipeline([]Pass{{Name: PassStrings, Method: StringsEscape}, {Name: PassRename}})
+	require.NoError(t, err)
+	out := obf.Obfuscate(code)
+	assert.Contains(t, out, `"\104\105"`)
+	assert.NotContains(t, out, "greeting")
+
+	obf, err = NewPipeline([]Pass{{Name: PassJunk, Count: 1}, {Name: PassMinify, SingleLine: true}})
+	require.NoError(t, err)
+	out = obf.Obfuscate(code)
+	assert.NotContains(t, out, "\n")
+	assert.True(t, strings.HasSuffix(out, `local greeting = "hi" print(greeting)`), out)
+}
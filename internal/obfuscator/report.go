@@ -0,0 +1,313 @@
+package obfuscator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// Ratings of a bundle against a deobfuscation technique
+const (
+	RatingResists  = "resists"  // the technique cannot undo the protection
+	RatingPartial  = "partial"  // the technique undoes it with manual help
+	RatingDefeated = "defeated" // the technique undoes it automatically
+	RatingExposed  = "exposed"  // nothing protects against it
+)
+
+// ratingPoints weighs ratings in the score
+var ratingPoints = map[string]int{RatingResists: 4, RatingPartial: 2, RatingDefeated: 1, RatingExposed: 0}
+
+// Protection is an obfuscation technique and whether a bundle uses it
+type Protection struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	Detail string `json:"detail"`
+}
+
+// Resistance rates a bundle against an automated deobfuscation technique
+type Resistance struct {
+	Technique string `json:"technique"`
+	Rating    string `json:"rating"`
+	Detail    string `json:"detail"`
+}
+
+// Report is how well a bundle stands up to automated deobfuscators
+type Report struct {
+	Protections []Protection `json:"protections"`
+	Resistances []Resistance `json:"resistances"`
+	Score       int          `json:"score"` // 0 to 100
+}
+
+// generatedName matches the identifiers renameIdentifiers generates
+var generatedName = regexp.MustCompile(`^_0x[0-9a-f]{6}$`)
+
+// loaderName matches the bundle's loader functions, helpers and module
+// tables, whose string arguments and keys are module names rather than data
+var loaderName = regexp.MustCompile(`^(?:[A-Za-z_][A-Za-z0-9_]*_)?(?:loadModule|EmbeddedModules|LoadedModules|packResults|unpackResults|uncachedModules|require)$`)
+
+// longLine is the length from which a line counts as minified
+const longLine = 120
+
+// bundleStats are the measurements a report is based on
+type bundleStats struct {
+	locals, renamed       int
+	lines, bytes          int
+	longBytes             int // bytes on lines of longLine or more
+	strings, readable     int
+	numbers, folded       int
+	decryptor             string // function called with constant unreadable strings, "" for none
+	decryptorCalls        int
+	dispatchers, branches int
+}
+
+// Analyze reports which protections the bundle code uses and how it rates
+// against the techniques automated deobfuscators apply: identifier
+// recovery, string decryptor lifting, constant propagation and control
+// flow unflattening. It only sees the code, so it describes what a
+// deobfuscator would find rather than the options the bundle was built with.
+func Analyze(code string) (Report, error) {
+	chunk, err := parser.Parse(code)
+	if err != nil {
+		return Report{}, fmt.Errorf("cannot analyze bundle: %w", err)
+	}
+	s := measure(code, chunk)
+
+	var r Report
+	r.Protections = []Protection{
+		{"minification", s.bytes > 0 && s.longBytes*2 >= s.bytes, fmt.Sprintf("%d%% of the code on lines of %d characters or more", s.longBytes*100/max(s.bytes, 1), longLine)},
+		{"identifier renaming", s.locals > 0 && s.renamed*2 >= s.locals, fmt.Sprintf("%d of %d local name(s) generated", s.renamed, s.locals)},
+		{"string encoding", s.strings > 0 && s.readable*5 < s.strings, fmt.Sprintf("%d of %d string literal(s) readable", s.readable, s.strings)},
+		{"number encoding", s.folded > 0 && s.folded*3 >= s.numbers, fmt.Sprintf("%d of %d number literal(s) in constant expressions", s.folded, s.numbers)},
+		{"control flow flattening", s.dispatchers > 0, fmt.Sprintf("%d dispatcher loop(s) with %d branch(es)", s.dispatchers, s.branches)},
+	}
+	active := make(map[string]bool)
+	for _, p := range r.Protections {
+		active[p.Name] = p.Active
+	}
+
+	identifiers := Resistance{"identifier recovery", RatingExposed, "original local names are readable"}
+	if active["identifier renaming"] {
+		identifiers = Resistance{"identifier recovery", RatingResists, "renamed locals cannot be recovered, only relabelled by use"}
+	}
+
+	strs := Resistance{"string decryptor lifting", RatingExposed, "strings are readable without lifting anything"}
+	switch {
+	case s.decryptor != "":
+		strs = Resistance{"string decryptor lifting", RatingDefeated, fmt.Sprintf("%s is called %d time(s) with constant arguments; evaluating it recovers every string", s.decryptor, s.decryptorCalls)}
+	case active["string encoding"]:
+		strs = Resistance{"string decryptor lifting", RatingPartial, "strings are encoded without a single decryptor to lift"}
+	}
+
+	constants := Resistance{"constant propagation", RatingExposed, "number literals are plain"}
+	if active["number encoding"] {
+		constants = Resistance{"constant propagation", RatingDefeated, "constant expressions fold back to the original numbers"}
+	}
+
+	flow := Resistance{"control flow unflattening", RatingExposed, "statements run in source order"}
+	if active["control flow flattening"] {
+		flow = Resistance{"control flow unflattening", RatingPartial, "dispatcher states can be traced, but only with the state transitions solved"}
+	}
+
+	r.Resistances = []Resistance{identifiers, strs, constants, flow}
+	points := 0
+	for _, res := range r.Resistances {
+		points += ratingPoints[res.Rating]
+	}
+	r.Score = points * 100 / (len(r.Resistances) * ratingPoints[RatingResists])
+	return r, nil
+}
+
+// measure collects the statistics of a parsed bundle
+func measure(code string, chunk *parser.Chunk) bundleStats {
+	var s bundleStats
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s.lines++
+		s.bytes += len(line)
+		if len(line) >= longLine {
+			s.longBytes += len(line)
+		}
+	}
+
+	// The loader's locals are the same in every bundle
+	var loaderSpans []parser.Span
+	for _, stmt := range chunk.Block.Stmts {
+		if fn, ok := stmt.(*parser.LocalFunctionStmt); ok && loaderName.MatchString(fn.Name.Name) {
+			loaderSpans = append(loaderSpans, fn.Span)
+		}
+	}
+	for _, b := range chunk.Bindings {
+		if b.Global() || b.Decl == nil || b.Name == "_" || b.Name == "self" || loaderName.MatchString(b.Name) || inSpans(b.Decl.Start, loaderSpans) {
+			continue
+		}
+		s.locals++
+		if generatedName.MatchString(b.Name) {
+			s.renamed++
+		}
+	}
+
+	// Strings naming modules are not data a deobfuscator is after
+	moduleNames := make(map[parser.Span]bool)
+	callsByFn := make(map[string][]*parser.CallExpr)
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case *parser.CallExpr:
+			fn, ok := n.Fn.(*parser.Ident)
+			if !ok {
+				return true
+			}
+			if loaderName.MatchString(fn.Name) {
+				for _, arg := range n.Args {
+					moduleNames[arg.Range()] = true
+				}
+			} else if constantArgs(n.Args) {
+				callsByFn[fn.Name] = append(callsByFn[fn.Name], n)
+			}
+		case *parser.IndexExpr:
+			if x, ok := n.X.(*parser.Ident); ok && loaderName.MatchString(x.Name) {
+				moduleNames[n.Key.Range()] = true
+			}
+		case *parser.BinaryExpr:
+			if isConstant(n.X) && isConstant(n.Y) {
+				s.folded += countNumbers(n)
+				return false
+			}
+		case *parser.WhileStmt:
+			if branches := dispatcherBranches(n); branches > 0 {
+				s.dispatchers++
+				s.branches += branches
+			}
+		}
+		return true
+	})
+
+	for _, tok := range chunk.Tokens {
+		switch tok.Kind {
+		case parser.Number:
+			s.numbers++
+		case parser.String:
+			if moduleNames[parser.Span{Start: tok.Start, End: tok.End}] {
+				continue
+			}
+			// Short strings such as separators say nothing either way
+			value, ok := parser.StringValue(tok)
+			if !ok || len(value) < 3 {
+				continue
+			}
+			s.strings++
+			if readable(value) {
+				s.readable++
+			}
+		}
+	}
+
+	// A decryptor is called again and again with unreadable constants
+	for name, calls := range callsByFn {
+		encoded := 0
+		for _, call := range calls {
+			for _, arg := range call.Args {
+				if str, ok := arg.(*parser.StringExpr); ok {
+					if value, ok := parser.StringValue(str.Token); ok && !readable(value) {
+						encoded++
+						break
+					}
+				}
+			}
+		}
+		if encoded >= 3 && (encoded > s.decryptorCalls || encoded == s.decryptorCalls && name < s.decryptor) {
+			s.decryptor, s.decryptorCalls = name, encoded
+		}
+	}
+	return s
+}
+
+// readable reports whether a string's text reads as words: mostly
+// letters, digits and spaces
+func readable(value string) bool {
+	plain := 0
+	for _, c := range value {
+		if c == ' ' || c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			plain++
+		}
+	}
+	return plain*10 >= len(value)*8
+}
+
+// constantArgs reports whether every argument is a literal, with at least one
+func constantArgs(args []parser.Expr) bool {
+	for _, arg := range args {
+		if !isConstant(arg) {
+			return false
+		}
+	}
+	return len(args) > 0
+}
+
+// isConstant reports whether e is built from literals alone
+func isConstant(e parser.Expr) bool {
+	switch e := e.(type) {
+	case *parser.NumberExpr, *parser.StringExpr:
+		return true
+	case *parser.ParenExpr:
+		return isConstant(e.X)
+	case *parser.UnaryExpr:
+		return isConstant(e.X)
+	case *parser.BinaryExpr:
+		return isConstant(e.X) && isConstant(e.Y)
+	}
+	return false
+}
+
+// countNumbers counts the number literals of a constant expression
+func countNumbers(e parser.Expr) int {
+	n := 0
+	parser.Walk(e, func(node parser.Node) bool {
+		if _, ok := node.(*parser.NumberExpr); ok {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// dispatcherBranches returns the branches of a flattened control flow
+// dispatcher, a loop whose body picks the next block by comparing a state
+// variable with numbers or constant expressions, which encoded numbers
+// become, or 0 when the loop is not one
+func dispatcherBranches(loop *parser.WhileStmt) int {
+	for _, stmt := range loop.Body.Stmts {
+		ifStmt, ok := stmt.(*parser.IfStmt)
+		if !ok || len(ifStmt.Conds) < 3 {
+			continue
+		}
+		state := ""
+		for _, cond := range ifStmt.Conds {
+			bin, ok := cond.(*parser.BinaryExpr)
+			if !ok || bin.Op != "==" {
+				return 0
+			}
+			ident, ok := bin.X.(*parser.Ident)
+			if !ok || !isConstant(bin.Y) || (state != "" && ident.Name != state) {
+				return 0
+			}
+			state = ident.Name
+		}
+		return len(ifStmt.Conds)
+	}
+	return 0
+}
+
+// inSpans reports whether pos lies in one of spans
+func inSpans(pos int, spans []parser.Span) bool {
+	for _, span := range spans {
+		if pos >= span.Start && pos < span.End {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package obfuscator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// protections returns the active protections of a report
+func protections(r Report) []string {
+	var active []string
+	for _, p := range r.Protections {
+		if p.Active {
+			active = append(active, p.Name)
+		}
+	}
+	return active
+}
+
+// ratings returns the rating of each technique of a report
+func ratings(r Report) map[string]string {
+	out := make(map[string]string)
+	for _, res := range r.Resistances {
+		out[res.Technique] = res.Rating
+	}
+	return out
+}
+
+func TestAnalyze_PlainCode(t *testing.T) {
+	report, err := Analyze(`local EmbeddedModules = {}
+local function loadModule(url)
+    local module = EmbeddedModules[url]
+    return module(url)
+end
+EmbeddedModules["ui.theme"] = function(...)
+    local colors = { accent = "Deep sky blue" }
+    return colors
+end
+local theme = loadModule("ui.theme")
+print("Loaded the theme with accent " .. theme.accent)
+`)
+	require.NoError(t, err)
+	assert.Empty(t, protections(report))
+	assert.Equal(t, "0 of 2 local name(s) generated", report.Protections[1].Detail, "loader locals are not counted")
+	assert.Equal(t, "2 of 2 string literal(s) readable", report.Protections[2].Detail, "module names are not counted")
+	for _, rating := range ratings(report) {
+		assert.Equal(t, RatingExposed, rating)
+	}
+	assert.Equal(t, 0, report.Score)
+}
+
+func TestAnalyze_Protections(t *testing.T) {
+	code := `local _0x1a2b3c = function(s, k) return s end
+local _0x4d5e6f = _0x1a2b3c("\1\2\3\4", 7) .. _0x1a2b3c("\9\8\7\6", 3) .. _0x1a2b3c("\5\5\5\5", 1)
+local _0x777777 = (12 * 3 + 4) - (2 ^ 3)
+local _0x888888 = 1
+while true do
+    if _0x888888 == 1 then
+        _0x888888 = 3
+    elseif _0x888888 == 2 then
+        break
+    elseif _0x888888 == 3 then
+        _0x888888 = 2
+    end
+end
+`
+	report, err := Analyze(strings.ReplaceAll(code, "\n", " "))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"minification", "identifier renaming", "string encoding", "number encoding", "control flow flattening"}, protections(report))
+	assert.Equal(t, map[string]string{
+		"identifier recovery":       RatingResists,
+		"string decryptor lifting":  RatingDefeated,
+		"constant propagation":      RatingDefeated,
+		"control flow unflattening": RatingPartial,
+	}, ratings(report))
+	assert.Contains(t, report.Resistances[1].Detail, "_0x1a2b3c is called 3 time(s)")
+	assert.Equal(t, 50, report.Score)
+}
+
+func TestAnalyze_InvalidCode(t *testing.T) {
+	_, err := Analyze("local = 1")
+	assert.ErrorContains(t, err, "cannot analyze bundle")
+}
+
+func TestReadable(t *testing.T) {
+	assert.True(t, readable("Hello, world"))
+	assert.True(t, readable("ui.theme_v2"))
+	assert.False(t, readable("\x01\x02\x03\x04"))
+	assert.False(t, readable("%$#@!&*"))
+}
@@ -0,0 +1,258 @@
+package obfuscator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// vmWordLimit bounds the words of encoded instructions, so masking them
+// stays exact in every number representation
+const vmWordLimit = 1 << 24
+
+// vmPlaceholder matches the names of vmRuntime and vmHandlers replaced by
+// generated ones
+var vmPlaceholder = regexp.MustCompile(`\$[A-Z0-9]+`)
+
+// vmRuntime is the interpreter the virtualize pass emits, %[1]s being its
+// dispatch branches and %[2]s the main proto. Instructions are decoded
+// once per proto, when the chunk loads.
+const vmRuntime = `local $UNPACK = table.unpack or unpack
+local $SELECT, $TYPE, $GETMT, $NEXT, $TONUMBER, $ERROR = select, type, getmetatable, next, tonumber, error
+local $E = getfenv and getfenv(1) or _ENV or _G
+local $DEC = function($DS, $DK) local $DT = {} for $DI = 1, #$DS do $DT[$DI] = string.char((string.byte($DS, $DI) - $DK * $DI) %% 256) end return table.concat($DT) end
+local $LOAD = function($LM, $LK, $LC, $LP, $LN, $LU)
+  local $LD = {}
+  for $LI = 1, #$LM do $LD[$LI] = ($LM[$LI] - $LK * $LI) %% 16777216 end
+  return {$LD, $LC, $LP, $LN, $LU}
+end
+local $PACK = function(...) return $SELECT("#", ...), {...} end
+local $RUN
+local $WRAP = function($WP, $WU)
+  return function(...) return $RUN($WP, $WU, $SELECT("#", ...), {...}) end
+end
+$RUN = function($PR, $UP, $N, $ARGS)
+  local $C, $K, $PS = $PR[1], $PR[2], $PR[3]
+  local $CELLS, $S, $T, $PC = {}, {}, 0, 1
+  for $I = 1, $PR[4] do $CELLS[$I] = {$ARGS[$I]} end
+  while true do
+    local $OP, $A, $B = $C[$PC], $C[$PC + 1], $C[$PC + 2]
+    $PC = $PC + 3
+    if %[1]s
+    end
+  end
+end
+return $WRAP(%[2]s, {})(...)
+`
+
+// vmHandlers run the instructions
+var vmHandlers = map[int]string{
+	opConst:     `$T = $T + 1 $S[$T] = $K[$A]`,
+	opNil:       `$T = $T + 1 $S[$T] = nil`,
+	opTrue:      `$T = $T + 1 $S[$T] = true`,
+	opFalse:     `$T = $T + 1 $S[$T] = false`,
+	opGetLocal:  `$T = $T + 1 $S[$T] = $CELLS[$A][1]`,
+	opSetLocal:  `$CELLS[$A][1] = $S[$T] $T = $T - 1`,
+	opNewLocal:  `$CELLS[$A] = {$S[$T]} $T = $T - 1`,
+	opGetUp:     `$T = $T + 1 $S[$T] = $UP[$A][1]`,
+	opSetUp:     `$UP[$A][1] = $S[$T] $T = $T - 1`,
+	opGetGlobal: `$T = $T + 1 $S[$T] = $E[$K[$A]]`,
+	opSetGlobal: `$E[$K[$A]] = $S[$T] $T = $T - 1`,
+	opIndex:     `$S[$T - 1] = $S[$T - 1][$S[$T]] $T = $T - 1`,
+	opIndexAt:   `$T = $T + 1 $S[$T] = $S[$A][$S[$A + 1]]`,
+	opSetIndex:  `$S[$A][$S[$A + 1]] = $S[$T] $T = $T - 1`,
+	opSetField:  `$S[$A][$S[$T - 1]] = $S[$T] $T = $T - 2`,
+	opNewTable:  `$T = $T + 1 $S[$T] = {}`,
+	opSetList:   `local $X = $S[$A] for $I = $A + 1, $T do $X[$I - $A] = $S[$I] end $T = $A`,
+	opTop:       `$T = $A`,
+	opJump:      `$PC = $A`,
+	opJumpFalse: `if not $S[$T] then $PC = $A end $T = $T - 1`,
+	opJumpNil:   `if $S[$T] == nil then $PC = $A end $T = $T - 1`,
+	opAnd:       `if $S[$T] then $T = $T - 1 else $PC = $A end`,
+	opOr:        `if $S[$T] then $PC = $A else $T = $T - 1 end`,
+	opCall: `local $RN, $R = $PACK($S[$A]($UNPACK($S, $A + 1, $T)))
+      $T = $A - 1
+      if $B == 0 then $B = $RN + 1 end
+      for $I = 1, $B - 1 do $T = $T + 1 $S[$T] = $R[$I] end`,
+	opSelf: `local $X = $S[$T] $S[$T] = $X[$K[$A]] $T = $T + 1 $S[$T] = $X`,
+	opVararg: `local $VN = $A - 1
+      if $A == 0 then $VN = $N - $PR[4] if $VN < 0 then $VN = 0 end end
+      for $I = 1, $VN do $T = $T + 1 $S[$T] = $ARGS[$PR[4] + $I] end`,
+	opReturn: `return $UNPACK($S, $A, $T)`,
+	opClosure: `local $Q = $PS[$A] local $QU, $QC = $Q[5], {}
+      for $I = 1, #$QU, 2 do if $QU[$I] == 1 then $QC[#$QC + 1] = $CELLS[$QU[$I + 1]] else $QC[#$QC + 1] = $UP[$QU[$I + 1]] end end
+      $T = $T + 1 $S[$T] = $WRAP($Q, $QC)`,
+	opForPrep: `for $I = 0, 2 do
+        local $X = $TONUMBER($S[$T - 2 + $I])
+        if $X == nil then $ERROR("'for' initial value, limit and step must be numbers", 0) end
+        $CELLS[$A + $I] = {$X}
+      end
+      $T = $T - 3`,
+	opForTest: `local $X, $Y, $Z = $CELLS[$A][1], $CELLS[$A + 1][1], $CELLS[$A + 2][1]
+      if ($Z > 0 and $X > $Y) or ($Z <= 0 and $X < $Y) then $PC = $B end`,
+	opForStep: `$CELLS[$A][1] = $CELLS[$A][1] + $CELLS[$A + 2][1]`,
+	opIterPrep: `local $X, $Y, $Z = $S[$T - 2], $S[$T - 1], $S[$T]
+      if $TYPE($X) == "table" then
+        local $MT = $GETMT($X)
+        if $TYPE($MT) == "table" and $MT.__iter then $X, $Y, $Z = $MT.__iter($X)
+        elseif not ($TYPE($MT) == "table" and $MT.__call) then $X, $Y, $Z = $NEXT, $X, nil end
+      end
+      $CELLS[$A], $CELLS[$A + 1], $CELLS[$A + 2] = {$X}, {$Y}, {$Z}
+      $T = $T - 3`,
+	opIterCall: `local $RN, $R = $PACK($CELLS[$A][1]($CELLS[$A + 1][1], $CELLS[$A + 2][1]))
+      for $I = 1, $B do $T = $T + 1 $S[$T] = $R[$I] end`,
+	opNative: `$T = $T + 1 $S[$T] = $NAT[$A]`,
+	opUnm:    `$S[$T] = -$S[$T]`,
+	opNot:    `$S[$T] = not $S[$T]`,
+	opLen:    `$S[$T] = #$S[$T]`,
+	opBNot:   `$S[$T] = ~$S[$T]`,
+}
+
+// vmOperators are the Lua operators of the binary instructions
+var vmOperators = map[int]string{
+	opAdd: "+", opSub: "-", opMul: "*", opDiv: "/", opIDiv: "//", opMod: "%", opPow: "^", opConcat: "..",
+	opEq: "==", opNe: "~=", opLt: "<", opLe: "<=", opGt: ">", opGe: ">=",
+	opBAnd: "&", opBOr: "|", opBXor: "~", opShl: "<<", opShr: ">>",
+}
+
+// virtualize compiles the chunk to instructions for a generated
+// interpreter: opcodes are numbered at random for every chunk, and the
+// interpreter only handles the instructions the chunk uses. Calls the
+// bundler rewrites stay as Lua. Code the compiler cannot handle, with
+// goto, to-be-closed locals, interpolated strings or _ENV, is left as it
+// is, as is code with hot functions and code the parser cannot read.
+func (o *Obfuscator) virtualize(code string, p Pass) string {
+	if hotMarker.MatchString(code) {
+		return code
+	}
+	chunk, err := parser.Parse(code)
+	if err != nil {
+		return code
+	}
+	main, natives, err := compileVM(code, chunk)
+	if err != nil {
+		return code
+	}
+
+	used := make(map[int]bool)
+	var collect func(*vmProto)
+	collect = func(p *vmProto) {
+		for i := 0; i < len(p.code); i += 3 {
+			used[p.code[i]] = true
+		}
+		for _, child := range p.children {
+			collect(child)
+		}
+	}
+	collect(main)
+
+	// Distinct random opcodes, dispatched in random order
+	opcodes := make(map[int]int)
+	taken := make(map[int]bool)
+	var ops []int
+	for op := range used {
+		n := 0
+		for n == 0 || taken[n] {
+			n = randomInt(1<<16) + 1
+		}
+		opcodes[op], taken[n] = n, true
+		ops = append(ops, op)
+	}
+	for i := len(ops) - 1; i > 0; i-- {
+		j := randomInt(i + 1)
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	branches := make([]string, len(ops))
+	for i, op := range ops {
+		handler, ok := vmHandlers[op]
+		if !ok {
+			handler = fmt.Sprintf("$S[$T - 1] = $S[$T - 1] %s $S[$T] $T = $T - 1", vmOperators[op])
+		}
+		branches[i] = fmt.Sprintf("$OP == %d then\n      %s", opcodes[op], handler)
+	}
+
+	proto, ok := o.vmProtoSource(main, opcodes)
+	if !ok {
+		return code
+	}
+	runtime := fmt.Sprintf(vmRuntime, strings.Join(branches, "\n    elseif "), proto)
+	if len(natives) > 0 {
+		runtime = "local $NAT = {}\n" + runtime
+	}
+	names := make(map[string]string)
+	runtime = vmPlaceholder.ReplaceAllStringFunc(runtime, func(placeholder string) string {
+		if names[placeholder] == "" {
+			names[placeholder] = o.generateObfuscatedName()
+		}
+		return names[placeholder]
+	})
+
+	// Natives go in after naming, as their strings may hold anything
+	if len(natives) > 0 {
+		calls := make([]string, len(natives))
+		for i, source := range natives {
+			calls[i] = "function() return " + source + " end"
+		}
+		runtime = strings.Replace(runtime, "{}", "{"+strings.Join(calls, ", ")+"}", 1)
+	}
+	return runtime
+}
+
+// vmProtoSource returns the Lua building a proto and its children, its
+// instructions masked by a random key, or false when an operand does not
+// fit in an instruction word
+func (o *Obfuscator) vmProtoSource(p *vmProto, opcodes map[int]int) (string, bool) {
+	key := randomInt(1<<20) + 1
+	words := make([]string, len(p.code))
+	for i, word := range p.code {
+		if i%3 == 0 {
+			word = opcodes[word]
+		}
+		if word < 0 || word >= vmWordLimit {
+			return "", false
+		}
+		words[i] = strconv.Itoa((word + key*(i+1)) % vmWordLimit)
+	}
+
+	consts := make([]string, len(p.consts))
+	for i, k := range p.consts {
+		consts[i] = k.value
+		if k.isString {
+			consts[i] = o.vmString(k.value)
+		}
+	}
+	children := make([]string, len(p.children))
+	for i, child := range p.children {
+		source, ok := o.vmProtoSource(child, opcodes)
+		if !ok {
+			return "", false
+		}
+		children[i] = source
+	}
+	upvalues := make([]string, 0, 2*len(p.upvalues))
+	for _, up := range p.upvalues {
+		local := 0
+		if up.local {
+			local = 1
+		}
+		upvalues = append(upvalues, strconv.Itoa(local), strconv.Itoa(up.index))
+	}
+	return fmt.Sprintf("$LOAD({%s}, %d, {%s}, {%s}, %d, {%s})", strings.Join(words, ", "), key,
+		strings.Join(consts, ", "), strings.Join(children, ", "), p.params, strings.Join(upvalues, ", ")), true
+}
+
+// vmString returns Lua decoding to s, encoded as the strings pass does
+func (o *Obfuscator) vmString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	key, encoded := 0, ""
+	for attempt := 0; attempt < 16 && (attempt == 0 || readable(encoded)); attempt++ {
+		key = randomInt(255) + 1
+		encoded = shiftBytes(s, key)
+	}
+	return fmt.Sprintf("$DEC(%s, %d)", escapeBytes(encoded), key)
+}
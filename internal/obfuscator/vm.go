@@ -0,0 +1,935 @@
+package obfuscator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// errNotVirtualizable reports code the virtualize pass cannot compile
+var errNotVirtualizable = errors.New("not virtualizable")
+
+// Instructions of the virtual machine. Each is an opcode and two operands;
+// s is the value stack, t its top, and cells hold locals, one per
+// declaration run, so closures capture them as Lua does.
+const (
+	opConst     = iota // push K[a]
+	opNil              // push nil
+	opTrue             // push true
+	opFalse            // push false
+	opGetLocal         // push cells[a]
+	opSetLocal         // pop into cells[a]
+	opNewLocal         // pop into a new cell a
+	opGetUp            // push upvalue a
+	opSetUp            // pop into upvalue a
+	opGetGlobal        // push the global named K[a]
+	opSetGlobal        // pop into the global named K[a]
+	opIndex            // replace s[t-1], s[t] with s[t-1][s[t]]
+	opIndexAt          // push s[a][s[a+1]]
+	opSetIndex         // pop into s[a][s[a+1]]
+	opSetField         // s[a][s[t-1]] = s[t], popping both
+	opNewTable         // push {}
+	opSetList          // append s[a+1..t] to the table s[a], leaving it on top
+	opTop              // drop the stack to a values
+	opJump             // continue at a
+	opJumpFalse        // pop, continuing at a when falsy
+	opJumpNil          // pop, continuing at a when nil
+	opAnd              // continue at a keeping a falsy top, else pop
+	opOr               // continue at a keeping a truthy top, else pop
+	opCall             // call s[a] with s[a+1..t]; b-1 results, all when b is 0
+	opSelf             // replace s[t] with s[t][K[a]], then push the old s[t]
+	opVararg           // push a-1 varargs, all when a is 0
+	opReturn           // return s[a..t]
+	opClosure          // push a closure of child proto a
+	opForPrep          // pop start, limit and step into new cells a to a+2
+	opForTest          // continue at b when the loop of cells a is done
+	opForStep          // add the step to the counter of cells a
+	opIterPrep         // pop f, s and control into new cells a to a+2
+	opIterCall         // push b results of calling the iterator of cells a
+	opNative           // push native function a
+	opAdd
+	opSub
+	opMul
+	opDiv
+	opIDiv
+	opMod
+	opPow
+	opConcat
+	opEq
+	opNe
+	opLt
+	opLe
+	opGt
+	opGe
+	opBAnd
+	opBOr
+	opBXor
+	opShl
+	opShr
+	opUnm
+	opNot
+	opLen
+	opBNot
+	opCount
+)
+
+// vmBinary maps binary operators to their instructions
+var vmBinary = map[string]int{
+	"+": opAdd, "-": opSub, "*": opMul, "/": opDiv, "//": opIDiv, "%": opMod, "^": opPow, "..": opConcat,
+	"==": opEq, "~=": opNe, "<": opLt, "<=": opLe, ">": opGt, ">=": opGe,
+	"&": opBAnd, "|": opBOr, "~": opBXor, "<<": opShl, ">>": opShr,
+}
+
+// vmUnary maps unary operators to their instructions
+var vmUnary = map[string]int{"-": opUnm, "not": opNot, "#": opLen, "~": opBNot}
+
+// vmConst is a constant: Lua number source, or a string's value
+type vmConst struct {
+	value    string
+	isString bool
+}
+
+// vmUpvalue is where a closure finds an upvalue when it is created: a
+// local cell of the enclosing function, or one of its upvalues
+type vmUpvalue struct {
+	local bool
+	index int
+}
+
+// vmProto is a compiled function
+type vmProto struct {
+	code     []int // opcode, a, b
+	consts   []vmConst
+	children []*vmProto
+	params   int
+	upvalues []vmUpvalue
+}
+
+// vmLoop collects the jumps of break and continue statements to patch
+type vmLoop struct {
+	breaks, continues []int
+}
+
+// vmCompiler compiles one function
+type vmCompiler struct {
+	code     string
+	natives  *[]string // source of calls left to Lua, shared by every function
+	proto    *vmProto
+	parent   *vmCompiler
+	scopes   []map[string]int // name -> cell
+	cells    int
+	upNames  map[string]int // name -> upvalue
+	constIDs map[vmConst]int
+	depth    int // values on the stack, -1 after an open call or vararg
+	vararg   bool
+	loops    []*vmLoop
+}
+
+// compileVM compiles a chunk into the proto of its main function. Calls
+// the bundler rewrites, such as requires, stay Lua as natives: functions
+// returning the call, which the proto calls instead.
+func compileVM(code string, chunk *parser.Chunk) (*vmProto, []string, error) {
+	// Globals resolve through the interpreter's environment
+	env := false
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if id, ok := n.(*parser.Ident); ok && id.Name == "_ENV" {
+			env = true
+		}
+		return !env
+	})
+	if env {
+		return nil, nil, fmt.Errorf("%w: _ENV", errNotVirtualizable)
+	}
+
+	var natives []string
+	c := newVMCompiler(nil, true)
+	c.code, c.natives = code, &natives
+	if err := c.function(nil, chunk.Block); err != nil {
+		return nil, nil, err
+	}
+	return c.proto, natives, nil
+}
+
+func newVMCompiler(parent *vmCompiler, vararg bool) *vmCompiler {
+	c := &vmCompiler{
+		proto:    &vmProto{},
+		parent:   parent,
+		upNames:  make(map[string]int),
+		constIDs: make(map[vmConst]int),
+		vararg:   vararg,
+	}
+	if parent != nil {
+		c.code, c.natives = parent.code, parent.natives
+	}
+	return c
+}
+
+// function compiles a function body with its parameters in the first cells
+func (c *vmCompiler) function(params []string, body *parser.Block) error {
+	c.scopes = []map[string]int{{}}
+	for _, name := range params {
+		c.cells++
+		c.scopes[0][name] = c.cells
+	}
+	c.proto.params = len(params)
+	if err := c.block(body); err != nil {
+		return err
+	}
+	c.emit(opReturn, 1, 0)
+	return nil
+}
+
+func (c *vmCompiler) emit(op, a, b int) int {
+	c.proto.code = append(c.proto.code, op, a, b)
+	return len(c.proto.code) - 3
+}
+
+// here returns the position of the next instruction, as jumps name it
+func (c *vmCompiler) here() int {
+	return len(c.proto.code) + 1
+}
+
+// patch points the jump at pos to target
+func (c *vmCompiler) patch(pos, target int) {
+	if c.proto.code[pos] == opForTest {
+		c.proto.code[pos+2] = target
+		return
+	}
+	c.proto.code[pos+1] = target
+}
+
+func (c *vmCompiler) constant(k vmConst) int {
+	if id, ok := c.constIDs[k]; ok {
+		return id
+	}
+	c.proto.consts = append(c.proto.consts, k)
+	c.constIDs[k] = len(c.proto.consts)
+	return len(c.proto.consts)
+}
+
+func (c *vmCompiler) str(s string) int {
+	return c.constant(vmConst{value: s, isString: true})
+}
+
+// declare allocates a cell for a local that becomes visible once the
+// statement declaring it has run
+func (c *vmCompiler) declare() int {
+	c.cells++
+	return c.cells
+}
+
+func (c *vmCompiler) bind(name string, cell int) {
+	c.scopes[len(c.scopes)-1][name] = cell
+}
+
+// Variable kinds resolve returns
+const (
+	vmLocal = iota
+	vmUp
+	vmGlobal
+)
+
+// resolve finds the variable a name refers to
+func (c *vmCompiler) resolve(name string) (int, int) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if cell, ok := c.scopes[i][name]; ok {
+			return vmLocal, cell
+		}
+	}
+	if index, ok := c.upNames[name]; ok {
+		return vmUp, index
+	}
+	if c.parent != nil {
+		if kind, index := c.parent.resolve(name); kind != vmGlobal {
+			c.proto.upvalues = append(c.proto.upvalues, vmUpvalue{local: kind == vmLocal, index: index})
+			c.upNames[name] = len(c.proto.upvalues)
+			return vmUp, len(c.proto.upvalues)
+		}
+	}
+	return vmGlobal, c.str(name)
+}
+
+// global reports whether name refers to a global, without recording
+// upvalues as resolve does
+func (c *vmCompiler) global(name string) bool {
+	for ; c != nil; c = c.parent {
+		for _, scope := range c.scopes {
+			if _, ok := scope[name]; ok {
+				return false
+			}
+		}
+		if _, ok := c.upNames[name]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// native returns the source of a call to leave to Lua: one calling a
+// function whose arguments the bundler rewrites, reading only globals
+func (c *vmCompiler) native(call parser.Expr) (string, bool) {
+	kept, free := false, true
+	parser.Walk(call, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case *parser.CallExpr:
+			switch fn := n.Fn.(type) {
+			case *parser.Ident:
+				kept = kept || keptCallees[fn.Name]
+			case *parser.FieldExpr:
+				kept = kept || keptCallees[fn.Name.Value]
+			}
+		case *parser.MethodCallExpr:
+			kept = kept || keptCallees[n.Name.Value]
+		case *parser.Ident:
+			free = c.global(n.Name)
+		case *parser.VarargExpr, *parser.FunctionExpr:
+			free = false
+		}
+		return free
+	})
+	if !kept || !free {
+		return "", false
+	}
+	return c.code[call.Range().Start:call.Range().End], true
+}
+
+func (c *vmCompiler) block(b *parser.Block) error {
+	c.scopes = append(c.scopes, map[string]int{})
+	defer func() { c.scopes = c.scopes[:len(c.scopes)-1] }()
+	return c.stmts(b.Stmts)
+}
+
+func (c *vmCompiler) stmts(stmts []parser.Stmt) error {
+	for _, stmt := range stmts {
+		if err := c.stmt(stmt); err != nil {
+			return err
+		}
+		if c.depth != 0 {
+			return fmt.Errorf("%w: stack left at %d", errNotVirtualizable, c.depth)
+		}
+	}
+	return nil
+}
+
+func (c *vmCompiler) stmt(stmt parser.Stmt) error {
+	switch s := stmt.(type) {
+	case *parser.LocalStmt:
+		for _, attrib := range s.Attribs {
+			if attrib == "close" {
+				return fmt.Errorf("%w: to-be-closed local", errNotVirtualizable)
+			}
+		}
+		if err := c.exprList(s.Values, len(s.Names)); err != nil {
+			return err
+		}
+		cells := make([]int, len(s.Names))
+		for i := range s.Names {
+			cells[i] = c.declare()
+		}
+		for i := len(cells) - 1; i >= 0; i-- {
+			c.emit(opNewLocal, cells[i], 0)
+			c.depth--
+		}
+		for i, name := range s.Names {
+			c.bind(name.Name, cells[i])
+		}
+	case *parser.LocalFunctionStmt:
+		cell := c.declare()
+		c.bind(s.Name.Name, cell)
+		c.emit(opNil, 0, 0)
+		c.emit(opNewLocal, cell, 0)
+		if err := c.closure(s.Func, false); err != nil {
+			return err
+		}
+		c.emit(opSetLocal, cell, 0)
+		c.depth--
+	case *parser.FunctionStmt:
+		// function a.b:m() stores into a.b, function a.b() into a
+		object, key := s.Target, ""
+		if s.Method != nil {
+			key = s.Method.Value
+		} else if field, ok := s.Target.(*parser.FieldExpr); ok {
+			object, key = field.X, field.Name.Value
+		}
+		if key != "" {
+			base := c.depth + 1
+			if err := c.expr(object); err != nil {
+				return err
+			}
+			c.push(opConst, c.str(key))
+			if err := c.closure(s.Func, s.Method != nil); err != nil {
+				return err
+			}
+			c.emit(opSetIndex, base, 0)
+			c.emit(opTop, base-1, 0)
+			c.depth = base - 1
+			return nil
+		}
+		target, ok := s.Target.(*parser.Ident)
+		if !ok {
+			return fmt.Errorf("%w: function name", errNotVirtualizable)
+		}
+		if err := c.closure(s.Func, false); err != nil {
+			return err
+		}
+		c.store(target.Name)
+	case *parser.AssignStmt:
+		return c.assign(s)
+	case *parser.CallStmt:
+		return c.multi(s.Call, 0)
+	case *parser.DoStmt:
+		return c.block(s.Body)
+	case *parser.WhileStmt:
+		start := c.here()
+		if err := c.expr(s.Cond); err != nil {
+			return err
+		}
+		exit := c.emit(opJumpFalse, 0, 0)
+		c.depth--
+		loop, err := c.loop(s.Body.Stmts, nil)
+		if err != nil {
+			return err
+		}
+		c.emit(opJump, start, 0)
+		c.close(loop, start)
+		c.patch(exit, c.here())
+	case *parser.RepeatStmt:
+		start := c.here()
+		var cond int
+		loop, err := c.loop(s.Body.Stmts, func() error {
+			cond = c.here()
+			if err := c.expr(s.Cond); err != nil {
+				return err
+			}
+			c.emit(opJumpFalse, start, 0)
+			c.depth--
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		c.close(loop, cond)
+	case *parser.IfStmt:
+		var ends []int
+		for i, cond := range s.Conds {
+			if err := c.expr(cond); err != nil {
+				return err
+			}
+			next := c.emit(opJumpFalse, 0, 0)
+			c.depth--
+			if err := c.block(s.Blocks[i]); err != nil {
+				return err
+			}
+			ends = append(ends, c.emit(opJump, 0, 0))
+			c.patch(next, c.here())
+		}
+		if s.Else != nil {
+			if err := c.block(s.Else); err != nil {
+				return err
+			}
+		}
+		for _, end := range ends {
+			c.patch(end, c.here())
+		}
+	case *parser.NumericForStmt:
+		if err := c.expr(s.Start); err != nil {
+			return err
+		}
+		if err := c.expr(s.Limit); err != nil {
+			return err
+		}
+		if s.Step != nil {
+			if err := c.expr(s.Step); err != nil {
+				return err
+			}
+		} else {
+			c.push(opConst, c.constant(vmConst{value: "1"}))
+		}
+		hidden := c.declare()
+		c.declare()
+		c.declare()
+		c.emit(opForPrep, hidden, 0)
+		c.depth -= 3
+		start := c.here()
+		exit := c.emit(opForTest, hidden, 0)
+		counter := c.declare()
+		c.push(opGetLocal, hidden)
+		c.emit(opNewLocal, counter, 0)
+		c.depth--
+		loop, err := c.loop(s.Body.Stmts, nil, s.Var.Name, counter)
+		if err != nil {
+			return err
+		}
+		step := c.here()
+		c.emit(opForStep, hidden, 0)
+		c.emit(opJump, start, 0)
+		c.close(loop, step)
+		c.patch(exit, c.here())
+	case *parser.GenericForStmt:
+		if err := c.exprList(s.Exprs, 3); err != nil {
+			return err
+		}
+		hidden := c.declare()
+		c.declare()
+		c.declare()
+		c.emit(opIterPrep, hidden, 0)
+		c.depth -= 3
+		start := c.here()
+		c.emit(opIterCall, hidden, len(s.Names))
+		c.depth += len(s.Names)
+		cells := make([]int, len(s.Names))
+		var bindings []any
+		for i, name := range s.Names {
+			cells[i] = c.declare()
+			bindings = append(bindings, name.Name, cells[i])
+		}
+		for i := len(cells) - 1; i >= 0; i-- {
+			c.emit(opNewLocal, cells[i], 0)
+			c.depth--
+		}
+		c.push(opGetLocal, cells[0])
+		exit := c.emit(opJumpNil, 0, 0)
+		c.depth--
+		c.push(opGetLocal, cells[0])
+		c.emit(opSetLocal, hidden+2, 0)
+		c.depth--
+		loop, err := c.loop(s.Body.Stmts, nil, bindings...)
+		if err != nil {
+			return err
+		}
+		c.emit(opJump, start, 0)
+		c.close(loop, start)
+		c.patch(exit, c.here())
+	case *parser.ReturnStmt:
+		base := c.depth + 1
+		if err := c.exprList(s.Values, -1); err != nil {
+			return err
+		}
+		c.emit(opReturn, base, 0)
+		c.depth = base - 1
+	case *parser.BreakStmt:
+		if len(c.loops) == 0 {
+			return fmt.Errorf("%w: break outside a loop", errNotVirtualizable)
+		}
+		loop := c.loops[len(c.loops)-1]
+		loop.breaks = append(loop.breaks, c.emit(opJump, 0, 0))
+	case *parser.ContinueStmt:
+		if len(c.loops) == 0 {
+			return fmt.Errorf("%w: continue outside a loop", errNotVirtualizable)
+		}
+		loop := c.loops[len(c.loops)-1]
+		loop.continues = append(loop.continues, c.emit(opJump, 0, 0))
+	case *parser.GotoStmt, *parser.LabelStmt:
+		return fmt.Errorf("%w: goto", errNotVirtualizable)
+	case *parser.TypeStmt:
+	default:
+		return fmt.Errorf("%w: %T", errNotVirtualizable, stmt)
+	}
+	return nil
+}
+
+// loop compiles a loop body in a scope binding names to cells, given as
+// name, cell pairs, then runs after, which sees the body's locals as the
+// condition of repeat does
+func (c *vmCompiler) loop(stmts []parser.Stmt, after func() error, bindings ...any) (*vmLoop, error) {
+	loop := &vmLoop{}
+	c.loops = append(c.loops, loop)
+	c.scopes = append(c.scopes, map[string]int{})
+	defer func() {
+		c.loops = c.loops[:len(c.loops)-1]
+		c.scopes = c.scopes[:len(c.scopes)-1]
+	}()
+	for i := 0; i < len(bindings); i += 2 {
+		c.bind(bindings[i].(string), bindings[i+1].(int))
+	}
+	if err := c.stmts(stmts); err != nil {
+		return nil, err
+	}
+	if after != nil {
+		if err := after(); err != nil {
+			return nil, err
+		}
+	}
+	return loop, nil
+}
+
+// close points a loop's continue statements at next and its break
+// statements past the instructions compiled so far
+func (c *vmCompiler) close(loop *vmLoop, next int) {
+	for _, pos := range loop.continues {
+		c.patch(pos, next)
+	}
+	for _, pos := range loop.breaks {
+		c.patch(pos, c.here())
+	}
+}
+
+// assign compiles an assignment: table targets first, then the values,
+// stored from the last target to the first
+func (c *vmCompiler) assign(s *parser.AssignStmt) error {
+	start := c.depth
+	bases := make([]int, len(s.Targets))
+	for i, target := range s.Targets {
+		switch target := target.(type) {
+		case *parser.Ident:
+		case *parser.FieldExpr:
+			bases[i] = c.depth + 1
+			if err := c.expr(target.X); err != nil {
+				return err
+			}
+			c.push(opConst, c.str(target.Name.Value))
+		case *parser.IndexExpr:
+			bases[i] = c.depth + 1
+			if err := c.expr(target.X); err != nil {
+				return err
+			}
+			if err := c.expr(target.Key); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: assignment target", errNotVirtualizable)
+		}
+	}
+
+	if s.Op != "" && s.Op != "=" {
+		// Compound assignments have one target and one value
+		op, ok := vmBinary[strings.TrimSuffix(s.Op, "=")]
+		if !ok || len(s.Targets) != 1 || len(s.Values) != 1 {
+			return fmt.Errorf("%w: compound assignment %s", errNotVirtualizable, s.Op)
+		}
+		if ident, ok := s.Targets[0].(*parser.Ident); ok {
+			c.load(ident.Name)
+		} else {
+			c.push(opIndexAt, bases[0])
+		}
+		if err := c.expr(s.Values[0]); err != nil {
+			return err
+		}
+		c.emit(op, 0, 0)
+		c.depth--
+	} else if err := c.exprList(s.Values, len(s.Targets)); err != nil {
+		return err
+	}
+
+	for i := len(s.Targets) - 1; i >= 0; i-- {
+		if ident, ok := s.Targets[i].(*parser.Ident); ok {
+			c.store(ident.Name)
+			continue
+		}
+		c.emit(opSetIndex, bases[i], 0)
+		c.depth--
+	}
+	if c.depth != start {
+		c.emit(opTop, start, 0)
+		c.depth = start
+	}
+	return nil
+}
+
+// push emits an instruction pushing one value
+func (c *vmCompiler) push(op, a int) {
+	c.emit(op, a, 0)
+	c.depth++
+}
+
+// load pushes a variable
+func (c *vmCompiler) load(name string) {
+	switch kind, index := c.resolve(name); kind {
+	case vmLocal:
+		c.push(opGetLocal, index)
+	case vmUp:
+		c.push(opGetUp, index)
+	default:
+		c.push(opGetGlobal, index)
+	}
+}
+
+// store pops into a variable
+func (c *vmCompiler) store(name string) {
+	switch kind, index := c.resolve(name); kind {
+	case vmLocal:
+		c.emit(opSetLocal, index, 0)
+	case vmUp:
+		c.emit(opSetUp, index, 0)
+	default:
+		c.emit(opSetGlobal, index, 0)
+	}
+	c.depth--
+}
+
+// closure compiles a function expression and pushes a closure of it
+func (c *vmCompiler) closure(fn *parser.FunctionExpr, method bool) error {
+	var params []string
+	if method {
+		params = append(params, "self")
+	}
+	for _, param := range fn.Params {
+		params = append(params, param.Name)
+	}
+	child := newVMCompiler(c, fn.IsVararg)
+	if err := child.function(params, fn.Body); err != nil {
+		return err
+	}
+	c.proto.children = append(c.proto.children, child.proto)
+	c.push(opClosure, len(c.proto.children))
+	return nil
+}
+
+// exprList pushes want values of exprs, adjusting like Lua: missing values
+// are nil, extra ones are evaluated and dropped, and a call or vararg last
+// fills the rest. want -1 keeps every value.
+func (c *vmCompiler) exprList(exprs []parser.Expr, want int) error {
+	for i, e := range exprs {
+		last := i == len(exprs)-1
+		switch {
+		case want >= 0 && i >= want:
+			if err := c.multi(e, 0); err != nil {
+				return err
+			}
+		case last && isMulti(e):
+			n := -1
+			if want >= 0 {
+				n = want - i
+			}
+			if err := c.multi(e, n); err != nil {
+				return err
+			}
+			return nil
+		default:
+			if err := c.expr(e); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(exprs); i < want; i++ {
+		c.push(opNil, 0)
+	}
+	return nil
+}
+
+// isMulti reports whether e can produce several values
+func isMulti(e parser.Expr) bool {
+	switch e.(type) {
+	case *parser.CallExpr, *parser.MethodCallExpr, *parser.VarargExpr:
+		return true
+	}
+	return false
+}
+
+// multi pushes n values of e, all of them when n is -1; other expressions
+// than calls and varargs give one value
+func (c *vmCompiler) multi(e parser.Expr, n int) error {
+	results := n + 1
+	if n < 0 {
+		results = 0
+	}
+	if source, ok := c.native(e); ok && isMulti(e) {
+		*c.natives = append(*c.natives, source)
+		base := c.depth + 1
+		c.push(opNative, len(*c.natives))
+		c.emit(opCall, base, results)
+		c.depth = base - 1 + n
+		if n < 0 {
+			c.depth = -1
+		}
+		return nil
+	}
+	switch e := e.(type) {
+	case *parser.CallExpr:
+		base := c.depth + 1
+		if err := c.expr(e.Fn); err != nil {
+			return err
+		}
+		if err := c.exprList(e.Args, -1); err != nil {
+			return err
+		}
+		c.emit(opCall, base, results)
+		c.depth = base - 1 + n
+	case *parser.MethodCallExpr:
+		base := c.depth + 1
+		if err := c.expr(e.Recv); err != nil {
+			return err
+		}
+		c.push(opSelf, c.str(e.Name.Value))
+		if err := c.exprList(e.Args, -1); err != nil {
+			return err
+		}
+		c.emit(opCall, base, results)
+		c.depth = base - 1 + n
+	case *parser.VarargExpr:
+		if !c.vararg {
+			return fmt.Errorf("%w: ... outside a vararg function", errNotVirtualizable)
+		}
+		c.emit(opVararg, results, 0)
+		c.depth += n
+	default:
+		if err := c.expr(e); err != nil {
+			return err
+		}
+		switch {
+		case n == 0:
+			c.emit(opTop, c.depth-1, 0)
+			c.depth--
+		case n > 1:
+			for i := 1; i < n; i++ {
+				c.push(opNil, 0)
+			}
+		}
+		return nil
+	}
+	if n < 0 {
+		c.depth = -1
+	}
+	return nil
+}
+
+// expr pushes the value of e
+func (c *vmCompiler) expr(e parser.Expr) error {
+	switch e := e.(type) {
+	case *parser.NilExpr:
+		c.push(opNil, 0)
+	case *parser.TrueExpr:
+		c.push(opTrue, 0)
+	case *parser.FalseExpr:
+		c.push(opFalse, 0)
+	case *parser.NumberExpr:
+		c.push(opConst, c.constant(vmConst{value: e.Value}))
+	case *parser.StringExpr:
+		value, ok := parser.StringValue(e.Token)
+		if !ok || strings.HasPrefix(e.Token.Value, "`") {
+			return fmt.Errorf("%w: interpolated string", errNotVirtualizable)
+		}
+		c.push(opConst, c.str(value))
+	case *parser.Ident:
+		c.load(e.Name)
+	case *parser.VarargExpr, *parser.CallExpr, *parser.MethodCallExpr:
+		return c.multi(e, 1)
+	case *parser.FunctionExpr:
+		return c.closure(e, false)
+	case *parser.TableExpr:
+		return c.table(e)
+	case *parser.BinaryExpr:
+		if e.Op == "and" || e.Op == "or" {
+			if err := c.expr(e.X); err != nil {
+				return err
+			}
+			op := opAnd
+			if e.Op == "or" {
+				op = opOr
+			}
+			jump := c.emit(op, 0, 0)
+			c.depth--
+			if err := c.expr(e.Y); err != nil {
+				return err
+			}
+			c.patch(jump, c.here())
+			return nil
+		}
+		op, ok := vmBinary[e.Op]
+		if !ok {
+			return fmt.Errorf("%w: operator %s", errNotVirtualizable, e.Op)
+		}
+		if err := c.expr(e.X); err != nil {
+			return err
+		}
+		if err := c.expr(e.Y); err != nil {
+			return err
+		}
+		c.emit(op, 0, 0)
+		c.depth--
+	case *parser.UnaryExpr:
+		op, ok := vmUnary[e.Op]
+		if !ok {
+			return fmt.Errorf("%w: operator %s", errNotVirtualizable, e.Op)
+		}
+		if err := c.expr(e.X); err != nil {
+			return err
+		}
+		c.emit(op, 0, 0)
+	case *parser.ParenExpr:
+		return c.expr(e.X)
+	case *parser.TypeAssertExpr:
+		return c.expr(e.X)
+	case *parser.IndexExpr:
+		if err := c.expr(e.X); err != nil {
+			return err
+		}
+		if err := c.expr(e.Key); err != nil {
+			return err
+		}
+		c.emit(opIndex, 0, 0)
+		c.depth--
+	case *parser.FieldExpr:
+		if err := c.expr(e.X); err != nil {
+			return err
+		}
+		c.push(opConst, c.str(e.Name.Value))
+		c.emit(opIndex, 0, 0)
+		c.depth--
+	case *parser.IfExpr:
+		var ends []int
+		for i, cond := range e.Conds {
+			if err := c.expr(cond); err != nil {
+				return err
+			}
+			next := c.emit(opJumpFalse, 0, 0)
+			c.depth--
+			if err := c.expr(e.Thens[i]); err != nil {
+				return err
+			}
+			ends = append(ends, c.emit(opJump, 0, 0))
+			c.depth--
+			c.patch(next, c.here())
+		}
+		if err := c.expr(e.Else); err != nil {
+			return err
+		}
+		for _, end := range ends {
+			c.patch(end, c.here())
+		}
+	default:
+		return fmt.Errorf("%w: %T", errNotVirtualizable, e)
+	}
+	return nil
+}
+
+// table pushes a table built by a constructor. Keyed fields are stored as
+// they come; list values wait on the stack and are appended at the end.
+func (c *vmCompiler) table(e *parser.TableExpr) error {
+	c.push(opNewTable, 0)
+	base := c.depth
+	list := false
+	for i, field := range e.Fields {
+		switch field.Kind {
+		case parser.ListField:
+			list = true
+			if i == len(e.Fields)-1 && isMulti(field.Value) {
+				if err := c.multi(field.Value, -1); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := c.expr(field.Value); err != nil {
+				return err
+			}
+		case parser.NamedField, parser.KeyedField:
+			if field.Kind == parser.NamedField {
+				c.push(opConst, c.str(field.Name.Value))
+			} else if err := c.expr(field.Key); err != nil {
+				return err
+			}
+			if err := c.expr(field.Value); err != nil {
+				return err
+			}
+			c.emit(opSetField, base, 0)
+			c.depth -= 2
+		}
+	}
+	if list {
+		c.emit(opSetList, base, 0)
+		c.depth = base
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+package obfuscator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/constt/lua-bundler/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests run compiled protos on a small executor mirroring vmHandlers,
+// with numbers, strings, tables, closures and a few builtins
+
+type vmCell struct{ v any }
+
+type vmTable struct {
+	fields map[any]any
+	keys   []any // in insertion order, for pairs
+}
+
+func newVMTable() *vmTable { return &vmTable{fields: make(map[any]any)} }
+
+func (t *vmTable) get(k any) any { return t.fields[k] }
+
+func (t *vmTable) set(k, v any) {
+	if _, ok := t.fields[k]; !ok && v != nil {
+		t.keys = append(t.keys, k)
+	}
+	if v == nil {
+		delete(t.fields, k)
+		return
+	}
+	t.fields[k] = v
+}
+
+func (t *vmTable) length() float64 {
+	n := 0.0
+	for t.fields[n+1] != nil {
+		n++
+	}
+	return n
+}
+
+type vmGoFunc func(args []any) []any
+
+type vmClosure struct {
+	proto *vmProto
+	ups   []*vmCell
+}
+
+type vmExec struct {
+	t       *testing.T
+	globals *vmTable
+	natives []vmGoFunc
+	output  []string
+}
+
+func truthy(v any) bool { return v != nil && v != false }
+
+func (x *vmExec) call(fn any, args []any) []any {
+	switch fn := fn.(type) {
+	case vmGoFunc:
+		return fn(args)
+	case *vmClosure:
+		return x.run(fn.proto, fn.ups, args)
+	}
+	x.t.Fatalf("attempt to call %v", fn)
+	return nil
+}
+
+func (x *vmExec) constant(k vmConst) any {
+	if k.isString {
+		return k.value
+	}
+	if f, err := strconv.ParseFloat(k.value, 64); err == nil {
+		return f
+	}
+	n, err := strconv.ParseInt(k.value, 0, 64)
+	require.NoError(x.t, err)
+	return float64(n)
+}
+
+func (x *vmExec) run(p *vmProto, ups []*vmCell, args []any) []any {
+	cells := make(map[int]*vmCell)
+	for i := 1; i <= p.params; i++ {
+		var v any
+		if i <= len(args) {
+			v = args[i-1]
+		}
+		cells[i] = &vmCell{v}
+	}
+	var s []any
+	pop := func() any {
+		v := s[len(s)-1]
+		s = s[:len(s)-1]
+		return v
+	}
+	push := func(v any) { s = append(s, v) }
+	// Stack positions are 1-based, as in Lua
+	at := func(i int) any { return s[i-1] }
+
+	for pc := 1; ; {
+		op, a, b := p.code[pc-1], p.code[pc], p.code[pc+1]
+		pc += 3
+		switch op {
+		case opConst:
+			push(x.constant(p.consts[a-1]))
+		case opNil:
+			push(nil)
+		case opTrue:
+			push(true)
+		case opFalse:
+			push(false)
+		case opGetLocal:
+			push(cells[a].v)
+		case opSetLocal:
+			cells[a].v = pop()
+		case opNewLocal:
+			cells[a] = &vmCell{pop()}
+		case opGetUp:
+			push(ups[a-1].v)
+		case opSetUp:
+			ups[a-1].v = pop()
+		case opGetGlobal:
+			push(x.globals.get(x.constant(p.consts[a-1])))
+		case opSetGlobal:
+			x.globals.set(x.constant(p.consts[a-1]), pop())
+		case opIndex:
+			k := pop()
+			push(pop().(*vmTable).get(k))
+		case opIndexAt:
+			push(at(a).(*vmTable).get(at(a + 1)))
+		case opSetIndex:
+			v := pop()
+			at(a).(*vmTable).set(at(a+1), v)
+		case opSetField:
+			v, k := pop(), pop()
+			at(a).(*vmTable).set(k, v)
+		case opNewTable:
+			push(newVMTable())
+		case opSetList:
+			t := at(a).(*vmTable)
+			for i := a + 1; i <= len(s); i++ {
+				t.set(float64(i-a), at(i))
+			}
+			s = s[:a]
+		case opTop:
+			for len(s) < a {
+				push(nil)
+			}
+			s = s[:a]
+		case opJump:
+			pc = a
+		case opJumpFalse:
+			if !truthy(pop()) {
+				pc = a
+			}
+		case opJumpNil:
+			if pop() == nil {
+				pc = a
+			}
+		case opAnd:
+			if truthy(s[len(s)-1]) {
+				pop()
+			} else {
+				pc = a
+			}
+		case opOr:
+			if truthy(s[len(s)-1]) {
+				pc = a
+			} else {
+				pop()
+			}
+		case opCall:
+			results := x.call(at(a), append([]any{}, s[a:]...))
+			s = s[:a-1]
+			if b == 0 {
+				b = len(results) + 1
+			}
+			for i := 0; i < b-1; i++ {
+				if i < len(results) {
+					push(results[i])
+				} else {
+					push(nil)
+				}
+			}
+		case opSelf:
+			obj := pop()
+			push(obj.(*vmTable).get(x.constant(p.consts[a-1])))
+			push(obj)
+		case opVararg:
+			var extra []any
+			if len(args) > p.params {
+				extra = args[p.params:]
+			}
+			n := a - 1
+			if a == 0 {
+				n = len(extra)
+			}
+			for i := 0; i < n; i++ {
+				if i < len(extra) {
+					push(extra[i])
+				} else {
+					push(nil)
+				}
+			}
+		case opReturn:
+			if a > len(s) {
+				return nil
+			}
+			return append([]any{}, s[a-1:]...)
+		case opClosure:
+			child := p.children[a-1]
+			var captured []*vmCell
+			for _, up := range child.upvalues {
+				if up.local {
+					captured = append(captured, cells[up.index])
+				} else {
+					captured = append(captured, ups[up.index-1])
+				}
+			}
+			push(&vmClosure{child, captured})
+		case opForPrep:
+			step, limit, start := pop(), pop(), pop()
+			cells[a], cells[a+1], cells[a+2] = &vmCell{start}, &vmCell{limit}, &vmCell{step}
+		case opForTest:
+			i, limit, step := cells[a].v.(float64), cells[a+1].v.(float64), cells[a+2].v.(float64)
+			if (step > 0 && i > limit) || (step <= 0 && i < limit) {
+				pc = b
+			}
+		case opForStep:
+			cells[a].v = cells[a].v.(float64) + cells[a+2].v.(float64)
+		case opIterPrep:
+			ctl, state, f := pop(), pop(), pop()
+			cells[a], cells[a+1], cells[a+2] = &vmCell{f}, &vmCell{state}, &vmCell{ctl}
+		case opIterCall:
+			results := x.call(cells[a].v, []any{cells[a+1].v, cells[a+2].v})
+			for i := 0; i < b; i++ {
+				if i < len(results) {
+					push(results[i])
+				} else {
+					push(nil)
+				}
+			}
+		case opNative:
+			push(x.natives[a-1])
+		case opUnm:
+			push(-pop().(float64))
+		case opNot:
+			push(!truthy(pop()))
+		case opLen:
+			switch v := pop().(type) {
+			case string:
+				push(float64(len(v)))
+			case *vmTable:
+				push(v.length())
+			}
+		default:
+			y, v := pop(), pop()
+			push(x.binary(op, v, y))
+		}
+	}
+}
+
+func (x *vmExec) binary(op int, a, b any) any {
+	switch op {
+	case opEq:
+		return a == b
+	case opNe:
+		return a != b
+	case opConcat:
+		return x.str(a) + x.str(b)
+	}
+	l, r := a.(float64), b.(float64)
+	switch op {
+	case opAdd:
+		return l + r
+	case opSub:
+		return l - r
+	case opMul:
+		return l * r
+	case opDiv:
+		return l / r
+	case opMod:
+		return l - math.Floor(l/r)*r
+	case opPow:
+		return math.Pow(l, r)
+	case opLt:
+		return l < r
+	case opLe:
+		return l <= r
+	case opGt:
+		return l > r
+	case opGe:
+		return l >= r
+	}
+	x.t.Fatalf("unsupported instruction %d", op)
+	return nil
+}
+
+func (x *vmExec) str(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// execVM compiles code and runs it, returning what it prints
+func execVM(t *testing.T, code string, natives ...vmGoFunc) []string {
+	chunk, err := parser.Parse(code)
+	require.NoError(t, err)
+	main, sources, err := compileVM(code, chunk)
+	require.NoError(t, err)
+	require.Len(t, sources, len(natives))
+
+	x := &vmExec{t: t, globals: newVMTable(), natives: natives}
+	x.globals.set("print", vmGoFunc(func(args []any) []any {
+		parts := make([]string, len(args))
+		for i, a := range args {
+			parts[i] = x.str(a)
+		}
+		x.output = append(x.output, strings.Join(parts, " "))
+		return nil
+	}))
+	x.globals.set("select", vmGoFunc(func(args []any) []any {
+		if args[0] == "#" {
+			return []any{float64(len(args) - 1)}
+		}
+		return args[int(args[0].(float64)):]
+	}))
+	inext := vmGoFunc(func(args []any) []any {
+		i := args[1].(float64) + 1
+		if v := args[0].(*vmTable).get(i); v != nil {
+			return []any{i, v}
+		}
+		return []any{nil}
+	})
+	x.globals.set("ipairs", vmGoFunc(func(args []any) []any { return []any{inext, args[0], 0.0} }))
+	x.globals.set("pairs", vmGoFunc(func(args []any) []any {
+		keys := args[0].(*vmTable).keys
+		i := 0
+		next := vmGoFunc(func([]any) []any {
+			for ; i < len(keys); i++ {
+				if v := args[0].(*vmTable).get(keys[i]); v != nil {
+					i++
+					return []any{keys[i-1], v}
+				}
+			}
+			return []any{nil}
+		})
+		return []any{next, args[0], nil}
+	}))
+	x.run(main, nil, nil)
+	return x.output
+}
+
+func TestCompileVM_Closures(t *testing.T) {
+	out := execVM(t, `
+local fns = {}
+for i = 1, 3 do fns[#fns + 1] = function() return i end end
+local seen = {}
+for _, f in ipairs(fns) do seen[#seen + 1] = f() end
+print(seen[1], seen[2], seen[3])
+
+local function counter()
+    local n = 0
+    return function() n = n + 1 return n end, function() return n end
+end
+local inc, get = counter()
+inc() inc()
+print(get())
+
+local function fib(n) if n < 2 then return n end return fib(n - 1) + fib(n - 2) end
+print(fib(10))
+`)
+	assert.Equal(t, []string{"1 2 3", "2", "55"}, out)
+}
+
+func TestCompileVM_Values(t *testing.T) {
+	out := execVM(t, `
+local function count(...) return select("#", ...), ... end
+local function three() return 1, 2, 3 end
+print(count(three()))
+print(count(three(), 10))
+print((three()))
+local list = {three(), three()}
+print(#list)
+local a, b, c, d = three()
+print(a, b, c, d)
+a, b = b, a
+print(a, b)
+local t = {x = 1, ["y"] = 2, 3}
+print(t.x + t.y + t[1])
+print(nil or "default", false and 1, 1 and 2, "a" .. 1)
+print(2 ^ 3, 7 % 3, -5 % 3, not nil)
+`)
+	assert.Equal(t, []string{"3 1 2 3", "2 1 10", "1", "4", "1 2 3 nil", "2 1", "6", "default false 2 a1", "8 1 1 true"}, out)
+}
+
+func TestCompileVM_Statements(t *testing.T) {
+	out := execVM(t, `
+local obj = {balance = 10}
+function obj:deposit(v) self.balance = self.balance + v return self end
+obj:deposit(5):deposit(1)
+print(obj.balance)
+
+total = 0
+for i = 10, 1, -3 do total = total + i end
+print(total)
+
+local n = 0
+while true do
+    n = n + 1
+    if n > 4 then break end
+end
+repeat local done = n >= 8 n = n + 1 until done
+print(n)
+
+local odd = 0
+for i = 1, 10 do
+    if i % 2 == 0 then continue end
+    odd += i
+end
+print(odd)
+
+local grade = if odd > 20 then "high" elseif odd > 10 then "mid" else "low"
+print(grade)
+
+local keys = {}
+for k, v in pairs({a = 1, b = 2}) do keys[#keys + 1] = k .. v end
+print(keys[1], keys[2])
+local m = {}
+m.list = {1}
+m.list[1] += 4
+print(m.list[1])
+`)
+	assert.Equal(t, []string{"16", "22", "9", "25", "high", "a1 b2", "5"}, out)
+}
+
+func TestCompileVM_Natives(t *testing.T) {
+	code := "local util = require(\"util\")\nlocal value = {name = require(\"util\").name}\nprint(util.name, value.name, select(\"#\", util, require(\"util\")))\n"
+	util := newVMTable()
+	util.set("name", "util")
+	require := vmGoFunc(func([]any) []any { return []any{util} })
+	assert.Equal(t, []string{"util util 2"}, execVM(t, code, require, require, require))
+
+	chunk, err := parser.Parse(code)
+	assert.NoError(t, err)
+	_, natives, err := compileVM(code, chunk)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`require("util")`, `require("util")`, `require("util")`}, natives)
+}
+
+func TestCompileVM_Unsupported(t *testing.T) {
+	for name, code := range map[string]string{
+		"goto":          "goto skip\n::skip::\n",
+		"close":         "local f <close> = nil\n",
+		"interpolation": "print(`{1}`)\n",
+		"env":           "local _ENV = {}\n",
+	} {
+		chunk, err := parser.Parse(code)
+		require.NoError(t, err, name)
+		_, _, err = compileVM(code, chunk)
+		assert.ErrorIs(t, err, errNotVirtualizable, name)
+	}
+}
+
+func TestVirtualize(t *testing.T) {
+	obf := NewObfuscator(1)
+	code := "local util = require(\"util\")\nlocal Secret = \"hunter2\"\nreturn function(x) return util.check(x, Secret) end\n"
+
+	out := obf.virtualize(code, Pass{Name: PassVirtualize})
+	_, err := parser.Parse(out)
+	require.NoError(t, err, out)
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "Secret")
+	assert.NotContains(t, out, "check")
+	assert.Contains(t, out, `function() return require("util") end`, "the bundler still sees the require")
+	assert.NotContains(t, out, "$", "every placeholder is named")
+	assert.NotEqual(t, out, obf.virtualize(code, Pass{Name: PassVirtualize}), "opcodes differ every time")
+
+	assert.Equal(t, "goto a\n::a::\n", obf.virtualize("goto a\n::a::\n", Pass{Name: PassVirtualize}))
+}
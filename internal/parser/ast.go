@@ -0,0 +1,301 @@
+package parser
+
+// Span is the byte range of a node in the source
+type Span struct {
+	Start int
+	End   int
+}
+
+// Range returns the node's byte range
+func (s Span) Range() Span { return s }
+
+// Node is any syntax tree node
+type Node interface {
+	Range() Span
+}
+
+// Expr is an expression node
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Stmt is a statement node
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Chunk is a parsed source file
+type Chunk struct {
+	Span
+	Block    *Block
+	Tokens   []Token    // all tokens, including comments
+	Scope    *Scope     // top-level scope
+	Bindings []*Binding // every local in declaration order, then globals by first use
+}
+
+// Block is a sequence of statements with its own scope
+type Block struct {
+	Span
+	Stmts []Stmt
+	Scope *Scope
+}
+
+// Expressions
+
+type (
+	NilExpr    struct{ Span }
+	TrueExpr   struct{ Span }
+	FalseExpr  struct{ Span }
+	VarargExpr struct{ Span }
+
+	NumberExpr struct {
+		Span
+		Value string
+	}
+
+	// StringExpr is a string literal; Token holds the raw literal
+	StringExpr struct {
+		Span
+		Token Token
+	}
+
+	// Ident is a name in expression or declaration position
+	Ident struct {
+		Span
+		Name    string
+		Binding *Binding
+		Scope   *Scope // scope the name occurs in
+		Seq     int    // position in scope resolution order
+	}
+
+	FunctionExpr struct {
+		Span
+		Params   []*Ident
+		IsVararg bool
+		Body     *Block
+	}
+
+	TableExpr struct {
+		Span
+		Fields []*TableField
+	}
+
+	BinaryExpr struct {
+		Span
+		Op string
+		X  Expr
+		Y  Expr
+	}
+
+	UnaryExpr struct {
+		Span
+		Op string
+		X  Expr
+	}
+
+	ParenExpr struct {
+		Span
+		X Expr
+	}
+
+	// IndexExpr is X[Key]
+	IndexExpr struct {
+		Span
+		X   Expr
+		Key Expr
+	}
+
+	// FieldExpr is X.Name
+	FieldExpr struct {
+		Span
+		X    Expr
+		Name Token
+	}
+
+	CallExpr struct {
+		Span
+		Fn   Expr
+		Args []Expr
+	}
+
+	// MethodCallExpr is Recv:Name(Args)
+	MethodCallExpr struct {
+		Span
+		Recv Expr
+		Name Token
+		Args []Expr
+	}
+
+	// IfExpr is a Luau if-then-else expression
+	IfExpr struct {
+		Span
+		Conds []Expr // if and elseif conditions
+		Thens []Expr
+		Else  Expr
+	}
+
+	// TypeAssertExpr is a Luau `X :: Type` assertion; the type is not modelled
+	TypeAssertExpr struct {
+		Span
+		X Expr
+	}
+)
+
+// TableField kinds
+const (
+	ListField  = iota // value
+	NamedField        // name = value
+	KeyedField        // [key] = value
+)
+
+// TableField is a single table constructor entry
+type TableField struct {
+	Span
+	Kind  int
+	Name  Token // NamedField key
+	Key   Expr  // KeyedField key
+	Value Expr
+}
+
+func (*NilExpr) exprNode()        {}
+func (*TrueExpr) exprNode()       {}
+func (*FalseExpr) exprNode()      {}
+func (*VarargExpr) exprNode()     {}
+func (*NumberExpr) exprNode()     {}
+func (*StringExpr) exprNode()     {}
+func (*Ident) exprNode()          {}
+func (*FunctionExpr) exprNode()   {}
+func (*TableExpr) exprNode()      {}
+func (*BinaryExpr) exprNode()     {}
+func (*UnaryExpr) exprNode()      {}
+func (*ParenExpr) exprNode()      {}
+func (*IndexExpr) exprNode()      {}
+func (*FieldExpr) exprNode()      {}
+func (*CallExpr) exprNode()       {}
+func (*MethodCallExpr) exprNode() {}
+func (*IfExpr) exprNode()         {}
+func (*TypeAssertExpr) exprNode() {}
+
+// Statements
+
+type (
+	// LocalStmt is `local a, b <const> = x, y`
+	LocalStmt struct {
+		Span
+		Names   []*Ident
+		Attribs []string // "" when absent
+		Values  []Expr
+	}
+
+	// AssignStmt is `a, b = x, y` or a Luau compound assignment (Op "+=", ...)
+	AssignStmt struct {
+		Span
+		Targets []Expr
+		Op      string
+		Values  []Expr
+	}
+
+	// CallStmt is a function or method call used as a statement
+	CallStmt struct {
+		Span
+		Call Expr
+	}
+
+	DoStmt struct {
+		Span
+		Body *Block
+	}
+
+	WhileStmt struct {
+		Span
+		Cond Expr
+		Body *Block
+	}
+
+	RepeatStmt struct {
+		Span
+		Body *Block
+		Cond Expr
+	}
+
+	IfStmt struct {
+		Span
+		Conds  []Expr // if and elseif conditions
+		Blocks []*Block
+		Else   *Block
+	}
+
+	NumericForStmt struct {
+		Span
+		Var   *Ident
+		Start Expr
+		Limit Expr
+		Step  Expr
+		Body  *Block
+	}
+
+	GenericForStmt struct {
+		Span
+		Names []*Ident
+		Exprs []Expr
+		Body  *Block
+	}
+
+	// FunctionStmt is `function a.b.c:m() end`; Target is an Ident or FieldExpr chain
+	FunctionStmt struct {
+		Span
+		Target Expr
+		Method *Token
+		Func   *FunctionExpr
+	}
+
+	LocalFunctionStmt struct {
+		Span
+		Name *Ident
+		Func *FunctionExpr
+	}
+
+	ReturnStmt struct {
+		Span
+		Values []Expr
+	}
+
+	BreakStmt    struct{ Span }
+	ContinueStmt struct{ Span }
+
+	GotoStmt struct {
+		Span
+		Label string
+	}
+
+	LabelStmt struct {
+		Span
+		Name string
+	}
+
+	// TypeStmt is a Luau type alias; its definition is not modelled
+	TypeStmt struct {
+		Span
+		Name string
+	}
+)
+
+func (*LocalStmt) stmtNode()         {}
+func (*AssignStmt) stmtNode()        {}
+func (*CallStmt) stmtNode()          {}
+func (*DoStmt) stmtNode()            {}
+func (*WhileStmt) stmtNode()         {}
+func (*RepeatStmt) stmtNode()        {}
+func (*IfStmt) stmtNode()            {}
+func (*NumericForStmt) stmtNode()    {}
+func (*GenericForStmt) stmtNode()    {}
+func (*FunctionStmt) stmtNode()      {}
+func (*LocalFunctionStmt) stmtNode() {}
+func (*ReturnStmt) stmtNode()        {}
+func (*BreakStmt) stmtNode()         {}
+func (*ContinueStmt) stmtNode()      {}
+func (*GotoStmt) stmtNode()          {}
+func (*LabelStmt) stmtNode()         {}
+func (*TypeStmt) stmtNode()          {}
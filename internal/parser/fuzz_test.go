@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+// fuzzSeeds are sources mixing Luau syntax with non-ASCII text in strings and
+// comments, where byte and rune offsets differ
+var fuzzSeeds = []string{
+	"local x = 1\nprint(x)\n",
+	"local s = \"héllo wörld\" -- ünïcode comment\nreturn s\n",
+	"local t = { name = 'l\\'été', [\"ключ\"] = `{1} 日本語` }\n",
+	"--[==[ 多行\n注释 ]==]\nlocal a = [[\n€ ]] .. [=[ ]] ]=]\n",
+	"local function f(a: string?): number return #a end\ntype T = { x: number }\n",
+	"print(\"\\u{1F600}\\z\n   \\x41\\65\")\n",
+	"local π = 3.14\n",
+	"#!/usr/bin/lua\nreturn 0x1p4 + 1e-3 + .5\n",
+	"x += 1; y //= 2; z ..= \"ß\"\n",
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		tokens, err := Tokenize(src)
+		if err != nil {
+			return
+		}
+		end, line := 0, 1
+		for _, tok := range tokens {
+			if tok.Start < end || tok.End < tok.Start || tok.End > len(src) {
+				t.Fatalf("token %q at %d-%d overlaps the previous one ending at %d", tok.Value, tok.Start, tok.End, end)
+			}
+			if src[tok.Start:tok.End] != tok.Value {
+				t.Fatalf("token %q is not the source %q at %d-%d", tok.Value, src[tok.Start:tok.End], tok.Start, tok.End)
+			}
+			if tok.Line < line {
+				t.Fatalf("token %q on line %d follows one on line %d", tok.Value, tok.Line, line)
+			}
+			// Tokens never split a UTF-8 sequence
+			if tok.Start < len(src) && !utf8.RuneStart(src[tok.Start]) {
+				t.Fatalf("token %q starts inside a UTF-8 sequence", tok.Value)
+			}
+			end, line = tok.End, tok.Line
+		}
+		if last := tokens[len(tokens)-1]; last.Kind != EOF {
+			t.Fatalf("tokens end with %v, not EOF", last.Kind)
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		chunk, err := Parse(src)
+		if err != nil {
+			return
+		}
+		Walk(chunk, func(Node) bool { return true })
+	})
+}
@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind identifies the kind of a lexical token
+type TokenKind int
+
+const (
+	EOF TokenKind = iota
+	Name
+	Keyword
+	Number
+	String
+	Comment
+	Symbol
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case EOF:
+		return "EOF"
+	case Name:
+		return "Name"
+	case Keyword:
+		return "Keyword"
+	case Number:
+		return "Number"
+	case String:
+		return "String"
+	case Comment:
+		return "Comment"
+	case Symbol:
+		return "Symbol"
+	}
+	return "Unknown"
+}
+
+// Token is a lexical token. Value is the raw source text, so src[Start:End] == Value.
+type Token struct {
+	Kind  TokenKind
+	Value string
+	Start int // byte offset of the first character
+	End   int // byte offset just past the last character
+	Line  int // 1-based line of the first character
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true,
+	"or": true, "repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+// Longest symbols first so greedy matching picks "..." over ".." over "."
+var symbols = []string{
+	"...", "..=", "//=",
+	"==", "~=", "<=", ">=", "..", "::", "//", "<<", ">>", "->",
+	"+=", "-=", "*=", "/=", "%=", "^=",
+	"+", "-", "*", "/", "%", "^", "#", "&", "~", "|", "<", ">", "=",
+	"(", ")", "{", "}", "[", "]", ";", ":", ",", ".", "?",
+}
+
+// IsKeyword reports whether name is a reserved word
+func IsKeyword(name string) bool {
+	return keywords[name]
+}
+
+// Tokenize splits Lua (and Luau) source into tokens, including comments.
+// Whitespace is skipped; the returned slice always ends with an EOF token.
+func Tokenize(src string) ([]Token, error) {
+	l := &lexer{src: src, line: 1}
+	var tokens []Token
+	// A leading #! line is skipped by the Lua interpreter; keep it as a comment
+	if strings.HasPrefix(src, "#") {
+		for l.pos < len(src) && src[l.pos] != '\n' {
+			l.pos++
+		}
+		tokens = append(tokens, Token{Kind: Comment, Value: src[:l.pos], End: l.pos, Line: 1})
+	}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Kind == EOF {
+			return tokens, nil
+		}
+	}
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) next() (Token, error) {
+	l.skipWhitespace()
+	start, line := l.pos, l.line
+	if l.pos >= len(l.src) {
+		return Token{Kind: EOF, Start: start, End: start, Line: line}, nil
+	}
+
+	kind, err := l.scan()
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Kind: kind, Value: l.src[start:l.pos], Start: start, End: l.pos, Line: line}, nil
+}
+
+func (l *lexer) scan() (TokenKind, error) {
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "--"):
+		l.pos += 2
+		if level := l.longBracketLevel(); level >= 0 {
+			return Comment, l.skipLongBracket(level, "comment")
+		}
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.pos++
+		}
+		return Comment, nil
+	case isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		if keywords[l.src[start:l.pos]] {
+			return Keyword, nil
+		}
+		return Name, nil
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		l.scanNumber()
+		return Number, nil
+	case c == '"' || c == '\'' || c == '`':
+		return String, l.scanQuoted(c)
+	case c == '[':
+		if level := l.longBracketLevel(); level >= 0 {
+			return String, l.skipLongBracket(level, "string")
+		}
+	}
+
+	for _, sym := range symbols {
+		if strings.HasPrefix(l.src[l.pos:], sym) {
+			l.pos += len(sym)
+			return Symbol, nil
+		}
+	}
+	if c >= utf8.RuneSelf {
+		// Names are ASCII; name the whole character, not its first byte
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if r == utf8.RuneError && size == 1 {
+			return 0, l.errorf("invalid UTF-8 byte 0x%02x outside a string or comment", c)
+		}
+		return 0, l.errorf("unexpected character %q (%U) outside a string or comment", r, r)
+	}
+	return 0, l.errorf("unexpected character %q", c)
+}
+
+func (l *lexer) skipWhitespace() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\n':
+			l.line++
+		case ' ', '\t', '\r', '\f', '\v':
+		default:
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) scanNumber() {
+	if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+		l.pos += 2
+		for l.pos < len(l.src) {
+			c := l.src[l.pos]
+			if isHexDigit(c) || c == '.' || c == '_' {
+				l.pos++
+			} else if (c == 'p' || c == 'P') && l.pos+1 < len(l.src) {
+				l.pos++
+				if l.src[l.pos] == '+' || l.src[l.pos] == '-' {
+					l.pos++
+				}
+			} else {
+				return
+			}
+		}
+		return
+	}
+	if strings.HasPrefix(l.src[l.pos:], "0b") || strings.HasPrefix(l.src[l.pos:], "0B") {
+		l.pos += 2
+	}
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if isDigit(c) || c == '.' || c == '_' {
+			l.pos++
+		} else if c == 'e' || c == 'E' {
+			l.pos++
+			if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (l *lexer) scanQuoted(quote byte) error {
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == quote:
+			l.pos++
+			return nil
+		case c == '\\':
+			l.pos++
+			if l.pos < len(l.src) {
+				if l.src[l.pos] == '\n' {
+					l.line++
+				} else if l.src[l.pos] == 'z' {
+					// \z skips the following whitespace, including newlines
+					l.pos++
+					for l.pos < len(l.src) && strings.IndexByte(" \t\r\n\f\v", l.src[l.pos]) >= 0 {
+						if l.src[l.pos] == '\n' {
+							l.line++
+						}
+						l.pos++
+					}
+					continue
+				}
+				l.pos++
+			}
+		case c == '\n' && quote != '`':
+			return l.errorf("unfinished string")
+		default:
+			if c == '\n' {
+				l.line++
+			}
+			l.pos++
+		}
+	}
+	return l.errorf("unfinished string")
+}
+
+// longBracketLevel returns the level of a long bracket opening at the current
+// position ([[ is 0, [=[ is 1, ...) or -1 if there is none
+func (l *lexer) longBracketLevel() int {
+	if l.pos >= len(l.src) || l.src[l.pos] != '[' {
+		return -1
+	}
+	i := l.pos + 1
+	for i < len(l.src) && l.src[i] == '=' {
+		i++
+	}
+	if i < len(l.src) && l.src[i] == '[' {
+		return i - l.pos - 1
+	}
+	return -1
+}
+
+func (l *lexer) skipLongBracket(level int, what string) error {
+	closing := "]" + strings.Repeat("=", level) + "]"
+	l.pos += level + 2
+	end := strings.Index(l.src[l.pos:], closing)
+	if end < 0 {
+		return l.errorf("unfinished long %s", what)
+	}
+	l.line += strings.Count(l.src[l.pos:l.pos+end], "\n")
+	l.pos += end + len(closing)
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
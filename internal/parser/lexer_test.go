@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	src := `local x = 0x1F + 3.5e-2 -- trailing
+--[==[ long
+comment ]==]
+s = [[long
+string]] .. 'a\'b' ... a.b:c(#t) ~= nil`
+
+	tokens, err := Tokenize(src)
+	require.NoError(t, err, "Tokenize should not fail")
+
+	type tok struct {
+		kind  TokenKind
+		value string
+		line  int
+	}
+	want := []tok{
+		{Keyword, "local", 1}, {Name, "x", 1}, {Symbol, "=", 1}, {Number, "0x1F", 1},
+		{Symbol, "+", 1}, {Number, "3.5e-2", 1}, {Comment, "-- trailing", 1},
+		{Comment, "--[==[ long\ncomment ]==]", 2},
+		{Name, "s", 4}, {Symbol, "=", 4}, {String, "[[long\nstring]]", 4},
+		{Symbol, "..", 5}, {String, `'a\'b'`, 5}, {Symbol, "...", 5},
+		{Name, "a", 5}, {Symbol, ".", 5}, {Name, "b", 5}, {Symbol, ":", 5}, {Name, "c", 5},
+		{Symbol, "(", 5}, {Symbol, "#", 5}, {Name, "t", 5}, {Symbol, ")", 5},
+		{Symbol, "~=", 5}, {Keyword, "nil", 5}, {EOF, "", 5},
+	}
+
+	got := make([]tok, len(tokens))
+	for i, tk := range tokens {
+		got[i] = tok{tk.Kind, tk.Value, tk.Line}
+		assert.Equal(t, tk.Value, src[tk.Start:tk.End], "token offsets should match its value")
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestTokenize_Luau(t *testing.T) {
+	tokens, err := Tokenize("x += 1 local s = `hi {name}` continue")
+	require.NoError(t, err, "Tokenize should not fail")
+
+	assert.Equal(t, "+=", tokens[1].Value)
+	assert.Equal(t, String, tokens[6].Kind)
+	assert.Equal(t, "`hi {name}`", tokens[6].Value)
+	assert.Equal(t, Name, tokens[7].Kind, "continue is contextual")
+}
+
+func TestTokenize_Shebang(t *testing.T) {
+	tokens, err := Tokenize("#!/usr/bin/env lua\nprint(1)")
+	require.NoError(t, err, "Tokenize should not fail")
+
+	assert.Equal(t, Comment, tokens[0].Kind)
+	assert.Equal(t, "#!/usr/bin/env lua", tokens[0].Value)
+	assert.Equal(t, "print", tokens[1].Value)
+}
+
+func TestTokenize_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"unfinished string", "local s = 'abc\nx = 1"},
+		{"unfinished long string", "local s = [[abc"},
+		{"unfinished long comment", "--[[ never closed"},
+		{"unexpected character", "local $ = 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Tokenize(tt.src)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTokenize_NonASCII(t *testing.T) {
+	src := "local s = \"héllo\" -- ünïcode\nlocal t = [[日本語]] .. `€`\n"
+	tokens, err := Tokenize(src)
+	require.NoError(t, err)
+	for _, tok := range tokens {
+		assert.Equal(t, src[tok.Start:tok.End], tok.Value)
+	}
+	assert.Equal(t, "\"héllo\"", tokens[3].Value)
+	assert.Equal(t, Comment, tokens[4].Kind)
+	assert.Equal(t, "[[日本語]]", tokens[8].Value)
+	assert.Equal(t, 2, tokens[8].Line)
+
+	_, err = Tokenize("local x = 1\nlocal π = 3.14")
+	assert.EqualError(t, err, "line 2: unexpected character 'π' (U+03C0) outside a string or comment")
+	_, err = Tokenize("local x\xe9 = 1")
+	assert.EqualError(t, err, "line 1: invalid UTF-8 byte 0xe9 outside a string or comment")
+}
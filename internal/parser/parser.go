@@ -0,0 +1,1132 @@
+package parser
+
+import "fmt"
+
+// Parse parses Lua 5.1-5.4 source, plus the common Luau extensions (compound
+// assignment, continue, if-expressions, string interpolation and type
+// annotations, which are skipped), and resolves every name to its binding.
+func Parse(src string) (*Chunk, error) {
+	tokens, err := Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		toks:    significant(tokens),
+		eof:     tokens[len(tokens)-1],
+		globals: make(map[string]*Binding),
+	}
+	chunk := &Chunk{Tokens: tokens}
+	chunk.Scope = p.openScope(true)
+	block, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if !p.at(EOF) {
+		return nil, p.errorf("'<eof>' expected")
+	}
+	p.closeScope(block.Span)
+	block.Scope = chunk.Scope
+
+	chunk.Span = Span{0, len(src)}
+	chunk.Block = block
+	chunk.Bindings = append(p.locals, p.globalOrder...)
+	return chunk, nil
+}
+
+type parser struct {
+	toks        []Token
+	eof         Token
+	i           int
+	scope       *Scope
+	seq         int
+	locals      []*Binding
+	globals     map[string]*Binding
+	globalOrder []*Binding
+}
+
+// binary operator priorities (left, right), as in the reference implementation
+var binaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"|": {4, 4}, "~": {5, 5}, "&": {6, 6}, "<<": {7, 7}, ">>": {7, 7},
+	"..": {9, 8}, "+": {10, 10}, "-": {10, 10},
+	"*": {11, 11}, "/": {11, 11}, "//": {11, 11}, "%": {11, 11},
+	"^": {14, 13},
+}
+
+const unaryPriority = 12
+
+var compoundOps = map[string]bool{"+=": true, "-=": true, "*=": true, "/=": true, "//=": true, "%=": true, "^=": true, "..=": true}
+
+// Token navigation
+
+func (p *parser) peek() Token {
+	return p.peekAt(0)
+}
+
+func (p *parser) peekAt(n int) Token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return p.eof
+}
+
+func (p *parser) at(kind TokenKind) bool {
+	return p.peek().Kind == kind
+}
+
+// is reports whether the current token is the keyword or symbol value
+func (p *parser) is(value string) bool {
+	t := p.peek()
+	return (t.Kind == Keyword || t.Kind == Symbol) && t.Value == value
+}
+
+func (p *parser) advance() Token {
+	t := p.peek()
+	if p.i < len(p.toks) {
+		p.i++
+	}
+	return t
+}
+
+// lastEnd returns the end offset of the previously consumed token
+func (p *parser) lastEnd() int {
+	if p.i == 0 {
+		return 0
+	}
+	return p.toks[p.i-1].End
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	near := t.Value
+	if t.Kind == EOF {
+		near = "<eof>"
+	}
+	return fmt.Errorf("line %d: %s near '%s'", t.Line, fmt.Sprintf(format, args...), near)
+}
+
+func (p *parser) expect(value string) (Token, error) {
+	if !p.is(value) {
+		return Token{}, p.errorf("'%s' expected", value)
+	}
+	return p.advance(), nil
+}
+
+func (p *parser) expectName() (Token, error) {
+	if !p.at(Name) {
+		return Token{}, p.errorf("<name> expected")
+	}
+	return p.advance(), nil
+}
+
+// Scopes
+
+func (p *parser) openScope(function bool) *Scope {
+	s := &Scope{Parent: p.scope, Function: function}
+	if p.scope != nil {
+		p.scope.Children = append(p.scope.Children, s)
+	}
+	p.scope = s
+	return s
+}
+
+func (p *parser) closeScope(span Span) {
+	p.scope.Span = span
+	p.scope = p.scope.Parent
+}
+
+func (p *parser) nextSeq() int {
+	p.seq++
+	return p.seq
+}
+
+// declare creates a local that becomes visible once activated
+func (p *parser) declare(tok Token, kind BindingKind) *Ident {
+	id := &Ident{Span: Span{tok.Start, tok.End}, Name: tok.Value, Scope: p.scope, Seq: p.nextSeq()}
+	b := &Binding{Name: tok.Value, Kind: kind, Decl: id, Idents: []*Ident{id}, Scope: p.scope, Seq: -1}
+	id.Binding = b
+	p.scope.Bindings = append(p.scope.Bindings, b)
+	p.locals = append(p.locals, b)
+	return id
+}
+
+func (p *parser) activate(ids ...*Ident) {
+	seq := p.nextSeq()
+	for _, id := range ids {
+		id.Binding.Seq = seq
+	}
+}
+
+// reference resolves a name in expression position
+func (p *parser) reference(tok Token) *Ident {
+	id := &Ident{Span: Span{tok.Start, tok.End}, Name: tok.Value, Scope: p.scope, Seq: p.nextSeq()}
+	b := p.scope.Lookup(tok.Value, id.Seq)
+	if b == nil {
+		b = p.globals[tok.Value]
+		if b == nil {
+			b = &Binding{Name: tok.Value, Kind: GlobalBinding, Seq: -1}
+			p.globals[tok.Value] = b
+			p.globalOrder = append(p.globalOrder, b)
+		}
+	}
+	b.Idents = append(b.Idents, id)
+	id.Binding = b
+	return id
+}
+
+// Blocks and statements
+
+func (p *parser) blockFollows() bool {
+	if p.at(EOF) {
+		return true
+	}
+	t := p.peek()
+	if t.Kind != Keyword {
+		return false
+	}
+	switch t.Value {
+	case "else", "elseif", "end", "until":
+		return true
+	}
+	return false
+}
+
+func (p *parser) block() (*Block, error) {
+	block := &Block{Scope: p.scope}
+	start := p.peek().Start
+	for !p.blockFollows() {
+		if p.is("return") {
+			stmt, err := p.returnStmt()
+			if err != nil {
+				return nil, err
+			}
+			block.Stmts = append(block.Stmts, stmt)
+			break
+		}
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if stmt != nil {
+			block.Stmts = append(block.Stmts, stmt)
+		}
+	}
+	block.Span = Span{start, p.lastEnd()}
+	if block.End < start {
+		block.End = start
+	}
+	return block, nil
+}
+
+// scopedBlock parses a block in a new scope, up to but not including its terminator
+func (p *parser) scopedBlock() (*Block, error) {
+	scope := p.openScope(false)
+	block, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	block.Scope = scope
+	p.closeScope(block.Span)
+	return block, nil
+}
+
+func (p *parser) statement() (Stmt, error) {
+	t := p.peek()
+	if t.Kind == Keyword {
+		switch t.Value {
+		case "if":
+			return p.ifStmt()
+		case "while":
+			return p.whileStmt()
+		case "do":
+			p.advance()
+			body, err := p.scopedBlock()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("end"); err != nil {
+				return nil, err
+			}
+			return &DoStmt{Span: Span{t.Start, p.lastEnd()}, Body: body}, nil
+		case "for":
+			return p.forStmt()
+		case "repeat":
+			return p.repeatStmt()
+		case "function":
+			return p.functionStmt()
+		case "local":
+			return p.localStmt()
+		case "break":
+			p.advance()
+			return &BreakStmt{Span{t.Start, t.End}}, nil
+		case "goto":
+			p.advance()
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			return &GotoStmt{Span: Span{t.Start, name.End}, Label: name.Value}, nil
+		}
+	}
+
+	switch {
+	case p.is(";"):
+		p.advance()
+		return nil, nil
+	case p.is("::"):
+		p.advance()
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		end, err := p.expect("::")
+		if err != nil {
+			return nil, err
+		}
+		return &LabelStmt{Span: Span{t.Start, end.End}, Name: name.Value}, nil
+	case t.Kind == Name && t.Value == "continue" && p.continueFollows():
+		p.advance()
+		return &ContinueStmt{Span{t.Start, t.End}}, nil
+	case t.Kind == Name && t.Value == "type" && p.peekAt(1).Kind == Name && (p.peekAt(2).Value == "=" || p.peekAt(2).Value == "<"):
+		return p.typeStmt(t.Start)
+	case t.Kind == Name && t.Value == "export" && p.peekAt(1).Value == "type" && p.peekAt(2).Kind == Name:
+		p.advance()
+		return p.typeStmt(t.Start)
+	}
+
+	return p.exprStmt()
+}
+
+// continueFollows reports whether a `continue` name is the Luau statement
+// rather than a variable, which it can still be in plain Lua
+func (p *parser) continueFollows() bool {
+	next := p.peekAt(1)
+	switch {
+	case next.Kind == String:
+		return false
+	case next.Kind == Symbol:
+		switch next.Value {
+		case "=", ",", "(", "{", ".", "[", ":":
+			return false
+		}
+		return !compoundOps[next.Value]
+	}
+	return true
+}
+
+func (p *parser) ifStmt() (Stmt, error) {
+	start := p.advance().Start
+	stmt := &IfStmt{}
+	for {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.scopedBlock()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Conds = append(stmt.Conds, cond)
+		stmt.Blocks = append(stmt.Blocks, body)
+		if !p.is("elseif") {
+			break
+		}
+		p.advance()
+	}
+	if p.is("else") {
+		p.advance()
+		body, err := p.scopedBlock()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Else = body
+	}
+	if _, err := p.expect("end"); err != nil {
+		return nil, err
+	}
+	stmt.Span = Span{start, p.lastEnd()}
+	return stmt, nil
+}
+
+func (p *parser) whileStmt() (Stmt, error) {
+	start := p.advance().Start
+	cond, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.scopedBlock()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("end"); err != nil {
+		return nil, err
+	}
+	return &WhileStmt{Span: Span{start, p.lastEnd()}, Cond: cond, Body: body}, nil
+}
+
+func (p *parser) repeatStmt() (Stmt, error) {
+	start := p.advance().Start
+	// The condition can see the body's locals, so it is parsed inside its scope
+	scope := p.openScope(false)
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	body.Scope = scope
+	if _, err := p.expect("until"); err != nil {
+		return nil, err
+	}
+	cond, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.closeScope(Span{body.Start, p.lastEnd()})
+	return &RepeatStmt{Span: Span{start, p.lastEnd()}, Body: body, Cond: cond}, nil
+}
+
+func (p *parser) forStmt() (Stmt, error) {
+	start := p.advance().Start
+	first, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipTypeAnnotation(); err != nil {
+		return nil, err
+	}
+
+	if p.is("=") {
+		p.advance()
+		stmt := &NumericForStmt{}
+		if stmt.Start, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if stmt.Limit, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if p.is(",") {
+			p.advance()
+			if stmt.Step, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.expect("do"); err != nil {
+			return nil, err
+		}
+		scope := p.openScope(false)
+		stmt.Var = p.declare(first, ForBinding)
+		p.activate(stmt.Var)
+		if stmt.Body, err = p.block(); err != nil {
+			return nil, err
+		}
+		stmt.Body.Scope = scope
+		p.closeScope(stmt.Body.Span)
+		if _, err := p.expect("end"); err != nil {
+			return nil, err
+		}
+		stmt.Span = Span{start, p.lastEnd()}
+		return stmt, nil
+	}
+
+	names := []Token{first}
+	for p.is(",") {
+		p.advance()
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skipTypeAnnotation(); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if _, err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	stmt := &GenericForStmt{}
+	if stmt.Exprs, err = p.exprList(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	scope := p.openScope(false)
+	for _, name := range names {
+		stmt.Names = append(stmt.Names, p.declare(name, ForBinding))
+	}
+	p.activate(stmt.Names...)
+	if stmt.Body, err = p.block(); err != nil {
+		return nil, err
+	}
+	stmt.Body.Scope = scope
+	p.closeScope(stmt.Body.Span)
+	if _, err := p.expect("end"); err != nil {
+		return nil, err
+	}
+	stmt.Span = Span{start, p.lastEnd()}
+	return stmt, nil
+}
+
+func (p *parser) functionStmt() (Stmt, error) {
+	start := p.advance().Start
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	var target Expr = p.reference(name)
+	for p.is(".") {
+		p.advance()
+		field, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		target = &FieldExpr{Span: Span{start, field.End}, X: target, Name: field}
+	}
+	// Targets start at the name, not at the function keyword
+	if fe, ok := target.(*FieldExpr); ok {
+		setFieldStart(fe, name.Start)
+	}
+
+	stmt := &FunctionStmt{Target: target}
+	if p.is(":") {
+		p.advance()
+		method, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Method = &method
+	}
+	if stmt.Func, err = p.functionBody(start, stmt.Method != nil); err != nil {
+		return nil, err
+	}
+	stmt.Span = Span{start, p.lastEnd()}
+	return stmt, nil
+}
+
+func setFieldStart(fe *FieldExpr, start int) {
+	fe.Start = start
+	if inner, ok := fe.X.(*FieldExpr); ok {
+		setFieldStart(inner, start)
+	}
+}
+
+func (p *parser) localStmt() (Stmt, error) {
+	start := p.advance().Start
+	if p.is("function") {
+		p.advance()
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		id := p.declare(name, LocalBinding)
+		p.activate(id)
+		fn, err := p.functionBody(start, false)
+		if err != nil {
+			return nil, err
+		}
+		return &LocalFunctionStmt{Span: Span{start, p.lastEnd()}, Name: id, Func: fn}, nil
+	}
+
+	stmt := &LocalStmt{}
+	var names []Token
+	for {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		attrib := ""
+		if p.is("<") {
+			p.advance()
+			a, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(">"); err != nil {
+				return nil, err
+			}
+			attrib = a.Value
+		}
+		stmt.Attribs = append(stmt.Attribs, attrib)
+		if err := p.skipTypeAnnotation(); err != nil {
+			return nil, err
+		}
+		if !p.is(",") {
+			break
+		}
+		p.advance()
+	}
+
+	// Declared before the values are parsed, activated after: in `local x = x`
+	// the right-hand x is the outer one
+	for _, name := range names {
+		stmt.Names = append(stmt.Names, p.declare(name, LocalBinding))
+	}
+	if p.is("=") {
+		p.advance()
+		values, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Values = values
+	}
+	p.activate(stmt.Names...)
+	stmt.Span = Span{start, p.lastEnd()}
+	return stmt, nil
+}
+
+func (p *parser) returnStmt() (Stmt, error) {
+	start := p.advance().Start
+	stmt := &ReturnStmt{}
+	if !p.blockFollows() && !p.is(";") {
+		values, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Values = values
+	}
+	if p.is(";") {
+		p.advance()
+	}
+	stmt.Span = Span{start, p.lastEnd()}
+	return stmt, nil
+}
+
+func (p *parser) typeStmt(start int) (Stmt, error) {
+	p.advance() // type
+	name := p.advance()
+	if p.is("<") {
+		if err := p.skipBalanced("<", ">"); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if err := p.skipType(); err != nil {
+		return nil, err
+	}
+	return &TypeStmt{Span: Span{start, p.lastEnd()}, Name: name.Value}, nil
+}
+
+func (p *parser) exprStmt() (Stmt, error) {
+	start := p.peek().Start
+	target, err := p.suffixedExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.is("=") || p.is(",") {
+		stmt := &AssignStmt{Targets: []Expr{target}, Op: "="}
+		for p.is(",") {
+			p.advance()
+			t, err := p.suffixedExpr()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Targets = append(stmt.Targets, t)
+		}
+		for _, t := range stmt.Targets {
+			if !assignable(t) {
+				return nil, p.errorf("syntax error")
+			}
+		}
+		if _, err := p.expect("="); err != nil {
+			return nil, err
+		}
+		if stmt.Values, err = p.exprList(); err != nil {
+			return nil, err
+		}
+		stmt.Span = Span{start, p.lastEnd()}
+		return stmt, nil
+	}
+
+	if p.at(Symbol) && compoundOps[p.peek().Value] {
+		if !assignable(target) {
+			return nil, p.errorf("syntax error")
+		}
+		op := p.advance().Value
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &AssignStmt{Span: Span{start, p.lastEnd()}, Targets: []Expr{target}, Op: op, Values: []Expr{value}}, nil
+	}
+
+	switch target.(type) {
+	case *CallExpr, *MethodCallExpr:
+		return &CallStmt{Span: Span{start, p.lastEnd()}, Call: target}, nil
+	}
+	return nil, p.errorf("syntax error")
+}
+
+func assignable(e Expr) bool {
+	switch e.(type) {
+	case *Ident, *FieldExpr, *IndexExpr:
+		return true
+	}
+	return false
+}
+
+// Expressions
+
+func (p *parser) exprList() ([]Expr, error) {
+	var list []Expr
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if !p.is(",") {
+			return list, nil
+		}
+		p.advance()
+	}
+}
+
+func (p *parser) expr() (Expr, error) {
+	return p.subExpr(0)
+}
+
+func (p *parser) subExpr(limit int) (Expr, error) {
+	var left Expr
+	t := p.peek()
+	if p.is("not") || p.is("-") || p.is("#") || p.is("~") {
+		p.advance()
+		x, err := p.subExpr(unaryPriority)
+		if err != nil {
+			return nil, err
+		}
+		left = &UnaryExpr{Span: Span{t.Start, p.lastEnd()}, Op: t.Value, X: x}
+	} else {
+		var err error
+		if left, err = p.simpleExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		op := p.peek()
+		prio, ok := binaryPriority[op.Value]
+		if !ok || (op.Kind != Symbol && op.Kind != Keyword) || prio[0] <= limit {
+			return left, nil
+		}
+		p.advance()
+		right, err := p.subExpr(prio[1])
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Span: Span{left.Range().Start, p.lastEnd()}, Op: op.Value, X: left, Y: right}
+	}
+}
+
+func (p *parser) simpleExpr() (Expr, error) {
+	t := p.peek()
+	var e Expr
+	switch {
+	case t.Kind == Number:
+		p.advance()
+		e = &NumberExpr{Span: Span{t.Start, t.End}, Value: t.Value}
+	case t.Kind == String:
+		p.advance()
+		e = &StringExpr{Span: Span{t.Start, t.End}, Token: t}
+	case p.is("nil"):
+		p.advance()
+		e = &NilExpr{Span{t.Start, t.End}}
+	case p.is("true"):
+		p.advance()
+		e = &TrueExpr{Span{t.Start, t.End}}
+	case p.is("false"):
+		p.advance()
+		e = &FalseExpr{Span{t.Start, t.End}}
+	case p.is("..."):
+		p.advance()
+		e = &VarargExpr{Span{t.Start, t.End}}
+	case p.is("{"):
+		table, err := p.tableExpr()
+		if err != nil {
+			return nil, err
+		}
+		e = table
+	case p.is("function"):
+		p.advance()
+		fn, err := p.functionBody(t.Start, false)
+		if err != nil {
+			return nil, err
+		}
+		e = fn
+	case p.is("if"):
+		ifExpr, err := p.ifExpr()
+		if err != nil {
+			return nil, err
+		}
+		e = ifExpr
+	default:
+		var err error
+		if e, err = p.suffixedExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Luau type assertion; `::name::` after an expression is a label instead
+	if p.is("::") && p.peekAt(2).Value != "::" {
+		p.advance()
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+		e = &TypeAssertExpr{Span: Span{t.Start, p.lastEnd()}, X: e}
+	}
+	return e, nil
+}
+
+func (p *parser) primaryExpr() (Expr, error) {
+	t := p.peek()
+	switch {
+	case t.Kind == Name:
+		p.advance()
+		return p.reference(t), nil
+	case p.is("("):
+		p.advance()
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &ParenExpr{Span: Span{t.Start, p.lastEnd()}, X: x}, nil
+	}
+	return nil, p.errorf("unexpected symbol")
+}
+
+func (p *parser) suffixedExpr() (Expr, error) {
+	start := p.peek().Start
+	e, err := p.primaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("."):
+			p.advance()
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			e = &FieldExpr{Span: Span{start, name.End}, X: e, Name: name}
+		case p.is("["):
+			p.advance()
+			key, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			e = &IndexExpr{Span: Span{start, p.lastEnd()}, X: e, Key: key}
+		case p.is(":") && p.peekAt(1).Kind == Name:
+			p.advance()
+			name := p.advance()
+			args, err := p.callArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &MethodCallExpr{Span: Span{start, p.lastEnd()}, Recv: e, Name: name, Args: args}
+		case p.is("(") || p.is("{") || p.at(String):
+			args, err := p.callArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &CallExpr{Span: Span{start, p.lastEnd()}, Fn: e, Args: args}
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (p *parser) callArgs() ([]Expr, error) {
+	t := p.peek()
+	switch {
+	case t.Kind == String:
+		p.advance()
+		return []Expr{&StringExpr{Span: Span{t.Start, t.End}, Token: t}}, nil
+	case p.is("{"):
+		table, err := p.tableExpr()
+		if err != nil {
+			return nil, err
+		}
+		return []Expr{table}, nil
+	case p.is("("):
+		p.advance()
+		if p.is(")") {
+			p.advance()
+			return nil, nil
+		}
+		args, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+	return nil, p.errorf("function arguments expected")
+}
+
+func (p *parser) tableExpr() (*TableExpr, error) {
+	start := p.advance().Start
+	table := &TableExpr{}
+	for !p.is("}") {
+		fieldStart := p.peek().Start
+		field := &TableField{}
+		switch {
+		case p.is("["):
+			p.advance()
+			key, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("="); err != nil {
+				return nil, err
+			}
+			field.Kind, field.Key = KeyedField, key
+		case p.at(Name) && p.peekAt(1).Value == "=" && p.peekAt(1).Kind == Symbol:
+			field.Kind, field.Name = NamedField, p.advance()
+			p.advance()
+		default:
+			field.Kind = ListField
+		}
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		field.Value = value
+		field.Span = Span{fieldStart, p.lastEnd()}
+		table.Fields = append(table.Fields, field)
+
+		if !p.is(",") && !p.is(";") {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	table.Span = Span{start, p.lastEnd()}
+	return table, nil
+}
+
+// functionBody parses generics, parameters, return type and body after the
+// function name; start is the offset of the function keyword
+func (p *parser) functionBody(start int, method bool) (*FunctionExpr, error) {
+	if p.is("<") {
+		if err := p.skipBalanced("<", ">"); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	scope := p.openScope(true)
+	fn := &FunctionExpr{}
+	if method {
+		self := &Binding{Name: "self", Kind: ParamBinding, Scope: scope, Seq: -1}
+		scope.Bindings = append(scope.Bindings, self)
+		p.locals = append(p.locals, self)
+		self.Seq = p.nextSeq()
+	}
+	for !p.is(")") {
+		if p.is("...") {
+			p.advance()
+			fn.IsVararg = true
+			if err := p.skipTypeAnnotation(); err != nil {
+				return nil, err
+			}
+			break
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skipTypeAnnotation(); err != nil {
+			return nil, err
+		}
+		fn.Params = append(fn.Params, p.declare(name, ParamBinding))
+		if !p.is(",") {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	p.activate(fn.Params...)
+	if err := p.skipTypeAnnotation(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	body.Scope = scope
+	if _, err := p.expect("end"); err != nil {
+		return nil, err
+	}
+	p.closeScope(Span{start, p.lastEnd()})
+	fn.Body = body
+	fn.Span = Span{start, p.lastEnd()}
+	return fn, nil
+}
+
+func (p *parser) ifExpr() (Expr, error) {
+	start := p.advance().Start
+	e := &IfExpr{}
+	for {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		then, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		e.Conds = append(e.Conds, cond)
+		e.Thens = append(e.Thens, then)
+		if !p.is("elseif") {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect("else"); err != nil {
+		return nil, err
+	}
+	elseExpr, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	e.Else = elseExpr
+	e.Span = Span{start, p.lastEnd()}
+	return e, nil
+}
+
+// Luau types are skipped, not modelled
+
+func (p *parser) skipTypeAnnotation() error {
+	if !p.is(":") {
+		return nil
+	}
+	p.advance()
+	return p.skipType()
+}
+
+// skipType skips a type: simple types joined by | or &, each optionally followed by ?
+func (p *parser) skipType() error {
+	for {
+		if err := p.skipSimpleType(); err != nil {
+			return err
+		}
+		for p.is("?") {
+			p.advance()
+		}
+		if !p.is("|") && !p.is("&") {
+			return nil
+		}
+		p.advance()
+	}
+}
+
+func (p *parser) skipSimpleType() error {
+	switch {
+	case p.is("|") || p.is("&"):
+		p.advance()
+		return p.skipSimpleType()
+	case p.is("{"):
+		return p.skipBalanced("{", "}")
+	case p.is("("):
+		if err := p.skipBalanced("(", ")"); err != nil {
+			return err
+		}
+		if p.is("->") {
+			p.advance()
+			return p.skipType()
+		}
+		return nil
+	case p.is("<"):
+		// generic function type: <T>(T) -> T
+		if err := p.skipBalanced("<", ">"); err != nil {
+			return err
+		}
+		return p.skipSimpleType()
+	case p.is("..."):
+		p.advance()
+		if p.at(Name) {
+			return p.skipSimpleType()
+		}
+		return nil
+	case p.is("nil") || p.is("true") || p.is("false") || p.at(String):
+		p.advance()
+		return nil
+	case p.at(Name):
+		name := p.advance()
+		if name.Value == "typeof" && p.is("(") {
+			return p.skipBalanced("(", ")")
+		}
+		for p.is(".") {
+			p.advance()
+			if _, err := p.expectName(); err != nil {
+				return err
+			}
+		}
+		if p.is("<") {
+			return p.skipBalanced("<", ">")
+		}
+		return nil
+	}
+	return p.errorf("type expected")
+}
+
+// skipBalanced skips from an opening token to its matching closing token
+func (p *parser) skipBalanced(open, close string) error {
+	depth := 0
+	for !p.at(EOF) {
+		t := p.advance()
+		if t.Kind != Symbol {
+			continue
+		}
+		switch t.Value {
+		case open:
+			depth++
+		case close:
+			depth--
+		case ">>":
+			// nested generics close two levels at once: Map<K, Array<V>>
+			if close == ">" {
+				depth -= 2
+			}
+		}
+		if depth <= 0 {
+			return nil
+		}
+	}
+	return p.errorf("'%s' expected", close)
+}
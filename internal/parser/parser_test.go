@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Syntax(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"locals and calls", `local a, b = 1, "x" print(a, b) obj:method{1, 2} f"str"`},
+		{"functions", `local function f(a, ...) return ... end function M.a.b:c(x) return self end`},
+		{"control flow", `if a then elseif b then else end while x do break end repeat local y = 1 until y for i = 1, 10, 2 do end for k, v in pairs(t) do end do end`},
+		{"goto and labels", `::top:: goto top`},
+		{"operators", `x = -a ^ 2 .. b .. c or not d and e <= f // 2 | g & h ~ i << 1`},
+		{"tables", `t = {1, x = 2, [3] = 4; f = function() end,}`},
+		{"lua 5.4 attribs", `local x <const>, y <close> = 1, nil`},
+		{"luau compound and continue", `x += 1 s ..= "a" for i = 1, 3 do continue end`},
+		{"continue as a variable", `local continue = true continue = false print(continue)`},
+		{"luau if expression", `local v = if a then 1 elseif b then 2 else 3`},
+		{"luau types", `type Point = {x: number, y: number}
+export type List<T> = {T}
+local function f<T>(a: number, b: string?, ...: any): (number, string) return a :: any end
+local m: Map<string, Array<number>> = {}
+local cb: (number) -> () = nil`},
+		{"empty", ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"missing end", "if a then", "'end' expected near '<eof>'"},
+		{"bad assignment", "f() = 1", "syntax error"},
+		{"bare expression", "x", "syntax error"},
+		{"lexer error", "s = 'abc", "unfinished string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestParse_Spans(t *testing.T) {
+	src := "local x = 1\nprint(x + 2)\n"
+	chunk, err := Parse(src)
+	require.NoError(t, err)
+
+	require.Len(t, chunk.Block.Stmts, 2)
+	assert.Equal(t, "local x = 1", src[chunk.Block.Stmts[0].Range().Start:chunk.Block.Stmts[0].Range().End])
+	call := chunk.Block.Stmts[1].(*CallStmt).Call.(*CallExpr)
+	assert.Equal(t, "print(x + 2)", src[call.Start:call.End])
+	assert.Equal(t, "x + 2", src[call.Args[0].Range().Start:call.Args[0].Range().End])
+}
+
+func TestParse_Scopes(t *testing.T) {
+	src := `local x = 1
+local x = x + 1
+local function f(a)
+	local y = a + x
+	return function() return y + z end
+end
+for i = 1, 3 do print(i) end
+repeat local done = true until done
+print(i)`
+
+	chunk, err := Parse(src)
+	require.NoError(t, err)
+
+	bindings := map[string][]*Binding{}
+	for _, b := range chunk.Bindings {
+		bindings[b.Name] = append(bindings[b.Name], b)
+	}
+
+	require.Len(t, bindings["x"], 2)
+	assert.Len(t, bindings["x"][0].Idents, 2, "outer x: declaration and use in `local x = x + 1`")
+	assert.Len(t, bindings["x"][1].Idents, 2, "inner x: declaration and use in f")
+	assert.Equal(t, LocalBinding, bindings["x"][1].Kind)
+
+	require.Len(t, bindings["a"], 1)
+	assert.Equal(t, ParamBinding, bindings["a"][0].Kind)
+	assert.Len(t, bindings["y"][0].Idents, 2, "y is used as an upvalue")
+	assert.Len(t, bindings["done"][0].Idents, 2, "until sees the repeat body's locals")
+
+	require.Len(t, bindings["i"], 2, "loop i and global i")
+	assert.Equal(t, ForBinding, bindings["i"][0].Kind)
+	assert.True(t, bindings["i"][1].Global(), "i after the loop is global")
+
+	for _, name := range []string{"z", "print"} {
+		require.Len(t, bindings[name], 1)
+		assert.True(t, bindings[name][0].Global(), "%s should be global", name)
+	}
+}
+
+func TestScope_Lookup(t *testing.T) {
+	chunk, err := Parse(`local a = 1
+do
+	local b = a
+end
+local c = 2`)
+	require.NoError(t, err)
+
+	var useOfA *Ident
+	Walk(chunk, func(n Node) bool {
+		if id, ok := n.(*Ident); ok && id.Name == "a" && id.Binding.Decl != id {
+			useOfA = id
+		}
+		return true
+	})
+	require.NotNil(t, useOfA)
+
+	assert.NotNil(t, useOfA.Scope.Lookup("a", useOfA.Seq))
+	assert.Nil(t, useOfA.Scope.Lookup("c", useOfA.Seq), "c is declared later")
+	assert.Nil(t, useOfA.Scope.Lookup("b", useOfA.Seq), "b is not visible at its own initializer")
+}
+
+func TestWalk(t *testing.T) {
+	chunk, err := Parse(`local t = {f = function(x) return x end} t.f(1)`)
+	require.NoError(t, err)
+
+	var kinds []string
+	Walk(chunk, func(n Node) bool {
+		switch n.(type) {
+		case *FunctionExpr:
+			kinds = append(kinds, "function")
+			return false
+		case *CallExpr:
+			kinds = append(kinds, "call")
+		case *Ident:
+			kinds = append(kinds, n.(*Ident).Name)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"function", "t", "call", "t"}, kinds)
+}
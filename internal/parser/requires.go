@@ -0,0 +1,120 @@
+package parser
+
+import "strings"
+
+// RequireCall is a require call whose argument is a string literal
+type RequireCall struct {
+	Path  string // unquoted module path
+	Token Token  // the string literal argument
+}
+
+// FindRequires returns the require("...") and require "..." calls in tokens.
+// Field and method calls such as obj.require("x") are ignored, as are
+// arguments that are not plain quoted strings.
+func FindRequires(tokens []Token) []RequireCall {
+	code := significant(tokens)
+
+	var calls []RequireCall
+	for i, tok := range code {
+		if tok.Kind != Name || tok.Value != "require" {
+			continue
+		}
+		if i > 0 && code[i-1].Kind == Symbol && (code[i-1].Value == "." || code[i-1].Value == ":") {
+			continue
+		}
+
+		var arg Token
+		switch {
+		case i+1 < len(code) && code[i+1].Kind == String:
+			arg = code[i+1]
+		case i+3 < len(code) && code[i+1].Value == "(" && code[i+2].Kind == String && code[i+3].Value == ")":
+			arg = code[i+2]
+		default:
+			continue
+		}
+
+		if path, ok := arg.Unquote(); ok {
+			calls = append(calls, RequireCall{Path: path, Token: arg})
+		}
+	}
+	return calls
+}
+
+// FindDynamicRequires returns the require token of each call whose
+// argument is neither a string literal nor a name or dotted name such as
+// script.Parent.Module: require("mods." .. name) or
+// require(folder:FindFirstChild("x")), which name no module until they run.
+// Uses of require that are not calls, such as local req = require, are
+// ignored.
+func FindDynamicRequires(tokens []Token) []Token {
+	code := significant(tokens)
+
+	var calls []Token
+	for i, tok := range code {
+		if tok.Kind != Name || tok.Value != "require" {
+			continue
+		}
+		if i > 0 && code[i-1].Kind == Symbol && (code[i-1].Value == "." || code[i-1].Value == ":") {
+			continue
+		}
+		if i+1 >= len(code) || code[i+1].Kind == String {
+			continue
+		}
+		if code[i+1].Value != "(" {
+			if code[i+1].Value == "{" {
+				calls = append(calls, tok)
+			}
+			continue
+		}
+
+		j := i + 2
+		if j < len(code) && code[j].Kind == String {
+			if _, ok := code[j].Unquote(); ok && j+1 < len(code) && code[j+1].Value == ")" {
+				continue
+			}
+		} else {
+			for j < len(code) && code[j].Kind == Name {
+				if j+1 < len(code) && code[j+1].Value == "." {
+					j += 2
+					continue
+				}
+				j++
+				break
+			}
+			if j > i+2 && j < len(code) && code[j].Value == ")" {
+				continue
+			}
+		}
+		calls = append(calls, tok)
+	}
+	return calls
+}
+
+// Unquote returns the contents of a single- or double-quoted string token.
+// It reports false for other tokens, long strings and strings with escapes,
+// which cannot be rewritten without changing their meaning.
+func (t Token) Unquote() (string, bool) {
+	if t.Kind != String || len(t.Value) < 2 {
+		return "", false
+	}
+	quote := t.Value[0]
+	if quote != '"' && quote != '\'' {
+		return "", false
+	}
+	inner := t.Value[1 : len(t.Value)-1]
+	if strings.ContainsRune(inner, '\\') {
+		return "", false
+	}
+	return inner, true
+}
+
+// significant drops comments and the trailing EOF token
+func significant(tokens []Token) []Token {
+	code := make([]Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Kind != Comment && tok.Kind != EOF {
+			code = append(code, tok)
+		}
+	}
+	return code
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindRequires(t *testing.T) {
+	src := `local a = require("modules.a")
+local b = require 'b'
+-- local c = require("commented")
+local d = obj.require("field")
+local e = require(script.Parent.E)
+local f = require("esc\\aped")
+local g = require [[long]]
+local s = "require('in_string')"
+local h = require ( "spaced" )`
+
+	tokens, err := Tokenize(src)
+	require.NoError(t, err, "Tokenize should not fail")
+
+	var paths []string
+	for _, call := range FindRequires(tokens) {
+		paths = append(paths, call.Path)
+	}
+	assert.Equal(t, []string{"modules.a", "b", "spaced"}, paths)
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{`"abc"`, "abc", true},
+		{`'abc'`, "abc", true},
+		{`"a\"b"`, "", false},
+		{`[[abc]]`, "", false},
+		{"`abc`", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := Token{Kind: String, Value: tt.value}.Unquote()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFindDynamicRequires(t *testing.T) {
+	src := `local a = require("modules.a")
+local b = require 'b'
+local c = require(script.Parent.C)
+local d = require(name)
+local e = require("mods." .. name)
+local f = require(folder:FindFirstChild("F"))
+local g = require("esc\\aped")
+local h = obj.require(name)
+local req = require
+-- require(commented)
+local i = require {}
+local j = require(script.Parent["J"])`
+
+	tokens, err := Tokenize(src)
+	require.NoError(t, err, "Tokenize should not fail")
+
+	var lines []int
+	for _, tok := range FindDynamicRequires(tokens) {
+		lines = append(lines, tok.Line)
+	}
+	assert.Equal(t, []int{5, 6, 7, 11, 12}, lines, "bare names are read as module paths, as the bundler reads them")
+}
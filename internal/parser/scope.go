@@ -0,0 +1,62 @@
+package parser
+
+// BindingKind says how a name was introduced
+type BindingKind int
+
+const (
+	GlobalBinding BindingKind = iota
+	LocalBinding              // local x / local function f
+	ParamBinding              // function parameter, including implicit self
+	ForBinding                // numeric or generic for variable
+)
+
+// Binding is a variable: a local declaration or a global name
+type Binding struct {
+	Name   string
+	Kind   BindingKind
+	Decl   *Ident   // declaring identifier; nil for globals and implicit self
+	Idents []*Ident // every occurrence, including Decl
+	Scope  *Scope   // declaring scope; nil for globals
+	Seq    int      // resolution order at which the local becomes visible (-1 before)
+}
+
+// Scope is a lexical block
+type Scope struct {
+	Span
+	Parent   *Scope
+	Children []*Scope
+	Bindings []*Binding
+	Function bool // scope of a function body (or the chunk)
+}
+
+// Lookup returns the local named name visible at resolution order seq in s,
+// or nil if the name would refer to a global there
+func (s *Scope) Lookup(name string, seq int) *Binding {
+	for scope := s; scope != nil; scope = scope.Parent {
+		for i := len(scope.Bindings) - 1; i >= 0; i-- {
+			b := scope.Bindings[i]
+			if b.Name == name && b.Seq >= 0 && b.Seq < seq {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// VisibleAt reports whether local b is in scope at resolution order seq in s
+func (b *Binding) VisibleAt(s *Scope, seq int) bool {
+	if b.Scope == nil || b.Seq < 0 || b.Seq >= seq {
+		return false
+	}
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope == b.Scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Global reports whether b is a global name
+func (b *Binding) Global() bool {
+	return b.Kind == GlobalBinding
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StringCall is a call of a global function with string literal arguments,
+// such as L("Hello") or L("menu.title", "Main menu")
+type StringCall struct {
+	Args  []string // decoded arguments
+	Token Token    // the first argument
+}
+
+// FindStringCalls returns the calls of name whose arguments are all string
+// literals, as name("a", "b") or name "a". Field and method calls such as
+// ui.L("a") are not calls of name.
+func FindStringCalls(tokens []Token, name string) []StringCall {
+	code := significant(tokens)
+
+	var calls []StringCall
+	for i, tok := range code {
+		if tok.Kind != Name || tok.Value != name {
+			continue
+		}
+		if i > 0 && code[i-1].Kind == Symbol && (code[i-1].Value == "." || code[i-1].Value == ":") {
+			continue
+		}
+
+		var args []Token
+		switch {
+		case i+1 < len(code) && code[i+1].Kind == String:
+			args = code[i+1 : i+2]
+		case i+1 < len(code) && code[i+1].Value == "(":
+			for j := i + 2; j < len(code); j += 2 {
+				if code[j].Kind != String || j+1 >= len(code) {
+					args = nil
+					break
+				}
+				args = append(args, code[j])
+				if code[j+1].Value == ")" {
+					break
+				}
+				if code[j+1].Value != "," {
+					args = nil
+					break
+				}
+			}
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		call := StringCall{Token: args[0]}
+		for _, arg := range args {
+			value, ok := StringValue(arg)
+			if !ok {
+				call.Args = nil
+				break
+			}
+			call.Args = append(call.Args, value)
+		}
+		if call.Args != nil {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// StringValue decodes a string literal token: quoted strings with their
+// escape sequences, and long strings. It reports false for other tokens and
+// malformed escapes.
+func StringValue(t Token) (string, bool) {
+	if t.Kind != String || len(t.Value) < 2 {
+		return "", false
+	}
+	if t.Value[0] == '[' {
+		open := strings.IndexByte(t.Value[1:], '[') + 2
+		body := t.Value[open : len(t.Value)-open]
+		// A newline right after the opening bracket is not part of the string
+		if strings.HasPrefix(body, "\r\n") {
+			body = body[2:]
+		} else if strings.HasPrefix(body, "\n") {
+			body = body[1:]
+		}
+		return body, true
+	}
+	return unescape(t.Value[1 : len(t.Value)-1])
+}
+
+// unescape decodes the escape sequences of a quoted string's contents
+func unescape(s string) (string, bool) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, true
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", false
+		}
+		switch c := s[i]; c {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case 'a':
+			out.WriteByte('\a')
+		case 'b':
+			out.WriteByte('\b')
+		case 'f':
+			out.WriteByte('\f')
+		case 'v':
+			out.WriteByte('\v')
+		case '\\', '"', '\'', '\n':
+			out.WriteByte(c)
+		case 'x':
+			if i+2 >= len(s) {
+				return "", false
+			}
+			n, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", false
+			}
+			out.WriteByte(byte(n))
+			i += 2
+		case 'z':
+			for i+1 < len(s) && strings.IndexByte(" \t\r\n\f\v", s[i+1]) >= 0 {
+				i++
+			}
+		case 'u':
+			end := strings.IndexByte(s[i:], '}')
+			if i+1 >= len(s) || s[i+1] != '{' || end < 0 {
+				return "", false
+			}
+			n, err := strconv.ParseUint(s[i+2:i+end], 16, 32)
+			if err != nil {
+				return "", false
+			}
+			out.WriteString(string(utf8.AppendRune(nil, rune(n))))
+			i += end
+		default:
+			if c < '0' || c > '9' {
+				return "", false
+			}
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(s[i:j])
+			if err != nil || n > 255 {
+				return "", false
+			}
+			out.WriteByte(byte(n))
+			i = j - 1
+		}
+	}
+	return out.String(), true
+}
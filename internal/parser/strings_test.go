@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindStringCalls(t *testing.T) {
+	src := `label.Text = L("Hello, world!")
+title.Text = L("menu.title", "Main menu")
+print(L "Bye")
+-- L("commented")
+local x = ui.L("field")
+local y = L(name)
+local z = L("mixed", count)
+local s = "L('in_string')"
+local w = L ( 'spaced\n' )`
+
+	tokens, err := Tokenize(src)
+	require.NoError(t, err)
+
+	var args [][]string
+	for _, call := range FindStringCalls(tokens, "L") {
+		args = append(args, call.Args)
+	}
+	assert.Equal(t, [][]string{{"Hello, world!"}, {"menu.title", "Main menu"}, {"Bye"}, {"spaced\n"}}, args)
+
+	calls := FindStringCalls(tokens, "L")
+	assert.Equal(t, 2, calls[1].Token.Line)
+}
+
+func TestStringValue(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`"plain"`, "plain"},
+		{`'single "quotes"'`, `single "quotes"`},
+		{`"tab\tnew\nline \\ \" \'"`, "tab\tnew\nline \\ \" '"},
+		{`"\65\066\x43"`, "ABC"},
+		{`"caf\u{E9}"`, "café"},
+		{`"a\z
+		   b"`, "ab"},
+		{"[[\nlong string]]", "long string"},
+		{"[==[with ]] inside]==]", "with ]] inside"},
+	}
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.src)
+		require.NoError(t, err, tt.src)
+		got, ok := StringValue(tokens[0])
+		assert.True(t, ok, tt.src)
+		assert.Equal(t, tt.want, got, tt.src)
+	}
+
+	_, ok := StringValue(Token{Kind: String, Value: `"\q"`})
+	assert.False(t, ok, "unknown escapes should be rejected")
+	_, ok = StringValue(Token{Kind: Name, Value: "name"})
+	assert.False(t, ok)
+}
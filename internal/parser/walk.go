@@ -0,0 +1,114 @@
+package parser
+
+// Walk traverses the tree rooted at node in source order, calling fn for each
+// node. Children are skipped when fn returns false.
+func Walk(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Chunk:
+		Walk(n.Block, fn)
+	case *Block:
+		for _, s := range n.Stmts {
+			Walk(s, fn)
+		}
+
+	case *FunctionExpr:
+		for _, param := range n.Params {
+			Walk(param, fn)
+		}
+		Walk(n.Body, fn)
+	case *TableExpr:
+		for _, f := range n.Fields {
+			Walk(f, fn)
+		}
+	case *TableField:
+		if n.Key != nil {
+			Walk(n.Key, fn)
+		}
+		Walk(n.Value, fn)
+	case *BinaryExpr:
+		Walk(n.X, fn)
+		Walk(n.Y, fn)
+	case *UnaryExpr:
+		Walk(n.X, fn)
+	case *ParenExpr:
+		Walk(n.X, fn)
+	case *IndexExpr:
+		Walk(n.X, fn)
+		Walk(n.Key, fn)
+	case *FieldExpr:
+		Walk(n.X, fn)
+	case *CallExpr:
+		Walk(n.Fn, fn)
+		walkExprs(n.Args, fn)
+	case *MethodCallExpr:
+		Walk(n.Recv, fn)
+		walkExprs(n.Args, fn)
+	case *IfExpr:
+		for i := range n.Conds {
+			Walk(n.Conds[i], fn)
+			Walk(n.Thens[i], fn)
+		}
+		Walk(n.Else, fn)
+	case *TypeAssertExpr:
+		Walk(n.X, fn)
+
+	case *LocalStmt:
+		walkExprs(n.Values, fn)
+		for _, name := range n.Names {
+			Walk(name, fn)
+		}
+	case *AssignStmt:
+		walkExprs(n.Targets, fn)
+		walkExprs(n.Values, fn)
+	case *CallStmt:
+		Walk(n.Call, fn)
+	case *DoStmt:
+		Walk(n.Body, fn)
+	case *WhileStmt:
+		Walk(n.Cond, fn)
+		Walk(n.Body, fn)
+	case *RepeatStmt:
+		Walk(n.Body, fn)
+		Walk(n.Cond, fn)
+	case *IfStmt:
+		for i := range n.Conds {
+			Walk(n.Conds[i], fn)
+			Walk(n.Blocks[i], fn)
+		}
+		if n.Else != nil {
+			Walk(n.Else, fn)
+		}
+	case *NumericForStmt:
+		Walk(n.Start, fn)
+		Walk(n.Limit, fn)
+		if n.Step != nil {
+			Walk(n.Step, fn)
+		}
+		Walk(n.Var, fn)
+		Walk(n.Body, fn)
+	case *GenericForStmt:
+		walkExprs(n.Exprs, fn)
+		for _, name := range n.Names {
+			Walk(name, fn)
+		}
+		Walk(n.Body, fn)
+	case *FunctionStmt:
+		Walk(n.Target, fn)
+		Walk(n.Func, fn)
+	case *LocalFunctionStmt:
+		Walk(n.Name, fn)
+		Walk(n.Func, fn)
+	case *ReturnStmt:
+		walkExprs(n.Values, fn)
+	}
+}
+
+func walkExprs(exprs []Expr, fn func(Node) bool) {
+	for _, e := range exprs {
+		Walk(e, fn)
+	}
+}
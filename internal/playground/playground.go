@@ -0,0 +1,122 @@
+// Package playground bundles projects held in memory, for the WebAssembly
+// build running in a browser and the server's build API
+package playground
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/constt/lua-bundler/internal/bundler"
+	"github.com/constt/lua-bundler/internal/config"
+)
+
+// Options are the build settings a playground user can choose. Settings
+// left empty come from a lua-bundler.json next to the entry file.
+type Options struct {
+	Entry     string            `json:"entry"` // default main.lua
+	Release   bool              `json:"release"`
+	Target    string            `json:"target"`
+	Minify    *int              `json:"minify"` // nil follows release mode
+	Loader    string            `json:"loader"`
+	Namespace string            `json:"namespace"`
+	Defines   map[string]string `json:"defines"`
+}
+
+// Result is a built bundle
+type Result struct {
+	Bundle   string          `json:"bundle"`
+	Warnings []string        `json:"warnings"`
+	Modules  []string        `json:"modules"`
+	Timings  bundler.Timings `json:"timings"`
+}
+
+// Bundle bundles files, a map of slash-separated project paths to their
+// contents. Remote modules are downloaded without the disk cache.
+func Bundle(files map[string]string, opts Options) (*Result, error) {
+	if opts.Entry == "" {
+		opts.Entry = "main.lua"
+	}
+	if _, ok := files[opts.Entry]; !ok {
+		return nil, fmt.Errorf("entry file %s is not among the files", opts.Entry)
+	}
+
+	cfg := &config.Config{}
+	if raw, ok := files[path.Join(path.Dir(opts.Entry), config.FileName)]; ok {
+		if err := json.Unmarshal([]byte(raw), cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", config.FileName, err)
+		}
+	}
+	if opts.Target == "" {
+		opts.Target = cfg.Target
+	}
+	if opts.Loader == "" {
+		opts.Loader = cfg.Loader
+	}
+	defines := make(map[string]string, len(cfg.Defines)+len(opts.Defines))
+	for name, value := range cfg.Defines {
+		defines[name] = value
+	}
+	for name, value := range opts.Defines {
+		defines[name] = value
+	}
+
+	b, err := bundler.NewBundler(opts.Entry, false, false)
+	if err != nil {
+		return nil, err
+	}
+	b.SetFileSystem(bundler.MemoryFS(files))
+	if opts.Target != "" {
+		if err := b.SetTarget(opts.Target); err != nil {
+			return nil, err
+		}
+	}
+	b.SetVariants(cfg.Variants)
+	b.SetMirrors(cfg.Mirrors)
+	if err := b.SetNamespace(opts.Namespace); err != nil {
+		return nil, err
+	}
+	if err := b.SetDefines(defines); err != nil {
+		return nil, err
+	}
+	if err := b.SuppressWarnings(cfg.SuppressWarnings); err != nil {
+		return nil, err
+	}
+	if err := b.SetKeepPatterns(cfg.KeepPatterns); err != nil {
+		return nil, err
+	}
+	if err := b.SetLogShim(cfg.LogShim); err != nil {
+		return nil, err
+	}
+	if opts.Minify != nil {
+		if err := b.SetMinifyLevel(*opts.Minify); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.SetLoader(opts.Loader); err != nil {
+		return nil, err
+	}
+	b.SetNoMemoize(cfg.NoMemoize)
+	stubs := make(map[string]string, len(cfg.Stubs)+len(cfg.Omit))
+	for module, stub := range cfg.Stubs {
+		stubs[module] = path.Join(path.Dir(opts.Entry), stub)
+	}
+	for _, module := range cfg.Omit {
+		stubs[module] = ""
+	}
+	b.SetStubs(stubs)
+	if err := b.SetVersion(cfg.Version); err != nil {
+		return nil, err
+	}
+
+	built, err := b.BundleWithResult(opts.Release)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Bundle: built.Output, Warnings: built.Warnings, Modules: []string{}, Timings: built.Timings}
+	for _, module := range built.Modules {
+		result.Modules = append(result.Modules, module.Key)
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package playground
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	files := map[string]string{
+		"src/main.lua":             "local log = require(\"utils.log\")\nprint(\"debug\")\nlog.info(VERSION, GREETING)\n",
+		"src/utils/log.lua":        "local M = {}\nfunction M.info(...) end\nreturn M\n",
+		"src/utils/log.luajit.lua": "return { jit = true }\n",
+		"src/lua-bundler.json":     `{"version": "2.0.0", "defines": {"GREETING": "hi"}}`,
+	}
+
+	result, err := Bundle(files, Options{Entry: "src/main.lua"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"utils.log"}, result.Modules)
+	assert.Contains(t, result.Bundle, "function M.info(...) end")
+	assert.Contains(t, result.Bundle, `local GREETING = "hi"`)
+	assert.Contains(t, result.Bundle, `print("debug")`)
+	assert.Empty(t, result.Warnings)
+
+	result, err = Bundle(files, Options{Entry: "src/main.lua", Release: true, Target: "luajit", Defines: map[string]string{"GREETING": "hey"}})
+	require.NoError(t, err)
+	assert.Contains(t, result.Bundle, "jit=true", "target variants are read from the files")
+	assert.Contains(t, result.Bundle, `"hey"`)
+	assert.NotContains(t, result.Bundle, `print("debug")`)
+}
+
+func TestBundleErrors(t *testing.T) {
+	_, err := Bundle(map[string]string{"init.lua": "return 1"}, Options{})
+	assert.EqualError(t, err, "entry file main.lua is not among the files")
+
+	_, err = Bundle(map[string]string{"main.lua": `require("missing")`}, Options{})
+	assert.Error(t, err)
+
+	_, err = Bundle(map[string]string{"main.lua": "", "lua-bundler.json": "{"}, Options{})
+	assert.Error(t, err)
+
+	_, err = Bundle(map[string]string{"main.lua": ""}, Options{Loader: "eager"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var versionRegex = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// Version is a semantic version
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string
+	Build               string
+}
+
+// Parse parses a semantic version such as 1.4.0, v2.0.0-rc.1 or 1.0.0+abc
+func Parse(s string) (Version, error) {
+	m := versionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid semantic version %q", s)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return Version{Major: major, Minor: minor, Patch: patch, Prerelease: m[4], Build: m[5]}, nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Bump returns the next major, minor or patch version. Prerelease and build
+// metadata are dropped; bumping the patch of a prerelease releases it, so
+// 1.2.0-rc.1 becomes 1.2.0.
+func (v Version) Bump(part string) (Version, error) {
+	next := Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	switch part {
+	case "major":
+		if v.Prerelease == "" || v.Minor != 0 || v.Patch != 0 {
+			next.Major++
+		}
+		next.Minor, next.Patch = 0, 0
+	case "minor":
+		if v.Prerelease == "" || v.Patch != 0 {
+			next.Minor++
+		}
+		next.Patch = 0
+	case "patch":
+		if v.Prerelease == "" {
+			next.Patch++
+		}
+	default:
+		return Version{}, fmt.Errorf("unknown version part %q (expected major, minor or patch)", part)
+	}
+	return next, nil
+}
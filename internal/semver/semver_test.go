@@ -0,0 +1,45 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.4.2-rc.1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Major: 1, Minor: 4, Patch: 2, Prerelease: "rc.1", Build: "build.5"}, v)
+	assert.Equal(t, "1.4.2-rc.1+build.5", v.String())
+
+	for _, bad := range []string{"", "1.4", "1.04.0", "1.4.0-", "one.two.three"} {
+		_, err := Parse(bad)
+		assert.Error(t, err, "%q should be rejected", bad)
+	}
+}
+
+func TestBump(t *testing.T) {
+	tests := []struct {
+		from, part, want string
+	}{
+		{"1.4.2", "patch", "1.4.3"},
+		{"1.4.2", "minor", "1.5.0"},
+		{"1.4.2", "major", "2.0.0"},
+		{"1.4.2+abc", "patch", "1.4.3"},
+		{"1.5.0-rc.1", "patch", "1.5.0"},
+		{"1.5.0-rc.1", "minor", "1.5.0"},
+		{"1.5.1-rc.1", "minor", "1.6.0"},
+		{"2.0.0-beta", "major", "2.0.0"},
+	}
+	for _, tt := range tests {
+		v, err := Parse(tt.from)
+		require.NoError(t, err)
+		next, err := v.Bump(tt.part)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, next.String(), "%s %s", tt.from, tt.part)
+	}
+
+	_, err := Version{}.Bump("build")
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+package stubs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/constt/lua-bundler/internal/parser"
+)
+
+// MemberKind is how a member of an external module is used
+type MemberKind int
+
+const (
+	Value    MemberKind = iota // read or assigned
+	Function                   // called as M.name(...)
+	Method                     // called as M:name(...)
+)
+
+// Member is a field of an external module used by the bundled code
+type Member struct {
+	Name string
+	Kind MemberKind
+}
+
+// Stub is a generated stub file
+type Stub struct {
+	File         string // slash-separated path relative to the stub directory
+	Content      string
+	InstancePath []string // DataModel path for Roblox instance requires, else nil
+}
+
+// robloxServices maps the names instance requires may start with to the
+// service they refer to
+var robloxServices = map[string]string{
+	"workspace":         "Workspace",
+	"ReplicatedStorage": "ReplicatedStorage",
+	"ServerStorage":     "ServerStorage",
+	"StarterGui":        "StarterGui",
+	"StarterPack":       "StarterPack",
+	"StarterPlayer":     "StarterPlayer",
+	"Lighting":          "Lighting",
+	"SoundService":      "SoundService",
+	"TweenService":      "TweenService",
+	"HttpService":       "HttpService",
+	"RunService":        "RunService",
+	"UserInputService":  "UserInputService",
+	"Players":           "Players",
+	"Teams":             "Teams",
+	"Debris":            "Debris",
+	"CollectionService": "CollectionService",
+}
+
+// InstancePath returns the DataModel path of a Roblox instance require such
+// as game.ReplicatedStorage.Shared.Util, or nil if requirePath is not one
+func InstancePath(requirePath string) []string {
+	parts := strings.Split(requirePath, ".")
+	if parts[0] == "game" {
+		parts = parts[1:]
+		if len(parts) > 0 && parts[0] == "Workspace" {
+			return parts
+		}
+	}
+	if len(parts) < 2 {
+		return nil
+	}
+	service, ok := robloxServices[parts[0]]
+	if !ok {
+		return nil
+	}
+	for _, p := range parts[1:] {
+		if p == "" {
+			return nil
+		}
+	}
+	return append([]string{service}, parts[1:]...)
+}
+
+// Members returns the fields of the module required as requirePath that src
+// uses, through a local holding the require result or on the call directly
+func Members(src, requirePath string) ([]Member, error) {
+	chunk, err := parser.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	isRequire := func(e parser.Expr) bool {
+		call, ok := unparen(e).(*parser.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return false
+		}
+		fn, ok := call.Fn.(*parser.Ident)
+		return ok && fn.Name == "require" && exprPath(call.Args[0]) == requirePath
+	}
+
+	// Locals assigned the module
+	holders := make(map[*parser.Binding]bool)
+	parser.Walk(chunk, func(n parser.Node) bool {
+		if stmt, ok := n.(*parser.LocalStmt); ok {
+			for i, value := range stmt.Values {
+				if i < len(stmt.Names) && isRequire(value) && stmt.Names[i].Binding != nil {
+					holders[stmt.Names[i].Binding] = true
+				}
+			}
+		}
+		return true
+	})
+
+	isModule := func(e parser.Expr) bool {
+		if id, ok := e.(*parser.Ident); ok {
+			return id.Binding != nil && holders[id.Binding]
+		}
+		return isRequire(e)
+	}
+
+	kinds := make(map[string]MemberKind)
+	record := func(name string, kind MemberKind) {
+		if existing, ok := kinds[name]; !ok || kind > existing {
+			kinds[name] = kind
+		}
+	}
+	parser.Walk(chunk, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case *parser.CallExpr:
+			if field, ok := n.Fn.(*parser.FieldExpr); ok && isModule(field.X) {
+				record(field.Name.Value, Function)
+			}
+		case *parser.MethodCallExpr:
+			if isModule(n.Recv) {
+				record(n.Name.Value, Method)
+			}
+		case *parser.FieldExpr:
+			if isModule(n.X) {
+				record(n.Name.Value, Value)
+			}
+		}
+		return true
+	})
+
+	members := make([]Member, 0, len(kinds))
+	for name, kind := range kinds {
+		members = append(members, Member{Name: name, Kind: kind})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members, nil
+}
+
+// Generate returns the stub for requirePath. Luau stubs are typed for
+// luau-lsp; Lua stubs are LuaLS definition files.
+func Generate(requirePath string, requiredBy []string, members []Member, luau bool) Stub {
+	instance := InstancePath(requirePath)
+	var segments []string
+	if instance != nil {
+		segments = instance
+	} else {
+		segments = strings.FieldsFunc(strings.Trim(requirePath, "@"), func(r rune) bool {
+			return r == '.' || r == '/' || r == ':'
+		})
+	}
+	local := localName(segments[len(segments)-1])
+
+	var out strings.Builder
+	if luau {
+		out.WriteString("--!strict\n")
+	} else {
+		out.WriteString("---@meta\n")
+	}
+	fmt.Fprintf(&out, "-- Stub for require(%s), which the bundle leaves to the runtime.\n", requirePath)
+	fmt.Fprintf(&out, "-- Generated by lua-bundler from its uses in: %s\n\n", strings.Join(requiredBy, ", "))
+	fmt.Fprintf(&out, "local %s = {}\n", local)
+	if len(members) > 0 {
+		out.WriteString("\n")
+	}
+	for _, m := range members {
+		switch {
+		case m.Kind == Value && luau:
+			fmt.Fprintf(&out, "%s.%s = (nil :: any)\n", local, m.Name)
+		case m.Kind == Value:
+			fmt.Fprintf(&out, "---@type any\n%s.%s = nil\n", local, m.Name)
+		case luau:
+			sep := map[MemberKind]string{Function: ".", Method: ":"}[m.Kind]
+			fmt.Fprintf(&out, "function %s%s%s(...: any): ...any end\n", local, sep, m.Name)
+		default:
+			sep := map[MemberKind]string{Function: ".", Method: ":"}[m.Kind]
+			fmt.Fprintf(&out, "---@return any\nfunction %s%s%s(...) end\n", local, sep, m.Name)
+		}
+	}
+	fmt.Fprintf(&out, "\nreturn %s\n", local)
+
+	ext := ".lua"
+	if luau {
+		ext = ".luau"
+	}
+	return Stub{File: path.Join(segments...) + ext, Content: out.String(), InstancePath: instance}
+}
+
+// Sourcemap returns a Rojo-format sourcemap.json mapping the DataModel paths
+// of instance stubs to their files, prefixed with dir, so luau-lsp resolves
+// the instance requires to the stubs
+func Sourcemap(stubs []Stub, dir string) ([]byte, error) {
+	type node struct {
+		Name      string   `json:"name"`
+		ClassName string   `json:"className"`
+		FilePaths []string `json:"filePaths,omitempty"`
+		Children  []*node  `json:"children,omitempty"`
+	}
+	root := &node{Name: "Game", ClassName: "DataModel"}
+	child := func(parent *node, name, class string) *node {
+		for _, c := range parent.Children {
+			if c.Name == name {
+				return c
+			}
+		}
+		c := &node{Name: name, ClassName: class}
+		parent.Children = append(parent.Children, c)
+		return c
+	}
+
+	for _, s := range stubs {
+		if s.InstancePath == nil {
+			continue
+		}
+		n := child(root, s.InstancePath[0], s.InstancePath[0])
+		for _, name := range s.InstancePath[1:] {
+			n = child(n, name, "Folder")
+		}
+		n.ClassName = "ModuleScript"
+		n.FilePaths = []string{path.Join(dir, s.File)}
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// exprPath renders a require argument as the path the bundler recorded
+func exprPath(e parser.Expr) string {
+	switch e := unparen(e).(type) {
+	case *parser.StringExpr:
+		s, _ := e.Token.Unquote()
+		return s
+	case *parser.Ident:
+		return e.Name
+	case *parser.FieldExpr:
+		if x := exprPath(e.X); x != "" {
+			return x + "." + e.Name.Value
+		}
+	}
+	return ""
+}
+
+func unparen(e parser.Expr) parser.Expr {
+	for {
+		p, ok := e.(*parser.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
+
+// localName turns a path segment into a Lua identifier for the stub's table
+func localName(segment string) string {
+	var b strings.Builder
+	for i, r := range segment {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	name := b.String()
+	if name == "" || parser.IsKeyword(name) {
+		name = "module_" + name
+	}
+	return name
+}
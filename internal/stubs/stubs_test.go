@@ -0,0 +1,116 @@
+package stubs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstancePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"game.ReplicatedStorage.Shared.Util", []string{"ReplicatedStorage", "Shared", "Util"}},
+		{"ReplicatedStorage.Shared.Util", []string{"ReplicatedStorage", "Shared", "Util"}},
+		{"workspace.Modules.Map", []string{"Workspace", "Modules", "Map"}},
+		{"game.Workspace.Map", []string{"Workspace", "Map"}},
+		{"ReplicatedStorage", nil},
+		{"pkg::mod", nil},
+		{"utils", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, InstancePath(tt.path), tt.path)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	src := `local Util = require(game.ReplicatedStorage.Util)
+local other = require(game.ReplicatedStorage.Other)
+print(Util.format("x"), Util.VERSION)
+Util:Connect()
+other.ignored()
+require(game.ReplicatedStorage.Util).direct()
+`
+	members, err := Members(src, "game.ReplicatedStorage.Util")
+	require.NoError(t, err)
+
+	kinds := make(map[string]MemberKind)
+	for _, m := range members {
+		kinds[m.Name] = m.Kind
+	}
+	assert.Equal(t, map[string]MemberKind{
+		"format":  Function,
+		"VERSION": Value,
+		"Connect": Method,
+		"direct":  Function,
+	}, kinds)
+}
+
+func TestMembersParseError(t *testing.T) {
+	_, err := Members("local = =", "game.ReplicatedStorage.Util")
+	assert.Error(t, err)
+}
+
+func TestGenerateLuau(t *testing.T) {
+	stub := Generate("game.ReplicatedStorage.Shared.Util", []string{"main.lua"}, []Member{
+		{Name: "format", Kind: Function},
+		{Name: "Connect", Kind: Method},
+		{Name: "VERSION", Kind: Value},
+	}, true)
+
+	assert.Equal(t, "ReplicatedStorage/Shared/Util.luau", stub.File)
+	assert.Equal(t, []string{"ReplicatedStorage", "Shared", "Util"}, stub.InstancePath)
+	assert.Contains(t, stub.Content, "--!strict")
+	assert.Contains(t, stub.Content, "local Util = {}")
+	assert.Contains(t, stub.Content, "function Util.format(...: any): ...any end")
+	assert.Contains(t, stub.Content, "function Util:Connect(...: any): ...any end")
+	assert.Contains(t, stub.Content, "Util.VERSION = (nil :: any)")
+	assert.Contains(t, stub.Content, "return Util")
+}
+
+func TestGenerateLuaLS(t *testing.T) {
+	stub := Generate("pkg::mod", []string{"main.lua"}, []Member{{Name: "run", Kind: Function}}, false)
+
+	assert.Equal(t, "pkg/mod.lua", stub.File)
+	assert.Nil(t, stub.InstancePath)
+	assert.Contains(t, stub.Content, "---@meta")
+	assert.NotContains(t, stub.Content, "--!strict")
+	assert.Contains(t, stub.Content, "run")
+}
+
+func TestSourcemap(t *testing.T) {
+	stubs := []Stub{
+		Generate("game.ReplicatedStorage.Shared.Util", nil, nil, true),
+		Generate("ReplicatedStorage.Net", nil, nil, true),
+		Generate("pkg::mod", nil, nil, true),
+	}
+	data, err := Sourcemap(stubs, "types")
+	require.NoError(t, err)
+
+	var root struct {
+		ClassName string
+		Children  []struct {
+			Name     string
+			Children []struct {
+				Name      string
+				ClassName string
+				FilePaths []string
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(data, &root))
+	assert.Equal(t, "DataModel", root.ClassName)
+	require.Len(t, root.Children, 1, "pkg::mod is not an instance and should be left out")
+	assert.Equal(t, "ReplicatedStorage", root.Children[0].Name)
+
+	children := root.Children[0].Children
+	require.Len(t, children, 2)
+	assert.Equal(t, "Shared", children[0].Name)
+	assert.Equal(t, "Folder", children[0].ClassName)
+	assert.Equal(t, "Net", children[1].Name)
+	assert.Equal(t, "ModuleScript", children[1].ClassName)
+	assert.Equal(t, []string{"types/ReplicatedStorage/Net.luau"}, children[1].FilePaths)
+}
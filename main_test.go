@@ -168,3 +168,103 @@ func TestMain_Version(t *testing.T) {
 	// Version testing would require more complex setup to verify
 	// the version is actually set correctly
 }
+
+// gitRepo commits files to a new git repository and returns its file:// URL
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return "file://" + filepath.ToSlash(dir)
+}
+
+func TestMain_GitCheckoutRemoved(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "lua-bundler-git-test")
+	require.NoError(t, exec.Command("go", "build", "-o", binary, ".").Run(), "Failed to build binary")
+	repo := gitRepo(t, map[string]string{
+		"main.lua":   "print(require(\"util\"))\n",
+		"util.lua":   "return 1\n",
+		"broken.lua": "require(\"missing\")\n",
+	})
+
+	for _, tt := range []struct {
+		entry       string
+		expectError bool
+	}{
+		{"main.lua", false},
+		{"broken.lua", true},
+	} {
+		t.Run(tt.entry, func(t *testing.T) {
+			tmp := t.TempDir()
+			cmd := exec.Command(binary, "--git", repo, "-e", tt.entry, "-o", filepath.Join(t.TempDir(), "bundle.lua"))
+			cmd.Env = append(os.Environ(), "TMPDIR="+tmp)
+			out, err := cmd.CombinedOutput()
+			if tt.expectError {
+				assert.Error(t, err, string(out))
+			} else {
+				assert.NoError(t, err, string(out))
+			}
+			leftover, _ := filepath.Glob(filepath.Join(tmp, "lua-bundler-git-*"))
+			assert.Empty(t, leftover, "the checkout should be removed on every exit")
+		})
+	}
+}
+
+func TestMain_ServerTokens(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "lua-bundler-tokens-test")
+	require.NoError(t, exec.Command("go", "build", "-o", binary, ".").Run(), "Failed to build binary")
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.lua")
+	require.NoError(t, os.WriteFile(mainFile, []byte("print(1)\n"), 0644))
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"build API without a token", []string{"--serve", "--build-api"}, "--build-api needs --serve and a token"},
+		{"variants with the build token", []string{"--serve", "--serve-variants", "--build-token", "s3cret"}, "--serve-variants needs --serve and a token (--variants-token"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(binary, append([]string{"-e", mainFile, "-o", filepath.Join(dir, "bundle.lua")}, tt.args...)...)
+			cmd.Env = append(os.Environ(), "LUA_BUNDLER_BUILD_TOKEN=", "LUA_BUNDLER_VARIANTS_TOKEN=")
+			out, err := cmd.CombinedOutput()
+			assert.Error(t, err)
+			assert.Contains(t, string(out), tt.want)
+		})
+	}
+}
+
+func TestMain_GitConfigSyncIgnored(t *testing.T) {
+	if _, err := exec.LookPath("touch"); err != nil {
+		t.Skip("touch is not installed")
+	}
+	binary := filepath.Join(t.TempDir(), "lua-bundler-git-sync-test")
+	require.NoError(t, exec.Command("go", "build", "-o", binary, ".").Run(), "Failed to build binary")
+	marker := filepath.Join(t.TempDir(), "pwned")
+	repo := gitRepo(t, map[string]string{
+		"main.lua":         "print(1)\n",
+		"lua-bundler.json": `{"sync": [{"run": ["touch", "` + filepath.ToSlash(marker) + `"]}]}`,
+	})
+
+	out, err := exec.Command(binary, "--git", repo, "-o", filepath.Join(t.TempDir(), "bundle.lua")).CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "--trust-config")
+	assert.NoFileExists(t, marker, "the checkout's commands should not run")
+
+	out, err = exec.Command(binary, "--git", repo, "-o", filepath.Join(t.TempDir(), "bundle.lua"), "--trust-config").CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.FileExists(t, marker, "--trust-config runs them")
+}
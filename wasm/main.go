@@ -0,0 +1,68 @@
+//go:build js && wasm
+
+// Command wasm exposes the bundler to JavaScript as
+// luaBundler.bundle(files, options), which returns a promise of
+// {bundle, warnings, modules}
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/constt/lua-bundler/internal/playground"
+)
+
+func main() {
+	js.Global().Set("luaBundler", map[string]interface{}{
+		"bundle": js.FuncOf(bundle),
+	})
+	select {}
+}
+
+// bundle runs a build in the background, since downloads of remote modules
+// block on fetch and must not run on the JavaScript event loop
+func bundle(this js.Value, args []js.Value) interface{} {
+	jsonValue := js.Global().Get("JSON")
+	arg := func(i int) string {
+		if i >= len(args) || args[i].IsUndefined() || args[i].IsNull() {
+			return "{}"
+		}
+		return jsonValue.Call("stringify", args[i]).String()
+	}
+	filesJSON, optsJSON := arg(0), arg(1)
+
+	executor := js.FuncOf(func(this js.Value, p []js.Value) interface{} {
+		resolve, reject := p[0], p[1]
+		go func() {
+			fail := func(err error) {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+			}
+
+			var files map[string]string
+			if err := json.Unmarshal([]byte(filesJSON), &files); err != nil {
+				fail(err)
+				return
+			}
+			var opts playground.Options
+			if err := json.Unmarshal([]byte(optsJSON), &opts); err != nil {
+				fail(err)
+				return
+			}
+
+			result, err := playground.Bundle(files, opts)
+			if err != nil {
+				fail(err)
+				return
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				fail(err)
+				return
+			}
+			resolve.Invoke(jsonValue.Call("parse", string(data)))
+		}()
+		return nil
+	})
+	defer executor.Release()
+	return js.Global().Get("Promise").New(executor)
+}